@@ -75,6 +75,7 @@ type cmdOpts struct {
 	builderImage     string
 	updateMetadata   bool
 	validateMetadata bool
+	strictMetadata   bool
 	btfgen           bool
 	btfhubarchive    string
 }
@@ -108,6 +109,7 @@ func NewBuildCmd() *cobra.Command {
 	cmd.Flags().StringVar(&opts.builderImage, "builder-image", builderImage, "Builder image to use")
 	cmd.Flags().BoolVar(&opts.updateMetadata, "update-metadata", false, "Update the metadata according to the eBPF code")
 	cmd.Flags().BoolVar(&opts.validateMetadata, "validate-metadata", true, "Validate the metadata file before building the gadget image")
+	cmd.Flags().BoolVar(&opts.strictMetadata, "strict-metadata", false, "Fail the validation of the metadata file if it still has placeholders")
 
 	cmd.Flags().BoolVar(&opts.btfgen, "btfgen", false, "Enable btfgen")
 	cmd.Flags().StringVar(&opts.btfhubarchive, "btfhub-archive", "", "Path to the location of the btfhub-archive files")
@@ -228,6 +230,7 @@ func runBuild(cmd *cobra.Command, opts *cmdOpts) error {
 		MetadataPath:     conf.Metadata,
 		UpdateMetadata:   opts.updateMetadata,
 		ValidateMetadata: opts.validateMetadata,
+		StrictMetadata:   opts.strictMetadata,
 	}
 
 	if sourceDateEpoch, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
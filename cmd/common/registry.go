@@ -751,6 +751,7 @@ func AddFlags(cmd *cobra.Command, params *params.Params, skipParams []params.Val
 		if p.IsMandatory {
 			cmd.MarkPersistentFlagRequired(p.Key)
 		}
+		flag.Hidden = p.IsHidden()
 
 		// Allow passing a boolean flag as --foo instead of having to use --foo=true
 		if p.IsBoolFlag() {
@@ -41,11 +41,44 @@
 #define GADGET_TOPPER(name, map_name) \
 	const void *gadget_topper_##name##___##map_name __attribute__((unused));
 
+// GADGET_PROFILER is used to define a profiler. Currently only one profiler per eBPF object is allowed.
+// name is the profiler's name
+// map_name is the name of the array or hash map holding the log2 histograms. Its values are an
+// array of __u64 slots, or a struct with such an array as its only member.
+#define GADGET_PROFILER(name, map_name) \
+	const void *gadget_profiler_##name##___##map_name __attribute__((unused));
+
 // GADGET_PARAM is used to indicate that a given variable is used as a parameter.
 // Users of Inspektor Gadget can set these values from userspace
 #define GADGET_PARAM(name) \
 	const void * gadget_param_##name __attribute__((unused));
 
+// GADGET_PARAM_DEFAULT sets the default value of a parameter defined with
+// GADGET_PARAM. value is a string literal, like "10" or "true".
+#define GADGET_PARAM_DEFAULT(name, value) \
+	const char gadget_param_default_##name[] __attribute__((unused)) = value;
+
+// GADGET_PARAM_DESC sets the description of a parameter defined with
+// GADGET_PARAM. Values set in the metadata file take precedence.
+#define GADGET_PARAM_DESC(name, desc) \
+	const char gadget_param_desc_##name[] __attribute__((unused)) = desc;
+
+// GADGET_PARAM_MANDATORY makes a parameter defined with GADGET_PARAM
+// mandatory: the gadget isn't loaded until users set it. It can't have a
+// default value.
+#define GADGET_PARAM_MANDATORY(name) \
+	const char gadget_param_mandatory_##name[] __attribute__((unused)) = "";
+
+// GADGET_NAME sets the name of the gadget. The name set in the metadata file
+// takes precedence.
+#define GADGET_NAME(name) \
+	const char gadget_info_name[] __attribute__((unused)) = name;
+
+// GADGET_DESCRIPTION sets the description of the gadget. The description set
+// in the metadata file takes precedence.
+#define GADGET_DESCRIPTION(desc) \
+	const char gadget_info_description[] __attribute__((unused)) = desc;
+
 // GADGET_SNAPSHOTTER is used to define a snapshotter:
 // name is the snapshotter's name
 // type is the name of the structure that describes each element in a snapshot
@@ -55,4 +88,12 @@
 	const struct type *unusedevent_##name##___##type __attribute__((unused)); \
     __GADGET_SNAPSHOTTER_IMPL(name, type, __VA_ARGS__)
 
+// GADGET_MAP_SNAPSHOTTER is used to define a snapshotter dumping a map instead of running
+// iterators. Each entry of the snapshot is the key of the map followed by its value.
+// name is the snapshotter's name
+// map_name is the name of the array or hash map to dump. Its keys and values are structs, arrays
+// don't need a key struct.
+#define GADGET_MAP_SNAPSHOTTER(name, map_name) \
+	const void *gadget_map_snapshotter_##name##___##map_name __attribute__((unused));
+
 #endif /* __MACROS_H */
@@ -0,0 +1,19 @@
+/* SPDX-License-Identifier: (GPL-2.0 WITH Linux-syscall-note) OR Apache-2.0 */
+
+#ifndef PROVENANCE_H
+#define PROVENANCE_H
+
+#include <gadget/types.h>
+
+#include <bpf/bpf_helpers.h>
+
+// gadget_get_program_id returns the id of the running program. Inspektor Gadget passes it as the
+// BPF cookie when attaching kprobes, kretprobes, tracepoints, fentry, fexit and LSM programs; it's
+// 0 for other program types or on kernels without BPF cookie support.
+// Keep this aligned with pkg/gadgets/provenance.go
+static __always_inline gadget_program_id gadget_get_program_id(void *ctx)
+{
+	return bpf_get_attach_cookie(ctx);
+}
+
+#endif
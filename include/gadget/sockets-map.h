@@ -44,6 +44,9 @@
 
 #define SE_TASK_COMM_LEN 16
 
+// The gadget_sockets map is shared by all gadgets. Any change to sockets_key
+// or sockets_value must increase SocketsMapLayoutVersion in
+// pkg/socketenricher/tracer.go.
 struct sockets_key {
 	__u32 netns;
 	__u16 family;
@@ -47,4 +47,9 @@ typedef __u64 gadget_syscall;
 
 typedef __u32 gadget_kernel_stack;
 
+// gadget_program_id identifies the program that produced an event. Set it with
+// gadget_get_program_id() from <gadget/provenance.h>. Fields with the program name, attach
+// point and direction are automatically added.
+typedef __u64 gadget_program_id;
+
 #endif /* __TYPES_H */
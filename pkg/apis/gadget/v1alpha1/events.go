@@ -0,0 +1,37 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MaxTraceEvents is the number of events kept in TraceStatus.Events
+const MaxTraceEvents = 50
+
+// AddEvent records an event in the event log of the trace, evicting the
+// oldest one if the log is full.
+func (s *TraceStatus) AddEvent(typ TraceEventType, fields map[string]string) {
+	s.Events = append(s.Events, TraceEvent{
+		Sequence: s.EvictedEvents + int64(len(s.Events)) + 1,
+		Time:     metav1.Now(),
+		Type:     typ,
+		Fields:   fields,
+	})
+	if evicted := len(s.Events) - MaxTraceEvents; evicted > 0 {
+		s.Events = append([]TraceEvent(nil), s.Events[evicted:]...)
+		s.EvictedEvents += int64(evicted)
+	}
+}
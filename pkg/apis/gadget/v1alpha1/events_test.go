@@ -0,0 +1,42 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddEvent(t *testing.T) {
+	t.Parallel()
+
+	status := &TraceStatus{}
+	for i := 0; i < MaxTraceEvents+5; i++ {
+		status.AddEvent(TraceEventOperation, map[string]string{"operation": fmt.Sprintf("op%d", i)})
+	}
+
+	require.Len(t, status.Events, MaxTraceEvents)
+	require.Equal(t, int64(5), status.EvictedEvents)
+
+	// Oldest events are evicted first and sequence numbers keep increasing
+	require.Equal(t, int64(6), status.Events[0].Sequence)
+	require.Equal(t, "op5", status.Events[0].Fields["operation"])
+	last := status.Events[len(status.Events)-1]
+	require.Equal(t, int64(MaxTraceEvents+5), last.Sequence)
+	require.Equal(t, fmt.Sprintf("op%d", MaxTraceEvents+4), last.Fields["operation"])
+	require.False(t, last.Time.IsZero())
+}
@@ -156,6 +156,48 @@ type TraceStatus struct {
 	// OperationError that represents a fatal error, the OperationWarning could
 	// be ignored according to the context.
 	OperationWarning string `json:"operationWarning,omitempty"`
+
+	// Events is the log of the last events of the trace, oldest first. It's
+	// bounded: the oldest events are evicted when new ones are recorded.
+	Events []TraceEvent `json:"events,omitempty"`
+
+	// EvictedEvents is the number of events evicted from Events
+	EvictedEvents int64 `json:"evictedEvents,omitempty"`
+}
+
+// TraceEventType is the type of an event of the trace
+// +kubebuilder:validation:Enum=StateChanged;Operation;OperationError;OperationWarning
+type TraceEventType string
+
+const (
+	// TraceEventStateChanged records a change of the state of the trace.
+	// Its fields are "from" and "to".
+	TraceEventStateChanged TraceEventType = "StateChanged"
+	// TraceEventOperation records an operation applied on the trace. Its
+	// fields are "operation" and the parameters of the operation.
+	TraceEventOperation TraceEventType = "Operation"
+	// TraceEventOperationError records an error returned by the gadget or
+	// the controller. Its fields are "operation", if any, and "error".
+	TraceEventOperationError TraceEventType = "OperationError"
+	// TraceEventOperationWarning records a warning returned by the gadget.
+	// Its fields are "operation" and "warning".
+	TraceEventOperationWarning TraceEventType = "OperationWarning"
+)
+
+// TraceEvent is an entry of the event log of a trace
+type TraceEvent struct {
+	// Sequence is the number of the event. It increases monotonically over
+	// the life of the trace, evicted events included.
+	Sequence int64 `json:"sequence"`
+
+	// Time is when the event was recorded
+	Time metav1.Time `json:"time"`
+
+	// Type is the type of the event, which defines its fields
+	Type TraceEventType `json:"type"`
+
+	// Fields holds the details of the event
+	Fields map[string]string `json:"fields,omitempty"`
 }
 
 // +genclient
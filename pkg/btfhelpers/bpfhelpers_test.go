@@ -15,11 +15,15 @@
 package btfhelpers
 
 import (
+	"encoding/binary"
+	"math"
 	"reflect"
 	"testing"
 
 	"github.com/cilium/ebpf/btf"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 var int32Type = &btf.Int{
@@ -227,6 +231,19 @@ func TestGetType(t *testing.T) {
 			expectedType:  reflect.TypeOf(uint16(0)),
 			expectedNames: []string{},
 		},
+		{
+			name: "qualified typedefs",
+			typ: &btf.Const{
+				Type: &btf.Typedef{
+					Type: &btf.Volatile{
+						Type: int32Type,
+					},
+					Name: "typedef1",
+				},
+			},
+			expectedType:  reflect.TypeOf(int32(0)),
+			expectedNames: []string{"typedef1", "int32"},
+		},
 		// TODO: checks structures
 	}
 
@@ -247,12 +264,12 @@ func TestGetUnderlyingType(t *testing.T) {
 
 	tests := []struct {
 		name         string
-		typDef       *btf.Typedef
+		typ          btf.Type
 		expectedType btf.Type
 	}{
 		{
 			name: "typedef",
-			typDef: &btf.Typedef{
+			typ: &btf.Typedef{
 				Type: int32Type,
 				Name: "typedef",
 			},
@@ -260,7 +277,7 @@ func TestGetUnderlyingType(t *testing.T) {
 		},
 		{
 			name: "typedef typedef",
-			typDef: &btf.Typedef{
+			typ: &btf.Typedef{
 				Type: &btf.Typedef{
 					Type: int32Type,
 					Name: "typedef",
@@ -269,6 +286,60 @@ func TestGetUnderlyingType(t *testing.T) {
 			},
 			expectedType: int32Type,
 		},
+		{
+			name:         "not qualified",
+			typ:          int32Type,
+			expectedType: int32Type,
+		},
+		{
+			name:         "const",
+			typ:          &btf.Const{Type: int32Type},
+			expectedType: int32Type,
+		},
+		{
+			name: "const volatile",
+			typ: &btf.Const{
+				Type: &btf.Volatile{Type: int32Type},
+			},
+			expectedType: int32Type,
+		},
+		{
+			name: "typedef const typedef volatile",
+			typ: &btf.Typedef{
+				Type: &btf.Const{
+					Type: &btf.Typedef{
+						Type: &btf.Volatile{Type: int32Type},
+						Name: "inner",
+					},
+				},
+				Name: "outer",
+			},
+			expectedType: int32Type,
+		},
+		{
+			name: "volatile typedef const",
+			typ: &btf.Volatile{
+				Type: &btf.Typedef{
+					Type: &btf.Const{Type: int32Type},
+					Name: "typedef",
+				},
+			},
+			expectedType: int32Type,
+		},
+		{
+			name: "restrict pointer",
+			typ: &btf.Restrict{
+				Type: &btf.Pointer{Target: int32Type},
+			},
+			expectedType: &btf.Pointer{Target: int32Type},
+		},
+		{
+			name: "pointer to const",
+			typ: &btf.Pointer{
+				Target: &btf.Const{Type: int32Type},
+			},
+			expectedType: &btf.Pointer{Target: &btf.Const{Type: int32Type}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -276,8 +347,266 @@ func TestGetUnderlyingType(t *testing.T) {
 
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			retTyp := GetUnderlyingType(tt.typDef)
+			retTyp := GetUnderlyingType(tt.typ)
 			assert.Equal(t, tt.expectedType, retTyp)
 		})
 	}
 }
+
+func TestQualifiers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		typ              btf.Type
+		expectedConst    bool
+		expectedVolatile bool
+	}{
+		{
+			name: "int",
+			typ:  int32Type,
+		},
+		{
+			name:          "const",
+			typ:           &btf.Const{Type: int32Type},
+			expectedConst: true,
+		},
+		{
+			name: "const volatile",
+			typ: &btf.Const{
+				Type: &btf.Volatile{Type: int32Type},
+			},
+			expectedConst:    true,
+			expectedVolatile: true,
+		},
+		{
+			name: "volatile const",
+			typ: &btf.Volatile{
+				Type: &btf.Const{Type: int32Type},
+			},
+			expectedConst:    true,
+			expectedVolatile: true,
+		},
+		{
+			name: "typedef const typedef volatile",
+			typ: &btf.Typedef{
+				Type: &btf.Const{
+					Type: &btf.Typedef{
+						Type: &btf.Volatile{Type: int32Type},
+						Name: "inner",
+					},
+				},
+				Name: "outer",
+			},
+			expectedConst:    true,
+			expectedVolatile: true,
+		},
+		{
+			name: "pointer to const",
+			typ: &btf.Pointer{
+				Target: &btf.Const{Type: int32Type},
+			},
+		},
+		{
+			name: "array of const volatile",
+			typ: &btf.Array{
+				Type:   &btf.Volatile{Type: &btf.Const{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}}},
+				Nelems: 16,
+			},
+			expectedConst:    true,
+			expectedVolatile: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			isConst, isVolatile := Qualifiers(tt.typ)
+			assert.Equal(t, tt.expectedConst, isConst)
+			assert.Equal(t, tt.expectedVolatile, isVolatile)
+		})
+	}
+}
+
+func TestCharArrayLen(t *testing.T) {
+	t.Parallel()
+
+	charType := &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}
+
+	tests := []struct {
+		name        string
+		typ         btf.Type
+		expectedLen uint32
+		expectedOk  bool
+	}{
+		{
+			name:        "char array",
+			typ:         &btf.Array{Type: charType, Nelems: 16},
+			expectedLen: 16,
+			expectedOk:  true,
+		},
+		{
+			name: "const volatile char array",
+			typ: &btf.Array{
+				Type:   &btf.Const{Type: &btf.Volatile{Type: charType}},
+				Nelems: 256,
+			},
+			expectedLen: 256,
+			expectedOk:  true,
+		},
+		{
+			name: "int array",
+			typ:  &btf.Array{Type: int32Type, Nelems: 4},
+		},
+		{
+			name: "char",
+			typ:  charType,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			n, ok := CharArrayLen(tt.typ)
+			assert.Equal(t, tt.expectedOk, ok)
+			assert.Equal(t, tt.expectedLen, n)
+		})
+	}
+}
+
+func TestIsBool(t *testing.T) {
+	t.Parallel()
+
+	boolType := &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}
+	charType := &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}
+	u8Type := &btf.Typedef{Name: "__u8", Type: &btf.Int{Name: "unsigned char", Size: 1}}
+
+	assert.True(t, IsBool(&btf.Const{Type: &btf.Volatile{Type: boolType}}))
+	assert.True(t, IsBool(charType))
+	assert.False(t, IsBool(u8Type))
+	assert.False(t, IsBool(int32Type))
+
+	assert.True(t, IsByte(boolType))
+	assert.True(t, IsByte(u8Type))
+	assert.False(t, IsByte(int32Type))
+	assert.False(t, IsByte(&btf.Array{Type: charType, Nelems: 1}))
+}
+
+func TestIntArray(t *testing.T) {
+	t.Parallel()
+
+	u16Type := &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}
+
+	elemType, n, ok := IntArray(&btf.Const{Type: &btf.Volatile{Type: &btf.Array{Type: u16Type, Nelems: 4}}})
+	assert.True(t, ok)
+	assert.Equal(t, params.TypeUint16, elemType)
+	assert.Equal(t, uint32(4), n)
+
+	elemType, _, ok = IntArray(&btf.Array{Type: int32Type, Nelems: 2})
+	assert.True(t, ok)
+	assert.Equal(t, params.TypeInt32, elemType)
+
+	_, _, ok = IntArray(&btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}, Nelems: 16})
+	assert.False(t, ok)
+	_, _, ok = IntArray(int32Type)
+	assert.False(t, ok)
+}
+
+func TestEnumValue(t *testing.T) {
+	t.Parallel()
+
+	unsigned := &btf.Enum{Name: "mode", Size: 4, Values: []btf.EnumValue{
+		{Name: "MODE_NONE", Value: 0},
+		{Name: "MODE_FULL", Value: 4},
+	}}
+	signed := &btf.Enum{Name: "level", Size: 4, Signed: true, Values: []btf.EnumValue{
+		{Name: "LEVEL_OFF", Value: math.MaxUint64},
+		{Name: "LEVEL_ON", Value: 1},
+	}}
+
+	enum, ok := AsEnum(&btf.Const{Type: &btf.Typedef{Name: "mode_t", Type: unsigned}})
+	assert.True(t, ok)
+	assert.Equal(t, unsigned, enum)
+	_, ok = AsEnum(int32Type)
+	assert.False(t, ok)
+
+	for value, expected := range map[string]uint64{"MODE_FULL": 4, "4": 4, "0x4": 4, "0": 0} {
+		n, ok := EnumValue(unsigned, value)
+		assert.True(t, ok, value)
+		assert.Equal(t, expected, n, value)
+	}
+	for _, value := range []string{"MODE_ALL", "1", "-1", ""} {
+		_, ok := EnumValue(unsigned, value)
+		assert.False(t, ok, value)
+	}
+
+	n, ok := EnumValue(signed, "-1")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(math.MaxUint64), n)
+	assert.Equal(t, "-1", FormatEnumValue(signed, n))
+
+	assert.Equal(t, []string{"MODE_NONE", "MODE_FULL"}, EnumNames(unsigned))
+	assert.Equal(t, []string{"enum:LEVEL_OFF=-1", "enum:LEVEL_ON=1"}, EnumParamTags(signed))
+}
+
+func TestReadBitfield(t *testing.T) {
+	t.Parallel()
+
+	// struct {
+	//	__u16 a:1, b:1, c:4, d:5, e:5;
+	//	__s8 f:3;
+	// };
+	//
+	// d crosses the byte boundary
+	little := []byte{
+		0b11_0110_0_1, // d[1:0]=3, c=6, b=0, a=1
+		0b10101_101,   // e=21, d[4:2]=5
+		0b00000_110,   // f=-2
+	}
+	big := []byte{
+		0b1_0_0110_10, // a=1, b=0, c=6, d[4:3]=2
+		0b111_10101,   // d[2:0]=7, e=21
+		0b110_00000,   // f=-2
+	}
+
+	tests := []struct {
+		name     string
+		offset   btf.Bits
+		size     btf.Bits
+		signed   bool
+		expected uint64
+	}{
+		{name: "a", offset: 0, size: 1, expected: 1},
+		{name: "b", offset: 1, size: 1, expected: 0},
+		{name: "c", offset: 2, size: 4, expected: 6},
+		{name: "d", offset: 6, size: 5, expected: 0b10111},
+		{name: "e", offset: 11, size: 5, expected: 21},
+		{name: "f", offset: 16, size: 3, signed: true, expected: uint64(0xfffffffffffffffe)},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, test.expected, ReadBitfield(little, test.offset, test.size, binary.LittleEndian, test.signed))
+			assert.Equal(t, test.expected, ReadBitfield(big, test.offset, test.size, binary.BigEndian, test.signed))
+		})
+	}
+}
+
+func TestBitfieldBytes(t *testing.T) {
+	t.Parallel()
+
+	start, end := BitfieldBytes(6, 5)
+	assert.Equal(t, uint32(0), start)
+	assert.Equal(t, uint32(2), end)
+
+	start, end = BitfieldBytes(16, 3)
+	assert.Equal(t, uint32(2), start)
+	assert.Equal(t, uint32(3), end)
+}
@@ -17,9 +17,16 @@
 package btfhelpers
 
 import (
+	"encoding/binary"
+	"errors"
+	"fmt"
 	"reflect"
+	"strconv"
 
+	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
 // GetType returns the reflect.Type for a given BTF type and the list of type names found while
@@ -49,6 +56,12 @@ func GetType(typ btf.Type) (reflect.Type, []string) {
 		default:
 			return GetType(typed)
 		}
+	case *btf.Const:
+		return GetType(typed.Type)
+	case *btf.Volatile:
+		return GetType(typed.Type)
+	case *btf.Restrict:
+		return GetType(typed.Type)
 	default:
 		refType = getSimpleType(typ)
 	}
@@ -56,13 +69,46 @@ func GetType(typ btf.Type) (reflect.Type, []string) {
 	return refType, typeNames
 }
 
-// GetUnderlyingType returns the underlying type of a typedef
-func GetUnderlyingType(tf *btf.Typedef) btf.Type {
-	switch typed := tf.Type.(type) {
-	case *btf.Typedef:
-		return GetUnderlyingType(typed)
-	default:
-		return typed
+// GetUnderlyingType returns the type behind typedefs and const, volatile and
+// restrict qualifiers, in any order
+func GetUnderlyingType(typ btf.Type) btf.Type {
+	for {
+		switch typed := typ.(type) {
+		case *btf.Typedef:
+			typ = typed.Type
+		case *btf.Const:
+			typ = typed.Type
+		case *btf.Volatile:
+			typ = typed.Type
+		case *btf.Restrict:
+			typ = typed.Type
+		default:
+			return typ
+		}
+	}
+}
+
+// Qualifiers returns whether typ is const and volatile, looking through
+// typedefs and qualifiers in any order. The qualifiers of an array are the ones
+// of its elements, as in C.
+func Qualifiers(typ btf.Type) (isConst, isVolatile bool) {
+	for {
+		switch typed := typ.(type) {
+		case *btf.Typedef:
+			typ = typed.Type
+		case *btf.Array:
+			typ = typed.Type
+		case *btf.Const:
+			isConst = true
+			typ = typed.Type
+		case *btf.Volatile:
+			isVolatile = true
+			typ = typed.Type
+		case *btf.Restrict:
+			typ = typed.Type
+		default:
+			return isConst, isVolatile
+		}
 	}
 }
 
@@ -131,3 +177,261 @@ func getSimpleType(typ btf.Type) reflect.Type {
 	}
 	return nil
 }
+
+// GetTypeHint returns the param type hint for a BTF type, following typedefs and
+// qualifiers. It returns params.TypeUnknown for types without a matching hint.
+func GetTypeHint(typ btf.Type) params.TypeHint {
+	switch typedMember := GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		switch typedMember.Encoding {
+		case btf.Signed:
+			switch typedMember.Size {
+			case 1:
+				return params.TypeInt8
+			case 2:
+				return params.TypeInt16
+			case 4:
+				return params.TypeInt32
+			case 8:
+				return params.TypeInt64
+			}
+		case btf.Unsigned:
+			switch typedMember.Size {
+			case 1:
+				return params.TypeUint8
+			case 2:
+				return params.TypeUint16
+			case 4:
+				return params.TypeUint32
+			case 8:
+				return params.TypeUint64
+			}
+		case btf.Bool:
+			return params.TypeBool
+		case btf.Char:
+			return params.TypeUint8
+		}
+	case *btf.Float:
+		switch typedMember.Size {
+		case 4:
+			return params.TypeFloat32
+		case 8:
+			return params.TypeFloat64
+		}
+	case *btf.Array:
+		if _, ok := CharArrayLen(typedMember); ok {
+			return params.TypeString
+		}
+	}
+
+	return params.TypeUnknown
+}
+
+// CharArrayLen returns the number of elements of typ if it's an array of chars,
+// following typedefs and qualifiers
+func CharArrayLen(typ btf.Type) (uint32, bool) {
+	array, ok := GetUnderlyingType(typ).(*btf.Array)
+	if !ok {
+		return 0, false
+	}
+	elem, ok := GetUnderlyingType(array.Type).(*btf.Int)
+	if !ok || elem.Size != 1 || elem.Encoding == btf.Bool {
+		return 0, false
+	}
+	return array.Nelems, true
+}
+
+// IntArray returns the type of the elements and the number of elements of typ
+// if it's an array of integers wider than a byte, following typedefs and
+// qualifiers. Arrays of bytes are strings.
+func IntArray(typ btf.Type) (params.TypeHint, uint32, bool) {
+	array, ok := GetUnderlyingType(typ).(*btf.Array)
+	if !ok {
+		return params.TypeUnknown, 0, false
+	}
+	elem, ok := GetUnderlyingType(array.Type).(*btf.Int)
+	if !ok || elem.Size == 1 || elem.Encoding == btf.Bool {
+		return params.TypeUnknown, 0, false
+	}
+	return GetTypeHint(elem), array.Nelems, true
+}
+
+// IsBool returns whether typ is a 1-byte integer encoded as a bool or a char,
+// following typedefs and qualifiers. Such variables back bool params.
+func IsBool(typ btf.Type) bool {
+	i, ok := GetUnderlyingType(typ).(*btf.Int)
+	return ok && i.Size == 1 && (i.Encoding == btf.Bool || i.Encoding == btf.Char)
+}
+
+// IsByte returns whether typ is a 1-byte integer, whatever its encoding,
+// following typedefs and qualifiers
+func IsByte(typ btf.Type) bool {
+	i, ok := GetUnderlyingType(typ).(*btf.Int)
+	return ok && i.Size == 1
+}
+
+// AsEnum returns typ as an enum, following typedefs and qualifiers
+func AsEnum(typ btf.Type) (*btf.Enum, bool) {
+	enum, ok := GetUnderlyingType(typ).(*btf.Enum)
+	return enum, ok
+}
+
+// EnumValue returns the value of the enumerator of enum named value or whose
+// number is value, like "2" or "0x2". Negative values of signed enums are sign
+// extended.
+func EnumValue(enum *btf.Enum, value string) (uint64, bool) {
+	for _, v := range enum.Values {
+		if v.Name == value {
+			return v.Value, true
+		}
+	}
+
+	var n uint64
+	if enum.Signed {
+		i, err := strconv.ParseInt(value, 0, 64)
+		if err != nil {
+			return 0, false
+		}
+		n = uint64(i)
+	} else {
+		u, err := strconv.ParseUint(value, 0, 64)
+		if err != nil {
+			return 0, false
+		}
+		n = u
+	}
+	for _, v := range enum.Values {
+		if v.Value == n {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// FormatEnumValue returns the number of an enumerator of enum as a decimal
+// string, negative for the negative values of signed enums
+func FormatEnumValue(enum *btf.Enum, value uint64) string {
+	if enum.Signed {
+		return strconv.FormatInt(int64(value), 10)
+	}
+	return strconv.FormatUint(value, 10)
+}
+
+// EnumParamTags returns the tags giving the numbers of the enumerators of enum,
+// letting a param backed by it accept them as well as their names
+func EnumParamTags(enum *btf.Enum) []string {
+	tags := make([]string, 0, len(enum.Values))
+	for _, v := range enum.Values {
+		tags = append(tags, fmt.Sprintf("%s%s=%s", params.TagEnumPrefix, v.Name, FormatEnumValue(enum, v.Value)))
+	}
+	return tags
+}
+
+// EnumNames returns the names of the enumerators of enum
+func EnumNames(enum *btf.Enum) []string {
+	names := make([]string, 0, len(enum.Values))
+	for _, v := range enum.Values {
+		names = append(names, v.Name)
+	}
+	return names
+}
+
+// IsSigned returns whether typ is a signed integer or enum, following typedefs
+// and qualifiers
+func IsSigned(typ btf.Type) bool {
+	switch typed := GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		return typed.Encoding == btf.Signed
+	case *btf.Enum:
+		return typed.Signed
+	}
+	return false
+}
+
+// GetBitfieldType returns the smallest reflect.Type able to hold a bitfield of
+// the given size. One-bit unsigned bitfields are handled as booleans.
+func GetBitfieldType(size btf.Bits, signed bool) reflect.Type {
+	switch {
+	case size == 1 && !signed:
+		return reflect.TypeOf(false)
+	case size <= 8:
+		if signed {
+			return reflect.TypeOf(int8(0))
+		}
+		return reflect.TypeOf(uint8(0))
+	case size <= 16:
+		if signed {
+			return reflect.TypeOf(int16(0))
+		}
+		return reflect.TypeOf(uint16(0))
+	case size <= 32:
+		if signed {
+			return reflect.TypeOf(int32(0))
+		}
+		return reflect.TypeOf(uint32(0))
+	}
+	if signed {
+		return reflect.TypeOf(int64(0))
+	}
+	return reflect.TypeOf(uint64(0))
+}
+
+// BitfieldBytes returns the range of bytes [start, end) of a struct holding a
+// bitfield at the given bit offset.
+func BitfieldBytes(offset, size btf.Bits) (uint32, uint32) {
+	return uint32(offset / 8), uint32((offset + size + 7) / 8)
+}
+
+// ReadBitfield extracts a bitfield of the given size starting at bit offset
+// of b. As in BTF, bits are numbered from the least significant bit of the
+// first byte for little endian and from the most significant one for big
+// endian. Signed values are sign-extended.
+func ReadBitfield(b []byte, offset, size btf.Bits, byteOrder binary.ByteOrder, signed bool) uint64 {
+	littleEndian := byteOrder.Uint16([]byte{1, 0}) == 1
+
+	var val uint64
+	for i := btf.Bits(0); i < size; i++ {
+		bit := offset + i
+		if littleEndian {
+			if b[bit/8]&(1<<(bit%8)) != 0 {
+				val |= 1 << i
+			}
+		} else {
+			if b[bit/8]&(0x80>>(bit%8)) != 0 {
+				val |= 1 << (size - 1 - i)
+			}
+		}
+	}
+
+	if signed && size < 64 && val&(1<<(size-1)) != 0 {
+		val |= ^uint64(0) << size
+	}
+	return val
+}
+
+var ErrMapNoBTFValue = errors.New("map spec does not contain a BTF Value")
+
+// DataSection returns the contents and BTF Datasec descriptor of the spec.
+// borrowed from cilium/ebpf
+func DataSection(ms *ebpf.MapSpec) ([]byte, *btf.Datasec, error) {
+	if ms.Value == nil {
+		return nil, nil, ErrMapNoBTFValue
+	}
+
+	ds, ok := ms.Value.(*btf.Datasec)
+	if !ok {
+		return nil, nil, fmt.Errorf("map value BTF is a %T, not a *btf.Datasec", ms.Value)
+	}
+
+	if n := len(ms.Contents); n != 1 {
+		return nil, nil, fmt.Errorf("expected one key, found %d", n)
+	}
+
+	kv := ms.Contents[0]
+	value, ok := kv.Value.([]byte)
+	if !ok {
+		return nil, nil, fmt.Errorf("value at first map key is %T, not []byte", kv.Value)
+	}
+
+	return value, ds, nil
+}
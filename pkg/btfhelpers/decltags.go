@@ -0,0 +1,103 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package btfhelpers
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/cilium/ebpf/btf"
+)
+
+type declTagKey struct {
+	kind reflect.Type
+	name string
+}
+
+// DeclTags holds the values of the btf_decl_tag attributes of a BTF spec
+type DeclTags struct {
+	// Named types are looked up by name, as the same type can be copied
+	// several times while loading a collection
+	byName map[declTagKey]map[int][]string
+	byType map[btf.Type]map[int][]string
+}
+
+// GetDeclTags returns the decl tags found in spec
+func GetDeclTags(spec *btf.Spec) DeclTags {
+	tags := DeclTags{
+		byName: make(map[declTagKey]map[int][]string),
+		byType: make(map[btf.Type]map[int][]string),
+	}
+	if spec == nil {
+		return tags
+	}
+
+	it := spec.Iterate()
+	for it.Next() {
+		target, value, index, ok := declTag(it.Type)
+		if !ok || target == nil {
+			continue
+		}
+
+		var byIndex map[int][]string
+		if target.TypeName() != "" {
+			key := declTagKey{reflect.TypeOf(target), target.TypeName()}
+			if tags.byName[key] == nil {
+				tags.byName[key] = make(map[int][]string)
+			}
+			byIndex = tags.byName[key]
+		} else {
+			if tags.byType[target] == nil {
+				tags.byType[target] = make(map[int][]string)
+			}
+			byIndex = tags.byType[target]
+		}
+		byIndex[index] = append(byIndex[index], value)
+	}
+
+	return tags
+}
+
+// Get returns the tags of the member with the given index of typ, or the ones
+// of typ itself if index is -1
+func (d DeclTags) Get(typ btf.Type, index int) []string {
+	if typ == nil {
+		return nil
+	}
+	if typ.TypeName() != "" {
+		return d.byName[declTagKey{reflect.TypeOf(typ), typ.TypeName()}][index]
+	}
+	return d.byType[typ][index]
+}
+
+// declTag returns the fields of typ if it's a decl tag. cilium/ebpf doesn't
+// export that type, so they're read through reflection.
+func declTag(typ btf.Type) (btf.Type, string, int, bool) {
+	v := reflect.ValueOf(typ)
+	if v.Kind() != reflect.Pointer || v.Type().String() != "*btf.declTag" {
+		return nil, "", 0, false
+	}
+	v = v.Elem()
+
+	targetField := v.FieldByName("Type")
+	valueField := v.FieldByName("Value")
+	indexField := v.FieldByName("Index")
+	if !targetField.IsValid() || valueField.Kind() != reflect.String || indexField.Kind() != reflect.Int {
+		return nil, "", 0, false
+	}
+
+	target, _ := reflect.NewAt(targetField.Type(), unsafe.Pointer(targetField.UnsafeAddr())).Elem().Interface().(btf.Type)
+	return target, valueField.String(), int(indexField.Int()), true
+}
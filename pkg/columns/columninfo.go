@@ -65,6 +65,8 @@ type Attributes struct {
 	EllipsisType ellipsis.EllipsisType `yaml:"ellipsis_type"`
 	// FixedWidth forces the Width even when using Auto-Scaling
 	FixedWidth bool `yaml:"fixed_width"`
+	// AutoWidth sizes the column from the values it shows, between MinWidth and MaxWidth
+	AutoWidth bool `yaml:"auto_width"`
 	// Precision defines how many decimals should be shown on float values, default: 2
 	Precision int `yaml:"precision"`
 	// Description can hold a short description of the field that can be used to aid the user
@@ -75,11 +77,15 @@ type Attributes struct {
 	Tags []string `yaml:"tags"`
 	// Template defines the template that will be used. Non-typed templates will be applied first.
 	Template string `yaml:"template"`
+	// Group is the name of a set of related columns; it's used as prefix in the header. Not to be
+	// confused with GroupType, which is about aggregating rows.
+	Group string `yaml:"group"`
 }
 
 type Column[T any] struct {
 	Attributes
-	Extractor func(*T) any // Extractor to be used; this can be defined to transform the output before retrieving the actual value
+	Extractor func(*T) any    // Extractor to be used; this can be defined to transform the output before retrieving the actual value
+	Style     func(*T) string // Style returns the ANSI escape sequence used to highlight the value of an entry in terminals; "" for none
 
 	explicitName  bool                    // true, if the name has been set explicitly
 	offset        uintptr                 // offset to the field (relative to root non-ptr struct)
@@ -194,6 +200,11 @@ func (ci *Column[T]) parseTagInfo(tagInfo []string) error {
 				return fmt.Errorf("parameter fixed on field %q must not have a value", ci.Name)
 			}
 			ci.FixedWidth = true
+		case "autoWidth":
+			if paramsLen != 1 {
+				return fmt.Errorf("parameter autoWidth on field %q must not have a value", ci.Name)
+			}
+			ci.AutoWidth = true
 		case "group":
 			if paramsLen == 1 {
 				return fmt.Errorf("missing group value for field %q", ci.Name)
@@ -431,6 +431,19 @@ func (c *Columns[T]) SetExtractor(columnName string, extractor func(*T) any) err
 	return nil
 }
 
+// SetStyle sets the function returning the ANSI escape sequence used to highlight the values of a specific column
+func (c *Columns[T]) SetStyle(columnName string, style func(*T) string) error {
+	if style == nil {
+		return fmt.Errorf("style func must be non-nil")
+	}
+	column, ok := c.ColumnMap[strings.ToLower(columnName)]
+	if !ok {
+		return fmt.Errorf("field %q not found", columnName)
+	}
+	column.Style = style
+	return nil
+}
+
 // MustSetExtractor adds a new extractor to a column and panics if it cannot successfully do so
 func (c *Columns[T]) MustSetExtractor(columnName string, extractor func(*T) any) {
 	err := c.SetExtractor(columnName, extractor)
@@ -53,6 +53,7 @@ The parameter could be used for specific options, passing nil will use the defau
 	| Attribute | Value(s)               | Description                                                                                                          |
 	|-----------|------------------------|----------------------------------------------------------------------------------------------------------------------|
 	| align     | left,right             | defines the alignment of the column (whitespace before or after the value)                                           |
+	| autoWidth | none                   | sizes the column from the values it shows, between minWidth and maxWidth (see textcolumns.Options)                   |
 	| ellipsis  | none,left,right,middle | defines how situations of content exceeding the given space should be handled, eg: where to place the ellipsis ("…") |
 	| fixed     | none                   | defines that this column will have a fixed width, even when auto-scaling is enabled                                  |
 	| group     | sum                    | defines what should happen with the field whenever entries are grouped (see grouping)                                |
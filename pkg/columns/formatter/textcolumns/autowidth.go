@@ -0,0 +1,108 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textcolumns
+
+// Columns with the AutoWidth attribute are sized from the values they show.
+// They start as wide as their header (or MinWidth) and grow to fit the
+// values, up to MaxWidth; they never shrink.
+//
+// Tables (WriteTable, FormatTable) measure all their entries before writing
+// the header, so snapshots are sized from the full snapshot. Streams measure
+// their first AutoWidthSamples entries: these rows use the widths known so
+// far and, once the last of them has been measured, the header is written
+// again if a column grew since it was written. Widths don't change afterwards.
+
+// isFixed returns whether the width of the column doesn't depend on the
+// screen width
+func (c *Column[T]) isFixed() bool {
+	return c.col.FixedWidth || c.col.AutoWidth
+}
+
+// width returns the width configured for the column or, for AutoWidth
+// columns, the measured one
+func (c *Column[T]) width() int {
+	if c.col.AutoWidth {
+		return c.autoWidth
+	}
+	return c.col.Width
+}
+
+func (tf *TextColumnsFormatter[T]) initialAutoWidth(column *Column[T]) int {
+	return column.clampAutoWidth(max(len([]rune(tf.headerName(column))), column.col.MinWidth))
+}
+
+func (c *Column[T]) clampAutoWidth(width int) int {
+	if c.col.MaxWidth > 0 {
+		return min(width, c.col.MaxWidth)
+	}
+	return width
+}
+
+// measure grows the AutoWidth columns to fit entry and returns whether any of
+// them grew
+func (tf *TextColumnsFormatter[T]) measure(entry *T) bool {
+	grew := false
+	for _, column := range tf.showColumns {
+		if !column.col.AutoWidth {
+			continue
+		}
+		width := column.clampAutoWidth(len([]rune(column.extractor(entry))))
+		if width > column.autoWidth {
+			column.autoWidth = width
+			grew = true
+		}
+	}
+	return grew
+}
+
+func (tf *TextColumnsFormatter[T]) applyAutoWidths() {
+	for _, column := range tf.showColumns {
+		if column.col.AutoWidth {
+			column.calculatedWidth = column.autoWidth
+		}
+	}
+	tf.rebuild()
+}
+
+// MeasureEntries grows the AutoWidth columns to fit entries. It returns
+// whether any of them grew.
+func (tf *TextColumnsFormatter[T]) MeasureEntries(entries []*T) bool {
+	grew := false
+	for _, entry := range entries {
+		if entry != nil && tf.measure(entry) {
+			grew = true
+		}
+	}
+	if grew {
+		tf.applyAutoWidths()
+	}
+	return grew
+}
+
+// MeasureStreamEntry grows the AutoWidth columns to fit entry if it's one of
+// the first AutoWidthSamples entries of a stream. It returns true once, after
+// the last of them, if the header has to be written again because a column
+// grew since it was written.
+func (tf *TextColumnsFormatter[T]) MeasureStreamEntry(entry *T) bool {
+	if entry == nil || tf.streamSamples >= tf.options.AutoWidthSamples {
+		return false
+	}
+	tf.streamSamples++
+	if tf.measure(entry) {
+		tf.applyAutoWidths()
+		tf.streamGrew = true
+	}
+	return tf.streamSamples == tf.options.AutoWidthSamples && tf.streamGrew
+}
@@ -32,22 +32,26 @@ const (
 type Option func(*Options)
 
 type Options struct {
-	AutoScale      bool        // if enabled, the screen size will be used to scale the widths
-	ColumnDivider  string      // defines the string that should be used as spacer in between columns (default " ")
-	DefaultColumns []string    // defines which columns to show by default; will be set to all visible columns if nil
-	HeaderStyle    HeaderStyle // defines how column headers are decorated (e.g. uppercase/lowercase)
-	RowDivider     string      // defines the (to be repeated) string that should be used below the header
-	ShouldTruncate bool        // defines whether to truncate strings or not
+	AutoScale        bool        // if enabled, the screen size will be used to scale the widths
+	AutoWidthSamples int         // number of entries of a stream measured to size AutoWidth columns (default 10)
+	Colors           bool        // if enabled, values of columns with a style are highlighted using ANSI escape sequences
+	ColumnDivider    string      // defines the string that should be used as spacer in between columns (default " ")
+	DefaultColumns   []string    // defines which columns to show by default; will be set to all visible columns if nil
+	HeaderStyle      HeaderStyle // defines how column headers are decorated (e.g. uppercase/lowercase)
+	RowDivider       string      // defines the (to be repeated) string that should be used below the header
+	ShouldTruncate   bool        // defines whether to truncate strings or not
 }
 
 func DefaultOptions() *Options {
 	return &Options{
-		AutoScale:      true,
-		ColumnDivider:  DividerSpace,
-		DefaultColumns: nil,
-		HeaderStyle:    HeaderStyleUppercase,
-		RowDivider:     DividerNone,
-		ShouldTruncate: true,
+		AutoScale:        true,
+		AutoWidthSamples: 10,
+		Colors:           false,
+		ColumnDivider:    DividerSpace,
+		DefaultColumns:   nil,
+		HeaderStyle:      HeaderStyleUppercase,
+		RowDivider:       DividerNone,
+		ShouldTruncate:   true,
 	}
 }
 
@@ -58,6 +62,20 @@ func WithAutoScale(autosScale bool) Option {
 	}
 }
 
+// WithAutoWidthSamples sets the number of entries of a stream that are measured to size AutoWidth columns
+func WithAutoWidthSamples(samples int) Option {
+	return func(opts *Options) {
+		opts.AutoWidthSamples = samples
+	}
+}
+
+// WithColors sets whether the styles of columns should be applied; only enable it for terminals
+func WithColors(colors bool) Option {
+	return func(opts *Options) {
+		opts.Colors = colors
+	}
+}
+
 // WithColumnDivider sets the string that should be used as divider between columns
 func WithColumnDivider(divider string) Option {
 	return func(opts *Options) {
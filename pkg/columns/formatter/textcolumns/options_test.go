@@ -30,6 +30,16 @@ func TestOptions(t *testing.T) {
 		t.Errorf("Expected AutoScale to be true")
 	}
 
+	WithAutoWidthSamples(3)(opts)
+	if opts.AutoWidthSamples != 3 {
+		t.Errorf("Expected AutoWidthSamples to be 3")
+	}
+
+	WithColors(true)(opts)
+	if !opts.Colors {
+		t.Errorf("Expected Colors to be true")
+	}
+
 	WithColumnDivider("X")(opts)
 	if opts.ColumnDivider != "X" {
 		t.Errorf("Expected ColumnDivider to be X")
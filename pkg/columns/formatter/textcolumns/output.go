@@ -24,10 +24,22 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/ellipsis"
 )
 
+// ansiReset is the ANSI escape sequence resetting colors and styles
+const ansiReset = "\x1b[0m"
+
 func (tf *TextColumnsFormatter[T]) setFormatter(column *Column[T]) {
 	ff := columns.GetFieldAsStringExt[T](column.col, 'f', column.col.Precision)
+	column.extractor = ff
 	column.formatter = func(entry *T) string {
-		return tf.buildFixedString(ff(entry), column.calculatedWidth, column.col.EllipsisType, column.col.Alignment)
+		s := tf.buildFixedString(ff(entry), column.calculatedWidth, column.col.EllipsisType, column.col.Alignment)
+		if tf.options.Colors && column.col.Style != nil {
+			// The padding is styled as well, it keeps the sequences out of
+			// the width calculations
+			if seq := column.col.Style(entry); seq != "" {
+				return seq + s + ansiReset
+			}
+		}
+		return s
 	}
 }
 
@@ -71,23 +83,32 @@ func (tf *TextColumnsFormatter[T]) FormatEntry(entry *T) string {
 // FormatHeader returns the formatted header line with all visible column names, separated by ColumnDivider
 func (tf *TextColumnsFormatter[T]) FormatHeader() string {
 	tf.AdjustWidthsToScreen()
+	tf.streamGrew = false
 	var row strings.Builder
 	for i, column := range tf.showColumns {
 		if i > 0 {
 			row.WriteString(tf.options.ColumnDivider)
 		}
-		name := column.col.Name
-		switch tf.options.HeaderStyle {
-		case HeaderStyleUppercase:
-			name = strings.ToUpper(name)
-		case HeaderStyleLowercase:
-			name = strings.ToLower(name)
-		}
-		row.WriteString(tf.buildFixedString(name, column.calculatedWidth, ellipsis.End, column.col.Alignment))
+		row.WriteString(tf.buildFixedString(tf.headerName(column), column.calculatedWidth, ellipsis.End, column.col.Alignment))
 	}
 	return row.String()
 }
 
+// headerName returns the name of column as shown in the header
+func (tf *TextColumnsFormatter[T]) headerName(column *Column[T]) string {
+	name := column.col.Name
+	if group := column.col.Group; group != "" && !strings.HasPrefix(strings.ToLower(name), strings.ToLower(group)+".") {
+		name = group + "." + name
+	}
+	switch tf.options.HeaderStyle {
+	case HeaderStyleUppercase:
+		name = strings.ToUpper(name)
+	case HeaderStyleLowercase:
+		name = strings.ToLower(name)
+	}
+	return name
+}
+
 // FormatRowDivider returns a string that repeats the defined RowDivider until the total length of a row is reached
 func (tf *TextColumnsFormatter[T]) FormatRowDivider() string {
 	if tf.options.RowDivider == DividerNone {
@@ -117,6 +138,7 @@ func (tf *TextColumnsFormatter[T]) FormatTable(entries []*T) string {
 
 // WriteTable writes header, divider and the formatted entries with the current settings to writer
 func (tf *TextColumnsFormatter[T]) WriteTable(writer io.Writer, entries []*T) error {
+	tf.MeasureEntries(entries)
 	_, err := writer.Write([]byte(tf.FormatHeader()))
 	if err != nil {
 		return err
@@ -62,9 +62,9 @@ func (tf *TextColumnsFormatter[T]) RecalculateWidths(maxWidth int, force bool) {
 
 		occurrences[column.col.Name]++
 
-		if column.col.FixedWidth && !force {
-			requiredWidth += column.col.Width
-			totalWidthFixed += column.col.Width
+		if column.isFixed() && !force {
+			requiredWidth += column.width()
+			totalWidthFixed += column.width()
 			continue
 		}
 
@@ -102,9 +102,9 @@ func (tf *TextColumnsFormatter[T]) RecalculateWidths(maxWidth int, force bool) {
 
 		totalAdjustedWidthNotFixed = 0
 		for _, column := range tf.showColumns {
-			if (column.col.FixedWidth || column.treatAsFixed) && !force {
-				if column.col.FixedWidth {
-					column.calculatedWidth = column.col.Width
+			if (column.isFixed() || column.treatAsFixed) && !force {
+				if column.isFixed() {
+					column.calculatedWidth = column.width()
 				}
 				continue
 			}
@@ -155,7 +155,7 @@ distributeLeftover:
 
 		// distribute one to each remaining candidate
 		for _, column := range tf.showColumns {
-			if (column.col.FixedWidth || column.treatAsFixed) && !force {
+			if (column.isFixed() || column.treatAsFixed) && !force {
 				continue
 			}
 
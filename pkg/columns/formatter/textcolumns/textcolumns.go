@@ -26,6 +26,8 @@ type Column[T any] struct {
 	col             *columns.Column[T]
 	calculatedWidth int
 	treatAsFixed    bool
+	autoWidth       int // width measured for AutoWidth columns
+	extractor       func(*T) string
 	formatter       func(*T) string
 }
 
@@ -35,6 +37,11 @@ type TextColumnsFormatter[T any] struct {
 	currentMaxWidth int
 	showColumns     []*Column[T]
 	fillString      string
+
+	// streamSamples counts the entries of a stream measured for AutoWidth
+	// columns; streamGrew is set if a column grew since the header was written
+	streamSamples int
+	streamGrew    bool
 }
 
 // NewFormatter returns a TextColumnsFormatter that will turn entries of type T into tables that can be shown
@@ -60,6 +67,10 @@ func NewFormatter[T any](columns columns.ColumnMap[T], options ...Option) *TextC
 
 	for _, column := range tf.columns {
 		tf.setFormatter(column)
+		if column.col.AutoWidth {
+			column.autoWidth = tf.initialAutoWidth(column)
+			column.calculatedWidth = column.autoWidth
+		}
 	}
 
 	tf.SetShowColumns(opts.DefaultColumns)
@@ -123,7 +134,7 @@ func (tf *TextColumnsFormatter[T]) SetAutoScale(enableAutoScale bool) {
 	} else {
 		// Set calculated width to configured widths
 		for _, column := range tf.columns {
-			column.calculatedWidth = column.col.Width
+			column.calculatedWidth = column.width()
 			column.treatAsFixed = false
 		}
 		tf.buildFillString()
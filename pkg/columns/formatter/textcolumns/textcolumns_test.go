@@ -296,3 +296,70 @@ func TestDynamicFields(t *testing.T) {
 	assert.Equal(t, "STR              INT32            BOOL            ", formatter.FormatHeader())
 	assert.Equal(t, "foobar           1234567890       true            ", formatter.FormatEntry(&empty{}))
 }
+
+type autoWidthStruct struct {
+	Pid       uint32 `column:"pid,width:7,fixed"`
+	Container string `column:"container,autoWidth,minWidth:4,maxWidth:12"`
+}
+
+func TestAutoWidthSnapshot(t *testing.T) {
+	cols := columns.MustCreateColumns[autoWidthStruct]().GetColumnMap()
+
+	formatter := NewFormatter(cols, WithAutoScale(false))
+	assert.Equal(t, "PID     CONTAINER", formatter.FormatHeader())
+
+	entries := []*autoWidthStruct{
+		{1, "db"},
+		{2, "frontend"},
+		{3, "a-very-long-container-name"},
+	}
+	assert.Equal(t, strings.Join([]string{
+		"PID     CONTAINER   ",
+		"1       db          ",
+		"2       frontend    ",
+		"3       a-very-long…",
+	}, "\n"), formatter.FormatTable(entries))
+
+	// Widths never shrink
+	formatter.FormatTable([]*autoWidthStruct{{4, "db"}})
+	assert.Equal(t, "PID     CONTAINER   ", formatter.FormatHeader())
+}
+
+func TestAutoWidthShortSnapshot(t *testing.T) {
+	cols := columns.MustCreateColumns[autoWidthStruct]().GetColumnMap()
+
+	formatter := NewFormatter(cols, WithAutoScale(false))
+	assert.Equal(t, strings.Join([]string{
+		"PID     CONTAINER",
+		"1       db       ",
+		"2       web      ",
+	}, "\n"), formatter.FormatTable([]*autoWidthStruct{{1, "db"}, {2, "web"}}))
+}
+
+func TestAutoWidthStream(t *testing.T) {
+	cols := columns.MustCreateColumns[autoWidthStruct]().GetColumnMap()
+
+	formatter := NewFormatter(cols, WithAutoScale(false), WithAutoWidthSamples(3))
+	assert.Equal(t, "PID     CONTAINER", formatter.FormatHeader())
+
+	// The header has to be written again once, after the last sample
+	assert.False(t, formatter.MeasureStreamEntry(&autoWidthStruct{1, "db"}))
+	assert.False(t, formatter.MeasureStreamEntry(&autoWidthStruct{2, "frontend-1234"}))
+	assert.True(t, formatter.MeasureStreamEntry(&autoWidthStruct{3, "web"}))
+	assert.Equal(t, "PID     CONTAINER   ", formatter.FormatHeader())
+
+	// Entries after the samples don't change the widths
+	assert.False(t, formatter.MeasureStreamEntry(&autoWidthStruct{4, "a-very-long-container-name"}))
+	assert.Equal(t, "4       a-very-long…", formatter.FormatEntry(&autoWidthStruct{4, "a-very-long-container-name"}))
+}
+
+func TestAutoWidthStreamNoGrowth(t *testing.T) {
+	cols := columns.MustCreateColumns[autoWidthStruct]().GetColumnMap()
+
+	formatter := NewFormatter(cols, WithAutoScale(false), WithAutoWidthSamples(2))
+	formatter.FormatHeader()
+
+	assert.False(t, formatter.MeasureStreamEntry(&autoWidthStruct{1, "db"}))
+	assert.False(t, formatter.MeasureStreamEntry(&autoWidthStruct{2, "web"}))
+	assert.Equal(t, "PID     CONTAINER", formatter.FormatHeader())
+}
@@ -61,3 +61,9 @@ func getTemplate(name string) (string, bool) {
 	tpl, ok := templates[name]
 	return tpl, ok
 }
+
+// HasTemplate returns whether a template has been registered as name
+func HasTemplate(name string) bool {
+	_, ok := getTemplate(name)
+	return ok
+}
@@ -96,6 +96,9 @@ type K8sMetadata struct {
 	types.BasicK8sMetadata `json:",inline"`
 	PodUID                 string `json:"podUID,omitempty"`
 
+	// PodPorts are the ports declared by the containers of the pod
+	PodPorts []int32 `json:"podPorts,omitempty"`
+
 	ownerReference *metav1.OwnerReference
 }
 
@@ -172,6 +172,7 @@ func (k *K8sClient) GetRunningContainers(pod *v1.Pod) []Container {
 					ContainerName: s.Name,
 					PodLabels:     labels,
 				},
+				PodPorts: podPorts(pod),
 			},
 		}
 		containers = append(containers, containerDef)
@@ -180,6 +181,18 @@ func (k *K8sClient) GetRunningContainers(pod *v1.Pod) []Container {
 	return containers
 }
 
+// podPorts returns the ports declared by the containers of the pod, in the
+// order they appear in the spec.
+func podPorts(pod *v1.Pod) []int32 {
+	var ports []int32
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			ports = append(ports, p.ContainerPort)
+		}
+	}
+	return ports
+}
+
 // ListContainers return a list of the current containers that are
 // running in the node.
 func (k *K8sClient) ListContainers() (arr []Container, err error) {
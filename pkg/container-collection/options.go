@@ -569,6 +569,7 @@ func WithKubernetesEnrichment(nodeName string, kubeconfig *rest.Config) Containe
 			container.K8s.PodName = pod.ObjectMeta.Name
 			container.K8s.PodUID = string(pod.ObjectMeta.UID)
 			container.K8s.PodLabels = pod.ObjectMeta.Labels
+			container.K8s.PodPorts = podPorts(pod)
 
 			// drop pause containers
 			if container.K8s.PodName != "" && container.K8s.ContainerName == "" {
@@ -0,0 +1,240 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package containertemplate resolves template expressions like
+// "{{ .Container.Image.Entrypoint }}" or "{{ .Pod.Ports[0] }}" against the
+// metadata of a container. Templates are parsed and validated once, before
+// the gadget starts, and resolved for each container the gadget is attached
+// to.
+//
+// Besides the text/template syntax, "[n]" can be used to index slices.
+package containertemplate
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+)
+
+// LookupVar is the name of the gadget context variable holding a
+// LookupFunc, set by the operator managing the containers
+const LookupVar = "ContainerLookupByMntNs"
+
+// LookupFunc returns the container with the given mount namespace id or nil
+type LookupFunc func(mntns uint64) *containercollection.Container
+
+type Image struct {
+	Name string
+	// Entrypoint is the executable of the container process
+	Entrypoint string
+	// Args are the arguments of the container process, including the
+	// entrypoint
+	Args []string
+}
+
+type Container struct {
+	ID      string
+	Name    string
+	Runtime string
+	Image   Image
+	Pid     uint32
+	Mntns   uint64
+	Netns   uint64
+}
+
+type Pod struct {
+	Name      string
+	Namespace string
+	UID       string
+	Labels    map[string]string
+	Ports     []int32
+}
+
+// Data is what templates are executed against
+type Data struct {
+	Container Container
+	Pod       Pod
+}
+
+// NewData returns the template data of c
+func NewData(c *containercollection.Container) *Data {
+	d := &Data{
+		Container: Container{
+			ID:      c.Runtime.ContainerID,
+			Name:    c.Runtime.ContainerName,
+			Runtime: string(c.Runtime.RuntimeName),
+			Image: Image{
+				Name: c.Runtime.ContainerImageName,
+			},
+			Pid:   c.Pid,
+			Mntns: c.Mntns,
+			Netns: c.Netns,
+		},
+		Pod: Pod{
+			Name:      c.K8s.PodName,
+			Namespace: c.K8s.Namespace,
+			UID:       c.K8s.PodUID,
+			Labels:    c.K8s.PodLabels,
+			Ports:     c.K8s.PodPorts,
+		},
+	}
+	if c.K8s.ContainerName != "" {
+		d.Container.Name = c.K8s.ContainerName
+	}
+	if c.OciConfig != nil && c.OciConfig.Process != nil && len(c.OciConfig.Process.Args) > 0 {
+		d.Container.Image.Entrypoint = c.OciConfig.Process.Args[0]
+		d.Container.Image.Args = c.OciConfig.Process.Args
+	}
+	return d
+}
+
+var (
+	actionRegex = regexp.MustCompile(`{{.*?}}`)
+	indexRegex  = regexp.MustCompile(`(\.[A-Za-z_][A-Za-z0-9_.]*)\[([0-9]+)\]`)
+)
+
+// IsTemplate returns whether s contains template expressions
+func IsTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+// Template is a parsed and validated template
+type Template struct {
+	text string
+	tmpl *template.Template
+}
+
+// Parse parses text and checks that it only refers to existing fields of Data
+func Parse(text string) (*Template, error) {
+	// Rewrite .Foo[0] to (index .Foo 0) inside of actions
+	rewritten := actionRegex.ReplaceAllStringFunc(text, func(action string) string {
+		return indexRegex.ReplaceAllString(action, "(index $1 $2)")
+	})
+
+	tmpl, err := template.New("").Option("missingkey=error").Parse(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %q: %w", text, err)
+	}
+
+	if err := validateNode(tmpl.Tree.Root, reflect.TypeOf(Data{})); err != nil {
+		return nil, fmt.Errorf("validating template %q: %w", text, err)
+	}
+
+	return &Template{text: text, tmpl: tmpl}, nil
+}
+
+// Validate checks the template syntax of s, if any
+func Validate(s string) error {
+	if !IsTemplate(s) {
+		return nil
+	}
+	_, err := Parse(s)
+	return err
+}
+
+func (t *Template) String() string {
+	return t.text
+}
+
+// Resolve executes the template for container c. An error is returned if the
+// template can't be resolved for c, e.g. because the information isn't
+// available, or if it resolves to an empty value.
+func (t *Template) Resolve(c *containercollection.Container) (string, error) {
+	var out bytes.Buffer
+	if err := t.tmpl.Execute(&out, NewData(c)); err != nil {
+		var execErr template.ExecError
+		if errors.As(err, &execErr) {
+			err = execErr.Err
+		}
+		return "", fmt.Errorf("resolving template %q: %w", t.text, err)
+	}
+	if out.Len() == 0 {
+		return "", fmt.Errorf("resolving template %q: empty value", t.text)
+	}
+	return out.String(), nil
+}
+
+// validateNode checks that the fields used by node exist in typ. Fields used
+// inside of range and with blocks aren't checked as dot changes there.
+func validateNode(node parse.Node, typ reflect.Type) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Nodes {
+			if err := validateNode(c, typ); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return validateNode(n.Pipe, typ)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, c := range n.Cmds {
+			if err := validateNode(c, typ); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := validateNode(arg, typ); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		if err := validateNode(n.Pipe, typ); err != nil {
+			return err
+		}
+		if err := validateNode(n.List, typ); err != nil {
+			return err
+		}
+		return validateNode(n.ElseList, typ)
+	case *parse.RangeNode:
+		return validateNode(n.Pipe, typ)
+	case *parse.WithNode:
+		return validateNode(n.Pipe, typ)
+	case *parse.FieldNode:
+		return validateFields(n.Ident, typ)
+	}
+	return nil
+}
+
+func validateFields(idents []string, typ reflect.Type) error {
+	for i, ident := range idents {
+		switch typ.Kind() {
+		case reflect.Map:
+			// Any key is fine, missing keys are reported when resolving
+			return nil
+		case reflect.Struct:
+			f, ok := typ.FieldByName(ident)
+			if !ok {
+				return fmt.Errorf("unknown field %q", "."+strings.Join(idents[:i+1], "."))
+			}
+			typ = f.Type
+		default:
+			return fmt.Errorf("%q has no fields", "."+strings.Join(idents[:i], "."))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package containertemplate
+
+import (
+	"testing"
+
+	ocispec "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		text          string
+		expectedError bool
+	}
+
+	tests := map[string]testCase{
+		"field": {
+			text: "{{ .Container.Image.Entrypoint }}",
+		},
+		"index": {
+			text: "{{ .Pod.Ports[0] }}",
+		},
+		"label": {
+			text: "{{ .Pod.Labels.app }}",
+		},
+		"text_around": {
+			text: "/usr/bin/{{ .Container.Name }}:main",
+		},
+		"if": {
+			text: "{{ if .Pod.Name }}{{ .Pod.Name }}{{ else }}{{ .Container.Name }}{{ end }}",
+		},
+		"syntax_error": {
+			text:          "{{ .Container.Name",
+			expectedError: true,
+		},
+		"unknown_field": {
+			text:          "{{ .Container.Foo }}",
+			expectedError: true,
+		},
+		"unknown_root": {
+			text:          "{{ .Node }}",
+			expectedError: true,
+		},
+		"field_of_scalar": {
+			text:          "{{ .Container.Pid.Foo }}",
+			expectedError: true,
+		},
+		"unknown_function": {
+			text:          "{{ foo .Container.Name }}",
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(test.text)
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	container := &containercollection.Container{
+		Runtime: containercollection.RuntimeMetadata{
+			BasicRuntimeMetadata: types.BasicRuntimeMetadata{
+				ContainerName:      "k8s_nginx_nginx-abcde",
+				ContainerImageName: "docker.io/library/nginx:latest",
+			},
+		},
+		K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{
+				Namespace:     "default",
+				PodName:       "nginx-abcde",
+				ContainerName: "nginx",
+				PodLabels:     map[string]string{"app": "nginx"},
+			},
+			PodPorts: []int32{80, 443},
+		},
+		Pid: 1234,
+		OciConfig: &ocispec.Spec{
+			Process: &ocispec.Process{
+				Args: []string{"/usr/sbin/nginx", "-g", "daemon off;"},
+			},
+		},
+	}
+
+	type testCase struct {
+		text          string
+		container     *containercollection.Container
+		expected      string
+		expectedError bool
+	}
+
+	tests := map[string]testCase{
+		"entrypoint": {
+			text:      "{{ .Container.Image.Entrypoint }}",
+			container: container,
+			expected:  "/usr/sbin/nginx",
+		},
+		"index": {
+			text:      "{{ .Pod.Ports[1] }}",
+			container: container,
+			expected:  "443",
+		},
+		"args_index": {
+			text:      "{{ .Container.Image.Args[2] }}",
+			container: container,
+			expected:  "daemon off;",
+		},
+		"k8s_name": {
+			text:      "{{ .Pod.Namespace }}/{{ .Container.Name }}",
+			container: container,
+			expected:  "default/nginx",
+		},
+		"label": {
+			text:      "{{ .Pod.Labels.app }}",
+			container: container,
+			expected:  "nginx",
+		},
+		"missing_label": {
+			text:          "{{ .Pod.Labels.foo }}",
+			container:     container,
+			expectedError: true,
+		},
+		"out_of_range": {
+			text:          "{{ .Pod.Ports[2] }}",
+			container:     container,
+			expectedError: true,
+		},
+		"no_ports": {
+			text:          "{{ .Pod.Ports[0] }}",
+			container:     &containercollection.Container{},
+			expectedError: true,
+		},
+		"empty": {
+			text:          "{{ .Container.Image.Entrypoint }}",
+			container:     &containercollection.Container{},
+			expectedError: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tmpl, err := Parse(test.text)
+			require.NoError(t, err)
+
+			res, err := tmpl.Resolve(test.container)
+			if test.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, res)
+		})
+	}
+}
@@ -80,10 +80,47 @@ func setTraceOpError(ctx context.Context, cli client.Client,
 	strError string,
 ) {
 	patch := client.MergeFrom(trace.DeepCopy())
+	// Reconcile is called again on every update of the trace: only record
+	// the error once
+	if trace.Status.OperationError != strError {
+		trace.Status.AddEvent(gadgetv1alpha1.TraceEventOperationError, map[string]string{
+			"error": strError,
+		})
+	}
 	trace.Status.OperationError = strError
 	updateTraceStatus(ctx, cli, traceNsName, trace, patch)
 }
 
+// addOperationEvents records in the event log of the trace the operation
+// applied and how it changed the status
+func addOperationEvents(before, after *gadgetv1alpha1.TraceStatus, op string, params map[string]string) {
+	fields := make(map[string]string, len(params)+1)
+	for k, v := range params {
+		fields[k] = v
+	}
+	fields["operation"] = op
+	after.AddEvent(gadgetv1alpha1.TraceEventOperation, fields)
+
+	if before.State != after.State {
+		after.AddEvent(gadgetv1alpha1.TraceEventStateChanged, map[string]string{
+			"from": string(before.State),
+			"to":   string(after.State),
+		})
+	}
+	if after.OperationError != "" {
+		after.AddEvent(gadgetv1alpha1.TraceEventOperationError, map[string]string{
+			"operation": op,
+			"error":     after.OperationError,
+		})
+	}
+	if after.OperationWarning != "" {
+		after.AddEvent(gadgetv1alpha1.TraceEventOperationWarning, map[string]string{
+			"operation": op,
+			"warning":   after.OperationWarning,
+		})
+	}
+}
+
 //+kubebuilder:rbac:groups=gadget.kinvolk.io,resources=traces,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=gadget.kinvolk.io,resources=traces/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=gadget.kinvolk.io,resources=traces/finalizers,verbs=update
@@ -256,8 +293,9 @@ func (r *TraceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 		log.Info("Gadget completed operation without changing the trace status")
 	} else {
 		log.Infof("Gadget completed operation. Trace status will be updated accordingly")
-		updateTraceStatus(ctx, r.Client, req.NamespacedName.String(), trace, patch)
 	}
+	addOperationEvents(&traceBeforeOperation.Status, &trace.Status, op, params)
+	updateTraceStatus(ctx, r.Client, req.NamespacedName.String(), trace, patch)
 
 	return ctrl.Result{}, nil
 }
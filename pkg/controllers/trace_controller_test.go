@@ -180,6 +180,21 @@ func HaveOutput(expectedOutput string) gomegatype.GomegaMatcher {
 	}, Equal(expectedOutput))
 }
 
+// HaveEventTypes returns a GomegaMatcher that checks if the
+// Trace.Status.Events have the expected types, in order
+func HaveEventTypes(expectedTypes ...gadgetv1alpha1.TraceEventType) gomegatype.GomegaMatcher {
+	return WithTransform(func(trace *gadgetv1alpha1.Trace) []gadgetv1alpha1.TraceEventType {
+		if trace == nil {
+			return nil
+		}
+		types := []gadgetv1alpha1.TraceEventType{}
+		for _, event := range trace.Status.Events {
+			types = append(types, event.Type)
+		}
+		return types
+	}, Equal(expectedTypes))
+}
+
 // HaveAnnotation returns a GomegaMatcher that checks if the Trace
 // has an annotation with the expected value
 func HaveAnnotation(annotation, expectedOperation string) gomegatype.GomegaMatcher {
@@ -242,6 +257,12 @@ var _ = Context("Controller with a fake gadget", func() {
 				HaveOperationError("FakeError"),
 				HaveOperationWarning("FakeWarning"),
 				HaveOutput("FakeOutput"),
+				HaveEventTypes(
+					gadgetv1alpha1.TraceEventOperation,
+					gadgetv1alpha1.TraceEventStateChanged,
+					gadgetv1alpha1.TraceEventOperationError,
+					gadgetv1alpha1.TraceEventOperationWarning,
+				),
 				HaveAnnotation(GadgetOperation, ""),
 				HaveAnnotation("hiking.walking", "mountains"),
 			))
@@ -0,0 +1,95 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"maps"
+	"strings"
+)
+
+const (
+	// AliasesAnnotation holds a comma-separated list of former names of a
+	// field, kept for backwards compatibility.
+	AliasesAnnotation = "aliases"
+
+	// AliasesEnabledAnnotation is set on a data source to make GetField()
+	// resolve aliases and the JSON formatter emit them.
+	AliasesEnabledAnnotation = "aliases.enabled"
+
+	// DeprecatedAnnotation marks a field that will be removed when set to
+	// "true"
+	DeprecatedAnnotation = "deprecated"
+)
+
+// AliasUsageCounter is implemented by data sources that keep track of how
+// many times each alias was used to look a field up.
+type AliasUsageCounter interface {
+	AliasUsages() map[string]uint64
+}
+
+// FieldAliases returns the aliases of the field accessed by f.
+func FieldAliases(f FieldAccessor) []string {
+	return parseAliases(f.Annotations()[AliasesAnnotation])
+}
+
+// IsDeprecated returns whether the field accessed by f is deprecated.
+func IsDeprecated(f FieldAccessor) bool {
+	return f.Annotations()[DeprecatedAnnotation] == "true"
+}
+
+// AliasesEnabled returns whether aliases are resolved for the given data
+// source.
+func AliasesEnabled(ds DataSource) bool {
+	return ds.Annotations()[AliasesEnabledAnnotation] == "true"
+}
+
+func parseAliases(s string) []string {
+	var aliases []string
+	for _, alias := range strings.Split(s, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// fieldByAlias returns the field that has name as alias, if aliases are
+// enabled. ds.lock must be held.
+func (ds *dataSource) fieldByAlias(name string) *field {
+	if ds.annotations[AliasesEnabledAnnotation] != "true" {
+		return nil
+	}
+
+	for _, f := range ds.fields {
+		for _, alias := range parseAliases(f.Annotations[AliasesAnnotation]) {
+			if alias != name {
+				continue
+			}
+
+			ds.aliasLock.Lock()
+			ds.aliasUsages[name]++
+			ds.aliasLock.Unlock()
+			return f
+		}
+	}
+	return nil
+}
+
+func (ds *dataSource) AliasUsages() map[string]uint64 {
+	ds.aliasLock.Lock()
+	defer ds.aliasLock.Unlock()
+	return maps.Clone(ds.aliasUsages)
+}
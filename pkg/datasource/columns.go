@@ -17,6 +17,7 @@ package datasource
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"unsafe"
 
@@ -31,6 +32,13 @@ const (
 	// ColumnsReplaceAnnotation is used to indicate that this field should be
 	// replaced by the one indicated in the annotation when printing it.
 	ColumnsReplaceAnnotation = "columns.replace"
+
+	// PrecisionAnnotation sets how many decimals are shown for float fields,
+	// both in columns and JSON output
+	PrecisionAnnotation = "columns.precision"
+
+	// Decimals shown for float fields without PrecisionAnnotation
+	defaultPrecision = 2
 )
 
 type DataTuple struct {
@@ -59,17 +67,30 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 		return nil, err
 	}
 
+	orders := columnOrders(ds.fields)
+	defaultColumns := DefaultColumns(ds)
+	styles := make(map[string]func(Data) string)
 	for i, f := range ds.fields {
 		if FieldFlagEmpty.In(f.Flags) || FieldFlagUnreferenced.In(f.Flags) {
 			continue
 		}
+		// Printed below the rows, see DataTuple.ExtraLines
+		if f.Annotations[ExtraLinesAnnotation] == "true" {
+			continue
+		}
+
+		visible := !FieldFlagHidden.In(f.Flags)
+		if defaultColumns != nil {
+			visible = IsDefaultColumn(defaultColumns, f.FullName)
+		}
 
 		attributes := &columns.Attributes{
-			Name:    f.FullName,
-			Tags:    f.Tags,
-			Visible: !FieldFlagHidden.In(f.Flags),
-			Width:   columns.GetDefault().DefaultWidth,
-			Order:   i * 100,
+			Name:      f.FullName,
+			Tags:      f.Tags,
+			Visible:   visible,
+			Width:     columns.GetDefault().DefaultWidth,
+			Order:     orders[i],
+			Precision: defaultPrecision,
 		}
 
 		df := columns.DynamicField{
@@ -102,6 +123,8 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 				default:
 					return nil, fmt.Errorf("invalid ellipsis type for column %q: %s", f.Name, v)
 				}
+			case GroupAnnotation:
+				attributes.Group = v
 			case "columns.width":
 				var err error
 				attributes.Width, err = strconv.Atoi(v)
@@ -120,6 +143,12 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 				if err != nil {
 					return nil, fmt.Errorf("reading maxWidth for column %q: %w", f.Name, err)
 				}
+			case PrecisionAnnotation:
+				precision, err := strconv.Atoi(v)
+				if err != nil || precision < 0 {
+					return nil, fmt.Errorf("invalid precision for column %q: %s", f.Name, v)
+				}
+				attributes.Precision = precision
 			case "columns.template":
 				attributes.Template = v
 				df.Template = v
@@ -127,9 +156,21 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 				if v == "true" {
 					attributes.FixedWidth = true
 				}
+			case "columns.autoWidth":
+				if v == "true" {
+					attributes.AutoWidth = true
+				}
 			}
 		}
 
+		style, err := fieldStyle(ds, &fieldAccessor{ds: ds, f: f})
+		if err != nil {
+			return nil, fmt.Errorf("reading style for column %q: %w", f.Name, err)
+		}
+		if style != nil {
+			styles[f.FullName] = style
+		}
+
 		// Use replace field if it's defined
 		if replacementField, ok := f.Annotations[ColumnsReplaceAnnotation]; ok {
 			f, ok = ds.fieldMap[replacementField]
@@ -178,14 +219,41 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 			continue
 		}
 
+		acc := &fieldAccessor{
+			ds: ds,
+			f:  f,
+		}
+
+		// The width of humanized fields depends on their unit instead of the
+		// digits of the raw value
+		unit, humanize := HumanizedUnit(acc)
+		if humanize {
+			attributes.Width = HumanizedWidth(unit)
+		}
+		durationUnit, durationPrecision, duration := DurationSettings(acc)
+		if duration {
+			attributes.Width = DurationWidth(durationPrecision)
+			attributes.Alignment = columns.AlignRight
+		}
+
 		if attributes.Width == 0 {
 			attributes.Width = columns.GetWidthFromType(f.ReflectType().Kind())
 		}
 
+		// Labels replace the value, make room for them
+		trueLabel, falseLabel, boolLabels := BoolLabels(acc)
+		if boolLabels {
+			attributes.Width = max(attributes.Width, labelsWidth(trueLabel, falseLabel))
+		}
+		zeroAs, hasZeroAs := ZeroAs(acc)
+		if hasZeroAs {
+			attributes.Width = max(attributes.Width, labelsWidth(zeroAs))
+		}
+
 		df.Type = f.ReflectType()
 		idx := f.PayloadIndex
 
-		err := cols.AddFields([]columns.DynamicField{df}, func(d *DataTuple) unsafe.Pointer {
+		err = cols.AddFields([]columns.DynamicField{df}, func(d *DataTuple) unsafe.Pointer {
 			if len(d.data.payload()[idx]) == 0 {
 				return nil
 			}
@@ -194,6 +262,89 @@ func (ds *dataSource) Columns() (*columns.Columns[DataTuple], error) {
 		if err != nil {
 			return nil, fmt.Errorf("creating columns: %w", err)
 		}
+
+		var render func(data Data) string
+		switch {
+		case duration:
+			render = func(data Data) string {
+				str, _ := DurationField(acc, durationUnit, durationPrecision, data)
+				return str
+			}
+		case humanize:
+			render = func(data Data) string {
+				str, _ := HumanizeField(acc, unit, data)
+				return str
+			}
+		case boolLabels:
+			render = func(data Data) string {
+				if isZero(acc, data) {
+					return falseLabel
+				}
+				return trueLabel
+			}
+		}
+
+		if hasZeroAs {
+			format := render
+			if format == nil {
+				precision := attributes.Precision
+				format = func(data Data) string {
+					return formatScalar(acc, data, precision)
+				}
+			}
+			render = func(data Data) string {
+				if isZero(acc, data) {
+					return zeroAs
+				}
+				return format(data)
+			}
+		}
+
+		if render == nil {
+			continue
+		}
+
+		// Only the shown value changes, sorting still uses the raw one
+		err = cols.SetExtractor(f.FullName, func(d *DataTuple) any {
+			if d.data == nil {
+				return ""
+			}
+			return render(d.data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting extractor for column %q: %w", f.Name, err)
+		}
+	}
+
+	for name, style := range styles {
+		style := style
+		err := cols.SetStyle(name, func(d *DataTuple) string {
+			if d.data == nil {
+				return ""
+			}
+			return style(d.data)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("setting style for column %q: %w", name, err)
+		}
 	}
 	return cols, nil
 }
+
+// columnOrders returns the order of the column of each field: fields are
+// sorted by their order and fields with the same order keep their position.
+func columnOrders(fields []*field) []int {
+	positions := make([]int, len(fields))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		return fields[positions[i]].Order < fields[positions[j]].Order
+	})
+
+	orders := make([]int, len(fields))
+	for pos, i := range positions {
+		orders[i] = pos * 100
+	}
+	return orders
+}
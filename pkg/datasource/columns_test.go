@@ -0,0 +1,452 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/textcolumns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/sort"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestColumnsFloatPrecision(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	ratio, err := ds.AddField("ratio", api.Kind_Float32)
+	require.NoError(t, err)
+	avg, err := ds.AddField("avg", api.Kind_Float64, WithAnnotations(map[string]string{
+		PrecisionAnnotation: "4",
+	}))
+	require.NoError(t, err)
+	rounded, err := ds.AddField("rounded", api.Kind_Float64, WithAnnotations(map[string]string{
+		PrecisionAnnotation: "0",
+	}))
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, ratio.PutFloat32(data, 0.5))
+	require.NoError(t, avg.PutFloat64(data, 3.14159265))
+	require.NoError(t, rounded.PutFloat64(data, 41.7))
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	formatter := textcolumns.NewFormatter(cols.GetColumnMap(), textcolumns.WithAutoScale(false))
+	out := strings.Fields(formatter.FormatEntry(NewDataTuple(ds, data)))
+	require.Equal(t, []string{"0.50", "3.1416", "42"}, out)
+}
+
+func TestColumnsInvalidPrecision(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("ratio", api.Kind_Float32, WithAnnotations(map[string]string{
+		PrecisionAnnotation: "-1",
+	}))
+	require.NoError(t, err)
+
+	_, err = ds.(*dataSource).Columns()
+	require.ErrorContains(t, err, "invalid precision")
+}
+
+func TestColumnsHumanize(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	size, err := ds.AddField("size", api.Kind_Uint64, WithAnnotations(map[string]string{
+		UnitAnnotation:     string(metadatav1.UnitBytes),
+		HumanizeAnnotation: "true",
+		"columns.width":    "20",
+	}))
+	require.NoError(t, err)
+	latency, err := ds.AddField("latency", api.Kind_Uint32, WithAnnotations(map[string]string{
+		UnitAnnotation:     string(metadatav1.UnitNanoseconds),
+		HumanizeAnnotation: "true",
+	}))
+	require.NoError(t, err)
+	// Without humanize, the unit doesn't change the output
+	raw, err := ds.AddField("raw", api.Kind_Uint32, WithAnnotations(map[string]string{
+		UnitAnnotation: string(metadatav1.UnitBytes),
+	}))
+	require.NoError(t, err)
+
+	newEntry := func(s uint64, l uint32) *DataTuple {
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, size.PutUint64(data, s))
+		require.NoError(t, latency.PutUint32(data, l))
+		require.NoError(t, raw.PutUint32(data, 2048))
+		return NewDataTuple(ds, data)
+	}
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	colMap := cols.GetColumnMap()
+	sizeCol, ok := colMap.GetColumn("size")
+	require.True(t, ok)
+	require.Equal(t, HumanizedWidth(metadatav1.UnitBytes), sizeCol.Width)
+	latencyCol, ok := colMap.GetColumn("latency")
+	require.True(t, ok)
+	require.Equal(t, HumanizedWidth(metadatav1.UnitNanoseconds), latencyCol.Width)
+
+	formatter := textcolumns.NewFormatter(colMap, textcolumns.WithAutoScale(false))
+	out := formatter.FormatEntry(newEntry(1468006, 2300000))
+	require.Equal(t, []string{"1.4", "MiB", "2.3", "ms", "2048"}, strings.Fields(out))
+
+	// Sorting uses the raw values: 900 B would come after 1.4 MiB as a string
+	entries := []*DataTuple{newEntry(1468006, 1), newEntry(900, 2), newEntry(2048, 3)}
+	sort.SortEntries(colMap, entries, []string{"size"})
+	sorted := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		str, _ := HumanizeField(size, metadatav1.UnitBytes, entry.data)
+		sorted = append(sorted, str)
+	}
+	require.Equal(t, []string{"900 B", "2.0 KiB", "1.4 MiB"}, sorted)
+}
+
+func TestColumnsDuration(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	latency, err := ds.AddField("latency", api.Kind_Uint64, WithAnnotations(map[string]string{
+		"columns.template": DurationTemplate,
+	}))
+	require.NoError(t, err)
+	delta, err := ds.AddField("delta", api.Kind_Uint32, WithAnnotations(map[string]string{
+		"columns.template":  DurationTemplate,
+		UnitAnnotation:      string(metadatav1.UnitMicroseconds),
+		PrecisionAnnotation: "1",
+	}))
+	require.NoError(t, err)
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	colMap := cols.GetColumnMap()
+	latencyCol, ok := colMap.GetColumn("latency")
+	require.True(t, ok)
+	require.Equal(t, DurationWidth(defaultPrecision), latencyCol.Width)
+	require.Equal(t, columns.AlignRight, latencyCol.Alignment)
+	deltaCol, ok := colMap.GetColumn("delta")
+	require.True(t, ok)
+	require.Equal(t, DurationWidth(1), deltaCol.Width)
+
+	formatter := textcolumns.NewFormatter(colMap, textcolumns.WithAutoScale(false))
+	for ns, expected := range map[uint64]string{
+		830:        "830ns",
+		1240000:    "1.24ms",
+		2100000000: "2.1s",
+	} {
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, latency.PutUint64(data, ns))
+		require.NoError(t, delta.PutUint32(data, 1260))
+
+		out := formatter.FormatEntry(NewDataTuple(ds, data))
+		require.Equal(t, []string{expected, "1.3ms"}, strings.Fields(out))
+		// Right-aligned
+		require.True(t, strings.HasPrefix(out, strings.Repeat(" ", latencyCol.Width-len([]rune(expected)))+expected))
+	}
+}
+
+func TestColumnsLabels(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	success, err := ds.AddField("success", api.Kind_Bool, WithAnnotations(map[string]string{
+		BoolTrueLabelAnnotation:  "yes",
+		BoolFalseLabelAnnotation: "no",
+	}))
+	require.NoError(t, err)
+	// 1-byte integers used as booleans
+	state, err := ds.AddField("state", api.Kind_Uint8, WithAnnotations(map[string]string{
+		BoolTrueLabelAnnotation:  "connected",
+		BoolFalseLabelAnnotation: "disconnected",
+		"columns.width":          "3",
+	}))
+	require.NoError(t, err)
+	port, err := ds.AddField("port", api.Kind_Uint16, WithAnnotations(map[string]string{
+		ZeroAsAnnotation: "-",
+	}))
+	require.NoError(t, err)
+	ratio, err := ds.AddField("ratio", api.Kind_Float64, WithAnnotations(map[string]string{
+		ZeroAsAnnotation:    "n/a",
+		PrecisionAnnotation: "1",
+	}))
+	require.NoError(t, err)
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	// The width grows to fit the labels
+	colMap := cols.GetColumnMap()
+	stateCol, ok := colMap.GetColumn("state")
+	require.True(t, ok)
+	require.Equal(t, len("disconnected"), stateCol.Width)
+
+	type testCase struct {
+		success  bool
+		state    uint8
+		port     uint16
+		ratio    float64
+		expected []string
+	}
+
+	tests := map[string]testCase{
+		"set": {
+			success:  true,
+			state:    1,
+			port:     8080,
+			ratio:    0.25,
+			expected: []string{"yes", "connected", "8080", "0.2"},
+		},
+		"zero": {
+			expected: []string{"no", "disconnected", "-", "n/a"},
+		},
+	}
+
+	formatter := textcolumns.NewFormatter(colMap, textcolumns.WithAutoScale(false))
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, success.PutBool(data, test.success))
+			require.NoError(t, state.PutUint8(data, test.state))
+			require.NoError(t, port.PutUint16(data, test.port))
+			require.NoError(t, ratio.PutFloat64(data, test.ratio))
+
+			out := formatter.FormatEntry(NewDataTuple(ds, data))
+			require.Equal(t, test.expected, strings.Fields(out))
+		})
+	}
+}
+
+func TestColumnsOrder(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	fields := []struct {
+		name string
+		opts []FieldOption
+	}{
+		{name: "comm"},
+		{name: "filename", opts: []FieldOption{WithOrder(10)}},
+		{name: "pid", opts: []FieldOption{WithOrder(EnrichmentOrderFirst - 10)}},
+		{name: "container", opts: []FieldOption{WithOrder(EnrichmentOrderFirst)}},
+		{name: "flags", opts: []FieldOption{WithOrder(10)}},
+		{name: "ret"},
+		{name: "tid", opts: []FieldOption{WithOrder(EnrichmentOrderFirst - 10)}},
+	}
+	for _, f := range fields {
+		_, err := ds.AddField(f.name, api.Kind_Uint32, f.opts...)
+		require.NoError(t, err)
+	}
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	var names []string
+	for _, col := range cols.GetColumnMap().GetOrderedColumns() {
+		names = append(names, col.Name)
+	}
+	// Fields with the same order keep the order they were added in
+	require.Equal(t, []string{"pid", "tid", "container", "comm", "ret", "filename", "flags"}, names)
+}
+
+func TestColumnsDefaultColumns(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		defaultColumns  string
+		expectedVisible []string
+	}
+
+	tests := map[string]testCase{
+		"no_default_columns": {
+			expectedVisible: []string{"comm", "k8s.namespace", "k8s.pod", "pid"},
+		},
+		"subset": {
+			defaultColumns:  "pid,k8s",
+			expectedVisible: []string{"k8s.namespace", "k8s.pod", "pid"},
+		},
+		// Default columns override the hidden flag
+		"hidden": {
+			defaultColumns:  "comm,tid",
+			expectedVisible: []string{"comm", "tid"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := New(TypeSingle, "event")
+			require.NoError(t, err)
+			if test.defaultColumns != "" {
+				ds.AddAnnotation(DefaultColumnsAnnotation, test.defaultColumns)
+			}
+
+			_, err = ds.AddField("comm", api.Kind_String)
+			require.NoError(t, err)
+			_, err = ds.AddField("pid", api.Kind_Uint32)
+			require.NoError(t, err)
+			_, err = ds.AddField("tid", api.Kind_Uint32, WithFlags(FieldFlagHidden))
+			require.NoError(t, err)
+			k8s, err := ds.AddField("k8s", api.Kind_Invalid, WithFlags(FieldFlagEmpty))
+			require.NoError(t, err)
+			_, err = k8s.AddSubField("namespace", api.Kind_String)
+			require.NoError(t, err)
+			_, err = k8s.AddSubField("pod", api.Kind_String)
+			require.NoError(t, err)
+
+			cols, err := ds.(*dataSource).Columns()
+			require.NoError(t, err)
+
+			var visible []string
+			for name, col := range cols.GetColumnMap() {
+				if col.Visible {
+					visible = append(visible, name)
+				}
+			}
+			slices.Sort(visible)
+			require.Equal(t, test.expectedVisible, visible)
+		})
+	}
+}
+
+func TestColumnsGroups(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("pid", api.Kind_Uint32)
+	require.NoError(t, err)
+	for _, name := range []string{"addr", "port"} {
+		_, err = ds.AddField("s"+name, api.Kind_Uint32, WithAnnotations(map[string]string{
+			GroupAnnotation: "src",
+		}))
+		require.NoError(t, err)
+	}
+	// Fields of hidden groups are hidden
+	for _, name := range []string{"addr", "port"} {
+		_, err = ds.AddField("d"+name, api.Kind_Uint32, WithFlags(FieldFlagHidden), WithAnnotations(map[string]string{
+			GroupAnnotation: "dst",
+		}))
+		require.NoError(t, err)
+	}
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	col, ok := cols.GetColumn("saddr")
+	require.True(t, ok)
+	require.Equal(t, "src", col.Group)
+
+	formatter := textcolumns.NewFormatter(cols.GetColumnMap(), textcolumns.WithAutoScale(false))
+	require.Equal(t, []string{"PID", "SRC.SADDR", "SRC.SPORT"}, strings.Fields(formatter.FormatHeader()))
+
+	require.NoError(t, formatter.SetShowColumns([]string{"pid", "daddr", "dport"}))
+	require.Equal(t, []string{"PID", "DST.DADDR", "DST.DPORT"}, strings.Fields(formatter.FormatHeader()))
+}
+
+func TestColumnsStyle(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	comm, err := ds.AddField("comm", api.Kind_String, WithAnnotations(map[string]string{
+		StyleAnnotation: "bold",
+	}))
+	require.NoError(t, err)
+	ret, err := ds.AddField("ret", api.Kind_Int32, WithAnnotations(map[string]string{
+		ColorAnnotation:     "red",
+		StyleAnnotation:     "bold,underline",
+		StyleWhenAnnotation: "ret!=0",
+	}))
+	require.NoError(t, err)
+
+	cols, err := ds.(*dataSource).Columns()
+	require.NoError(t, err)
+
+	formatter := textcolumns.NewFormatter(cols.GetColumnMap(), textcolumns.WithAutoScale(false),
+		textcolumns.WithColors(true))
+	require.NoError(t, formatter.SetShowColumns([]string{"comm", "ret"}))
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, comm.PutString(data, "cat"))
+
+	// Matching event
+	require.NoError(t, ret.PutInt32(data, -2))
+	out := formatter.FormatEntry(NewDataTuple(ds, data))
+	require.True(t, strings.HasPrefix(out, "\x1b[1mcat"), out)
+	require.Contains(t, out, "\x1b[31;1;4m-2")
+	require.True(t, strings.HasSuffix(out, "\x1b[0m"), out)
+
+	// Non-matching event
+	require.NoError(t, ret.PutInt32(data, 0))
+	out = formatter.FormatEntry(NewDataTuple(ds, data))
+	require.True(t, strings.HasPrefix(out, "\x1b[1mcat"), out)
+	require.Contains(t, out, " 0")
+	require.NotContains(t, out, "\x1b[31")
+
+	// Styles are stripped when colors are disabled
+	formatter = textcolumns.NewFormatter(cols.GetColumnMap(), textcolumns.WithAutoScale(false))
+	require.NoError(t, ret.PutInt32(data, -2))
+	require.NotContains(t, formatter.FormatEntry(NewDataTuple(ds, data)), "\x1b[")
+}
+
+func TestColumnsInvalidStyle(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("ret", api.Kind_Int32, WithAnnotations(map[string]string{
+		ColorAnnotation: "purple",
+	}))
+	require.NoError(t, err)
+
+	_, err = ds.(*dataSource).Columns()
+	require.ErrorContains(t, err, `unknown color "purple"`)
+}
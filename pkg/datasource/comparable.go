@@ -0,0 +1,34 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+const (
+	// FilterableAnnotation set to "false" prevents using a field in filter
+	// expressions
+	FilterableAnnotation = "filterable"
+	// SortableAnnotation set to "false" prevents sorting entries by a field
+	SortableAnnotation = "sortable"
+)
+
+// IsFilterable returns whether the field accessed by f can be used in filter
+// expressions
+func IsFilterable(f FieldAccessor) bool {
+	return f.Annotations()[FilterableAnnotation] != "false"
+}
+
+// IsSortable returns whether entries can be sorted by the field accessed by f
+func IsSortable(f FieldAccessor) bool {
+	return f.Annotations()[SortableAnnotation] != "false"
+}
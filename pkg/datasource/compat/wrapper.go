@@ -145,7 +145,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		datasource.WithAnnotations(map[string]string{
 			"columns.template": "namespace",
 		}),
-		datasource.WithOrder(-30),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst),
 	)
 	if err != nil {
 		return nil, err
@@ -157,7 +157,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		datasource.WithAnnotations(map[string]string{
 			"columns.template": "pod",
 		}),
-		datasource.WithOrder(-29),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+1),
 	)
 	if err != nil {
 		return nil, err
@@ -169,7 +169,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		datasource.WithAnnotations(map[string]string{
 			"columns.template": "container",
 		}),
-		datasource.WithOrder(-28),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+2),
 	)
 	if err != nil {
 		return nil, err
@@ -179,7 +179,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		api.Kind_Bool,
 		datasource.WithTags("kubernetes"),
 		datasource.WithFlags(datasource.FieldFlagHidden),
-		datasource.WithOrder(-27),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+3),
 	)
 	if err != nil {
 		return nil, err
@@ -201,7 +201,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		datasource.WithAnnotations(map[string]string{
 			"columns.template": "container",
 		}),
-		datasource.WithOrder(-26),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+4),
 	)
 	if err != nil {
 		return nil, err
@@ -214,7 +214,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 			"columns.fixed": "true",
 		}),
 		datasource.WithFlags(datasource.FieldFlagHidden),
-		datasource.WithOrder(-25),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+5),
 	)
 	if err != nil {
 		return nil, err
@@ -227,7 +227,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 			"columns.maxWidth": "64",
 		}),
 		datasource.WithFlags(datasource.FieldFlagHidden),
-		datasource.WithOrder(-24),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+6),
 	)
 	if err != nil {
 		return nil, err
@@ -236,7 +236,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		"containerImageName",
 		api.Kind_String,
 		datasource.WithFlags(datasource.FieldFlagHidden),
-		datasource.WithOrder(-23),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+7),
 	)
 	if err != nil {
 		return nil, err
@@ -245,7 +245,7 @@ func WrapAccessors(source datasource.DataSource, mntnsidAccessor datasource.Fiel
 		"containerImageDigest",
 		api.Kind_String,
 		datasource.WithFlags(datasource.FieldFlagHidden),
-		datasource.WithOrder(-22),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst+8),
 	)
 	if err != nil {
 		return nil, err
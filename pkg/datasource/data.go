@@ -159,6 +159,10 @@ type dataSource struct {
 
 	byteOrder binary.ByteOrder
 	lock      sync.RWMutex
+
+	// number of lookups by alias, see aliases.go
+	aliasUsages map[string]uint64
+	aliasLock   sync.Mutex
 }
 
 func newDataSource(t Type, name string) (*dataSource, error) {
@@ -176,6 +180,7 @@ func newDataSource(t Type, name string) (*dataSource, error) {
 		byteOrder:       binary.NativeEndian,
 		tags:            make([]string, 0),
 		annotations:     map[string]string{},
+		aliasUsages:     make(map[string]uint64),
 	}, nil
 }
 
@@ -362,6 +367,9 @@ func (ds *dataSource) AddStaticFields(size uint32, fields []StaticField) (FieldA
 		if s, ok := f.(AnnotatedField); ok {
 			nf.Annotations = s.FieldAnnotations()
 		}
+		if s, ok := f.(OrderedField); ok {
+			nf.Order = s.FieldOrder()
+		}
 		if s, ok := f.(ParentedField); ok {
 			parent := s.FieldParent()
 			if parent >= 0 {
@@ -443,7 +451,9 @@ func (ds *dataSource) GetField(name string) FieldAccessor {
 
 	f, ok := ds.fieldMap[name]
 	if !ok {
-		return nil
+		if f = ds.fieldByAlias(name); f == nil {
+			return nil
+		}
 	}
 	return &fieldAccessor{ds: ds, f: f}
 }
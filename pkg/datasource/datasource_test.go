@@ -402,6 +402,38 @@ func TestDataSourceStaticFieldsStrings(t *testing.T) {
 	}
 }
 
+func TestDataSourceStaticFieldsCStringTrimmed(t *testing.T) {
+	t.Parallel()
+
+	// Like a char filename[PATH_MAX] member
+	const strSize = 4096
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	fieldsAcc, err := ds.AddStaticFields(strSize, []StaticField{
+		&dummyField{
+			name:   "filename",
+			size:   strSize,
+			offset: 0,
+			kind:   api.Kind_CString,
+		},
+	})
+	require.NoError(t, err)
+
+	d, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+
+	// Leftovers of a previous string after the NUL aren't shown
+	buf := randBytes(strSize)
+	copy(buf, "/etc/passwd\x00")
+	require.NoError(t, fieldsAcc.Set(d, buf))
+
+	str, err := ds.GetField("filename").String(d)
+	require.NoError(t, err)
+	require.Equal(t, "/etc/passwd", str)
+}
+
 // TODO(Jose): Repeat this for all the types
 func TestDataSourceSubscribePriorities(t *testing.T) {
 	t.Parallel()
@@ -698,3 +730,32 @@ func randBytes(n int) []byte {
 	rand.Read(ret)
 	return ret
 }
+
+func TestDataSourceFieldAliases(t *testing.T) {
+	t.Parallel()
+
+	ds, err := New(TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("src_addr", api.Kind_String, WithAnnotations(map[string]string{
+		AliasesAnnotation: "saddr, src",
+	}))
+	require.NoError(t, err)
+
+	// Aliases are only resolved when enabled
+	require.Nil(t, ds.GetField("saddr"))
+
+	ds.AddAnnotation(AliasesEnabledAnnotation, "true")
+	require.True(t, AliasesEnabled(ds))
+
+	acc := ds.GetField("saddr")
+	require.NotNil(t, acc)
+	require.Equal(t, "src_addr", acc.Name())
+	require.Equal(t, []string{"saddr", "src"}, FieldAliases(acc))
+
+	require.NotNil(t, ds.GetField("saddr"))
+	require.NotNil(t, ds.GetField("src_addr"))
+	require.Nil(t, ds.GetField("dst"))
+
+	require.Equal(t, map[string]uint64{"saddr": 2}, ds.(AliasUsageCounter).AliasUsages())
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"strings"
+)
+
+// DefaultColumnsAnnotation holds a comma separated list of the fields shown
+// by default in columns output. Other fields can still be requested
+// explicitly. Listing a field also shows its subfields.
+const DefaultColumnsAnnotation = "columns.default"
+
+// DefaultColumns returns the names of the fields shown by default in columns
+// output of ds, or nil if all non-hidden fields should be shown.
+func DefaultColumns(ds DataSource) []string {
+	v, ok := ds.Annotations()[DefaultColumnsAnnotation]
+	if !ok || v == "" {
+		return nil
+	}
+	names := strings.Split(v, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+	return names
+}
+
+// IsDefaultColumn returns whether the field fullName is one of defaultColumns
+// or one of their subfields.
+func IsDefaultColumn(defaultColumns []string, fullName string) bool {
+	for _, name := range defaultColumns {
+		if fullName == name || strings.HasPrefix(fullName, name+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import "strings"
+
+// ExtraLinesAnnotation marks a string field, like a rendered histogram, that
+// is printed on its own lines below each row in columns output instead of as
+// a column.
+const ExtraLinesAnnotation = "columns.extraLines"
+
+// ExtraLinesFields returns the fields of ds with ExtraLinesAnnotation, in the
+// order they were added.
+func ExtraLinesFields(ds DataSource) []FieldAccessor {
+	var res []FieldAccessor
+	for _, f := range ds.Accessors(false) {
+		if f.Annotations()[ExtraLinesAnnotation] == "true" {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// ExtraLines returns the lines of the fields with ExtraLinesAnnotation, see
+// parser.ExtraLines
+func (d *DataTuple) ExtraLines() []string {
+	if d.ds == nil || d.data == nil {
+		return nil
+	}
+	var lines []string
+	for _, f := range ExtraLinesFields(d.ds) {
+		s, _ := f.String(d.data)
+		s = strings.TrimSuffix(s, "\n")
+		if s == "" {
+			continue
+		}
+		lines = append(lines, strings.Split(s, "\n")...)
+	}
+	return lines
+}
@@ -20,6 +20,13 @@ import (
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 )
 
+// Range of orders used by the fields added by the container enrichment, see
+// WithOrder. Gadget fields use 0 by default and are shown after them.
+const (
+	EnrichmentOrderFirst int32 = -30
+	EnrichmentOrderLast  int32 = -20
+)
+
 type FieldFlag uint32
 
 const (
@@ -91,6 +98,11 @@ type HiddenField interface {
 	FieldHidden() bool
 }
 
+type OrderedField interface {
+	// FieldOrder returns the default position of the field, see WithOrder
+	FieldOrder() int32
+}
+
 type ParentedField interface {
 	// FieldParent should return an index to the parent of the field, -1 for no parent
 	FieldParent() int
@@ -116,6 +128,10 @@ func WithAnnotations(annotations map[string]string) FieldOption {
 	}
 }
 
+// WithOrder sets the default position of the field when showing several
+// fields: fields are sorted by order and fields with the same order keep the
+// order they were added in. Fields use 0 by default; the enrichment fields use
+// orders from EnrichmentOrderFirst to EnrichmentOrderLast.
 func WithOrder(order int32) FieldOption {
 	return func(f *field) {
 		f.Order = order
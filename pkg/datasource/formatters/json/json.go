@@ -44,6 +44,14 @@ const (
 	// SkipFieldAnnotation is used to indicate that this field should be
 	// skipped.
 	SkipFieldAnnotation = "json.skip"
+
+	// RawAnnotation is used to indicate that a string field holds JSON, like
+	// an array, that is written as is instead of as a string
+	RawAnnotation = "json.raw"
+
+	// HumanizedSuffix is appended to the name of humanized fields to get the
+	// name of their human-readable form
+	HumanizedSuffix = "_humanized"
 )
 
 type Formatter struct {
@@ -53,6 +61,8 @@ type Formatter struct {
 	showFields        map[string]struct{}
 	hideFields        map[string]struct{}
 	allRelativeFields bool
+	aliases           bool
+	humanized         bool
 	useDefault        bool
 	showAll           bool
 	pretty            bool
@@ -68,6 +78,7 @@ func New(ds datasource.DataSource, options ...Option) (*Formatter, error) {
 		showFields: map[string]struct{}{},
 		hideFields: map[string]struct{}{},
 		useDefault: true,
+		aliases:    datasource.AliasesEnabled(ds),
 	}
 	for _, o := range options {
 		o(f)
@@ -140,7 +151,7 @@ func (f *Formatter) addSubFields(accessors []datasource.FieldAccessor, prefix st
 
 	// sort lexicographically
 	slices.SortFunc(accessors, func(i datasource.FieldAccessor, j datasource.FieldAccessor) int {
-		return strings.Compare(i.Name(), j.Name())
+		return strings.Compare(datasource.FieldOutputName(i), datasource.FieldOutputName(j))
 	})
 
 	for _, acc := range accessors {
@@ -189,7 +200,7 @@ func (f *Formatter) addSubFields(accessors []datasource.FieldAccessor, prefix st
 
 		ctr++
 		fieldCounter++
-		fieldName := []byte("\"" + accessor.Name() + "\":")
+		fieldName := []byte("\"" + datasource.FieldOutputName(accessor) + "\":")
 		if f.pretty {
 			fieldName = append(append([]byte(indent), fieldName...), ' ')
 		}
@@ -269,16 +280,29 @@ func (f *Formatter) addSubFields(accessors []datasource.FieldAccessor, prefix st
 				e.Write(b)
 			}
 		case api.Kind_Float32:
+			precision := getPrecision(accessor)
 			fn = func(e *encodeState, data datasource.Data) {
 				v, _ := accessor.Float32(data)
-				floatEncoder(32).writeFloat(e, float64(v))
+				floatEncoder(32).writeFloatPrecision(e, float64(v), precision)
 			}
 		case api.Kind_Float64:
+			precision := getPrecision(accessor)
 			fn = func(e *encodeState, data datasource.Data) {
 				v, _ := accessor.Float64(data)
-				floatEncoder(64).writeFloat(e, v)
+				floatEncoder(64).writeFloatPrecision(e, v, precision)
 			}
 		case api.Kind_String, api.Kind_CString:
+			if acc.Annotations()[RawAnnotation] == "true" {
+				fn = func(e *encodeState, data datasource.Data) {
+					v, _ := accessor.String(data)
+					if v == "" {
+						e.WriteString("null")
+						return
+					}
+					e.WriteString(v)
+				}
+				break
+			}
 			fn = func(e *encodeState, data datasource.Data) {
 				v, _ := accessor.String(data)
 				writeString(e, v)
@@ -301,6 +325,37 @@ func (f *Formatter) addSubFields(accessors []datasource.FieldAccessor, prefix st
 			e.Write(fieldName)
 			fn(e, data)
 		})
+
+		// Add the human-readable form of the value next to the raw one
+		if unit, ok := datasource.HumanizedUnit(accessor); ok && f.humanized {
+			humanizedName := []byte("\"" + datasource.FieldOutputName(accessor) + HumanizedSuffix + "\":")
+			if f.pretty {
+				humanizedName = append(append([]byte(indent), humanizedName...), ' ')
+			}
+			fns = append(fns, func(e *encodeState, data datasource.Data) {
+				str, _ := datasource.HumanizeField(accessor, unit, data)
+				e.Write(f.fieldSep)
+				e.Write(humanizedName)
+				writeString(e, str)
+			})
+		}
+
+		if !f.aliases {
+			continue
+		}
+
+		// Also emit the value under the former names of the field
+		for _, alias := range datasource.FieldAliases(accessor) {
+			aliasName := []byte("\"" + alias + "\":")
+			if f.pretty {
+				aliasName = append(append([]byte(indent), aliasName...), ' ')
+			}
+			fns = append(fns, func(e *encodeState, data datasource.Data) {
+				e.Write(f.fieldSep)
+				e.Write(aliasName)
+				fn(e, data)
+			})
+		}
 	}
 	return
 }
@@ -354,6 +409,34 @@ func (f *Formatter) MarshalArray(a datasource.DataArray) []byte {
 type floatEncoder int // number of bits
 
 // from encoding/json/encode.go
+// getPrecision returns the number of decimals set with
+// datasource.PrecisionAnnotation or -1 if it isn't set
+func getPrecision(accessor datasource.FieldAccessor) int {
+	v, ok := accessor.Annotations()[datasource.PrecisionAnnotation]
+	if !ok {
+		return -1
+	}
+	precision, err := strconv.Atoi(v)
+	if err != nil || precision < 0 {
+		return -1
+	}
+	return precision
+}
+
+// writeFloatPrecision writes f with the given number of decimals, or like
+// writeFloat if precision is negative
+func (bits floatEncoder) writeFloatPrecision(e *encodeState, f float64, precision int) {
+	if precision < 0 {
+		bits.writeFloat(e, f)
+		return
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		e.err = fmt.Errorf("invalid float value")
+		return
+	}
+	e.Write(strconv.AppendFloat(e.scratch[:0], f, 'f', precision, int(bits)))
+}
+
 func (bits floatEncoder) writeFloat(e *encodeState, f float64) {
 	if math.IsInf(f, 0) || math.IsNaN(f) {
 		e.err = fmt.Errorf("invalid float value")
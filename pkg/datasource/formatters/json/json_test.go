@@ -0,0 +1,193 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package json
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestFloatPrecision(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	ratio, err := ds.AddField("ratio", api.Kind_Float32)
+	require.NoError(t, err)
+	avg, err := ds.AddField("avg", api.Kind_Float64, datasource.WithAnnotations(map[string]string{
+		datasource.PrecisionAnnotation: "4",
+	}))
+	require.NoError(t, err)
+	ratio2, err := ds.AddField("ratio2", api.Kind_Float32, datasource.WithAnnotations(map[string]string{
+		datasource.PrecisionAnnotation: "1",
+	}))
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, ratio.PutFloat32(data, 0.5))
+	require.NoError(t, avg.PutFloat64(data, 3.14159265))
+	require.NoError(t, ratio2.PutFloat32(data, 0.25))
+
+	formatter, err := New(ds)
+	require.NoError(t, err)
+
+	// Fields without precision use the shortest representation
+	require.Equal(t, `{"avg":3.1416,"ratio":0.5,"ratio2":0.2}`, string(formatter.Marshal(data)))
+}
+
+func TestHumanized(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	size, err := ds.AddField("size", api.Kind_Uint64, datasource.WithAnnotations(map[string]string{
+		datasource.UnitAnnotation:     string(metadatav1.UnitBytes),
+		datasource.HumanizeAnnotation: "true",
+	}))
+	require.NoError(t, err)
+	latency, err := ds.AddField("latency", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+		datasource.UnitAnnotation:     string(metadatav1.UnitNanoseconds),
+		datasource.HumanizeAnnotation: "true",
+	}))
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, size.PutUint64(data, 1468006))
+	require.NoError(t, latency.PutUint32(data, 2300000))
+
+	// The raw values are kept by default
+	formatter, err := New(ds)
+	require.NoError(t, err)
+	require.Equal(t, `{"latency":2300000,"size":1468006}`, string(formatter.Marshal(data)))
+
+	formatter, err = New(ds, WithHumanized(true))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"latency":2300000,"latency_humanized":"2.3 ms","size":1468006,"size_humanized":"1.4 MiB"}`,
+		string(formatter.Marshal(data)))
+}
+
+func TestDuration(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	latency, err := ds.AddField("latency", api.Kind_Uint64, datasource.WithAnnotations(map[string]string{
+		"columns.template": datasource.DurationTemplate,
+	}))
+	require.NoError(t, err)
+
+	formatter, err := New(ds)
+	require.NoError(t, err)
+
+	// Durations are only shown in a compact form in columns
+	for _, ns := range []uint64{830, 1240000, 2100000000} {
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, latency.PutUint64(data, ns))
+		require.Equal(t, fmt.Sprintf(`{"latency":%d}`, ns), string(formatter.Marshal(data)))
+	}
+}
+
+func TestLabels(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	success, err := ds.AddField("success", api.Kind_Uint8, datasource.WithAnnotations(map[string]string{
+		datasource.BoolTrueLabelAnnotation:  "yes",
+		datasource.BoolFalseLabelAnnotation: "no",
+	}))
+	require.NoError(t, err)
+	_, err = ds.AddField("port", api.Kind_Uint16, datasource.WithAnnotations(map[string]string{
+		datasource.ZeroAsAnnotation: "-",
+	}))
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, success.PutUint8(data, 1))
+
+	// Labels are only used by columns
+	formatter, err := New(ds)
+	require.NoError(t, err)
+	require.Equal(t, `{"port":0,"success":1}`, string(formatter.Marshal(data)))
+}
+
+func TestOutputName(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	saddr, err := ds.AddField("saddr_v4", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+		datasource.OutputNameAnnotation: "srcAddr",
+	}))
+	require.NoError(t, err)
+	pid, err := ds.AddField("pid", api.Kind_Uint32)
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, saddr.PutUint32(data, 0x0a000001))
+	require.NoError(t, pid.PutUint32(data, 42))
+
+	formatter, err := New(ds)
+	require.NoError(t, err)
+	require.Equal(t, `{"pid":42,"srcAddr":167772161}`, string(formatter.Marshal(data)))
+
+	// Fields are still looked up by their name
+	require.NotNil(t, ds.GetField("saddr_v4"))
+	require.Nil(t, ds.GetField("srcAddr"))
+	require.Equal(t, "saddr_v4", datasource.FieldByOutputName(ds, "srcAddr").Name())
+}
+
+func TestRaw(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	buckets, err := ds.AddField("buckets", api.Kind_String, datasource.WithAnnotations(map[string]string{
+		RawAnnotation: "true",
+	}))
+	require.NoError(t, err)
+	dev, err := ds.AddField("dev", api.Kind_Uint32)
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, dev.PutUint32(data, 8))
+
+	formatter, err := New(ds)
+	require.NoError(t, err)
+
+	// Empty raw fields are null, as they can't be written as is
+	require.Equal(t, `{"buckets":null,"dev":8}`, string(formatter.Marshal(data)))
+
+	require.NoError(t, buckets.PutString(data, `[{"count":1,"start":0,"end":1}]`))
+	require.Equal(t, `{"buckets":[{"count":1,"start":0,"end":1}],"dev":8}`, string(formatter.Marshal(data)))
+}
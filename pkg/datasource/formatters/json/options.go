@@ -50,3 +50,12 @@ func WithArray(val bool) Option {
 		formatter.array = val
 	}
 }
+
+// WithHumanized adds the human-readable form of fields with
+// datasource.HumanizeAnnotation next to their raw value, e.g. "size": 1468006
+// and "size_humanized": "1.4 MiB"
+func WithHumanized(val bool) Option {
+	return func(formatter *Formatter) {
+		formatter.humanized = val
+	}
+}
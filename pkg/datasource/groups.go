@@ -0,0 +1,32 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+// GroupAnnotation sets the name of the group of related fields a field belongs
+// to. Columns of a group share the group name as header prefix and the group
+// name can be used to select all of them at once.
+const GroupAnnotation = "columns.group"
+
+// FieldsInGroup returns the fields of ds belonging to group, in the order they
+// were added.
+func FieldsInGroup(ds DataSource, group string) []FieldAccessor {
+	var res []FieldAccessor
+	for _, f := range ds.Accessors(false) {
+		if f.Annotations()[GroupAnnotation] == group {
+			res = append(res, f)
+		}
+	}
+	return res
+}
@@ -0,0 +1,240 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+const (
+	// UnitAnnotation is the unit of the value of a numeric field
+	UnitAnnotation = "columns.unit"
+
+	// HumanizeAnnotation shows numeric fields with a unit in a human-readable
+	// form, like 1.4 MiB or 2.3 ms, when set to "true"
+	HumanizeAnnotation = "columns.humanize"
+
+	// DurationTemplate shows integer durations, given in nanoseconds unless
+	// UnitAnnotation says otherwise, in a compact form like 1.24ms or 830µs.
+	// PrecisionAnnotation sets the maximum number of decimals.
+	DurationTemplate = "duration"
+)
+
+type unitScale struct {
+	base     float64
+	suffixes []string
+}
+
+var (
+	bytesScale    = unitScale{base: 1024, suffixes: []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}}
+	durationScale = unitScale{base: 1000, suffixes: []string{"ns", "us", "ms", "s"}}
+	countScale    = unitScale{base: 1000, suffixes: []string{"", "k", "M", "G", "T", "P", "E"}}
+)
+
+// format shows v with one decimal in the largest unit it's at least one of.
+// Values in the smallest unit are shown as they are.
+func (s unitScale) format(v float64) string {
+	i := 0
+	// Move up before the value gets rounded to base, e.g. 1023.96 B
+	for math.Abs(v) >= s.base-0.05 && i < len(s.suffixes)-1 {
+		v /= s.base
+		i++
+	}
+
+	var num string
+	if i == 0 {
+		num = strconv.FormatFloat(v, 'f', -1, 64)
+	} else {
+		num = strconv.FormatFloat(v, 'f', 1, 64)
+	}
+	if s.suffixes[i] == "" {
+		return num
+	}
+	return num + " " + s.suffixes[i]
+}
+
+// Humanize returns v, given in unit, in a human-readable form. Values of
+// durations are scaled from nanoseconds up to seconds.
+func Humanize(unit metadatav1.Unit, v float64) (string, error) {
+	switch unit {
+	case metadatav1.UnitBytes:
+		return bytesScale.format(v), nil
+	case metadatav1.UnitNanoseconds:
+		return durationScale.format(v), nil
+	case metadatav1.UnitMicroseconds:
+		return durationScale.format(v * 1e3), nil
+	case metadatav1.UnitMilliseconds:
+		return durationScale.format(v * 1e6), nil
+	case metadatav1.UnitCount:
+		return countScale.format(v), nil
+	}
+	return "", fmt.Errorf("invalid unit %q", unit)
+}
+
+// HumanizedWidth returns the width needed to show humanized values of unit,
+// like "1023.9 KiB" for bytes. Durations above 999.9 seconds and negative
+// values can exceed it.
+func HumanizedWidth(unit metadatav1.Unit) int {
+	switch unit {
+	case metadatav1.UnitBytes:
+		return len("1023.9 KiB")
+	case metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds, metadatav1.UnitMilliseconds:
+		return len("999.9 ms")
+	case metadatav1.UnitCount:
+		return len("999.9 k")
+	}
+	return 0
+}
+
+var durationSuffixes = []string{"ns", "µs", "ms", "s"}
+
+// FormatDuration returns a duration of ns nanoseconds in the largest unit up to
+// seconds it's at least one of, with up to precision decimals. Nanoseconds are
+// shown without decimals.
+func FormatDuration(ns float64, precision int) string {
+	v := ns
+	i := 0
+	decimals := 0
+	// Move up before the value gets rounded to 1000, e.g. 999.999µs
+	for i < len(durationSuffixes)-1 && math.Abs(roundTo(v, decimals)) >= 1000 {
+		v /= 1000
+		i++
+		decimals = precision
+	}
+
+	num := strconv.FormatFloat(v, 'f', decimals, 64)
+	if strings.Contains(num, ".") {
+		num = strings.TrimRight(strings.TrimRight(num, "0"), ".")
+	}
+	return num + durationSuffixes[i]
+}
+
+// roundTo rounds v to the given number of decimals
+func roundTo(v float64, decimals int) float64 {
+	p := math.Pow10(decimals)
+	return math.Round(v*p) / p
+}
+
+// DurationWidth returns the width needed to show durations with the given
+// precision, like "999.99ms". Durations above 999 seconds and negative ones
+// can exceed it.
+func DurationWidth(precision int) int {
+	width := len("999") + len("ms")
+	if precision > 0 {
+		width += 1 + precision
+	}
+	return width
+}
+
+// DurationSettings returns the unit and the precision of a field using
+// DurationTemplate
+func DurationSettings(acc FieldAccessor) (metadatav1.Unit, int, bool) {
+	annotations := acc.Annotations()
+	if annotations["columns.template"] != DurationTemplate {
+		return metadatav1.UnitNone, 0, false
+	}
+	unit := metadatav1.Unit(annotations[UnitAnnotation])
+	if unit == metadatav1.UnitNone {
+		unit = metadatav1.UnitNanoseconds
+	}
+	precision := defaultPrecision
+	if v, err := strconv.Atoi(annotations[PrecisionAnnotation]); err == nil && v >= 0 {
+		precision = v
+	}
+	return unit, precision, true
+}
+
+// DurationField returns the value of a field using DurationTemplate in the
+// compact form of FormatDuration
+func DurationField(acc FieldAccessor, unit metadatav1.Unit, precision int, data Data) (string, error) {
+	v, err := numericValue(acc, data)
+	if err != nil {
+		return "", err
+	}
+	switch unit {
+	case metadatav1.UnitNanoseconds:
+	case metadatav1.UnitMicroseconds:
+		v *= 1e3
+	case metadatav1.UnitMilliseconds:
+		v *= 1e6
+	default:
+		return "", fmt.Errorf("invalid unit %q for a duration", unit)
+	}
+	return FormatDuration(v, precision), nil
+}
+
+// HumanizedUnit returns the unit of a field whose values must be humanized
+func HumanizedUnit(acc FieldAccessor) (metadatav1.Unit, bool) {
+	annotations := acc.Annotations()
+	if annotations[HumanizeAnnotation] != "true" {
+		return metadatav1.UnitNone, false
+	}
+	unit := metadatav1.Unit(annotations[UnitAnnotation])
+	if unit == metadatav1.UnitNone {
+		return metadatav1.UnitNone, false
+	}
+	return unit, true
+}
+
+// HumanizeField returns the value of a numeric field in a human-readable form
+func HumanizeField(acc FieldAccessor, unit metadatav1.Unit, data Data) (string, error) {
+	v, err := numericValue(acc, data)
+	if err != nil {
+		return "", err
+	}
+	return Humanize(unit, v)
+}
+
+// numericValue returns the value of a numeric field as a float64
+func numericValue(acc FieldAccessor, data Data) (float64, error) {
+	switch acc.Type() {
+	case api.Kind_Int8:
+		v, err := acc.Int8(data)
+		return float64(v), err
+	case api.Kind_Int16:
+		v, err := acc.Int16(data)
+		return float64(v), err
+	case api.Kind_Int32:
+		v, err := acc.Int32(data)
+		return float64(v), err
+	case api.Kind_Int64:
+		v, err := acc.Int64(data)
+		return float64(v), err
+	case api.Kind_Uint8:
+		v, err := acc.Uint8(data)
+		return float64(v), err
+	case api.Kind_Uint16:
+		v, err := acc.Uint16(data)
+		return float64(v), err
+	case api.Kind_Uint32:
+		v, err := acc.Uint32(data)
+		return float64(v), err
+	case api.Kind_Uint64:
+		v, err := acc.Uint64(data)
+		return float64(v), err
+	case api.Kind_Float32:
+		v, err := acc.Float32(data)
+		return float64(v), err
+	case api.Kind_Float64:
+		return acc.Float64(data)
+	}
+	return 0, fmt.Errorf("field %q is not numeric", acc.Name())
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestHumanize(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		unit     metadatav1.Unit
+		value    float64
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"bytes":             {unit: metadatav1.UnitBytes, value: 512, expected: "512 B"},
+		"kibibytes":         {unit: metadatav1.UnitBytes, value: 1536, expected: "1.5 KiB"},
+		"mebibytes":         {unit: metadatav1.UnitBytes, value: 1468006, expected: "1.4 MiB"},
+		"bytes_rounding_up": {unit: metadatav1.UnitBytes, value: 1023.97, expected: "1.0 KiB"},
+		"exbibytes":         {unit: metadatav1.UnitBytes, value: 1 << 62, expected: "4.0 EiB"},
+		"nanoseconds":       {unit: metadatav1.UnitNanoseconds, value: 999, expected: "999 ns"},
+		"ns_to_ms":          {unit: metadatav1.UnitNanoseconds, value: 2300000, expected: "2.3 ms"},
+		"ns_to_s":           {unit: metadatav1.UnitNanoseconds, value: 4500000000, expected: "4.5 s"},
+		"microseconds":      {unit: metadatav1.UnitMicroseconds, value: 250, expected: "250.0 us"},
+		"milliseconds":      {unit: metadatav1.UnitMilliseconds, value: 2.3, expected: "2.3 ms"},
+		"ms_to_s":           {unit: metadatav1.UnitMilliseconds, value: 61000, expected: "61.0 s"},
+		"negative":          {unit: metadatav1.UnitNanoseconds, value: -1500, expected: "-1.5 us"},
+		"count":             {unit: metadatav1.UnitCount, value: 42, expected: "42"},
+		"count_millions":    {unit: metadatav1.UnitCount, value: 3200000, expected: "3.2 M"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			str, err := Humanize(test.unit, test.value)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, str)
+		})
+	}
+
+	_, err := Humanize("KiB", 1)
+	require.ErrorContains(t, err, "invalid unit")
+}
+
+func TestFormatDuration(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		ns        float64
+		precision int
+		expected  string
+	}
+
+	tests := map[string]testCase{
+		"zero":                {ns: 0, precision: 2, expected: "0ns"},
+		"sub_microsecond":     {ns: 830, precision: 2, expected: "830ns"},
+		"microseconds":        {ns: 830000, precision: 2, expected: "830µs"},
+		"milliseconds":        {ns: 1240000, precision: 2, expected: "1.24ms"},
+		"milliseconds_zeros":  {ns: 2000000, precision: 2, expected: "2ms"},
+		"seconds":             {ns: 2100000000, precision: 2, expected: "2.1s"},
+		"multi_seconds":       {ns: 125500000000, precision: 2, expected: "125.5s"},
+		"no_decimals":         {ns: 1240000, precision: 0, expected: "1ms"},
+		"more_decimals":       {ns: 1234567, precision: 4, expected: "1.2346ms"},
+		"rounding_to_next":    {ns: 999999, precision: 2, expected: "1ms"},
+		"negative":            {ns: -1500, precision: 2, expected: "-1.5µs"},
+		"beyond_max_seconds":  {ns: 4000e9, precision: 1, expected: "4000s"},
+		"rounding_nanosecond": {ns: 999.6, precision: 2, expected: "1µs"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, FormatDuration(test.ns, test.precision))
+		})
+	}
+
+	require.Equal(t, len("999.99ms"), DurationWidth(2))
+	require.Equal(t, len("999ms"), DurationWidth(0))
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+const (
+	// BoolTrueLabelAnnotation and BoolFalseLabelAnnotation are shown in
+	// columns instead of the value of boolean fields, like "yes" and "no"
+	BoolTrueLabelAnnotation  = "columns.boolLabels.true"
+	BoolFalseLabelAnnotation = "columns.boolLabels.false"
+
+	// ZeroAsAnnotation is shown in columns instead of the zero value of
+	// numeric fields, like "-" for optional values
+	ZeroAsAnnotation = "columns.zeroAs"
+)
+
+// BoolLabels returns the labels shown for the true and false values of a
+// field, if it has them
+func BoolLabels(acc FieldAccessor) (string, string, bool) {
+	annotations := acc.Annotations()
+	trueLabel, hasTrue := annotations[BoolTrueLabelAnnotation]
+	falseLabel, hasFalse := annotations[BoolFalseLabelAnnotation]
+	if !hasTrue && !hasFalse {
+		return "", "", false
+	}
+	if !hasTrue {
+		trueLabel = "true"
+	}
+	if !hasFalse {
+		falseLabel = "false"
+	}
+	return trueLabel, falseLabel, true
+}
+
+// ZeroAs returns the placeholder shown for the zero value of a field, if it
+// has one
+func ZeroAs(acc FieldAccessor) (string, bool) {
+	zeroAs, ok := acc.Annotations()[ZeroAsAnnotation]
+	return zeroAs, ok
+}
+
+// isZero returns true if all the bytes of the value of the field are zero
+func isZero(acc FieldAccessor, data Data) bool {
+	for _, b := range acc.Get(data) {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// labelsWidth returns the width needed to show any of labels
+func labelsWidth(labels ...string) int {
+	width := 0
+	for _, label := range labels {
+		width = max(width, utf8.RuneCountInString(label))
+	}
+	return width
+}
+
+// formatScalar returns the value of a scalar field as the columns show it.
+// Floats use precision decimals.
+func formatScalar(acc FieldAccessor, data Data, precision int) string {
+	switch acc.Type() {
+	case api.Kind_Bool:
+		v, _ := acc.Bool(data)
+		return strconv.FormatBool(v)
+	case api.Kind_Int64:
+		v, _ := acc.Int64(data)
+		return strconv.FormatInt(v, 10)
+	case api.Kind_Uint64:
+		v, _ := acc.Uint64(data)
+		return strconv.FormatUint(v, 10)
+	case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32:
+		// Exact as float64
+		v, _ := numericValue(acc, data)
+		return strconv.FormatInt(int64(v), 10)
+	case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32:
+		v, _ := numericValue(acc, data)
+		return strconv.FormatUint(uint64(v), 10)
+	case api.Kind_Float32, api.Kind_Float64:
+		v, _ := numericValue(acc, data)
+		return strconv.FormatFloat(v, 'f', precision, 64)
+	}
+	str, _ := acc.String(data)
+	return str
+}
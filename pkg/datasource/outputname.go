@@ -0,0 +1,39 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+// OutputNameAnnotation sets the key of a field in JSON and YAML output. The
+// field keeps its name everywhere else, like in columns and filters.
+const OutputNameAnnotation = "output.name"
+
+// FieldOutputName returns the key of the field accessed by f in JSON and YAML
+// output.
+func FieldOutputName(f FieldAccessor) string {
+	if name := f.Annotations()[OutputNameAnnotation]; name != "" {
+		return name
+	}
+	return f.Name()
+}
+
+// FieldByOutputName returns the field of ds using name as output name, or nil
+// if there is none.
+func FieldByOutputName(ds DataSource, name string) FieldAccessor {
+	for _, f := range ds.Accessors(false) {
+		if f.Annotations()[OutputNameAnnotation] == name {
+			return f
+		}
+	}
+	return nil
+}
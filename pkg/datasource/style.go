@@ -0,0 +1,188 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ColorAnnotation sets the color used to show the value of a field in
+	// terminals, like "red"
+	ColorAnnotation = "columns.color"
+	// StyleAnnotation sets a comma separated list of styles used to show the
+	// value of a field in terminals, like "bold"
+	StyleAnnotation = "columns.style"
+	// StyleWhenAnnotation restricts the color and style of a field to the
+	// events matching a condition on a field of the same data source, like
+	// "ret!=0" or "latency>=1000000"
+	StyleWhenAnnotation = "columns.style.when"
+)
+
+var colorCodes = map[string]string{
+	"black":   "30",
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+var styleCodes = map[string]string{
+	"bold":      "1",
+	"dim":       "2",
+	"italic":    "3",
+	"underline": "4",
+}
+
+// Operators supported in style conditions; the two-character ones come
+// first so they are matched before their prefixes
+var styleConditionOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// StyleSequence returns the ANSI escape sequence showing text with the given
+// color and comma separated styles. Both of them are optional.
+func StyleSequence(color, style string) (string, error) {
+	var codes []string
+	if color != "" {
+		code, ok := colorCodes[color]
+		if !ok {
+			return "", fmt.Errorf("unknown color %q, expected one of %s", color, strings.Join(sortedKeys(colorCodes), ", "))
+		}
+		codes = append(codes, code)
+	}
+	if style != "" {
+		for _, s := range strings.Split(style, ",") {
+			s = strings.TrimSpace(s)
+			code, ok := styleCodes[s]
+			if !ok {
+				return "", fmt.Errorf("unknown style %q, expected one of %s", s, strings.Join(sortedKeys(styleCodes), ", "))
+			}
+			codes = append(codes, code)
+		}
+	}
+	if len(codes) == 0 {
+		return "", nil
+	}
+	return "\x1b[" + strings.Join(codes, ";") + "m", nil
+}
+
+// StyleCondition is a comparison of the value of a field with a constant,
+// see StyleWhenAnnotation
+type StyleCondition struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// ParseStyleCondition parses a condition like "ret!=0"
+func ParseStyleCondition(s string) (*StyleCondition, error) {
+	for _, op := range styleConditionOps {
+		field, value, ok := strings.Cut(s, op)
+		if !ok {
+			continue
+		}
+		field = strings.TrimSpace(field)
+		if field == "" {
+			return nil, fmt.Errorf("invalid condition %q: missing field", s)
+		}
+		return &StyleCondition{Field: field, Op: op, Value: strings.TrimSpace(value)}, nil
+	}
+	return nil, fmt.Errorf("invalid condition %q: expected <field><op><value> with op one of %s",
+		s, strings.Join(styleConditionOps, ", "))
+}
+
+// Match returns whether the value of the field accessed by acc in data
+// fulfills the condition. Numeric fields are compared by value, other fields
+// by their string representation.
+func (c *StyleCondition) Match(acc FieldAccessor, data Data) bool {
+	if v, err := numericValue(acc, data); err == nil {
+		want, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			return false
+		}
+		return compare(v, want, c.Op)
+	}
+	v, err := acc.String(data)
+	if err != nil {
+		return false
+	}
+	return compare(v, c.Value, c.Op)
+}
+
+func compare[T float64 | string](a, b T, op string) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldStyle returns a function returning the ANSI escape sequence used to
+// show the value of the field accessed by acc in an event, or nil if the field
+// isn't styled
+func fieldStyle(ds DataSource, acc FieldAccessor) (func(data Data) string, error) {
+	annotations := acc.Annotations()
+	seq, err := StyleSequence(annotations[ColorAnnotation], annotations[StyleAnnotation])
+	if err != nil {
+		return nil, err
+	}
+	if seq == "" {
+		return nil, nil
+	}
+
+	when, ok := annotations[StyleWhenAnnotation]
+	if !ok {
+		return func(Data) string { return seq }, nil
+	}
+
+	cond, err := ParseStyleCondition(when)
+	if err != nil {
+		return nil, err
+	}
+	condAcc := ds.GetField(cond.Field)
+	if condAcc == nil {
+		return nil, fmt.Errorf("condition %q: field %q not found", when, cond.Field)
+	}
+	return func(data Data) string {
+		if cond.Match(condAcc, data) {
+			return seq
+		}
+		return ""
+	}, nil
+}
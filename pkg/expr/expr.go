@@ -0,0 +1,590 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expr implements the small expression language used by computed
+// fields. Expressions combine fields and constants using arithmetic (+, -, *,
+// /, %), comparisons (==, !=, <, <=, >, >=), logical operators (&&, ||, !),
+// string concatenation (+) and the conditional operator (c ? a : b).
+//
+// Values are numbers (float64), strings or booleans. Expressions are type
+// checked before being evaluated, so evaluating them can only fail on
+// divisions by zero.
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of the value of an expression
+type Type int
+
+const (
+	TypeInvalid Type = iota
+	TypeNumber
+	TypeString
+	TypeBool
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeNumber:
+		return "number"
+	case TypeString:
+		return "string"
+	case TypeBool:
+		return "bool"
+	}
+	return "invalid"
+}
+
+// ErrDivisionByZero is returned when evaluating a division or modulo by zero
+var ErrDivisionByZero = errors.New("division by zero")
+
+// Expr is a parsed expression
+type Expr struct {
+	src  string
+	root node
+}
+
+// Parse parses the expression in s
+func Parse(s string) (*Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing expression %q: %w", s, err)
+	}
+	p := &parser{tokens: tokens}
+	root, err := p.ternary()
+	if err != nil {
+		return nil, fmt.Errorf("parsing expression %q: %w", s, err)
+	}
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("parsing expression %q: unexpected %q", s, tok.text)
+	}
+	return &Expr{src: s, root: root}, nil
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Fields returns the sorted names of the fields referenced by the expression
+func (e *Expr) Fields() []string {
+	set := make(map[string]struct{})
+	e.root.fields(set)
+	res := make([]string, 0, len(set))
+	for name := range set {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Check type checks the expression given the types of the fields and returns
+// the type of its value
+func (e *Expr) Check(types map[string]Type) (Type, error) {
+	return e.root.check(types)
+}
+
+// Eval evaluates the expression, getting the values of the fields from lookup.
+// The expression must have been type checked with the types of the values
+// returned by lookup.
+func (e *Expr) Eval(lookup func(name string) (any, error)) (any, error) {
+	return e.root.eval(lookup)
+}
+
+// Format returns the string representation of a value returned by Eval
+func Format(v any) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	return fmt.Sprint(v)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// Operators, the two-character ones first so they are matched before their
+// prefixes
+var operators = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")",
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			start := i
+			for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: s[start:i]})
+		case isIdentStart(c):
+			start := i
+			for i < len(s) && (isIdentStart(s[i]) || isDigit(s[i]) || s[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: s[start:i]})
+		case c == '"':
+			start := i
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(s) {
+				return nil, errors.New("unterminated string")
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: s[start:i]})
+		default:
+			found := false
+			for _, op := range operators {
+				if strings.HasPrefix(s[i:], op) {
+					tokens = append(tokens, token{kind: tokenOp, text: op})
+					i += len(op)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it's one of the given operators
+func (p *parser) accept(ops ...string) (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if tok.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) ternary() (node, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept(":"); !ok {
+		return nil, errors.New("expected \":\"")
+	}
+	els, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return &conditional{cond: cond, then: then, els: els}, nil
+}
+
+// Binary operators by precedence, from the lowest to the highest
+var precedences = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(precedences) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(precedences[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &unary{op: op, operand: operand}, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokenNumber:
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return &constant{value: v}, nil
+	case tokenString:
+		v, err := strconv.Unquote(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", tok.text)
+		}
+		return &constant{value: v}, nil
+	case tokenIdent:
+		switch tok.text {
+		case "true":
+			return &constant{value: true}, nil
+		case "false":
+			return &constant{value: false}, nil
+		}
+		return &field{name: tok.text}, nil
+	case tokenOp:
+		if tok.text == "(" {
+			n, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := p.accept(")"); !ok {
+				return nil, errors.New("expected \")\"")
+			}
+			return n, nil
+		}
+		return nil, fmt.Errorf("unexpected %q", tok.text)
+	}
+	return nil, errors.New("unexpected end of expression")
+}
+
+type node interface {
+	fields(set map[string]struct{})
+	check(types map[string]Type) (Type, error)
+	eval(lookup func(name string) (any, error)) (any, error)
+}
+
+type constant struct {
+	value any
+}
+
+func (c *constant) fields(map[string]struct{}) {}
+
+func (c *constant) check(map[string]Type) (Type, error) {
+	switch c.value.(type) {
+	case float64:
+		return TypeNumber, nil
+	case string:
+		return TypeString, nil
+	default:
+		return TypeBool, nil
+	}
+}
+
+func (c *constant) eval(func(string) (any, error)) (any, error) {
+	return c.value, nil
+}
+
+type field struct {
+	name string
+}
+
+func (f *field) fields(set map[string]struct{}) {
+	set[f.name] = struct{}{}
+}
+
+func (f *field) check(types map[string]Type) (Type, error) {
+	typ, ok := types[f.name]
+	if !ok {
+		return TypeInvalid, fmt.Errorf("unknown field %q", f.name)
+	}
+	return typ, nil
+}
+
+func (f *field) eval(lookup func(string) (any, error)) (any, error) {
+	return lookup(f.name)
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (u *unary) fields(set map[string]struct{}) {
+	u.operand.fields(set)
+}
+
+func (u *unary) check(types map[string]Type) (Type, error) {
+	typ, err := u.operand.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	want := TypeNumber
+	if u.op == "!" {
+		want = TypeBool
+	}
+	if typ != want {
+		return TypeInvalid, fmt.Errorf("operator %q requires a %s, got %s", u.op, want, typ)
+	}
+	return typ, nil
+}
+
+func (u *unary) eval(lookup func(string) (any, error)) (any, error) {
+	v, err := u.operand.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	if u.op == "!" {
+		return !v.(bool), nil
+	}
+	return -v.(float64), nil
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (b *binary) fields(set map[string]struct{}) {
+	b.left.fields(set)
+	b.right.fields(set)
+}
+
+func (b *binary) check(types map[string]Type) (Type, error) {
+	left, err := b.left.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	right, err := b.right.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	if left != right {
+		return TypeInvalid, fmt.Errorf("operator %q can't be used with a %s and a %s", b.op, left, right)
+	}
+
+	switch b.op {
+	case "&&", "||":
+		if left != TypeBool {
+			return TypeInvalid, fmt.Errorf("operator %q requires bools, got %s", b.op, left)
+		}
+		return TypeBool, nil
+	case "==", "!=":
+		return TypeBool, nil
+	case "<", "<=", ">", ">=":
+		if left == TypeBool {
+			return TypeInvalid, fmt.Errorf("operator %q can't be used with bools", b.op)
+		}
+		return TypeBool, nil
+	case "+":
+		if left == TypeBool {
+			return TypeInvalid, fmt.Errorf("operator %q can't be used with bools", b.op)
+		}
+		return left, nil
+	default:
+		if left != TypeNumber {
+			return TypeInvalid, fmt.Errorf("operator %q requires numbers, got %s", b.op, left)
+		}
+		return TypeNumber, nil
+	}
+}
+
+func (b *binary) eval(lookup func(string) (any, error)) (any, error) {
+	left, err := b.left.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	// Short-circuit logical operators
+	switch b.op {
+	case "&&":
+		if !left.(bool) {
+			return false, nil
+		}
+		return b.right.eval(lookup)
+	case "||":
+		if left.(bool) {
+			return true, nil
+		}
+		return b.right.eval(lookup)
+	}
+
+	right, err := b.right.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+
+	switch b.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	if l, ok := left.(string); ok {
+		r := right.(string)
+		switch b.op {
+		case "+":
+			return l + r, nil
+		case "<":
+			return l < r, nil
+		case "<=":
+			return l <= r, nil
+		case ">":
+			return l > r, nil
+		case ">=":
+			return l >= r, nil
+		}
+		return nil, fmt.Errorf("invalid operator %q for strings", b.op)
+	}
+
+	l, r := left.(float64), right.(float64)
+	switch b.op {
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	case "/":
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return l / r, nil
+	case "%":
+		if r == 0 {
+			return nil, ErrDivisionByZero
+		}
+		return math.Mod(l, r), nil
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	}
+	return nil, fmt.Errorf("invalid operator %q for numbers", b.op)
+}
+
+type conditional struct {
+	cond, then, els node
+}
+
+func (c *conditional) fields(set map[string]struct{}) {
+	c.cond.fields(set)
+	c.then.fields(set)
+	c.els.fields(set)
+}
+
+func (c *conditional) check(types map[string]Type) (Type, error) {
+	cond, err := c.cond.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	if cond != TypeBool {
+		return TypeInvalid, fmt.Errorf("condition requires a bool, got %s", cond)
+	}
+	then, err := c.then.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	els, err := c.els.check(types)
+	if err != nil {
+		return TypeInvalid, err
+	}
+	if then != els {
+		return TypeInvalid, fmt.Errorf("branches of conditional have different types: %s and %s", then, els)
+	}
+	return then, nil
+}
+
+func (c *conditional) eval(lookup func(string) (any, error)) (any, error) {
+	cond, err := c.cond.eval(lookup)
+	if err != nil {
+		return nil, err
+	}
+	if cond.(bool) {
+		return c.then.eval(lookup)
+	}
+	return c.els.eval(lookup)
+}
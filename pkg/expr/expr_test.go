@@ -0,0 +1,238 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpr(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		expr           string
+		expectedType   Type
+		expectedValue  any
+		expectedFields []string
+	}
+
+	types := map[string]Type{
+		"bytes_out": TypeNumber,
+		"duration":  TypeNumber,
+		"dport":     TypeNumber,
+		"comm":      TypeString,
+		"proc.comm": TypeString,
+		"success":   TypeBool,
+	}
+	values := map[string]any{
+		"bytes_out": 3000.0,
+		"duration":  2.0,
+		"dport":     53.0,
+		"comm":      "curl",
+		"proc.comm": "bash",
+		"success":   true,
+	}
+
+	tests := map[string]testCase{
+		"division": {
+			expr:           "bytes_out/duration",
+			expectedType:   TypeNumber,
+			expectedValue:  1500.0,
+			expectedFields: []string{"bytes_out", "duration"},
+		},
+		"precedence": {
+			expr:          "1 + 2 * 3 - -4 % 3",
+			expectedType:  TypeNumber,
+			expectedValue: 8.0,
+		},
+		"parentheses": {
+			expr:          "(1 + 2) * 3",
+			expectedType:  TypeNumber,
+			expectedValue: 9.0,
+		},
+		"conditional": {
+			expr:           `dport == 53 ? "dns" : "other"`,
+			expectedType:   TypeString,
+			expectedValue:  "dns",
+			expectedFields: []string{"dport"},
+		},
+		"nested_conditional": {
+			expr:          `dport == 80 ? "http" : dport == 53 ? "dns" : "other"`,
+			expectedType:  TypeString,
+			expectedValue: "dns",
+		},
+		"concat": {
+			expr:           `comm + "/" + proc.comm`,
+			expectedType:   TypeString,
+			expectedValue:  "curl/bash",
+			expectedFields: []string{"comm", "proc.comm"},
+		},
+		"logical": {
+			expr:          `!success || comm != "curl" && dport >= 53`,
+			expectedType:  TypeBool,
+			expectedValue: false,
+		},
+		"string_comparison": {
+			expr:          `comm < "wget"`,
+			expectedType:  TypeBool,
+			expectedValue: true,
+		},
+		"decimals": {
+			expr:          "duration * .5 + 0.25",
+			expectedType:  TypeNumber,
+			expectedValue: 1.25,
+		},
+		"escaped_string": {
+			expr:          `"a\"b"`,
+			expectedType:  TypeString,
+			expectedValue: `a"b`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e, err := Parse(test.expr)
+			require.NoError(t, err)
+
+			if test.expectedFields != nil {
+				require.Equal(t, test.expectedFields, e.Fields())
+			}
+
+			typ, err := e.Check(types)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedType, typ)
+
+			v, err := e.Eval(func(name string) (any, error) {
+				return values[name], nil
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.expectedValue, v)
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		expr              string
+		expectedErrString string
+	}
+
+	types := map[string]Type{
+		"bytes": TypeNumber,
+		"comm":  TypeString,
+		"ok":    TypeBool,
+	}
+
+	tests := map[string]testCase{
+		"unknown_field": {
+			expr:              "bytes / duration",
+			expectedErrString: `unknown field "duration"`,
+		},
+		"mixed_types": {
+			expr:              "bytes + comm",
+			expectedErrString: `operator "+" can't be used with a number and a string`,
+		},
+		"string_division": {
+			expr:              `comm / "a"`,
+			expectedErrString: `operator "/" requires numbers, got string`,
+		},
+		"bool_arithmetic": {
+			expr:              "ok + ok",
+			expectedErrString: `operator "+" can't be used with bools`,
+		},
+		"non_bool_condition": {
+			expr:              `bytes ? "a" : "b"`,
+			expectedErrString: "condition requires a bool, got number",
+		},
+		"different_branches": {
+			expr:              `ok ? "a" : 1`,
+			expectedErrString: "branches of conditional have different types: string and number",
+		},
+		"negated_string": {
+			expr:              "-comm",
+			expectedErrString: `operator "-" requires a number, got string`,
+		},
+		"incomplete": {
+			expr:              "bytes +",
+			expectedErrString: "unexpected end of expression",
+		},
+		"missing_colon": {
+			expr:              `ok ? "a"`,
+			expectedErrString: `expected ":"`,
+		},
+		"unbalanced_parentheses": {
+			expr:              "(bytes + 1",
+			expectedErrString: `expected ")"`,
+		},
+		"trailing": {
+			expr:              "bytes 1",
+			expectedErrString: `unexpected "1"`,
+		},
+		"unterminated_string": {
+			expr:              `comm + "abc`,
+			expectedErrString: "unterminated string",
+		},
+		"invalid_character": {
+			expr:              "bytes & 1",
+			expectedErrString: `unexpected character '&'`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			e, err := Parse(test.expr)
+			if err == nil {
+				_, err = e.Check(types)
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestExprDivisionByZero(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"bytes / duration", "bytes % duration"} {
+		e, err := Parse(s)
+		require.NoError(t, err)
+
+		_, err = e.Eval(func(name string) (any, error) {
+			if name == "duration" {
+				return 0.0, nil
+			}
+			return 10.0, nil
+		})
+		require.ErrorIs(t, err, ErrDivisionByZero)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "1500", Format(1500.0))
+	require.Equal(t, "0.25", Format(0.25))
+	require.Equal(t, "dns", Format("dns"))
+	require.Equal(t, "true", Format(true))
+}
@@ -26,4 +26,8 @@ const (
 	// Name of the map that stores the mount namespace inode id to filter on.
 	// Keep in syn with name used in include/gadget/mntns_filter.h.
 	MntNsFilterMapName = "gadget_mntns_filter_map"
+
+	// Suffix of the variable holding the prefix length of a cidr param, next
+	// to the one holding its address, like saddr and saddr_prefixlen.
+	PrefixLenSuffix = "_prefixlen"
 )
@@ -0,0 +1,45 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CheckContainerFilter returns an error if the user asked to filter events by
+// container, setting one of the given params, but the gadget can't do it:
+// it neither defines the mount namespace filter map nor attaches to each
+// container. The filter would be silently ignored otherwise.
+func CheckContainerFilter(canFilter bool, filterParams map[string]string) error {
+	if canFilter {
+		return nil
+	}
+
+	var set []string
+	for key, value := range filterParams {
+		if value != "" {
+			set = append(set, key)
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	sort.Strings(set)
+
+	return fmt.Errorf("gadget can't filter by container (%s set): it doesn't define the %q map, see include/gadget/mntns_filter.h",
+		strings.Join(set, ", "), MntNsFilterMapName)
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckContainerFilter(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		canFilter         bool
+		filterParams      map[string]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"filter_map": {
+			canFilter:    true,
+			filterParams: map[string]string{"containername": "nginx"},
+		},
+		"not_requested": {
+			filterParams: map[string]string{"containername": "", "podname": ""},
+		},
+		"requested_without_map": {
+			filterParams:      map[string]string{"containername": "nginx", "podname": ""},
+			expectedErrString: "gadget can't filter by container (containername set): it doesn't define the \"gadget_mntns_filter_map\" map",
+		},
+		"several_requested_without_map": {
+			filterParams:      map[string]string{"podname": "web", "containername": "nginx"},
+			expectedErrString: "(containername, podname set)",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckContainerFilter(test.canFilter, test.filterParams)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -163,6 +163,15 @@ func WallTimeFromBootTime(ts uint64) types.Time {
 	return types.Time(time.Unix(0, int64(ts)).Add(timeDiff).UnixNano())
 }
 
+// CurrentKernelVersion returns the version of the running kernel
+func CurrentKernelVersion() (KernelVersion, error) {
+	var uts unix.Utsname
+	if err := unix.Uname(&uts); err != nil {
+		return KernelVersion{}, fmt.Errorf("calling uname: %w", err)
+	}
+	return ParseKernelVersion(unix.ByteSliceToString(uts.Release[:]))
+}
+
 // HasBpfKtimeGetBootNs returns true if bpf_ktime_get_boot_ns is available
 func HasBpfKtimeGetBootNs() bool {
 	// We only care about the helper, hence test with ebpf.SocketFilter that exist in all
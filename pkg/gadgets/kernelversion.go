@@ -0,0 +1,68 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// KernelVersion is the version of a kernel, like 5.15.0
+type KernelVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+func (v KernelVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less returns true if v is older than other
+func (v KernelVersion) Less(other KernelVersion) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// ParseKernelVersion parses versions like "5.15", "5.15.0" or kernel releases
+// like "5.15.0-91-generic", whose suffix is ignored
+func ParseKernelVersion(s string) (KernelVersion, error) {
+	release, _, _ := strings.Cut(s, "-")
+	parts := strings.Split(release, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return KernelVersion{}, fmt.Errorf("invalid kernel version %q: expected major.minor[.patch]", s)
+	}
+
+	var numbers [3]int
+	for i, part := range parts {
+		// Releases like "6.8.0+" can have other suffixes
+		if i == 2 {
+			part = strings.TrimRightFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return KernelVersion{}, fmt.Errorf("invalid kernel version %q: expected major.minor[.patch]", s)
+		}
+		numbers[i] = n
+	}
+
+	return KernelVersion{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
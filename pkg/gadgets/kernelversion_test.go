@@ -0,0 +1,67 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKernelVersion(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		version           string
+		expected          KernelVersion
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"major_minor":  {version: "5.15", expected: KernelVersion{5, 15, 0}},
+		"patch":        {version: "6.1.12", expected: KernelVersion{6, 1, 12}},
+		"release":      {version: "5.15.0-91-generic", expected: KernelVersion{5, 15, 0}},
+		"plus_suffix":  {version: "6.8.0+", expected: KernelVersion{6, 8, 0}},
+		"major_only":   {version: "5", expectedErrString: "invalid kernel version \"5\""},
+		"too_many":     {version: "5.15.0.1", expectedErrString: "invalid kernel version"},
+		"not_a_number": {version: "5.x", expectedErrString: "invalid kernel version"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			v, err := ParseKernelVersion(test.version)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestKernelVersionLess(t *testing.T) {
+	t.Parallel()
+
+	// Versions are compared numerically, not as strings
+	require.True(t, KernelVersion{5, 4, 0}.Less(KernelVersion{5, 15, 0}))
+	require.True(t, KernelVersion{5, 15, 0}.Less(KernelVersion{6, 1, 0}))
+	require.True(t, KernelVersion{5, 15, 0}.Less(KernelVersion{5, 15, 1}))
+	require.False(t, KernelVersion{5, 15, 0}.Less(KernelVersion{5, 15, 0}))
+	require.False(t, KernelVersion{6, 0, 0}.Less(KernelVersion{5, 19, 9}))
+}
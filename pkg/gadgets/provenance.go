@@ -0,0 +1,127 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+)
+
+// ProgramIdTypeName is the name of the type gadgets use to store the id of the
+// program that produced an event. Keep this aligned with
+// include/gadget/types.h
+const ProgramIdTypeName = "gadget_program_id"
+
+const (
+	DirectionEntry   = "entry"
+	DirectionExit    = "exit"
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+)
+
+// ProgramInfo describes where an event comes from
+type ProgramInfo struct {
+	// ID is the value returned by gadget_get_program_id() in the program. It's
+	// passed as the BPF cookie when attaching it.
+	ID          uint64
+	Name        string
+	AttachPoint string
+	// Direction is the entry/exit or ingress/egress side of the hook, if any
+	Direction string
+}
+
+// GetProgramsInfo returns the provenance information of all programs in
+// spec. IDs are assigned in the alphabetical order of the program names,
+// starting at 1, so they're stable for a given eBPF object. 0 is left for
+// programs that couldn't be attached with a cookie.
+func GetProgramsInfo(spec *ebpf.CollectionSpec) map[string]ProgramInfo {
+	names := make([]string, 0, len(spec.Programs))
+	for name := range spec.Programs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	infos := make(map[string]ProgramInfo, len(names))
+	for idx, name := range names {
+		p := spec.Programs[name]
+		infos[name] = ProgramInfo{
+			ID:          uint64(idx + 1),
+			Name:        name,
+			AttachPoint: programAttachPoint(p),
+			Direction:   programDirection(p),
+		}
+	}
+	return infos
+}
+
+func programAttachPoint(p *ebpf.ProgramSpec) string {
+	if p.AttachTo != "" {
+		return p.AttachTo
+	}
+	// e.g. classifier/ingress/drop
+	parts := strings.SplitN(p.SectionName, "/", 2)
+	if len(parts) == 2 {
+		return parts[1]
+	}
+	return p.SectionName
+}
+
+func programDirection(p *ebpf.ProgramSpec) string {
+	section, attachTo, _ := strings.Cut(p.SectionName, "/")
+	switch section {
+	case "kprobe", "uprobe", "usdt", "fentry":
+		return DirectionEntry
+	case "kretprobe", "uretprobe", "fexit":
+		return DirectionExit
+	case "classifier", "tc":
+		if strings.HasPrefix(attachTo, DirectionIngress) {
+			return DirectionIngress
+		}
+		if strings.HasPrefix(attachTo, DirectionEgress) {
+			return DirectionEgress
+		}
+	case "tracepoint", "tp", "raw_tracepoint", "raw_tp", "tp_btf":
+		// syscalls/sys_enter_openat, raw_syscalls/sys_exit
+		_, event, _ := strings.Cut(attachTo, "/")
+		if event == "" {
+			event = attachTo
+		}
+		if strings.HasPrefix(event, "sys_enter") {
+			return DirectionEntry
+		}
+		if strings.HasPrefix(event, "sys_exit") {
+			return DirectionExit
+		}
+	}
+	return ""
+}
+
+// GetMapProducers returns the sorted names of the programs that reference
+// the map with the given name.
+func GetMapProducers(spec *ebpf.CollectionSpec, mapName string) []string {
+	var producers []string
+	for name, p := range spec.Programs {
+		for _, ins := range p.Instructions {
+			if ins.IsLoadFromMap() && ins.Reference() == mapName {
+				producers = append(producers, name)
+				break
+			}
+		}
+	}
+	sort.Strings(producers)
+	return producers
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gadgets
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/require"
+)
+
+func programUsingMap(sectionName, attachTo, mapName string) *ebpf.ProgramSpec {
+	insns := asm.Instructions{}
+	if mapName != "" {
+		insns = append(insns, asm.LoadMapPtr(asm.R1, 0).WithReference(mapName))
+	}
+	insns = append(insns, asm.Mov.Imm(asm.R0, 0), asm.Return())
+
+	return &ebpf.ProgramSpec{
+		SectionName:  sectionName,
+		AttachTo:     attachTo,
+		Instructions: insns,
+	}
+}
+
+func TestGetProgramsInfo(t *testing.T) {
+	t.Parallel()
+
+	spec := &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_execve_x": programUsingMap("tracepoint/syscalls/sys_exit_execve", "syscalls/sys_exit_execve", "events"),
+			"ig_execve_e": programUsingMap("tracepoint/syscalls/sys_enter_execve", "syscalls/sys_enter_execve", "events"),
+			"ig_open":     programUsingMap("kprobe/do_sys_openat2", "do_sys_openat2", "events"),
+			"ig_open_ret": programUsingMap("kretprobe/do_sys_openat2", "do_sys_openat2", ""),
+			"ig_tc":       programUsingMap("classifier/egress/drop", "", "other"),
+		},
+	}
+
+	expected := map[string]ProgramInfo{
+		"ig_execve_e": {ID: 1, Name: "ig_execve_e", AttachPoint: "syscalls/sys_enter_execve", Direction: DirectionEntry},
+		"ig_execve_x": {ID: 2, Name: "ig_execve_x", AttachPoint: "syscalls/sys_exit_execve", Direction: DirectionExit},
+		"ig_open":     {ID: 3, Name: "ig_open", AttachPoint: "do_sys_openat2", Direction: DirectionEntry},
+		"ig_open_ret": {ID: 4, Name: "ig_open_ret", AttachPoint: "do_sys_openat2", Direction: DirectionExit},
+		"ig_tc":       {ID: 5, Name: "ig_tc", AttachPoint: "egress/drop", Direction: DirectionEgress},
+	}
+	require.Equal(t, expected, GetProgramsInfo(spec))
+
+	require.Equal(t, []string{"ig_execve_e", "ig_execve_x", "ig_open"}, GetMapProducers(spec, "events"))
+	require.Equal(t, []string{"ig_tc"}, GetMapProducers(spec, "other"))
+	require.Empty(t, GetMapProducers(spec, "unknown"))
+}
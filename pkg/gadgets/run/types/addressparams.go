@@ -0,0 +1,120 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// lpmPrefixLenSize is the size of the prefix length starting the keys of LPM
+// trie maps, struct bpf_lpm_trie_key
+const lpmPrefixLenSize = 4
+
+// validateAddressParams checks the variables backing the ipv4, ipv6 and cidr
+// params. The runtime writes addresses in network byte order into a u32 for
+// IPv4 and into a 16-byte array for IPv6. The prefix length of cidr params goes
+// into the <variable>_prefixlen variable and, if lpmMap is set, the network
+// into that LPM trie map.
+func validateAddressParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		p := m.EBPFParams[varName]
+		if !p.IsAddress() {
+			if p.LPMMap != "" {
+				result = multierror.Append(result, fmt.Errorf("param %q: lpmMap is only supported by cidr params", varName))
+			}
+			continue
+		}
+		btfVar, err := idx.varByName(varName)
+		if err != nil {
+			// Already reported by checkParamVar
+			continue
+		}
+		if err := validateAddressParam(idx, varName, &p, btfVar); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func validateAddressParam(idx *btfIndex, varName string, p *metadatav1.EBPFParam, btfVar *btf.Var) error {
+	size, err := btf.Sizeof(btfVar.Type)
+	if err != nil {
+		return fmt.Errorf("param %q: getting size of variable: %w", varName, err)
+	}
+
+	switch {
+	case p.TypeHint == params.TypeIPv4 && size != net.IPv4len:
+		return fmt.Errorf("param %q: ipv4 params need a variable of %d bytes like __u32, got %d", varName, net.IPv4len, size)
+	case p.TypeHint == params.TypeIPv6 && size != net.IPv6len:
+		return fmt.Errorf("param %q: ipv6 params need a variable of %d bytes like __u8[16], got %d", varName, net.IPv6len, size)
+	case p.TypeHint == params.TypeCIDR && size != net.IPv4len && size != net.IPv6len:
+		return fmt.Errorf("param %q: cidr params need a variable of %d bytes for IPv4 or %d for IPv6, got %d",
+			varName, net.IPv4len, net.IPv6len, size)
+	case p.TypeHint != params.TypeCIDR:
+		if p.LPMMap != "" {
+			return fmt.Errorf("param %q: lpmMap is only supported by cidr params", varName)
+		}
+		return nil
+	}
+
+	var result error
+
+	prefixLenVar := varName + gadgets.PrefixLenSuffix
+	if err := checkParamVar(idx, prefixLenVar); err != nil {
+		result = multierror.Append(result, err)
+	} else if prefixLen, _ := idx.varByName(prefixLenVar); !isPrefixLenType(prefixLen.Type) {
+		result = multierror.Append(result, fmt.Errorf("param %q: prefix length: %q must be an unsigned integer",
+			varName, prefixLenVar))
+	}
+
+	if p.LPMMap != "" {
+		if err := validateLPMMap(idx.spec, p.LPMMap, size); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %w", varName, err))
+		}
+	}
+
+	return result
+}
+
+func isPrefixLenType(typ btf.Type) bool {
+	intType, ok := btfhelpers.GetUnderlyingType(typ).(*btf.Int)
+	return ok && intType.Encoding == btf.Unsigned
+}
+
+func validateLPMMap(spec *ebpf.CollectionSpec, name string, addrSize int) error {
+	lpmMap, ok := spec.Maps[name]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", name)
+	}
+	if lpmMap.Type != ebpf.LPMTrie {
+		return fmt.Errorf("map %q has type %s, expected %s", name, lpmMap.Type, ebpf.LPMTrie)
+	}
+	if expected := lpmPrefixLenSize + addrSize; lpmMap.KeySize != uint32(expected) {
+		return fmt.Errorf("map %q has keys of %d bytes, expected %d (prefix length and address)",
+			name, lpmMap.KeySize, expected)
+	}
+	return nil
+}
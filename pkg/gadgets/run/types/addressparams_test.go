@@ -0,0 +1,181 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func addressParamsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	addr6 := &btf.Array{Type: u8Type, Index: u32Type, Nelems: 16}
+	spec := newTestBTF(t,
+		constVar("saddr", u32Type),
+		constVar("saddr6", addr6),
+		constVar("port", &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}),
+		constVar("net", u32Type),
+		constVar("net_prefixlen", u8Type),
+		constVar("net6", addr6),
+		constVar("net6_prefixlen", u32Type),
+		constVar("signed", u32Type),
+		constVar("signed_prefixlen", s32Type),
+		constVar("nolen", u32Type),
+	)
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"nets":   {Name: "nets", Type: ebpf.LPMTrie, KeySize: 8, ValueSize: 1, MaxEntries: 16},
+			"nets6":  {Name: "nets6", Type: ebpf.LPMTrie, KeySize: 20, ValueSize: 1, MaxEntries: 16},
+			"hashed": {Name: "hashed", Type: ebpf.Hash, KeySize: 8, ValueSize: 1, MaxEntries: 16},
+		},
+		Types: spec,
+	}
+}
+
+func TestValidateAddressParams(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		varName           string
+		param             metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	addressParam := func(typeHint params.TypeHint, lpmMap string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{ParamDesc: params.ParamDesc{TypeHint: typeHint}, LPMMap: lpmMap}
+	}
+
+	tests := map[string]testCase{
+		"ipv4": {
+			varName: "saddr",
+			param:   addressParam(params.TypeIPv4, ""),
+		},
+		"ipv6": {
+			varName: "saddr6",
+			param:   addressParam(params.TypeIPv6, ""),
+		},
+		"ipv4_wrong_size": {
+			varName:           "port",
+			param:             addressParam(params.TypeIPv4, ""),
+			expectedErrString: "param \"port\": ipv4 params need a variable of 4 bytes like __u32, got 2",
+		},
+		"ipv6_wrong_size": {
+			varName:           "saddr",
+			param:             addressParam(params.TypeIPv6, ""),
+			expectedErrString: "param \"saddr\": ipv6 params need a variable of 16 bytes like __u8[16], got 4",
+		},
+		"cidr_v4": {
+			varName: "net",
+			param:   addressParam(params.TypeCIDR, ""),
+		},
+		"cidr_v6": {
+			varName: "net6",
+			param:   addressParam(params.TypeCIDR, ""),
+		},
+		"cidr_wrong_size": {
+			varName:           "port",
+			param:             addressParam(params.TypeCIDR, ""),
+			expectedErrString: "cidr params need a variable of 4 bytes for IPv4 or 16 for IPv6, got 2",
+		},
+		"cidr_without_prefix_length": {
+			varName:           "nolen",
+			param:             addressParam(params.TypeCIDR, ""),
+			expectedErrString: "variable \"nolen_prefixlen\" not found",
+		},
+		"cidr_signed_prefix_length": {
+			varName:           "signed",
+			param:             addressParam(params.TypeCIDR, ""),
+			expectedErrString: "\"signed_prefixlen\" must be an unsigned integer",
+		},
+		"cidr_lpm_map": {
+			varName: "net",
+			param:   addressParam(params.TypeCIDR, "nets"),
+		},
+		"cidr_v6_lpm_map": {
+			varName: "net6",
+			param:   addressParam(params.TypeCIDR, "nets6"),
+		},
+		"cidr_lpm_map_wrong_key_size": {
+			varName:           "net6",
+			param:             addressParam(params.TypeCIDR, "nets"),
+			expectedErrString: "map \"nets\" has keys of 8 bytes, expected 20 (prefix length and address)",
+		},
+		"cidr_lpm_map_wrong_type": {
+			varName:           "net",
+			param:             addressParam(params.TypeCIDR, "hashed"),
+			expectedErrString: "map \"hashed\" has type Hash, expected LPMTrie",
+		},
+		"cidr_lpm_map_not_found": {
+			varName:           "net",
+			param:             addressParam(params.TypeCIDR, "networks"),
+			expectedErrString: "map \"networks\" not found in eBPF object",
+		},
+		"lpm_map_on_ipv4": {
+			varName:           "saddr",
+			param:             addressParam(params.TypeIPv4, "nets"),
+			expectedErrString: "param \"saddr\": lpmMap is only supported by cidr params",
+		},
+		"lpm_map_on_integer": {
+			varName:           "port",
+			param:             addressParam(params.TypeUint16, "nets"),
+			expectedErrString: "param \"port\": lpmMap is only supported by cidr params",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{test.varName: test.param},
+			}
+			err := validateAddressParams(m, newBTFIndex(addressParamsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateAddressParamDefault(t *testing.T) {
+	t.Parallel()
+
+	spec := addressParamsSpec(t)
+	m := &metadatav1.GadgetMetadata{
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"saddr": {ParamDesc: params.ParamDesc{Key: "saddr", TypeHint: params.TypeIPv4, DefaultValue: "10.0.0.1"}},
+			"net":   {ParamDesc: params.ParamDesc{Key: "net", TypeHint: params.TypeCIDR, DefaultValue: "10.0.0.0/8"}},
+		},
+	}
+	require.NoError(t, validateEbpfParams(m, newBTFIndex(spec)))
+	require.NoError(t, validateParamConstraints(m, newBTFIndex(spec)))
+
+	m.EBPFParams["saddr6"] = metadatav1.EBPFParam{
+		ParamDesc: params.ParamDesc{Key: "saddr6", TypeHint: params.TypeIPv6, DefaultValue: "10.0.0.1"},
+	}
+	err := validateParamConstraints(m, newBTFIndex(spec))
+	require.ErrorContains(t, err, "param \"saddr6\": default value: invalid value \"10.0.0.1\" as \"saddr6\": \"10.0.0.1\" is not a valid IPv6 address")
+}
@@ -0,0 +1,126 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// validateTracerAggregation checks the aggregation of a tracer: the window,
+// the field holding the number of events of each group, which can't collide
+// with the fields of the events, and the fields events are grouped by
+func validateTracerAggregation(m *metadatav1.GadgetMetadata, t metadatav1.Tracer, idx *btfIndex) error {
+	aggregation := t.Aggregation
+	if aggregation == nil {
+		return nil
+	}
+
+	var result error
+
+	if t.Discriminator != "" {
+		result = multierror.Append(result, errors.New("aggregation isn't supported by tracers sending several kinds of events"))
+	}
+
+	if d, err := time.ParseDuration(aggregation.Window); err != nil || d <= 0 {
+		result = multierror.Append(result, fmt.Errorf("aggregation: invalid window %q, expected a positive duration like \"1s\"", aggregation.Window))
+	}
+
+	if aggregation.CountField == "" {
+		result = multierror.Append(result, errors.New("aggregation: countField is required"))
+	} else if countFieldCollides(m.Structs[t.StructName], t.StructName, aggregation.CountField, idx) {
+		result = multierror.Append(result, fmt.Errorf("aggregation: countField %q collides with a field of struct %q",
+			aggregation.CountField, t.StructName))
+	}
+
+	if len(snapshotterKeyFields(m, t.StructName)) == 0 {
+		result = multierror.Append(result, fmt.Errorf("aggregation requires key fields: set the key attribute of the fields of struct %q events are grouped by",
+			t.StructName))
+	}
+
+	return result
+}
+
+func countFieldCollides(s metadatav1.Struct, structName, countField string, idx *btfIndex) bool {
+	for _, field := range s.Fields {
+		if field.Name == countField {
+			return true
+		}
+	}
+	btfStruct, err := idx.structByName(structName)
+	if err != nil {
+		return false
+	}
+	_, ok := findMember(btfStruct.Members, countField)
+	return ok
+}
+
+// validateAggregateFields checks the fields combining their values when
+// events are collapsed: they have to be integer fields, other than the keys,
+// of the events of tracers with aggregation
+func validateAggregateFields(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	aggregated := make(map[string]struct{})
+	for _, tracer := range m.Tracers {
+		if tracer.Aggregation != nil {
+			aggregated[tracer.StructName] = struct{}{}
+		}
+	}
+
+	for _, structName := range sortedKeys(m.Structs) {
+		for _, field := range m.Structs[structName].Fields {
+			if field.Attributes.Aggregate == "" {
+				continue
+			}
+			if err := validateAggregateField(structName, field, aggregated, idx); err != nil {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q: %w", field.Name, structName, err))
+			}
+		}
+	}
+
+	return result
+}
+
+func validateAggregateField(structName string, field metadatav1.Field, aggregated map[string]struct{}, idx *btfIndex) error {
+	switch field.Attributes.Aggregate {
+	case metadatav1.AggregateSum, metadatav1.AggregateMax, metadatav1.AggregateMin:
+	default:
+		return fmt.Errorf("invalid aggregate %q, expected %q, %q or %q", field.Attributes.Aggregate,
+			metadatav1.AggregateSum, metadatav1.AggregateMax, metadatav1.AggregateMin)
+	}
+	if _, ok := aggregated[structName]; !ok {
+		return errors.New("aggregate is only supported by the fields of tracers with aggregation")
+	}
+	if field.Attributes.Key {
+		return errors.New("key fields can't be aggregated")
+	}
+
+	btfStruct, err := idx.structByName(structName)
+	if err != nil {
+		// Reported when validating the tracer
+		return nil
+	}
+	member, ok := findMember(btfStruct.Members, field.Name)
+	if !ok || !isInteger(member) {
+		return errors.New("only integer fields can be aggregated")
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func aggregationIndex(t *testing.T) *btfIndex {
+	t.Helper()
+
+	event := &btf.Struct{Name: "open_event", Size: 28, Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "ret", Type: s32Type, Offset: 32},
+		{Name: "fname", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 16}, Offset: 64},
+		{Name: "latency", Type: u32Type, Offset: 192},
+	}}
+
+	return newBTFIndex(newTestSpec(t, event))
+}
+
+func TestValidateTracerAggregation(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		aggregation       *metadatav1.Aggregation
+		discriminator     string
+		fields            []metadatav1.Field
+		expectedErrString string
+	}
+
+	keyFields := []metadatav1.Field{
+		{Name: "pid", Attributes: metadatav1.FieldAttributes{Key: true}},
+		{Name: "fname", Attributes: metadatav1.FieldAttributes{Key: true}},
+		{Name: "latency", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateMax}},
+	}
+
+	tests := map[string]testCase{
+		"valid": {
+			aggregation: &metadatav1.Aggregation{Window: "1s", CountField: "count"},
+			fields:      keyFields,
+		},
+		"no_aggregation": {
+			fields: []metadatav1.Field{{Name: "pid"}},
+		},
+		"invalid_window": {
+			aggregation:       &metadatav1.Aggregation{Window: "often", CountField: "count"},
+			fields:            keyFields,
+			expectedErrString: "aggregation: invalid window \"often\"",
+		},
+		"negative_window": {
+			aggregation:       &metadatav1.Aggregation{Window: "-1s", CountField: "count"},
+			fields:            keyFields,
+			expectedErrString: "aggregation: invalid window \"-1s\"",
+		},
+		"missing_count_field": {
+			aggregation:       &metadatav1.Aggregation{Window: "1s"},
+			fields:            keyFields,
+			expectedErrString: "aggregation: countField is required",
+		},
+		"count_field_collides": {
+			aggregation:       &metadatav1.Aggregation{Window: "1s", CountField: "latency"},
+			fields:            keyFields,
+			expectedErrString: "aggregation: countField \"latency\" collides with a field of struct \"open_event\"",
+		},
+		"count_field_collides_with_member": {
+			aggregation:       &metadatav1.Aggregation{Window: "1s", CountField: "ret"},
+			fields:            keyFields,
+			expectedErrString: "aggregation: countField \"ret\" collides with a field of struct \"open_event\"",
+		},
+		"no_key_fields": {
+			aggregation:       &metadatav1.Aggregation{Window: "1s", CountField: "count"},
+			fields:            []metadatav1.Field{{Name: "pid"}},
+			expectedErrString: "aggregation requires key fields",
+		},
+		"discriminator": {
+			aggregation:       &metadatav1.Aggregation{Window: "1s", CountField: "count"},
+			discriminator:     "kind",
+			fields:            keyFields,
+			expectedErrString: "aggregation isn't supported by tracers sending several kinds of events",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			tracer := metadatav1.Tracer{
+				MapName:       "events",
+				StructName:    "open_event",
+				Discriminator: test.discriminator,
+				Aggregation:   test.aggregation,
+			}
+			m := &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{"open": tracer},
+				Structs: map[string]metadatav1.Struct{"open_event": {Fields: test.fields}},
+			}
+			err := validateTracerAggregation(m, tracer, aggregationIndex(t))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateAggregateFields(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		aggregation       *metadatav1.Aggregation
+		field             metadatav1.Field
+		expectedErrString string
+	}
+
+	aggregation := &metadatav1.Aggregation{Window: "1s", CountField: "count"}
+
+	tests := map[string]testCase{
+		"sum": {
+			aggregation: aggregation,
+			field:       metadatav1.Field{Name: "latency", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateSum}},
+		},
+		"invalid_aggregate": {
+			aggregation:       aggregation,
+			field:             metadatav1.Field{Name: "latency", Attributes: metadatav1.FieldAttributes{Aggregate: "avg"}},
+			expectedErrString: "field \"latency\" of struct \"open_event\": invalid aggregate \"avg\", expected \"sum\", \"max\" or \"min\"",
+		},
+		"without_aggregation": {
+			field:             metadatav1.Field{Name: "latency", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateSum}},
+			expectedErrString: "aggregate is only supported by the fields of tracers with aggregation",
+		},
+		"key_field": {
+			aggregation: aggregation,
+			field: metadatav1.Field{Name: "pid", Attributes: metadatav1.FieldAttributes{
+				Key:       true,
+				Aggregate: metadatav1.AggregateMin,
+			}},
+			expectedErrString: "key fields can't be aggregated",
+		},
+		"not_integer": {
+			aggregation:       aggregation,
+			field:             metadatav1.Field{Name: "fname", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateMax}},
+			expectedErrString: "only integer fields can be aggregated",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"open": {MapName: "events", StructName: "open_event", Aggregation: test.aggregation},
+				},
+				Structs: map[string]metadatav1.Struct{"open_event": {Fields: []metadatav1.Field{test.field}}},
+			}
+			err := validateAggregateFields(m, aggregationIndex(t))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
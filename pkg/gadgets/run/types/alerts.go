@@ -0,0 +1,146 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/expr"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func validateAlerts(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Alerts) {
+		if err := validateAlert(m, idx, m.Alerts[name]); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating alert %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+// validateAlert type checks the condition and the message of an alert. They
+// can only reference fields of the eBPF struct of its data source that can be
+// used in expressions, like computed fields.
+func validateAlert(m *metadatav1.GadgetMetadata, idx *btfIndex, alert metadatav1.Alert) (result error) {
+	if alert.Severity.Level() == 0 {
+		result = multierror.Append(result, fmt.Errorf("invalid severity %q, expected %q, %q or %q", alert.Severity,
+			metadatav1.AlertSeverityInfo, metadatav1.AlertSeverityWarning, metadatav1.AlertSeverityCritical))
+	}
+
+	if alert.Condition == "" {
+		result = multierror.Append(result, errors.New("condition is required"))
+	}
+
+	if alert.DataSource == "" {
+		return multierror.Append(result, errors.New("dataSource is required"))
+	}
+	var structName string
+	if t, ok := m.Tracers[alert.DataSource]; ok {
+		structName = t.StructName
+	} else if s, ok := m.Snapshotters[alert.DataSource]; ok {
+		structName = s.StructName
+	} else {
+		return multierror.Append(result, fmt.Errorf("dataSource %q not found, expected a tracer or snapshotter", alert.DataSource))
+	}
+	btfStruct, err := idx.structByName(structName)
+	if err != nil {
+		return multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", structName, err))
+	}
+
+	if alert.Condition != "" {
+		if err := validateAlertCondition(alert.Condition, btfStruct); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	for _, field := range AlertMessageFields(alert) {
+		if _, err := alertFieldType(btfStruct, field); err != nil {
+			result = multierror.Append(result, fmt.Errorf("message: %w", err))
+		}
+	}
+
+	return
+}
+
+func validateAlertCondition(condition string, btfStruct *btf.Struct) error {
+	e, err := expr.Parse(condition)
+	if err != nil {
+		return fmt.Errorf("condition: %w", err)
+	}
+
+	var result error
+	types := make(map[string]expr.Type)
+	for _, field := range e.Fields() {
+		typ, err := alertFieldType(btfStruct, field)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("condition: %w", err))
+			continue
+		}
+		types[field] = typ
+	}
+	if result != nil {
+		return result
+	}
+
+	typ, err := e.Check(types)
+	if err != nil {
+		return fmt.Errorf("condition: checking expression %q: %w", condition, err)
+	}
+	if typ != expr.TypeBool {
+		return fmt.Errorf("condition: expression %q is a %s, expected a bool", condition, typ)
+	}
+	return nil
+}
+
+// alertFieldType returns the type in expressions of the field with the given
+// name of the struct
+func alertFieldType(btfStruct *btf.Struct, name string) (expr.Type, error) {
+	member, ok := findMember(btfStruct.Members, name)
+	if !ok {
+		return expr.TypeInvalid, fmt.Errorf("field %q not found in struct %q", name, btfStruct.Name)
+	}
+	typ := exprType(member)
+	if typ == expr.TypeInvalid {
+		return expr.TypeInvalid, fmt.Errorf("field %q can't be used in alerts", name)
+	}
+	return typ, nil
+}
+
+var alertPlaceholderRegex = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// AlertMessageFields returns the fields referenced by the message of the alert, in order
+func AlertMessageFields(a metadatav1.Alert) []string {
+	var fields []string
+	for _, match := range alertPlaceholderRegex.FindAllStringSubmatch(a.Message, -1) {
+		fields = append(fields, match[1])
+	}
+	return fields
+}
+
+// FormatAlertMessage returns the message of the alert with its placeholders replaced by the values of
+// the fields
+func FormatAlertMessage(a metadatav1.Alert, value func(field string) string) string {
+	return alertPlaceholderRegex.ReplaceAllStringFunc(a.Message, func(placeholder string) string {
+		return value(placeholder[1 : len(placeholder)-1])
+	})
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func alertsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	event := &btf.Struct{Name: "exec_event", Size: 40, Members: []btf.Member{
+		{Name: "uid", Type: u32Type},
+		{Name: "ret", Type: s32Type, Offset: 32},
+		{Name: "comm", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 16}, Offset: 64},
+		{Name: "args", Type: &btf.Pointer{Target: charType}, Offset: 256},
+	}}
+
+	return newTestSpec(t, event)
+}
+
+func TestValidateAlerts(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		alert             metadatav1.Alert
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"valid": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  `uid == 0 && comm == "sh"`,
+				Severity:   metadatav1.AlertSeverityWarning,
+				Message:    "{comm} run as root returned {ret}",
+			},
+		},
+		"no_message": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "ret < 0",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+		},
+		"invalid_severity": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "ret < 0",
+				Severity:   "high",
+			},
+			expectedErrString: "invalid severity \"high\", expected \"info\", \"warning\" or \"critical\"",
+		},
+		"missing_condition": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition is required",
+		},
+		"unknown_data_source": {
+			alert: metadatav1.Alert{
+				DataSource: "open",
+				Condition:  "ret < 0",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "dataSource \"open\" not found",
+		},
+		"syntax_error": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "ret <",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition: parsing expression \"ret <\"",
+		},
+		"type_mismatch": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  `uid == "root"`,
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition: checking expression",
+		},
+		"not_bool": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "uid + 1",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition: expression \"uid + 1\" is a number, expected a bool",
+		},
+		"unknown_field": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "gid == 0",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition: field \"gid\" not found in struct \"exec_event\"",
+		},
+		"unsupported_field": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "args == 0",
+				Severity:   metadatav1.AlertSeverityInfo,
+			},
+			expectedErrString: "condition: field \"args\" can't be used in alerts",
+		},
+		"unknown_message_field": {
+			alert: metadatav1.Alert{
+				DataSource: "exec",
+				Condition:  "ret < 0",
+				Severity:   metadatav1.AlertSeverityInfo,
+				Message:    "{comm} failed with {errno}",
+			},
+			expectedErrString: "message: field \"errno\" not found in struct \"exec_event\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"exec": {MapName: "events", StructName: "exec_event"},
+				},
+				Alerts: map[string]metadatav1.Alert{"alert": test.alert},
+			}
+			err := validateAlerts(m, newBTFIndex(alertsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// validateArrayParams checks the params backed by an array of integers, like
+// up to 4 ports to watch: they must be lists, the variable holding the number
+// of values must exist and be an integer, and the default value and examples
+// must fit in the array and satisfy the constraints of the param.
+func validateArrayParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		btfVar, err := idx.varByName(varName)
+		if err != nil {
+			// Already reported by checkParamVar
+			continue
+		}
+		elemType, nelems, ok := btfhelpers.IntArray(btfVar.Type)
+		if !ok {
+			continue
+		}
+
+		p := m.EBPFParams[varName]
+		if !p.List {
+			result = multierror.Append(result, fmt.Errorf("param %q: %q is an array, list must be set", varName, varName))
+			continue
+		}
+
+		lenVarName := varName + metadatav1.ArrayLenSuffix
+		if lenVar, err := idx.varByName(lenVarName); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: variable %q holding the number of values not found",
+				varName, lenVarName))
+		} else if typ, ok := btfhelpers.GetUnderlyingType(lenVar.Type).(*btf.Int); !ok || typ.Encoding == btf.Bool {
+			result = multierror.Append(result, fmt.Errorf("param %q: %q must be an integer, it's set to the number of values",
+				varName, lenVarName))
+		}
+
+		validator, err := ParamValidator(&p, elemType)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %w", varName, err))
+			continue
+		}
+		for _, v := range checkedValues(&p) {
+			if _, _, err := EncodeArray(v.value, elemType, int(nelems)); err != nil {
+				result = multierror.Append(result, fmt.Errorf("param %q: %s: %w", varName, v.what, p.MaskError(err, v.value)))
+				continue
+			}
+			if validator == nil || strings.TrimSpace(v.value) == "" {
+				continue
+			}
+			for _, elem := range strings.Split(v.value, ",") {
+				if err := validator(strings.TrimSpace(elem)); err != nil {
+					result = multierror.Append(result, fmt.Errorf("param %q: %s: %w", varName, v.what, p.MaskError(err, strings.TrimSpace(elem))))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// isArrayParam returns whether the param named varName is backed by an array
+// of integers
+func isArrayParam(idx *btfIndex, varName string) bool {
+	btfVar, err := idx.varByName(varName)
+	if err != nil {
+		return false
+	}
+	_, _, ok := btfhelpers.IntArray(btfVar.Type)
+	return ok
+}
+
+// EncodeArray returns the elements of value, a comma-separated list, as an array of n integers of
+// the given type in host byte order, zero-filled after them, and their number. It fails if there
+// are more than n of them.
+func EncodeArray(value string, elemType params.TypeHint, n int) ([]byte, int, error) {
+	size, ok := targetMapKeySizes[string(elemType)]
+	if !ok || string(elemType) == metadatav1.KeyTypePort {
+		return nil, 0, fmt.Errorf("arrays of %s aren't supported", elemType)
+	}
+
+	array := make([]byte, size*n)
+	if strings.TrimSpace(value) == "" {
+		return array, 0, nil
+	}
+
+	elems := strings.Split(value, ",")
+	if len(elems) > n {
+		return nil, 0, fmt.Errorf("at most %d values can be given, got %d", n, len(elems))
+	}
+	for i, elem := range elems {
+		if err := putInteger(array[i*size:(i+1)*size], strings.TrimSpace(elem), string(elemType)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return array, len(elems), nil
+}
+
+// putInteger parses elem as an integer of the given type, or as a port, and writes it into b, in
+// network byte order for ports and host byte order otherwise
+func putInteger(b []byte, elem, typ string) error {
+	switch {
+	case typ == metadatav1.KeyTypePort:
+		n, err := strconv.ParseUint(elem, 10, 16)
+		if err != nil {
+			return fmt.Errorf("%q isn't a valid port", elem)
+		}
+		binary.BigEndian.PutUint16(b, uint16(n))
+	case strings.HasPrefix(typ, "int"):
+		n, err := strconv.ParseInt(elem, 10, 8*len(b))
+		if err != nil {
+			return fmt.Errorf("%q isn't a valid %s", elem, typ)
+		}
+		putNativeUint(b, uint64(n))
+	default:
+		n, err := strconv.ParseUint(elem, 10, 8*len(b))
+		if err != nil {
+			return fmt.Errorf("%q isn't a valid %s", elem, typ)
+		}
+		putNativeUint(b, n)
+	}
+	return nil
+}
+
+// putNativeUint writes v into b in host byte order, truncated to the size of b
+func putNativeUint(b []byte, v uint64) {
+	switch len(b) {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		binary.NativeEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.NativeEndian.PutUint32(b, uint32(v))
+	case 8:
+		binary.NativeEndian.PutUint64(b, v)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// arrayParamsSpec returns an object with const volatile __u16 ports[4] and
+// the given variables
+func arrayParamsSpec(t *testing.T, vars map[string]btf.Type) *ebpf.CollectionSpec {
+	t.Helper()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	types := []btf.Type{
+		&btf.Var{
+			Name: "ports",
+			Type: &btf.Array{
+				Type:   &btf.Const{Type: &btf.Volatile{Type: &btf.Int{Name: "__u16", Size: 2}}},
+				Index:  u32,
+				Nelems: 4,
+			},
+			Linkage: btf.GlobalVar,
+		},
+	}
+	for _, name := range sortedKeys(vars) {
+		types = append(types, constVar(name, vars[name]))
+	}
+	return newTestSpec(t, types...)
+}
+
+func TestValidateArrayParams(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	type testCase struct {
+		param             metadatav1.EBPFParam
+		vars              map[string]btf.Type
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"full": {
+			param: metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "22,80,443,8080"}, List: true},
+			vars:  map[string]btf.Type{"ports_len": u32},
+		},
+		"partially_full": {
+			param: metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "80, 443"}, List: true},
+			vars:  map[string]btf.Type{"ports_len": u32},
+		},
+		"no_default": {
+			param: metadatav1.EBPFParam{List: true},
+			vars:  map[string]btf.Type{"ports_len": u32},
+		},
+		"over_capacity": {
+			param:             metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "22,80,443,8080,8443"}, List: true},
+			vars:              map[string]btf.Type{"ports_len": u32},
+			expectedErrString: "param \"ports\": default value: at most 4 values can be given, got 5",
+		},
+		"out_of_range": {
+			param:             metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "80,65536"}, List: true},
+			vars:              map[string]btf.Type{"ports_len": u32},
+			expectedErrString: "param \"ports\": default value:",
+		},
+		"below_min": {
+			param:             metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "80,22"}, Min: "80", List: true},
+			vars:              map[string]btf.Type{"ports_len": u32},
+			expectedErrString: "param \"ports\": default value:",
+		},
+		"example_over_capacity": {
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "80"},
+				List:      true,
+				Examples:  []string{"80,443", "1,2,3,4,5"},
+			},
+			vars:              map[string]btf.Type{"ports_len": u32},
+			expectedErrString: "param \"ports\": example: at most 4 values can be given, got 5",
+		},
+		"not_list": {
+			param:             metadatav1.EBPFParam{},
+			vars:              map[string]btf.Type{"ports_len": u32},
+			expectedErrString: "param \"ports\": \"ports\" is an array, list must be set",
+		},
+		"missing_len": {
+			param:             metadatav1.EBPFParam{List: true},
+			expectedErrString: "param \"ports\": variable \"ports_len\" holding the number of values not found",
+		},
+		"bool_len": {
+			param:             metadatav1.EBPFParam{List: true},
+			vars:              map[string]btf.Type{"ports_len": &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}},
+			expectedErrString: "param \"ports\": \"ports_len\" must be an integer",
+		},
+		"struct_len": {
+			param:             metadatav1.EBPFParam{List: true},
+			vars:              map[string]btf.Type{"ports_len": &btf.Struct{Name: "len", Size: 4}},
+			expectedErrString: "param \"ports\": \"ports_len\" must be an integer",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{"ports": test.param},
+			}
+			err := validateArrayParams(m, newBTFIndex(arrayParamsSpec(t, test.vars)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateTargetMapListOnArray(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	idx := newBTFIndex(arrayParamsSpec(t, map[string]btf.Type{"ports_len": u32, "count": u32}))
+
+	// Arrays take lists without a target map
+	m := &metadatav1.GadgetMetadata{
+		EBPFParams: map[string]metadatav1.EBPFParam{"ports": {List: true}},
+	}
+	require.NoError(t, validateTargetMapParams(m, idx))
+
+	m = &metadatav1.GadgetMetadata{
+		EBPFParams: map[string]metadatav1.EBPFParam{"count": {List: true}},
+	}
+	require.ErrorContains(t, validateTargetMapParams(m, idx), "keyType and list are only supported by params with a targetMap")
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+)
+
+// markerPrefix is the common prefix of the variables generated by GADGET_
+// macros
+const markerPrefix = "gadget_"
+
+// btfIndex holds the gadget markers, structs and variables of an eBPF object,
+// collected in a single pass over its BTF. Populate and Validate use it
+// instead of iterating over all the types, which is slow for big objects, for
+// each kind of marker.
+type btfIndex struct {
+	spec *ebpf.CollectionSpec
+
+	// markerVars are the variables whose name starts with markerPrefix, in
+	// the order they appear in the BTF
+	markerVars []*btf.Var
+
+	// markers are the result of parsing markerVars
+	markers *Markers
+
+	structs map[string][]*btf.Struct
+	vars    map[string][]*btf.Var
+}
+
+func newBTFIndex(spec *ebpf.CollectionSpec) *btfIndex {
+	idx := &btfIndex{
+		spec:    spec,
+		structs: make(map[string][]*btf.Struct),
+		vars:    make(map[string][]*btf.Var),
+	}
+
+	if spec.Types != nil {
+		it := spec.Types.Iterate()
+		for it.Next() {
+			switch t := it.Type.(type) {
+			case *btf.Struct:
+				if t.Name != "" {
+					idx.structs[t.Name] = append(idx.structs[t.Name], t)
+				}
+			case *btf.Var:
+				idx.vars[t.Name] = append(idx.vars[t.Name], t)
+				if strings.HasPrefix(t.Name, markerPrefix) {
+					idx.markerVars = append(idx.markerVars, t)
+				}
+			}
+		}
+	}
+
+	idx.markers = scanMarkers(idx.markerVars)
+
+	return idx
+}
+
+// identsByPrefix returns the strings generated by GADGET_ macros with the
+// given prefix
+func (idx *btfIndex) identsByPrefix(prefix string) ([]string, error) {
+	var resultNames []string
+	var resultError error
+
+	for _, btfVar := range idx.markerVars {
+		if !strings.HasPrefix(btfVar.Name, prefix) {
+			continue
+		}
+		if isParamMarker(btfVar.Name) && !isParamMarker(prefix) {
+			continue
+		}
+		if err := checkMarkerVar(btfVar); err != nil {
+			resultError = multierror.Append(resultError, err)
+			continue
+		}
+
+		resultNames = append(resultNames, strings.TrimPrefix(btfVar.Name, prefix))
+	}
+
+	return resultNames, resultError
+}
+
+// structByName returns the struct with the given name, like TypeByName, but
+// failing if other types use the same name too
+func (idx *btfIndex) structByName(name string) (*btf.Struct, error) {
+	if err := idx.checkUnambiguous(name); err != nil {
+		return nil, err
+	}
+	if structs := idx.structs[name]; len(structs) == 1 {
+		return structs[0], nil
+	}
+
+	// Let TypeByName report the error
+	var btfStruct *btf.Struct
+	err := idx.typeByName(name, &btfStruct)
+	return btfStruct, err
+}
+
+// varByName returns the variable with the given name, like TypeByName, but
+// failing if other types use the same name too
+func (idx *btfIndex) varByName(name string) (*btf.Var, error) {
+	if err := idx.checkUnambiguous(name); err != nil {
+		return nil, err
+	}
+	if vars := idx.vars[name]; len(vars) == 1 {
+		return vars[0], nil
+	}
+
+	// Let TypeByName report the error
+	var btfVar *btf.Var
+	err := idx.typeByName(name, &btfVar)
+	return btfVar, err
+}
+
+func (idx *btfIndex) typeByName(name string, typ interface{}) error {
+	if idx.spec.Types == nil {
+		return fmt.Errorf("type name %s: %w", name, btf.ErrNotFound)
+	}
+	return idx.spec.Types.TypeByName(name, typ)
+}
+
+// checkUnambiguous returns an error if several types use the given name, as
+// TypeByName would pick one of them depending on the order of the BTF, which
+// changes across compilers. Forward declarations and typedefs of a type with
+// the same name, like "typedef struct event event;", don't count.
+func (idx *btfIndex) checkUnambiguous(name string) error {
+	if idx.spec.Types == nil {
+		return nil
+	}
+	types, err := idx.spec.Types.AnyTypesByName(name)
+	if err != nil {
+		// Not found, reported by the lookup
+		return nil
+	}
+
+	var candidates []string
+	for _, typ := range types {
+		switch t := typ.(type) {
+		case *btf.Fwd:
+			continue
+		case *btf.Typedef:
+			if btfhelpers.GetUnderlyingType(t).TypeName() == name {
+				continue
+			}
+		}
+		candidates = append(candidates, fmt.Sprintf("%s %s", typeKind(typ), name))
+	}
+	if len(candidates) <= 1 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: name %q is used by %d types (%s), please rename them",
+		btf.ErrMultipleMatches, name, len(candidates), strings.Join(candidates, ", "))
+}
+
+// typeKind returns the C keyword or a short description of the kind of typ
+func typeKind(typ btf.Type) string {
+	switch t := typ.(type) {
+	case *btf.Struct:
+		return "struct"
+	case *btf.Union:
+		return "union"
+	case *btf.Enum:
+		return "enum"
+	case *btf.Typedef:
+		return "typedef"
+	case *btf.Var:
+		return "variable"
+	case *btf.Func:
+		return "function"
+	case *btf.Int:
+		return "int"
+	case *btf.Float:
+		return "float"
+	case *btf.Datasec:
+		return "section"
+	default:
+		return strings.ToLower(strings.TrimPrefix(fmt.Sprintf("%T", t), "*btf."))
+	}
+}
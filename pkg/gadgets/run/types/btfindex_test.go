@@ -0,0 +1,235 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// markerCategories are the prefixes of the markers gadgets declare
+var markerCategories = []string{
+	tracerInfoPrefix,
+	topperInfoPrefix,
+	paramPrefix,
+	snapshottersPrefix,
+}
+
+// indexSpec returns a spec with the given number of filler structs besides a
+// few gadget markers, structs and variables
+func indexSpec(tb testing.TB, fillers int) *ebpf.CollectionSpec {
+	tb.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	types := []btf.Type{
+		&btf.Var{Name: "gadget_tracer_events___events___event", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_snapshotter_procs___proc___iter", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_limit", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_default_limit", Type: &btf.Const{Type: u32}, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_bad", Type: u32, Linkage: btf.GlobalVar},
+		constVar("limit", u32),
+		&btf.Struct{Name: "event", Size: 4, Members: []btf.Member{{Name: "pid", Type: u32}}},
+		&btf.Struct{Name: "dup", Size: 4, Members: []btf.Member{{Name: "a", Type: u32}}},
+		&btf.Struct{Name: "dup", Size: 4, Members: []btf.Member{{Name: "b", Type: u32}}},
+	}
+	for i := 0; i < fillers; i++ {
+		types = append(types, &btf.Struct{
+			Name:    fmt.Sprintf("filler_%d", i),
+			Size:    4,
+			Members: []btf.Member{{Name: "x", Type: u32}},
+		})
+	}
+
+	return newTestSpec(tb, types...)
+}
+
+func TestBTFIndex(t *testing.T) {
+	t.Parallel()
+
+	spec := indexSpec(t, 10)
+	idx := newBTFIndex(spec)
+
+	for _, prefix := range append(markerCategories, "gadget_param_default_", "gadget_nothing_") {
+		expected, expectedErr := GetGadgetIdentByPrefix(spec, prefix)
+		idents, err := idx.identsByPrefix(prefix)
+		require.Equal(t, expected, idents, prefix)
+		require.Equal(t, expectedErr, err, prefix)
+	}
+
+	idents, err := idx.identsByPrefix(paramPrefix)
+	require.Equal(t, []string{"limit"}, idents)
+	require.ErrorContains(t, err, `"gadget_param_bad" is not a pointer`)
+
+	idents, err = idx.identsByPrefix(tracerInfoPrefix)
+	require.NoError(t, err)
+	require.Equal(t, []string{"events___events___event"}, idents)
+
+	btfStruct, err := idx.structByName("event")
+	require.NoError(t, err)
+	require.Equal(t, "event", btfStruct.Name)
+
+	_, err = idx.structByName("dup")
+	require.ErrorIs(t, err, btf.ErrMultipleMatches)
+	_, err = idx.structByName("limit")
+	require.ErrorIs(t, err, btf.ErrNotFound)
+	_, err = idx.structByName("missing")
+	require.ErrorIs(t, err, btf.ErrNotFound)
+
+	btfVar, err := idx.varByName("limit")
+	require.NoError(t, err)
+	require.Equal(t, "limit", btfVar.Name)
+
+	_, err = idx.varByName("event")
+	require.ErrorIs(t, err, btf.ErrNotFound)
+}
+
+const benchmarkFillers = 100000
+
+// BenchmarkScanByPrefix looks the markers up the way Populate did before
+// btfIndex: iterating over all the types for each kind of marker
+func BenchmarkScanByPrefix(b *testing.B) {
+	spec := indexSpec(b, benchmarkFillers)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, prefix := range markerCategories {
+			GetGadgetIdentByPrefix(spec, prefix)
+		}
+		var btfStruct *btf.Struct
+		spec.Types.TypeByName("event", &btfStruct)
+	}
+}
+
+func BenchmarkBTFIndex(b *testing.B) {
+	spec := indexSpec(b, benchmarkFillers)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		idx := newBTFIndex(spec)
+		for _, prefix := range markerCategories {
+			idx.identsByPrefix(prefix)
+		}
+		idx.structByName("event")
+	}
+}
+
+// ambiguousSpec returns a spec where the names used by a snapshotter, its
+// struct and a param are shared by several types
+func ambiguousSpec(tb testing.TB) *ebpf.CollectionSpec {
+	tb.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	event := &btf.Struct{Name: "event", Size: 4, Members: []btf.Member{{Name: "pid", Type: u32}}}
+	proc := &btf.Struct{Name: "proc", Size: 4, Members: []btf.Member{{Name: "pid", Type: u32}}}
+
+	types := []btf.Type{
+		&btf.Var{Name: "gadget_snapshotter_procs___event___iter", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_limit", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		constVar("limit", u32),
+		// Shadows the param
+		&btf.Struct{Name: "limit", Size: 4, Members: []btf.Member{{Name: "max", Type: u32}}},
+		event,
+		// Shadows the struct
+		&btf.Typedef{Name: "event", Type: u32},
+		// Don't make the name ambiguous
+		proc,
+		&btf.Fwd{Name: "proc", Kind: btf.FwdStruct},
+		&btf.Typedef{Name: "proc", Type: proc},
+	}
+
+	spec := newTestBTF(tb, types...)
+
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"iter": {Name: "iter", Type: ebpf.Tracing, SectionName: "iter/task"},
+		},
+		Types: spec,
+	}
+}
+
+func TestCheckUnambiguous(t *testing.T) {
+	t.Parallel()
+
+	idx := newBTFIndex(ambiguousSpec(t))
+
+	type testCase struct {
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"event": {
+			expectedErrString: `name "event" is used by 2 types (struct event, typedef event), please rename them`,
+		},
+		"limit": {
+			expectedErrString: `name "limit" is used by 2 types (variable limit, struct limit), please rename them`,
+		},
+		"proc":    {},
+		"__u32":   {},
+		"missing": {},
+	}
+
+	for name, test := range tests {
+		name := name
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := idx.checkUnambiguous(name)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, btf.ErrMultipleMatches)
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestAmbiguousTypeNames(t *testing.T) {
+	t.Parallel()
+
+	spec := ambiguousSpec(t)
+
+	err := populateEbpfParams(&metadatav1.GadgetMetadata{}, newBTFIndex(spec), nil)
+	require.ErrorContains(t, err, `looking variable "limit" up: multiple matching types: name "limit"`)
+
+	err = populateSnapshotters(&metadatav1.GadgetMetadata{}, newBTFIndex(spec), populateOptions{}, nil)
+	require.ErrorContains(t, err, `finding struct "event" in eBPF object: multiple matching types: name "event"`)
+
+	m := &metadatav1.GadgetMetadata{
+		Name: "ambiguous",
+		Structs: map[string]metadatav1.Struct{
+			"event": {Fields: []metadatav1.Field{{Name: "pid"}}},
+			"proc":  {Fields: []metadatav1.Field{{Name: "pid"}}},
+		},
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"limit": {ParamDesc: params.ParamDesc{Key: "limit"}},
+		},
+	}
+	err = Validate(m, spec)
+	require.ErrorContains(t, err, `looking for struct "event" in eBPF object: multiple matching types: name "event" is used by 2 types (struct event, typedef event)`)
+	require.ErrorContains(t, err, `variable "limit" not found in eBPF object: multiple matching types: name "limit"`)
+	require.NotContains(t, err.Error(), `"proc"`)
+}
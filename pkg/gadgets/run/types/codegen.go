@@ -0,0 +1,353 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+const typesImportPath = "github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+
+// goField is a field of the generated struct
+type goField struct {
+	name   string
+	typ    string
+	tag    string
+	decode []string
+}
+
+// GenerateGoStruct returns the source of a Go file of package pkgName defining
+// a struct with the fields of the events of type s and an UnmarshalBinary
+// method decoding them from the layout of s. The column tags of the fields are
+// derived from their attributes in m, which can be nil. Endpoints are decoded
+// as Endpoint. The output only depends on its inputs, so it can be committed
+// and regenerated with go generate.
+func GenerateGoStruct(m *metadatav1.GadgetMetadata, s *btf.Struct, pkgName string) ([]byte, error) {
+	fieldsMetadata := make(map[string]metadatav1.Field)
+	if m != nil {
+		for _, field := range m.Structs[s.Name].Fields {
+			fieldsMetadata[field.Name] = field
+		}
+	}
+
+	members, err := flattenMembers(s.Members, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("struct %q: %w", s.Name, err)
+	}
+
+	imports := map[string]struct{}{"fmt": {}}
+	goNames := make(map[string]string, len(members))
+	found := make(map[string]struct{}, len(members))
+	var fields []goField
+
+	for _, member := range members {
+		found[member.Name] = struct{}{}
+
+		name := goIdentifier(member.Name)
+		if other, ok := goNames[name]; ok {
+			return nil, fmt.Errorf("struct %q: fields %q and %q have the same Go name %q", s.Name, other, member.Name, name)
+		}
+		goNames[name] = member.Name
+
+		field, err := memberGoField(member, name, imports)
+		if err != nil {
+			return nil, fmt.Errorf("struct %q: field %q: %w", s.Name, member.Name, err)
+		}
+		if field == nil {
+			continue
+		}
+		field.tag = columnTag(member.Name, fieldsMetadata[member.Name].Attributes)
+		fields = append(fields, *field)
+	}
+
+	for name := range fieldsMetadata {
+		if _, ok := found[name]; !ok {
+			return nil, fmt.Errorf("field %q not found in struct %q", name, s.Name)
+		}
+	}
+
+	sortedImports := make([]string, 0, len(imports))
+	for imp := range imports {
+		sortedImports = append(sortedImports, imp)
+	}
+	sort.Slice(sortedImports, func(i, j int) bool {
+		a, b := sortedImports[i], sortedImports[j]
+		if isStdImport(a) != isStdImport(b) {
+			return isStdImport(a)
+		}
+		return a < b
+	})
+
+	typeName := goIdentifier(s.Name)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated from the BTF and metadata of struct %s. DO NOT EDIT.\n\n", s.Name)
+	fmt.Fprintf(&buf, "package %s\n\n", pkgName)
+	buf.WriteString("import (\n")
+	for i, imp := range sortedImports {
+		// Separate the standard library from other packages
+		if i > 0 && !isStdImport(imp) && isStdImport(sortedImports[i-1]) {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "\t%q\n", imp)
+	}
+	buf.WriteString(")\n\n")
+
+	fmt.Fprintf(&buf, "// %s is an event of type struct %s\n", typeName, s.Name)
+	fmt.Fprintf(&buf, "type %s struct {\n", typeName)
+	for _, f := range fields {
+		fmt.Fprintf(&buf, "\t%s %s `%s`\n", f.name, f.typ, f.tag)
+	}
+	buf.WriteString("}\n\n")
+
+	buf.WriteString("// UnmarshalBinary decodes an event as written by the eBPF programs of the host\n")
+	fmt.Fprintf(&buf, "func (e *%s) UnmarshalBinary(b []byte) error {\n", typeName)
+	fmt.Fprintf(&buf, "\tif len(b) < %d {\n", s.Size)
+	fmt.Fprintf(&buf, "\t\treturn fmt.Errorf(\"buffer has %%d bytes, expected at least %d\", len(b))\n", s.Size)
+	buf.WriteString("\t}\n")
+	for _, f := range fields {
+		for _, line := range f.decode {
+			fmt.Fprintf(&buf, "\t%s\n", line)
+		}
+	}
+	buf.WriteString("\treturn nil\n}\n")
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return out, nil
+}
+
+// memberGoField returns the Go type of member and the statements decoding it,
+// or nil if it has no value of its own (e.g. void). The packages used by the
+// statements are added to imports.
+func memberGoField(member btf.Member, name string, imports map[string]struct{}) (*goField, error) {
+	f := &goField{name: name}
+	dst := "e." + name
+
+	if member.BitfieldSize > 0 {
+		signed := btfhelpers.IsSigned(member.Type)
+		start, end := btfhelpers.BitfieldBytes(member.Offset, member.BitfieldSize)
+		f.typ = btfhelpers.GetBitfieldType(member.BitfieldSize, signed).String()
+		imports["encoding/binary"] = struct{}{}
+		imports["github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"] = struct{}{}
+		read := fmt.Sprintf("btfhelpers.ReadBitfield(b[%d:%d], %d, %d, binary.NativeEndian, %t)",
+			start, end, member.Offset%8, member.BitfieldSize, signed)
+		if f.typ == "bool" {
+			f.decode = []string{fmt.Sprintf("%s = %s != 0", dst, read)}
+		} else {
+			f.decode = []string{fmt.Sprintf("%s = %s(%s)", dst, f.typ, read)}
+		}
+		return f, nil
+	}
+
+	if member.Offset%8 != 0 {
+		return nil, fmt.Errorf("offset %d isn't a multiple of 8 bits", member.Offset)
+	}
+	offset := member.Offset.Bytes()
+
+	if kind, ok := getEndpointKind(member.Type); ok {
+		st := btfhelpers.GetUnderlyingType(member.Type).(*btf.Struct)
+		return endpointGoField(f, offset, st, kind, imports)
+	}
+
+	if n := charArrayLen(member.Type); n > 0 {
+		end := offset + uint32(n)
+		imports["bytes"] = struct{}{}
+		f.typ = "string"
+		f.decode = []string{
+			fmt.Sprintf("%s = string(b[%d:%d])", dst, offset, end),
+			fmt.Sprintf("if i := bytes.IndexByte(b[%d:%d], 0); i >= 0 {", offset, end),
+			fmt.Sprintf("\t%s = string(b[%d:%d+i])", dst, offset, offset),
+			"}",
+		}
+		return f, nil
+	}
+
+	switch t := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if t.Encoding == btf.Bool {
+			f.typ = "bool"
+			f.decode = []string{fmt.Sprintf("%s = b[%d] != 0", dst, offset)}
+			return f, nil
+		}
+		return intGoField(f, offset, t.Size, t.Encoding == btf.Signed, imports)
+	case *btf.Enum:
+		return intGoField(f, offset, t.Size, t.Signed, imports)
+	case *btf.Float:
+		imports["encoding/binary"] = struct{}{}
+		imports["math"] = struct{}{}
+		switch t.Size {
+		case 4:
+			f.typ = "float32"
+			f.decode = []string{fmt.Sprintf("%s = math.Float32frombits(binary.NativeEndian.Uint32(b[%d:]))", dst, offset)}
+		case 8:
+			f.typ = "float64"
+			f.decode = []string{fmt.Sprintf("%s = math.Float64frombits(binary.NativeEndian.Uint64(b[%d:]))", dst, offset)}
+		default:
+			return nil, fmt.Errorf("unsupported float size %d", t.Size)
+		}
+		return f, nil
+	case *btf.Pointer:
+		return intGoField(f, offset, 8, false, imports)
+	case *btf.Void:
+		return nil, nil
+	}
+
+	// Arrays other than strings, unions, etc. are kept as raw bytes
+	size, err := btf.Sizeof(member.Type)
+	if err != nil {
+		return nil, fmt.Errorf("getting size: %w", err)
+	}
+	f.typ = fmt.Sprintf("[%d]byte", size)
+	f.decode = []string{fmt.Sprintf("copy(%s[:], b[%d:%d])", dst, offset, offset+uint32(size))}
+	return f, nil
+}
+
+func intGoField(f *goField, offset, size uint32, signed bool, imports map[string]struct{}) (*goField, error) {
+	dst := "e." + f.name
+
+	prefix := "u"
+	if signed {
+		prefix = ""
+	}
+
+	switch size {
+	case 1:
+		f.typ = prefix + "int8"
+		if signed {
+			f.decode = []string{fmt.Sprintf("%s = int8(b[%d])", dst, offset)}
+		} else {
+			f.decode = []string{fmt.Sprintf("%s = b[%d]", dst, offset)}
+		}
+		return f, nil
+	case 2, 4, 8:
+		bits := size * 8
+		f.typ = fmt.Sprintf("%sint%d", prefix, bits)
+		read := fmt.Sprintf("binary.NativeEndian.Uint%d(b[%d:])", bits, offset)
+		if signed {
+			read = fmt.Sprintf("%s(%s)", f.typ, read)
+		}
+		imports["encoding/binary"] = struct{}{}
+		f.decode = []string{fmt.Sprintf("%s = %s", dst, read)}
+		return f, nil
+	}
+	return nil, fmt.Errorf("unsupported integer size %d", size)
+}
+
+// endpointGoField decodes endpoints using the layout of st, as endpointDecoder
+// does
+func endpointGoField(f *goField, offset uint32, st *btf.Struct, kind endpointKind, imports map[string]struct{}) (*goField, error) {
+	offsets := make(map[string]uint32, len(st.Members))
+	for _, m := range st.Members {
+		offsets[m.Name] = offset + m.Offset.Bytes()
+	}
+
+	required := []string{"addr_raw", "version"}
+	if kind.name == "l4" {
+		required = append(required, "port", "proto")
+	}
+	for _, name := range required {
+		if _, ok := offsets[name]; !ok {
+			return nil, fmt.Errorf("member %q not found in %q", name, st.Name)
+		}
+	}
+
+	dst := "e." + f.name
+	addr := offsets["addr_raw"]
+
+	imports["bytes"] = struct{}{}
+	imports[typesImportPath] = struct{}{}
+	f.typ = "types.Endpoint"
+	f.decode = []string{fmt.Sprintf("%s = types.Endpoint{}", dst)}
+	if kind.name == "l4" {
+		imports["encoding/binary"] = struct{}{}
+		f.decode = []string{
+			fmt.Sprintf("%s = types.Endpoint{", dst),
+			fmt.Sprintf("\tPort:  binary.NativeEndian.Uint16(b[%d:]),", offsets["port"]),
+			fmt.Sprintf("\tProto: binary.NativeEndian.Uint16(b[%d:]),", offsets["proto"]),
+			"}",
+		}
+	}
+	f.decode = append(f.decode,
+		fmt.Sprintf("switch b[%d] {", offsets["version"]),
+		"case 4:",
+		fmt.Sprintf("\t%s.IP = bytes.Clone(b[%d:%d])", dst, addr, addr+4),
+		"case 6:",
+		fmt.Sprintf("\t%s.IP = bytes.Clone(b[%d:%d])", dst, addr, addr+16),
+		"}",
+	)
+	return f, nil
+}
+
+func isStdImport(path string) bool {
+	return !strings.Contains(strings.SplitN(path, "/", 2)[0], ".")
+}
+
+// columnTag returns the struct tag with the column attributes of a field
+func columnTag(name string, attrs metadatav1.FieldAttributes) string {
+	parts := []string{name}
+	if attrs.Width != 0 {
+		parts = append(parts, fmt.Sprintf("width:%d", attrs.Width))
+	}
+	if attrs.MinWidth != 0 {
+		parts = append(parts, fmt.Sprintf("minWidth:%d", attrs.MinWidth))
+	}
+	if attrs.MaxWidth != 0 {
+		parts = append(parts, fmt.Sprintf("maxWidth:%d", attrs.MaxWidth))
+	}
+	if attrs.Alignment != metadatav1.AlignmenNone {
+		parts = append(parts, "align:"+string(attrs.Alignment))
+	}
+	if attrs.Ellipsis != metadatav1.EllipsisNone {
+		parts = append(parts, "ellipsis:"+string(attrs.Ellipsis))
+	}
+	if attrs.Precision != nil {
+		parts = append(parts, fmt.Sprintf("precision:%d", *attrs.Precision))
+	}
+	if attrs.Template != "" {
+		parts = append(parts, "template:"+attrs.Template)
+	}
+	if attrs.Hidden {
+		parts = append(parts, "hide")
+	}
+	return fmt.Sprintf("column:%q", strings.Join(parts, ","))
+}
+
+// goIdentifier converts a C identifier, possibly with dots from flattened
+// nested structs, to an exported Go identifier: "src_addr" becomes "SrcAddr"
+func goIdentifier(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '_' || r == '.' }) {
+		runes := []rune(part)
+		runes[0] = unicode.ToUpper(runes[0])
+		sb.WriteString(string(runes))
+	}
+	if sb.Len() == 0 || unicode.IsDigit([]rune(sb.String())[0]) {
+		return "F" + sb.String()
+	}
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestGenerateGoStruct(t *testing.T) {
+	t.Parallel()
+
+	const goldenPath = "../../../../testdata/generate_go_struct_event.go.golden"
+
+	_, l4 := endpointTypes()
+	event := &btf.Struct{
+		Name: "my_event",
+		Size: 80,
+		Members: []btf.Member{
+			{Name: "u8", Type: u8Type},
+			{Name: "s8", Type: &btf.Int{Name: "signed char", Size: 1, Encoding: btf.Signed}, Offset: 8},
+			{Name: "u16", Type: &btf.Int{Name: "unsigned short", Size: 2}, Offset: 16},
+			{Name: "s16", Type: &btf.Int{Name: "short", Size: 2, Encoding: btf.Signed}, Offset: 32},
+			// 2 bytes of padding
+			{Name: "u32", Type: u32Type, Offset: 64},
+			{Name: "s32", Type: s32Type, Offset: 96},
+			{Name: "u64", Type: &btf.Int{Name: "unsigned long long", Size: 8}, Offset: 128},
+			{Name: "s64", Type: &btf.Int{Name: "long long", Size: 8, Encoding: btf.Signed}, Offset: 192},
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}, Offset: 256},
+			{Name: "src_endpoint", Type: l4, Offset: 384},
+			{Name: "flags", Type: u8Type, Offset: 576},
+			// 7 bytes of trailing padding
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"my_event": {
+				Fields: []metadatav1.Field{
+					{Name: "comm", Attributes: metadatav1.FieldAttributes{Template: "comm"}},
+					{Name: "u32", Attributes: metadatav1.FieldAttributes{
+						Width:     10,
+						MinWidth:  5,
+						MaxWidth:  20,
+						Alignment: metadatav1.AlignmentRight,
+						Ellipsis:  metadatav1.EllipsisEnd,
+					}},
+					{Name: "flags", Attributes: metadatav1.FieldAttributes{Hidden: true}},
+				},
+			},
+		},
+	}
+
+	out, err := GenerateGoStruct(m, event, "events")
+	require.NoError(t, err)
+
+	again, err := GenerateGoStruct(m, event, "events")
+	require.NoError(t, err)
+	require.Equal(t, string(out), string(again))
+
+	golden, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(out))
+}
+
+func TestGenerateGoStructErrors(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		metadata    *metadatav1.GadgetMetadata
+		members     []btf.Member
+		expectedErr string
+	}
+
+	tests := map[string]testCase{
+		"unknown_field": {
+			metadata: &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: []metadatav1.Field{{Name: "foo"}}},
+				},
+			},
+			members:     []btf.Member{{Name: "pid", Type: u32Type}},
+			expectedErr: `field "foo" not found in struct "event"`,
+		},
+		"same_go_name": {
+			members: []btf.Member{
+				{Name: "src_port", Type: u32Type},
+				{Name: "srcPort", Type: u32Type, Offset: 32},
+			},
+			expectedErr: `fields "src_port" and "srcPort" have the same Go name "SrcPort"`,
+		},
+		"unaligned": {
+			members:     []btf.Member{{Name: "pid", Type: u32Type, Offset: 4}},
+			expectedErr: "offset 4 isn't a multiple of 8 bits",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			event := &btf.Struct{Name: "event", Size: 8, Members: test.members}
+			_, err := GenerateGoStruct(test.metadata, event, "events")
+			require.ErrorContains(t, err, test.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,589 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"slices"
+	"sort"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/capabilities"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/errno"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/signals"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/syscalls"
+)
+
+// Endpoint is the decoded value of gadget_l3endpoint_t and
+// gadget_l4endpoint_t members. Port and Proto are only set for L4 endpoints.
+type Endpoint struct {
+	IP    net.IP
+	Port  uint16
+	Proto uint16
+}
+
+// Decoder decodes the raw events of a struct, as read from the tracer map,
+// using the layout of the struct from BTF and the metadata of its fields
+type Decoder struct {
+	size    uint32
+	fields  []decoderField
+	trailer *decoderTrailer
+}
+
+// decoderTrailer decodes the variable-length data following the struct
+type decoderTrailer struct {
+	name       string
+	asString   bool
+	lengthOff  uint32
+	lengthSize uint32
+	// tracer is the name of the tracer sending the events, for errors
+	tracer string
+}
+
+type decoderField struct {
+	name   string
+	decode func(b []byte) any
+}
+
+// DecoderOption configures a Decoder
+type DecoderOption func(*decoderOptions)
+
+type decoderOptions struct {
+	syscallArch  string
+	hiddenFields bool
+}
+
+// WithSyscallArch sets the architecture whose syscall table is used for the
+// fields with the "syscall" template, to decode events captured on a host of
+// another architecture. The one set in the syscalls package is used by
+// default.
+func WithSyscallArch(arch string) DecoderOption {
+	return func(o *decoderOptions) {
+		o.syscallArch = arch
+	}
+}
+
+// WithHiddenFields decodes the fields hidden by the metadata too, for
+// consumers still showing them on demand, like column-based outputs
+func WithHiddenFields() DecoderOption {
+	return func(o *decoderOptions) {
+		o.hiddenFields = true
+	}
+}
+
+// byteOrder is the one of the events, written by eBPF programs running on the
+// same host
+var byteOrder binary.ByteOrder = binary.NativeEndian
+
+// NewDecoder returns a decoder for events of type s. Hidden fields aren't
+// decoded, unless WithHiddenFields is given, and the labels of enums set in
+// the metadata win over the names of the enumerators. If the metadata declares
+// a trailer for s, the data after the struct is decoded as an additional
+// field. m can be nil.
+func NewDecoder(m *metadatav1.GadgetMetadata, s *btf.Struct, opts ...DecoderOption) (*Decoder, error) {
+	var options decoderOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.syscallArch == "" {
+		options.syscallArch = syscalls.Arch()
+	} else if !slices.Contains(syscalls.Arches(), options.syscallArch) {
+		return nil, fmt.Errorf("no syscall table for architecture %q", options.syscallArch)
+	}
+
+	fieldsMetadata := make(map[string]metadatav1.Field)
+	var trailer *metadatav1.Trailer
+	if m != nil {
+		for _, field := range m.Structs[s.Name].Fields {
+			fieldsMetadata[field.Name] = field
+		}
+		trailer = m.Structs[s.Name].Trailer
+	}
+
+	members, err := flattenMembers(s.Members, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("struct %q: %w", s.Name, err)
+	}
+
+	d := &Decoder{size: s.Size}
+	found := make(map[string]struct{}, len(members))
+
+	for _, member := range members {
+		found[member.Name] = struct{}{}
+
+		field := fieldsMetadata[member.Name]
+		if field.Attributes.Hidden && !options.hiddenFields {
+			continue
+		}
+
+		decode, err := memberDecoder(member, field)
+		if err != nil {
+			return nil, fmt.Errorf("struct %q: field %q: %w", s.Name, member.Name, err)
+		}
+		if decode == nil {
+			continue
+		}
+		if field.Attributes.Template == syscallTemplate && isInteger(member) {
+			decode = syscallDecoder(decode, options.syscallArch)
+		}
+		if field.Attributes.Template == signalTemplate && isSignal(member) {
+			decode = integerDecoder(decode, signals.Name)
+		}
+		if field.Attributes.Template == capabilitiesTemplate && isCapabilities(member) {
+			decode = integerDecoder(decode, func(v int64) string {
+				return capabilities.Format(uint64(v))
+			})
+		}
+		if field.Attributes.Template == errnoTemplate && isSignedInt(member) {
+			// Keep the number next to the name of the error
+			d.fields = append(d.fields, decoderField{name: member.Name + errnoRawSuffix, decode: decode})
+			decode = errnoDecoder(decode)
+		}
+		d.fields = append(d.fields, decoderField{name: member.Name, decode: decode})
+	}
+
+	for name := range fieldsMetadata {
+		if _, ok := found[name]; !ok {
+			return nil, fmt.Errorf("field %q not found in struct %q", name, s.Name)
+		}
+	}
+
+	if trailer != nil {
+		d.trailer, err = newDecoderTrailer(m, s, *trailer)
+		if err != nil {
+			return nil, fmt.Errorf("struct %q: trailer: %w", s.Name, err)
+		}
+	}
+
+	return d, nil
+}
+
+func newDecoderTrailer(m *metadatav1.GadgetMetadata, s *btf.Struct, trailer metadatav1.Trailer) (*decoderTrailer, error) {
+	if err := validateTrailer(trailer, s); err != nil {
+		return nil, err
+	}
+
+	member, _ := trailerLengthMember(trailer, s)
+	size, err := btf.Sizeof(member.Type)
+	if err != nil {
+		return nil, fmt.Errorf("getting size of length field: %w", err)
+	}
+
+	t := &decoderTrailer{
+		name:       trailer.Name,
+		asString:   trailer.Type == metadatav1.TrailerTypeString,
+		lengthOff:  member.Offset.Bytes(),
+		lengthSize: uint32(size),
+	}
+
+	// Sort the names to always report the same tracer
+	tracers := make([]string, 0, len(m.Tracers))
+	for name, tracer := range m.Tracers {
+		if tracer.StructName == s.Name {
+			tracers = append(tracers, name)
+		}
+	}
+	sort.Strings(tracers)
+	if len(tracers) > 0 {
+		t.tracer = tracers[0]
+	}
+
+	return t, nil
+}
+
+// Decode returns the value of each field of the event in b, by field name. b
+// can be longer than the struct and its trailer, the remaining bytes are
+// ignored.
+func (d *Decoder) Decode(b []byte) (map[string]any, error) {
+	if len(b) < int(d.size) {
+		return nil, fmt.Errorf("buffer has %d bytes, expected at least %d", len(b), d.size)
+	}
+
+	out := make(map[string]any, len(d.fields)+1)
+	for _, field := range d.fields {
+		out[field.name] = field.decode(b)
+	}
+
+	if d.trailer != nil {
+		data, err := d.trailer.decode(b, d.size)
+		if err != nil {
+			return nil, err
+		}
+		out[d.trailer.name] = data
+	}
+
+	return out, nil
+}
+
+// decode returns the trailer of the event in b, a struct of the given size.
+// Unlike the struct, the trailer must be complete.
+func (t *decoderTrailer) decode(b []byte, size uint32) (any, error) {
+	var length uint64
+	switch t.lengthSize {
+	case 1:
+		length = uint64(b[t.lengthOff])
+	case 2:
+		length = uint64(byteOrder.Uint16(b[t.lengthOff:]))
+	case 4:
+		length = uint64(byteOrder.Uint32(b[t.lengthOff:]))
+	case 8:
+		length = byteOrder.Uint64(b[t.lengthOff:])
+	}
+
+	if available := uint64(len(b)) - uint64(size); length > available {
+		if t.tracer != "" {
+			return nil, fmt.Errorf("tracer %q: event has %d bytes of %q, expected %d", t.tracer, available, t.name, length)
+		}
+		return nil, fmt.Errorf("event has %d bytes of %q, expected %d", available, t.name, length)
+	}
+
+	data := b[size : uint64(size)+length]
+	if t.asString {
+		return cString(data), nil
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// memberDecoder returns the function decoding member from the raw event, or
+// nil if the type of the member has no value of its own (e.g. void)
+func memberDecoder(member btf.Member, field metadatav1.Field) (func([]byte) any, error) {
+	if member.BitfieldSize > 0 {
+		start, end := btfhelpers.BitfieldBytes(member.Offset, member.BitfieldSize)
+		offset := member.Offset % 8
+		size := member.BitfieldSize
+		decode := func(b []byte) any {
+			return btfhelpers.ReadBitfield(b[start:end], offset, size, byteOrder, false)
+		}
+		if btfhelpers.IsSigned(member.Type) {
+			decode = func(b []byte) any {
+				return int64(btfhelpers.ReadBitfield(b[start:end], offset, size, byteOrder, true))
+			}
+		}
+		if len(field.Values) == 0 {
+			return decode, nil
+		}
+		return labelDecoder(decode, field.Values, field.DefaultLabel), nil
+	}
+
+	if member.Offset%8 != 0 {
+		return nil, fmt.Errorf("offset %d isn't a multiple of 8 bits", member.Offset)
+	}
+	offset := member.Offset.Bytes()
+
+	// Addresses are stored in network byte order, as net.IP expects them
+	if size := ipVersionSize(field.Attributes.IPVersion); size != 0 {
+		return func(b []byte) any {
+			return net.IP(append([]byte(nil), b[offset:offset+size]...))
+		}, nil
+	}
+
+	switch int128Repr(field, member) {
+	case int128Number:
+		signed := btfhelpers.IsSigned(member.Type)
+		return func(b []byte) any {
+			return decodeInt128(b[offset:offset+16], signed)
+		}, nil
+	case int128Bytes:
+		return func(b []byte) any {
+			var v [16]byte
+			copy(v[:], b[offset:])
+			return v
+		}, nil
+	case int128IPv6:
+		return func(b []byte) any {
+			return net.IP(append([]byte(nil), b[offset:offset+net.IPv6len]...))
+		}, nil
+	}
+
+	if kind, ok := getEndpointKind(member.Type); ok {
+		st := btfhelpers.GetUnderlyingType(member.Type).(*btf.Struct)
+		return endpointDecoder(offset, st, kind)
+	}
+
+	if field.Attributes.Template == macAddrTemplate && isMacAddr(member) {
+		return func(b []byte) any {
+			return net.HardwareAddr(b[offset : offset+macAddrLen]).String()
+		}, nil
+	}
+
+	if n := charArrayLen(member.Type); n > 0 {
+		end := offset + uint32(n)
+		switch field.Attributes.Encoding {
+		case metadatav1.EncodingHex:
+			return func(b []byte) any {
+				return hex.EncodeToString(b[offset:end])
+			}, nil
+		case metadatav1.EncodingBase64:
+			return func(b []byte) any {
+				return base64.StdEncoding.EncodeToString(b[offset:end])
+			}, nil
+		}
+		return func(b []byte) any {
+			return cString(b[offset:end])
+		}, nil
+	}
+
+	switch t := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if t.Encoding == btf.Bool {
+			return func(b []byte) any {
+				return b[offset] != 0
+			}, nil
+		}
+		decode, err := intDecoder(offset, t.Size, t.Encoding == btf.Signed)
+		if err != nil || len(field.Values) == 0 {
+			return decode, err
+		}
+		return labelDecoder(decode, field.Values, field.DefaultLabel), nil
+	case *btf.Enum:
+		labels, _ := enumValues(t)
+		for v, label := range field.Values {
+			labels[v] = label
+		}
+		decode, err := intDecoder(offset, t.Size, t.Signed)
+		if err != nil {
+			return nil, err
+		}
+		return labelDecoder(decode, labels, field.DefaultLabel), nil
+	case *btf.Float:
+		switch t.Size {
+		case 4:
+			return func(b []byte) any {
+				return math.Float32frombits(byteOrder.Uint32(b[offset:]))
+			}, nil
+		case 8:
+			return func(b []byte) any {
+				return math.Float64frombits(byteOrder.Uint64(b[offset:]))
+			}, nil
+		}
+		return nil, fmt.Errorf("unsupported float size %d", t.Size)
+	case *btf.Pointer:
+		return func(b []byte) any {
+			return byteOrder.Uint64(b[offset:])
+		}, nil
+	case *btf.Void:
+		return nil, nil
+	}
+
+	// Arrays other than strings, unions, etc. are returned as raw bytes
+	size, err := btf.Sizeof(member.Type)
+	if err != nil {
+		return nil, fmt.Errorf("getting size: %w", err)
+	}
+	return func(b []byte) any {
+		return append([]byte(nil), b[offset:offset+uint32(size)]...)
+	}, nil
+}
+
+func intDecoder(offset, size uint32, signed bool) (func([]byte) any, error) {
+	switch size {
+	case 1:
+		if signed {
+			return func(b []byte) any { return int8(b[offset]) }, nil
+		}
+		return func(b []byte) any { return b[offset] }, nil
+	case 2:
+		if signed {
+			return func(b []byte) any { return int16(byteOrder.Uint16(b[offset:])) }, nil
+		}
+		return func(b []byte) any { return byteOrder.Uint16(b[offset:]) }, nil
+	case 4:
+		if signed {
+			return func(b []byte) any { return int32(byteOrder.Uint32(b[offset:])) }, nil
+		}
+		return func(b []byte) any { return byteOrder.Uint32(b[offset:]) }, nil
+	case 8:
+		if signed {
+			return func(b []byte) any { return int64(byteOrder.Uint64(b[offset:])) }, nil
+		}
+		return func(b []byte) any { return byteOrder.Uint64(b[offset:]) }, nil
+	}
+	return nil, fmt.Errorf("unsupported integer size %d", size)
+}
+
+// syscallDecoder wraps the decoder of an integer to return the name of the
+// syscall with that number in the table of arch, or syscall_<n> if unknown
+func syscallDecoder(decode func([]byte) any, arch string) func([]byte) any {
+	return integerDecoder(decode, func(nr int64) string {
+		return syscalls.SyscallNameForArch(arch, int(nr))
+	})
+}
+
+// integerDecoder wraps the decoder of an integer to return its rendering as a
+// string. Values that aren't integers, like labels, are kept.
+func integerDecoder(decode func([]byte) any, render func(int64) string) func([]byte) any {
+	return func(b []byte) any {
+		var v int64
+		switch i := decode(b).(type) {
+		case int8:
+			v = int64(i)
+		case int16:
+			v = int64(i)
+		case int32:
+			v = int64(i)
+		case int64:
+			v = i
+		case uint8:
+			v = int64(i)
+		case uint16:
+			v = int64(i)
+		case uint32:
+			v = int64(i)
+		case uint64:
+			v = int64(i)
+		default:
+			return i
+		}
+		return render(v)
+	}
+}
+
+// errnoDecoder wraps the decoder of a signed integer to return the name of the
+// error for negative values, like EACCES for -13. Other values are kept.
+func errnoDecoder(decode func([]byte) any) func([]byte) any {
+	return func(b []byte) any {
+		v := decode(b)
+		var n int64
+		switch i := v.(type) {
+		case int8:
+			n = int64(i)
+		case int16:
+			n = int64(i)
+		case int32:
+			n = int64(i)
+		case int64:
+			n = i
+		}
+		if n < 0 {
+			if name := errno.Name(-n); name != "" {
+				return name
+			}
+		}
+		return v
+	}
+}
+
+// decodeInt128 returns the 128-bit integer in b, stored in the byte order of
+// the host
+func decodeInt128(b []byte, signed bool) *big.Int {
+	// big.Int reads big-endian bytes
+	buf := make([]byte, 16)
+	copy(buf, b)
+	if byteOrder.Uint16([]byte{1, 0}) == 1 {
+		slices.Reverse(buf)
+	}
+
+	n := new(big.Int).SetBytes(buf)
+	if signed && buf[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), 128))
+	}
+	return n
+}
+
+// labelDecoder wraps decode to return the label of the value, or
+// defaultLabel if it has none. Values without label are returned as they are
+// if there isn't a default label.
+func labelDecoder(decode func([]byte) any, labels map[int64]string, defaultLabel string) func([]byte) any {
+	return func(b []byte) any {
+		val := decode(b)
+		if label, ok := labels[toInt64(val)]; ok {
+			return label
+		}
+		if defaultLabel != "" {
+			return defaultLabel
+		}
+		return val
+	}
+}
+
+// toInt64 converts the values returned by intDecoder to the keys used by
+// enumValues
+func toInt64(val any) int64 {
+	switch v := val.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	}
+	return 0
+}
+
+// endpointDecoder decodes endpoints using the layout of st, see
+// include/gadget/types.h
+func endpointDecoder(offset uint32, st *btf.Struct, kind endpointKind) (func([]byte) any, error) {
+	offsets := make(map[string]uint32, len(st.Members))
+	for _, m := range st.Members {
+		offsets[m.Name] = offset + m.Offset.Bytes()
+	}
+
+	required := []string{"addr_raw", "version"}
+	if kind.name == "l4" {
+		required = append(required, "port", "proto")
+	}
+	for _, name := range required {
+		if _, ok := offsets[name]; !ok {
+			return nil, fmt.Errorf("member %q not found in %q", name, st.Name)
+		}
+	}
+
+	return func(b []byte) any {
+		var ep Endpoint
+
+		addr := offsets["addr_raw"]
+		switch b[offsets["version"]] {
+		case 4:
+			ep.IP = net.IP(append([]byte(nil), b[addr:addr+net.IPv4len]...))
+		case 6:
+			ep.IP = net.IP(append([]byte(nil), b[addr:addr+net.IPv6len]...))
+		}
+
+		if kind.name == "l4" {
+			ep.Port = byteOrder.Uint16(b[offsets["port"]:])
+			ep.Proto = byteOrder.Uint16(b[offsets["proto"]:])
+		}
+		return ep
+	}, nil
+}
+
+// cString returns the string in b up to the first NUL
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,737 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"math/big"
+	"net"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+type testL4Endpoint struct {
+	Addr    [16]byte
+	Port    uint16
+	Proto   uint16
+	Version uint8
+	_       [3]byte
+}
+
+type testL3Endpoint struct {
+	Addr    [16]byte
+	Version uint8
+	_       [3]byte
+}
+
+// testDecoderEvent is the packed layout of decoderStruct
+type testDecoderEvent struct {
+	Pid    uint32
+	Delta  int32
+	Comm   [16]byte
+	Type   uint32
+	Ratio  float64
+	Flag   bool
+	Src    testL4Endpoint
+	Dst    testL3Endpoint
+	Secret uint64
+	Addr   uint64
+	Raw    [4]uint32
+	Bits   uint8
+}
+
+func decoderStruct() *btf.Struct {
+	l3, l4 := endpointTypes()
+	return &btf.Struct{
+		Name: "event",
+		Size: 114,
+		Members: []btf.Member{
+			{Name: "pid", Type: u32Type, Offset: 0},
+			{Name: "delta", Type: s32Type, Offset: 4 * 8},
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}, Offset: 8 * 8},
+			{Name: "type", Type: eventTypeEnum, Offset: 24 * 8},
+			{Name: "ratio", Type: &btf.Float{Name: "double", Size: 8}, Offset: 28 * 8},
+			{Name: "flag", Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}, Offset: 36 * 8},
+			{Name: "src", Type: l4, Offset: 37 * 8},
+			{Name: "dst", Type: &btf.Typedef{Name: "my_l3_t", Type: l3}, Offset: 61 * 8},
+			{Name: "secret", Type: &btf.Int{Name: "__u64", Size: 8}, Offset: 81 * 8},
+			{Name: "addr", Type: &btf.Pointer{Target: &btf.Void{}}, Offset: 89 * 8},
+			{Name: "raw", Type: &btf.Array{Type: u32Type, Nelems: 4}, Offset: 97 * 8},
+			{Name: "state", Type: &btf.Int{Name: "unsigned char", Size: 1}, Offset: 113 * 8, BitfieldSize: 3},
+			{Name: "prio", Type: &btf.Int{Name: "signed char", Size: 1, Encoding: btf.Signed}, Offset: 113*8 + 3, BitfieldSize: 5},
+		},
+	}
+}
+
+// packBits returns the byte holding state (3 bits) and prio (5 bits), as the
+// compiler lays them out for the byte order of the host
+func packBits(state, prio uint8) uint8 {
+	if byteOrder.Uint16([]byte{1, 0}) == 1 {
+		return state | prio<<3
+	}
+	return state<<5 | prio
+}
+
+func testDecoderEventBytes(t *testing.T) ([]byte, testDecoderEvent) {
+	t.Helper()
+
+	ev := testDecoderEvent{
+		Pid:   1234,
+		Delta: -42,
+		Type:  3,
+		Ratio: 0.75,
+		Flag:  true,
+		Src: testL4Endpoint{
+			Port:    443,
+			Proto:   6,
+			Version: 4,
+		},
+		Dst: testL3Endpoint{
+			Version: 6,
+		},
+		Secret: 0xdeadbeef,
+		Addr:   0x7ffd4c3a1e08,
+		Raw:    [4]uint32{1, 2, 3, 4},
+		Bits:   packBits(5, 0x1e),
+	}
+	copy(ev.Comm[:], "curl")
+	copy(ev.Src.Addr[:], net.IPv4(10, 0, 0, 1).To4())
+	copy(ev.Dst.Addr[:], net.ParseIP("2001:db8::1"))
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, byteOrder, ev))
+	require.Equal(t, 114, buf.Len())
+	return buf.Bytes(), ev
+}
+
+func TestDecoderRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	raw, ev := testDecoderEventBytes(t)
+
+	d, err := NewDecoder(nil, decoderStruct())
+	require.NoError(t, err)
+
+	out, err := d.Decode(raw)
+	require.NoError(t, err)
+
+	var rawArray bytes.Buffer
+	require.NoError(t, binary.Write(&rawArray, byteOrder, ev.Raw))
+
+	require.Equal(t, map[string]any{
+		"pid":    uint32(1234),
+		"delta":  int32(-42),
+		"comm":   "curl",
+		"type":   "CONNECT",
+		"ratio":  0.75,
+		"flag":   true,
+		"src":    Endpoint{IP: net.IPv4(10, 0, 0, 1).To4(), Port: 443, Proto: 6},
+		"dst":    Endpoint{IP: net.ParseIP("2001:db8::1")},
+		"secret": uint64(0xdeadbeef),
+		"addr":   uint64(0x7ffd4c3a1e08),
+		"raw":    rawArray.Bytes(),
+		"state":  uint64(5),
+		"prio":   int64(-2),
+	}, out)
+
+	// Encode the decoded values again
+	var back testDecoderEvent
+	back.Pid = out["pid"].(uint32)
+	back.Delta = out["delta"].(int32)
+	copy(back.Comm[:], out["comm"].(string))
+	for _, v := range eventTypeEnum.Values {
+		if v.Name == out["type"] {
+			back.Type = uint32(v.Value)
+		}
+	}
+	back.Ratio = out["ratio"].(float64)
+	back.Flag = out["flag"].(bool)
+	src := out["src"].(Endpoint)
+	copy(back.Src.Addr[:], src.IP)
+	back.Src.Port = src.Port
+	back.Src.Proto = src.Proto
+	back.Src.Version = 4
+	dst := out["dst"].(Endpoint)
+	copy(back.Dst.Addr[:], dst.IP)
+	back.Dst.Version = 6
+	back.Secret = out["secret"].(uint64)
+	back.Addr = out["addr"].(uint64)
+	require.NoError(t, binary.Read(bytes.NewReader(out["raw"].([]byte)), byteOrder, &back.Raw))
+	back.Bits = packBits(uint8(out["state"].(uint64)), uint8(out["prio"].(int64))&0x1f)
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, byteOrder, back))
+	require.Equal(t, raw, buf.Bytes())
+}
+
+func TestDecoderMetadata(t *testing.T) {
+	t.Parallel()
+
+	raw, _ := testDecoderEventBytes(t)
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "secret", Attributes: metadatav1.FieldAttributes{Hidden: true}},
+					{Name: "type", Values: map[int64]string{3: "connect"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, decoderStruct())
+	require.NoError(t, err)
+
+	// Trailing data is ignored
+	out, err := d.Decode(append(raw, 1, 2, 3))
+	require.NoError(t, err)
+	require.NotContains(t, out, "secret")
+	require.Equal(t, "connect", out["type"])
+	require.Equal(t, uint32(1234), out["pid"])
+
+	// Values without label are kept as numbers
+	raw[24] = 42
+	out, err = d.Decode(raw)
+	require.NoError(t, err)
+	require.Equal(t, uint32(42), out["type"])
+
+	_, err = d.Decode(raw[:113])
+	require.ErrorContains(t, err, "buffer has 113 bytes, expected at least 114")
+
+	d, err = NewDecoder(m, decoderStruct(), WithHiddenFields())
+	require.NoError(t, err)
+	out, err = d.Decode(raw)
+	require.NoError(t, err)
+	require.Contains(t, out, "secret")
+
+	m.Structs["event"] = metadatav1.Struct{
+		Fields: []metadatav1.Field{{Name: "nonexistent"}},
+	}
+	_, err = NewDecoder(m, decoderStruct())
+	require.ErrorContains(t, err, `field "nonexistent" not found in struct "event"`)
+}
+
+func TestDecoderTrailer(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "pid", Type: u32Type},
+			{Name: "args_len", Type: u32Type, Offset: 32},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Tracers: map[string]metadatav1.Tracer{
+			"exec": {MapName: "events", StructName: "event"},
+		},
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Trailer: &metadatav1.Trailer{
+					Name:        "args",
+					Type:        metadatav1.TrailerTypeString,
+					LengthField: "args_len",
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	encode := func(pid uint32, args string) []byte {
+		b := make([]byte, 8, 8+len(args))
+		byteOrder.PutUint32(b[0:], pid)
+		byteOrder.PutUint32(b[4:], uint32(len(args)))
+		return append(b, args...)
+	}
+
+	first := encode(1, "ls\x00-l\x00")
+	second := encode(2, "cat\x00/etc/os-release\x00")
+
+	out, err := d.Decode(first)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"pid": uint32(1), "args_len": uint32(6), "args": "ls"}, out)
+
+	out, err = d.Decode(second)
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"pid": uint32(2), "args_len": uint32(20), "args": "cat"}, out)
+
+	// Data after the trailer is ignored
+	out, err = d.Decode(append(first, 0xff, 0xff))
+	require.NoError(t, err)
+	require.Equal(t, "ls", out["args"])
+
+	_, err = d.Decode(second[:len(second)-1])
+	require.ErrorContains(t, err, `tracer "exec": event has 19 bytes of "args", expected 20`)
+
+	// As bytes
+	m.Structs["event"].Trailer.Type = metadatav1.TrailerTypeBytes
+	d, err = NewDecoder(m, event)
+	require.NoError(t, err)
+
+	out, err = d.Decode(second)
+	require.NoError(t, err)
+	require.Equal(t, []byte("cat\x00/etc/os-release\x00"), out["args"])
+
+	m.Structs["event"].Trailer.LengthField = "len"
+	_, err = NewDecoder(m, event)
+	require.ErrorContains(t, err, `length field "len" not found`)
+}
+
+func TestDecoderInt128(t *testing.T) {
+	t.Parallel()
+
+	u128, s128, in6 := int128Types()
+	hash := &btf.Array{Type: u32Type, Nelems: 4}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 80,
+		Members: []btf.Member{
+			{Name: "bytes", Type: u128},
+			{Name: "delta", Type: s128, Offset: 128},
+			{Name: "saddr", Type: in6, Offset: 256},
+			{Name: "hash", Type: hash, Offset: 384},
+			{Name: "raw", Type: u128, Offset: 512},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "hash", Annotations: map[string]interface{}{"ebpf.int128": "number"}},
+					{Name: "raw", Annotations: map[string]interface{}{"ebpf.int128": "bytes"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	// 2^64 + 5: the low half is 5 and the high half is 1
+	putUint128 := func(b []byte, hi, lo uint64) {
+		if byteOrder.Uint16([]byte{1, 0}) == 1 {
+			byteOrder.PutUint64(b[0:], lo)
+			byteOrder.PutUint64(b[8:], hi)
+		} else {
+			byteOrder.PutUint64(b[0:], hi)
+			byteOrder.PutUint64(b[8:], lo)
+		}
+	}
+
+	raw := make([]byte, 80)
+	putUint128(raw[0:], 1, 5)
+	putUint128(raw[16:], math.MaxUint64, math.MaxUint64-1) // -2
+	copy(raw[32:], net.ParseIP("2001:db8::1"))
+	putUint128(raw[48:], math.MaxUint64, math.MaxUint64)
+	for i := range raw[64:] {
+		raw[64+i] = byte(i)
+	}
+
+	out, err := d.Decode(raw)
+	require.NoError(t, err)
+
+	expected, ok := new(big.Int).SetString("18446744073709551621", 10)
+	require.True(t, ok)
+	require.Equal(t, 0, expected.Cmp(out["bytes"].(*big.Int)), out["bytes"])
+	require.Equal(t, 0, big.NewInt(-2).Cmp(out["delta"].(*big.Int)), out["delta"])
+	require.Equal(t, net.ParseIP("2001:db8::1"), out["saddr"])
+
+	maxUint128 := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+	require.Equal(t, 0, maxUint128.Cmp(out["hash"].(*big.Int)), out["hash"])
+
+	require.Equal(t, [16]byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, out["raw"])
+}
+
+func TestDecoderIPAddresses(t *testing.T) {
+	t.Parallel()
+
+	be32 := &btf.Typedef{Name: "__be32", Type: &btf.Int{Name: "unsigned int", Size: 4}}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 20,
+		Members: []btf.Member{
+			{Name: "saddr", Type: be32},
+			{Name: "daddr", Type: &btf.Array{Type: u8Type, Nelems: 16}, Offset: 32},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "saddr", Attributes: metadatav1.FieldAttributes{IPVersion: 4}},
+					{Name: "daddr", Attributes: metadatav1.FieldAttributes{IPVersion: 6}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	raw := make([]byte, 20)
+	// __be32 holds the address in network byte order whatever the host is
+	binary.BigEndian.PutUint32(raw[0:], 0xc0a80001)
+	copy(raw[4:], net.ParseIP("2001:db8::1"))
+
+	out, err := d.Decode(raw)
+	require.NoError(t, err)
+
+	require.Equal(t, "192.168.0.1", out["saddr"].(net.IP).String())
+	require.Equal(t, "2001:db8::1", out["daddr"].(net.IP).String())
+}
+
+func TestDecoderMacAddresses(t *testing.T) {
+	t.Parallel()
+
+	mac := &btf.Array{Type: u8Type, Nelems: 6}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 12,
+		Members: []btf.Member{
+			{Name: "src_mac", Type: mac},
+			{Name: "dst_mac", Type: mac, Offset: 48},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "src_mac", Attributes: metadatav1.FieldAttributes{Template: "macaddr"}},
+					{Name: "dst_mac", Attributes: metadatav1.FieldAttributes{Template: "macaddr"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	raw := []byte{
+		0x02, 0x42, 0xac, 0x11, 0x00, 0x0a,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	}
+	out, err := d.Decode(raw)
+	require.NoError(t, err)
+
+	require.Equal(t, "02:42:ac:11:00:0a", out["src_mac"])
+	require.Equal(t, "ff:ff:ff:ff:ff:ff", out["dst_mac"])
+}
+
+func TestDecoderEncoding(t *testing.T) {
+	t.Parallel()
+
+	payload := &btf.Array{Type: u8Type, Nelems: 8}
+	event := &btf.Struct{
+		Name:    "event",
+		Size:    8,
+		Members: []btf.Member{{Name: "payload", Type: payload}},
+	}
+
+	// Text followed by binary data, which isn't valid UTF-8
+	raw := []byte{'G', 'E', 'T', 0x00, 0xff, 0xfe, 0x80, 0x01}
+
+	type testCase struct {
+		encoding metadatav1.Encoding
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			expected: "GET",
+		},
+		"utf8": {
+			encoding: metadatav1.EncodingUTF8,
+			expected: "GET",
+		},
+		"hex": {
+			encoding: metadatav1.EncodingHex,
+			expected: "47455400fffe8001",
+		},
+		"base64": {
+			encoding: metadatav1.EncodingBase64,
+			expected: "R0VUAP/+gAE=",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {
+						Fields: []metadatav1.Field{
+							{Name: "payload", Attributes: metadatav1.FieldAttributes{Encoding: test.encoding}},
+						},
+					},
+				},
+			}
+
+			d, err := NewDecoder(m, event)
+			require.NoError(t, err)
+
+			out, err := d.Decode(raw)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, out["payload"])
+
+			js, err := json.Marshal(out)
+			require.NoError(t, err)
+			require.True(t, json.Valid(js))
+			require.True(t, utf8.Valid(js))
+		})
+	}
+}
+
+func TestDecoderErrno(t *testing.T) {
+	t.Parallel()
+
+	s32 := &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}
+	event := &btf.Struct{
+		Name:    "event",
+		Size:    4,
+		Members: []btf.Member{{Name: "ret", Type: s32}},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "ret", Attributes: metadatav1.FieldAttributes{Template: "errno"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	type testCase struct {
+		ret      int32
+		expected any
+	}
+
+	tests := map[string]testCase{
+		"eacces": {
+			ret:      -13,
+			expected: "EACCES",
+		},
+		"zero": {
+			ret:      0,
+			expected: int32(0),
+		},
+		"fd": {
+			ret:      3,
+			expected: int32(3),
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			raw := make([]byte, 4)
+			byteOrder.PutUint32(raw, uint32(test.ret))
+			out, err := d.Decode(raw)
+			require.NoError(t, err)
+
+			require.Equal(t, test.expected, out["ret"])
+			// The number is kept
+			require.Equal(t, test.ret, out["ret_raw"])
+		})
+	}
+}
+
+func TestDecoderSyscall(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name:    "event",
+		Size:    4,
+		Members: []btf.Member{{Name: "syscall", Type: u32Type}},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "syscall", Attributes: metadatav1.FieldAttributes{Template: "syscall"}},
+				},
+			},
+		},
+	}
+
+	type testCase struct {
+		arch     string
+		nr       uint32
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"amd64": {
+			arch:     "amd64",
+			nr:       257,
+			expected: "openat",
+		},
+		"arm64": {
+			arch:     "arm64",
+			nr:       56,
+			expected: "openat",
+		},
+		"unknown": {
+			arch:     "amd64",
+			nr:       9999,
+			expected: "syscall_9999",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := NewDecoder(m, event, WithSyscallArch(test.arch))
+			require.NoError(t, err)
+
+			raw := make([]byte, 4)
+			byteOrder.PutUint32(raw, test.nr)
+			out, err := d.Decode(raw)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, out["syscall"])
+		})
+	}
+
+	_, err := NewDecoder(m, event, WithSyscallArch("mips"))
+	require.ErrorContains(t, err, `no syscall table for architecture "mips"`)
+}
+
+func TestDecoderSignalCapabilities(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "u64", Size: 8}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "sig", Type: s32Type},
+			{Name: "caps", Type: u64, Offset: 64},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "sig", Attributes: metadatav1.FieldAttributes{Template: "signal"}},
+					{Name: "caps", Attributes: metadatav1.FieldAttributes{Template: "capabilities"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, event)
+	require.NoError(t, err)
+
+	type testCase struct {
+		sig          int32
+		caps         uint64
+		expectedSig  string
+		expectedCaps string
+	}
+
+	tests := map[string]testCase{
+		"normal_signal_empty_caps": {
+			sig:          9,
+			caps:         0,
+			expectedSig:  "SIGKILL",
+			expectedCaps: "",
+		},
+		"realtime_signal_unknown_cap": {
+			sig:          35,
+			caps:         1<<21 | 1<<62,
+			expectedSig:  "SIGRTMIN+3",
+			expectedCaps: "CAP_SYS_ADMIN|CAP_62",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			raw := make([]byte, 16)
+			byteOrder.PutUint32(raw, uint32(test.sig))
+			byteOrder.PutUint64(raw[8:], test.caps)
+			out, err := d.Decode(raw)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSig, out["sig"])
+			require.Equal(t, test.expectedCaps, out["caps"])
+		})
+	}
+}
+
+func TestDecoderValues(t *testing.T) {
+	t.Parallel()
+
+	raw, _ := testDecoderEventBytes(t)
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "pid", Values: map[int64]string{1: "init"}, DefaultLabel: "other"},
+					{Name: "delta", Values: map[int64]string{-42: "EAGAIN"}},
+					{Name: "type", Values: map[int64]string{3: "connect"}, DefaultLabel: "UNKNOWN"},
+					{Name: "state", Values: map[int64]string{5: "ESTABLISHED"}},
+				},
+			},
+		},
+	}
+
+	d, err := NewDecoder(m, decoderStruct())
+	require.NoError(t, err)
+
+	out, err := d.Decode(raw)
+	require.NoError(t, err)
+	require.Equal(t, "other", out["pid"])
+	require.Equal(t, "EAGAIN", out["delta"])
+	require.Equal(t, "connect", out["type"])
+	require.Equal(t, "ESTABLISHED", out["state"])
+
+	byteOrder.PutUint32(raw[0:], 1)
+	raw[24] = 42
+	out, err = d.Decode(raw)
+	require.NoError(t, err)
+	require.Equal(t, "init", out["pid"])
+	// The default label also applies to values not defined by the enum
+	require.Equal(t, "UNKNOWN", out["type"])
+}
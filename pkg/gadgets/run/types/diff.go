@@ -0,0 +1,178 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"gopkg.in/yaml.v2"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+type ChangeType string
+
+const (
+	ChangeAdded    ChangeType = "added"
+	ChangeModified ChangeType = "modified"
+)
+
+// Change is a modification Populate would do to the metadata
+type Change struct {
+	Type ChangeType
+	// Path of the changed element, like "structs.event.fields.pid"
+	Path string
+}
+
+func (c Change) String() string {
+	prefix := "+"
+	if c.Type == ChangeModified {
+		prefix = "~"
+	}
+	return prefix + " " + c.Path
+}
+
+// ChangeSet holds the modifications Populate would do to the metadata
+type ChangeSet struct {
+	Changes []Change
+}
+
+func (c *ChangeSet) Empty() bool {
+	return len(c.Changes) == 0
+}
+
+func (c *ChangeSet) String() string {
+	var sb strings.Builder
+	for _, change := range c.Changes {
+		sb.WriteString(change.String())
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func (c *ChangeSet) add(typ ChangeType, format string, args ...any) {
+	c.Changes = append(c.Changes, Change{Type: typ, Path: fmt.Sprintf(format, args...)})
+}
+
+// PopulateDiff returns the changes Populate would do to the metadata, without
+// modifying it
+func PopulateDiff(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, opts ...PopulateOption) (*ChangeSet, error) {
+	// Compare copies, so both sides went through the same marshalling
+	before, err := copyMetadata(m)
+	if err != nil {
+		return nil, err
+	}
+	after, err := copyMetadata(m)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Populate(after, spec, opts...); err != nil {
+		return nil, err
+	}
+
+	changes := &ChangeSet{}
+
+	for _, f := range []struct {
+		name          string
+		before, after string
+	}{
+		{"name", before.Name, after.Name},
+		{"description", before.Description, after.Description},
+		{"homepageURL", before.HomepageURL, after.HomepageURL},
+		{"documentationURL", before.DocumentationURL, after.DocumentationURL},
+		{"sourceURL", before.SourceURL, after.SourceURL},
+	} {
+		if f.before != f.after {
+			changes.add(ChangeModified, "%s", f.name)
+		}
+	}
+
+	diffMaps(changes, "tracers", before.Tracers, after.Tracers)
+	diffMaps(changes, "toppers", before.Toppers, after.Toppers)
+	diffMaps(changes, "profilers", before.Profilers, after.Profilers)
+	diffMaps(changes, "snapshotters", before.Snapshotters, after.Snapshotters)
+	diffMaps(changes, "ebpfParams", before.EBPFParams, after.EBPFParams)
+	diffMaps(changes, "gadgetParams", before.GadgetParams, after.GadgetParams)
+	diffMaps(changes, "metrics", before.Metrics, after.Metrics)
+	diffMaps(changes, "alerts", before.Alerts, after.Alerts)
+	diffMaps(changes, "programs", before.Programs, after.Programs)
+	diffMaps(changes, "attach", before.Attach, after.Attach)
+
+	if before.MntNsFilter == nil && after.MntNsFilter != nil {
+		changes.add(ChangeAdded, "mntnsFilter")
+	} else if !reflect.DeepEqual(before.MntNsFilter, after.MntNsFilter) {
+		changes.add(ChangeModified, "mntnsFilter")
+	}
+
+	for _, name := range sortedKeys(after.Structs) {
+		beforeStruct, ok := before.Structs[name]
+		if !ok {
+			changes.add(ChangeAdded, "structs.%s", name)
+			continue
+		}
+
+		beforeFields := make(map[string]metadatav1.Field, len(beforeStruct.Fields))
+		for _, field := range beforeStruct.Fields {
+			beforeFields[field.Name] = field
+		}
+		for _, field := range after.Structs[name].Fields {
+			beforeField, ok := beforeFields[field.Name]
+			if !ok {
+				changes.add(ChangeAdded, "structs.%s.fields.%s", name, field.Name)
+			} else if !reflect.DeepEqual(beforeField, field) {
+				changes.add(ChangeModified, "structs.%s.fields.%s", name, field.Name)
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+func diffMaps[T any](changes *ChangeSet, section string, before, after map[string]T) {
+	for _, name := range sortedKeys(after) {
+		beforeValue, ok := before[name]
+		if !ok {
+			changes.add(ChangeAdded, "%s.%s", section, name)
+		} else if !reflect.DeepEqual(beforeValue, after[name]) {
+			changes.add(ChangeModified, "%s.%s", section, name)
+		}
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func copyMetadata(m *metadatav1.GadgetMetadata) (*metadatav1.GadgetMetadata, error) {
+	out, err := Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	cpy := &metadatav1.GadgetMetadata{}
+	if err := yaml.Unmarshal(out, cpy); err != nil {
+		return nil, fmt.Errorf("copying metadata: %w", err)
+	}
+	return cpy, nil
+}
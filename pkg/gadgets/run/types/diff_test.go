@@ -0,0 +1,120 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestPopulateDiff(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		objectPath string
+		// modify changes the metadata generated by Populate
+		modify   func(m *metadatav1.GadgetMetadata)
+		expected []Change
+	}
+
+	tests := map[string]testCase{
+		"up_to_date": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+		},
+		"hand_edited": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.Name = "my gadget"
+				m.Structs["event"].Fields[0].Description = "PID of the process"
+			},
+		},
+		"missing_field": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+			modify: func(m *metadatav1.GadgetMetadata) {
+				st := m.Structs["event"]
+				st.Fields = st.Fields[:2]
+				m.Structs["event"] = st
+			},
+			expected: []Change{
+				{Type: ChangeAdded, Path: "structs.event.fields.filename"},
+			},
+		},
+		"missing_param": {
+			objectPath: "../../../../testdata/populate_metadata_1_param_from_scratch.o",
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.EBPFParams = nil
+			},
+			expected: []Change{
+				{Type: ChangeAdded, Path: "ebpfParams.param"},
+			},
+		},
+		"placeholder_removed": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.SourceURL = ""
+				delete(m.Tracers, "test")
+			},
+			expected: []Change{
+				{Type: ChangeModified, Path: "sourceURL"},
+				{Type: ChangeAdded, Path: "tracers.test"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			spec, err := ebpf.LoadCollectionSpec(test.objectPath)
+			require.NoError(t, err)
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, Populate(m, spec))
+			if test.modify != nil {
+				test.modify(m)
+			}
+
+			before, err := Marshal(m)
+			require.NoError(t, err)
+
+			changes, err := PopulateDiff(m, spec)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, changes.Changes)
+			require.Equal(t, len(test.expected) == 0, changes.Empty())
+
+			// The metadata isn't modified
+			after, err := Marshal(m)
+			require.NoError(t, err)
+			require.Equal(t, string(before), string(after))
+		})
+	}
+}
+
+func TestChangeSetString(t *testing.T) {
+	t.Parallel()
+
+	changes := &ChangeSet{
+		Changes: []Change{
+			{Type: ChangeAdded, Path: "structs.event.fields.pid"},
+			{Type: ChangeModified, Path: "ebpfParams.param"},
+		},
+	}
+	require.Equal(t, "+ structs.event.fields.pid\n~ ebpfParams.param\n", changes.String())
+}
@@ -0,0 +1,268 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+type markdownOptions struct {
+	spec *ebpf.CollectionSpec
+}
+
+type MarkdownOption func(*markdownOptions)
+
+// WithSpec adds the types of the fields, taken from the BTF of spec, to the
+// generated documentation
+func WithSpec(spec *ebpf.CollectionSpec) MarkdownOption {
+	return func(opts *markdownOptions) {
+		opts.spec = spec
+	}
+}
+
+// GenerateMarkdown returns the reference page of the gadget described by m:
+// its description, data sources, params and the fields of its structs with
+// their examples. The output only depends on its inputs, so it can be
+// committed and regenerated whenever the metadata changes.
+func GenerateMarkdown(m *metadatav1.GadgetMetadata, opts ...MarkdownOption) ([]byte, error) {
+	options := &markdownOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	var idx *btfIndex
+	if options.spec != nil {
+		idx = newBTFIndex(options.spec)
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "# %s\n", m.Name)
+	if m.Description != "" {
+		fmt.Fprintf(&buf, "\n%s\n", m.Description)
+	}
+	if m.LongDescription != "" {
+		fmt.Fprintf(&buf, "\n%s\n", strings.TrimSpace(m.LongDescription))
+	}
+
+	links := []struct{ name, url string }{
+		{"Documentation", m.DocURL()},
+		{"Source", m.SourceURL},
+	}
+	first := true
+	for _, link := range links {
+		if link.url == "" {
+			continue
+		}
+		if first {
+			buf.WriteString("\n")
+			first = false
+		}
+		fmt.Fprintf(&buf, "- %s: <%s>\n", link.name, link.url)
+	}
+
+	writeDataSourcesMarkdown(&buf, m)
+	writeParamsMarkdown(&buf, m)
+
+	for _, name := range sortedKeys(m.Structs) {
+		if err := writeStructMarkdown(&buf, idx, name, m.Structs[name]); err != nil {
+			return nil, fmt.Errorf("struct %q: %w", name, err)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeDataSourcesMarkdown(buf *bytes.Buffer, m *metadatav1.GadgetMetadata) {
+	type dataSource struct {
+		name, kind, structName, mapName string
+	}
+	var dataSources []dataSource
+	for name, t := range m.Tracers {
+		dataSources = append(dataSources, dataSource{name, "tracer", t.StructName, t.MapName})
+	}
+	for name, t := range m.Toppers {
+		dataSources = append(dataSources, dataSource{name, "topper", t.StructName, t.MapName})
+	}
+	for name, p := range m.Profilers {
+		dataSources = append(dataSources, dataSource{name, "profiler", p.KeyStructName, p.MapName})
+	}
+	for name, s := range m.Snapshotters {
+		dataSources = append(dataSources, dataSource{name, "snapshotter", s.StructName, s.MapName})
+	}
+	if len(dataSources) == 0 {
+		return
+	}
+	sort.Slice(dataSources, func(i, j int) bool {
+		return dataSources[i].name < dataSources[j].name
+	})
+
+	buf.WriteString("\n## Data sources\n\n")
+	buf.WriteString("| Name | Kind | Struct | Map |\n")
+	buf.WriteString("|------|------|--------|-----|\n")
+	for _, ds := range dataSources {
+		fmt.Fprintf(buf, "| %s | %s | %s | %s |\n", ds.name, ds.kind, markdownCode(ds.structName), markdownCode(ds.mapName))
+	}
+}
+
+func writeParamsMarkdown(buf *bytes.Buffer, m *metadatav1.GadgetMetadata) {
+	var descs []params.ParamDesc
+	altKeys := make(map[string][]string)
+	for _, p := range m.EBPFParams {
+		if p.Hidden {
+			continue
+		}
+		descs = append(descs, p.ToParamDesc())
+		altKeys[p.Key] = p.AltKeys
+	}
+	for key, p := range m.GadgetParams {
+		if p.Key == "" {
+			p.Key = key
+		}
+		descs = append(descs, p)
+	}
+	if len(descs) == 0 {
+		return
+	}
+	sort.Slice(descs, func(i, j int) bool {
+		return descs[i].Key < descs[j].Key
+	})
+
+	buf.WriteString("\n## Parameters\n\n")
+	buf.WriteString("| Name | Type | Default | Description |\n")
+	buf.WriteString("|------|------|---------|-------------|\n")
+	for _, p := range descs {
+		name := "--" + p.Key
+		for _, altKey := range altKeys[p.Key] {
+			name += ", --" + altKey
+		}
+		if p.Alias != "" {
+			name += ", -" + p.Alias
+		}
+		typ := string(p.TypeHint)
+		if typ == "" {
+			typ = string(params.TypeString)
+		}
+		defaultValue := params.MaskValue(p.DefaultValue, p.IsSensitive() && p.DefaultValue != "")
+		fmt.Fprintf(buf, "| %s | %s | %s | %s |\n", markdownCode(name), typ, markdownCode(defaultValue), markdownCell(p.Description))
+	}
+}
+
+func writeStructMarkdown(buf *bytes.Buffer, idx *btfIndex, name string, s metadatav1.Struct) error {
+	var btfStruct *btf.Struct
+	if idx != nil {
+		var err error
+		btfStruct, err = idx.structByName(name)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(buf, "\n## Struct %s\n\n", markdownCode(name))
+	if btfStruct != nil {
+		buf.WriteString("| Field | Type | Description | Examples |\n")
+		buf.WriteString("|-------|------|-------------|----------|\n")
+	} else {
+		buf.WriteString("| Field | Description | Examples |\n")
+		buf.WriteString("|-------|-------------|----------|\n")
+	}
+
+	for _, field := range s.Fields {
+		description := field.Description
+		if field.Deprecated {
+			description = strings.TrimSpace("**Deprecated.** " + description)
+		}
+
+		examples := make([]string, 0, len(field.Examples))
+		for _, example := range field.Examples {
+			examples = append(examples, markdownCode(example))
+		}
+
+		if btfStruct == nil {
+			fmt.Fprintf(buf, "| %s | %s | %s |\n", markdownCode(field.Name), markdownCell(description),
+				strings.Join(examples, ", "))
+			continue
+		}
+
+		typ := "string"
+		if field.Source == "" {
+			member, ok := findMember(btfStruct.Members, field.Name)
+			if !ok {
+				return fmt.Errorf("field %q not found in eBPF struct", field.Name)
+			}
+			typ = btfTypeName(member.Type)
+		}
+		fmt.Fprintf(buf, "| %s | %s | %s | %s |\n", markdownCode(field.Name), markdownCode(typ),
+			markdownCell(description), strings.Join(examples, ", "))
+	}
+
+	if t := s.Trailer; t != nil {
+		if btfStruct != nil {
+			fmt.Fprintf(buf, "| %s | %s | Variable-length data, its length is in %s |  |\n",
+				markdownCode(t.Name), markdownCode(string(t.Type)), markdownCode(t.LengthField))
+		} else {
+			fmt.Fprintf(buf, "| %s | Variable-length data, its length is in %s |  |\n",
+				markdownCode(t.Name), markdownCode(t.LengthField))
+		}
+	}
+
+	return nil
+}
+
+// btfTypeName returns the name of typ as written in C
+func btfTypeName(typ btf.Type) string {
+	switch t := typ.(type) {
+	case *btf.Struct:
+		return "struct " + t.Name
+	case *btf.Union:
+		return "union " + t.Name
+	case *btf.Enum:
+		return "enum " + t.Name
+	case *btf.Array:
+		return fmt.Sprintf("%s[%d]", btfTypeName(t.Type), t.Nelems)
+	case *btf.Pointer:
+		return btfTypeName(t.Target) + " *"
+	case *btf.Const:
+		return "const " + btfTypeName(t.Type)
+	case *btf.Volatile:
+		return "volatile " + btfTypeName(t.Type)
+	}
+	if name := typ.TypeName(); name != "" {
+		return name
+	}
+	return "?"
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + markdownCell(s) + "`"
+}
+
+// markdownCell escapes s to be used in a table cell
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,166 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func docsMetadata() *metadatav1.GadgetMetadata {
+	return &metadatav1.GadgetMetadata{
+		Name:        "trace_open",
+		Description: "Trace open system calls",
+		LongDescription: `trace_open shows the files opened by the processes of the selected containers.
+
+Failed calls are shown too, with the error they returned.`,
+		DocumentationURL: "https://example.com/docs/trace_open",
+		SourceURL:        "https://example.com/src/trace_open",
+		Tracers: map[string]metadatav1.Tracer{
+			"open": {MapName: "events", StructName: "event"},
+		},
+		Snapshotters: map[string]metadatav1.Snapshotter{
+			"files": {StructName: "file"},
+		},
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"targ_failed": {ParamDesc: params.ParamDesc{
+				Key:          "failed",
+				DefaultValue: "false",
+				Description:  "Show only failed calls",
+				TypeHint:     params.TypeBool,
+			}},
+		},
+		GadgetParams: map[string]params.ParamDesc{
+			"paths": {
+				Alias:       "p",
+				Description: "Paths to trace, separated by |",
+			},
+		},
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "pid", Description: "Process ID", Examples: []string{"1234"}},
+					{Name: "comm", Description: "Command name", Examples: []string{"cat", "bash"}},
+					{Name: "fd", Description: "File descriptor", Deprecated: true},
+					{Name: "kind", Description: "Kind of access", Source: `fd < 0 ? "failed" : "ok"`},
+				},
+			},
+			"file": {
+				Fields: []metadatav1.Field{
+					{Name: "ino", Description: "Inode number"},
+				},
+				Trailer: &metadatav1.Trailer{Name: "path", Type: metadatav1.TrailerTypeString, LengthField: "path_len"},
+			},
+		},
+	}
+}
+
+func docsSpec(t *testing.T) *ebpf.CollectionSpec {
+	types := []btf.Type{
+		&btf.Struct{Name: "event", Size: 24, Members: []btf.Member{
+			{Name: "pid", Type: u32Type},
+			{Name: "comm", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 16}, Offset: 32},
+			{Name: "fd", Type: s32Type, Offset: 160},
+		}},
+		&btf.Struct{Name: "file", Size: 16, Members: []btf.Member{
+			{Name: "ino", Type: &btf.Int{Name: "unsigned long long", Size: 8}},
+			{Name: "path_len", Type: u32Type, Offset: 64},
+		}},
+	}
+
+	return newTestSpec(t, types...)
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	t.Parallel()
+
+	const goldenPath = "../../../../testdata/generate_markdown_trace_open.md.golden"
+
+	m := docsMetadata()
+	spec := docsSpec(t)
+
+	out, err := GenerateMarkdown(m, WithSpec(spec))
+	require.NoError(t, err)
+
+	again, err := GenerateMarkdown(m, WithSpec(spec))
+	require.NoError(t, err)
+	require.Equal(t, string(out), string(again))
+
+	golden, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(out))
+}
+
+func TestGenerateMarkdownWithoutSpec(t *testing.T) {
+	t.Parallel()
+
+	out, err := GenerateMarkdown(docsMetadata())
+	require.NoError(t, err)
+	require.Contains(t, string(out), "| Field | Description | Examples |\n")
+	require.Contains(t, string(out), "| `comm` | Command name | `cat`, `bash` |\n")
+}
+
+func TestGenerateMarkdownSensitiveParam(t *testing.T) {
+	t.Parallel()
+
+	m := docsMetadata()
+	m.EBPFParams["targ_user"] = metadatav1.EBPFParam{
+		ParamDesc: params.ParamDesc{Key: "user", DefaultValue: "alice", Description: "User to match"},
+		Sensitive: true,
+	}
+
+	out, err := GenerateMarkdown(m)
+	require.NoError(t, err)
+	require.Contains(t, string(out), "| `--user` | string | `***` | User to match |\n")
+	require.NotContains(t, string(out), "alice")
+}
+
+func TestGenerateMarkdownUnknownField(t *testing.T) {
+	t.Parallel()
+
+	m := docsMetadata()
+	m.Structs["file"] = metadatav1.Struct{Fields: []metadatav1.Field{{Name: "dev"}}}
+
+	_, err := GenerateMarkdown(m, WithSpec(docsSpec(t)))
+	require.ErrorContains(t, err, `struct "file": field "dev" not found in eBPF struct`)
+}
+
+func TestDocsRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	m := docsMetadata()
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+
+	var got metadatav1.GadgetMetadata
+	require.NoError(t, yaml.Unmarshal(out, &got))
+	require.Equal(t, m.LongDescription, got.LongDescription)
+	require.Equal(t, m.DocURL(), got.DocURL())
+
+	field, ok := got.Field("event", "comm")
+	require.True(t, ok)
+	require.Equal(t, []string{"cat", "bash"}, field.Examples)
+
+	_, ok = got.Field("event", "unknown")
+	require.False(t, ok)
+}
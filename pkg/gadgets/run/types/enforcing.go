@@ -0,0 +1,86 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// validateEnforcing checks the programs that can deny operations: they have to
+// be LSM programs whose mode is switched by a boolean eBPF param, so they can
+// run in audit mode
+func validateEnforcing(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Programs) {
+		program := m.Programs[name]
+		if !program.Enforcing && program.EnforceParam == "" {
+			continue
+		}
+		if err := validateEnforcingProgram(m, idx, name, program); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating program %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+func validateEnforcingProgram(m *metadatav1.GadgetMetadata, idx *btfIndex, name string, program metadatav1.Program) (result error) {
+	// Missing programs are reported by validatePrograms
+	if p, ok := idx.spec.Programs[name]; ok && p.Type != ebpf.LSM {
+		result = multierror.Append(result, fmt.Errorf("program %q is %s with section %q, only LSM programs can be enforcing",
+			name, p.Type, p.SectionName))
+	}
+
+	if !program.Enforcing {
+		return multierror.Append(result, errors.New("enforceParam requires enforcing to be set"))
+	}
+	if program.EnforceParam == "" {
+		return multierror.Append(result, errors.New("enforcing programs require enforceParam, to switch between audit and enforce modes"))
+	}
+
+	varName, ok := ebpfParamVar(m, program.EnforceParam)
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("enforceParam: eBPF param %q not found", program.EnforceParam))
+	}
+	btfVar, err := idx.varByName(varName)
+	if err != nil {
+		return multierror.Append(result, fmt.Errorf("enforceParam: looking for variable %q: %w", varName, err))
+	}
+	if btfhelpers.GetTypeHint(btfVar.Type) != params.TypeBool {
+		result = multierror.Append(result, fmt.Errorf("enforceParam: variable %q must be a bool", varName))
+	}
+
+	return
+}
+
+// ebpfParamVar returns the name of the variable backing the eBPF param with
+// the given key
+func ebpfParamVar(m *metadatav1.GadgetMetadata, key string) (string, bool) {
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		if m.EBPFParams[varName].Key == key {
+			return varName, true
+		}
+	}
+	return "", false
+}
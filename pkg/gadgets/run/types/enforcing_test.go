@@ -0,0 +1,133 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func enforcingSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	spec := newTestBTF(t,
+		constVar("enforce", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("max_denials", u32Type),
+	)
+
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"check_exec": {
+				Name:        "check_exec",
+				Type:        ebpf.LSM,
+				SectionName: "lsm/bprm_check_security",
+				AttachTo:    "bprm_check_security",
+			},
+			"do_unlinkat": {
+				Name:        "do_unlinkat",
+				Type:        ebpf.Kprobe,
+				SectionName: "kprobe/do_unlinkat",
+				AttachTo:    "do_unlinkat",
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestValidateEnforcing(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		programs          map[string]metadatav1.Program
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"enforcing": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Enforcing: true, EnforceParam: "enforce"},
+			},
+		},
+		"not_enforcing": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Optional: true},
+			},
+		},
+		"not_lsm": {
+			programs: map[string]metadatav1.Program{
+				"do_unlinkat": {Enforcing: true, EnforceParam: "enforce"},
+			},
+			expectedErrString: "program \"do_unlinkat\" is Kprobe with section \"kprobe/do_unlinkat\", only LSM programs can be enforcing",
+		},
+		"missing_enforce_param": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Enforcing: true},
+			},
+			expectedErrString: "enforcing programs require enforceParam",
+		},
+		"enforce_param_without_enforcing": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {EnforceParam: "enforce"},
+			},
+			expectedErrString: "enforceParam requires enforcing to be set",
+		},
+		"unknown_enforce_param": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Enforcing: true, EnforceParam: "deny"},
+			},
+			expectedErrString: "enforceParam: eBPF param \"deny\" not found",
+		},
+		"enforce_param_not_bool": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Enforcing: true, EnforceParam: "max-denials"},
+			},
+			expectedErrString: "enforceParam: variable \"max_denials\" must be a bool",
+		},
+		"missing_variable": {
+			programs: map[string]metadatav1.Program{
+				"check_exec": {Enforcing: true, EnforceParam: "mode"},
+			},
+			expectedErrString: "enforceParam: looking for variable \"enforce_mode\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Programs: test.programs,
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"enforce":      {ParamDesc: params.ParamDesc{Key: "enforce"}},
+					"max_denials":  {ParamDesc: params.ParamDesc{Key: "max-denials"}},
+					"enforce_mode": {ParamDesc: params.ParamDesc{Key: "mode"}},
+				},
+			}
+			err := validateEnforcing(m, newBTFIndex(enforcingSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
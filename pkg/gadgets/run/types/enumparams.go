@@ -0,0 +1,93 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// validateEnumParams checks the params backed by an enum: their possible
+// values must be names of its enumerators, and their default value and examples
+// the name or the number of one of them. The runtime rejects any other value.
+func validateEnumParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		btfVar, err := idx.varByName(varName)
+		if err != nil {
+			// Already reported by checkParamVar
+			continue
+		}
+		enum, ok := btfhelpers.AsEnum(btfVar.Type)
+		if !ok {
+			continue
+		}
+
+		p := m.EBPFParams[varName]
+		names := btfhelpers.EnumNames(enum)
+		for _, value := range p.PossibleValues {
+			if !slices.Contains(names, value) {
+				result = multierror.Append(result, fmt.Errorf("param %q: possible value %q isn't a value of enum %s",
+					varName, p.MaskValue(value), enum.Name))
+			}
+		}
+		for _, v := range checkedValues(&p) {
+			if _, ok := btfhelpers.EnumValue(enum, v.value); !ok {
+				result = multierror.Append(result, fmt.Errorf("param %q: %s %q isn't a value of enum %s",
+					varName, v.what, p.MaskValue(v.value), enum.Name))
+			} else if name, _ := enumName(enum, v.value); len(p.PossibleValues) > 0 && !slices.Contains(p.PossibleValues, name) {
+				result = multierror.Append(result, fmt.Errorf("param %q: %s %q isn't one of the possible values",
+					varName, v.what, p.MaskValue(v.value)))
+			}
+		}
+	}
+	return result
+}
+
+// enumName returns the name of the enumerator of enum named value or whose
+// number is value
+func enumName(enum *btf.Enum, value string) (string, bool) {
+	n, ok := btfhelpers.EnumValue(enum, value)
+	if !ok {
+		return "", false
+	}
+	for _, v := range enum.Values {
+		if v.Value == n {
+			return v.Name, true
+		}
+	}
+	return "", false
+}
+
+// enumZeroValue returns the name of the enumerator of enum whose number is 0,
+// the value of the variable if it isn't initialized, or the name of the first
+// one if there is none
+func enumZeroValue(enum *btf.Enum) string {
+	for _, v := range enum.Values {
+		if v.Value == 0 {
+			return v.Name
+		}
+	}
+	if len(enum.Values) > 0 {
+		return enum.Values[0].Name
+	}
+	return ""
+}
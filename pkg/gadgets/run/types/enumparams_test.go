@@ -0,0 +1,118 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// captureModeEnum is enum capture_mode { CAPTURE_NONE, CAPTURE_HEADERS, CAPTURE_FULL = 4 }
+var captureModeEnum = &btf.Enum{
+	Name: "capture_mode",
+	Size: 4,
+	Values: []btf.EnumValue{
+		{Name: "CAPTURE_NONE", Value: 0},
+		{Name: "CAPTURE_HEADERS", Value: 1},
+		{Name: "CAPTURE_FULL", Value: 4},
+	},
+}
+
+func enumParamsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	return newTestSpec(t, constVar("mode", captureModeEnum))
+}
+
+func TestValidateEnumParams(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		possibleValues    []string
+		defaultValue      string
+		examples          []string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"all_values": {
+			possibleValues: []string{"CAPTURE_NONE", "CAPTURE_HEADERS", "CAPTURE_FULL"},
+			defaultValue:   "CAPTURE_NONE",
+		},
+		"subset": {
+			possibleValues: []string{"CAPTURE_NONE", "CAPTURE_FULL"},
+			defaultValue:   "4",
+		},
+		"no_possible_values": {
+			defaultValue: "CAPTURE_HEADERS",
+		},
+		"unknown_possible_value": {
+			possibleValues:    []string{"CAPTURE_NONE", "CAPTURE_ALL"},
+			expectedErrString: "param \"mode\": possible value \"CAPTURE_ALL\" isn't a value of enum capture_mode",
+		},
+		"numeric_possible_value": {
+			possibleValues:    []string{"0", "1"},
+			expectedErrString: "param \"mode\": possible value \"0\" isn't a value of enum capture_mode",
+		},
+		"unknown_default": {
+			defaultValue:      "2",
+			expectedErrString: "param \"mode\": default value \"2\" isn't a value of enum capture_mode",
+		},
+		"examples": {
+			examples: []string{"CAPTURE_FULL", "1"},
+		},
+		"unknown_example": {
+			examples:          []string{"CAPTURE_ALL"},
+			expectedErrString: "param \"mode\": example \"CAPTURE_ALL\" isn't a value of enum capture_mode",
+		},
+		"default_not_possible": {
+			possibleValues:    []string{"CAPTURE_NONE", "CAPTURE_FULL"},
+			defaultValue:      "1",
+			expectedErrString: "param \"mode\": default value \"1\" isn't one of the possible values",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"mode": {
+						ParamDesc: params.ParamDesc{
+							Key:            "mode",
+							DefaultValue:   test.defaultValue,
+							PossibleValues: test.possibleValues,
+						},
+						Examples: test.examples,
+					},
+				},
+			}
+			err := validateEnumParams(m, newBTFIndex(enumParamsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
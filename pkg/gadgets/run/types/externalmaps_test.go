@@ -0,0 +1,127 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
+)
+
+const testExternalMapName = "types_test_sockets"
+
+type testProvider struct{}
+
+func (p *testProvider) ProviderName() string { return "test-enricher" }
+func (p *testProvider) MapName() string      { return testExternalMapName }
+func (p *testProvider) LayoutVersion() int   { return 2 }
+func (p *testProvider) MapSpec() (*ebpf.MapSpec, error) {
+	return &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16}, nil
+}
+func (p *testProvider) Acquire() (*ebpf.Map, error) { return nil, nil }
+func (p *testProvider) Release()                    {}
+
+func init() {
+	sharedmaps.Register(&testProvider{})
+}
+
+func TestValidateExternalMaps(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		externalMaps      map[string]metadatav1.ExternalMap
+		fields            []metadatav1.Field
+		valueSize         uint32
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"provider_matches": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				testExternalMapName: {LayoutVersion: 2, Provider: "test-enricher"},
+			},
+			fields: []metadatav1.Field{{Name: "comm", ExternalMap: testExternalMapName}},
+		},
+		"provider_not_available": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				"other_sockets": {LayoutVersion: 1, Provider: "socket-enricher"},
+			},
+		},
+		"other_provider": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				testExternalMapName: {LayoutVersion: 2, Provider: "socket-enricher"},
+			},
+			expectedErrString: "external map \"types_test_sockets\": provided by \"test-enricher\", not \"socket-enricher\"",
+		},
+		"other_layout_version": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				testExternalMapName: {LayoutVersion: 1},
+			},
+			expectedErrString: "layoutVersion is 1 but \"test-enricher\" provides version 2",
+		},
+		"missing_layout_version": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				testExternalMapName: {Provider: "test-enricher"},
+			},
+			expectedErrString: "layoutVersion is 0 but \"test-enricher\" provides version 2",
+		},
+		"layout_mismatch": {
+			externalMaps: map[string]metadatav1.ExternalMap{
+				testExternalMapName: {LayoutVersion: 2},
+			},
+			valueSize:         8,
+			expectedErrString: "layout doesn't match the one of \"test-enricher\": value size is 8, expected 16",
+		},
+		"field_map_not_declared": {
+			fields:            []metadatav1.Field{{Name: "comm", ExternalMap: testExternalMapName}},
+			expectedErrString: "field \"comm\" of struct \"event\": external map \"types_test_sockets\" not declared in externalMaps",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			valueSize := test.valueSize
+			if valueSize == 0 {
+				valueSize = 16
+			}
+			spec := &ebpf.CollectionSpec{
+				Maps: map[string]*ebpf.MapSpec{
+					testExternalMapName: {Type: ebpf.Hash, KeySize: 8, ValueSize: valueSize},
+					"other_sockets":     {Type: ebpf.Hash, KeySize: 8, ValueSize: 16},
+				},
+			}
+			m := &metadatav1.GadgetMetadata{
+				ExternalMaps: test.externalMaps,
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: test.fields},
+				},
+			}
+
+			err := validateExternalMaps(m, spec)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// helpParam is a param as shown in the help text
+type helpParam struct {
+	desc    params.ParamDesc
+	altKeys []string
+	// ebpf is set for eBPF params, giving their constraints
+	ebpf *metadatav1.EBPFParam
+}
+
+// HelpText returns the help of the params of the gadget described by m, one
+// block per param with its flags, type and description, followed by its
+// default value, constraints and examples, aligned on the descriptions.
+// Hidden params are left out and the values of sensitive params masked. The
+// output only depends on m, front-ends can show it as is.
+func HelpText(m *metadatav1.GadgetMetadata) string {
+	var ps []helpParam
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		p := m.EBPFParams[varName]
+		if p.Hidden {
+			continue
+		}
+		ps = append(ps, helpParam{desc: p.ParamDesc, altKeys: p.AltKeys, ebpf: &p})
+	}
+	for key, p := range m.GadgetParams {
+		if p.Key == "" {
+			p.Key = key
+		}
+		ps = append(ps, helpParam{desc: p})
+	}
+	if len(ps) == 0 {
+		return ""
+	}
+	sort.Slice(ps, func(i, j int) bool {
+		return ps[i].desc.Key < ps[j].desc.Key
+	})
+
+	heads := make([]string, len(ps))
+	width := 0
+	for i, p := range ps {
+		heads[i] = p.head()
+		width = max(width, len(heads[i]))
+	}
+	indent := strings.Repeat(" ", width+4)
+
+	var sb strings.Builder
+	for i, p := range ps {
+		line := fmt.Sprintf("  %-*s  %s", width, heads[i], p.desc.Description)
+		sb.WriteString(strings.TrimRight(line, " "))
+		sb.WriteString("\n")
+		for _, detail := range p.details() {
+			sb.WriteString(indent)
+			sb.WriteString(detail)
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
+
+// head returns the flags setting the param followed by its type, like
+// "--pid, -p uint32"
+func (p *helpParam) head() string {
+	flags := []string{"--" + p.desc.Key}
+	for _, altKey := range p.altKeys {
+		flags = append(flags, "--"+altKey)
+	}
+	if p.desc.Alias != "" {
+		flags = append(flags, "-"+p.desc.Alias)
+	}
+	typ := string(p.desc.TypeHint)
+	if typ == "" {
+		typ = string(params.TypeString)
+	}
+	return strings.Join(flags, ", ") + " " + typ
+}
+
+// details returns the lines shown under the description: the default value,
+// the constraints and the examples of the param
+func (p *helpParam) details() []string {
+	sensitive := p.ebpf != nil && p.ebpf.Sensitive
+
+	var details []string
+	if p.desc.DefaultValue != "" {
+		details = append(details, "Default: "+params.MaskValue(p.desc.DefaultValue, sensitive))
+	}
+	if constraints := p.constraints(); len(constraints) > 0 {
+		details = append(details, "Constraints: "+strings.Join(constraints, "; "))
+	}
+	if p.ebpf != nil && len(p.ebpf.Examples) > 0 {
+		examples := p.ebpf.Examples
+		if sensitive {
+			examples = []string{params.MaskedValue}
+		}
+		details = append(details, "Examples: "+strings.Join(examples, ", "))
+	}
+	return details
+}
+
+// constraints describes the values the param accepts
+func (p *helpParam) constraints() []string {
+	var constraints []string
+	if p.desc.IsMandatory {
+		constraints = append(constraints, "required")
+	}
+	if len(p.desc.PossibleValues) > 0 {
+		constraints = append(constraints, "one of "+strings.Join(p.desc.PossibleValues, ", "))
+	}
+
+	e := p.ebpf
+	if e == nil {
+		return constraints
+	}
+	if e.List {
+		constraints = append(constraints, "comma-separated list")
+	}
+	switch {
+	case e.Min != "" && e.Max != "":
+		constraints = append(constraints, fmt.Sprintf("between %s and %s", e.Min, e.Max))
+	case e.Min != "":
+		constraints = append(constraints, "at least "+e.Min)
+	case e.Max != "":
+		constraints = append(constraints, "at most "+e.Max)
+	}
+	if e.MaxLength > 0 {
+		constraints = append(constraints, fmt.Sprintf("at most %d bytes long", e.MaxLength))
+	}
+	if e.Pattern != "" {
+		constraints = append(constraints, "matching "+e.Pattern)
+	}
+	for _, key := range e.Requires {
+		constraints = append(constraints, "requires --"+key)
+	}
+	for _, key := range e.ConflictsWith {
+		constraints = append(constraints, "conflicts with --"+key)
+	}
+	return constraints
+}
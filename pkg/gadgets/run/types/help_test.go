@@ -0,0 +1,131 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func helpMetadata() *metadatav1.GadgetMetadata {
+	return &metadatav1.GadgetMetadata{
+		Name: "trace open",
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"targ_pid": {
+				ParamDesc: params.ParamDesc{
+					Key:          "pid",
+					Alias:        "p",
+					Description:  "Show only events of this process",
+					TypeHint:     params.TypeUint32,
+					DefaultValue: "0",
+				},
+				Min:           "0",
+				Max:           "4194304",
+				ConflictsWith: []string{"comm"},
+				Examples:      []string{"1", "4242"},
+			},
+			"targ_comm": {
+				ParamDesc: params.ParamDesc{
+					Key:         "comm",
+					Description: "Show only events of processes with this name",
+					TypeHint:    params.TypeString,
+				},
+				MaxLength: 15,
+				Pattern:   "^[a-z0-9_-]+$",
+				Examples:  []string{"nginx", "cat"},
+			},
+			"ports": {
+				ParamDesc: params.ParamDesc{
+					Key:         "ports",
+					Description: "Ports to trace",
+					TypeHint:    params.TypeString,
+				},
+				List:     true,
+				Min:      "1",
+				Examples: []string{"80,443"},
+			},
+			"targ_timeout_ms": {
+				ParamDesc: params.ParamDesc{
+					Key:          "timeout",
+					Description:  "Time to wait for the file to be closed",
+					TypeHint:     params.TypeDuration,
+					DefaultValue: "250ms",
+				},
+				TargetUnit: "ms",
+				AltKeys:    []string{"close-timeout"},
+				Requires:   []string{"latency"},
+				Examples:   []string{"1s"},
+			},
+			"targ_mode": {
+				ParamDesc: params.ParamDesc{
+					Key:            "mode",
+					Description:    "What to capture",
+					DefaultValue:   "CAPTURE_NONE",
+					PossibleValues: []string{"CAPTURE_NONE", "CAPTURE_HEADERS", "CAPTURE_FULL"},
+				},
+			},
+			"targ_user": {
+				ParamDesc: params.ParamDesc{
+					Key:          "user",
+					Description:  "Show only events of this user",
+					DefaultValue: "root",
+				},
+				Sensitive: true,
+				Examples:  []string{"alice"},
+			},
+			"targ_debug": {
+				ParamDesc: params.ParamDesc{Key: "debug", DefaultValue: "false", TypeHint: params.TypeBool},
+				Hidden:    true,
+			},
+			"targ_latency": {
+				ParamDesc: params.ParamDesc{
+					Key:          "latency",
+					Description:  "Measure how long files are kept open",
+					DefaultValue: "false",
+					TypeHint:     params.TypeBool,
+				},
+			},
+		},
+		GadgetParams: map[string]params.ParamDesc{
+			"path": {
+				Description: "Path to trace",
+				IsMandatory: true,
+			},
+		},
+	}
+}
+
+func TestHelpText(t *testing.T) {
+	t.Parallel()
+
+	const goldenPath = "../../../../testdata/help_text_trace_open.golden"
+
+	m := helpMetadata()
+	out := HelpText(m)
+	require.Equal(t, out, HelpText(m))
+
+	golden, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), out)
+	require.NotContains(t, out, "alice")
+	require.NotContains(t, out, "--debug")
+
+	require.Empty(t, HelpText(&metadatav1.GadgetMetadata{}))
+}
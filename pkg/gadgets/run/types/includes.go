@@ -0,0 +1,157 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// IncludeLoader returns the content of the metadata fragment ref points to
+type IncludeLoader func(ref string) ([]byte, error)
+
+// ResolveIncludes merges the fragments included by m, and the ones they
+// include, into m. The fragments are merged in order and m itself last, later
+// documents overriding earlier ones: struct fields are overridden by name, the
+// entries of the other sections by key and the gadget information when set.
+// Includes are cleared, so Validate and Populate see the resolved metadata.
+func ResolveIncludes(m *metadatav1.GadgetMetadata, loader IncludeLoader) error {
+	resolved, err := resolveIncludes(m, loader, nil)
+	if err != nil {
+		return err
+	}
+	*m = *resolved
+	return nil
+}
+
+func resolveIncludes(m *metadatav1.GadgetMetadata, loader IncludeLoader, stack []string) (*metadatav1.GadgetMetadata, error) {
+	resolved := &metadatav1.GadgetMetadata{}
+
+	for _, ref := range m.Includes {
+		// Don't share the backing array between the includes of m
+		path := append(stack[:len(stack):len(stack)], ref)
+		for _, including := range stack {
+			if including == ref {
+				return nil, fmt.Errorf("include cycle: %s", strings.Join(path, " -> "))
+			}
+		}
+
+		content, err := loader(ref)
+		if err != nil {
+			return nil, fmt.Errorf("loading include %q: %w", ref, err)
+		}
+		fragment := &metadatav1.GadgetMetadata{}
+		if err := yaml.Unmarshal(content, fragment); err != nil {
+			return nil, fmt.Errorf("decoding include %q: %w", ref, err)
+		}
+
+		fragment, err = resolveIncludes(fragment, loader, path)
+		if err != nil {
+			return nil, err
+		}
+		mergeMetadata(resolved, fragment)
+	}
+
+	mergeMetadata(resolved, m)
+	resolved.Includes = nil
+	return resolved, nil
+}
+
+// mergeMetadata merges src into dst, src overriding dst
+func mergeMetadata(dst, src *metadatav1.GadgetMetadata) {
+	for _, f := range []struct {
+		dst *string
+		src string
+	}{
+		{&dst.Name, src.Name},
+		{&dst.Description, src.Description},
+		{&dst.LongDescription, src.LongDescription},
+		{&dst.HomepageURL, src.HomepageURL},
+		{&dst.DocumentationURL, src.DocumentationURL},
+		{&dst.SourceURL, src.SourceURL},
+	} {
+		if f.src != "" {
+			*f.dst = f.src
+		}
+	}
+
+	dst.Annotations = mergeMaps(dst.Annotations, src.Annotations)
+	dst.Tracers = mergeMaps(dst.Tracers, src.Tracers)
+	dst.Toppers = mergeMaps(dst.Toppers, src.Toppers)
+	dst.Profilers = mergeMaps(dst.Profilers, src.Profilers)
+	dst.Snapshotters = mergeMaps(dst.Snapshotters, src.Snapshotters)
+	dst.EBPFParams = mergeMaps(dst.EBPFParams, src.EBPFParams)
+	dst.GadgetParams = mergeMaps(dst.GadgetParams, src.GadgetParams)
+	dst.ExternalMaps = mergeMaps(dst.ExternalMaps, src.ExternalMaps)
+	dst.Groups = mergeMaps(dst.Groups, src.Groups)
+	dst.Programs = mergeMaps(dst.Programs, src.Programs)
+	dst.Attach = mergeMaps(dst.Attach, src.Attach)
+	dst.Metrics = mergeMaps(dst.Metrics, src.Metrics)
+	dst.Alerts = mergeMaps(dst.Alerts, src.Alerts)
+	if src.MntNsFilter != nil {
+		dst.MntNsFilter = src.MntNsFilter
+	}
+
+	for _, name := range sortedKeys(src.Structs) {
+		if dst.Structs == nil {
+			dst.Structs = make(map[string]metadatav1.Struct)
+		}
+		dst.Structs[name] = mergeStruct(dst.Structs[name], src.Structs[name])
+	}
+}
+
+func mergeMaps[T any](dst, src map[string]T) map[string]T {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]T, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// mergeStruct overrides the fields of dst with the ones of src with the same
+// name, keeping their position, and appends the other ones
+func mergeStruct(dst, src metadatav1.Struct) metadatav1.Struct {
+	merged := metadatav1.Struct{
+		Fields:  append([]metadatav1.Field(nil), dst.Fields...),
+		Trailer: dst.Trailer,
+	}
+	if src.Trailer != nil {
+		merged.Trailer = src.Trailer
+	}
+
+	positions := make(map[string]int, len(merged.Fields))
+	for i, field := range merged.Fields {
+		positions[field.Name] = i
+	}
+	for _, field := range src.Fields {
+		if i, ok := positions[field.Name]; ok {
+			merged.Fields[i] = field
+			continue
+		}
+		positions[field.Name] = len(merged.Fields)
+		merged.Fields = append(merged.Fields, field)
+	}
+
+	return merged
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestResolveIncludes(t *testing.T) {
+	t.Parallel()
+
+	const testdata = "../../../../testdata"
+
+	original, err := os.ReadFile(filepath.Join(testdata, "include_gadget.yaml"))
+	require.NoError(t, err)
+	expected, err := os.ReadFile(filepath.Join(testdata, "include_gadget_expected.yaml"))
+	require.NoError(t, err)
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal(original, m))
+
+	loader := func(ref string) ([]byte, error) {
+		return os.ReadFile(filepath.Join(testdata, ref))
+	}
+	require.NoError(t, ResolveIncludes(m, loader))
+
+	out, err := Marshal(m)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+}
+
+func TestResolveIncludesOrder(t *testing.T) {
+	t.Parallel()
+
+	fragments := map[string]string{
+		"base.yaml": `
+description: base
+structs:
+  event:
+    fields:
+    - name: pid
+      description: from base
+    - name: comm
+      description: from base
+gadgetParams:
+  verbose:
+    key: verbose
+    description: from base
+`,
+		"override.yaml": `
+includes:
+- nested.yaml
+structs:
+  event:
+    fields:
+    - name: comm
+      description: from override
+`,
+		"nested.yaml": `
+description: nested
+gadgetParams:
+  verbose:
+    key: verbose
+    description: from nested
+`,
+	}
+	loader := func(ref string) ([]byte, error) {
+		fragment, ok := fragments[ref]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		return []byte(fragment), nil
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Name:     "gadget",
+		Includes: []string{"base.yaml", "override.yaml"},
+		Structs: map[string]metadatav1.Struct{
+			"event": {Fields: []metadatav1.Field{{Name: "pid", Description: "from gadget"}}},
+		},
+	}
+	require.NoError(t, ResolveIncludes(m, loader))
+
+	require.Empty(t, m.Includes)
+	require.Equal(t, "gadget", m.Name)
+	require.Equal(t, "nested", m.Description)
+	require.Equal(t, "from nested", m.GadgetParams["verbose"].Description)
+	require.Equal(t, []metadatav1.Field{
+		{Name: "pid", Description: "from gadget"},
+		{Name: "comm", Description: "from override"},
+	}, m.Structs["event"].Fields)
+}
+
+func TestResolveIncludesErrors(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		includes          []string
+		fragments         map[string]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"not_found": {
+			includes:          []string{"missing.yaml"},
+			expectedErrString: "loading include \"missing.yaml\": not found",
+		},
+		"invalid": {
+			includes:          []string{"bad.yaml"},
+			fragments:         map[string]string{"bad.yaml": "structs: [1, 2]"},
+			expectedErrString: "decoding include \"bad.yaml\"",
+		},
+		"self": {
+			includes:          []string{"a.yaml"},
+			fragments:         map[string]string{"a.yaml": "includes: [a.yaml]"},
+			expectedErrString: "include cycle: a.yaml -> a.yaml",
+		},
+		"cycle": {
+			includes: []string{"a.yaml"},
+			fragments: map[string]string{
+				"a.yaml": "includes: [b.yaml]",
+				"b.yaml": "includes: [c.yaml]",
+				"c.yaml": "includes: [a.yaml]",
+			},
+			expectedErrString: "include cycle: a.yaml -> b.yaml -> c.yaml -> a.yaml",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			loader := func(ref string) ([]byte, error) {
+				fragment, ok := test.fragments[ref]
+				if !ok {
+					return nil, errors.New("not found")
+				}
+				return []byte(fragment), nil
+			}
+			m := &metadatav1.GadgetMetadata{Includes: test.includes}
+			require.ErrorContains(t, ResolveIncludes(m, loader), test.expectedErrString)
+		})
+	}
+}
+
+func TestResolveIncludesShared(t *testing.T) {
+	t.Parallel()
+
+	// Including the same fragment twice through different paths isn't a cycle
+	fragments := map[string]string{
+		"a.yaml":      "includes: [common.yaml]",
+		"b.yaml":      "includes: [common.yaml]",
+		"common.yaml": "description: common",
+	}
+	loader := func(ref string) ([]byte, error) {
+		return []byte(fragments[ref]), nil
+	}
+
+	m := &metadatav1.GadgetMetadata{Includes: []string{"a.yaml", "b.yaml"}}
+	require.NoError(t, ResolveIncludes(m, loader))
+	require.Equal(t, "common", m.Description)
+}
@@ -0,0 +1,209 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+)
+
+// Marker is a variable generated by a GADGET_ macro declaring a tracer, a
+// topper, a profiler, a snapshotter or an eBPF param
+type Marker struct {
+	// Var is the name of the variable generated by the macro
+	Var string
+	// Name of the tracer, topper, profiler, snapshotter or param
+	Name string
+	// Map is the map used by tracers, toppers, profilers and snapshotters
+	// dumping a map
+	Map string
+	// Type is the name of the struct generated by tracers and snapshotters
+	Type string
+	// Programs are the iterators used by snapshotters
+	Programs []string
+	// Err is why the marker is malformed. Only Var is set then.
+	Err error
+}
+
+// Markers are the markers of an eBPF object, in the order of its BTF
+type Markers struct {
+	Tracers      []Marker
+	Toppers      []Marker
+	Profilers    []Marker
+	Snapshotters []Marker
+	Params       []Marker
+}
+
+// ScanMarkers returns the markers of the gadget in spec, including the
+// malformed ones
+func ScanMarkers(spec *ebpf.CollectionSpec) (*Markers, error) {
+	if spec.Types == nil {
+		return nil, errors.New("eBPF object has no BTF")
+	}
+	return newBTFIndex(spec).markers, nil
+}
+
+// scanMarkers sorts the marker variables into categories and parses them
+func scanMarkers(vars []*btf.Var) *Markers {
+	markers := &Markers{}
+
+	for _, v := range vars {
+		switch {
+		case strings.HasPrefix(v.Name, tracerInfoPrefix):
+			markers.Tracers = append(markers.Tracers, newMarker(v, tracerInfoPrefix, parseTracerMarker))
+		case strings.HasPrefix(v.Name, topperInfoPrefix):
+			markers.Toppers = append(markers.Toppers, newMarker(v, topperInfoPrefix, parseTopperMarker))
+		case strings.HasPrefix(v.Name, profilerInfoPrefix):
+			markers.Profilers = append(markers.Profilers, newMarker(v, profilerInfoPrefix, parseProfilerMarker))
+		case strings.HasPrefix(v.Name, snapshottersPrefix):
+			markers.Snapshotters = append(markers.Snapshotters, newMarker(v, snapshottersPrefix, parseSnapshotterMarker))
+		case strings.HasPrefix(v.Name, mapSnapshotterPrefix):
+			markers.Snapshotters = append(markers.Snapshotters, newMarker(v, mapSnapshotterPrefix, parseMapSnapshotterMarker))
+		case strings.HasPrefix(v.Name, paramPrefix) && !isParamMarker(v.Name):
+			markers.Params = append(markers.Params, newMarker(v, paramPrefix, parseParamMarker))
+		}
+	}
+
+	return markers
+}
+
+func newMarker(v *btf.Var, prefix string, parse func(*Marker, string) error) Marker {
+	if err := checkMarkerVar(v); err != nil {
+		return Marker{Var: v.Name, Err: err}
+	}
+
+	marker := Marker{Var: v.Name}
+	if err := parse(&marker, strings.TrimPrefix(v.Name, prefix)); err != nil {
+		return Marker{Var: v.Name, Err: err}
+	}
+	return marker
+}
+
+// checkMarkerVar checks that v was generated by a GADGET_ macro: a global
+// const void pointer
+func checkMarkerVar(v *btf.Var) error {
+	var result error
+
+	if v.Linkage != btf.GlobalVar {
+		result = multierror.Append(result, fmt.Errorf("%q is not a global variable", v.Name))
+	}
+
+	btfPtr, ok := v.Type.(*btf.Pointer)
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("%q is not a pointer", v.Name))
+	}
+	btfConst, ok := btfPtr.Target.(*btf.Const)
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("%q is not const", v.Name))
+	}
+	if _, ok := btfConst.Type.(*btf.Void); !ok {
+		return multierror.Append(result, fmt.Errorf("%q is not a const void pointer", v.Name))
+	}
+
+	return result
+}
+
+// parseTracerMarker parses the identifier generated by GADGET_TRACER():
+// <name>___<mapName>___<structName>
+func parseTracerMarker(marker *Marker, ident string) error {
+	parts := strings.Split(ident, "___")
+	if len(parts) != 3 {
+		return fmt.Errorf("invalid tracer info: %q", ident)
+	}
+	marker.Name, marker.Map, marker.Type = parts[0], parts[1], parts[2]
+	return nil
+}
+
+// parseTopperMarker parses the identifier generated by GADGET_TOPPER():
+// <name>___<mapName>
+func parseTopperMarker(marker *Marker, ident string) error {
+	parts := strings.Split(ident, "___")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid topper info: %q", ident)
+	}
+	marker.Name, marker.Map = parts[0], parts[1]
+	return nil
+}
+
+// parseProfilerMarker parses the identifier generated by GADGET_PROFILER():
+// <name>___<mapName>
+func parseProfilerMarker(marker *Marker, ident string) error {
+	parts := strings.Split(ident, "___")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid profiler info: %q", ident)
+	}
+	marker.Name, marker.Map = parts[0], parts[1]
+	return nil
+}
+
+// parseSnapshotterMarker parses the identifier generated by
+// GADGET_SNAPSHOTTER(): <name>___<structName>___<program1>___...___<programN>
+func parseSnapshotterMarker(marker *Marker, ident string) error {
+	parts := strings.Split(ident, "___")
+	if len(parts) < 3 {
+		// At least one program is required
+		return fmt.Errorf("invalid snapshotter definition, expected format: <name>___<structName>___<program1>___...___<programN>, got %q",
+			ident)
+	}
+	marker.Name, marker.Type, marker.Programs = parts[0], parts[1], parts[2:]
+	return nil
+}
+
+// parseMapSnapshotterMarker parses the identifier generated by
+// GADGET_MAP_SNAPSHOTTER(): <name>___<mapName>
+func parseMapSnapshotterMarker(marker *Marker, ident string) error {
+	parts := strings.Split(ident, "___")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid map snapshotter info: %q", ident)
+	}
+	marker.Name, marker.Map = parts[0], parts[1]
+	return nil
+}
+
+// parseParamMarker parses the identifier generated by GADGET_PARAM(): the name
+// of the variable holding the value of the param
+func parseParamMarker(marker *Marker, ident string) error {
+	marker.Name = ident
+	return nil
+}
+
+// firstMarker returns the first of the markers of a kind, as gadgets can only
+// have one of each. Malformed markers are errors even if they aren't the
+// first one.
+func firstMarker(markers []Marker, kind string) (*Marker, error) {
+	var result error
+	for _, marker := range markers {
+		if marker.Err != nil {
+			result = multierror.Append(result, marker.Err)
+		}
+	}
+	if result != nil {
+		return nil, result
+	}
+
+	if len(markers) == 0 {
+		return nil, nil
+	}
+	if len(markers) > 1 {
+		log.Warnf("multiple %ss found, using %q", kind, markers[0].Name)
+	}
+	return &markers[0], nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanMarkers(t *testing.T) {
+	t.Parallel()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	types := []btf.Type{
+		&btf.Var{Name: "gadget_tracer_exec___events___event", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_tracer_bad___events", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_topper_top___stats", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_snapshotter_procs___proc___iter1___iter2", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_limit", Type: constVoidPtr, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_default_limit", Type: &btf.Const{Type: u32}, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_not_pointer", Type: u32, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_not_const", Type: &btf.Pointer{Target: &btf.Void{}}, Linkage: btf.GlobalVar},
+		&btf.Var{Name: "gadget_param_static", Type: constVoidPtr, Linkage: btf.StaticVar},
+	}
+
+	markers, err := ScanMarkers(newTestSpec(t, types...))
+	require.NoError(t, err)
+
+	require.Len(t, markers.Tracers, 2)
+	require.Equal(t, Marker{
+		Var:  "gadget_tracer_exec___events___event",
+		Name: "exec",
+		Map:  "events",
+		Type: "event",
+	}, markers.Tracers[0])
+	require.Equal(t, "gadget_tracer_bad___events", markers.Tracers[1].Var)
+	require.EqualError(t, markers.Tracers[1].Err, `invalid tracer info: "bad___events"`)
+
+	require.Equal(t, []Marker{{
+		Var:  "gadget_topper_top___stats",
+		Name: "top",
+		Map:  "stats",
+	}}, markers.Toppers)
+
+	require.Equal(t, []Marker{{
+		Var:      "gadget_snapshotter_procs___proc___iter1___iter2",
+		Name:     "procs",
+		Type:     "proc",
+		Programs: []string{"iter1", "iter2"},
+	}}, markers.Snapshotters)
+
+	// Each malformed marker has its own error
+	require.Len(t, markers.Params, 4)
+	require.Equal(t, Marker{Var: "gadget_param_limit", Name: "limit"}, markers.Params[0])
+
+	notPointer := markers.Params[1]
+	require.Equal(t, "gadget_param_not_pointer", notPointer.Var)
+	require.ErrorContains(t, notPointer.Err, `"gadget_param_not_pointer" is not a pointer`)
+	require.NotContains(t, notPointer.Err.Error(), "not_const")
+
+	notConst := markers.Params[2]
+	require.Equal(t, "gadget_param_not_const", notConst.Var)
+	require.ErrorContains(t, notConst.Err, `"gadget_param_not_const" is not const`)
+	require.NotContains(t, notConst.Err.Error(), "not_pointer")
+
+	static := markers.Params[3]
+	require.Equal(t, "gadget_param_static", static.Var)
+	require.ErrorContains(t, static.Err, `"gadget_param_static" is not a global variable`)
+
+	_, err = ScanMarkers(&ebpf.CollectionSpec{})
+	require.Error(t, err)
+}
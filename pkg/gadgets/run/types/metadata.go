@@ -17,7 +17,12 @@ package types
 import (
 	"errors"
 	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
@@ -26,9 +31,16 @@ import (
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/compat"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/expr"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/annotations"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/errno"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/redact"
 )
 
 // Keep this aligned with include/gadget/macros.h
@@ -39,12 +51,27 @@ const (
 	// Prefix used to mark topper maps
 	topperInfoPrefix = "gadget_topper_"
 
+	// Prefix used to mark profiler maps
+	profilerInfoPrefix = "gadget_profiler_"
+
 	// Prefix used to mark eBPF params
 	paramPrefix = "gadget_param_"
 
+	// Prefixes used to set the default value and description of eBPF params,
+	// and to make them mandatory
+	paramDefaultPrefix   = "gadget_param_default_"
+	paramDescPrefix      = "gadget_param_desc_"
+	paramMandatoryPrefix = "gadget_param_mandatory_"
+
+	// Prefix of the strings set by GADGET_NAME and GADGET_DESCRIPTION
+	gadgetInfoPrefix = "gadget_info_"
+
 	// Prefix used to mark snapshotters structs
 	snapshottersPrefix = "gadget_snapshotter_"
 
+	// Prefix used to mark snapshotters dumping a map
+	mapSnapshotterPrefix = "gadget_map_snapshotter_"
+
 	// Prefix used to mark tracer map created with GADGET_TRACER_MAP() defined in
 	// include/gadget/buffer.h.
 	TracerMapPrefix = "gadget_map_tracer_"
@@ -53,10 +80,184 @@ const (
 const (
 	// Name of the parameter that defins the network interface a TC program is attached to.
 	IfaceParam = "iface"
+
+	// Placeholders for the information about the gadget, they're replaced by
+	// the one set in the eBPF code
+	gadgetNameTODO             = "TODO: Fill the gadget name"
+	gadgetDescriptionTODO      = "TODO: Fill the gadget description"
+	gadgetHomepageURLTODO      = "TODO: Fill the gadget homepage URL"
+	gadgetDocumentationURLTODO = "TODO: Fill the gadget documentation URL"
+	gadgetSourceURLTODO        = "TODO: Fill the gadget source code URL"
+
+	// Descriptions of params and fields without one, they're replaced by the
+	// ones set in the eBPF code
+	paramDescTODO = "TODO: Fill parameter description"
+	fieldDescTODO = "TODO: Fill field description"
+
+	// Prefix of the decl tags used to describe fields and params
+	descTagPrefix = "ig:desc="
+
+	// Prefix of the decl tags used to set the enum with the names of the flags
+	// of an integer member, flagsTagNone disables it
+	flagsTagPrefix = "ig:flags="
+	flagsTagNone   = "none"
+)
+
+const (
+	// Maximum default width for columns showing char arrays. Longer arrays
+	// get their full length as MaxWidth instead.
+	maxCharArrayColumnWidth = 32
+
+	// Maximum nesting level of structs flattened into dotted field names
+	maxStructFlattenDepth = 3
+
+	// Decimals shown for float fields without a precision, like the columns
+	// package does
+	defaultFloatPrecision = 2
+
+	// Width of pointers shown in hex: "0x" and 16 digits
+	pointerColumnWidth = 18
+
+	// Width of 128-bit integers: 39 digits for the maximum unsigned value and
+	// "0x" and 32 digits in hex
+	int128ColumnWidth    = 39
+	int128HexColumnWidth = 34
+
+	// Width of IPv4 and IPv6 addresses, see "ipaddr" template
+	ipv4ColumnWidth = 15
+	ipv6ColumnWidth = 45
+
+	// Width of MAC addresses: six bytes in hex separated by colons, see
+	// "macaddr" template
+	macAddrColumnWidth = 17
+)
+
+const (
+	// macAddrTemplate is the template of fields showing a MAC address
+	macAddrTemplate = "macaddr"
+	// macAddrLen is the size of a MAC address in bytes
+	macAddrLen = 6
+)
+
+// Templates of user and group ids, resolved to names at runtime by the
+// uidgidresolver operator
+const (
+	uidTemplate = "uid"
+	gidTemplate = "gid"
+)
+
+const (
+	// syscallTemplate shows integers as the name of the syscall with that
+	// number, see the syscalls package
+	syscallTemplate = "syscall"
+	// Width of syscall names, see "syscall" template
+	syscallColumnWidth = 18
+)
+
+const (
+	// signalTemplate shows integers as the name of the signal, see the
+	// signals package
+	signalTemplate = "signal"
+	// capabilitiesTemplate shows 64-bit masks as the names of the
+	// capabilities set, see the capabilities package
+	capabilitiesTemplate = "capabilities"
+)
+
+const (
+	// durationTemplate shows integer durations in a compact form, like
+	// 1.24ms. The unit attribute sets the unit of the raw values, nanoseconds
+	// by default, and precision the maximum number of decimals.
+	durationTemplate = datasource.DurationTemplate
+)
+
+const (
+	// errnoTemplate shows negative values of signed integers as the name of
+	// the error, like EACCES
+	errnoTemplate = "errno"
+	// errnoRawSuffix is appended to the name of the field to get the key
+	// holding the numeric value of errno fields
+	errnoRawSuffix = "_raw"
 )
 
+// Annotations recording the layout of bitfields, in bits from the start of
+// the struct
+const (
+	bitfieldOffsetAnnotation = "ebpf.bitfield.offset"
+	bitfieldSizeAnnotation   = "ebpf.bitfield.size"
+)
+
+// endpointAnnotation marks L3 and L4 endpoint fields, which are enriched as a
+// whole by the formatters operator. Its value is the kind of the endpoint.
+const endpointAnnotation = "ebpf.endpoint"
+
+// pointerAnnotation tells whether a pointer field holds a kernel or a user
+// space address, so the address can be resolved to a symbol
+const (
+	pointerAnnotation = "ebpf.pointer"
+	pointerKernel     = "kernel"
+	pointerUser       = "user"
+)
+
+// int128Annotation sets how 128-bit members (__int128, 16-byte arrays of
+// integers and in6_addr) are decoded: as a number, as raw bytes or as an IPv6
+// address
+const (
+	int128Annotation = "ebpf.int128"
+	int128Number     = "number"
+	int128Bytes      = "bytes"
+	int128IPv6       = "ipv6"
+)
+
+// endpointKind describes how fields holding an endpoint are shown
+type endpointKind struct {
+	name     string
+	template string
+	width    uint
+}
+
+// Column width for endpoints:
+// IPv6 address (45, see "ipaddr" template) + ":" + port (5)
+var endpointKinds = map[string]endpointKind{
+	"gadget_l3endpoint_t": {name: "l3", template: "ipaddr", width: 45},
+	"gadget_l4endpoint_t": {name: "l4", template: "l4endpoint", width: 51},
+}
+
+// Root fields added by the enrichment, see pkg/datasource/compat
+var enrichmentFields = []string{"k8s", "runtime"}
+
+// Structs that are formatted as a whole and hence aren't flattened. Keep this
+// aligned with include/gadget/types.h
+var opaqueStructs = map[string]struct{}{
+	"gadget_l3endpoint_t": {},
+	"gadget_l4endpoint_t": {},
+	// Shown as an IPv6 address, see int128Annotation
+	"in6_addr": {},
+}
+
+// validatePlaceholders checks that the information about the gadget isn't the
+// placeholder set by Populate
+func validatePlaceholders(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	for _, info := range []struct {
+		name, value, placeholder string
+	}{
+		{"name", m.Name, gadgetNameTODO},
+		{"description", m.Description, gadgetDescriptionTODO},
+		{"homepageURL", m.HomepageURL, gadgetHomepageURLTODO},
+		{"documentationURL", m.DocumentationURL, gadgetDocumentationURLTODO},
+		{"sourceURL", m.SourceURL, gadgetSourceURLTODO},
+	} {
+		if info.value == info.placeholder {
+			result = multierror.Append(result, fmt.Errorf("%s is still %q", info.name, info.placeholder))
+		}
+	}
+
+	return result
+}
+
 // countDistImp returns the number of distinct implementations of tracers,
-// snapshotters and toppers that the gadget has.
+// snapshotters, toppers and profilers that the gadget has.
 func countDistImp(m *metadatav1.GadgetMetadata) int {
 	count := 0
 	if len(m.Tracers) > 0 {
@@ -68,29 +269,123 @@ func countDistImp(m *metadatav1.GadgetMetadata) int {
 	if len(m.Toppers) > 0 {
 		count++
 	}
+	if len(m.Profilers) > 0 {
+		count++
+	}
 	return count
 }
 
-func Validate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+type validateOptions struct {
+	strict             bool
+	reservedShorthands []string
+	maxParamSize       uint32
+}
+
+// ValidateOption configures Validate
+type ValidateOption func(*validateOptions)
+
+// WithStrict makes Validate fail if the information about the gadget, like
+// its name or description, is still the placeholder set by Populate
+func WithStrict() ValidateOption {
+	return func(o *validateOptions) {
+		o.strict = true
+	}
+}
+
+// WithReservedShorthands makes Validate fail if the alias of a param is one
+// of the given shorthands, already used by the flags of the command running
+// the gadget
+func WithReservedShorthands(shorthands ...string) ValidateOption {
+	return func(o *validateOptions) {
+		o.reservedShorthands = append(o.reservedShorthands, shorthands...)
+	}
+}
+
+// WithMaxParamSize makes Validate fail if a variable backing a param is larger
+// than size bytes, instead of DefaultMaxParamSize. 0 means no limit.
+func WithMaxParamSize(size uint32) ValidateOption {
+	return func(o *validateOptions) {
+		o.maxParamSize = size
+	}
+}
+
+func Validate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, opts ...ValidateOption) error {
+	o := validateOptions{maxParamSize: DefaultMaxParamSize}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	var result error
 
 	if m.Name == "" {
 		result = multierror.Append(result, errors.New("gadget name is required"))
 	}
 
+	if o.strict {
+		if err := validatePlaceholders(m); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
 	// Temporary limitation
 	if count := countDistImp(m); count > 1 {
 		result = multierror.Append(
 			result,
-			fmt.Errorf("gadget can implement only one tracer or snapshotter or topper, found %d", count),
+			fmt.Errorf("gadget can implement only one tracer or snapshotter or topper or profiler, found %d", count),
 		)
 	}
 
-	if err := validateEbpfParams(m, spec); err != nil {
+	idx := newBTFIndex(spec)
+
+	if err := validateEbpfParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateParamConstraints(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateAddressParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateUnitParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateTargetMapParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateParamAliases(m, o.reservedShorthands); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateParamDependencies(m); err != nil {
 		result = multierror.Append(result, err)
 	}
 
-	if err := validateTracers(m, spec); err != nil {
+	if err := validateEnumParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateArrayParams(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateParamBudget(m, idx, o.maxParamSize); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateParamAttaches(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateTracers(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateTracerSelector(m); err != nil {
 		result = multierror.Append(result, err)
 	}
 
@@ -98,11 +393,15 @@ func Validate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
 		result = multierror.Append(result, err)
 	}
 
+	if err := validateProfilers(m, spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	if err := validateSnapshotters(m, spec); err != nil {
 		result = multierror.Append(result, err)
 	}
 
-	if err := validateStructs(m, spec); err != nil {
+	if err := validateStructs(m, idx); err != nil {
 		result = multierror.Append(result, err)
 	}
 
@@ -110,10 +409,54 @@ func Validate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
 		result = multierror.Append(result, err)
 	}
 
+	if err := validateMetrics(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateAlerts(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validatePrograms(m, spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateEnforcing(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateAttach(m, spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateAliases(m); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateOutputNames(m); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateDefaultColumns(m); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateGroups(m); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateExternalMaps(m, spec); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	if err := validateMntNsFilter(m, idx); err != nil {
+		result = multierror.Append(result, err)
+	}
+
 	return result
 }
 
-func validateTracers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+func validateTracers(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
 	var result error
 
 	// Temporary limitation
@@ -122,10 +465,25 @@ func validateTracers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) er
 	}
 
 	for name, t := range m.Tracers {
-		err := validateMapAndStruct(t.MapName, t.StructName, spec, m, validateTracerMap)
+		err := validateMapAndStruct(t.MapName, t.StructName, idx.spec, m, validateTracerMap)
 		if err != nil {
 			result = multierror.Append(result, fmt.Errorf("validating tracer %q: %w", name, err))
+			continue
+		}
+
+		if err := validateTracerVariants(t, idx); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating tracer %q: %w", name, err))
+		}
+
+		if err := validateTracerAggregation(m, t, idx); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating tracer %q: %w", name, err))
 		}
+
+		checkTracerProvenance(name, t, idx)
+	}
+
+	if err := validateAggregateFields(m, idx); err != nil {
+		result = multierror.Append(result, err)
 	}
 
 	return result
@@ -142,6 +500,29 @@ func validateTracerMap(tracerMap *ebpf.MapSpec, _ string) error {
 	return nil
 }
 
+// checkTracerProvenance warns when several programs write to the tracer map
+// but the event has no field telling which one produced it.
+func checkTracerProvenance(name string, t metadatav1.Tracer, idx *btfIndex) {
+	producers := gadgets.GetMapProducers(idx.spec, t.MapName)
+	if len(producers) <= 1 {
+		return
+	}
+
+	btfStruct, err := idx.structByName(t.StructName)
+	if err != nil {
+		return
+	}
+
+	for _, member := range btfStruct.Members {
+		if isSpecialType(member.Type, gadgets.ProgramIdTypeName) {
+			return
+		}
+	}
+
+	log.Warnf("Tracer %q: programs %s write to map %q but struct %q has no %q field to tell their events apart",
+		name, strings.Join(producers, ", "), t.MapName, t.StructName, gadgets.ProgramIdTypeName)
+}
+
 func validateToppers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
 	var result error
 
@@ -155,14 +536,19 @@ func validateToppers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) er
 		if err != nil {
 			result = multierror.Append(result, fmt.Errorf("validating topper %q: %w", name, err))
 		}
+		if err := validateTopperFields(t, spec); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating topper %q: %w", name, err))
+		}
 	}
 
 	return result
 }
 
 func validateTopperMap(topperMap *ebpf.MapSpec, expectedStructName string) error {
-	if topperMap.Type != ebpf.Hash {
-		return fmt.Errorf("map %q has a wrong type, expected: hash, got: %s",
+	switch topperMap.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	default:
+		return fmt.Errorf("map %q has a wrong type, expected: hash or lru hash (optionally per-CPU), got: %s",
 			topperMap.Name, topperMap.Type)
 	}
 
@@ -181,507 +567,3198 @@ func validateTopperMap(topperMap *ebpf.MapSpec, expectedStructName string) error
 			topperMap.Name, topperMapStruct.Name, expectedStructName)
 	}
 
+	if topperMap.Key == nil {
+		return fmt.Errorf("map %q does not have BTF information for its keys", topperMap.Name)
+	}
+
 	return nil
 }
 
-func validateSnapshotters(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	var result error
+// validateTopperFields checks the key struct, the sort field and the interval
+// of a topper. Errors about its map are reported by validateTopperMap.
+func validateTopperFields(t metadatav1.Topper, spec *ebpf.CollectionSpec) (result error) {
+	if t.Interval != "" {
+		if d, err := time.ParseDuration(t.Interval); err != nil || d <= 0 {
+			result = multierror.Append(result, fmt.Errorf("invalid interval %q, expected a positive duration like \"1s\"", t.Interval))
+		}
+	}
 
-	// Temporary limitation
-	if len(m.Snapshotters) > 1 {
-		result = multierror.Append(result, errors.New("only one snapshotter is allowed"))
+	topperMap, ok := spec.Maps[t.MapName]
+	if !ok {
+		return
 	}
 
-	for name, snapshotter := range m.Snapshotters {
-		if snapshotter.StructName == "" {
-			result = multierror.Append(result, fmt.Errorf("snapshotter %q is missing structName", name))
-			continue
+	if t.KeyStructName != "" {
+		if keyStruct, ok := topperMap.Key.(*btf.Struct); !ok || keyStruct.Name != t.KeyStructName {
+			name := "<none>"
+			if topperMap.Key != nil {
+				name = topperMap.Key.TypeName()
+			}
+			result = multierror.Append(result, fmt.Errorf("map %q key is %q, expected struct %q",
+				topperMap.Name, name, t.KeyStructName))
 		}
+	}
 
-		if _, ok := m.Structs[snapshotter.StructName]; !ok {
-			result = multierror.Append(result, fmt.Errorf("snapshotter %q references unknown struct %q", name, snapshotter.StructName))
+	valueStruct, ok := topperMap.Value.(*btf.Struct)
+	if !ok || t.SortField == "" {
+		return
+	}
+	member, ok := findMember(valueStruct.Members, t.SortField)
+	if !ok {
+		result = multierror.Append(result, fmt.Errorf("sortField %q not found in struct %q", t.SortField, valueStruct.Name))
+	} else if !isInteger(member) {
+		result = multierror.Append(result, fmt.Errorf("sortField %q must be an integer field", t.SortField))
+	}
+	return
+}
+
+// maxProfilerSlots is the number of slots needed to bucket any __u64 value
+const maxProfilerSlots = 64
+
+func validateProfilers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
+
+	// Temporary limitation
+	if len(m.Profilers) > 1 {
+		result = multierror.Append(result, errors.New("only one profiler is allowed"))
+	}
+
+	for name, p := range m.Profilers {
+		if err := validateProfiler(p, spec, m); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating profiler %q: %w", name, err))
 		}
 	}
 
 	return result
 }
 
-// validateMapAndStruct fully validates the map, while the struct is only
-// checked for existence in the Structs section of the metadata as it will be
-// validated with the rest of the structs.
-func validateMapAndStruct(mapName, structName string,
-	spec *ebpf.CollectionSpec,
-	m *metadatav1.GadgetMetadata,
-	validateMap func(*ebpf.MapSpec, string) error,
-) (result error) {
-	if mapName == "" {
-		result = multierror.Append(result, errors.New("missing mapName"))
-	} else {
-		ebpfMap, ok := spec.Maps[mapName]
-		if !ok {
-			return fmt.Errorf("map %q not found in eBPF object", mapName)
+func validateProfiler(p metadatav1.Profiler, spec *ebpf.CollectionSpec, m *metadatav1.GadgetMetadata) (result error) {
+	if p.Interval != "" {
+		if d, err := time.ParseDuration(p.Interval); err != nil || d <= 0 {
+			result = multierror.Append(result, fmt.Errorf("invalid interval %q, expected a positive duration like \"1s\"", p.Interval))
 		}
+	}
 
-		if err := validateMap(ebpfMap, structName); err != nil {
-			result = multierror.Append(result, err)
-		}
+	switch p.Unit {
+	case metadatav1.UnitNone, metadatav1.UnitBytes, metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds,
+		metadatav1.UnitMilliseconds, metadatav1.UnitCount:
+	default:
+		result = multierror.Append(result, fmt.Errorf("invalid unit %q", p.Unit))
 	}
 
-	if structName == "" {
-		result = multierror.Append(result, errors.New("missing structName"))
-	} else if _, ok := m.Structs[structName]; !ok {
-		result = multierror.Append(result, fmt.Errorf("referencing unknown struct %q", structName))
+	if p.MapName == "" {
+		return multierror.Append(result, errors.New("missing mapName"))
+	}
+	profilerMap, ok := spec.Maps[p.MapName]
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", p.MapName))
+	}
+	if err := validateProfilerMap(profilerMap); err != nil {
+		result = multierror.Append(result, err)
 	}
 
+	if p.KeyStructName != "" {
+		if keyStruct, ok := profilerMap.Key.(*btf.Struct); !ok || keyStruct.Name != p.KeyStructName {
+			name := "<none>"
+			if profilerMap.Key != nil {
+				name = profilerMap.Key.TypeName()
+			}
+			result = multierror.Append(result, fmt.Errorf("map %q key is %q, expected struct %q",
+				profilerMap.Name, name, p.KeyStructName))
+		} else if _, ok := m.Structs[p.KeyStructName]; !ok {
+			result = multierror.Append(result, fmt.Errorf("referencing unknown struct %q", p.KeyStructName))
+		}
+	}
 	return
 }
 
-func validateStructs(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	var result error
+func validateProfilerMap(profilerMap *ebpf.MapSpec) error {
+	switch profilerMap.Type {
+	case ebpf.Array, ebpf.PerCPUArray, ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	default:
+		return fmt.Errorf("map %q has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: %s",
+			profilerMap.Name, profilerMap.Type)
+	}
 
-	for name, mapStruct := range m.Structs {
-		var btfStruct *btf.Struct
-		if err := spec.Types.TypeByName(name, &btfStruct); err != nil {
-			result = multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", name, err))
-			continue
-		}
+	if profilerMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information for its values", profilerMap.Name)
+	}
 
-		mapStructFields := make(map[string]metadatav1.Field, len(mapStruct.Fields))
-		for _, f := range mapStruct.Fields {
-			mapStructFields[f.Name] = f
+	if _, err := profilerSlots(profilerMap.Value); err != nil {
+		return fmt.Errorf("map %q: %w", profilerMap.Name, err)
+	}
+	return nil
+}
+
+// profilerSlots returns the number of slots of the value of a profiler map:
+// an array of __u64, or a struct with such an array as its only member
+func profilerSlots(value btf.Type) (int, error) {
+	typ := btf.UnderlyingType(value)
+	if s, ok := typ.(*btf.Struct); ok {
+		if len(s.Members) != 1 {
+			return 0, fmt.Errorf("value struct %q has %d members, expected only the slots array", s.Name, len(s.Members))
 		}
+		typ = btf.UnderlyingType(s.Members[0].Type)
+	}
 
-		mntnsFields := 0
-		netnsFields := 0
+	array, ok := typ.(*btf.Array)
+	if !ok {
+		return 0, fmt.Errorf("value is %s, expected an array of __u64 slots", btfTypeName(value))
+	}
+	if slot, ok := btf.UnderlyingType(array.Type).(*btf.Int); !ok || slot.Size != 8 || slot.Encoding == btf.Signed {
+		return 0, fmt.Errorf("slots are %s, expected __u64", btfTypeName(array.Type))
+	}
+	if array.Nelems == 0 || array.Nelems > maxProfilerSlots {
+		return 0, fmt.Errorf("value has %d slots, expected between 1 and %d", array.Nelems, maxProfilerSlots)
+	}
+	return int(array.Nelems), nil
+}
 
-		mntNsIdType := strings.TrimPrefix(compat.MntNsIdType, "type:")
-		netNsIdType := strings.TrimPrefix(compat.NetNsIdType, "type:")
+func validateSnapshotters(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
 
-		btfStructFields := make(map[string]btf.Member, len(btfStruct.Members))
-		for _, m := range btfStruct.Members {
-			btfStructFields[m.Name] = m
+	// Temporary limitation
+	if len(m.Snapshotters) > 1 {
+		result = multierror.Append(result, errors.New("only one snapshotter is allowed"))
+	}
 
-			if mntNsIdType == m.Type.TypeName() {
-				mntnsFields++
+	for name, snapshotter := range m.Snapshotters {
+		switch snapshotter.Source {
+		case "", metadatav1.SnapshotterSourceIterator:
+			if snapshotter.MapName != "" || snapshotter.KeyStructName != "" {
+				result = multierror.Append(result, fmt.Errorf("snapshotter %q: mapName and keyStructName require source %q",
+					name, metadatav1.SnapshotterSourceMap))
+			}
+		case metadatav1.SnapshotterSourceMap:
+			if err := validateSnapshotterMap(m, spec, snapshotter); err != nil {
+				result = multierror.Append(result, fmt.Errorf("snapshotter %q: %w", name, err))
+			}
+		default:
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q: invalid source %q, expected %q or %q",
+				name, snapshotter.Source, metadatav1.SnapshotterSourceIterator, metadatav1.SnapshotterSourceMap))
+		}
+
+		if snapshotter.StructName == "" {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q is missing structName", name))
+			continue
+		}
+
+		if _, ok := m.Structs[snapshotter.StructName]; !ok {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q references unknown struct %q", name, snapshotter.StructName))
+			continue
+		}
+
+		if err := validateSnapshotterMode(m, snapshotter); err != nil {
+			result = multierror.Append(result, fmt.Errorf("snapshotter %q: %w", name, err))
+		}
+	}
+
+	if err := validateKeyFields(m); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	return result
+}
+
+// validateSnapshotterMode checks the interval and the mode of snapshotters
+// taking snapshots periodically. The diff mode needs key fields to match the
+// entries of consecutive snapshots.
+func validateSnapshotterMode(m *metadatav1.GadgetMetadata, snapshotter metadatav1.Snapshotter) error {
+	var result error
+
+	if snapshotter.Interval != "" {
+		if d, err := time.ParseDuration(snapshotter.Interval); err != nil || d <= 0 {
+			result = multierror.Append(result, fmt.Errorf("invalid interval %q, expected a positive duration like \"5s\"", snapshotter.Interval))
+		}
+	}
+
+	switch snapshotter.Mode {
+	case "", metadatav1.SnapshotterModeReplace:
+	case metadatav1.SnapshotterModeDiff:
+		if snapshotter.KeyStructName == "" && len(snapshotterKeyFields(m, snapshotter.StructName)) == 0 {
+			result = multierror.Append(result, fmt.Errorf("mode %q requires key fields: set the key attribute of the fields of struct %q identifying the entries",
+				snapshotter.Mode, snapshotter.StructName))
+		}
+	default:
+		result = multierror.Append(result, fmt.Errorf("invalid mode %q, expected %q or %q",
+			snapshotter.Mode, metadatav1.SnapshotterModeReplace, metadatav1.SnapshotterModeDiff))
+	}
+
+	return result
+}
+
+// snapshotterKeyFields returns the names of the fields of the struct with the
+// key attribute
+func snapshotterKeyFields(m *metadatav1.GadgetMetadata, structName string) []string {
+	var keys []string
+	for _, field := range m.Structs[structName].Fields {
+		if field.Attributes.Key {
+			keys = append(keys, field.Name)
+		}
+	}
+	return keys
+}
+
+// validateKeyFields checks the fields with the key attribute: they have to be
+// part of the entries of snapshotters or of the events of tracers with
+// aggregation and exist in the eBPF struct
+func validateKeyFields(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	snapshotterStructs := make(map[string]struct{})
+	for _, snapshotter := range m.Snapshotters {
+		snapshotterStructs[snapshotter.StructName] = struct{}{}
+		if snapshotter.KeyStructName != "" {
+			snapshotterStructs[snapshotter.KeyStructName] = struct{}{}
+		}
+	}
+	for _, tracer := range m.Tracers {
+		if tracer.Aggregation != nil {
+			snapshotterStructs[tracer.StructName] = struct{}{}
+		}
+	}
+
+	for _, structName := range sortedKeys(m.Structs) {
+		for _, field := range m.Structs[structName].Fields {
+			if !field.Attributes.Key {
+				continue
+			}
+			if _, ok := snapshotterStructs[structName]; !ok {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q: key is only supported by the fields of snapshotters and of tracers with aggregation",
+					field.Name, structName))
+				continue
+			}
+			if field.Source != "" {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q: computed fields can't be keys",
+					field.Name, structName))
+			}
+		}
+	}
+
+	return result
+}
+
+// validateSnapshotterMap checks the map dumped by a snapshotter against the
+// structs of its metadata
+func validateSnapshotterMap(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, snapshotter metadatav1.Snapshotter) error {
+	if snapshotter.MapName == "" {
+		return errors.New("missing mapName")
+	}
+	ebpfMap, ok := spec.Maps[snapshotter.MapName]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", snapshotter.MapName)
+	}
+	keyStruct, valueStruct, err := snapshotterMapStructs(ebpfMap)
+	if err != nil {
+		return err
+	}
+
+	var result error
+	if snapshotter.StructName != "" && snapshotter.StructName != valueStruct.Name {
+		result = multierror.Append(result, fmt.Errorf("map %q value is %q, expected struct %q",
+			ebpfMap.Name, valueStruct.Name, snapshotter.StructName))
+	}
+
+	keyStructName := ""
+	if keyStruct != nil {
+		keyStructName = keyStruct.Name
+	}
+	if snapshotter.KeyStructName != keyStructName {
+		result = multierror.Append(result, fmt.Errorf("map %q key struct is %q, got keyStructName %q",
+			ebpfMap.Name, keyStructName, snapshotter.KeyStructName))
+	} else if keyStructName != "" {
+		if _, ok := m.Structs[keyStructName]; !ok {
+			result = multierror.Append(result, fmt.Errorf("referencing unknown struct %q", keyStructName))
+		}
+	}
+	return result
+}
+
+// snapshotterMapStructs returns the key and value structs of a map dumped by a
+// snapshotter. Arrays don't have a key struct, their index isn't part of the
+// entries.
+func snapshotterMapStructs(ebpfMap *ebpf.MapSpec) (*btf.Struct, *btf.Struct, error) {
+	isArray := false
+	switch ebpfMap.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	case ebpf.Array, ebpf.PerCPUArray:
+		isArray = true
+	default:
+		return nil, nil, fmt.Errorf("map %q has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: %s",
+			ebpfMap.Name, ebpfMap.Type)
+	}
+	if ebpfMap.Key == nil || ebpfMap.Value == nil {
+		return nil, nil, fmt.Errorf("map %q does not have BTF information for its keys and values", ebpfMap.Name)
+	}
+
+	valueStruct, ok := btf.UnderlyingType(ebpfMap.Value).(*btf.Struct)
+	if !ok {
+		return nil, nil, fmt.Errorf("map %q value is %q, expected a struct", ebpfMap.Name, ebpfMap.Value.TypeName())
+	}
+	if isArray {
+		return nil, valueStruct, nil
+	}
+	keyStruct, ok := btf.UnderlyingType(ebpfMap.Key).(*btf.Struct)
+	if !ok {
+		return nil, nil, fmt.Errorf("map %q key is %q, expected a struct", ebpfMap.Name, ebpfMap.Key.TypeName())
+	}
+	return keyStruct, valueStruct, nil
+}
+
+// validateMapAndStruct fully validates the map, while the struct is only
+// checked for existence in the Structs section of the metadata as it will be
+// validated with the rest of the structs.
+func validateMapAndStruct(mapName, structName string,
+	spec *ebpf.CollectionSpec,
+	m *metadatav1.GadgetMetadata,
+	validateMap func(*ebpf.MapSpec, string) error,
+) (result error) {
+	if mapName == "" {
+		result = multierror.Append(result, errors.New("missing mapName"))
+	} else {
+		ebpfMap, ok := spec.Maps[mapName]
+		if !ok {
+			return fmt.Errorf("map %q not found in eBPF object", mapName)
+		}
+
+		if err := validateMap(ebpfMap, structName); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if structName == "" {
+		result = multierror.Append(result, errors.New("missing structName"))
+	} else if _, ok := m.Structs[structName]; !ok {
+		result = multierror.Append(result, fmt.Errorf("referencing unknown struct %q", structName))
+	}
+
+	return
+}
+
+func validateStructs(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	for name, mapStruct := range m.Structs {
+		btfStruct, err := idx.structByName(name)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", name, err))
+			continue
+		}
+
+		mapStructFields := make(map[string]metadatav1.Field, len(mapStruct.Fields))
+		for _, f := range mapStruct.Fields {
+			mapStructFields[f.Name] = f
+		}
+
+		mntnsFields := countSpecialMembers(btfStruct.Members, strings.TrimPrefix(compat.MntNsIdType, "type:"))
+		netnsFields := countSpecialMembers(btfStruct.Members, strings.TrimPrefix(compat.NetNsIdType, "type:"))
+
+		if mntnsFields > 1 {
+			log.Warnf("Using multiple fields of %q may cause unpredictable behavior during enrichment",
+				compat.MntNsIdType)
+		}
+		if netnsFields > 1 {
+			log.Warnf("Using multiple fields of %q may cause unpredictable behavior during enrichment",
+				compat.NetNsIdType)
+		}
+
+		for fieldName, field := range mapStructFields {
+			if field.Source != "" {
+				// Computed fields don't exist in the eBPF struct, see
+				// validateComputedFields
+				continue
+			}
+
+			member, ok := findMember(btfStruct.Members, fieldName)
+			if !ok {
+				result = multierror.Append(result, fmt.Errorf("field %q not found in eBPF struct %q", fieldName, name))
+				continue
+			}
+
+			if err := validateFieldMaxWidth(field, member); err != nil {
+				log.Warnf("Field %q of struct %q: %s", fieldName, name, err)
+			}
+			if err := validateFieldInt128Width(field, member); err != nil {
+				log.Warnf("Field %q of struct %q: %s", fieldName, name, err)
+			}
+			if err := validateFieldEnumValues(field, member); err != nil {
+				log.Warnf("Field %q of struct %q: %s", fieldName, name, err)
+			}
+			if err := validateFieldValues(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldBitfield(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldVariants(field, member, btfStruct.Members); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldBitmask(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldFlags(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldIPVersion(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldMacAddr(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldIdTemplate(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldSyscall(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldSignal(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldCapabilities(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldStackMap(field, member, idx.spec); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldBoolLabels(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldEncoding(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldRedact(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldAutoWidth(field); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldExamples(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldZeroAs(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldDuration(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldErrno(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldEndpoint(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldPrecision(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldUnit(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldPointer(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldInt128(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldComparable(field, member); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+			if err := validateFieldStyle(field, mapStruct); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating field %q of struct %q: %w", fieldName, name, err))
+			}
+		}
+
+		if err := validateFieldOrders(mapStruct); err != nil {
+			log.Warnf("Struct %q: %s", name, err)
+		}
+
+		if err := validateComputedFields(mapStruct, btfStruct); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating struct %q: %w", name, err))
+		}
+
+		if mapStruct.Trailer != nil {
+			if err := validateTrailer(*mapStruct.Trailer, btfStruct); err != nil {
+				result = multierror.Append(result, fmt.Errorf("validating trailer of struct %q: %w", name, err))
+			}
+		}
+	}
+
+	return result
+}
+
+// validateComputedFields type checks the expressions of the fields with a
+// source. They can only reference fields of the struct that exist in the eBPF
+// struct and hold numbers, strings or bools.
+func validateComputedFields(s metadatav1.Struct, btfStruct *btf.Struct) error {
+	var result error
+
+	types := make(map[string]expr.Type)
+	for _, f := range s.Fields {
+		if f.Source != "" {
+			continue
+		}
+		member, ok := findMember(btfStruct.Members, f.Name)
+		if !ok {
+			continue
+		}
+		if typ := exprType(member); typ != expr.TypeInvalid {
+			types[f.Name] = typ
+		}
+	}
+
+	for _, f := range s.Fields {
+		if f.Source == "" {
+			if f.SourcePlaceholder != "" {
+				result = multierror.Append(result, fmt.Errorf("field %q: sourcePlaceholder requires source", f.Name))
+			}
+			continue
+		}
+		e, err := expr.Parse(f.Source)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("field %q: %w", f.Name, err))
+			continue
+		}
+		for _, name := range e.Fields() {
+			if _, ok := types[name]; ok {
+				continue
+			}
+			if _, ok := findMember(btfStruct.Members, name); ok {
+				result = multierror.Append(result, fmt.Errorf("field %q: field %q can't be used in expressions", f.Name, name))
+			}
+		}
+		if _, err := e.Check(types); err != nil {
+			result = multierror.Append(result, fmt.Errorf("field %q: checking expression %q: %w", f.Name, f.Source, err))
+		}
+	}
+
+	return result
+}
+
+// exprType returns the type of the value of member in expressions of computed
+// fields: integers, floats and enums are numbers, char arrays are strings and
+// bools are bools. Other members can't be used in expressions.
+func exprType(member btf.Member) expr.Type {
+	if _, ok := getEndpointKind(member.Type); ok {
+		return expr.TypeInvalid
+	}
+	if charArrayLen(member.Type) > 0 {
+		return expr.TypeString
+	}
+
+	switch typ := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if typ.Encoding == btf.Bool {
+			return expr.TypeBool
+		}
+		if typ.Size <= 8 {
+			return expr.TypeNumber
+		}
+	case *btf.Enum, *btf.Float:
+		return expr.TypeNumber
+	}
+	return expr.TypeInvalid
+}
+
+// validateFieldOrders checks that the fields of a struct setting an order use
+// different ones. Ties are valid but the resulting order is easy to miss.
+func validateFieldOrders(s metadatav1.Struct) error {
+	var result error
+
+	// order -> name of the first field using it
+	owners := make(map[int32]string)
+	for _, f := range s.Fields {
+		if f.Attributes.Order == nil {
+			continue
+		}
+		order := *f.Attributes.Order
+		if owner, ok := owners[order]; ok {
+			result = multierror.Append(result, fmt.Errorf("fields %q and %q have the same order %d", owner, f.Name, order))
+			continue
+		}
+		owners[order] = f.Name
+	}
+
+	return result
+}
+
+// validateTrailer checks that the variable-length data following a struct has
+// a supported type, doesn't shadow a member and that its length is stored in
+// an unsigned integer member.
+func validateTrailer(trailer metadatav1.Trailer, btfStruct *btf.Struct) error {
+	var result error
+
+	if trailer.Name == "" {
+		result = multierror.Append(result, errors.New("name is required"))
+	} else if _, ok := findMember(btfStruct.Members, trailer.Name); ok {
+		result = multierror.Append(result, fmt.Errorf("name %q is already used by a member", trailer.Name))
+	}
+
+	switch trailer.Type {
+	case metadatav1.TrailerTypeBytes, metadatav1.TrailerTypeString:
+	default:
+		result = multierror.Append(result, fmt.Errorf("invalid type %q, expected %q or %q",
+			trailer.Type, metadatav1.TrailerTypeBytes, metadatav1.TrailerTypeString))
+	}
+
+	if _, err := trailerLengthMember(trailer, btfStruct); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	return result
+}
+
+// trailerLengthMember returns the member holding the length of the trailer
+func trailerLengthMember(trailer metadatav1.Trailer, btfStruct *btf.Struct) (btf.Member, error) {
+	member, ok := findMember(btfStruct.Members, trailer.LengthField)
+	if !ok {
+		return btf.Member{}, fmt.Errorf("length field %q not found", trailer.LengthField)
+	}
+
+	intType, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+	if !ok || intType.Encoding == btf.Signed || intType.Encoding == btf.Bool || member.BitfieldSize > 0 {
+		return btf.Member{}, fmt.Errorf("length field %q must be an unsigned integer, got %s", trailer.LengthField, member.Type)
+	}
+
+	return member, nil
+}
+
+// validateFieldValues checks that the values mapping is only set on enum and
+// integer fields and that the values fit in the integer. Values not defined by
+// an enum are reported by validateFieldEnumValues.
+func validateFieldValues(field metadatav1.Field, member btf.Member) error {
+	if len(field.Values) == 0 {
+		if field.DefaultLabel != "" {
+			return errors.New("defaultLabel requires values")
+		}
+		return nil
+	}
+
+	if enumType(member.Type) != nil {
+		return nil
+	}
+
+	intType, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+	if !ok || intType.Encoding == btf.Bool {
+		return errors.New("values can only be set on enum and integer fields")
+	}
+
+	width := uint64(intType.Size) * 8
+	if member.BitfieldSize > 0 {
+		width = uint64(member.BitfieldSize)
+	}
+	// Unsigned 64-bit values above math.MaxInt64 are stored as negative ones
+	if width >= 64 {
+		return nil
+	}
+
+	minValue, maxValue := int64(0), int64(1)<<width-1
+	if intType.Encoding == btf.Signed {
+		minValue, maxValue = -(int64(1) << (width - 1)), int64(1)<<(width-1)-1
+	}
+
+	var result error
+	for _, v := range sortedValues(field.Values) {
+		if v < minValue || v > maxValue {
+			result = multierror.Append(result, fmt.Errorf("value %d is out of the range of the field [%d, %d]", v, minValue, maxValue))
+		}
+	}
+	return result
+}
+
+// sortedValues returns the values of a value-to-label mapping in order
+func sortedValues(values map[int64]string) []int64 {
+	keys := make([]int64, 0, len(values))
+	for v := range values {
+		keys = append(keys, v)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// validateFieldEnumValues checks that the values mapping of an enum field only
+// contains values defined by the enum in the eBPF object. The mapping wins
+// anyway, so this is only a hint of an outdated mapping.
+func validateFieldEnumValues(field metadatav1.Field, member btf.Member) error {
+	enum := enumType(member.Type)
+	if enum == nil || len(field.Values) == 0 {
+		return nil
+	}
+
+	btfValues, _ := enumValues(enum)
+
+	var result error
+	for _, v := range sortedValues(field.Values) {
+		if _, ok := btfValues[v]; !ok {
+			result = multierror.Append(result, fmt.Errorf("value %d not found in enum %q", v, enum.Name))
+		}
+	}
+	return result
+}
+
+// validateFieldVariants checks that the variant selector of a union field
+// refers to an integer or enum sibling field and that the variants mapping
+// only uses members of the union and, for enum selectors, values of the enum.
+func validateFieldVariants(field metadatav1.Field, member btf.Member, members []btf.Member) error {
+	if field.VariantOf == "" {
+		if len(field.Variants) > 0 {
+			return errors.New("variants can only be set along with variantOf")
+		}
+		return nil
+	}
+
+	union := namedUnion(member.Type)
+	if union == nil {
+		return errors.New("variantOf can only be set on union fields")
+	}
+	if len(field.Variants) == 0 {
+		return errors.New("variantOf needs a variants mapping")
+	}
+
+	// The selector is a sibling of the union
+	selectorName := field.VariantOf
+	if i := strings.LastIndex(field.Name, "."); i >= 0 {
+		selectorName = field.Name[:i+1] + field.VariantOf
+	}
+	selector, ok := findMember(members, selectorName)
+	if !ok {
+		return fmt.Errorf("variant selector %q not found", selectorName)
+	}
+
+	selectorType := btfhelpers.GetUnderlyingType(selector.Type)
+	var selectorValues map[int64]string
+	switch t := selectorType.(type) {
+	case *btf.Int:
+	case *btf.Enum:
+		selectorValues, _ = enumValues(t)
+	default:
+		return fmt.Errorf("variant selector %q must be an integer or an enum", selectorName)
+	}
+
+	unionMembers := make(map[string]struct{}, len(union.Members))
+	for _, m := range union.Members {
+		unionMembers[m.Name] = struct{}{}
+	}
+
+	var result error
+	used := make(map[string]struct{}, len(field.Variants))
+	for v, name := range field.Variants {
+		if _, ok := unionMembers[name]; !ok {
+			result = multierror.Append(result, fmt.Errorf("variant %q for value %d not found in union", name, v))
+		}
+		if selectorValues != nil {
+			if _, ok := selectorValues[v]; !ok {
+				result = multierror.Append(result, fmt.Errorf("value %d of variant %q not found in enum of selector %q", v, name, selectorName))
+			}
+		}
+		used[name] = struct{}{}
+	}
+
+	for name := range unionMembers {
+		if _, ok := used[name]; !ok && name != "" {
+			log.Warnf("Member %q of union %q isn't selected by any value of %q", name, field.Name, selectorName)
+		}
+	}
+
+	return result
+}
+
+// validateAliases checks that the aliases of the fields don't collide with
+// the names of other fields, including the ones added by the enrichment.
+func validateAliases(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	fieldNames := make(map[string]struct{})
+	for _, s := range m.Structs {
+		for _, f := range s.Fields {
+			fieldNames[f.Name] = struct{}{}
+		}
+	}
+
+	structNames := make([]string, 0, len(m.Structs))
+	for name := range m.Structs {
+		structNames = append(structNames, name)
+	}
+	sort.Strings(structNames)
+
+	// alias -> name of the field it belongs to
+	owners := make(map[string]string)
+
+	for _, structName := range structNames {
+		for _, f := range m.Structs[structName].Fields {
+			for _, alias := range f.Aliases {
+				if alias == "" {
+					result = multierror.Append(result, fmt.Errorf("field %q of struct %q has an empty alias", f.Name, structName))
+					continue
+				}
+				if _, ok := fieldNames[alias]; ok {
+					result = multierror.Append(result, fmt.Errorf("alias %q of field %q collides with an existing field", alias, f.Name))
+					continue
+				}
+				if isEnrichmentField(alias) {
+					result = multierror.Append(result, fmt.Errorf("alias %q of field %q collides with an enrichment field", alias, f.Name))
+					continue
+				}
+				if owner, ok := owners[alias]; ok && owner != f.Name {
+					result = multierror.Append(result, fmt.Errorf("alias %q used by fields %q and %q", alias, owner, f.Name))
+					continue
+				}
+				owners[alias] = f.Name
+			}
+		}
+	}
+
+	return result
+}
+
+// validateOutputNames checks that the fields of each struct have different
+// names in JSON and YAML output, taking OutputName into account.
+func validateOutputNames(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	structNames := make([]string, 0, len(m.Structs))
+	for name := range m.Structs {
+		structNames = append(structNames, name)
+	}
+	sort.Strings(structNames)
+
+	for _, structName := range structNames {
+		// output name -> name of the field using it
+		owners := make(map[string]string)
+		for _, f := range m.Structs[structName].Fields {
+			outputName := f.Name
+			if f.OutputName != "" {
+				outputName = f.OutputName
+			}
+			if owner, ok := owners[outputName]; ok {
+				result = multierror.Append(result, fmt.Errorf("fields %q and %q of struct %q have the same output name %q",
+					owner, f.Name, structName, outputName))
+				continue
+			}
+			owners[outputName] = f.Name
+		}
+	}
+
+	return result
+}
+
+// validateDefaultColumns checks that the default columns of tracers and
+// snapshotters are fields of their structs or enrichment fields.
+func validateDefaultColumns(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	check := func(kind, name, structName string, defaultColumns []string) {
+		s, ok := m.Structs[structName]
+		if !ok {
+			// Reported when validating the tracer or snapshotter
+			return
+		}
+		seen := make(map[string]struct{}, len(defaultColumns))
+		for _, column := range defaultColumns {
+			if _, ok := seen[column]; ok {
+				result = multierror.Append(result, fmt.Errorf("%s %q: default column %q listed twice", kind, name, column))
+				continue
+			}
+			seen[column] = struct{}{}
+			if isEnrichmentField(column) {
+				continue
+			}
+			found := false
+			for _, f := range s.Fields {
+				if f.Name == column {
+					found = true
+					break
+				}
+			}
+			if !found {
+				result = multierror.Append(result, fmt.Errorf("%s %q: default column %q not found in struct %q",
+					kind, name, column, structName))
+			}
+		}
+	}
+
+	tracerNames := make([]string, 0, len(m.Tracers))
+	for name := range m.Tracers {
+		tracerNames = append(tracerNames, name)
+	}
+	sort.Strings(tracerNames)
+	for _, name := range tracerNames {
+		t := m.Tracers[name]
+		check("tracer", name, t.StructName, t.DefaultColumns)
+	}
+
+	snapshotterNames := make([]string, 0, len(m.Snapshotters))
+	for name := range m.Snapshotters {
+		snapshotterNames = append(snapshotterNames, name)
+	}
+	sort.Strings(snapshotterNames)
+	for _, name := range snapshotterNames {
+		s := m.Snapshotters[name]
+		check("snapshotter", name, s.StructName, s.DefaultColumns)
+	}
+
+	return result
+}
+
+// validateGroups checks that the groups referenced by fields are defined
+func validateGroups(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	structNames := make([]string, 0, len(m.Structs))
+	for name := range m.Structs {
+		structNames = append(structNames, name)
+	}
+	sort.Strings(structNames)
+
+	for _, structName := range structNames {
+		for _, f := range m.Structs[structName].Fields {
+			if f.Group == "" {
+				continue
+			}
+			if _, ok := m.Groups[f.Group]; !ok {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q references undefined group %q",
+					f.Name, structName, f.Group))
+			}
+		}
+	}
+
+	return result
+}
+
+func isEnrichmentField(name string) bool {
+	for _, root := range enrichmentFields {
+		if name == root || strings.HasPrefix(name, root+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExternalMaps checks that the maps shared with other gadgets are
+// defined by the gadget and declare the layout they were built against
+func validateExternalMaps(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.ExternalMaps) {
+		externalMap := m.ExternalMaps[name]
+		mapSpec, ok := spec.Maps[name]
+		if !ok {
+			result = multierror.Append(result, fmt.Errorf("external map %q not found in eBPF object", name))
+		}
+		if externalMap.LayoutVersion <= 0 {
+			result = multierror.Append(result, fmt.Errorf("external map %q: layoutVersion must be greater than 0", name))
+		}
+		if ok {
+			if err := validateExternalMapProvider(name, externalMap, mapSpec); err != nil {
+				result = multierror.Append(result, fmt.Errorf("external map %q: %w", name, err))
+			}
+		}
+	}
+
+	for _, structName := range sortedKeys(m.Structs) {
+		for _, field := range m.Structs[structName].Fields {
+			if field.ExternalMap == "" {
+				continue
+			}
+			if _, ok := m.ExternalMaps[field.ExternalMap]; !ok {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q: external map %q not declared in externalMaps",
+					field.Name, structName, field.ExternalMap))
+			}
+		}
+	}
+
+	return result
+}
+
+// validateExternalMapProvider checks the map against its provider, when it's
+// available in this binary, like the socket enricher in ig
+func validateExternalMapProvider(name string, externalMap metadatav1.ExternalMap, mapSpec *ebpf.MapSpec) error {
+	provider := sharedmaps.Get(name)
+	if provider == nil {
+		log.Debugf("No provider of external map %q available, skipping its checks", name)
+		return nil
+	}
+
+	if externalMap.Provider != "" && externalMap.Provider != provider.ProviderName() {
+		return fmt.Errorf("provided by %q, not %q", provider.ProviderName(), externalMap.Provider)
+	}
+	if externalMap.LayoutVersion != provider.LayoutVersion() {
+		return fmt.Errorf("layoutVersion is %d but %q provides version %d", externalMap.LayoutVersion,
+			provider.ProviderName(), provider.LayoutVersion())
+	}
+
+	providerSpec, err := provider.MapSpec()
+	if err != nil {
+		return fmt.Errorf("getting spec from %q: %w", provider.ProviderName(), err)
+	}
+	if err := sharedmaps.CheckLayout(mapSpec, providerSpec); err != nil {
+		return fmt.Errorf("layout doesn't match the one of %q: %w", provider.ProviderName(), err)
+	}
+	return nil
+}
+
+func validateEbpfParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for varName := range m.EBPFParams {
+		if err := checkParamVar(idx, varName); err != nil {
+			result = multierror.Append(result, err)
+		}
+		if len(m.EBPFParams[varName].Key) == 0 {
+			result = multierror.Append(result, fmt.Errorf("param %q has an empty key", varName))
+		}
+		if p := m.EBPFParams[varName]; p.Hidden && p.DefaultValue == "" {
+			result = multierror.Append(result, fmt.Errorf("param %q is hidden but has no default value, it couldn't be set in non-interactive contexts",
+				varName))
+		}
+		if p := m.EBPFParams[varName]; p.IsMandatory && p.DefaultValue != "" {
+			result = multierror.Append(result, fmt.Errorf("param %q is mandatory but has the default value %q, remove it or make the param optional",
+				varName, p.MaskValue(p.DefaultValue)))
+		}
+		if p := m.EBPFParams[varName]; p.IsAddress() || p.HasUnit() || p.HasTargetMap() {
+			// Checked by validateAddressParams, validateUnitParams and
+			// validateTargetMapParams
+			continue
+		}
+		if err := validateParamTypeHint(m.EBPFParams[varName].ParamDesc, idx, varName); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+// validateParamTypeHint checks that the type hint set in the metadata of a param
+// matches the type of the eBPF variable backing it.
+func validateParamTypeHint(p params.ParamDesc, idx *btfIndex, varName string) error {
+	if p.TypeHint == params.TypeUnknown {
+		return nil
+	}
+
+	btfVar, err := idx.varByName(varName)
+	if err != nil {
+		// Already reported by checkParamVar
+		return nil
+	}
+
+	if p.TypeHint == params.TypeBool {
+		// Bools are written as a single byte, any 1-byte integer can hold them
+		if !btfhelpers.IsByte(btfVar.Type) {
+			size, _ := btf.Sizeof(btfVar.Type)
+			return fmt.Errorf("param %q has type %q but the eBPF variable is %d bytes wide, expected 1",
+				varName, p.TypeHint, size)
+		}
+		return nil
+	}
+
+	expected := btfhelpers.GetTypeHint(btfVar.Type)
+	if expected == params.TypeUnknown || expected == p.TypeHint {
+		return nil
+	}
+
+	return fmt.Errorf("param %q has type %q but the eBPF variable is %q", varName, p.TypeHint, expected)
+}
+
+func validateGadgetParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
+	for _, p := range spec.Programs {
+		switch p.Type {
+		// Networking programs provide an interface name to attach to
+		case ebpf.SchedCLS:
+			if len(m.GadgetParams) == 0 {
+				result = multierror.Append(result, fmt.Errorf("there aren't gadget parameters"))
+			} else {
+				if _, ok := m.GadgetParams[IfaceParam]; !ok {
+					result = multierror.Append(result, fmt.Errorf("iface param not found"))
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Populate fills the metadata from its ebpf spec
+func Populate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, opts ...PopulateOption) error {
+	return populate(m, spec, nil, opts...)
+}
+
+func populate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, report *PopulateReport, opts ...PopulateOption) error {
+	var o populateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	info, err := getMarkers(spec, gadgetInfoPrefix)
+	if err != nil {
+		return fmt.Errorf("reading gadget information: %w", err)
+	}
+
+	idx := newBTFIndex(spec)
+
+	// Values in the metadata file win over the ones set in the eBPF code
+	populateInfo(&m.Name, info["name"], gadgetNameTODO)
+	populateInfo(&m.Description, info["description"], gadgetDescriptionTODO)
+	populateInfo(&m.HomepageURL, "", gadgetHomepageURLTODO)
+	populateInfo(&m.DocumentationURL, "", gadgetDocumentationURLTODO)
+	populateInfo(&m.SourceURL, "", gadgetSourceURLTODO)
+
+	if err := populateTracers(m, idx, o, report); err != nil {
+		return fmt.Errorf("handling tracers: %w", err)
+	}
+
+	if err := populateToppers(m, idx, o, report); err != nil {
+		return fmt.Errorf("handling toppers: %w", err)
+	}
+
+	if err := populateProfilers(m, idx, o, report); err != nil {
+		return fmt.Errorf("handling profilers: %w", err)
+	}
+
+	if err := populateSnapshotters(m, idx, o, report); err != nil {
+		return fmt.Errorf("handling snapshotters: %w", err)
+	}
+
+	if err := populateEbpfParams(m, idx, report); err != nil {
+		return fmt.Errorf("handling params: %w", err)
+	}
+
+	if err := populateGadgetParams(m, spec); err != nil {
+		return fmt.Errorf("handling gadget params: %w", err)
+	}
+	populateTracerSelector(m)
+
+	populatePrograms(m, spec)
+	populateMntNsFilter(m, spec)
+
+	return nil
+}
+
+// populateInfo sets a piece of information about the gadget that is empty or
+// still the placeholder to the value set in the eBPF code, if any, or to the
+// placeholder
+func populateInfo(field *string, value, placeholder string) {
+	if *field != "" && *field != placeholder {
+		return
+	}
+	if value != "" {
+		*field = value
+		return
+	}
+	*field = placeholder
+}
+
+type populateOptions struct {
+	allMembers    bool
+	leftAlignment bool
+}
+
+// PopulateOption configures Populate
+type PopulateOption func(*populateOptions)
+
+// WithAllMembers disables the heuristics that skip padding members and hide
+// internal ones, prefixed with an underscore
+func WithAllMembers() PopulateOption {
+	return func(o *populateOptions) {
+		o.allMembers = true
+	}
+}
+
+// WithLeftAlignment aligns all the new fields to the left, instead of aligning
+// numbers to the right
+func WithLeftAlignment() PopulateOption {
+	return func(o *populateOptions) {
+		o.leftAlignment = true
+	}
+}
+
+// Marshal returns the YAML representation of the metadata. The output only
+// depends on its content: map keys are sorted and slices, like the fields of
+// structs, keep their order. Hence, populating the metadata again from an
+// unchanged eBPF object produces the same file. It's formatted like the files
+// updated by MarshalPreserving.
+func Marshal(m *metadatav1.GadgetMetadata) ([]byte, error) {
+	out, err := encodeYAML(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshalling metadata: %w", err)
+	}
+	return out, nil
+}
+
+// getBitfieldColumnSize returns the width needed to show the values of a
+// bitfield member: one-bit flags are shown as booleans.
+func getBitfieldColumnSize(member btf.Member) uint {
+	signed := btfhelpers.IsSigned(member.Type)
+	if member.BitfieldSize == 1 && !signed {
+		return columns.MaxCharsBool
+	}
+	if member.BitfieldSize >= 64 {
+		return getColumnSize(member.Type)
+	}
+
+	width := uint(len(strconv.FormatUint(1<<member.BitfieldSize-1, 10)))
+	if signed {
+		width++
+	}
+	return width
+}
+
+// validateFieldBitfield checks that the bitfield layout recorded in the
+// metadata of a field still matches the BTF member.
+func validateFieldBitfield(field metadatav1.Field, member btf.Member) error {
+	offset, hasOffset := field.Annotations[bitfieldOffsetAnnotation]
+	size, hasSize := field.Annotations[bitfieldSizeAnnotation]
+	if !hasOffset && !hasSize {
+		return nil
+	}
+
+	if member.BitfieldSize == 0 {
+		return fmt.Errorf("field is recorded as a bitfield but it's not one in the eBPF struct")
+	}
+
+	if fmt.Sprint(offset) != strconv.FormatUint(uint64(member.Offset), 10) ||
+		fmt.Sprint(size) != strconv.FormatUint(uint64(member.BitfieldSize), 10) {
+		return fmt.Errorf("bitfield layout changed: metadata has offset %v and size %v, eBPF struct has offset %d and size %d",
+			offset, size, member.Offset, member.BitfieldSize)
+	}
+
+	return nil
+}
+
+func getColumnSize(typ btf.Type) uint {
+	switch typedMember := btfhelpers.GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		switch typedMember.Encoding {
+		case btf.Signed:
+			switch typedMember.Size {
+			case 1:
+				return columns.MaxCharsInt8
+			case 2:
+				return columns.MaxCharsInt16
+			case 4:
+				return columns.MaxCharsInt32
+			case 8:
+				return columns.MaxCharsInt64
+			case 16:
+				return int128ColumnWidth + 1
+			}
+		case btf.Unsigned:
+			return unsignedColumnSize(typedMember.Size)
+		case btf.Bool:
+			return columns.MaxCharsBool
+		case btf.Char:
+			return columns.MaxCharsChar
+		}
+	case *btf.Enum:
+		// Enums with enumerators are shown by name, see enumValues
+		if len(typedMember.Values) > 0 {
+			_, width := enumValues(typedMember)
+			return width
+		}
+		return unsignedColumnSize(typedMember.Size)
+	case *btf.Float:
+		return floatColumnSize(typedMember.Size, defaultFloatPrecision)
+	case *btf.Pointer:
+		return pointerColumnWidth
+	case *btf.Array:
+		if n := charArrayLen(typedMember); n > 0 {
+			return min(n, maxCharArrayColumnWidth)
+		}
+	}
+
+	return metadatav1.DefaultColumnWidth
+}
+
+// floatColumnSize returns the width of a column showing a float of the given
+// size with precision decimals: the sign, the significant digits of the type
+// (7 for float, 15 for double) as integer part, the dot and the decimals.
+func floatColumnSize(size uint32, precision uint) uint {
+	var digits uint
+	switch size {
+	case 4:
+		digits = 7
+	case 8:
+		digits = 15
+	default:
+		return metadatav1.DefaultColumnWidth
+	}
+
+	width := 1 + digits
+	if precision > 0 {
+		width += 1 + precision
+	}
+	return width
+}
+
+// validateFieldPrecision checks that only float fields and durations set a
+// precision
+func validateFieldPrecision(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Precision == nil || field.Attributes.Template == durationTemplate {
+		return nil
+	}
+	if _, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Float); !ok {
+		return errors.New("precision can only be set on float fields")
+	}
+	return nil
+}
+
+// validateFieldUnit checks that the unit is a known one and that only numeric
+// fields set it. Humanizing a field requires a unit.
+func validateFieldUnit(field metadatav1.Field, member btf.Member) error {
+	unit := field.Attributes.Unit
+	if unit == metadatav1.UnitNone {
+		if field.Attributes.Humanize {
+			return errors.New("humanize requires a unit")
+		}
+		return nil
+	}
+
+	switch unit {
+	case metadatav1.UnitBytes, metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds,
+		metadatav1.UnitMilliseconds, metadatav1.UnitCount:
+	default:
+		return fmt.Errorf("invalid unit %q, expected %q, %q, %q, %q or %q", unit,
+			metadatav1.UnitBytes, metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds,
+			metadatav1.UnitMilliseconds, metadatav1.UnitCount)
+	}
+
+	switch typ := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Float:
+		return nil
+	case *btf.Int:
+		if typ.Encoding != btf.Bool && typ.Encoding != btf.Char && typ.Size <= 8 &&
+			!(member.BitfieldSize == 1 && typ.Encoding != btf.Signed) {
+			return nil
+		}
+	}
+	return errors.New("unit can only be set on numeric fields")
+}
+
+// validateFieldPointer checks the base and the pointer annotation of a field:
+// only pointers and 128-bit integers can be shown in hex for now.
+func validateFieldPointer(field metadatav1.Field, member btf.Member) error {
+	pointer := isPointer(member.Type)
+
+	switch field.Attributes.Base {
+	case metadatav1.BaseDecimal:
+		if pointer {
+			return errors.New("pointers can only be shown in hex")
+		}
+	case metadatav1.BaseHex:
+		if !pointer && !is128Bit(member.Type) {
+			return errors.New("base hex can only be set on pointer fields and 128-bit integers")
+		}
+	default:
+		return fmt.Errorf("invalid base %q", field.Attributes.Base)
+	}
+
+	val, ok := field.Annotations[pointerAnnotation]
+	if !ok {
+		return nil
+	}
+	if !pointer {
+		return fmt.Errorf("%q annotation can only be set on pointer fields", pointerAnnotation)
+	}
+	switch val {
+	case pointerKernel, pointerUser:
+	default:
+		return fmt.Errorf("%q annotation is %q, expected %q or %q", pointerAnnotation, val, pointerKernel, pointerUser)
+	}
+	return nil
+}
+
+// is128Bit returns true if typ holds 128 bits that can be decoded as a number
+// or an IPv6 address: __int128, 16-byte arrays of integers and in6_addr
+func is128Bit(typ btf.Type) bool {
+	switch t := btfhelpers.GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		return t.Size == 16 && t.Encoding != btf.Bool
+	case *btf.Array:
+		if _, ok := btfhelpers.GetUnderlyingType(t.Type).(*btf.Int); !ok {
+			return false
+		}
+		size, err := btf.Sizeof(t)
+		return err == nil && size == 16
+	case *btf.Struct:
+		return t.Name == "in6_addr" && t.Size == 16
+	}
+	return false
+}
+
+// int128Repr returns how a 128-bit member is decoded: the value of
+// int128Annotation or, without it, as a number for integers and as an IPv6
+// address for in6_addr. It returns "" if the member isn't decoded as a 128-bit
+// value, like arrays without annotation.
+func int128Repr(field metadatav1.Field, member btf.Member) string {
+	if !is128Bit(member.Type) {
+		return ""
+	}
+	if val, ok := field.Annotations[int128Annotation]; ok {
+		return fmt.Sprint(val)
+	}
+
+	switch btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		return int128Number
+	case *btf.Struct:
+		return int128IPv6
+	}
+	return ""
+}
+
+// validateFieldInt128 checks that only 128-bit members use int128Annotation
+// and that its value is valid
+func validateFieldInt128(field metadatav1.Field, member btf.Member) error {
+	val, ok := field.Annotations[int128Annotation]
+	if !ok {
+		return nil
+	}
+	if !is128Bit(member.Type) {
+		return fmt.Errorf("%q annotation can only be set on 128-bit fields", int128Annotation)
+	}
+	switch val {
+	case int128Number, int128Bytes, int128IPv6:
+	default:
+		return fmt.Errorf("%q annotation is %q, expected %q, %q or %q",
+			int128Annotation, val, int128Number, int128Bytes, int128IPv6)
+	}
+	return nil
+}
+
+// validateFieldInt128Width checks that the column of a 128-bit number is wide
+// enough for its values in the base of the field
+func validateFieldInt128Width(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Width == 0 || int128Repr(field, member) != int128Number {
+		return nil
+	}
+
+	width := uint(int128ColumnWidth)
+	if field.Attributes.Base == metadatav1.BaseHex {
+		width = int128HexColumnWidth
+	} else if btfhelpers.IsSigned(member.Type) {
+		width++
+	}
+	if field.Attributes.Width >= width {
+		return nil
+	}
+	return fmt.Errorf("width is %d but 128-bit values need up to %d characters", field.Attributes.Width, width)
+}
+
+func isPointer(typ btf.Type) bool {
+	_, ok := btfhelpers.GetUnderlyingType(typ).(*btf.Pointer)
+	return ok
+}
+
+func unsignedColumnSize(size uint32) uint {
+	switch size {
+	case 1:
+		return columns.MaxCharsUint8
+	case 2:
+		return columns.MaxCharsUint16
+	case 4:
+		return columns.MaxCharsUint32
+	case 8:
+		return columns.MaxCharsUint64
+	case 16:
+		return int128ColumnWidth
+	}
+	return metadatav1.DefaultColumnWidth
+}
+
+// getAlignment returns the default alignment of the column of a member of type
+// typ: numbers are aligned to the right and everything else to the left
+func getAlignment(typ btf.Type, opts populateOptions) metadatav1.Alignment {
+	if opts.leftAlignment {
+		return metadatav1.AlignmentLeft
+	}
+
+	switch t := btfhelpers.GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		// Characters are usually signed ints in BTF
+		if t.Name == "char" || t.Encoding == btf.Char || t.Encoding == btf.Bool {
+			return metadatav1.AlignmentLeft
+		}
+		return metadatav1.AlignmentRight
+	case *btf.Enum, *btf.Float:
+		return metadatav1.AlignmentRight
+	}
+
+	return metadatav1.AlignmentLeft
+}
+
+// isComparable returns whether the values of member can be compared, which is
+// needed to filter and sort by it: integers, floats, pointers and strings can,
+// structs, unions, endpoints and other arrays can't.
+func isComparable(member btf.Member) bool {
+	if _, ok := getEndpointKind(member.Type); ok {
+		return false
+	}
+	if charArrayLen(member.Type) > 0 {
+		return true
+	}
+
+	switch typ := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		return typ.Size <= 8
+	case *btf.Enum, *btf.Float, *btf.Pointer:
+		return true
+	}
+	return false
+}
+
+// validateFieldComparable checks that only fields with comparable values are
+// marked as filterable or sortable, see isComparable.
+func validateFieldComparable(field metadatav1.Field, member btf.Member) error {
+	if isComparable(member) {
+		return nil
+	}
+
+	var result error
+	if v := field.Attributes.Filterable; v != nil && *v {
+		result = multierror.Append(result, errors.New("filterable can only be set on fields with comparable values"))
+	}
+	if v := field.Attributes.Sortable; v != nil && *v {
+		result = multierror.Append(result, errors.New("sortable can only be set on fields with comparable values"))
+	}
+	return result
+}
+
+// validateFieldStyle checks the color and style annotations of a field: the
+// colors and styles must be known and the condition, if any, must refer to a
+// field of the same struct.
+func validateFieldStyle(field metadatav1.Field, s metadatav1.Struct) error {
+	annotation := func(key string) (string, bool) {
+		v, ok := field.Annotations[key]
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprint(v), true
+	}
+
+	color, _ := annotation(datasource.ColorAnnotation)
+	style, _ := annotation(datasource.StyleAnnotation)
+	seq, err := datasource.StyleSequence(color, style)
+	if err != nil {
+		return err
+	}
+
+	when, ok := annotation(datasource.StyleWhenAnnotation)
+	if !ok {
+		return nil
+	}
+	if seq == "" {
+		return fmt.Errorf("%s requires %s or %s", datasource.StyleWhenAnnotation,
+			datasource.ColorAnnotation, datasource.StyleAnnotation)
+	}
+	cond, err := datasource.ParseStyleCondition(when)
+	if err != nil {
+		return err
+	}
+	for _, f := range s.Fields {
+		if f.Name == cond.Field {
+			return nil
+		}
+	}
+	return fmt.Errorf("condition %q refers to unknown field %q", when, cond.Field)
+}
+
+// charArrayLen returns the number of elements of typ if it's an array of
+// 1-byte integers (char, __u8, etc.), following typedefs and qualifiers. It
+// returns 0 otherwise.
+func charArrayLen(typ btf.Type) uint {
+	typ = btfhelpers.GetUnderlyingType(typ)
+
+	arr, ok := typ.(*btf.Array)
+	if !ok {
+		return 0
+	}
+
+	elemType := arr.Type
+	elemType = btfhelpers.GetUnderlyingType(elemType)
+
+	elem, ok := elemType.(*btf.Int)
+	if !ok || elem.Size != 1 || elem.Encoding == btf.Bool {
+		return 0
+	}
+
+	return uint(arr.Nelems)
+}
+
+// isPathLike returns true if the name of a member suggests it holds a path or
+// a file name
+func isPathLike(name string) bool {
+	name = strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	for _, s := range []string{"path", "file", "name"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFieldMaxWidth checks that the column of a char array isn't wider
+// than the string it can hold
+func validateFieldMaxWidth(field metadatav1.Field, member btf.Member) error {
+	n := charArrayLen(member.Type)
+	if n == 0 || field.Attributes.MaxWidth <= n {
+		return nil
+	}
+	return fmt.Errorf("maxWidth is %d but the buffer only holds %d characters", field.Attributes.MaxWidth, n)
+}
+
+// enumType returns typ as an enum, following typedefs and qualifiers. It
+// returns nil if typ isn't an enum.
+func enumType(typ btf.Type) *btf.Enum {
+	typ = btfhelpers.GetUnderlyingType(typ)
+
+	enum, _ := typ.(*btf.Enum)
+	return enum
+}
+
+// enumValues returns the value-to-label mapping of enum along with the length
+// of its longest label.
+func enumValues(enum *btf.Enum) (map[int64]string, uint) {
+	values := make(map[int64]string, len(enum.Values))
+	var width uint
+	for _, v := range enum.Values {
+		// Keep the first name if several enumerators share the same value
+		if _, ok := values[int64(v.Value)]; !ok {
+			values[int64(v.Value)] = v.Name
+		}
+		width = max(width, uint(len(v.Name)))
+	}
+	return values, width
+}
+
+func populateTracers(m *metadatav1.GadgetMetadata, idx *btfIndex, opts populateOptions, report *PopulateReport) error {
+	spec := idx.spec
+	tracerInfo, err := firstMarker(idx.markers.Tracers, "tracer")
+	if err != nil {
+		return err
+	}
+	if tracerInfo == nil {
+		log.Debug("No tracer found in eBPF object")
+		return nil
+	}
+
+	if m.Tracers == nil {
+		m.Tracers = make(map[string]metadatav1.Tracer)
+	}
+
+	tracerMap := spec.Maps[tracerInfo.Map]
+	if tracerMap == nil {
+		return fmt.Errorf("map %q not found in eBPF object", tracerInfo.Map)
+	}
+
+	if err := validateTracerMap(tracerMap, ""); err != nil {
+		return fmt.Errorf("tracer map is invalid: %w", err)
+	}
+
+	tracerMapStruct, err := idx.structByName(tracerInfo.Type)
+	if err != nil {
+		return fmt.Errorf("finding struct %q in eBPF object: %w", tracerInfo.Type, err)
+	}
+
+	report.addTracer(tracerInfo.Name)
+
+	if _, found := m.Tracers[tracerInfo.Name]; !found {
+		log.Debugf("Adding tracer %q with map %q and struct %q",
+			tracerInfo.Name, tracerMap.Name, tracerMapStruct.Name)
+
+		m.Tracers[tracerInfo.Name] = metadatav1.Tracer{
+			MapName:    tracerMap.Name,
+			StructName: tracerMapStruct.Name,
+		}
+	} else {
+		log.Debugf("Tracer %q already defined, skipping", tracerInfo.Name)
+	}
+
+	if err := populateStruct(m, tracerMapStruct, spec.Types, opts, report); err != nil {
+		return fmt.Errorf("populating struct: %w", err)
+	}
+
+	if err := populateTracerVariants(m, m.Tracers[tracerInfo.Name], idx, opts, report); err != nil {
+		return fmt.Errorf("tracer %q: %w", tracerInfo.Name, err)
+	}
+
+	return nil
+}
+
+func populateToppers(m *metadatav1.GadgetMetadata, idx *btfIndex, opts populateOptions, report *PopulateReport) error {
+	spec := idx.spec
+	topperInfo, err := firstMarker(idx.markers.Toppers, "topper")
+	if err != nil {
+		return fmt.Errorf("getting topper info: %w", err)
+	}
+	if topperInfo == nil {
+		log.Debug("No topper found in eBPF object")
+		return nil
+	}
+
+	if m.Toppers == nil {
+		m.Toppers = make(map[string]metadatav1.Topper)
+	}
+
+	topperMap := spec.Maps[topperInfo.Map]
+	if topperMap == nil {
+		return fmt.Errorf("map %q not found in eBPF object", topperInfo.Map)
+	}
+
+	t, found := m.Toppers[topperInfo.Name]
+	if err := validateTopperMap(topperMap, t.StructName); err != nil {
+		return err
+	}
+
+	topperMapStruct, err := idx.structByName(topperMap.Value.TypeName())
+	if err != nil {
+		return fmt.Errorf("finding struct %q in eBPF object: %w", topperMap.Value.TypeName(), err)
+	}
+
+	report.addTopper(topperInfo.Name)
+
+	if !found {
+		log.Debugf("Adding topper %q with map %q and struct %q",
+			topperInfo.Name, topperMap.Name, topperMapStruct.Name)
+
+		t = metadatav1.Topper{
+			MapName:    topperMap.Name,
+			StructName: topperMapStruct.Name,
+		}
+	} else {
+		log.Debugf("Topper %q already defined, skipping", topperInfo.Name)
+	}
+
+	if keyStruct, ok := topperMap.Key.(*btf.Struct); ok && t.KeyStructName == "" {
+		t.KeyStructName = keyStruct.Name
+	}
+	m.Toppers[topperInfo.Name] = t
+
+	if err := populateStruct(m, topperMapStruct, spec.Types, opts, report); err != nil {
+		return fmt.Errorf("populating struct: %w", err)
+	}
+
+	return nil
+}
+
+func populateProfilers(m *metadatav1.GadgetMetadata, idx *btfIndex, opts populateOptions, report *PopulateReport) error {
+	spec := idx.spec
+	profilerInfo, err := firstMarker(idx.markers.Profilers, "profiler")
+	if err != nil {
+		return fmt.Errorf("getting profiler info: %w", err)
+	}
+	if profilerInfo == nil {
+		log.Debug("No profiler found in eBPF object")
+		return nil
+	}
+
+	if m.Profilers == nil {
+		m.Profilers = make(map[string]metadatav1.Profiler)
+	}
+
+	profilerMap := spec.Maps[profilerInfo.Map]
+	if profilerMap == nil {
+		return fmt.Errorf("map %q not found in eBPF object", profilerInfo.Map)
+	}
+
+	if err := validateProfilerMap(profilerMap); err != nil {
+		return err
+	}
+
+	report.addProfiler(profilerInfo.Name)
+
+	p, found := m.Profilers[profilerInfo.Name]
+	if !found {
+		log.Debugf("Adding profiler %q with map %q", profilerInfo.Name, profilerMap.Name)
+		p = metadatav1.Profiler{
+			MapName: profilerMap.Name,
+		}
+	} else {
+		log.Debugf("Profiler %q already defined, skipping", profilerInfo.Name)
+	}
+
+	keyStruct, ok := profilerMap.Key.(*btf.Struct)
+	if ok && p.KeyStructName == "" {
+		p.KeyStructName = keyStruct.Name
+	}
+	m.Profilers[profilerInfo.Name] = p
+
+	if !ok {
+		return nil
+	}
+	if err := populateStruct(m, keyStruct, spec.Types, opts, report); err != nil {
+		return fmt.Errorf("populating struct: %w", err)
+	}
+
+	return nil
+}
+
+// GetGadgetIdentByPrefix returns the strings generated by GADGET_ macros.
+func GetGadgetIdentByPrefix(spec *ebpf.CollectionSpec, prefix string) ([]string, error) {
+	return newBTFIndex(spec).identsByPrefix(prefix)
+}
+
+func populateStruct(m *metadatav1.GadgetMetadata, btfStruct *btf.Struct, types *btf.Spec, opts populateOptions, report *PopulateReport) error {
+	if m.Structs == nil {
+		m.Structs = make(map[string]metadatav1.Struct)
+	}
+
+	gadgetStruct := m.Structs[btfStruct.Name]
+	existingFields := make(map[string]int)
+	for i, field := range gadgetStruct.Fields {
+		existingFields[field.Name] = i
+	}
+
+	members, err := flattenMembers(btfStruct.Members, "", 0)
+	if err != nil {
+		return fmt.Errorf("struct %q: %w", btfStruct.Name, err)
+	}
+
+	tags := btfhelpers.GetDeclTags(types)
+	descriptions := make(map[string]string)
+	memberDescriptions(btfStruct, "", 0, tags, descriptions)
+	flags := memberFlags(btfStruct, types, tags)
+
+	// Prefixes of the members of named unions, which are variants sharing the
+	// same bytes
+	var unionPrefixes []string
+
+	for idx, member := range members {
+		if namedUnion(member.Type) != nil {
+			unionPrefixes = append(unionPrefixes, member.Name+".")
+		}
+
+		if !opts.allMembers && isPaddingMember(btfStruct, members, idx) {
+			report.skipMember(btfStruct.Name, member.Name, SkipReasonPadding)
+			continue
+		}
+
+		// check if field already exists
+		if i, ok := existingFields[member.Name]; ok {
+			existing := &gadgetStruct.Fields[i]
+			if desc, ok := descriptions[member.Name]; ok && existing.Description == fieldDescTODO {
+				log.Debugf("Setting description of field %q", member.Name)
+				existing.Description = desc
+			}
+			report.skipMember(btfStruct.Name, member.Name, SkipReasonExists)
+			continue
+		}
+
+		description := fieldDescTODO
+		if desc, ok := descriptions[member.Name]; ok {
+			description = desc
+		}
+
+		report.addField(btfStruct.Name, member.Name)
+		field := metadatav1.Field{
+			Name:        member.Name,
+			Description: description,
+			Attributes: metadatav1.FieldAttributes{
+				Width:     getColumnSize(member.Type),
+				Alignment: getAlignment(member.Type, opts),
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+		}
+
+		// Char arrays are shown as strings: keep the column reasonably small
+		// but allow it to grow up to the full length of the array.
+		if n := charArrayLen(member.Type); n > 0 {
+			field.Attributes.MaxWidth = n
+
+			// Keep both ends of paths visible, the start and the file name
+			if isPathLike(member.Name) {
+				field.Attributes.Ellipsis = metadatav1.EllipsisMiddle
 			}
-			if netNsIdType == m.Type.TypeName() {
-				netnsFields++
+		}
+
+		// Enums are shown using the name of the enumerators
+		if enum := enumType(member.Type); enum != nil && len(enum.Values) > 0 {
+			field.Values, _ = enumValues(enum)
+		}
+
+		// Pointers are shown as hex addresses
+		if isPointer(member.Type) {
+			field.Attributes.Base = metadatav1.BaseHex
+		}
+
+		// in6_addr members are shown as IPv6 addresses
+		if int128Repr(field, member) == int128IPv6 {
+			field.Attributes.Template = "ipaddr"
+			field.Attributes.Width = ipv6ColumnWidth
+			field.Annotations = map[string]interface{}{
+				int128Annotation: int128IPv6,
 			}
 		}
 
-		if mntnsFields > 1 {
-			log.Warnf("Using multiple fields of %q may cause unpredictable behavior during enrichment",
-				compat.MntNsIdType)
+		// Raw addresses are guessed from the name and size of the member:
+		// the author has to confirm the guess
+		if version := guessIPVersion(member); version != 0 {
+			field.Attributes.Template = "ipaddr"
+			field.Attributes.IPVersion = version
+			field.Attributes.Width = ipColumnWidth(version)
+			warning := fmt.Sprintf("member %q of struct %q looks like an IPv%d address, check its ipversion attribute",
+				member.Name, btfStruct.Name, version)
+			log.Warn(warning)
+			report.addWarning(warning)
 		}
-		if netnsFields > 1 {
-			log.Warnf("Using multiple fields of %q may cause unpredictable behavior during enrichment",
-				compat.NetNsIdType)
+
+		// MAC addresses are also guessed from the name and size of the
+		// member. They're shown in hex, not as strings.
+		if isMacAddr(member) && isMacLike(member.Name) {
+			field.Attributes.Template = macAddrTemplate
+			field.Attributes.Width = macAddrColumnWidth
+			field.Attributes.MaxWidth = 0
+			warning := fmt.Sprintf("member %q of struct %q looks like a MAC address, check its %q template",
+				member.Name, btfStruct.Name, macAddrTemplate)
+			log.Warn(warning)
+			report.addWarning(warning)
 		}
 
-		for fieldName := range mapStructFields {
-			if _, ok := btfStructFields[fieldName]; !ok {
-				result = multierror.Append(result, fmt.Errorf("field %q not found in eBPF struct %q", fieldName, name))
+		// User and group ids get a companion column with their name
+		if template := idTemplate(member); template != "" {
+			field.Attributes.Template = template
+		}
+
+		// Syscall numbers are shown by name
+		if isSyscallLike(member) {
+			field.Attributes.Template = syscallTemplate
+			field.Attributes.Width = syscallColumnWidth
+		}
+
+		// Latencies are shown as durations; JSON keeps the raw nanoseconds
+		if isDurationLike(member) {
+			field.Attributes.Template = durationTemplate
+			field.Attributes.Width = getDurationColumnSize(defaultFloatPrecision)
+			warning := fmt.Sprintf("member %q of struct %q looks like a duration in nanoseconds, check its %q template",
+				member.Name, btfStruct.Name, durationTemplate)
+			log.Warn(warning)
+			report.addWarning(warning)
+		}
+
+		// Errors are shown by name. Return values are left to the author:
+		// not all of them are errors.
+		if isErrnoLike(member) {
+			field.Attributes.Template = errnoTemplate
+			field.Attributes.Width = errno.MaxNameLen
+		}
+
+		// Endpoints are shown as a single address (and port) column
+		if kind, ok := getEndpointKind(member.Type); ok {
+			field.Attributes.Template = kind.template
+			field.Attributes.Width = kind.width
+			field.Annotations = map[string]interface{}{
+				endpointAnnotation: kind.name,
+			}
+		}
+
+		// Show the names of the flags set in flag-style integers
+		if enum, ok := flags[member.Name]; ok {
+			addBitmaskAnnotations(&field, member, enum)
+		}
+
+		// Members prefixed with an underscore are internal: keep them
+		// available, e.g. for JSON, but don't show them by default
+		if !opts.allMembers && isInternalMember(member.Name) {
+			field.Attributes.Hidden = true
+		}
+
+		// Only the active variant of a union should be shown, see VariantOf
+		for _, prefix := range unionPrefixes {
+			if strings.HasPrefix(member.Name, prefix) {
+				field.Attributes.Hidden = true
+				break
+			}
+		}
+
+		// Only scalar values can be compared when filtering and sorting;
+		// fields are filterable and sortable unless stated otherwise
+		if !isComparable(member) {
+			comparable := false
+			field.Attributes.Filterable = &comparable
+			field.Attributes.Sortable = &comparable
+		}
+
+		if member.BitfieldSize > 0 {
+			field.Attributes.Width = getBitfieldColumnSize(member)
+			field.Annotations = map[string]interface{}{
+				bitfieldOffsetAnnotation: uint32(member.Offset),
+				bitfieldSizeAnnotation:   uint32(member.BitfieldSize),
 			}
 		}
+
+		gadgetStruct.Fields = append(gadgetStruct.Fields, field)
 	}
 
-	return result
+	m.Structs[btfStruct.Name] = gadgetStruct
+
+	return nil
 }
 
-func validateEbpfParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	var result error
-	for varName := range m.EBPFParams {
-		if err := checkParamVar(spec, varName); err != nil {
-			result = multierror.Append(result, err)
+// nestedStruct returns typ as a struct that can be flattened, following
+// typedefs. It returns nil otherwise.
+func nestedStruct(typ btf.Type) *btf.Struct {
+	typ = btfhelpers.GetUnderlyingType(typ)
+
+	st, ok := typ.(*btf.Struct)
+	if !ok {
+		return nil
+	}
+	if _, ok := opaqueStructs[st.Name]; ok {
+		return nil
+	}
+	return st
+}
+
+// getEndpointKind returns the kind of endpoint stored by a member of type typ,
+// following typedefs and qualifiers
+func getEndpointKind(typ btf.Type) (endpointKind, bool) {
+	for name, kind := range endpointKinds {
+		if isSpecialType(typ, name) {
+			return kind, true
 		}
-		if len(m.EBPFParams[varName].Key) == 0 {
-			result = multierror.Append(result, fmt.Errorf("param %q has an empty key", varName))
+	}
+	return endpointKind{}, false
+}
+
+// isSpecialType returns whether typ is the type with the given name, like
+// gadget_mntns_id, or a typedef of it. Qualifiers are ignored.
+func isSpecialType(typ btf.Type, name string) bool {
+	for typ != nil {
+		if typ.TypeName() == name {
+			return true
+		}
+		switch t := typ.(type) {
+		case *btf.Typedef:
+			typ = t.Type
+		case *btf.Const:
+			typ = t.Type
+		case *btf.Volatile:
+			typ = t.Type
+		case *btf.Restrict:
+			typ = t.Type
+		default:
+			return false
 		}
 	}
-	return result
+	return false
 }
 
-func validateGadgetParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	var result error
-	for _, p := range spec.Programs {
-		switch p.Type {
-		// Networking programs provide an interface name to attach to
-		case ebpf.SchedCLS:
-			if len(m.GadgetParams) == 0 {
-				result = multierror.Append(result, fmt.Errorf("there aren't gadget parameters"))
-			} else {
-				if _, ok := m.GadgetParams[IfaceParam]; !ok {
-					result = multierror.Append(result, fmt.Errorf("iface param not found"))
+// countSpecialMembers returns the number of members of the type with the given
+// name (see isSpecialType), including the ones of structs embedded one level
+// down
+func countSpecialMembers(members []btf.Member, name string) int {
+	count := 0
+	for _, member := range members {
+		if isSpecialType(member.Type, name) {
+			count++
+			continue
+		}
+		if st, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Struct); ok {
+			for _, inner := range st.Members {
+				if isSpecialType(inner.Type, name) {
+					count++
 				}
 			}
 		}
 	}
-	return result
+	return count
 }
 
-// Populate fills the metadata from its ebpf spec
-func Populate(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	if m.Name == "" {
-		m.Name = "TODO: Fill the gadget name"
+// isAddrLike returns true if the name of a member suggests it stores an IP
+// address, like addr, saddr or dst_addr
+func isAddrLike(name string) bool {
+	name = strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	return strings.HasSuffix(name, "addr")
+}
+
+// ipAddrSize returns the size of a member that can store an IP address: an
+// integer (not a bool), an array of integers or in6_addr. It returns 0 for
+// other members.
+func ipAddrSize(typ btf.Type) uint32 {
+	switch t := btfhelpers.GetUnderlyingType(typ).(type) {
+	case *btf.Int:
+		if t.Encoding == btf.Bool {
+			return 0
+		}
+		return t.Size
+	case *btf.Array:
+		if _, ok := btfhelpers.GetUnderlyingType(t.Type).(*btf.Int); !ok {
+			return 0
+		}
+		size, err := btf.Sizeof(t)
+		if err != nil {
+			return 0
+		}
+		return uint32(size)
+	case *btf.Struct:
+		if t.Name == "in6_addr" {
+			return t.Size
+		}
+	}
+	return 0
+}
+
+// ipVersionSize returns the size in bytes of an address of the given version
+func ipVersionSize(version uint) uint32 {
+	switch version {
+	case 4:
+		return net.IPv4len
+	case 6:
+		return net.IPv6len
+	}
+	return 0
+}
+
+func ipColumnWidth(version uint) uint {
+	if version == 4 {
+		return ipv4ColumnWidth
 	}
+	return ipv6ColumnWidth
+}
 
-	if m.Description == "" {
-		m.Description = "TODO: Fill the gadget description"
+// guessIPVersion returns the IP version of members named like addresses:
+// unsigned 32-bit integers are IPv4 addresses and 16-byte arrays IPv6 ones.
+// in6_addr members are handled by int128Repr. It returns 0 for other members.
+func guessIPVersion(member btf.Member) uint {
+	if member.BitfieldSize > 0 || !isAddrLike(member.Name) {
+		return 0
+	}
+	switch t := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if t.Size == net.IPv4len && t.Encoding == btf.Unsigned {
+			return 4
+		}
+	case *btf.Array:
+		if ipAddrSize(t) == net.IPv6len {
+			return 6
+		}
 	}
+	return 0
+}
 
-	if m.HomepageURL == "" {
-		m.HomepageURL = "TODO: Fill the gadget homepage URL"
+// validateFieldIPVersion checks that fields with an IP version are stored in
+// members of the size of an address of that version
+func validateFieldIPVersion(field metadatav1.Field, member btf.Member) error {
+	version := field.Attributes.IPVersion
+	if version == 0 {
+		return nil
 	}
 
-	if m.DocumentationURL == "" {
-		m.DocumentationURL = "TODO: Fill the gadget documentation URL"
+	expected := ipVersionSize(version)
+	if expected == 0 {
+		return fmt.Errorf("invalid ipversion %d: expected 4 or 6", version)
+	}
+	if member.BitfieldSize > 0 {
+		return errors.New("ipversion can't be set on bitfields")
 	}
+	size := ipAddrSize(member.Type)
+	if size == 0 {
+		return errors.New("ipversion can only be set on integers, arrays of integers or in6_addr")
+	}
+	if size != expected {
+		return fmt.Errorf("ipversion %d needs a %d-byte member, got %d bytes", version, expected, size)
+	}
+	if repr := int128Repr(field, member); repr != "" && repr != int128IPv6 {
+		return fmt.Errorf("ipversion can't be set on fields with %s annotation %q", int128Annotation, repr)
+	}
+	return nil
+}
+
+// isMacLike returns true if the name of a member suggests it stores a MAC
+// address, like mac or src_mac
+func isMacLike(name string) bool {
+	name = strings.ToLower(name[strings.LastIndex(name, ".")+1:])
+	return strings.Contains(name, "mac")
+}
+
+// isMacAddr returns true if member can store a MAC address: an array of 6
+// bytes
+func isMacAddr(member btf.Member) bool {
+	return member.BitfieldSize == 0 && charArrayLen(member.Type) == macAddrLen
+}
 
-	if m.SourceURL == "" {
-		m.SourceURL = "TODO: Fill the gadget source code URL"
+// validateFieldMacAddr checks that only arrays of 6 bytes use the "macaddr"
+// template
+func validateFieldMacAddr(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != macAddrTemplate || isMacAddr(member) {
+		return nil
 	}
+	return fmt.Errorf("template %q can only be used by arrays of %d bytes", macAddrTemplate, macAddrLen)
+}
 
-	if err := populateTracers(m, spec); err != nil {
-		return fmt.Errorf("handling tracers: %w", err)
+// isU32 returns true if member is a 32-bit unsigned integer
+func isU32(member btf.Member) bool {
+	if member.BitfieldSize > 0 {
+		return false
 	}
+	t, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+	return ok && t.Size == 4 && t.Encoding == btf.Unsigned
+}
 
-	if err := populateToppers(m, spec); err != nil {
-		return fmt.Errorf("handling toppers: %w", err)
+// idTemplate returns the template of members storing a user or group id:
+// 32-bit unsigned integers named uid or gid. It returns "" for other members.
+func idTemplate(member btf.Member) string {
+	if !isU32(member) {
+		return ""
 	}
+	switch strings.ToLower(member.Name[strings.LastIndex(member.Name, ".")+1:]) {
+	case "uid":
+		return uidTemplate
+	case "gid":
+		return gidTemplate
+	}
+	return ""
+}
 
-	if err := populateSnapshotters(m, spec); err != nil {
-		return fmt.Errorf("handling snapshotters: %w", err)
+// validateFieldIdTemplate checks that only 32-bit unsigned integers use the
+// "uid" and "gid" templates
+func validateFieldIdTemplate(field metadatav1.Field, member btf.Member) error {
+	switch field.Attributes.Template {
+	case uidTemplate, gidTemplate:
+		if !isU32(member) {
+			return fmt.Errorf("template %q can only be used by u32 fields", field.Attributes.Template)
+		}
 	}
+	return nil
+}
 
-	if err := populateEbpfParams(m, spec); err != nil {
-		return fmt.Errorf("handling params: %w", err)
+// isSignedInt returns true if member is a signed integer of up to 64 bits
+func isSignedInt(member btf.Member) bool {
+	if member.BitfieldSize > 0 {
+		return false
 	}
+	t, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+	return ok && t.Size <= 8 && t.Encoding == btf.Signed
+}
 
-	if err := populateGadgetParams(m, spec); err != nil {
-		return fmt.Errorf("handling gadget params: %w", err)
+// isInteger returns true if member is an integer of up to 64 bits, not a bool
+func isInteger(member btf.Member) bool {
+	if member.BitfieldSize > 0 {
+		return false
 	}
+	t, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+	return ok && t.Size <= 8 && t.Encoding != btf.Bool
+}
+
+// isSyscallLike returns true if member is an integer named syscall or
+// nr_syscall
+func isSyscallLike(member btf.Member) bool {
+	switch strings.ToLower(member.Name[strings.LastIndex(member.Name, ".")+1:]) {
+	case "syscall", "nr_syscall":
+		return isInteger(member)
+	}
+	return false
+}
+
+// validateFieldSyscall checks that only integers use the "syscall" template
+func validateFieldSyscall(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != syscallTemplate || isInteger(member) {
+		return nil
+	}
+	return fmt.Errorf("template %q can only be used by integer fields", syscallTemplate)
+}
 
+// validateFieldStackMap checks that fields referencing a stack map are
+// integers and that the map exists and is a stack trace map
+func validateFieldStackMap(field metadatav1.Field, member btf.Member, spec *ebpf.CollectionSpec) error {
+	mapName := field.Attributes.StackMap
+	if mapName == "" {
+		return nil
+	}
+	if !isInteger(member) {
+		return fmt.Errorf("stackMap can only be used by integer fields")
+	}
+	stackMap, ok := spec.Maps[mapName]
+	if !ok {
+		return fmt.Errorf("stack map %q not found in eBPF object", mapName)
+	}
+	if stackMap.Type != ebpf.StackTrace {
+		return fmt.Errorf("map %q has type %s, expected %s", mapName, stackMap.Type, ebpf.StackTrace)
+	}
 	return nil
 }
 
-func getColumnSize(typ btf.Type) uint {
-	switch typedMember := typ.(type) {
-	case *btf.Int:
-		switch typedMember.Encoding {
-		case btf.Signed:
-			switch typedMember.Size {
-			case 1:
-				return columns.MaxCharsInt8
-			case 2:
-				return columns.MaxCharsInt16
-			case 4:
-				return columns.MaxCharsInt32
-			case 8:
-				return columns.MaxCharsInt64
+// isDurationLike returns true if member is an integer whose name suggests a
+// duration in nanoseconds, like latency_ns or delta_ns
+func isDurationLike(member btf.Member) bool {
+	if !isInteger(member) {
+		return false
+	}
+	name := strings.ToLower(member.Name[strings.LastIndex(member.Name, ".")+1:])
+	return strings.HasPrefix(name, "latency") || strings.HasPrefix(name, "duration") || name == "delta_ns"
+}
 
+// getDurationColumnSize returns the width needed to show durations with the
+// given precision, see durationTemplate
+func getDurationColumnSize(precision uint) uint {
+	return uint(datasource.DurationWidth(int(precision)))
+}
+
+// validateFieldDuration checks that only integers use the "duration" template
+// and that their unit is a time one
+func validateFieldDuration(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != durationTemplate {
+		return nil
+	}
+	if !isInteger(member) {
+		return fmt.Errorf("template %q can only be used by integer fields", durationTemplate)
+	}
+	switch field.Attributes.Unit {
+	case metadatav1.UnitNone, metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds, metadatav1.UnitMilliseconds:
+		return nil
+	}
+	return fmt.Errorf("template %q needs a unit of %q, %q or %q, got %q", durationTemplate,
+		metadatav1.UnitNanoseconds, metadatav1.UnitMicroseconds, metadatav1.UnitMilliseconds, field.Attributes.Unit)
+}
+
+// validateFieldBoolLabels checks that only booleans and 1-byte integers, used
+// as booleans, have labels
+func validateFieldBoolLabels(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.BoolLabels == nil {
+		return nil
+	}
+	if t, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int); ok && t.Size == 1 {
+		return nil
+	}
+	return errors.New("boolLabels can only be used by booleans and 1-byte integers")
+}
+
+// validateFieldEncoding checks that the encoding is a known one and that only
+// byte arrays set it
+func validateFieldEncoding(field metadatav1.Field, member btf.Member) error {
+	switch field.Attributes.Encoding {
+	case "":
+		return nil
+	case metadatav1.EncodingUTF8, metadatav1.EncodingHex, metadatav1.EncodingBase64:
+	default:
+		return fmt.Errorf("invalid encoding %q, expected %q, %q or %q", field.Attributes.Encoding,
+			metadatav1.EncodingUTF8, metadatav1.EncodingHex, metadatav1.EncodingBase64)
+	}
+	if charArrayLen(member.Type) == 0 {
+		return errors.New("encoding can only be used by arrays of bytes")
+	}
+	return nil
+}
+
+// validateFieldRedact checks the redaction mode and that only strings are
+// redacted
+func validateFieldRedact(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Redact == "" {
+		return nil
+	}
+	if _, err := redact.Parse(field.Attributes.Redact); err != nil {
+		return err
+	}
+	if charArrayLen(member.Type) == 0 {
+		return errors.New("redact can only be used by string fields")
+	}
+	return nil
+}
+
+// validateFieldAutoWidth checks that columns sized from their content have an
+// upper bound and no fixed width
+func validateFieldAutoWidth(field metadatav1.Field) error {
+	attrs := field.Attributes
+	if !attrs.AutoWidth {
+		return nil
+	}
+	if attrs.MaxWidth == 0 {
+		return errors.New("autoWidth requires maxWidth")
+	}
+	if attrs.Width != 0 {
+		return fmt.Errorf("width must be 0 when autoWidth is set, got %d", attrs.Width)
+	}
+	if attrs.MinWidth > attrs.MaxWidth {
+		return fmt.Errorf("minWidth %d is bigger than maxWidth %d", attrs.MinWidth, attrs.MaxWidth)
+	}
+	return nil
+}
+
+// validateFieldExamples checks that the examples of fields with known values
+// are valid: labels of fields with a values mapping, or numbers of fields shown
+// as they are
+func validateFieldExamples(field metadatav1.Field, member btf.Member) error {
+	if len(field.Examples) == 0 {
+		return nil
+	}
+
+	if len(field.Values) > 0 {
+		labels := make(map[string]struct{}, len(field.Values)+1)
+		for _, label := range field.Values {
+			labels[label] = struct{}{}
+		}
+		if field.DefaultLabel != "" {
+			labels[field.DefaultLabel] = struct{}{}
+		}
+		for _, example := range field.Examples {
+			if _, ok := labels[example]; !ok {
+				return fmt.Errorf("example %q isn't one of the values of the field", example)
 			}
-		case btf.Unsigned:
-			switch typedMember.Size {
-			case 1:
-				return columns.MaxCharsUint8
-			case 2:
-				return columns.MaxCharsUint16
-			case 4:
-				return columns.MaxCharsUint32
-			case 8:
-				return columns.MaxCharsUint64
-			}
-		case btf.Bool:
-			return columns.MaxCharsBool
-		case btf.Char:
-			return columns.MaxCharsChar
 		}
-	case *btf.Typedef:
-		typ := btfhelpers.GetUnderlyingType(typedMember)
-		return getColumnSize(typ)
+		return nil
 	}
 
-	return metadatav1.DefaultColumnWidth
+	// Templates and other attributes change how numbers are shown
+	attrs := field.Attributes
+	if attrs.Template != "" || attrs.Humanize || attrs.BoolLabels != nil || attrs.Base != metadatav1.BaseDecimal ||
+		len(field.Flags) > 0 {
+		return nil
+	}
+
+	var parse func(string) error
+	switch t := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		bits := int(t.Size) * 8
+		switch {
+		case bits > 64:
+			// 128-bit numbers don't fit in strconv
+			return nil
+		case t.Encoding == btf.Bool:
+			parse = func(s string) error { _, err := strconv.ParseBool(s); return err }
+		case t.Encoding == btf.Signed:
+			parse = func(s string) error { _, err := strconv.ParseInt(s, 10, bits); return err }
+		default:
+			parse = func(s string) error { _, err := strconv.ParseUint(s, 10, bits); return err }
+		}
+	case *btf.Float:
+		parse = func(s string) error { _, err := strconv.ParseFloat(s, int(t.Size)*8); return err }
+	default:
+		return nil
+	}
+
+	for _, example := range field.Examples {
+		if example == attrs.ZeroAs {
+			continue
+		}
+		if err := parse(example); err != nil {
+			return fmt.Errorf("example %q isn't a valid %s", example, btfTypeName(member.Type))
+		}
+	}
+	return nil
 }
 
-func populateTracers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	tracerInfo, err := getTracerInfo(spec)
-	if err != nil {
-		return err
+// validateFieldZeroAs checks that only scalar fields set a placeholder for
+// their zero value
+func validateFieldZeroAs(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.ZeroAs == "" {
+		return nil
 	}
-	if tracerInfo == nil {
-		log.Debug("No tracer found in eBPF object")
+	switch t := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if t.Size <= 8 {
+			return nil
+		}
+	case *btf.Enum, *btf.Float, *btf.Pointer:
 		return nil
 	}
+	return errors.New("zeroAs can only be used by scalar fields")
+}
 
-	if m.Tracers == nil {
-		m.Tracers = make(map[string]metadatav1.Tracer)
+// isSignal returns true if member can store a signal: an integer of up to 32
+// bits
+func isSignal(member btf.Member) bool {
+	return isInteger(member) && btfhelpers.GetUnderlyingType(member.Type).(*btf.Int).Size <= 4
+}
+
+// validateFieldSignal checks that only integers of up to 32 bits use the
+// "signal" template
+func validateFieldSignal(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != signalTemplate || isSignal(member) {
+		return nil
 	}
+	return fmt.Errorf("template %q can only be used by integer fields of up to 32 bits", signalTemplate)
+}
 
-	tracerMap := spec.Maps[tracerInfo.mapName]
-	if tracerMap == nil {
-		return fmt.Errorf("map %q not found in eBPF object", tracerInfo.mapName)
+// isCapabilities returns true if member can store a capability set, a 64-bit
+// unsigned integer like kernel_cap_t
+func isCapabilities(member btf.Member) bool {
+	return isInteger(member) && !btfhelpers.IsSigned(member.Type) &&
+		btfhelpers.GetUnderlyingType(member.Type).(*btf.Int).Size == 8
+}
+
+// validateFieldCapabilities checks that only 64-bit unsigned integers use the
+// "capabilities" template
+func validateFieldCapabilities(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != capabilitiesTemplate || isCapabilities(member) {
+		return nil
 	}
+	return fmt.Errorf("template %q can only be used by u64 fields", capabilitiesTemplate)
+}
 
-	if err := validateTracerMap(tracerMap, ""); err != nil {
-		return fmt.Errorf("tracer map is invalid: %w", err)
+// isErrnoLike returns true if member is a signed integer named err or errno
+func isErrnoLike(member btf.Member) bool {
+	switch strings.ToLower(member.Name[strings.LastIndex(member.Name, ".")+1:]) {
+	case "err", "errno":
+		return isSignedInt(member)
 	}
+	return false
+}
 
-	var tracerMapStruct *btf.Struct
-	if err := spec.Types.TypeByName(tracerInfo.eventType, &tracerMapStruct); err != nil {
-		return fmt.Errorf("finding struct %q in eBPF object: %w", tracerInfo.eventType, err)
+// validateFieldErrno checks that only signed integers use the "errno"
+// template
+func validateFieldErrno(field metadatav1.Field, member btf.Member) error {
+	if field.Attributes.Template != errnoTemplate || isSignedInt(member) {
+		return nil
 	}
+	return fmt.Errorf("template %q can only be used by signed integer fields", errnoTemplate)
+}
 
-	if _, found := m.Tracers[tracerInfo.name]; !found {
-		log.Debugf("Adding tracer %q with map %q and struct %q",
-			tracerInfo.name, tracerMap.Name, tracerMapStruct.Name)
+// validateFieldEndpoint checks that only endpoint members use the endpoint
+// annotation and the templates specific to them
+func validateFieldEndpoint(field metadatav1.Field, member btf.Member) error {
+	kind, isEndpoint := getEndpointKind(member.Type)
 
-		m.Tracers[tracerInfo.name] = metadatav1.Tracer{
-			MapName:    tracerMap.Name,
-			StructName: tracerMapStruct.Name,
+	if val, ok := field.Annotations[endpointAnnotation]; ok {
+		if !isEndpoint {
+			return fmt.Errorf("%s annotation can only be set on endpoint fields", endpointAnnotation)
+		}
+		if fmt.Sprint(val) != kind.name {
+			return fmt.Errorf("%s annotation is %q, expected %q", endpointAnnotation, val, kind.name)
 		}
-	} else {
-		log.Debugf("Tracer %q already defined, skipping", tracerInfo.name)
 	}
 
-	if err := populateStruct(m, tracerMapStruct); err != nil {
-		return fmt.Errorf("populating struct: %w", err)
+	for _, k := range endpointKinds {
+		// "ipaddr" is also used by fields storing a plain address
+		if k.template == "ipaddr" || field.Attributes.Template != k.template {
+			continue
+		}
+		if !isEndpoint || kind.name != k.name {
+			return fmt.Errorf("template %q can only be used by %s endpoint fields", k.template, k.name)
+		}
 	}
 
 	return nil
 }
 
-func populateToppers(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	topperInfo, err := getTopperInfo(spec)
-	if err != nil {
-		return err
-	}
-	if topperInfo == nil {
-		log.Debug("No topper found in eBPF object")
-		return nil
+// namedUnion returns typ as a union, following typedefs and qualifiers. It
+// returns nil if typ isn't a union.
+func namedUnion(typ btf.Type) *btf.Union {
+	typ = btfhelpers.GetUnderlyingType(typ)
+
+	union, _ := typ.(*btf.Union)
+	return union
+}
+
+// isPaddingMember returns true if the member at idx of the flattened members
+// of btfStruct is only there to align the next one: its name starts with
+// "__pad", or it's a byte array named like "pad" or "reserved" that takes
+// exactly the space the compiler would have added as padding.
+func isPaddingMember(btfStruct *btf.Struct, members []btf.Member, idx int) bool {
+	member := members[idx]
+	name := member.Name
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
 	}
 
-	if m.Toppers == nil {
-		m.Toppers = make(map[string]metadatav1.Topper)
+	if strings.HasPrefix(name, "__pad") {
+		return true
 	}
 
-	topperMap := spec.Maps[topperInfo.mapName]
-	if topperMap == nil {
-		return fmt.Errorf("map %q not found in eBPF object", topperInfo.mapName)
+	lower := strings.ToLower(name)
+	if !strings.Contains(lower, "pad") && !strings.Contains(lower, "reserved") {
+		return false
 	}
 
-	t, found := m.Toppers[topperInfo.name]
-	if err := validateTopperMap(topperMap, t.StructName); err != nil {
-		return err
+	arr, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Array)
+	if !ok || member.BitfieldSize != 0 {
+		return false
+	}
+	elemSize, err := btf.Sizeof(arr.Type)
+	if err != nil || elemSize != 1 {
+		return false
 	}
 
-	var topperMapStruct *btf.Struct
-	if err := spec.Types.TypeByName(topperMap.Value.TypeName(), &topperMapStruct); err != nil {
-		return fmt.Errorf("finding struct %q in eBPF object: %w", topperMap.Value.TypeName(), err)
+	start := member.Offset.Bytes()
+	end := start + arr.Nelems
+
+	// Alignment required by what comes next: the next member or, at the end,
+	// the struct itself
+	var align uint32
+	var next uint32
+	if idx+1 < len(members) {
+		align = alignment(members[idx+1].Type)
+		next = members[idx+1].Offset.Bytes()
+	} else {
+		align = alignment(btfStruct)
+		next = btfStruct.Size
+	}
+	if align <= 1 || end != next {
+		return false
 	}
 
-	if !found {
-		log.Debugf("Adding topper %q with map %q and struct %q",
-			topperInfo.name, topperMap.Name, topperMapStruct.Name)
+	return (start+align-1)/align*align == end
+}
 
-		m.Toppers[topperInfo.name] = metadatav1.Topper{
-			MapName:    topperMap.Name,
-			StructName: topperMapStruct.Name,
+// alignment returns the alignment of typ, following the rules of the C
+// compiler for the BPF target
+func alignment(typ btf.Type) uint32 {
+	switch t := btfhelpers.GetUnderlyingType(typ).(type) {
+	case *btf.Array:
+		return alignment(t.Type)
+	case *btf.Struct:
+		return membersAlignment(t.Members)
+	case *btf.Union:
+		return membersAlignment(t.Members)
+	default:
+		size, err := btf.Sizeof(t)
+		if err != nil || size <= 0 {
+			return 1
 		}
-	} else {
-		log.Debugf("Topper %q already defined, skipping", topperInfo.name)
+		return uint32(min(size, 8))
 	}
+}
 
-	if err := populateStruct(m, topperMapStruct); err != nil {
-		return fmt.Errorf("populating struct: %w", err)
+func membersAlignment(members []btf.Member) uint32 {
+	align := uint32(1)
+	for _, m := range members {
+		align = max(align, alignment(m.Type))
 	}
+	return align
+}
 
-	return nil
+// isInternalMember returns true for members, or members of nested structs,
+// whose name starts with an underscore
+func isInternalMember(name string) bool {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.HasPrefix(name, "_")
 }
 
-// GetGadgetIdentByPrefix returns the strings generated by GADGET_ macros.
-func GetGadgetIdentByPrefix(spec *ebpf.CollectionSpec, prefix string) ([]string, error) {
-	var resultNames []string
-	var resultError error
+// memberDescriptions fills descriptions with the ones set with decl tags on
+// the members of typ, using the names given to them by flattenMembers
+func memberDescriptions(typ btf.Type, prefix string, depth int, tags btfhelpers.DeclTags, descriptions map[string]string) {
+	typ = btfhelpers.GetUnderlyingType(typ)
 
-	it := spec.Types.Iterate()
-	for it.Next() {
-		btfVar, ok := it.Type.(*btf.Var)
-		if !ok {
-			continue
-		}
-		if !strings.HasPrefix(btfVar.Name, prefix) {
+	var members []btf.Member
+	switch t := typ.(type) {
+	case *btf.Struct:
+		members = t.Members
+	case *btf.Union:
+		members = t.Members
+	default:
+		return
+	}
+
+	for idx, member := range members {
+		if member.Name == "" {
+			memberDescriptions(member.Type, prefix, depth, tags, descriptions)
 			continue
 		}
-		if btfVar.Linkage != btf.GlobalVar {
-			resultError = multierror.Append(resultError, fmt.Errorf("%q is not a global variable", btfVar.Name))
+
+		name := prefix + member.Name
+		if desc, ok := tagDescription(tags.Get(typ, idx)); ok {
+			descriptions[name] = desc
 		}
-		btfPtr, ok := btfVar.Type.(*btf.Pointer)
-		if !ok {
-			resultError = multierror.Append(resultError, fmt.Errorf("%q is not a pointer", btfVar.Name))
+
+		if depth >= maxStructFlattenDepth {
 			continue
 		}
-		btfConst, ok := btfPtr.Target.(*btf.Const)
-		if !ok {
-			resultError = multierror.Append(resultError, fmt.Errorf("%q is not const", btfVar.Name))
-			continue
+		if st := nestedStruct(member.Type); st != nil {
+			memberDescriptions(st, name+".", depth+1, tags, descriptions)
+		} else if union := namedUnion(member.Type); union != nil {
+			memberDescriptions(union, name+".", depth+1, tags, descriptions)
 		}
-		_, ok = btfConst.Type.(*btf.Void)
-		if !ok {
-			resultError = multierror.Append(resultError, fmt.Errorf("%q is not a const void pointer", btfVar.Name))
-			continue
+	}
+}
+
+// memberFlags returns the enums holding the names of the flags of the
+// flag-style integer members of btfStruct, indexed by the names given to them by
+// flattenMembers. The enum is set with a decl tag like
+// __attribute__((btf_decl_tag("ig:flags=open_flags"))), "ig:flags=none" opts
+// out. Otherwise, members whose name ends with "flags" use the enum with the
+// same name or, prefixed with the name of the struct, like "event_flags" for
+// the "flags" member of struct event.
+func memberFlags(btfStruct *btf.Struct, types *btf.Spec, tags btfhelpers.DeclTags) map[string]*btf.Enum {
+	flags := make(map[string]*btf.Enum)
+	if types == nil {
+		return flags
+	}
+
+	var walk func(typ btf.Type, prefix string, depth int)
+	walk = func(typ btf.Type, prefix string, depth int) {
+		typ = btfhelpers.GetUnderlyingType(typ)
+
+		var members []btf.Member
+		switch t := typ.(type) {
+		case *btf.Struct:
+			members = t.Members
+		case *btf.Union:
+			members = t.Members
+		default:
+			return
 		}
 
-		resultNames = append(resultNames, strings.TrimPrefix(btfVar.Name, prefix))
-	}
+		for idx, member := range members {
+			if member.Name == "" {
+				walk(member.Type, prefix, depth)
+				continue
+			}
 
-	return resultNames, resultError
-}
+			name := prefix + member.Name
 
-type tracerInfo struct {
-	name      string
-	mapName   string
-	eventType string
-}
+			if _, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int); ok {
+				if enum := flagsEnum(typ, idx, member, types, tags); enum != nil {
+					flags[name] = enum
+				}
+				continue
+			}
 
-// getTracerInfo returns the tracer info generated with GADGET_TRACER().
-// If there are multiple annotations only the first one is returned.
-func getTracerInfo(spec *ebpf.CollectionSpec) (*tracerInfo, error) {
-	tracersInfo, err := GetGadgetIdentByPrefix(spec, tracerInfoPrefix)
-	if err != nil {
-		return nil, err
+			if depth >= maxStructFlattenDepth {
+				continue
+			}
+			if st := nestedStruct(member.Type); st != nil {
+				walk(st, name+".", depth+1)
+			} else if union := namedUnion(member.Type); union != nil {
+				walk(union, name+".", depth+1)
+			}
+		}
 	}
-	if len(tracersInfo) == 0 {
-		return nil, nil
+	walk(btfStruct, "", 0)
+
+	return flags
+}
+
+func flagsEnum(parent btf.Type, idx int, member btf.Member, types *btf.Spec, tags btfhelpers.DeclTags) *btf.Enum {
+	var candidates []string
+	for _, tag := range tags.Get(parent, idx) {
+		if enumName, ok := strings.CutPrefix(tag, flagsTagPrefix); ok {
+			if enumName == flagsTagNone {
+				return nil
+			}
+			candidates = append(candidates, enumName)
+		}
 	}
 
-	if len(tracersInfo) > 1 {
-		log.Warnf("multiple tracers found, using %q", tracersInfo[0])
+	if len(candidates) == 0 {
+		name := strings.TrimSuffix(member.Name, "_raw")
+		if !strings.HasSuffix(name, "flags") {
+			return nil
+		}
+		candidates = append(candidates, name)
+		if parent.TypeName() != "" {
+			candidates = append(candidates, parent.TypeName()+"_"+name)
+		}
 	}
 
-	parts := strings.Split(tracersInfo[0], "___")
-	if len(parts) != 3 {
-		return nil, fmt.Errorf("invalid tracer info: %q", tracersInfo[0])
+	for _, enumName := range candidates {
+		var enum *btf.Enum
+		if err := types.TypeByName(enumName, &enum); err == nil {
+			return enum
+		}
 	}
 
-	return &tracerInfo{
-		name:      parts[0],
-		mapName:   parts[1],
-		eventType: parts[2],
-	}, nil
+	log.Debugf("No enum found for flags member %q", member.Name)
+	return nil
 }
 
-type topperInfo struct {
-	name    string
-	mapName string
+// addBitmaskAnnotations sets the annotations used to show the names of the
+// flags set in field, taken from the enumerators of enum with a single bit
+// set
+func addBitmaskAnnotations(field *metadatav1.Field, member btf.Member, enum *btf.Enum) {
+	flagBits := make(map[uint]string)
+	var zero string
+	for _, v := range enum.Values {
+		switch {
+		case v.Value == 0:
+			zero = v.Name
+		case v.Value&(v.Value-1) == 0:
+			bit := uint(bits.TrailingZeros64(v.Value))
+			if _, ok := flagBits[bit]; !ok {
+				flagBits[bit] = v.Name
+			}
+		default:
+			log.Debugf("Skipping %q of enum %q: it's not a single bit", v.Name, enum.Name)
+		}
+	}
+	if len(flagBits) == 0 {
+		return
+	}
+
+	if field.Annotations == nil {
+		field.Annotations = make(map[string]interface{})
+	}
+	if !strings.HasSuffix(member.Name, "_raw") {
+		name := member.Name
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		field.Annotations[annotations.BitmaskAnnotation] = name + "_str"
+	}
+	field.Annotations[annotations.BitmaskBitsAnnotation] = annotations.FormatBitmask(flagBits)
+	if zero != "" {
+		field.Annotations[annotations.BitmaskZeroAnnotation] = zero
+	}
 }
 
-// getTopperInfo returns the topper info generated with GADGET_TOPPER().
-// If there are multiple annotations only the first one is returned.
-func getTopperInfo(spec *ebpf.CollectionSpec) (*topperInfo, error) {
-	toppersInfo, err := GetGadgetIdentByPrefix(spec, topperInfoPrefix)
+// validateFieldBitmask checks that the bits listed in the bitmask annotation
+// of a field fit the member
+func validateFieldBitmask(field metadatav1.Field, member btf.Member) error {
+	value, ok := field.Annotations[annotations.BitmaskBitsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s must be a string", annotations.BitmaskBitsAnnotation)
+	}
+	flagBits, err := annotations.ParseBitmask(s)
 	if err != nil {
-		return nil, fmt.Errorf("getting topper info: %w", err)
+		return err
+	}
+
+	if _, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int); !ok {
+		return fmt.Errorf("bitmask is only supported for integers, got %s", member.Type)
 	}
-	if len(toppersInfo) == 0 {
-		return nil, nil
+	width := uint(member.BitfieldSize)
+	if width == 0 {
+		size, err := btf.Sizeof(member.Type)
+		if err != nil {
+			return fmt.Errorf("getting size: %w", err)
+		}
+		width = uint(size) * 8
 	}
 
-	if len(toppersInfo) > 1 {
-		log.Warnf("multiple toppers found, using %q", toppersInfo[0])
+	for bit, name := range flagBits {
+		if bit >= width {
+			return fmt.Errorf("bit %d (%s) doesn't fit in %d bits", bit, name, width)
+		}
 	}
+	return nil
+}
 
-	parts := strings.Split(toppersInfo[0], "___")
-	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid topper info: %q", toppersInfo[0])
+// tagDescription returns the description set with a decl tag like
+// __attribute__((btf_decl_tag("ig:desc=...")))
+func tagDescription(tags []string) (string, bool) {
+	for _, tag := range tags {
+		if desc, ok := strings.CutPrefix(tag, descTagPrefix); ok {
+			return desc, true
+		}
 	}
+	return "", false
+}
 
-	return &topperInfo{
-		name:    parts[0],
-		mapName: parts[1],
-	}, nil
+// anonymousMembers returns the members of the struct or union of an anonymous
+// member, following typedefs and qualifiers. It returns nil for any other type.
+func anonymousMembers(typ btf.Type) []btf.Member {
+	typ = btfhelpers.GetUnderlyingType(typ)
+
+	switch t := typ.(type) {
+	case *btf.Struct:
+		return t.Members
+	case *btf.Union:
+		return t.Members
+	}
+	return nil
 }
 
-func populateStruct(m *metadatav1.GadgetMetadata, btfStruct *btf.Struct) error {
-	if m.Structs == nil {
-		m.Structs = make(map[string]metadatav1.Struct)
+// withOffset returns a copy of members with offset added to their offsets.
+func withOffset(members []btf.Member, offset btf.Bits) []btf.Member {
+	result := make([]btf.Member, len(members))
+	for i, m := range members {
+		m.Offset += offset
+		result[i] = m
 	}
+	return result
+}
 
-	gadgetStruct := m.Structs[btfStruct.Name]
-	existingFields := make(map[string]struct{})
-	for _, field := range gadgetStruct.Fields {
-		existingFields[field.Name] = struct{}{}
+// flattenMembers returns the members of a struct with the members of nested
+// structs replaced by their own members, named "outer.inner". Members of
+// anonymous structs and unions are promoted without a prefix, as in C. Named
+// unions are kept as a member followed by their members, the variants, named
+// "union.variant". Structs and unions nested deeper than maxStructFlattenDepth
+// are kept as a single member.
+// Offsets of the returned members are relative to the outermost struct.
+func flattenMembers(members []btf.Member, prefix string, depth int) ([]btf.Member, error) {
+	var result []btf.Member
+	seen := make(map[string]struct{})
+
+	add := func(m btf.Member) error {
+		if _, ok := seen[m.Name]; ok {
+			return fmt.Errorf("field %q defined more than once after flattening nested structs", m.Name)
+		}
+		seen[m.Name] = struct{}{}
+		result = append(result, m)
+		return nil
 	}
 
-	for _, member := range btfStruct.Members {
-		// check if field already exists
-		if _, ok := existingFields[member.Name]; ok {
-			log.Debugf("Field %q already exists, skipping", member.Name)
+	for _, member := range members {
+		name := prefix + member.Name
+
+		if member.Name == "" {
+			// Anonymous members don't add a level of nesting
+			inner, err := flattenMembers(withOffset(anonymousMembers(member.Type), member.Offset), prefix, depth)
+			if err != nil {
+				return nil, err
+			}
+			if len(inner) == 0 {
+				log.Debugf("Skipping anonymous member of type %s", member.Type)
+			}
+			for _, m := range inner {
+				if err := add(m); err != nil {
+					return nil, err
+				}
+			}
 			continue
 		}
 
-		log.Debugf("Adding field %q", member.Name)
-		field := metadatav1.Field{
-			Name:        member.Name,
-			Description: "TODO: Fill field description",
-			Attributes: metadatav1.FieldAttributes{
-				Width:     getColumnSize(member.Type),
-				Alignment: metadatav1.AlignmentLeft,
-				Ellipsis:  metadatav1.EllipsisEnd,
-			},
+		if st := nestedStruct(member.Type); st != nil && depth < maxStructFlattenDepth {
+			inner, err := flattenMembers(withOffset(st.Members, member.Offset), name+".", depth+1)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range inner {
+				if err := add(m); err != nil {
+					return nil, err
+				}
+			}
+			continue
 		}
 
-		gadgetStruct.Fields = append(gadgetStruct.Fields, field)
+		if union := namedUnion(member.Type); union != nil && depth < maxStructFlattenDepth {
+			inner, err := flattenMembers(withOffset(union.Members, member.Offset), name+".", depth+1)
+			if err != nil {
+				return nil, err
+			}
+			member.Name = name
+			if err := add(member); err != nil {
+				return nil, err
+			}
+			for _, m := range inner {
+				if err := add(m); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+
+		member.Name = name
+		if err := add(member); err != nil {
+			return nil, err
+		}
 	}
 
-	m.Structs[btfStruct.Name] = gadgetStruct
+	return result, nil
+}
 
-	return nil
+// findMember looks a member up by its (possibly dotted) field name, going
+// through nested structs and unions and anonymous structs and unions. The offset of the
+// returned member is relative to the outermost struct.
+func findMember(members []btf.Member, name string) (btf.Member, bool) {
+	first, rest, nested := strings.Cut(name, ".")
+
+	for _, member := range members {
+		if member.Name == "" {
+			if m, ok := findMember(withOffset(anonymousMembers(member.Type), member.Offset), name); ok {
+				return m, true
+			}
+			continue
+		}
+
+		if member.Name != first {
+			continue
+		}
+		if !nested {
+			return member, true
+		}
+		if st := nestedStruct(member.Type); st != nil {
+			return findMember(withOffset(st.Members, member.Offset), rest)
+		}
+		if union := namedUnion(member.Type); union != nil {
+			return findMember(withOffset(union.Members, member.Offset), rest)
+		}
+		return btf.Member{}, false
+	}
+
+	return btf.Member{}, false
 }
 
-func populateEbpfParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+func populateEbpfParams(m *metadatav1.GadgetMetadata, idx *btfIndex, report *PopulateReport) error {
 	var result error
+	spec := idx.spec
+
+	var paramNames []string
+	for _, marker := range idx.markers.Params {
+		if marker.Err != nil {
+			result = multierror.Append(result, marker.Err)
+			continue
+		}
+		paramNames = append(paramNames, marker.Name)
+	}
 
-	paramNames, err := GetGadgetIdentByPrefix(spec, paramPrefix)
+	defaults, err := getMarkers(spec, paramDefaultPrefix)
 	if err != nil {
-		result = multierror.Append(result, err)
+		result = multierror.Append(result, fmt.Errorf("reading param defaults: %w", err))
+	}
+	descs, err := getMarkers(spec, paramDescPrefix)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("reading param descriptions: %w", err))
 	}
+	mandatory, err := getMarkers(spec, paramMandatoryPrefix)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("reading mandatory params: %w", err))
+	}
+	tags := btfhelpers.GetDeclTags(spec.Types)
 
 	for _, name := range paramNames {
-		var btfVar *btf.Var
-		err := spec.Types.TypeByName(name, &btfVar)
+		btfVar, err := idx.varByName(name)
 		if err != nil {
 			result = multierror.Append(result, fmt.Errorf("looking variable %q up: %w", name, err))
 			continue
 		}
 
-		err = checkParamVar(spec, name)
+		err = checkParamVar(idx, name)
 		if err != nil {
 			result = multierror.Append(result, err)
 			continue
 		}
 
+		if _, ok := descs[name]; !ok {
+			if desc, ok := tagDescription(tags.Get(btfVar, -1)); ok {
+				descs[name] = desc
+			}
+		}
+
 		if m.EBPFParams == nil {
 			m.EBPFParams = make(map[string]metadatav1.EBPFParam)
 		}
 
-		if _, found := m.EBPFParams[name]; found {
-			log.Debugf("Param %q already defined, skipping", name)
+		// Values in the metadata file win over the ones set in the eBPF code
+		if p, found := m.EBPFParams[name]; found {
+			if p.DefaultValue == "" && defaults[name] != "" {
+				log.Debugf("Setting default value of param %q to %q", name, p.MaskValue(defaults[name]))
+				p.DefaultValue = defaults[name]
+			}
+			if (p.Description == "" || p.Description == paramDescTODO) && descs[name] != "" {
+				log.Debugf("Setting description of param %q", name)
+				p.Description = descs[name]
+			}
+			if p.MaxLength == 0 && !p.IsAddress() {
+				p.MaxLength = paramMaxLength(btfVar)
+			}
+			if enum, ok := btfhelpers.AsEnum(btfVar.Type); ok && len(p.PossibleValues) == 0 {
+				log.Debugf("Setting possible values of param %q", name)
+				p.PossibleValues = btfhelpers.EnumNames(enum)
+			}
+			if _, _, ok := btfhelpers.IntArray(btfVar.Type); ok && !p.List {
+				log.Debugf("Making param %q a list", name)
+				p.List = true
+			}
+			if _, ok := mandatory[name]; ok && !p.IsMandatory {
+				log.Debugf("Making param %q mandatory", name)
+				p.IsMandatory = true
+			}
+			m.EBPFParams[name] = p
 			continue
 		}
 
-		log.Debugf("Adding param %q", name)
-		m.EBPFParams[name] = metadatav1.EBPFParam{
-			ParamDesc: params.ParamDesc{
-				Key:         name,
-				Description: "TODO: Fill parameter description",
-			},
+		report.addParam(name)
+		p := paramDescFromVar(btfVar)
+		if _, ok := mandatory[name]; ok {
+			// Users must set it, the zero value of its type isn't a default
+			p.IsMandatory = true
+			p.DefaultValue = ""
 		}
+		if value, ok := defaults[name]; ok {
+			p.DefaultValue = value
+		}
+		if desc, ok := descs[name]; ok {
+			p.Description = desc
+		}
+		_, _, isArray := btfhelpers.IntArray(btfVar.Type)
+		ebpfParam := metadatav1.EBPFParam{
+			ParamDesc: p,
+			MaxLength: paramMaxLength(btfVar),
+			// Arrays of integers take a list of values
+			List: isArray,
+		}
+		applyDurationSuffix(&ebpfParam, btfVar)
+		m.EBPFParams[name] = ebpfParam
 	}
 
 	return result
 }
 
+func isParamMarker(name string) bool {
+	return strings.HasPrefix(name, paramDefaultPrefix) || strings.HasPrefix(name, paramDescPrefix) ||
+		strings.HasPrefix(name, paramMandatoryPrefix)
+}
+
+// getMarkers returns the strings set with macros like GADGET_PARAM_DEFAULT()
+// or GADGET_NAME(), indexed by their name without the prefix
+func getMarkers(spec *ebpf.CollectionSpec, prefix string) (map[string]string, error) {
+	markers := make(map[string]string)
+
+	for name, mapSpec := range spec.Maps {
+		if !strings.HasPrefix(name, ".rodata") {
+			continue
+		}
+		b, ds, err := btfhelpers.DataSection(mapSpec)
+		if errors.Is(err, btfhelpers.ErrMapNoBTFValue) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("map %s: %w", name, err)
+		}
+
+		for _, v := range ds.Vars {
+			varName := v.Type.TypeName()
+			if !strings.HasPrefix(varName, prefix) {
+				continue
+			}
+			if int(v.Offset+v.Size) > len(b) {
+				return nil, fmt.Errorf("%q is out of bounds of %s", varName, name)
+			}
+			value, _, _ := strings.Cut(string(b[v.Offset:v.Offset+v.Size]), "\x00")
+			markers[strings.TrimPrefix(varName, prefix)] = value
+		}
+	}
+
+	return markers, nil
+}
+
+// paramDescFromVar returns the description of the param backed by btfVar, with
+// the type hint and a zero default value derived from its type. Params backed
+// by an enum take the names of its enumerators.
+func paramDescFromVar(btfVar *btf.Var) params.ParamDesc {
+	typeHint := btfhelpers.GetTypeHint(btfVar.Type)
+	if btfhelpers.IsBool(btfVar.Type) {
+		// Flags are often declared as chars
+		typeHint = params.TypeBool
+	}
+
+	var defaultValue string
+	switch typeHint {
+	case params.TypeBool:
+		defaultValue = "false"
+	case params.TypeUnknown, params.TypeString:
+	default:
+		defaultValue = "0"
+	}
+
+	var possibleValues []string
+	if enum, ok := btfhelpers.AsEnum(btfVar.Type); ok {
+		// Enums take the names of their enumerators
+		possibleValues = btfhelpers.EnumNames(enum)
+		defaultValue = enumZeroValue(enum)
+	}
+
+	return params.ParamDesc{
+		Key:            btfVar.Name,
+		Description:    paramDescTODO,
+		TypeHint:       typeHint,
+		DefaultValue:   defaultValue,
+		IsMandatory:    false,
+		PossibleValues: possibleValues,
+	}
+}
+
+// paramMaxLength returns the maximum length of the values of the string param
+// backed by btfVar, keeping room for the terminating NUL, or 0 if it isn't a
+// char array
+func paramMaxLength(btfVar *btf.Var) uint32 {
+	n, ok := btfhelpers.CharArrayLen(btfVar.Type)
+	if !ok || n == 0 {
+		return 0
+	}
+	return n - 1
+}
+
 func populateGadgetParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
 	for _, p := range spec.Programs {
 		switch p.Type {
@@ -701,11 +3778,10 @@ func populateGadgetParams(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpe
 	return nil
 }
 
-func checkParamVar(spec *ebpf.CollectionSpec, name string) error {
+func checkParamVar(idx *btfIndex, name string) error {
 	var result error
 
-	var btfVar *btf.Var
-	err := spec.Types.TypeByName(name, &btfVar)
+	btfVar, err := idx.varByName(name)
 	if err != nil {
 		result = multierror.Append(result, fmt.Errorf("variable %q not found in eBPF object: %w", name, err))
 		return result
@@ -713,16 +3789,27 @@ func checkParamVar(spec *ebpf.CollectionSpec, name string) error {
 	if btfVar.Linkage != btf.GlobalVar {
 		result = multierror.Append(result, fmt.Errorf("%q is not a global variable", name))
 	}
-	btfConst, ok := btfVar.Type.(*btf.Const)
-	if !ok {
+	isConst, isVolatile := btfhelpers.Qualifiers(btfVar.Type)
+	if !isConst {
 		result = multierror.Append(result, fmt.Errorf("%q is not const", name))
 		return result
 	}
-	_, ok = btfConst.Type.(*btf.Volatile)
-	if !ok {
+	if !isVolatile {
 		result = multierror.Append(result, fmt.Errorf("%q is not volatile", name))
 		return result
 	}
+	if _, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Array); ok {
+		if _, n, ok := btfhelpers.IntArray(btfVar.Type); ok {
+			// Backs a list param
+			if n == 0 {
+				result = multierror.Append(result, fmt.Errorf("%q has room for no value", name))
+			}
+		} else if n, ok := btfhelpers.CharArrayLen(btfVar.Type); !ok {
+			result = multierror.Append(result, fmt.Errorf("%q is an array but not of chars or integers, the only arrays supported", name))
+		} else if n < 2 {
+			result = multierror.Append(result, fmt.Errorf("%q has room for no char besides the terminating NUL", name))
+		}
+	}
 
 	return result
 }
@@ -747,44 +3834,39 @@ func validateSnapshotterPrograms(spec *ebpf.CollectionSpec, programs []string) e
 	return nil
 }
 
-func populateSnapshotters(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
-	snapshottersDef, _ := GetGadgetIdentByPrefix(spec, snapshottersPrefix)
-	if len(snapshottersDef) == 0 {
+func populateSnapshotters(m *metadatav1.GadgetMetadata, idx *btfIndex, opts populateOptions, report *PopulateReport) error {
+	spec := idx.spec
+	snapshotter, err := firstMarker(idx.markers.Snapshotters, "snapshotter")
+	if err != nil {
+		return err
+	}
+	if snapshotter == nil {
 		log.Debug("No snapshotters found")
 		return nil
 	}
 
-	if len(snapshottersDef) > 1 {
-		log.Warnf("Multiple snapshotters found, using %q", snapshottersDef[0])
-	}
-
-	snapshotterDef := snapshottersDef[0]
-
 	if m.Snapshotters == nil {
 		m.Snapshotters = make(map[string]metadatav1.Snapshotter)
 	}
 
-	parts := strings.Split(snapshotterDef, "___")
-	if len(parts) < 3 {
-		// At least one program is required
-		return fmt.Errorf("invalid snapshotter definition, expected format: <name>___<structName>___<program1>___...___<programN>, got %q",
-			snapshotterDef)
+	if snapshotter.Map != "" {
+		return populateMapSnapshotter(m, idx, snapshotter, opts, report)
 	}
 
-	sname := parts[0]
-	stype := parts[1]
+	sname := snapshotter.Name
+	stype := snapshotter.Type
 
-	if err := validateSnapshotterPrograms(spec, parts[2:]); err != nil {
+	if err := validateSnapshotterPrograms(spec, snapshotter.Programs); err != nil {
 		return fmt.Errorf("validating snapshotter %q programs: %w", sname, err)
 	}
 
-	var btfStruct *btf.Struct
-	spec.Types.TypeByName(stype, &btfStruct)
-
-	if btfStruct == nil {
-		return fmt.Errorf("struct %q not found", stype)
+	btfStruct, err := idx.structByName(stype)
+	if err != nil {
+		return fmt.Errorf("finding struct %q in eBPF object: %w", stype, err)
 	}
 
+	report.addSnapshotter(sname)
+
 	_, ok := m.Snapshotters[sname]
 	if !ok {
 		log.Debugf("Adding snapshotter %q", sname)
@@ -795,9 +3877,109 @@ func populateSnapshotters(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpe
 		log.Debugf("Snapshotter %q already defined, skipping", sname)
 	}
 
-	if err := populateStruct(m, btfStruct); err != nil {
+	if err := populateStruct(m, btfStruct, spec.Types, opts, report); err != nil {
+		return fmt.Errorf("populating struct: %w", err)
+	}
+
+	return nil
+}
+
+// populateMapSnapshotter populates a snapshotter dumping a map, along with the
+// structs of its keys and values
+func populateMapSnapshotter(m *metadatav1.GadgetMetadata, idx *btfIndex, snapshotter *Marker, opts populateOptions, report *PopulateReport) error {
+	sname := snapshotter.Name
+
+	ebpfMap, ok := idx.spec.Maps[snapshotter.Map]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", snapshotter.Map)
+	}
+	keyStruct, valueStruct, err := snapshotterMapStructs(ebpfMap)
+	if err != nil {
+		return fmt.Errorf("validating snapshotter %q map: %w", sname, err)
+	}
+
+	report.addSnapshotter(sname)
+
+	if _, ok := m.Snapshotters[sname]; !ok {
+		log.Debugf("Adding snapshotter %q", sname)
+		s := metadatav1.Snapshotter{
+			StructName: valueStruct.Name,
+			Source:     metadatav1.SnapshotterSourceMap,
+			MapName:    ebpfMap.Name,
+		}
+		if keyStruct != nil {
+			s.KeyStructName = keyStruct.Name
+		}
+		m.Snapshotters[sname] = s
+	} else {
+		log.Debugf("Snapshotter %q already defined, skipping", sname)
+	}
+
+	if keyStruct != nil {
+		if err := populateStruct(m, keyStruct, idx.spec.Types, opts, report); err != nil {
+			return fmt.Errorf("populating key struct: %w", err)
+		}
+	}
+	if err := populateStruct(m, valueStruct, idx.spec.Types, opts, report); err != nil {
 		return fmt.Errorf("populating struct: %w", err)
 	}
 
 	return nil
 }
+
+// validateFieldFlags checks that the flags of a bitmask field have unique names
+// and values fitting the member. Each flag must be a single bit unless it's
+// declared as a mask.
+func validateFieldFlags(field metadatav1.Field, member btf.Member) error {
+	if len(field.Flags) == 0 {
+		return nil
+	}
+	if len(field.Values) > 0 {
+		return errors.New("flags and values can't be set together")
+	}
+
+	switch typ := btfhelpers.GetUnderlyingType(member.Type).(type) {
+	case *btf.Int:
+		if typ.Encoding == btf.Bool {
+			return fmt.Errorf("flags are only supported for integers, got %s", member.Type)
+		}
+	case *btf.Enum:
+	default:
+		return fmt.Errorf("flags are only supported for integers, got %s", member.Type)
+	}
+
+	width := uint(member.BitfieldSize)
+	if width == 0 {
+		size, err := btf.Sizeof(member.Type)
+		if err != nil {
+			return fmt.Errorf("getting size: %w", err)
+		}
+		width = uint(size) * 8
+	}
+
+	var result error
+	names := make(map[string]struct{}, len(field.Flags))
+	values := make(map[uint64]string, len(field.Flags))
+	for _, flag := range field.Flags {
+		if flag.Name == "" {
+			result = multierror.Append(result, fmt.Errorf("flag with value %#x has no name", flag.Value))
+			continue
+		}
+		if _, ok := names[flag.Name]; ok {
+			result = multierror.Append(result, fmt.Errorf("flag %q defined more than once", flag.Name))
+		}
+		names[flag.Name] = struct{}{}
+		if other, ok := values[flag.Value]; ok {
+			result = multierror.Append(result, fmt.Errorf("flags %q and %q have the same value %#x", other, flag.Name, flag.Value))
+		}
+		values[flag.Value] = flag.Name
+
+		if width < 64 && flag.Value>>width != 0 {
+			result = multierror.Append(result, fmt.Errorf("flag %q (%#x) doesn't fit in %d bits", flag.Name, flag.Value, width))
+		}
+		if !flag.Mask && flag.Value&(flag.Value-1) != 0 {
+			result = multierror.Append(result, fmt.Errorf("flag %q (%#x) has several bits set, declare it as a mask", flag.Name, flag.Value))
+		}
+	}
+	return result
+}
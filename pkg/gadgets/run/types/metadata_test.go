@@ -15,11 +15,18 @@
 package types
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
 	"testing"
 
 	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
 	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
@@ -324,6 +331,156 @@ func TestValidate(t *testing.T) {
 				},
 			},
 		},
+		"param_type_matching": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:      "param",
+							TypeHint: params.TypeInt32,
+						},
+					},
+				},
+			},
+		},
+		"param_type_mismatch": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:      "param",
+							TypeHint: params.TypeUint64,
+						},
+					},
+				},
+			},
+			expectedErrString: "param \"param\" has type \"uint64\" but the eBPF variable is \"int32\"",
+		},
+		"param_bool_too_wide": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:      "param",
+							TypeHint: params.TypeBool,
+						},
+					},
+				},
+			},
+			expectedErrString: "param \"param\" has type \"bool\" but the eBPF variable is 4 bytes wide, expected 1",
+		},
+		"param_hidden": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:          "param",
+							DefaultValue: "0",
+						},
+						Hidden: true,
+					},
+				},
+			},
+		},
+		"param_hidden_without_default": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key: "param",
+						},
+						Hidden: true,
+					},
+				},
+			},
+			expectedErrString: "param \"param\" is hidden but has no default value, it couldn't be set in non-interactive contexts",
+		},
+		"param_mandatory": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:         "param",
+							IsMandatory: true,
+						},
+					},
+				},
+			},
+		},
+		"param_mandatory_with_default": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:          "param",
+							IsMandatory:  true,
+							DefaultValue: "0",
+						},
+					},
+				},
+			},
+			expectedErrString: "param \"param\" is mandatory but has the default value \"0\", remove it or make the param optional",
+		},
+		"sensitive_param_mandatory_with_default": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"param": {
+						ParamDesc: params.ParamDesc{
+							Key:          "param",
+							IsMandatory:  true,
+							DefaultValue: "0",
+						},
+						Sensitive: true,
+					},
+				},
+			},
+			expectedErrString: "param \"param\" is mandatory but has the default value \"***\", remove it or make the param optional",
+		},
+		"external_map": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				ExternalMaps: map[string]metadatav1.ExternalMap{
+					"myhashmap": {LayoutVersion: 1},
+				},
+			},
+		},
+		"external_map_not_found": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				ExternalMaps: map[string]metadatav1.ExternalMap{
+					"nonexistent": {LayoutVersion: 1},
+				},
+			},
+			expectedErrString: "external map \"nonexistent\" not found in eBPF object",
+		},
+		"external_map_missing_layout_version": {
+			objectPath: "../../../../testdata/validate_metadata1.o",
+			metadata: &metadatav1.GadgetMetadata{
+				Name: "foo",
+				ExternalMaps: map[string]metadatav1.ExternalMap{
+					"myhashmap": {},
+				},
+			},
+			expectedErrString: "external map \"myhashmap\": layoutVersion must be greater than 0",
+		},
 		"param2_not_volatile": {
 			objectPath: "../../../../testdata/validate_metadata1.o",
 			metadata: &metadatav1.GadgetMetadata{
@@ -431,7 +588,7 @@ func TestPopulate(t *testing.T) {
 						Description: "TODO: Fill field description",
 						Attributes: metadatav1.FieldAttributes{
 							Width:     10,
-							Alignment: metadatav1.AlignmentLeft,
+							Alignment: metadatav1.AlignmentRight,
 							Ellipsis:  metadatav1.EllipsisEnd,
 						},
 					},
@@ -440,6 +597,7 @@ func TestPopulate(t *testing.T) {
 						Description: "TODO: Fill field description",
 						Attributes: metadatav1.FieldAttributes{
 							Width:     16,
+							MaxWidth:  16,
 							Alignment: metadatav1.AlignmentLeft,
 							Ellipsis:  metadatav1.EllipsisEnd,
 						},
@@ -448,9 +606,10 @@ func TestPopulate(t *testing.T) {
 						Name:        "filename",
 						Description: "TODO: Fill field description",
 						Attributes: metadatav1.FieldAttributes{
-							Width:     16,
+							Width:     32,
+							MaxWidth:  255,
 							Alignment: metadatav1.AlignmentLeft,
-							Ellipsis:  metadatav1.EllipsisEnd,
+							Ellipsis:  metadatav1.EllipsisMiddle,
 						},
 					},
 				},
@@ -474,6 +633,7 @@ func TestPopulate(t *testing.T) {
 				HomepageURL:      "TODO: Fill the gadget homepage URL",
 				DocumentationURL: "TODO: Fill the gadget documentation URL",
 				SourceURL:        "TODO: Fill the gadget source code URL",
+				MntNsFilter:      &metadatav1.MntNsFilter{MapName: "gadget_mntns_filter_map"},
 				Tracers: map[string]metadatav1.Tracer{
 					"test": {
 						MapName:    "events",
@@ -488,7 +648,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     10,
-									Alignment: metadatav1.AlignmentLeft,
+									Alignment: metadatav1.AlignmentRight,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
 							},
@@ -497,6 +657,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     16,
+									MaxWidth:  16,
 									Alignment: metadatav1.AlignmentLeft,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
@@ -505,9 +666,10 @@ func TestPopulate(t *testing.T) {
 								Name:        "filename",
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
-									Width:     16,
+									Width:     32,
+									MaxWidth:  255,
 									Alignment: metadatav1.AlignmentLeft,
-									Ellipsis:  metadatav1.EllipsisEnd,
+									Ellipsis:  metadatav1.EllipsisMiddle,
 								},
 							},
 						},
@@ -565,6 +727,7 @@ func TestPopulate(t *testing.T) {
 				HomepageURL:      "url1",
 				DocumentationURL: "url2",
 				SourceURL:        "url3",
+				MntNsFilter:      &metadatav1.MntNsFilter{MapName: "gadget_mntns_filter_map"},
 				Annotations: map[string]string{
 					"io.inspektor-gadget.test": "test",
 				},
@@ -599,9 +762,10 @@ func TestPopulate(t *testing.T) {
 								Name:        "filename",
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
-									Width:     16,
+									Width:     32,
+									MaxWidth:  255,
 									Alignment: metadatav1.AlignmentLeft,
-									Ellipsis:  metadatav1.EllipsisEnd,
+									Ellipsis:  metadatav1.EllipsisMiddle,
 								},
 							},
 						},
@@ -617,6 +781,7 @@ func TestPopulate(t *testing.T) {
 				HomepageURL:      "TODO: Fill the gadget homepage URL",
 				DocumentationURL: "TODO: Fill the gadget documentation URL",
 				SourceURL:        "TODO: Fill the gadget source code URL",
+				MntNsFilter:      &metadatav1.MntNsFilter{MapName: "gadget_mntns_filter_map"},
 			},
 		},
 		"tracer_wrong_map_type": {
@@ -649,7 +814,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     10,
-									Alignment: metadatav1.AlignmentLeft,
+									Alignment: metadatav1.AlignmentRight,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
 							},
@@ -658,6 +823,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     16,
+									MaxWidth:  16,
 									Alignment: metadatav1.AlignmentLeft,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
@@ -666,9 +832,10 @@ func TestPopulate(t *testing.T) {
 								Name:        "filename",
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
-									Width:     16,
+									Width:     32,
+									MaxWidth:  255,
 									Alignment: metadatav1.AlignmentLeft,
-									Ellipsis:  metadatav1.EllipsisEnd,
+									Ellipsis:  metadatav1.EllipsisMiddle,
 								},
 							},
 						},
@@ -684,13 +851,16 @@ func TestPopulate(t *testing.T) {
 				HomepageURL:      "TODO: Fill the gadget homepage URL",
 				DocumentationURL: "TODO: Fill the gadget documentation URL",
 				SourceURL:        "TODO: Fill the gadget source code URL",
+				MntNsFilter:      &metadatav1.MntNsFilter{MapName: "gadget_mntns_filter_map"},
 				EBPFParams: map[string]metadatav1.EBPFParam{
 					// This also makes sure that param2 won't get picked up
 					// since GADGET_PARAM(param2) is missing
 					"param": {
 						ParamDesc: params.ParamDesc{
-							Key:         "param",
-							Description: "TODO: Fill parameter description",
+							Key:          "param",
+							Description:  "TODO: Fill parameter description",
+							TypeHint:     params.TypeInt32,
+							DefaultValue: "0",
 						},
 					},
 				},
@@ -724,6 +894,7 @@ func TestPopulate(t *testing.T) {
 				HomepageURL:      "url1",
 				DocumentationURL: "url2",
 				SourceURL:        "url3",
+				MntNsFilter:      &metadatav1.MntNsFilter{MapName: "gadget_mntns_filter_map"},
 				Annotations: map[string]string{
 					"io.inspektor-gadget.test": "test",
 				},
@@ -762,7 +933,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     10,
-									Alignment: metadatav1.AlignmentLeft,
+									Alignment: metadatav1.AlignmentRight,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
 							},
@@ -771,6 +942,7 @@ func TestPopulate(t *testing.T) {
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
 									Width:     16,
+									MaxWidth:  16,
 									Alignment: metadatav1.AlignmentLeft,
 									Ellipsis:  metadatav1.EllipsisEnd,
 								},
@@ -779,9 +951,10 @@ func TestPopulate(t *testing.T) {
 								Name:        "filename",
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
-									Width:     16,
+									Width:     32,
+									MaxWidth:  255,
 									Alignment: metadatav1.AlignmentLeft,
-									Ellipsis:  metadatav1.EllipsisEnd,
+									Ellipsis:  metadatav1.EllipsisMiddle,
 								},
 							},
 						},
@@ -883,9 +1056,10 @@ func TestPopulate(t *testing.T) {
 								Name:        "filename",
 								Description: "TODO: Fill field description",
 								Attributes: metadatav1.FieldAttributes{
-									Width:     16,
+									Width:     32,
+									MaxWidth:  255,
 									Alignment: metadatav1.AlignmentLeft,
-									Ellipsis:  metadatav1.EllipsisEnd,
+									Ellipsis:  metadatav1.EllipsisMiddle,
 								},
 							},
 						},
@@ -953,3 +1127,4824 @@ func TestPopulate(t *testing.T) {
 		})
 	}
 }
+
+var (
+	charType = &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}
+	u8Type   = &btf.Typedef{Name: "__u8", Type: &btf.Int{Name: "unsigned char", Size: 1}}
+	u32Type  = &btf.Typedef{Name: "__u32", Type: &btf.Int{Name: "unsigned int", Size: 4}}
+	s32Type  = &btf.Typedef{Name: "__s32", Type: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}}
+)
+
+func TestGetColumnSize(t *testing.T) {
+	type testCase struct {
+		typ           btf.Type
+		expectedWidth uint
+	}
+
+	tests := map[string]testCase{
+		"u32": {
+			typ:           u32Type,
+			expectedWidth: columns.MaxCharsUint32,
+		},
+		"comm": {
+			typ:           &btf.Array{Type: charType, Nelems: 16},
+			expectedWidth: 16,
+		},
+		"path": {
+			typ:           &btf.Array{Type: charType, Nelems: 4096},
+			expectedWidth: maxCharArrayColumnWidth,
+		},
+		"u8_array": {
+			typ:           &btf.Array{Type: u8Type, Nelems: 8},
+			expectedWidth: 8,
+		},
+		"non_char_array": {
+			typ:           &btf.Array{Type: u32Type, Nelems: 4},
+			expectedWidth: metadatav1.DefaultColumnWidth,
+		},
+		"small_enum": {
+			typ:           eventTypeEnum,
+			expectedWidth: uint(len("CONNECT")),
+		},
+		"enum_long_names": {
+			typ: &btf.Enum{
+				Name: "state",
+				Size: 4,
+				Values: []btf.EnumValue{
+					{Name: "TCP_ESTABLISHED", Value: 1},
+					{Name: "TCP_NEW_SYN_RECV", Value: 12},
+				},
+			},
+			expectedWidth: uint(len("TCP_NEW_SYN_RECV")),
+		},
+		"enum64": {
+			typ:           bigEnum,
+			expectedWidth: uint(len("HUGE_VALUE")),
+		},
+		"enum_without_values": {
+			typ:           &btf.Enum{Name: "empty", Size: 2},
+			expectedWidth: columns.MaxCharsUint16,
+		},
+		"typedef_enum": {
+			typ:           &btf.Typedef{Name: "event_type_t", Type: &btf.Typedef{Name: "inner_t", Type: eventTypeEnum}},
+			expectedWidth: uint(len("CONNECT")),
+		},
+		"const_typedef_enum": {
+			typ:           &btf.Const{Type: &btf.Typedef{Name: "event_type_t", Type: eventTypeEnum}},
+			expectedWidth: uint(len("CONNECT")),
+		},
+		"float": {
+			typ:           &btf.Float{Name: "float", Size: 4},
+			expectedWidth: 11,
+		},
+		"double": {
+			typ:           &btf.Float{Name: "double", Size: 8},
+			expectedWidth: 19,
+		},
+		"qualified_chain": {
+			typ: &btf.Typedef{Name: "outer_t", Type: &btf.Const{Type: &btf.Typedef{
+				Name: "inner_t",
+				Type: &btf.Volatile{Type: u32Type},
+			}}},
+			expectedWidth: columns.MaxCharsUint32,
+		},
+		"volatile_enum": {
+			typ:           &btf.Volatile{Type: &btf.Const{Type: eventTypeEnum}},
+			expectedWidth: uint(len("CONNECT")),
+		},
+		"const_char_array": {
+			typ:           &btf.Const{Type: &btf.Array{Type: charType, Nelems: 16}},
+			expectedWidth: 16,
+		},
+		"void_pointer": {
+			typ:           &btf.Pointer{Target: &btf.Void{}},
+			expectedWidth: 18,
+		},
+		"typedef_pointer": {
+			typ:           &btf.Typedef{Name: "handle_t", Type: &btf.Pointer{Target: u32Type}},
+			expectedWidth: 18,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expectedWidth, getColumnSize(test.typ))
+		})
+	}
+}
+
+func TestPopulateStructCharArrays(t *testing.T) {
+	btfStruct := &btf.Struct{
+		Name: "event",
+		Members: []btf.Member{
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			{Name: "path", Type: &btf.Array{Type: charType, Nelems: 4096}},
+			{Name: "args", Type: &btf.Array{Type: u32Type, Nelems: 4}},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, btfStruct, nil, populateOptions{}, nil))
+
+	notComparable := false
+	expected := []metadatav1.Field{
+		{
+			Name:        "comm",
+			Description: "TODO: Fill field description",
+			Attributes: metadatav1.FieldAttributes{
+				Width:     16,
+				MaxWidth:  16,
+				Alignment: metadatav1.AlignmentLeft,
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+		},
+		{
+			Name:        "path",
+			Description: "TODO: Fill field description",
+			Attributes: metadatav1.FieldAttributes{
+				Width:     maxCharArrayColumnWidth,
+				MaxWidth:  4096,
+				Alignment: metadatav1.AlignmentLeft,
+				Ellipsis:  metadatav1.EllipsisMiddle,
+			},
+		},
+		{
+			Name:        "args",
+			Description: "TODO: Fill field description",
+			Attributes: metadatav1.FieldAttributes{
+				Width:      metadatav1.DefaultColumnWidth,
+				Alignment:  metadatav1.AlignmentLeft,
+				Ellipsis:   metadatav1.EllipsisEnd,
+				Filterable: &notComparable,
+				Sortable:   &notComparable,
+			},
+		},
+	}
+	require.Equal(t, expected, m.Structs["event"].Fields)
+}
+
+var (
+	eventTypeEnum = &btf.Enum{
+		Name: "event_type",
+		Size: 4,
+		Values: []btf.EnumValue{
+			{Name: "OPEN", Value: 1},
+			{Name: "EXEC", Value: 2},
+			{Name: "CONNECT", Value: 3},
+		},
+	}
+	bigEnum = &btf.Enum{
+		Name: "big",
+		Size: 8,
+		Values: []btf.EnumValue{
+			{Name: "SMALL", Value: 1},
+			{Name: "HUGE_VALUE", Value: 1 << 40},
+		},
+	}
+)
+
+func TestPopulateStructEnums(t *testing.T) {
+	btfStruct := &btf.Struct{
+		Name: "event",
+		Members: []btf.Member{
+			{Name: "type", Type: &btf.Typedef{Name: "event_type_t", Type: eventTypeEnum}},
+			{Name: "big", Type: bigEnum},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, btfStruct, nil, populateOptions{}, nil))
+
+	expected := []metadatav1.Field{
+		{
+			Name:        "type",
+			Description: "TODO: Fill field description",
+			Attributes: metadatav1.FieldAttributes{
+				Width:     uint(len("CONNECT")),
+				Alignment: metadatav1.AlignmentRight,
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+			Values: map[int64]string{1: "OPEN", 2: "EXEC", 3: "CONNECT"},
+		},
+		{
+			Name:        "big",
+			Description: "TODO: Fill field description",
+			Attributes: metadatav1.FieldAttributes{
+				Width:     uint(len("HUGE_VALUE")),
+				Alignment: metadatav1.AlignmentRight,
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+			Values: map[int64]string{1: "SMALL", 1 << 40: "HUGE_VALUE"},
+		},
+	}
+	require.Equal(t, expected, m.Structs["event"].Fields)
+}
+
+func TestValidateFieldValues(t *testing.T) {
+	type testCase struct {
+		values            map[int64]string
+		defaultLabel      string
+		member            btf.Member
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_values": {
+			member: btf.Member{Name: "type", Type: u32Type},
+		},
+		"subset": {
+			values: map[int64]string{2: "exec"},
+			member: btf.Member{Name: "type", Type: &btf.Typedef{Name: "event_type_t", Type: eventTypeEnum}},
+		},
+		"enum64": {
+			values: map[int64]string{1 << 40: "huge"},
+			member: btf.Member{Name: "type", Type: bigEnum},
+		},
+		// Only a warning, see validateFieldEnumValues
+		"unknown_enum_value": {
+			values: map[int64]string{1: "OPEN", 42: "FOO"},
+			member: btf.Member{Name: "type", Type: eventTypeEnum},
+		},
+		"protocols": {
+			values:       map[int64]string{6: "TCP", 17: "UDP"},
+			defaultLabel: "OTHER",
+			member:       btf.Member{Name: "proto", Type: u8Type},
+		},
+		"signed": {
+			values: map[int64]string{-1: "ERROR", 0: "OK"},
+			member: btf.Member{Name: "ret", Type: s32Type},
+		},
+		"u64_two_complement": {
+			values: map[int64]string{-1: "MAX"},
+			member: btf.Member{Name: "id", Type: &btf.Int{Name: "u64", Size: 8}},
+		},
+		"out_of_range": {
+			values:            map[int64]string{6: "TCP", 256: "BIG"},
+			member:            btf.Member{Name: "proto", Type: u8Type},
+			expectedErrString: "value 256 is out of the range of the field [0, 255]",
+		},
+		"negative_unsigned": {
+			values:            map[int64]string{-1: "ERROR"},
+			member:            btf.Member{Name: "ret", Type: u32Type},
+			expectedErrString: "value -1 is out of the range of the field [0, 4294967295]",
+		},
+		"signed_out_of_range": {
+			values:            map[int64]string{-129: "LOW"},
+			member:            btf.Member{Name: "ret", Type: &btf.Int{Name: "s8", Size: 1, Encoding: btf.Signed}},
+			expectedErrString: "value -129 is out of the range of the field [-128, 127]",
+		},
+		"bitfield": {
+			values:            map[int64]string{3: "THREE", 4: "FOUR"},
+			member:            btf.Member{Name: "state", Type: u32Type, BitfieldSize: 2},
+			expectedErrString: "value 4 is out of the range of the field [0, 3]",
+		},
+		"not_integer": {
+			values:            map[int64]string{1: "ONE"},
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			expectedErrString: "values can only be set on enum and integer fields",
+		},
+		"bool": {
+			values:            map[int64]string{1: "YES"},
+			member:            btf.Member{Name: "ok", Type: &btf.Int{Name: "bool", Size: 1, Encoding: btf.Bool}},
+			expectedErrString: "values can only be set on enum and integer fields",
+		},
+		"default_label_without_values": {
+			defaultLabel:      "OTHER",
+			member:            btf.Member{Name: "proto", Type: u8Type},
+			expectedErrString: "defaultLabel requires values",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:         test.member.Name,
+				Values:       test.values,
+				DefaultLabel: test.defaultLabel,
+			}
+			err := validateFieldValues(field, test.member)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateFieldEnumValues(t *testing.T) {
+	t.Parallel()
+
+	field := metadatav1.Field{Name: "type", Values: map[int64]string{1: "OPEN", 42: "FOO"}}
+	err := validateFieldEnumValues(field, btf.Member{Name: "type", Type: eventTypeEnum})
+	require.ErrorContains(t, err, `value 42 not found in enum "event_type"`)
+
+	field.Values = map[int64]string{1: "OPEN"}
+	require.NoError(t, validateFieldEnumValues(field, btf.Member{Name: "type", Type: eventTypeEnum}))
+
+	// Plain integers are checked by validateFieldValues
+	field.Values = map[int64]string{42: "FOO"}
+	require.NoError(t, validateFieldEnumValues(field, btf.Member{Name: "type", Type: u32Type}))
+}
+
+var processStruct = &btf.Struct{
+	Name: "process",
+	Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+	},
+}
+
+// Mimics an event reusing a kernel layout:
+//
+//	struct event {
+//		__u32 pid;
+//		union {
+//			struct { __u32 ppid; char comm[16]; };
+//			struct { __u32 exit_code; } exit;
+//		};
+//	};
+var anonUnionEvent = &btf.Struct{
+	Name: "event",
+	Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "", Type: &btf.Union{Members: []btf.Member{
+			{Name: "", Type: &btf.Struct{Members: []btf.Member{
+				{Name: "ppid", Type: u32Type},
+				{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			}}},
+			{Name: "exit", Type: &btf.Struct{Members: []btf.Member{
+				{Name: "exit_code", Type: u32Type},
+			}}},
+		}}},
+	},
+}
+
+func TestFlattenMembers(t *testing.T) {
+	type testCase struct {
+		members           []btf.Member
+		expectedNames     []string
+		expectedErrString string
+	}
+
+	l4Endpoint := &btf.Struct{
+		Name: "gadget_l4endpoint_t",
+		Members: []btf.Member{
+			{Name: "port", Type: u32Type},
+		},
+	}
+
+	// level3 -> level2 -> level1 -> process
+	level1 := &btf.Struct{Name: "level1", Members: []btf.Member{{Name: "proc", Type: processStruct}}}
+	level2 := &btf.Struct{Name: "level2", Members: []btf.Member{{Name: "l1", Type: level1}}}
+
+	tests := map[string]testCase{
+		"nested": {
+			members: []btf.Member{
+				{Name: "src", Type: processStruct},
+				{Name: "dst", Type: &btf.Typedef{Name: "process_t", Type: processStruct}},
+			},
+			expectedNames: []string{"src.pid", "src.comm", "dst.pid", "dst.comm"},
+		},
+		"anonymous": {
+			members: []btf.Member{
+				{Name: "", Type: &btf.Struct{Members: []btf.Member{{Name: "a", Type: u32Type}}}},
+				{Name: "b", Type: u32Type},
+			},
+			expectedNames: []string{"a", "b"},
+		},
+		"anonymous_union": {
+			members:       anonUnionEvent.Members,
+			expectedNames: []string{"pid", "ppid", "comm", "exit.exit_code"},
+		},
+		"anonymous_union_collision": {
+			members: []btf.Member{
+				{Name: "", Type: &btf.Union{Members: []btf.Member{
+					{Name: "", Type: &btf.Struct{Members: []btf.Member{{Name: "code", Type: u32Type}}}},
+					{Name: "", Type: &btf.Struct{Members: []btf.Member{{Name: "code", Type: u32Type}}}},
+				}}},
+			},
+			expectedErrString: `field "code" defined more than once`,
+		},
+		"anonymous_not_struct": {
+			members: []btf.Member{
+				{Name: "", Type: u32Type},
+				{Name: "b", Type: u32Type},
+			},
+			expectedNames: []string{"b"},
+		},
+		"endpoint_kept": {
+			members: []btf.Member{
+				{Name: "dst", Type: l4Endpoint},
+			},
+			expectedNames: []string{"dst"},
+		},
+		"max_depth": {
+			members: []btf.Member{
+				{Name: "l2", Type: level2},
+				{Name: "top", Type: &btf.Struct{Members: []btf.Member{{Name: "l2", Type: level2}}}},
+			},
+			expectedNames: []string{"l2.l1.proc.pid", "l2.l1.proc.comm", "top.l2.l1.proc"},
+		},
+		"collision": {
+			members: []btf.Member{
+				{Name: "", Type: &btf.Struct{Members: []btf.Member{{Name: "pid", Type: u32Type}}}},
+				{Name: "pid", Type: u32Type},
+			},
+			expectedErrString: `field "pid" defined more than once`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			members, err := flattenMembers(test.members, "", 0)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+
+			names := make([]string, 0, len(members))
+			for _, m := range members {
+				names = append(names, m.Name)
+			}
+			require.Equal(t, test.expectedNames, names)
+		})
+	}
+}
+
+func TestFindMember(t *testing.T) {
+	members := []btf.Member{
+		{Name: "src", Type: processStruct},
+		{Name: "", Type: &btf.Struct{Members: []btf.Member{{Name: "a", Type: u32Type}}}},
+	}
+
+	m, ok := findMember(members, "src.comm")
+	require.True(t, ok)
+	require.Equal(t, "comm", m.Name)
+
+	m, ok = findMember(members, "a")
+	require.True(t, ok)
+	require.Equal(t, "a", m.Name)
+
+	_, ok = findMember(members, "src.foo")
+	require.False(t, ok)
+
+	// Offsets are relative to the outermost struct
+	m, ok = findMember([]btf.Member{{Name: "flags", Type: bitfieldsStruct, Offset: 64}}, "flags.cross")
+	require.True(t, ok)
+	require.Equal(t, btf.Bits(102), m.Offset)
+
+	m, ok = findMember(anonUnionEvent.Members, "comm")
+	require.True(t, ok)
+	require.Equal(t, "comm", m.Name)
+
+	m, ok = findMember(anonUnionEvent.Members, "exit.exit_code")
+	require.True(t, ok)
+	require.Equal(t, "exit_code", m.Name)
+
+	_, ok = findMember(anonUnionEvent.Members, "exit_code")
+	require.False(t, ok)
+
+	_, ok = findMember(members, "src.pid.foo")
+	require.False(t, ok)
+}
+
+func TestPopulateStructAnonymousMembers(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, anonUnionEvent, nil, populateOptions{}, nil))
+
+	names := []string{}
+	for _, f := range m.Structs["event"].Fields {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"pid", "ppid", "comm", "exit.exit_code"}, names)
+}
+
+func TestValidateAliases(t *testing.T) {
+	type testCase struct {
+		structs           map[string]metadatav1.Struct
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"valid": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "src_addr", Aliases: []string{"saddr"}},
+					{Name: "dst_addr", Aliases: []string{"daddr"}},
+				}},
+				// The same field in another struct can share its aliases
+				"event2": {Fields: []metadatav1.Field{
+					{Name: "src_addr", Aliases: []string{"saddr"}},
+				}},
+			},
+		},
+		"collides_with_field": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "src_addr", Aliases: []string{"saddr"}},
+				}},
+				"event2": {Fields: []metadatav1.Field{
+					{Name: "saddr"},
+				}},
+			},
+			expectedErrString: `alias "saddr" of field "src_addr" collides with an existing field`,
+		},
+		"collides_with_enrichment": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "pod", Aliases: []string{"k8s.podName"}},
+				}},
+			},
+			expectedErrString: `alias "k8s.podName" of field "pod" collides with an enrichment field`,
+		},
+		"shared_alias": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "src_addr", Aliases: []string{"addr"}},
+					{Name: "dst_addr", Aliases: []string{"addr"}},
+				}},
+			},
+			expectedErrString: `alias "addr" used by fields "src_addr" and "dst_addr"`,
+		},
+		"empty": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "src_addr", Aliases: []string{""}},
+				}},
+			},
+			expectedErrString: "empty alias",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateAliases(&metadatav1.GadgetMetadata{Structs: test.structs})
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateOutputNames(t *testing.T) {
+	type testCase struct {
+		structs           map[string]metadatav1.Struct
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"valid": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "saddr_v4", OutputName: "srcAddr"},
+					{Name: "daddr_v4", OutputName: "dstAddr"},
+					{Name: "pid"},
+				}},
+				// Other structs can use the same output names
+				"event2": {Fields: []metadatav1.Field{
+					{Name: "addr", OutputName: "srcAddr"},
+				}},
+			},
+		},
+		// Swapping names is fine
+		"swap": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "src", OutputName: "dst"},
+					{Name: "dst", OutputName: "src"},
+				}},
+			},
+		},
+		"same_output_name": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "saddr_v4", OutputName: "srcAddr"},
+					{Name: "saddr_v6", OutputName: "srcAddr"},
+				}},
+			},
+			expectedErrString: `fields "saddr_v4" and "saddr_v6" of struct "event" have the same output name "srcAddr"`,
+		},
+		"collides_with_name": {
+			structs: map[string]metadatav1.Struct{
+				"event": {Fields: []metadatav1.Field{
+					{Name: "pid"},
+					{Name: "tgid", OutputName: "pid"},
+				}},
+			},
+			expectedErrString: `fields "pid" and "tgid" of struct "event" have the same output name "pid"`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateOutputNames(&metadatav1.GadgetMetadata{Structs: test.structs})
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateFieldOrders(t *testing.T) {
+	t.Parallel()
+
+	order := func(o int32) *int32 { return &o }
+
+	s := metadatav1.Struct{Fields: []metadatav1.Field{
+		{Name: "pid", Attributes: metadatav1.FieldAttributes{Order: order(-40)}},
+		{Name: "comm"},
+		{Name: "filename", Attributes: metadatav1.FieldAttributes{Order: order(10)}},
+		{Name: "ret"},
+	}}
+	require.NoError(t, validateFieldOrders(s))
+
+	s.Fields = append(s.Fields,
+		metadatav1.Field{Name: "flags", Attributes: metadatav1.FieldAttributes{Order: order(10)}},
+		metadatav1.Field{Name: "mode", Attributes: metadatav1.FieldAttributes{Order: order(10)}},
+	)
+	err := validateFieldOrders(s)
+	require.ErrorContains(t, err, `fields "filename" and "flags" have the same order 10`)
+	require.ErrorContains(t, err, `fields "filename" and "mode" have the same order 10`)
+}
+
+// Packs flags as bitfields:
+//
+//	struct flags_event {
+//		__u32 pid;
+//		__u32 is_ipv6:1, is_retrans:1, state:4, cross:5;
+//		__s32 delta:3;
+//	};
+//
+// cross spans the first and second byte of the bitfields.
+var bitfieldsStruct = &btf.Struct{
+	Name: "flags_event",
+	Size: 12,
+	Members: []btf.Member{
+		{Name: "pid", Type: u32Type, Offset: 0},
+		{Name: "is_ipv6", Type: u32Type, Offset: 32, BitfieldSize: 1},
+		{Name: "is_retrans", Type: u32Type, Offset: 33, BitfieldSize: 1},
+		{Name: "state", Type: u32Type, Offset: 34, BitfieldSize: 4},
+		{Name: "cross", Type: u32Type, Offset: 38, BitfieldSize: 5},
+		{Name: "delta", Type: s32Type, Offset: 64, BitfieldSize: 3},
+	},
+}
+
+func TestPopulateStructBitfields(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, bitfieldsStruct, nil, populateOptions{}, nil))
+
+	fields := m.Structs["flags_event"].Fields
+	require.Len(t, fields, 6)
+
+	require.Nil(t, fields[0].Annotations)
+	require.Equal(t, uint(columns.MaxCharsUint32), fields[0].Attributes.Width)
+
+	expected := []struct {
+		offset uint32
+		size   uint32
+		width  uint
+	}{
+		{offset: 32, size: 1, width: columns.MaxCharsBool},
+		{offset: 33, size: 1, width: columns.MaxCharsBool},
+		{offset: 34, size: 4, width: 2},
+		{offset: 38, size: 5, width: 2},
+		{offset: 64, size: 3, width: 2},
+	}
+	for i, e := range expected {
+		field := fields[i+1]
+		require.Equal(t, e.offset, field.Annotations[bitfieldOffsetAnnotation], field.Name)
+		require.Equal(t, e.size, field.Annotations[bitfieldSizeAnnotation], field.Name)
+		require.Equal(t, e.width, field.Attributes.Width, field.Name)
+	}
+}
+
+func TestValidateFieldBitfield(t *testing.T) {
+	type testCase struct {
+		annotations       map[string]interface{}
+		member            btf.Member
+		expectedErrString string
+	}
+
+	cross := bitfieldsStruct.Members[4]
+
+	tests := map[string]testCase{
+		"not_recorded": {
+			member: cross,
+		},
+		"matching": {
+			annotations: map[string]interface{}{
+				bitfieldOffsetAnnotation: 38,
+				bitfieldSizeAnnotation:   5,
+			},
+			member: cross,
+		},
+		"offset_changed": {
+			annotations: map[string]interface{}{
+				bitfieldOffsetAnnotation: 37,
+				bitfieldSizeAnnotation:   5,
+			},
+			member:            cross,
+			expectedErrString: "bitfield layout changed",
+		},
+		"size_changed": {
+			annotations: map[string]interface{}{
+				bitfieldOffsetAnnotation: 38,
+				bitfieldSizeAnnotation:   4,
+			},
+			member:            cross,
+			expectedErrString: "bitfield layout changed",
+		},
+		"no_longer_bitfield": {
+			annotations: map[string]interface{}{
+				bitfieldOffsetAnnotation: 32,
+				bitfieldSizeAnnotation:   1,
+			},
+			member:            btf.Member{Name: "cross", Type: u32Type, Offset: 32},
+			expectedErrString: "not one in the eBPF struct",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{Name: test.member.Name, Annotations: test.annotations}
+			err := validateFieldBitfield(field, test.member)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+var addrKindEnum = &btf.Enum{
+	Name: "addr_kind",
+	Size: 4,
+	Values: []btf.EnumValue{
+		{Name: "ADDR_V4", Value: 1},
+		{Name: "ADDR_V6", Value: 2},
+	},
+}
+
+// Stores an address of either family:
+//
+//	struct conn_event {
+//		__u16 family;
+//		enum addr_kind kind;
+//		union { __u32 v4; __u8 v6[16]; } addr;
+//	};
+var connEvent = &btf.Struct{
+	Name: "conn_event",
+	Size: 24,
+	Members: []btf.Member{
+		{Name: "family", Type: &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}},
+		{Name: "kind", Type: addrKindEnum, Offset: 32},
+		{Name: "addr", Type: &btf.Union{Size: 16, Members: []btf.Member{
+			{Name: "v4", Type: u32Type},
+			{Name: "v6", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+		}}, Offset: 64},
+	},
+}
+
+func TestPopulateStructUnionVariants(t *testing.T) {
+	t.Parallel()
+
+	members, err := flattenMembers(connEvent.Members, "", 0)
+	require.NoError(t, err)
+	require.Len(t, members, 5)
+	require.Equal(t, btf.Bits(64), members[4].Offset)
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, connEvent, nil, populateOptions{}, nil))
+
+	names := []string{}
+	hidden := []string{}
+	for _, f := range m.Structs["conn_event"].Fields {
+		names = append(names, f.Name)
+		if f.Attributes.Hidden {
+			hidden = append(hidden, f.Name)
+		}
+	}
+	require.Equal(t, []string{"family", "kind", "addr", "addr.v4", "addr.v6"}, names)
+	require.Equal(t, []string{"addr.v4", "addr.v6"}, hidden)
+
+	m2, ok := findMember(connEvent.Members, "addr.v6")
+	require.True(t, ok)
+	require.Equal(t, btf.Bits(64), m2.Offset)
+}
+
+func TestValidateFieldVariants(t *testing.T) {
+	type testCase struct {
+		field             metadatav1.Field
+		members           []btf.Member
+		expectedErrString string
+	}
+
+	nested := []btf.Member{
+		{Name: "conn", Type: &btf.Struct{Name: "conn", Members: connEvent.Members}},
+	}
+
+	tests := map[string]testCase{
+		"no_variants": {
+			field: metadatav1.Field{Name: "addr"},
+		},
+		"int_selector": {
+			field: metadatav1.Field{Name: "addr", VariantOf: "family", Variants: map[int64]string{2: "v4", 10: "v6"}},
+		},
+		"enum_selector": {
+			field: metadatav1.Field{Name: "addr", VariantOf: "kind", Variants: map[int64]string{1: "v4", 2: "v6"}},
+		},
+		"nested": {
+			field:   metadatav1.Field{Name: "conn.addr", VariantOf: "family", Variants: map[int64]string{2: "v4", 10: "v6"}},
+			members: nested,
+		},
+		"enum_unknown_value": {
+			field:             metadatav1.Field{Name: "addr", VariantOf: "kind", Variants: map[int64]string{1: "v4", 3: "v6"}},
+			expectedErrString: `value 3 of variant "v6" not found in enum of selector "kind"`,
+		},
+		"unknown_variant": {
+			field:             metadatav1.Field{Name: "addr", VariantOf: "family", Variants: map[int64]string{2: "v5"}},
+			expectedErrString: `variant "v5" for value 2 not found in union`,
+		},
+		"selector_not_found": {
+			field:             metadatav1.Field{Name: "addr", VariantOf: "proto", Variants: map[int64]string{2: "v4"}},
+			expectedErrString: `variant selector "proto" not found`,
+		},
+		"selector_not_integer": {
+			field:             metadatav1.Field{Name: "addr", VariantOf: "addr", Variants: map[int64]string{2: "v4"}},
+			expectedErrString: `variant selector "addr" must be an integer or an enum`,
+		},
+		"not_union": {
+			field:             metadatav1.Field{Name: "family", VariantOf: "kind", Variants: map[int64]string{1: "v4"}},
+			expectedErrString: "variantOf can only be set on union fields",
+		},
+		"no_mapping": {
+			field:             metadatav1.Field{Name: "addr", VariantOf: "family"},
+			expectedErrString: "variantOf needs a variants mapping",
+		},
+		"mapping_without_selector": {
+			field:             metadatav1.Field{Name: "addr", Variants: map[int64]string{2: "v4"}},
+			expectedErrString: "variants can only be set along with variantOf",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			members := test.members
+			if members == nil {
+				members = connEvent.Members
+			}
+			member, ok := findMember(members, test.field.Name)
+			require.True(t, ok)
+
+			err := validateFieldVariants(test.field, member, members)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestParamDescFromVar(t *testing.T) {
+	type testCase struct {
+		typ                    btf.Type
+		expectedTypeHint       params.TypeHint
+		expectedDefaultValue   string
+		expectedPossibleValues []string
+	}
+
+	constVolatile := func(typ btf.Type) btf.Type {
+		return &btf.Const{Type: &btf.Volatile{Type: typ}}
+	}
+
+	tests := map[string]testCase{
+		"bool": {
+			typ:                  constVolatile(&btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+			expectedTypeHint:     params.TypeBool,
+			expectedDefaultValue: "false",
+		},
+		"char_encoded": {
+			typ:                  constVolatile(&btf.Int{Name: "char", Size: 1, Encoding: btf.Char}),
+			expectedTypeHint:     params.TypeBool,
+			expectedDefaultValue: "false",
+		},
+		"u8": {
+			typ:                  constVolatile(&btf.Typedef{Name: "__u8", Type: &btf.Int{Name: "unsigned char", Size: 1}}),
+			expectedTypeHint:     params.TypeUint8,
+			expectedDefaultValue: "0",
+		},
+		"u64": {
+			typ:                  constVolatile(&btf.Typedef{Name: "__u64", Type: &btf.Int{Name: "unsigned long long", Size: 8}}),
+			expectedTypeHint:     params.TypeUint64,
+			expectedDefaultValue: "0",
+		},
+		"s32": {
+			typ:                  constVolatile(s32Type),
+			expectedTypeHint:     params.TypeInt32,
+			expectedDefaultValue: "0",
+		},
+		"typedef_u32": {
+			typ:                  constVolatile(&btf.Typedef{Name: "pid_t", Type: u32Type}),
+			expectedTypeHint:     params.TypeUint32,
+			expectedDefaultValue: "0",
+		},
+		"char_array": {
+			typ:              constVolatile(&btf.Array{Type: charType, Nelems: 16}),
+			expectedTypeHint: params.TypeString,
+		},
+		"unknown": {
+			typ:              constVolatile(&btf.Array{Type: u32Type, Nelems: 16}),
+			expectedTypeHint: params.TypeUnknown,
+		},
+		"enum": {
+			typ:                    constVolatile(captureModeEnum),
+			expectedTypeHint:       params.TypeUnknown,
+			expectedDefaultValue:   "CAPTURE_NONE",
+			expectedPossibleValues: []string{"CAPTURE_NONE", "CAPTURE_HEADERS", "CAPTURE_FULL"},
+		},
+		"enum_without_zero": {
+			typ: constVolatile(&btf.Enum{Name: "level", Size: 4, Values: []btf.EnumValue{
+				{Name: "LEVEL_LOW", Value: 1},
+				{Name: "LEVEL_HIGH", Value: 2},
+			}}),
+			expectedTypeHint:       params.TypeUnknown,
+			expectedDefaultValue:   "LEVEL_LOW",
+			expectedPossibleValues: []string{"LEVEL_LOW", "LEVEL_HIGH"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := paramDescFromVar(&btf.Var{Name: "param", Type: test.typ})
+			require.Equal(t, "param", p.Key)
+			require.Equal(t, test.expectedTypeHint, p.TypeHint)
+			require.Equal(t, test.expectedDefaultValue, p.DefaultValue)
+			require.Equal(t, test.expectedPossibleValues, p.PossibleValues)
+			require.False(t, p.IsMandatory)
+		})
+	}
+}
+
+func TestEBPFParamDisplay(t *testing.T) {
+	t.Parallel()
+
+	const metadata = `
+ebpfParams:
+  targ_min_us:
+    key: min
+    defaultValue: "0"
+    tags: [latency]
+    category: filtering
+    advanced: true
+  targ_debug:
+    key: debug
+    defaultValue: "false"
+    hidden: true
+`
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal([]byte(metadata), m))
+
+	minUs := m.EBPFParams["targ_min_us"]
+	require.Equal(t, "filtering", minUs.Category)
+	require.True(t, minUs.Advanced)
+	require.False(t, minUs.Hidden)
+
+	desc := minUs.ToParamDesc()
+	require.Equal(t, []string{"latency", "category:filtering", "advanced"}, desc.Tags)
+	require.Equal(t, "filtering", desc.Category())
+	require.True(t, desc.IsAdvanced())
+	require.False(t, desc.IsHidden())
+	// The metadata isn't modified
+	require.Equal(t, []string{"latency"}, minUs.Tags)
+
+	debug := m.EBPFParams["targ_debug"]
+	desc = debug.ToParamDesc()
+	require.Empty(t, desc.Category())
+	require.False(t, desc.IsAdvanced())
+	require.True(t, desc.IsHidden())
+
+	out, err := yaml.Marshal(m)
+	require.NoError(t, err)
+	roundTrip := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal(out, roundTrip))
+	require.Equal(t, m.EBPFParams, roundTrip.EBPFParams)
+}
+
+func TestValidateBoolParamTypeHint(t *testing.T) {
+	t.Parallel()
+
+	idx := newBTFIndex(newTestSpec(t,
+		constVar("enable_bool", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("enable_char", &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}),
+		constVar("enable_u8", &btf.Typedef{Name: "__u8", Type: &btf.Int{Name: "unsigned char", Size: 1}}),
+		constVar("enable_u32", u32Type),
+	))
+
+	boolParam := params.ParamDesc{TypeHint: params.TypeBool}
+	for _, varName := range []string{"enable_bool", "enable_char", "enable_u8"} {
+		require.NoError(t, validateParamTypeHint(boolParam, idx, varName), varName)
+	}
+	require.EqualError(t, validateParamTypeHint(boolParam, idx, "enable_u32"),
+		"param \"enable_u32\" has type \"bool\" but the eBPF variable is 4 bytes wide, expected 1")
+}
+
+// paramMarkersSpec returns a spec like the one of a gadget defining the
+// "ports" and "verbose" params, with a default value and description set
+// with GADGET_PARAM_DEFAULT() and GADGET_PARAM_DESC() for both of them, and
+// the "pid" param made mandatory with GADGET_PARAM_MANDATORY().
+func markersSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	char := &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}
+	u16 := &btf.Int{Name: "__u16", Size: 2}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	boolType := &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}
+
+	var contents []byte
+	var vars []btf.VarSecinfo
+	types := []btf.Type{}
+
+	addVar := func(name string, typ btf.Type, value []byte) {
+		v := &btf.Var{Name: name, Type: typ, Linkage: btf.GlobalVar}
+		types = append(types, v)
+		if value == nil {
+			return
+		}
+		vars = append(vars, btf.VarSecinfo{
+			Type:   v,
+			Offset: uint32(len(contents)),
+			Size:   uint32(len(value)),
+		})
+		contents = append(contents, value...)
+	}
+	addString := func(name, value string) {
+		value += "\x00"
+		addVar(name, &btf.Const{Type: &btf.Array{Type: char, Index: u16, Nelems: uint32(len(value))}}, []byte(value))
+	}
+
+	addVar("ports", &btf.Const{Type: &btf.Volatile{Type: u16}}, []byte{0, 0})
+	addVar("verbose", &btf.Const{Type: &btf.Volatile{Type: boolType}}, []byte{0})
+	addVar("gadget_param_ports", constVoidPtr, nil)
+	addVar("gadget_param_verbose", constVoidPtr, nil)
+	addVar("pid", &btf.Const{Type: &btf.Volatile{Type: u32}}, []byte{0, 0, 0, 0})
+	addVar("gadget_param_pid", constVoidPtr, nil)
+	addString("gadget_param_mandatory_pid", "")
+	addString("gadget_param_default_ports", "443")
+	addString("gadget_param_desc_ports", "Port to trace")
+	addString("gadget_param_default_verbose", "true")
+	addString("gadget_param_desc_verbose", "Show all the events")
+	addString("gadget_info_name", "trace ports")
+	addString("gadget_info_description", "Trace the ports used by processes")
+
+	datasec := &btf.Datasec{Name: ".rodata", Size: uint32(len(contents)), Vars: vars}
+	types = append(types, datasec)
+
+	spec := newTestBTF(t, types...)
+
+	var loadedDatasec *btf.Datasec
+	require.NoError(t, spec.TypeByName(".rodata", &loadedDatasec))
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			".rodata": {
+				Name:       ".rodata",
+				Type:       ebpf.Array,
+				KeySize:    4,
+				ValueSize:  uint32(len(contents)),
+				MaxEntries: 1,
+				Value:      loadedDatasec,
+				Contents:   []ebpf.MapKV{{Key: uint32(0), Value: contents}},
+			},
+		},
+		Types: spec,
+	}
+}
+
+// topperSpec returns a spec like the one of a gadget defining a topper with
+// GADGET_TOPPER(files, stats), whose map has the given type and uses struct
+// file_id as key and struct file_stat as value
+func topperSpec(t *testing.T, mapType ebpf.MapType) *ebpf.CollectionSpec {
+	t.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	key := &btf.Struct{Name: "file_id", Size: 8, Members: []btf.Member{
+		{Name: "inode", Type: u64},
+	}}
+	value := &btf.Struct{Name: "file_stat", Size: 24, Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "comm", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 4}, Offset: 32},
+		{Name: "reads", Type: u64, Offset: 64},
+		{Name: "rbytes", Type: u64, Offset: 128},
+	}}
+	types := []btf.Type{
+		key,
+		value,
+		&btf.Var{Name: "gadget_topper_files___stats", Type: constVoidPtr, Linkage: btf.GlobalVar},
+	}
+
+	spec := newTestBTF(t, types...)
+
+	var loadedKey, loadedValue *btf.Struct
+	require.NoError(t, spec.TypeByName("file_id", &loadedKey))
+	require.NoError(t, spec.TypeByName("file_stat", &loadedValue))
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"stats": {
+				Name:       "stats",
+				Type:       mapType,
+				KeySize:    loadedKey.Size,
+				ValueSize:  loadedValue.Size,
+				MaxEntries: 10240,
+				Key:        loadedKey,
+				Value:      loadedValue,
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestPopulateTopper(t *testing.T) {
+	t.Parallel()
+
+	for _, mapType := range []ebpf.MapType{ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash} {
+		mapType := mapType
+		t.Run(mapType.String(), func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, Populate(m, topperSpec(t, mapType)))
+			require.Equal(t, map[string]metadatav1.Topper{
+				"files": {MapName: "stats", StructName: "file_stat", KeyStructName: "file_id"},
+			}, m.Toppers)
+			require.Contains(t, m.Structs, "file_stat")
+
+			m.Toppers["files"] = metadatav1.Topper{
+				MapName:       "stats",
+				StructName:    "file_stat",
+				KeyStructName: "file_id",
+				SortField:     "rbytes",
+				Interval:      "2s",
+			}
+			require.NoError(t, Validate(m, topperSpec(t, mapType)))
+		})
+	}
+}
+
+func TestValidateTopperFields(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		topper            metadatav1.Topper
+		mapType           ebpf.MapType
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"good": {
+			topper: metadatav1.Topper{MapName: "stats", StructName: "file_stat", KeyStructName: "file_id", SortField: "reads", Interval: "500ms"},
+		},
+		"wrong_map_type": {
+			topper:            metadatav1.Topper{MapName: "stats", StructName: "file_stat"},
+			mapType:           ebpf.Array,
+			expectedErrString: "map \"stats\" has a wrong type, expected: hash or lru hash (optionally per-CPU), got: Array",
+		},
+		"wrong_key_struct": {
+			topper:            metadatav1.Topper{MapName: "stats", StructName: "file_stat", KeyStructName: "file_key"},
+			expectedErrString: "map \"stats\" key is \"file_id\", expected struct \"file_key\"",
+		},
+		"unknown_sort_field": {
+			topper:            metadatav1.Topper{MapName: "stats", StructName: "file_stat", SortField: "wbytes"},
+			expectedErrString: "sortField \"wbytes\" not found in struct \"file_stat\"",
+		},
+		"sort_field_not_numeric": {
+			topper:            metadatav1.Topper{MapName: "stats", StructName: "file_stat", SortField: "comm"},
+			expectedErrString: "sortField \"comm\" must be an integer field",
+		},
+		"bad_interval": {
+			topper:            metadatav1.Topper{MapName: "stats", StructName: "file_stat", Interval: "-1s"},
+			expectedErrString: "invalid interval \"-1s\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mapType := test.mapType
+			if mapType == ebpf.UnspecifiedMap {
+				mapType = ebpf.LRUHash
+			}
+			spec := topperSpec(t, mapType)
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, Populate(m, topperSpec(t, ebpf.Hash)))
+			m.Toppers["files"] = test.topper
+
+			err := Validate(m, spec)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+// profilerSpec returns a spec with a profiler of latencies per disk, whose
+// histograms are a struct hist { slot slots[nslots]; }
+func profilerSpec(t *testing.T, mapType ebpf.MapType, slot btf.Type, nslots uint32) *ebpf.CollectionSpec {
+	t.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	key := &btf.Struct{Name: "disk_key", Size: 4, Members: []btf.Member{
+		{Name: "dev", Type: u32Type},
+	}}
+	value := &btf.Struct{Name: "hist", Size: 8 * nslots, Members: []btf.Member{
+		{Name: "slots", Type: &btf.Array{Index: u32Type, Type: slot, Nelems: nslots}},
+	}}
+	types := []btf.Type{
+		key,
+		value,
+		&btf.Var{Name: "gadget_profiler_latency___hists", Type: constVoidPtr, Linkage: btf.GlobalVar},
+	}
+
+	spec := newTestBTF(t, types...)
+
+	var loadedKey, loadedValue *btf.Struct
+	require.NoError(t, spec.TypeByName("disk_key", &loadedKey))
+	require.NoError(t, spec.TypeByName("hist", &loadedValue))
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"hists": {
+				Name:       "hists",
+				Type:       mapType,
+				KeySize:    loadedKey.Size,
+				ValueSize:  loadedValue.Size,
+				MaxEntries: 256,
+				Key:        loadedKey,
+				Value:      loadedValue,
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestPopulateProfiler(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, Populate(m, profilerSpec(t, ebpf.Hash, u64, 27)))
+	require.Equal(t, map[string]metadatav1.Profiler{
+		"latency": {MapName: "hists", KeyStructName: "disk_key"},
+	}, m.Profilers)
+	require.Contains(t, m.Structs, "disk_key")
+
+	m.Profilers["latency"] = metadatav1.Profiler{
+		MapName:       "hists",
+		Unit:          metadatav1.UnitNanoseconds,
+		KeyStructName: "disk_key",
+		Interval:      "5s",
+	}
+	require.NoError(t, Validate(m, profilerSpec(t, ebpf.Hash, u64, 27)))
+}
+
+func TestValidateProfiler(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	s64 := &btf.Int{Name: "__s64", Size: 8, Encoding: btf.Signed}
+
+	type testCase struct {
+		profiler          metadatav1.Profiler
+		mapType           ebpf.MapType
+		slot              btf.Type
+		nslots            uint32
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"good": {
+			profiler: metadatav1.Profiler{MapName: "hists", Unit: metadatav1.UnitBytes, KeyStructName: "disk_key"},
+		},
+		"per_cpu_array": {
+			profiler: metadatav1.Profiler{MapName: "hists"},
+			mapType:  ebpf.PerCPUArray,
+		},
+		"wrong_map_type": {
+			profiler:          metadatav1.Profiler{MapName: "hists"},
+			mapType:           ebpf.RingBuf,
+			expectedErrString: "map \"hists\" has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: RingBuf",
+		},
+		"u32_slots": {
+			profiler:          metadatav1.Profiler{MapName: "hists"},
+			slot:              u32Type,
+			expectedErrString: "map \"hists\": slots are __u32, expected __u64",
+		},
+		"signed_slots": {
+			profiler:          metadatav1.Profiler{MapName: "hists"},
+			slot:              s64,
+			expectedErrString: "map \"hists\": slots are __s64, expected __u64",
+		},
+		"too_many_slots": {
+			profiler:          metadatav1.Profiler{MapName: "hists"},
+			nslots:            65,
+			expectedErrString: "map \"hists\": value has 65 slots, expected between 1 and 64",
+		},
+		"unknown_map": {
+			profiler:          metadatav1.Profiler{MapName: "latencies"},
+			expectedErrString: "map \"latencies\" not found in eBPF object",
+		},
+		"wrong_key_struct": {
+			profiler:          metadatav1.Profiler{MapName: "hists", KeyStructName: "part_key"},
+			expectedErrString: "map \"hists\" key is \"disk_key\", expected struct \"part_key\"",
+		},
+		"bad_unit": {
+			profiler:          metadatav1.Profiler{MapName: "hists", Unit: "parsecs"},
+			expectedErrString: "invalid unit \"parsecs\"",
+		},
+		"bad_interval": {
+			profiler:          metadatav1.Profiler{MapName: "hists", Interval: "soon"},
+			expectedErrString: "invalid interval \"soon\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mapType := test.mapType
+			if mapType == ebpf.UnspecifiedMap {
+				mapType = ebpf.Hash
+			}
+			slot := test.slot
+			if slot == nil {
+				slot = u64
+			}
+			nslots := test.nslots
+			if nslots == 0 {
+				nslots = 27
+			}
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, Populate(m, profilerSpec(t, ebpf.Hash, u64, 27)))
+			m.Profilers["latency"] = test.profiler
+
+			err := Validate(m, profilerSpec(t, mapType, slot, nslots))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateEbpfParamsMarkers(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		initialMetadata *metadatav1.GadgetMetadata
+		expectedParams  map[string]metadatav1.EBPFParam
+	}
+
+	tests := map[string]testCase{
+		"from_scratch": {
+			initialMetadata: &metadatav1.GadgetMetadata{},
+			expectedParams: map[string]metadatav1.EBPFParam{
+				"pid": {ParamDesc: params.ParamDesc{
+					Key:         "pid",
+					Description: "TODO: Fill parameter description",
+					TypeHint:    params.TypeUint32,
+					IsMandatory: true,
+				}},
+				"ports": {ParamDesc: params.ParamDesc{
+					Key:          "ports",
+					Description:  "Port to trace",
+					TypeHint:     params.TypeUint16,
+					DefaultValue: "443",
+				}},
+				"verbose": {ParamDesc: params.ParamDesc{
+					Key:          "verbose",
+					Description:  "Show all the events",
+					TypeHint:     params.TypeBool,
+					DefaultValue: "true",
+				}},
+			},
+		},
+		"metadata_wins": {
+			initialMetadata: &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"pid": {ParamDesc: params.ParamDesc{
+						Key:         "pid",
+						Description: "PID to trace",
+						TypeHint:    params.TypeUint32,
+					}},
+					"ports": {ParamDesc: params.ParamDesc{
+						Key:          "ports",
+						Description:  "Ports to trace, hand-edited",
+						TypeHint:     params.TypeUint16,
+						DefaultValue: "80",
+					}},
+					"verbose": {ParamDesc: params.ParamDesc{
+						Key:          "verbose",
+						Description:  "Print everything",
+						TypeHint:     params.TypeBool,
+						DefaultValue: "false",
+					}},
+				},
+			},
+			expectedParams: map[string]metadatav1.EBPFParam{
+				"ports": {ParamDesc: params.ParamDesc{
+					Key:          "ports",
+					Description:  "Ports to trace, hand-edited",
+					TypeHint:     params.TypeUint16,
+					DefaultValue: "80",
+				}},
+				"verbose": {ParamDesc: params.ParamDesc{
+					Key:          "verbose",
+					Description:  "Print everything",
+					TypeHint:     params.TypeBool,
+					DefaultValue: "false",
+				}},
+				"pid": {ParamDesc: params.ParamDesc{
+					Key:         "pid",
+					Description: "PID to trace",
+					TypeHint:    params.TypeUint32,
+					IsMandatory: true,
+				}},
+			},
+		},
+		"metadata_missing_values": {
+			initialMetadata: &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"ports": {ParamDesc: params.ParamDesc{
+						Key:         "ports",
+						Description: "TODO: Fill parameter description",
+					}},
+					"verbose": {ParamDesc: params.ParamDesc{
+						Key:          "verbose",
+						DefaultValue: "false",
+					}},
+				},
+			},
+			expectedParams: map[string]metadatav1.EBPFParam{
+				"ports": {ParamDesc: params.ParamDesc{
+					Key:          "ports",
+					Description:  "Port to trace",
+					DefaultValue: "443",
+				}},
+				"verbose": {ParamDesc: params.ParamDesc{
+					Key:          "verbose",
+					Description:  "Show all the events",
+					DefaultValue: "false",
+				}},
+				"pid": {ParamDesc: params.ParamDesc{
+					Key:         "pid",
+					Description: "TODO: Fill parameter description",
+					TypeHint:    params.TypeUint32,
+					IsMandatory: true,
+				}},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := populateEbpfParams(test.initialMetadata, newBTFIndex(markersSpec(t)), nil)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedParams, test.initialMetadata.EBPFParams)
+		})
+	}
+}
+
+type testDeclTag struct {
+	target btf.Type
+	index  int
+	value  string
+}
+
+// btfSpecWithDeclTags returns a spec with types and decl tags. btf.Builder
+// can't add decl tags, so they're appended to the marshaled BTF.
+func btfSpecWithDeclTags(t *testing.T, types []btf.Type, tags []testDeclTag) *btf.Spec {
+	t.Helper()
+
+	const (
+		hdrLen      = 24
+		kindDeclTag = 17
+	)
+
+	b, err := btf.NewBuilder(types)
+	require.NoError(t, err)
+
+	var typesSec, stringsSec []byte
+	for _, tag := range tags {
+		id, err := b.Add(tag.target)
+		require.NoError(t, err)
+
+		typesSec = binary.NativeEndian.AppendUint32(typesSec, uint32(len(stringsSec)))
+		typesSec = binary.NativeEndian.AppendUint32(typesSec, kindDeclTag<<24)
+		typesSec = binary.NativeEndian.AppendUint32(typesSec, uint32(id))
+		typesSec = binary.NativeEndian.AppendUint32(typesSec, uint32(int32(tag.index)))
+		stringsSec = append(stringsSec, tag.value+"\x00"...)
+	}
+
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(t, err)
+
+	typeOff := binary.NativeEndian.Uint32(raw[8:])
+	typeLen := binary.NativeEndian.Uint32(raw[12:])
+	strOff := binary.NativeEndian.Uint32(raw[16:])
+	strLen := binary.NativeEndian.Uint32(raw[20:])
+	oldTypes := raw[hdrLen+typeOff : hdrLen+typeOff+typeLen]
+	oldStrings := raw[hdrLen+strOff : hdrLen+strOff+strLen]
+
+	// Names of the tags are offsets in the new strings section
+	for i := 0; i < len(typesSec); i += 16 {
+		nameOff := binary.NativeEndian.Uint32(typesSec[i:])
+		binary.NativeEndian.PutUint32(typesSec[i:], nameOff+strLen)
+	}
+
+	var out []byte
+	out = append(out, raw[:hdrLen]...)
+	binary.NativeEndian.PutUint32(out[8:], 0)
+	binary.NativeEndian.PutUint32(out[12:], typeLen+uint32(len(typesSec)))
+	binary.NativeEndian.PutUint32(out[16:], typeLen+uint32(len(typesSec)))
+	binary.NativeEndian.PutUint32(out[20:], strLen+uint32(len(stringsSec)))
+	out = append(out, oldTypes...)
+	out = append(out, typesSec...)
+	out = append(out, oldStrings...)
+	out = append(out, stringsSec...)
+
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(out))
+	require.NoError(t, err)
+	return spec
+}
+
+func TestPopulateStructDeclTags(t *testing.T) {
+	t.Parallel()
+
+	u16 := &btf.Int{Name: "__u16", Size: 2}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	addr := &btf.Struct{
+		Name: "addr",
+		Size: 4,
+		Members: []btf.Member{
+			{Name: "port", Type: u16},
+			{Name: "proto", Type: u16, Offset: 16},
+		},
+	}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 12,
+		Members: []btf.Member{
+			{Name: "pid", Type: u32},
+			{Name: "uid", Type: u32, Offset: 32},
+			{Name: "addr", Type: addr, Offset: 64},
+		},
+	}
+
+	spec := btfSpecWithDeclTags(t, []btf.Type{event}, []testDeclTag{
+		{target: event, index: 0, value: "ig:desc=Process ID"},
+		{target: event, index: 1, value: "other tag"},
+		{target: addr, index: 0, value: "ig:desc=Destination port"},
+	})
+
+	var btfStruct *btf.Struct
+	require.NoError(t, spec.TypeByName("event", &btfStruct))
+
+	type testCase struct {
+		initialFields []metadatav1.Field
+		expected      map[string]string
+	}
+
+	tests := map[string]testCase{
+		"from_scratch": {
+			expected: map[string]string{
+				"pid":        "Process ID",
+				"uid":        "TODO: Fill field description",
+				"addr.port":  "Destination port",
+				"addr.proto": "TODO: Fill field description",
+			},
+		},
+		"placeholder_replaced": {
+			initialFields: []metadatav1.Field{
+				{Name: "pid", Description: "TODO: Fill field description"},
+			},
+			expected: map[string]string{
+				"pid":        "Process ID",
+				"uid":        "TODO: Fill field description",
+				"addr.port":  "Destination port",
+				"addr.proto": "TODO: Fill field description",
+			},
+		},
+		"metadata_wins": {
+			initialFields: []metadatav1.Field{
+				{Name: "pid", Description: "PID of the process"},
+				{Name: "addr.port", Description: ""},
+			},
+			expected: map[string]string{
+				"pid":        "PID of the process",
+				"uid":        "TODO: Fill field description",
+				"addr.port":  "",
+				"addr.proto": "TODO: Fill field description",
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: append([]metadatav1.Field(nil), test.initialFields...)},
+				},
+			}
+			require.NoError(t, populateStruct(m, btfStruct, spec, populateOptions{}, nil))
+
+			descriptions := make(map[string]string)
+			for _, field := range m.Structs["event"].Fields {
+				descriptions[field.Name] = field.Description
+			}
+			require.Equal(t, test.expected, descriptions)
+		})
+	}
+}
+
+func TestPopulateEbpfParamsDeclTags(t *testing.T) {
+	t.Parallel()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	limit := constVar("limit", u32)
+	types := []btf.Type{
+		limit,
+		&btf.Var{Name: "gadget_param_limit", Type: constVoidPtr, Linkage: btf.GlobalVar},
+	}
+
+	btfSpec := btfSpecWithDeclTags(t, types, []testDeclTag{
+		{target: limit, index: -1, value: "ig:desc=Maximum number of events"},
+	})
+	idx := newBTFIndex(&ebpf.CollectionSpec{Types: btfSpec})
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateEbpfParams(m, idx, nil))
+	require.Equal(t, "Maximum number of events", m.EBPFParams["limit"].Description)
+
+	m = &metadatav1.GadgetMetadata{
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"limit": {ParamDesc: params.ParamDesc{Key: "limit", Description: "Hand-edited"}},
+		},
+	}
+	require.NoError(t, populateEbpfParams(m, idx, nil))
+	require.Equal(t, "Hand-edited", m.EBPFParams["limit"].Description)
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	const (
+		objectPath = "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o"
+		goldenPath = "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.yaml"
+	)
+
+	spec, err := ebpf.LoadCollectionSpec(objectPath)
+	require.NoError(t, err)
+
+	populate := func(m *metadatav1.GadgetMetadata) []byte {
+		require.NoError(t, Populate(m, spec))
+		out, err := Marshal(m)
+		require.NoError(t, err)
+		return out
+	}
+
+	first := populate(&metadatav1.GadgetMetadata{})
+	second := populate(&metadatav1.GadgetMetadata{})
+	require.Equal(t, string(first), string(second))
+
+	// Populating the generated file again doesn't change it
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal(first, m))
+	require.Equal(t, string(first), string(populate(m)))
+
+	golden, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(first))
+}
+
+func TestPopulateStructPadding(t *testing.T) {
+	t.Parallel()
+
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	byteArray := func(n uint32) *btf.Array {
+		return &btf.Array{Type: u8, Index: u32, Nelems: n}
+	}
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "pid", Type: u32},
+			{Name: "__pad", Type: byteArray(4), Offset: 32},
+			{Name: "ts", Type: u64, Offset: 64},
+			{Name: "flag", Type: u8, Offset: 128},
+			// Only aligns _internal_state
+			{Name: "padding", Type: byteArray(3), Offset: 136},
+			{Name: "_internal_state", Type: u32, Offset: 160},
+			// Not needed for alignment, so it's kept
+			{Name: "reserved", Type: byteArray(4), Offset: 192},
+			{Name: "count", Type: u32, Offset: 224},
+		},
+	}
+
+	type expectedField struct {
+		name   string
+		hidden bool
+	}
+
+	type testCase struct {
+		opts     populateOptions
+		expected []expectedField
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			expected: []expectedField{
+				{name: "pid"},
+				{name: "ts"},
+				{name: "flag"},
+				{name: "_internal_state", hidden: true},
+				{name: "reserved"},
+				{name: "count"},
+			},
+		},
+		"all_members": {
+			opts: populateOptions{allMembers: true},
+			expected: []expectedField{
+				{name: "pid"},
+				{name: "__pad"},
+				{name: "ts"},
+				{name: "flag"},
+				{name: "padding"},
+				{name: "_internal_state"},
+				{name: "reserved"},
+				{name: "count"},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, populateStruct(m, event, nil, test.opts, nil))
+
+			var fields []expectedField
+			for _, field := range m.Structs["event"].Fields {
+				fields = append(fields, expectedField{name: field.Name, hidden: field.Attributes.Hidden})
+			}
+			require.Equal(t, test.expected, fields)
+		})
+	}
+}
+
+func TestPopulateStructFlags(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	openFlags := &btf.Enum{
+		Name: "open_flags",
+		Size: 4,
+		Values: []btf.EnumValue{
+			{Name: "O_RDONLY", Value: 0},
+			{Name: "O_WRONLY", Value: 1},
+			{Name: "O_RDWR", Value: 2},
+			{Name: "O_ACCMODE", Value: 3},
+			{Name: "O_CREAT", Value: 0o100},
+			{Name: "O_CLOEXEC", Value: 0o2000000},
+		},
+	}
+	modeFlags := &btf.Enum{
+		Name:   "mode_flags",
+		Size:   4,
+		Values: []btf.EnumValue{{Name: "M_READ", Value: 1}},
+	}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "open_flags", Type: u32},
+			// No enum named flags or event_flags
+			{Name: "flags", Type: u32, Offset: 32},
+			// Opted out
+			{Name: "mode_flags", Type: u32, Offset: 64},
+			// Enum set explicitly
+			{Name: "how", Type: u32, Offset: 96},
+		},
+	}
+
+	spec := btfSpecWithDeclTags(t, []btf.Type{event, openFlags, modeFlags}, []testDeclTag{
+		{target: event, index: 2, value: "ig:flags=none"},
+		{target: event, index: 3, value: "ig:flags=open_flags"},
+	})
+	var btfStruct *btf.Struct
+	require.NoError(t, spec.TypeByName("event", &btfStruct))
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, btfStruct, spec, populateOptions{}, nil))
+
+	openAnnotations := map[string]interface{}{
+		"ebpf.formatter.bitmask.bits": "0:O_WRONLY,1:O_RDWR,6:O_CREAT,19:O_CLOEXEC",
+		"ebpf.formatter.bitmask.zero": "O_RDONLY",
+	}
+
+	annotations := make(map[string]map[string]interface{})
+	for _, field := range m.Structs["event"].Fields {
+		annotations[field.Name] = field.Annotations
+	}
+	require.Equal(t, map[string]map[string]interface{}{
+		"open_flags": withAnnotation(openAnnotations, "ebpf.formatter.bitmask", "open_flags_str"),
+		"flags":      nil,
+		"mode_flags": nil,
+		"how":        withAnnotation(openAnnotations, "ebpf.formatter.bitmask", "how_str"),
+	}, annotations)
+
+	// The populated annotations are valid
+	for _, field := range m.Structs["event"].Fields {
+		member, ok := findMember(btfStruct.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldBitmask(field, member))
+	}
+}
+
+func withAnnotation(annotations map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	out := map[string]interface{}{key: value}
+	for k, v := range annotations {
+		out[k] = v
+	}
+	return out
+}
+
+func TestValidateFieldBitmask(t *testing.T) {
+	t.Parallel()
+
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	type testCase struct {
+		member            btf.Member
+		bits              interface{}
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_bitmask": {
+			member: btf.Member{Name: "flags", Type: u32},
+		},
+		"valid": {
+			member: btf.Member{Name: "flags", Type: u32},
+			bits:   "0:A,31:B",
+		},
+		"bit_too_high": {
+			member:            btf.Member{Name: "flags", Type: u8},
+			bits:              "0:A,8:B",
+			expectedErrString: "bit 8 (B) doesn't fit in 8 bits",
+		},
+		"bitfield": {
+			member:            btf.Member{Name: "flags", Type: u32, BitfieldSize: 4},
+			bits:              "4:A",
+			expectedErrString: "bit 4 (A) doesn't fit in 4 bits",
+		},
+		"not_integer": {
+			member:            btf.Member{Name: "flags", Type: &btf.Array{Type: u8, Index: u32, Nelems: 4}},
+			bits:              "0:A",
+			expectedErrString: "bitmask is only supported for integers",
+		},
+		"invalid": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			bits:              "A",
+			expectedErrString: "invalid bitmask entry \"A\"",
+		},
+		"not_string": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			bits:              42,
+			expectedErrString: "must be a string",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{Name: test.member.Name}
+			if test.bits != nil {
+				field.Annotations = map[string]interface{}{"ebpf.formatter.bitmask.bits": test.bits}
+			}
+			err := validateFieldBitmask(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldFlags(t *testing.T) {
+	t.Parallel()
+
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	openFlags := []metadatav1.Flag{
+		{Name: "O_RDONLY", Value: 0},
+		{Name: "O_WRONLY", Value: 0o1},
+		{Name: "O_RDWR", Value: 0o2},
+		{Name: "O_CREAT", Value: 0o100},
+		{Name: "O_CLOEXEC", Value: 0o2000000},
+	}
+
+	type testCase struct {
+		member            btf.Member
+		flags             []metadatav1.Flag
+		values            map[int64]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_flags": {
+			member: btf.Member{Name: "flags", Type: u32},
+		},
+		"open_flags": {
+			member: btf.Member{Name: "flags", Type: u32},
+			flags:  openFlags,
+		},
+		"mask": {
+			member: btf.Member{Name: "flags", Type: u32},
+			flags:  append(openFlags, metadatav1.Flag{Name: "O_ACCMODE", Value: 0o3, Mask: true}),
+		},
+		"several_bits": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			flags:             append(openFlags, metadatav1.Flag{Name: "O_ACCMODE", Value: 0o3}),
+			expectedErrString: `flag "O_ACCMODE" (0x3) has several bits set, declare it as a mask`,
+		},
+		"too_wide": {
+			member:            btf.Member{Name: "flags", Type: u8},
+			flags:             openFlags,
+			expectedErrString: `flag "O_CLOEXEC" (0x80000) doesn't fit in 8 bits`,
+		},
+		"bitfield": {
+			member:            btf.Member{Name: "flags", Type: u32, BitfieldSize: 4},
+			flags:             []metadatav1.Flag{{Name: "A", Value: 0x10}},
+			expectedErrString: `flag "A" (0x10) doesn't fit in 4 bits`,
+		},
+		"duplicated_name": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			flags:             []metadatav1.Flag{{Name: "A", Value: 1}, {Name: "A", Value: 2}},
+			expectedErrString: `flag "A" defined more than once`,
+		},
+		"duplicated_value": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			flags:             []metadatav1.Flag{{Name: "A", Value: 1}, {Name: "B", Value: 1}},
+			expectedErrString: `flags "A" and "B" have the same value 0x1`,
+		},
+		"no_name": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			flags:             []metadatav1.Flag{{Value: 4}},
+			expectedErrString: "flag with value 0x4 has no name",
+		},
+		"values": {
+			member:            btf.Member{Name: "flags", Type: u32},
+			flags:             openFlags,
+			values:            map[int64]string{1: "ONE"},
+			expectedErrString: "flags and values can't be set together",
+		},
+		"not_integer": {
+			member:            btf.Member{Name: "flags", Type: &btf.Array{Type: u8, Index: u32, Nelems: 4}},
+			flags:             openFlags,
+			expectedErrString: "flags are only supported for integers",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{Name: test.member.Name, Flags: test.flags, Values: test.values}
+			err := validateFieldFlags(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func endpointTypes() (l3, l4 *btf.Struct) {
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u16 := &btf.Int{Name: "__u16", Size: 2}
+	addr := &btf.Union{
+		Name: "gadget_ip_addr_t",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "v6", Type: &btf.Array{Type: u8, Index: u8, Nelems: 16}},
+		},
+	}
+	l3 = &btf.Struct{
+		Name: "gadget_l3endpoint_t",
+		Size: 20,
+		Members: []btf.Member{
+			{Name: "addr_raw", Type: addr},
+			{Name: "version", Type: u8, Offset: 128},
+		},
+	}
+	l4 = &btf.Struct{
+		Name: "gadget_l4endpoint_t",
+		Size: 24,
+		Members: []btf.Member{
+			{Name: "addr_raw", Type: addr},
+			{Name: "port", Type: u16, Offset: 128},
+			{Name: "proto", Type: u16, Offset: 144},
+			{Name: "version", Type: u8, Offset: 160},
+		},
+	}
+	return l3, l4
+}
+
+func TestPopulateStructEndpoints(t *testing.T) {
+	t.Parallel()
+
+	l3, l4 := endpointTypes()
+	event := &btf.Struct{
+		Name: "event",
+		Size: 96,
+		Members: []btf.Member{
+			{Name: "addr", Type: l3},
+			{Name: "src", Type: l4, Offset: 160},
+			{Name: "dst", Type: &btf.Typedef{Name: "my_endpoint_t", Type: l4}, Offset: 352},
+			{Name: "pid", Type: &btf.Int{Name: "__u32", Size: 4}, Offset: 544},
+			{Name: "peer", Type: &btf.Const{Type: &btf.Typedef{Name: "my_endpoint_t", Type: &btf.Volatile{Type: l4}}}, Offset: 576},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	type endpointInfo struct {
+		template   string
+		width      uint
+		annotation interface{}
+	}
+
+	got := make(map[string]endpointInfo)
+	for _, field := range m.Structs["event"].Fields {
+		got[field.Name] = endpointInfo{
+			template:   field.Attributes.Template,
+			width:      field.Attributes.Width,
+			annotation: field.Annotations["ebpf.endpoint"],
+		}
+	}
+	require.Equal(t, map[string]endpointInfo{
+		"addr": {template: "ipaddr", width: 45, annotation: "l3"},
+		"src":  {template: "l4endpoint", width: 51, annotation: "l4"},
+		"dst":  {template: "l4endpoint", width: 51, annotation: "l4"},
+		"pid":  {width: columns.MaxCharsUint32},
+		"peer": {template: "l4endpoint", width: 51, annotation: "l4"},
+	}, got)
+
+	// The populated fields are valid
+	for _, field := range m.Structs["event"].Fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldEndpoint(field, member))
+	}
+}
+
+// int128Types returns an unsigned __int128, a signed one and struct in6_addr
+func int128Types() (u128, s128 *btf.Int, in6 *btf.Struct) {
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u128 = &btf.Int{Name: "unsigned __int128", Size: 16}
+	s128 = &btf.Int{Name: "__int128", Size: 16, Encoding: btf.Signed}
+	in6 = &btf.Struct{
+		Name: "in6_addr",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "in6_u", Type: &btf.Union{
+				Size: 16,
+				Members: []btf.Member{
+					{Name: "u6_addr8", Type: &btf.Array{Type: u8, Index: u8, Nelems: 16}},
+				},
+			}},
+		},
+	}
+	return u128, s128, in6
+}
+
+func TestPopulateStructInt128(t *testing.T) {
+	t.Parallel()
+
+	u128, s128, in6 := int128Types()
+	event := &btf.Struct{
+		Name: "event",
+		Size: 64,
+		Members: []btf.Member{
+			{Name: "bytes", Type: &btf.Typedef{Name: "__u128", Type: u128}},
+			{Name: "delta", Type: s128, Offset: 128},
+			{Name: "saddr", Type: in6, Offset: 256},
+			{Name: "hash", Type: &btf.Array{Type: u32Type, Nelems: 4}, Offset: 384},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	type int128Info struct {
+		template   string
+		width      uint
+		alignment  metadatav1.Alignment
+		annotation interface{}
+	}
+
+	got := make(map[string]int128Info)
+	for _, field := range m.Structs["event"].Fields {
+		got[field.Name] = int128Info{
+			template:   field.Attributes.Template,
+			width:      field.Attributes.Width,
+			alignment:  field.Attributes.Alignment,
+			annotation: field.Annotations["ebpf.int128"],
+		}
+	}
+	require.Equal(t, map[string]int128Info{
+		"bytes": {width: 39, alignment: metadatav1.AlignmentRight},
+		"delta": {width: 40, alignment: metadatav1.AlignmentRight},
+		"saddr": {template: "ipaddr", width: 45, alignment: metadatav1.AlignmentLeft, annotation: "ipv6"},
+		"hash":  {width: metadatav1.DefaultColumnWidth, alignment: metadatav1.AlignmentLeft},
+	}, got)
+
+	// The populated fields are valid
+	for _, field := range m.Structs["event"].Fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldInt128(field, member))
+		require.NoError(t, validateFieldInt128Width(field, member))
+		require.NoError(t, validateFieldPointer(field, member))
+	}
+}
+
+func TestValidateFieldInt128(t *testing.T) {
+	t.Parallel()
+
+	u128, s128, in6 := int128Types()
+
+	type testCase struct {
+		member            btf.Member
+		width             uint
+		base              metadatav1.Base
+		annotation        interface{}
+		expectedErrString string
+		expectedWarning   string
+	}
+
+	tests := map[string]testCase{
+		"number": {
+			member:     btf.Member{Name: "bytes", Type: u128},
+			width:      39,
+			annotation: "number",
+		},
+		"hex": {
+			member: btf.Member{Name: "bytes", Type: u128},
+			width:  34,
+			base:   metadatav1.BaseHex,
+		},
+		"array_bytes": {
+			member:     btf.Member{Name: "hash", Type: &btf.Array{Type: u32Type, Nelems: 4}},
+			annotation: "bytes",
+		},
+		"in6_addr": {
+			member:     btf.Member{Name: "saddr", Type: in6},
+			annotation: "ipv6",
+		},
+		"too_narrow_signed": {
+			member:          btf.Member{Name: "delta", Type: s128},
+			width:           39,
+			expectedWarning: "width is 39 but 128-bit values need up to 40 characters",
+		},
+		"too_narrow_hex": {
+			member:          btf.Member{Name: "bytes", Type: u128},
+			width:           20,
+			base:            metadatav1.BaseHex,
+			expectedWarning: "need up to 34 characters",
+		},
+		"not_128_bit": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			annotation:        "number",
+			expectedErrString: "can only be set on 128-bit fields",
+		},
+		"char_array": {
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 8}},
+			annotation:        "bytes",
+			expectedErrString: "can only be set on 128-bit fields",
+		},
+		"invalid_annotation": {
+			member:            btf.Member{Name: "bytes", Type: u128},
+			annotation:        "string",
+			expectedErrString: "annotation is \"string\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Width: test.width, Base: test.base},
+			}
+			if test.annotation != nil {
+				field.Annotations = map[string]interface{}{"ebpf.int128": test.annotation}
+			}
+
+			require.NoError(t, validateFieldPointer(field, test.member))
+
+			err := validateFieldInt128Width(field, test.member)
+			if test.expectedWarning == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, test.expectedWarning)
+			}
+
+			err = validateFieldInt128(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestIsSpecialType(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	mntns := &btf.Typedef{Name: "gadget_mntns_id", Type: u64}
+	_, l4 := endpointTypes()
+
+	type testCase struct {
+		typ      btf.Type
+		name     string
+		expected bool
+	}
+
+	tests := map[string]testCase{
+		"mntns": {
+			typ:      mntns,
+			name:     "gadget_mntns_id",
+			expected: true,
+		},
+		"typedef_mntns": {
+			typ:      &btf.Typedef{Name: "my_mntns_t", Type: mntns},
+			name:     "gadget_mntns_id",
+			expected: true,
+		},
+		"qualified_typedef_mntns": {
+			typ:      &btf.Const{Type: &btf.Typedef{Name: "my_mntns_t", Type: &btf.Volatile{Type: mntns}}},
+			name:     "gadget_mntns_id",
+			expected: true,
+		},
+		"underlying_int": {
+			typ:  mntns,
+			name: "gadget_netns_id",
+		},
+		"plain_u64": {
+			typ:  u64,
+			name: "gadget_mntns_id",
+		},
+		"typedef_endpoint": {
+			typ:      &btf.Typedef{Name: "my_endpoint_t", Type: &btf.Const{Type: l4}},
+			name:     "gadget_l4endpoint_t",
+			expected: true,
+		},
+		"nil": {
+			name: "gadget_mntns_id",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, isSpecialType(test.typ, test.name))
+		})
+	}
+}
+
+func TestCountSpecialMembers(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	mntns := &btf.Typedef{Name: "gadget_mntns_id", Type: u64}
+	myMntns := &btf.Typedef{Name: "my_mntns_t", Type: mntns}
+	proc := &btf.Struct{
+		Name: "proc",
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "mntns_id", Type: myMntns},
+		},
+	}
+	outer := &btf.Struct{
+		Name: "outer",
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "proc", Type: proc},
+		},
+	}
+
+	type testCase struct {
+		members  []btf.Member
+		expected int
+	}
+
+	tests := map[string]testCase{
+		"none": {
+			members: []btf.Member{{Name: "pid", Type: u64}},
+		},
+		"direct": {
+			members:  []btf.Member{{Name: "mntns_id", Type: mntns}},
+			expected: 1,
+		},
+		"typedef": {
+			members:  []btf.Member{{Name: "mntns_id", Type: myMntns}},
+			expected: 1,
+		},
+		"direct_and_typedef": {
+			members: []btf.Member{
+				{Name: "mntns_id", Type: mntns},
+				{Name: "other_mntns_id", Type: myMntns, Offset: 64},
+			},
+			expected: 2,
+		},
+		"embedded": {
+			members: []btf.Member{
+				{Name: "mntns_id", Type: mntns},
+				{Name: "proc", Type: &btf.Typedef{Name: "proc_t", Type: proc}, Offset: 64},
+			},
+			expected: 2,
+		},
+		"embedded_two_levels": {
+			members:  []btf.Member{{Name: "outer", Type: outer}},
+			expected: 0,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, countSpecialMembers(test.members, "gadget_mntns_id"))
+		})
+	}
+}
+
+func TestValidateFieldEndpoint(t *testing.T) {
+	t.Parallel()
+
+	l3, l4 := endpointTypes()
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		annotation        interface{}
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"l4": {
+			member:     btf.Member{Name: "src", Type: l4},
+			template:   "l4endpoint",
+			annotation: "l4",
+		},
+		"l3": {
+			member:     btf.Member{Name: "addr", Type: l3},
+			template:   "ipaddr",
+			annotation: "l3",
+		},
+		"typedef": {
+			member:     btf.Member{Name: "src", Type: &btf.Typedef{Name: "my_endpoint_t", Type: l4}},
+			template:   "l4endpoint",
+			annotation: "l4",
+		},
+		"plain_address": {
+			member:   btf.Member{Name: "addr", Type: u32},
+			template: "ipaddr",
+		},
+		"template_not_endpoint": {
+			member:            btf.Member{Name: "addr", Type: u32},
+			template:          "l4endpoint",
+			expectedErrString: "template \"l4endpoint\" can only be used by l4 endpoint fields",
+		},
+		"template_l3": {
+			member:            btf.Member{Name: "addr", Type: l3},
+			template:          "l4endpoint",
+			expectedErrString: "template \"l4endpoint\" can only be used by l4 endpoint fields",
+		},
+		"annotation_not_endpoint": {
+			member:            btf.Member{Name: "addr", Type: u32},
+			annotation:        "l3",
+			expectedErrString: "can only be set on endpoint fields",
+		},
+		"annotation_wrong_kind": {
+			member:            btf.Member{Name: "src", Type: l4},
+			annotation:        "l3",
+			expectedErrString: "annotation is \"l3\", expected \"l4\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			if test.annotation != nil {
+				field.Annotations = map[string]interface{}{"ebpf.endpoint": test.annotation}
+			}
+			err := validateFieldEndpoint(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructIPAddresses(t *testing.T) {
+	t.Parallel()
+
+	be32 := &btf.Typedef{Name: "__be32", Type: &btf.Int{Name: "unsigned int", Size: 4}}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "saddr", Type: be32},
+			{Name: "daddr", Type: &btf.Array{Type: u8Type, Nelems: 16}, Offset: 32},
+			{Name: "pid", Type: u32Type, Offset: 160},
+			{Name: "laddr", Type: s32Type, Offset: 192},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	report := newPopulateReport()
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, report))
+
+	type ipInfo struct {
+		template string
+		width    uint
+		version  uint
+	}
+
+	got := make(map[string]ipInfo)
+	for _, field := range m.Structs["event"].Fields {
+		if field.Attributes.IPVersion == 0 {
+			continue
+		}
+		got[field.Name] = ipInfo{
+			template: field.Attributes.Template,
+			width:    field.Attributes.Width,
+			version:  field.Attributes.IPVersion,
+		}
+	}
+	require.Equal(t, map[string]ipInfo{
+		"saddr": {template: "ipaddr", width: 15, version: 4},
+		"daddr": {template: "ipaddr", width: 45, version: 6},
+	}, got)
+
+	// The guesses are reported so the author can confirm them
+	require.Len(t, report.Warnings, 2)
+	require.Contains(t, report.Warnings[0], "saddr")
+	require.Contains(t, report.Warnings[1], "daddr")
+
+	// The populated fields are valid
+	for _, field := range m.Structs["event"].Fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldIPVersion(field, member))
+	}
+}
+
+func TestValidateFieldIPVersion(t *testing.T) {
+	t.Parallel()
+
+	_, _, in6 := int128Types()
+	be32 := &btf.Typedef{Name: "__be32", Type: &btf.Int{Name: "unsigned int", Size: 4}}
+	u16 := &btf.Int{Name: "__u16", Size: 2}
+
+	type testCase struct {
+		member            btf.Member
+		version           uint
+		annotation        interface{}
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_version": {
+			member: btf.Member{Name: "port", Type: u16},
+		},
+		"v4": {
+			member:  btf.Member{Name: "saddr", Type: be32},
+			version: 4,
+		},
+		"v4_array": {
+			member:  btf.Member{Name: "saddr", Type: &btf.Array{Type: u8Type, Nelems: 4}},
+			version: 4,
+		},
+		"v6_array": {
+			member:  btf.Member{Name: "daddr", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			version: 6,
+		},
+		"v6_in6_addr": {
+			member:     btf.Member{Name: "daddr", Type: in6},
+			version:    6,
+			annotation: "ipv6",
+		},
+		"v6_too_small": {
+			member:            btf.Member{Name: "saddr", Type: be32},
+			version:           6,
+			expectedErrString: "ipversion 6 needs a 16-byte member, got 4 bytes",
+		},
+		"v4_too_big": {
+			member:            btf.Member{Name: "daddr", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			version:           4,
+			expectedErrString: "ipversion 4 needs a 4-byte member, got 16 bytes",
+		},
+		"invalid_version": {
+			member:            btf.Member{Name: "saddr", Type: be32},
+			version:           5,
+			expectedErrString: "invalid ipversion 5",
+		},
+		"not_integer": {
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: &btf.Struct{Name: "foo", Size: 1}, Nelems: 4}},
+			version:           4,
+			expectedErrString: "ipversion can only be set on integers",
+		},
+		"int128_number": {
+			member:            btf.Member{Name: "daddr", Type: &btf.Array{Type: u32Type, Nelems: 4}},
+			version:           6,
+			annotation:        "number",
+			expectedErrString: "ipversion can't be set on fields with ebpf.int128 annotation",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{IPVersion: test.version},
+			}
+			if test.annotation != nil {
+				field.Annotations = map[string]interface{}{"ebpf.int128": test.annotation}
+			}
+			err := validateFieldIPVersion(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructMacAddresses(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "src_mac", Type: &btf.Array{Type: u8Type, Nelems: 6}},
+			{Name: "comm", Type: &btf.Array{Type: u8Type, Nelems: 16}, Offset: 48},
+			{Name: "macro", Type: &btf.Array{Type: u8Type, Nelems: 8}, Offset: 176},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	report := newPopulateReport()
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, report))
+
+	fields := m.Structs["event"].Fields
+	require.Len(t, fields, 3)
+	require.Equal(t, "macaddr", fields[0].Attributes.Template)
+	require.Equal(t, uint(17), fields[0].Attributes.Width)
+	require.Zero(t, fields[0].Attributes.MaxWidth)
+	require.Empty(t, fields[1].Attributes.Template)
+	require.Empty(t, fields[2].Attributes.Template)
+
+	// The guess is reported so the author can remove it if wrong
+	require.Len(t, report.Warnings, 1)
+	require.Contains(t, report.Warnings[0], "src_mac")
+
+	for _, field := range fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldMacAddr(field, member))
+		require.NoError(t, validateFieldMaxWidth(field, member))
+	}
+}
+
+func TestValidateFieldMacAddr(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"mac": {
+			member:   btf.Member{Name: "mac", Type: &btf.Array{Type: u8Type, Nelems: 6}},
+			template: "macaddr",
+		},
+		"no_template": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"wrong_size": {
+			member:            btf.Member{Name: "mac", Type: &btf.Array{Type: u8Type, Nelems: 8}},
+			template:          "macaddr",
+			expectedErrString: "template \"macaddr\" can only be used by arrays of 6 bytes",
+		},
+		"integer": {
+			member:            btf.Member{Name: "mac", Type: u32Type},
+			template:          "macaddr",
+			expectedErrString: "template \"macaddr\" can only be used by arrays of 6 bytes",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			err := validateFieldMacAddr(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructIdTemplates(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 16,
+		Members: []btf.Member{
+			{Name: "uid", Type: u32Type},
+			{Name: "gid", Type: u32Type, Offset: 32},
+			{Name: "pid", Type: u32Type, Offset: 64},
+			// Not unsigned: no template
+			{Name: "Uid", Type: s32Type, Offset: 96},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	templates := make(map[string]string)
+	for _, field := range m.Structs["event"].Fields {
+		templates[field.Name] = field.Attributes.Template
+
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldIdTemplate(field, member))
+	}
+	require.Equal(t, map[string]string{"uid": "uid", "gid": "gid", "pid": "", "Uid": ""}, templates)
+}
+
+func TestValidateFieldIdTemplate(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"uid": {
+			member:   btf.Member{Name: "uid", Type: u32Type},
+			template: "uid",
+		},
+		"gid_other_name": {
+			member:   btf.Member{Name: "owner", Type: u32Type},
+			template: "gid",
+		},
+		"signed": {
+			member:            btf.Member{Name: "uid", Type: s32Type},
+			template:          "uid",
+			expectedErrString: "template \"uid\" can only be used by u32 fields",
+		},
+		"u8": {
+			member:            btf.Member{Name: "gid", Type: u8Type},
+			template:          "gid",
+			expectedErrString: "template \"gid\" can only be used by u32 fields",
+		},
+		"bitfield": {
+			member:            btf.Member{Name: "uid", Type: u32Type, BitfieldSize: 16},
+			template:          "uid",
+			expectedErrString: "template \"uid\" can only be used by u32 fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			err := validateFieldIdTemplate(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructErrno(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 12,
+		Members: []btf.Member{
+			{Name: "err", Type: s32Type},
+			// Left to the author
+			{Name: "ret", Type: s32Type, Offset: 32},
+			// Not signed: no template
+			{Name: "errno", Type: u32Type, Offset: 64},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	fields := m.Structs["event"].Fields
+	require.Len(t, fields, 3)
+	require.Equal(t, "errno", fields[0].Attributes.Template)
+	require.Equal(t, uint(15), fields[0].Attributes.Width)
+	require.Empty(t, fields[1].Attributes.Template)
+	require.Empty(t, fields[2].Attributes.Template)
+
+	for _, field := range fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldErrno(field, member))
+	}
+}
+
+func TestValidateFieldErrno(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"signed": {
+			member:   btf.Member{Name: "ret", Type: s32Type},
+			template: "errno",
+		},
+		"no_template": {
+			member: btf.Member{Name: "ret", Type: u32Type},
+		},
+		"unsigned": {
+			member:            btf.Member{Name: "ret", Type: u32Type},
+			template:          "errno",
+			expectedErrString: "template \"errno\" can only be used by signed integer fields",
+		},
+		"char_array": {
+			member:            btf.Member{Name: "ret", Type: &btf.Array{Type: u8Type, Nelems: 4}},
+			template:          "errno",
+			expectedErrString: "template \"errno\" can only be used by signed integer fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			err := validateFieldErrno(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructSyscall(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 12,
+		Members: []btf.Member{
+			{Name: "syscall", Type: u32Type},
+			{Name: "nr_syscall", Type: s32Type, Offset: 32},
+			{Name: "syscalls", Type: u32Type, Offset: 64},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	fields := m.Structs["event"].Fields
+	require.Len(t, fields, 3)
+	require.Equal(t, "syscall", fields[0].Attributes.Template)
+	require.Equal(t, uint(18), fields[0].Attributes.Width)
+	require.Equal(t, "syscall", fields[1].Attributes.Template)
+	require.Empty(t, fields[2].Attributes.Template)
+
+	for _, field := range fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldSyscall(field, member))
+	}
+}
+
+func TestPopulateStructDuration(t *testing.T) {
+	t.Parallel()
+
+	u64Type := &btf.Int{Name: "u64", Size: 8}
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 32,
+		Members: []btf.Member{
+			{Name: "latency_ns", Type: u64Type},
+			{Name: "duration", Type: u64Type, Offset: 64},
+			{Name: "delta_ns", Type: u32Type, Offset: 128},
+			{Name: "delta", Type: u32Type, Offset: 160},
+			{Name: "latency_comm", Type: &btf.Array{Type: u8Type, Nelems: 8}, Offset: 192},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	report := newPopulateReport()
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, report))
+
+	fields := m.Structs["event"].Fields
+	require.Len(t, fields, 5)
+	for _, field := range fields[:3] {
+		require.Equal(t, "duration", field.Attributes.Template, field.Name)
+		require.Equal(t, uint(len("999.99ms")), field.Attributes.Width, field.Name)
+	}
+	require.Empty(t, fields[3].Attributes.Template)
+	require.Empty(t, fields[4].Attributes.Template)
+
+	// The guesses are reported so the author can remove them if wrong
+	require.Len(t, report.Warnings, 3)
+	require.Contains(t, report.Warnings[0], "latency_ns")
+
+	for _, field := range fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldDuration(field, member))
+	}
+}
+
+func TestValidateFieldDuration(t *testing.T) {
+	t.Parallel()
+
+	u64Type := &btf.Int{Name: "u64", Size: 8}
+
+	precision := uint(3)
+
+	type testCase struct {
+		member            btf.Member
+		unit              metadatav1.Unit
+		precision         *uint
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"nanoseconds": {
+			member: btf.Member{Name: "latency", Type: u64Type},
+		},
+		"microseconds_with_precision": {
+			member:    btf.Member{Name: "latency", Type: u32Type},
+			unit:      metadatav1.UnitMicroseconds,
+			precision: &precision,
+		},
+		"bytes": {
+			member:            btf.Member{Name: "latency", Type: u64Type},
+			unit:              metadatav1.UnitBytes,
+			expectedErrString: "template \"duration\" needs a unit of \"ns\", \"us\" or \"ms\", got \"bytes\"",
+		},
+		"char_array": {
+			member:            btf.Member{Name: "latency", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			expectedErrString: "template \"duration\" can only be used by integer fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name: test.member.Name,
+				Attributes: metadatav1.FieldAttributes{
+					Template:  "duration",
+					Unit:      test.unit,
+					Precision: test.precision,
+				},
+			}
+			// Durations can set a precision, unlike other integers
+			require.NoError(t, validateFieldPrecision(field, test.member))
+			err := validateFieldDuration(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldLabels(t *testing.T) {
+	t.Parallel()
+
+	boolType := &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}
+	u16Type := &btf.Int{Name: "u16", Size: 2}
+	labels := &metadatav1.BoolLabels{True: "yes", False: "no"}
+
+	type testCase struct {
+		member            btf.Member
+		boolLabels        *metadatav1.BoolLabels
+		zeroAs            string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"bool_labels_on_bool": {
+			member:     btf.Member{Name: "success", Type: boolType},
+			boolLabels: labels,
+		},
+		"bool_labels_on_u8": {
+			member:     btf.Member{Name: "success", Type: u8Type},
+			boolLabels: labels,
+		},
+		"bool_labels_on_wider_integer": {
+			member:            btf.Member{Name: "success", Type: u32Type},
+			boolLabels:        labels,
+			expectedErrString: "boolLabels can only be used by booleans and 1-byte integers",
+		},
+		"zero_as_on_port": {
+			member: btf.Member{Name: "port", Type: u16Type},
+			zeroAs: "-",
+		},
+		"zero_as_on_float": {
+			member: btf.Member{Name: "ratio", Type: &btf.Float{Name: "double", Size: 8}},
+			zeroAs: "-",
+		},
+		"zero_as_on_char_array": {
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			zeroAs:            "-",
+			expectedErrString: "zeroAs can only be used by scalar fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name: test.member.Name,
+				Attributes: metadatav1.FieldAttributes{
+					BoolLabels: test.boolLabels,
+					ZeroAs:     test.zeroAs,
+				},
+			}
+			err := errors.Join(validateFieldBoolLabels(field, test.member), validateFieldZeroAs(field, test.member))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldEncoding(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		encoding          metadatav1.Encoding
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_encoding": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"hex_on_u8_array": {
+			member:   btf.Member{Name: "payload", Type: &btf.Array{Type: u8Type, Nelems: 64}},
+			encoding: metadatav1.EncodingHex,
+		},
+		"base64_on_char_array": {
+			member:   btf.Member{Name: "payload", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Nelems: 64}},
+			encoding: metadatav1.EncodingBase64,
+		},
+		"utf8_on_integer": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			encoding:          metadatav1.EncodingUTF8,
+			expectedErrString: "encoding can only be used by arrays of bytes",
+		},
+		"hex_on_u32_array": {
+			member:            btf.Member{Name: "addrs", Type: &btf.Array{Type: u32Type, Nelems: 4}},
+			encoding:          metadatav1.EncodingHex,
+			expectedErrString: "encoding can only be used by arrays of bytes",
+		},
+		"unknown": {
+			member:            btf.Member{Name: "payload", Type: &btf.Array{Type: u8Type, Nelems: 64}},
+			encoding:          "ascii",
+			expectedErrString: "invalid encoding \"ascii\", expected \"utf8\", \"hex\" or \"base64\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Encoding: test.encoding},
+			}
+			err := validateFieldEncoding(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldRedact(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		redact            string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_redact": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"full_on_char_array": {
+			member: btf.Member{Name: "password", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Nelems: 64}},
+			redact: "full",
+		},
+		"keep_prefix_on_char_array": {
+			member: btf.Member{Name: "token", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Nelems: 64}},
+			redact: "keepPrefix:4",
+		},
+		"hash_on_integer": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			redact:            "hash",
+			expectedErrString: "redact can only be used by string fields",
+		},
+		"unknown_mode": {
+			member:            btf.Member{Name: "password", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Nelems: 64}},
+			redact:            "mask",
+			expectedErrString: "invalid redaction mode \"mask\"",
+		},
+		"keep_prefix_without_count": {
+			member:            btf.Member{Name: "token", Type: &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Nelems: 64}},
+			redact:            "keepPrefix",
+			expectedErrString: "needs the number of characters to keep",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Redact: test.redact},
+			}
+			err := validateFieldRedact(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldAutoWidth(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		attributes        metadatav1.FieldAttributes
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_auto_width": {
+			attributes: metadatav1.FieldAttributes{Width: 16},
+		},
+		"auto_width": {
+			attributes: metadatav1.FieldAttributes{AutoWidth: true, MinWidth: 4, MaxWidth: 32},
+		},
+		"missing_max_width": {
+			attributes:        metadatav1.FieldAttributes{AutoWidth: true},
+			expectedErrString: "autoWidth requires maxWidth",
+		},
+		"with_width": {
+			attributes:        metadatav1.FieldAttributes{AutoWidth: true, Width: 16, MaxWidth: 32},
+			expectedErrString: "width must be 0 when autoWidth is set, got 16",
+		},
+		"min_bigger_than_max": {
+			attributes:        metadatav1.FieldAttributes{AutoWidth: true, MinWidth: 40, MaxWidth: 32},
+			expectedErrString: "minWidth 40 is bigger than maxWidth 32",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{Name: "container", Attributes: test.attributes}
+			err := validateFieldAutoWidth(field)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldExamples(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		field             metadatav1.Field
+		member            btf.Member
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_examples": {
+			field:  metadatav1.Field{Name: "pid"},
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"unsigned": {
+			field:  metadatav1.Field{Name: "pid", Examples: []string{"1", "4294967295"}},
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"unsigned_out_of_range": {
+			field:             metadatav1.Field{Name: "pid", Examples: []string{"4294967296"}},
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			expectedErrString: "example \"4294967296\" isn't a valid __u32",
+		},
+		"signed": {
+			field:  metadatav1.Field{Name: "ret", Examples: []string{"-2"}},
+			member: btf.Member{Name: "ret", Type: s32Type},
+		},
+		"not_a_number": {
+			field:             metadatav1.Field{Name: "ret", Examples: []string{"ENOENT"}},
+			member:            btf.Member{Name: "ret", Type: s32Type},
+			expectedErrString: "example \"ENOENT\" isn't a valid __s32",
+		},
+		"template": {
+			field: metadatav1.Field{
+				Name:       "ret",
+				Examples:   []string{"ENOENT"},
+				Attributes: metadatav1.FieldAttributes{Template: "errno"},
+			},
+			member: btf.Member{Name: "ret", Type: s32Type},
+		},
+		"zero_as": {
+			field: metadatav1.Field{
+				Name:       "pid",
+				Examples:   []string{"-"},
+				Attributes: metadatav1.FieldAttributes{ZeroAs: "-"},
+			},
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"values": {
+			field: metadatav1.Field{
+				Name:         "proto",
+				Examples:     []string{"TCP", "other"},
+				Values:       map[int64]string{6: "TCP", 17: "UDP"},
+				DefaultLabel: "other",
+			},
+			member: btf.Member{Name: "proto", Type: u8Type},
+		},
+		"unknown_value": {
+			field: metadatav1.Field{
+				Name:     "proto",
+				Examples: []string{"ICMP"},
+				Values:   map[int64]string{6: "TCP", 17: "UDP"},
+			},
+			member:            btf.Member{Name: "proto", Type: u8Type},
+			expectedErrString: "example \"ICMP\" isn't one of the values of the field",
+		},
+		"string": {
+			field:  metadatav1.Field{Name: "comm", Examples: []string{"cat"}},
+			member: btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateFieldExamples(test.field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldSyscall(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"unsigned": {
+			member:   btf.Member{Name: "nr", Type: u32Type},
+			template: "syscall",
+		},
+		"signed": {
+			member:   btf.Member{Name: "nr", Type: s32Type},
+			template: "syscall",
+		},
+		"char_array": {
+			member:            btf.Member{Name: "nr", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			template:          "syscall",
+			expectedErrString: "template \"syscall\" can only be used by integer fields",
+		},
+		"bool": {
+			member:            btf.Member{Name: "nr", Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}},
+			template:          "syscall",
+			expectedErrString: "template \"syscall\" can only be used by integer fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			err := validateFieldSyscall(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldStackMap(t *testing.T) {
+	t.Parallel()
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"stacks": {Name: "stacks", Type: ebpf.StackTrace},
+			"events": {Name: "events", Type: ebpf.RingBuf},
+		},
+	}
+
+	type testCase struct {
+		member            btf.Member
+		stackMap          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_stack_map": {
+			member: btf.Member{Name: "comm", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+		},
+		"valid": {
+			member:   btf.Member{Name: "kern_stack_id", Type: s32Type},
+			stackMap: "stacks",
+		},
+		"not_integer": {
+			member:            btf.Member{Name: "kern_stack_id", Type: &btf.Array{Type: u8Type, Nelems: 16}},
+			stackMap:          "stacks",
+			expectedErrString: "stackMap can only be used by integer fields",
+		},
+		"unknown_map": {
+			member:            btf.Member{Name: "kern_stack_id", Type: u32Type},
+			stackMap:          "foo",
+			expectedErrString: "stack map \"foo\" not found in eBPF object",
+		},
+		"wrong_map_type": {
+			member:            btf.Member{Name: "kern_stack_id", Type: u32Type},
+			stackMap:          "events",
+			expectedErrString: "map \"events\" has type RingBuf, expected StackTrace",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{StackMap: test.stackMap},
+			}
+			err := validateFieldStackMap(field, test.member, spec)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldSignalCapabilities(t *testing.T) {
+	t.Parallel()
+
+	u64 := &btf.Int{Name: "u64", Size: 8}
+	s64 := &btf.Int{Name: "s64", Size: 8, Encoding: btf.Signed}
+
+	type testCase struct {
+		member            btf.Member
+		template          string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"signal_s32": {
+			member:   btf.Member{Name: "sig", Type: s32Type},
+			template: "signal",
+		},
+		"signal_u8": {
+			member:   btf.Member{Name: "sig", Type: u8Type},
+			template: "signal",
+		},
+		"signal_u64": {
+			member:            btf.Member{Name: "sig", Type: u64},
+			template:          "signal",
+			expectedErrString: "template \"signal\" can only be used by integer fields of up to 32 bits",
+		},
+		"capabilities_u64": {
+			member:   btf.Member{Name: "caps", Type: u64},
+			template: "capabilities",
+		},
+		"capabilities_u32": {
+			member:            btf.Member{Name: "caps", Type: u32Type},
+			template:          "capabilities",
+			expectedErrString: "template \"capabilities\" can only be used by u64 fields",
+		},
+		"capabilities_s64": {
+			member:            btf.Member{Name: "caps", Type: s64},
+			template:          "capabilities",
+			expectedErrString: "template \"capabilities\" can only be used by u64 fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Template: test.template},
+			}
+			err := validateFieldSignal(field, test.member)
+			if err == nil {
+				err = validateFieldCapabilities(field, test.member)
+			}
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStructPointers(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 20,
+		Members: []btf.Member{
+			{Name: "addr", Type: &btf.Pointer{Target: &btf.Void{}}},
+			{Name: "ip", Type: &btf.Typedef{Name: "handle_t", Type: &btf.Pointer{Target: u32Type}}, Offset: 64},
+			{Name: "pid", Type: u32Type, Offset: 128},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	type pointerInfo struct {
+		width     uint
+		alignment metadatav1.Alignment
+		base      metadatav1.Base
+	}
+
+	got := make(map[string]pointerInfo)
+	for _, field := range m.Structs["event"].Fields {
+		got[field.Name] = pointerInfo{
+			width:     field.Attributes.Width,
+			alignment: field.Attributes.Alignment,
+			base:      field.Attributes.Base,
+		}
+	}
+	require.Equal(t, map[string]pointerInfo{
+		"addr": {width: 18, alignment: metadatav1.AlignmentLeft, base: metadatav1.BaseHex},
+		"ip":   {width: 18, alignment: metadatav1.AlignmentLeft, base: metadatav1.BaseHex},
+		"pid":  {width: columns.MaxCharsUint32, alignment: metadatav1.AlignmentRight},
+	}, got)
+
+	// The populated fields are valid
+	for _, field := range m.Structs["event"].Fields {
+		member, ok := findMember(event.Members, field.Name)
+		require.True(t, ok)
+		require.NoError(t, validateFieldPointer(field, member))
+	}
+}
+
+func TestValidateFieldPointer(t *testing.T) {
+	t.Parallel()
+
+	voidPtr := &btf.Pointer{Target: &btf.Void{}}
+
+	type testCase struct {
+		member            btf.Member
+		base              metadatav1.Base
+		annotation        interface{}
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"pointer": {
+			member: btf.Member{Name: "addr", Type: voidPtr},
+			base:   metadatav1.BaseHex,
+		},
+		"kernel_pointer": {
+			member:     btf.Member{Name: "addr", Type: voidPtr},
+			base:       metadatav1.BaseHex,
+			annotation: "kernel",
+		},
+		"user_pointer": {
+			member:     btf.Member{Name: "addr", Type: &btf.Typedef{Name: "handle_t", Type: voidPtr}},
+			base:       metadatav1.BaseHex,
+			annotation: "user",
+		},
+		"integer": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"pointer_decimal": {
+			member:            btf.Member{Name: "addr", Type: voidPtr},
+			expectedErrString: "pointers can only be shown in hex",
+		},
+		"integer_hex": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			base:              metadatav1.BaseHex,
+			expectedErrString: "base hex can only be set on pointer fields",
+		},
+		"invalid_base": {
+			member:            btf.Member{Name: "addr", Type: voidPtr},
+			base:              "octal",
+			expectedErrString: "invalid base \"octal\"",
+		},
+		"annotation_not_pointer": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			annotation:        "kernel",
+			expectedErrString: "can only be set on pointer fields",
+		},
+		"invalid_annotation": {
+			member:            btf.Member{Name: "addr", Type: voidPtr},
+			base:              metadatav1.BaseHex,
+			annotation:        "bpf",
+			expectedErrString: "annotation is \"bpf\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Base: test.base},
+			}
+			if test.annotation != nil {
+				field.Annotations = map[string]interface{}{"ebpf.pointer": test.annotation}
+			}
+			err := validateFieldPointer(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateTrailer(t *testing.T) {
+	t.Parallel()
+
+	event := &btf.Struct{
+		Name: "event",
+		Size: 12,
+		Members: []btf.Member{
+			{Name: "pid", Type: u32Type},
+			{Name: "delta", Type: s32Type, Offset: 32},
+			{Name: "args_len", Type: &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}, Offset: 64},
+			{Name: "flag", Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}, Offset: 80},
+		},
+	}
+
+	type testCase struct {
+		trailer           metadatav1.Trailer
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"string": {
+			trailer: metadatav1.Trailer{Name: "args", Type: metadatav1.TrailerTypeString, LengthField: "args_len"},
+		},
+		"bytes": {
+			trailer: metadatav1.Trailer{Name: "args", Type: metadatav1.TrailerTypeBytes, LengthField: "pid"},
+		},
+		"no_name": {
+			trailer:           metadatav1.Trailer{Type: metadatav1.TrailerTypeBytes, LengthField: "args_len"},
+			expectedErrString: "name is required",
+		},
+		"name_used": {
+			trailer:           metadatav1.Trailer{Name: "pid", Type: metadatav1.TrailerTypeBytes, LengthField: "args_len"},
+			expectedErrString: "name \"pid\" is already used by a member",
+		},
+		"invalid_type": {
+			trailer:           metadatav1.Trailer{Name: "args", Type: "int", LengthField: "args_len"},
+			expectedErrString: "invalid type \"int\"",
+		},
+		"length_not_found": {
+			trailer:           metadatav1.Trailer{Name: "args", Type: metadatav1.TrailerTypeBytes, LengthField: "len"},
+			expectedErrString: "length field \"len\" not found",
+		},
+		"length_signed": {
+			trailer:           metadatav1.Trailer{Name: "args", Type: metadatav1.TrailerTypeBytes, LengthField: "delta"},
+			expectedErrString: "length field \"delta\" must be an unsigned integer",
+		},
+		"length_bool": {
+			trailer:           metadatav1.Trailer{Name: "args", Type: metadatav1.TrailerTypeBytes, LengthField: "flag"},
+			expectedErrString: "length field \"flag\" must be an unsigned integer",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateTrailer(test.trailer, event)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldPrecision(t *testing.T) {
+	t.Parallel()
+
+	precision := uint(3)
+
+	type testCase struct {
+		member            btf.Member
+		precision         *uint
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"float": {
+			member:    btf.Member{Name: "ratio", Type: &btf.Float{Name: "float", Size: 4}},
+			precision: &precision,
+		},
+		"typedef_double": {
+			member:    btf.Member{Name: "avg", Type: &btf.Typedef{Name: "avg_t", Type: &btf.Float{Name: "double", Size: 8}}},
+			precision: &precision,
+		},
+		"not_set": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"integer": {
+			member:            btf.Member{Name: "pid", Type: u32Type},
+			precision:         &precision,
+			expectedErrString: "precision can only be set on float fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{Precision: test.precision},
+			}
+			err := validateFieldPrecision(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldUnit(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		unit              metadatav1.Unit
+		humanize          bool
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"not_set": {
+			member: btf.Member{Name: "pid", Type: u32Type},
+		},
+		"bytes": {
+			member:   btf.Member{Name: "size", Type: &btf.Int{Name: "u64", Size: 8}},
+			unit:     metadatav1.UnitBytes,
+			humanize: true,
+		},
+		"ns_typedef": {
+			member:   btf.Member{Name: "latency", Type: &btf.Typedef{Name: "__u64", Type: &btf.Int{Name: "u64", Size: 8}}},
+			unit:     metadatav1.UnitNanoseconds,
+			humanize: true,
+		},
+		"ms_float": {
+			member: btf.Member{Name: "avg", Type: &btf.Float{Name: "double", Size: 8}},
+			unit:   metadatav1.UnitMilliseconds,
+		},
+		"count_signed": {
+			member: btf.Member{Name: "delta", Type: s32Type},
+			unit:   metadatav1.UnitCount,
+		},
+		"humanize_without_unit": {
+			member:            btf.Member{Name: "size", Type: u32Type},
+			humanize:          true,
+			expectedErrString: "humanize requires a unit",
+		},
+		"invalid_unit": {
+			member:            btf.Member{Name: "size", Type: u32Type},
+			unit:              "KiB",
+			expectedErrString: "invalid unit \"KiB\"",
+		},
+		"char_array": {
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			unit:              metadatav1.UnitBytes,
+			expectedErrString: "unit can only be set on numeric fields",
+		},
+		"bool": {
+			member:            btf.Member{Name: "ok", Type: &btf.Int{Name: "bool", Size: 1, Encoding: btf.Bool}},
+			unit:              metadatav1.UnitCount,
+			expectedErrString: "unit can only be set on numeric fields",
+		},
+		"flag_bitfield": {
+			member:            btf.Member{Name: "flag", Type: u32Type, BitfieldSize: 1},
+			unit:              metadatav1.UnitCount,
+			expectedErrString: "unit can only be set on numeric fields",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name: test.member.Name,
+				Attributes: metadatav1.FieldAttributes{
+					Unit:     test.unit,
+					Humanize: test.humanize,
+				},
+			}
+			err := validateFieldUnit(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFieldMaxWidth(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		maxWidth          uint
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"not_set": {
+			member: btf.Member{Name: "filename", Type: &btf.Array{Type: charType, Nelems: 4096}},
+		},
+		"buffer_size": {
+			member:   btf.Member{Name: "filename", Type: &btf.Array{Type: charType, Nelems: 4096}},
+			maxWidth: 4096,
+		},
+		"too_big": {
+			member:            btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			maxWidth:          32,
+			expectedErrString: "maxWidth is 32 but the buffer only holds 16 characters",
+		},
+		"not_char_array": {
+			member:   btf.Member{Name: "pid", Type: u32Type},
+			maxWidth: 32,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name:       test.member.Name,
+				Attributes: metadatav1.FieldAttributes{MaxWidth: test.maxWidth},
+			}
+			err := validateFieldMaxWidth(field, test.member)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestIsPathLike(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]bool{
+		"filename":      true,
+		"path":          true,
+		"exe_path":      true,
+		"file.name":     true,
+		"Hostname":      true,
+		"comm":          false,
+		"args":          false,
+		"filename.args": false,
+	} {
+		require.Equal(t, expected, isPathLike(name), name)
+	}
+}
+
+func TestPopulateStructAlignment(t *testing.T) {
+	t.Parallel()
+
+	_, l4 := endpointTypes()
+	event := &btf.Struct{
+		Name: "event",
+		Members: []btf.Member{
+			{Name: "pid", Type: &btf.Typedef{Name: "pid_t", Type: &btf.Int{Name: "int", Size: 4, Encoding: btf.Signed}}},
+			{Name: "latency", Type: &btf.Int{Name: "__u64", Size: 8}},
+			{Name: "type", Type: eventTypeEnum},
+			{Name: "ratio", Type: &btf.Float{Name: "double", Size: 8}},
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			{Name: "dst", Type: l4},
+			{Name: "bytes", Type: u32Type},
+			{Name: "c", Type: charType},
+		},
+	}
+
+	type testCase struct {
+		opts     populateOptions
+		initial  []metadatav1.Field
+		expected map[string]metadatav1.Alignment
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			expected: map[string]metadatav1.Alignment{
+				"pid":     metadatav1.AlignmentRight,
+				"latency": metadatav1.AlignmentRight,
+				"type":    metadatav1.AlignmentRight,
+				"ratio":   metadatav1.AlignmentRight,
+				"comm":    metadatav1.AlignmentLeft,
+				"dst":     metadatav1.AlignmentLeft,
+				"bytes":   metadatav1.AlignmentRight,
+				"c":       metadatav1.AlignmentLeft,
+			},
+		},
+		"left_alignment": {
+			opts: populateOptions{leftAlignment: true},
+			expected: map[string]metadatav1.Alignment{
+				"pid":     metadatav1.AlignmentLeft,
+				"latency": metadatav1.AlignmentLeft,
+				"type":    metadatav1.AlignmentLeft,
+				"ratio":   metadatav1.AlignmentLeft,
+				"comm":    metadatav1.AlignmentLeft,
+				"dst":     metadatav1.AlignmentLeft,
+				"bytes":   metadatav1.AlignmentLeft,
+				"c":       metadatav1.AlignmentLeft,
+			},
+		},
+		"existing_fields": {
+			initial: []metadatav1.Field{
+				{Name: "pid", Attributes: metadatav1.FieldAttributes{Alignment: metadatav1.AlignmentLeft}},
+				{Name: "comm", Attributes: metadatav1.FieldAttributes{Alignment: metadatav1.AlignmentRight}},
+				{Name: "bytes"},
+			},
+			expected: map[string]metadatav1.Alignment{
+				"pid":     metadatav1.AlignmentLeft,
+				"latency": metadatav1.AlignmentRight,
+				"type":    metadatav1.AlignmentRight,
+				"ratio":   metadatav1.AlignmentRight,
+				"comm":    metadatav1.AlignmentRight,
+				"dst":     metadatav1.AlignmentLeft,
+				"bytes":   metadatav1.AlignmenNone,
+				"c":       metadatav1.AlignmentLeft,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: test.initial},
+				},
+			}
+			require.NoError(t, populateStruct(m, event, nil, test.opts, nil))
+
+			alignments := make(map[string]metadatav1.Alignment)
+			for _, field := range m.Structs["event"].Fields {
+				alignments[field.Name] = field.Attributes.Alignment
+			}
+			require.Equal(t, test.expected, alignments)
+		})
+	}
+}
+
+func TestPopulateGadgetInfo(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		initialMetadata string
+		expectedName    string
+		expectedDesc    string
+	}
+
+	tests := map[string]testCase{
+		"from_scratch": {
+			expectedName: "trace ports",
+			expectedDesc: "Trace the ports used by processes",
+		},
+		"placeholders": {
+			initialMetadata: `
+name: 'TODO: Fill the gadget name'
+description: 'TODO: Fill the gadget description'
+`,
+			expectedName: "trace ports",
+			expectedDesc: "Trace the ports used by processes",
+		},
+		"description_overridden": {
+			initialMetadata: `
+description: Show the ports used by processes
+`,
+			expectedName: "trace ports",
+			expectedDesc: "Show the ports used by processes",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, yaml.Unmarshal([]byte(test.initialMetadata), m))
+
+			require.NoError(t, Populate(m, markersSpec(t)))
+			require.Equal(t, test.expectedName, m.Name)
+			require.Equal(t, test.expectedDesc, m.Description)
+			require.Equal(t, "TODO: Fill the gadget source code URL", m.SourceURL)
+		})
+	}
+}
+
+func TestCheckParamVar(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	vars := map[string]btf.Type{
+		"const_volatile": &btf.Const{Type: &btf.Volatile{Type: u32}},
+		"volatile_const": &btf.Volatile{Type: &btf.Const{Type: u32}},
+		"typedef_chain": &btf.Typedef{Name: "outer_t", Type: &btf.Const{Type: &btf.Typedef{
+			Name: "inner_t",
+			Type: &btf.Volatile{Type: u32},
+		}}},
+		"not_const":    &btf.Volatile{Type: u32},
+		"not_volatile": &btf.Typedef{Name: "const_u32", Type: &btf.Const{Type: u32}},
+		"char_array": &btf.Array{
+			Type:   &btf.Const{Type: &btf.Volatile{Type: charType}},
+			Index:  u32,
+			Nelems: 16,
+		},
+		"int_array": &btf.Array{
+			Type:   &btf.Const{Type: &btf.Volatile{Type: u32}},
+			Index:  u32,
+			Nelems: 16,
+		},
+		"one_char_array": &btf.Array{
+			Type:   &btf.Const{Type: &btf.Volatile{Type: charType}},
+			Index:  u32,
+			Nelems: 1,
+		},
+		"empty_int_array": &btf.Array{
+			Type:   &btf.Const{Type: &btf.Volatile{Type: u32}},
+			Index:  u32,
+			Nelems: 0,
+		},
+		"bool_array": &btf.Array{
+			Type:   &btf.Const{Type: &btf.Volatile{Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}}},
+			Index:  u32,
+			Nelems: 4,
+		},
+	}
+
+	var types []btf.Type
+	for name, typ := range vars {
+		types = append(types, &btf.Var{Name: name, Type: typ, Linkage: btf.GlobalVar})
+	}
+	idx := newBTFIndex(newTestSpec(t, types...))
+
+	type testCase struct {
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"const_volatile": {},
+		"volatile_const": {},
+		"typedef_chain":  {},
+		"not_const": {
+			expectedErrString: "\"not_const\" is not const",
+		},
+		"not_volatile": {
+			expectedErrString: "\"not_volatile\" is not volatile",
+		},
+		"char_array": {},
+		"int_array":  {},
+		"empty_int_array": {
+			expectedErrString: "\"empty_int_array\" has room for no value",
+		},
+		"bool_array": {
+			expectedErrString: "\"bool_array\" is an array but not of chars or integers",
+		},
+		"one_char_array": {
+			expectedErrString: "\"one_char_array\" has room for no char besides the terminating NUL",
+		},
+		"missing": {
+			expectedErrString: "variable \"missing\" not found",
+		},
+	}
+
+	for name, test := range tests {
+		name := name
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkParamVar(idx, name)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	t.Parallel()
+
+	spec := markersSpec(t)
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, Populate(m, spec))
+
+	// Placeholders are only checked in strict mode
+	require.NoError(t, Validate(m, spec))
+
+	err := Validate(m, spec, WithStrict())
+	require.ErrorContains(t, err, "homepageURL is still")
+	require.ErrorContains(t, err, "documentationURL is still")
+	require.ErrorContains(t, err, "sourceURL is still")
+	// Set from the eBPF code
+	require.NotContains(t, err.Error(), "name is still")
+	require.NotContains(t, err.Error(), "description is still")
+
+	m.HomepageURL = "https://inspektor-gadget.io/"
+	m.DocumentationURL = "https://inspektor-gadget.io/docs"
+	m.SourceURL = "https://github.com/inspektor-gadget/inspektor-gadget/"
+	require.NoError(t, Validate(m, spec, WithStrict()))
+}
+
+func TestValidateDefaultColumns(t *testing.T) {
+	type testCase struct {
+		metadata          *metadatav1.GadgetMetadata
+		expectedErrString string
+	}
+
+	structs := map[string]metadatav1.Struct{
+		"event": {Fields: []metadatav1.Field{
+			{Name: "pid"},
+			{Name: "comm"},
+			{Name: "filename"},
+		}},
+	}
+
+	tests := map[string]testCase{
+		"no_default_columns": {
+			metadata: &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"events": {MapName: "events", StructName: "event"},
+				},
+				Structs: structs,
+			},
+		},
+		"tracer_subset": {
+			metadata: &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"events": {MapName: "events", StructName: "event", DefaultColumns: []string{"k8s.namespace", "pid", "comm"}},
+				},
+				Structs: structs,
+			},
+		},
+		"snapshotter_subset": {
+			metadata: &metadatav1.GadgetMetadata{
+				Snapshotters: map[string]metadatav1.Snapshotter{
+					"processes": {StructName: "event", DefaultColumns: []string{"runtime", "comm"}},
+				},
+				Structs: structs,
+			},
+		},
+		"tracer_unknown_column": {
+			metadata: &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"events": {MapName: "events", StructName: "event", DefaultColumns: []string{"pid", "tid"}},
+				},
+				Structs: structs,
+			},
+			expectedErrString: `tracer "events": default column "tid" not found in struct "event"`,
+		},
+		"snapshotter_unknown_column": {
+			metadata: &metadatav1.GadgetMetadata{
+				Snapshotters: map[string]metadatav1.Snapshotter{
+					"processes": {StructName: "event", DefaultColumns: []string{"k8s8"}},
+				},
+				Structs: structs,
+			},
+			expectedErrString: `snapshotter "processes": default column "k8s8" not found in struct "event"`,
+		},
+		"duplicated_column": {
+			metadata: &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"events": {MapName: "events", StructName: "event", DefaultColumns: []string{"pid", "comm", "pid"}},
+				},
+				Structs: structs,
+			},
+			expectedErrString: `tracer "events": default column "pid" listed twice`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateDefaultColumns(test.metadata)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateGroups(t *testing.T) {
+	type testCase struct {
+		metadata          *metadatav1.GadgetMetadata
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_groups": {
+			metadata: &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: []metadatav1.Field{{Name: "pid"}}},
+				},
+			},
+		},
+		"defined_groups": {
+			metadata: &metadatav1.GadgetMetadata{
+				Groups: map[string]metadatav1.Group{
+					"src": {Description: "Source endpoint"},
+					"dst": {Description: "Destination endpoint", Hidden: true},
+				},
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: []metadatav1.Field{
+						{Name: "pid"},
+						{Name: "saddr", Group: "src"},
+						{Name: "sport", Group: "src"},
+						{Name: "daddr", Group: "dst"},
+					}},
+				},
+			},
+		},
+		"undefined_group": {
+			metadata: &metadatav1.GadgetMetadata{
+				Groups: map[string]metadatav1.Group{
+					"src": {},
+				},
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: []metadatav1.Field{
+						{Name: "saddr", Group: "src"},
+						{Name: "daddr", Group: "dst"},
+					}},
+				},
+			},
+			expectedErrString: `field "daddr" of struct "event" references undefined group "dst"`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := validateGroups(test.metadata)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateFieldComparable(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		member            btf.Member
+		filterable        *bool
+		sortable          *bool
+		expectedErrString string
+	}
+
+	yes, no := true, false
+	_, l4 := endpointTypes()
+	_, _, in6 := int128Types()
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+
+	tests := map[string]testCase{
+		"u64_sortable": {
+			member:   btf.Member{Name: "bytes", Type: u64},
+			sortable: &yes,
+		},
+		"u64_filterable": {
+			member:     btf.Member{Name: "bytes", Type: u64},
+			filterable: &yes,
+		},
+		"string_sortable": {
+			member:   btf.Member{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			sortable: &yes,
+		},
+		"endpoint_not_sortable": {
+			member:     btf.Member{Name: "src", Type: l4},
+			filterable: &no,
+			sortable:   &no,
+		},
+		"endpoint_unset": {
+			member: btf.Member{Name: "src", Type: l4},
+		},
+		"endpoint_sortable": {
+			member:            btf.Member{Name: "src", Type: l4},
+			sortable:          &yes,
+			expectedErrString: "sortable can only be set on fields with comparable values",
+		},
+		"struct_filterable": {
+			member:            btf.Member{Name: "addr", Type: in6},
+			filterable:        &yes,
+			expectedErrString: "filterable can only be set on fields with comparable values",
+		},
+		"array_sortable": {
+			member:            btf.Member{Name: "args", Type: &btf.Array{Type: u32Type, Nelems: 4}},
+			sortable:          &yes,
+			expectedErrString: "sortable can only be set on fields with comparable values",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{
+				Name: test.member.Name,
+				Attributes: metadatav1.FieldAttributes{
+					Filterable: test.filterable,
+					Sortable:   test.sortable,
+				},
+			}
+			err := validateFieldComparable(field, test.member)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestFilterableFields(t *testing.T) {
+	t.Parallel()
+
+	l3, l4 := endpointTypes()
+	event := &btf.Struct{
+		Name: "event",
+		Size: 72,
+		Members: []btf.Member{
+			{Name: "addr", Type: l3},
+			{Name: "src", Type: l4, Offset: 160},
+			{Name: "bytes", Type: &btf.Int{Name: "__u64", Size: 8}, Offset: 384},
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}, Offset: 448},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, nil))
+
+	require.Equal(t, []string{"bytes", "comm"}, m.FilterableFields("event"))
+	require.Equal(t, []string{"bytes", "comm"}, m.SortableFields("event"))
+	require.Nil(t, m.SortableFields("unknown"))
+}
+
+func TestValidateFieldStyle(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		annotations       map[string]interface{}
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_style": {},
+		"color": {
+			annotations: map[string]interface{}{"columns.color": "red"},
+		},
+		"color_and_styles": {
+			annotations: map[string]interface{}{"columns.color": "yellow", "columns.style": "bold,underline"},
+		},
+		"conditional": {
+			annotations: map[string]interface{}{"columns.color": "red", "columns.style.when": "ret!=0"},
+		},
+		"conditional_other_field": {
+			annotations: map[string]interface{}{"columns.style": "bold", "columns.style.when": "latency>=1000000"},
+		},
+		"unknown_color": {
+			annotations:       map[string]interface{}{"columns.color": "purple"},
+			expectedErrString: `unknown color "purple"`,
+		},
+		"unknown_style": {
+			annotations:       map[string]interface{}{"columns.style": "bold,blink"},
+			expectedErrString: `unknown style "blink"`,
+		},
+		"condition_without_style": {
+			annotations:       map[string]interface{}{"columns.style.when": "ret!=0"},
+			expectedErrString: "columns.style.when requires columns.color or columns.style",
+		},
+		"invalid_condition": {
+			annotations:       map[string]interface{}{"columns.color": "red", "columns.style.when": "ret"},
+			expectedErrString: `invalid condition "ret"`,
+		},
+		"unknown_field": {
+			annotations:       map[string]interface{}{"columns.color": "red", "columns.style.when": "errno!=0"},
+			expectedErrString: `condition "errno!=0" refers to unknown field "errno"`,
+		},
+	}
+
+	s := metadatav1.Struct{Fields: []metadatav1.Field{{Name: "ret"}, {Name: "latency"}}}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			field := metadatav1.Field{Name: "ret", Annotations: test.annotations}
+			err := validateFieldStyle(field, s)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestValidateComputedFields(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		fields            []metadatav1.Field
+		expectedErrString string
+	}
+
+	_, l4 := endpointTypes()
+	btfStruct := &btf.Struct{
+		Name: "event",
+		Members: []btf.Member{
+			{Name: "bytes_out", Type: &btf.Int{Name: "__u64", Size: 8}},
+			{Name: "duration", Type: u32Type},
+			{Name: "dport", Type: &btf.Int{Name: "__u16", Size: 2}},
+			{Name: "comm", Type: &btf.Array{Type: charType, Nelems: 16}},
+			{Name: "success", Type: &btf.Int{Name: "bool", Size: 1, Encoding: btf.Bool}},
+			{Name: "src", Type: l4},
+		},
+	}
+	fields := []metadatav1.Field{
+		{Name: "bytes_out"}, {Name: "duration"}, {Name: "dport"}, {Name: "comm"}, {Name: "success"}, {Name: "src"},
+	}
+
+	tests := map[string]testCase{
+		"no_computed_fields": {},
+		"valid": {
+			fields: []metadatav1.Field{
+				{Name: "rate", Source: "bytes_out / duration", SourcePlaceholder: "n/a"},
+				{Name: "proto", Source: `dport == 53 ? "dns" : "other"`},
+				{Name: "label", Source: `success ? comm + " ok" : comm`},
+			},
+		},
+		"unknown_field": {
+			fields: []metadatav1.Field{
+				{Name: "rate", Source: "bytes_out / elapsed"},
+			},
+			expectedErrString: `field "rate": checking expression "bytes_out / elapsed": unknown field "elapsed"`,
+		},
+		"computed_field_reference": {
+			fields: []metadatav1.Field{
+				{Name: "rate", Source: "bytes_out / duration"},
+				{Name: "double_rate", Source: "rate * 2"},
+			},
+			expectedErrString: `unknown field "rate"`,
+		},
+		"type_mismatch": {
+			fields: []metadatav1.Field{
+				{Name: "bad", Source: "comm * 2"},
+			},
+			expectedErrString: `operator "*" can't be used with a string and a number`,
+		},
+		"endpoint": {
+			fields: []metadatav1.Field{
+				{Name: "bad", Source: `src == "1.1.1.1"`},
+			},
+			expectedErrString: `field "bad": field "src" can't be used in expressions`,
+		},
+		"syntax_error": {
+			fields: []metadatav1.Field{
+				{Name: "bad", Source: "bytes_out /"},
+			},
+			expectedErrString: `field "bad": parsing expression "bytes_out /"`,
+		},
+		"placeholder_without_source": {
+			fields: []metadatav1.Field{
+				{Name: "dport", SourcePlaceholder: "n/a"},
+			},
+			expectedErrString: `field "dport": sourcePlaceholder requires source`,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := metadatav1.Struct{Fields: append(append([]metadatav1.Field{}, fields...), test.fields...)}
+			err := validateComputedFields(s, btfStruct)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func mapSnapshotterSpec(t *testing.T, mapType ebpf.MapType) *ebpf.CollectionSpec {
+	t.Helper()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	key := &btf.Struct{Name: "sock_key", Size: 8, Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "fd", Type: u32Type, Offset: 32},
+	}}
+	value := &btf.Struct{Name: "sock_stats", Size: 8, Members: []btf.Member{
+		{Name: "bytes", Type: &btf.Int{Name: "__u64", Size: 8}},
+	}}
+	types := []btf.Type{
+		key,
+		value,
+		&btf.Var{Name: "gadget_map_snapshotter_socks___socks", Type: constVoidPtr, Linkage: btf.GlobalVar},
+	}
+
+	spec := newTestBTF(t, types...)
+
+	var loadedKey, loadedValue *btf.Struct
+	require.NoError(t, spec.TypeByName("sock_key", &loadedKey))
+	require.NoError(t, spec.TypeByName("sock_stats", &loadedValue))
+
+	var mapKey btf.Type = loadedKey
+	keySize := loadedKey.Size
+	if mapType == ebpf.Array || mapType == ebpf.PerCPUArray {
+		mapKey, keySize = u32Type, 4
+	}
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"socks": {
+				Name:       "socks",
+				Type:       mapType,
+				KeySize:    keySize,
+				ValueSize:  loadedValue.Size,
+				MaxEntries: 1024,
+				Key:        mapKey,
+				Value:      loadedValue,
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestPopulateMapSnapshotter(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, Populate(m, mapSnapshotterSpec(t, ebpf.Hash)))
+	require.Equal(t, map[string]metadatav1.Snapshotter{
+		"socks": {
+			StructName:    "sock_stats",
+			Source:        metadatav1.SnapshotterSourceMap,
+			MapName:       "socks",
+			KeyStructName: "sock_key",
+		},
+	}, m.Snapshotters)
+	require.Contains(t, m.Structs, "sock_key")
+	require.Contains(t, m.Structs, "sock_stats")
+	require.NoError(t, Validate(m, mapSnapshotterSpec(t, ebpf.Hash)))
+
+	// The index of arrays isn't part of the entries
+	m = &metadatav1.GadgetMetadata{}
+	require.NoError(t, Populate(m, mapSnapshotterSpec(t, ebpf.PerCPUArray)))
+	require.Equal(t, "", m.Snapshotters["socks"].KeyStructName)
+	require.NotContains(t, m.Structs, "sock_key")
+	require.NoError(t, Validate(m, mapSnapshotterSpec(t, ebpf.PerCPUArray)))
+}
+
+func TestValidateMapSnapshotter(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		snapshotter       metadatav1.Snapshotter
+		mapType           ebpf.MapType
+		expectedErrString string
+	}
+
+	good := metadatav1.Snapshotter{
+		StructName:    "sock_stats",
+		Source:        metadatav1.SnapshotterSourceMap,
+		MapName:       "socks",
+		KeyStructName: "sock_key",
+	}
+
+	tests := map[string]testCase{
+		"good": {
+			snapshotter: good,
+		},
+		"lru_hash": {
+			snapshotter: good,
+			mapType:     ebpf.LRUHash,
+		},
+		"wrong_map_type": {
+			snapshotter:       good,
+			mapType:           ebpf.RingBuf,
+			expectedErrString: "map \"socks\" has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: RingBuf",
+		},
+		"missing_map_name": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName: "sock_stats",
+				Source:     metadatav1.SnapshotterSourceMap,
+			},
+			expectedErrString: "missing mapName",
+		},
+		"unknown_map": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName: "sock_stats",
+				Source:     metadatav1.SnapshotterSourceMap,
+				MapName:    "sockets",
+			},
+			expectedErrString: "map \"sockets\" not found in eBPF object",
+		},
+		"wrong_struct": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName:    "sock_key",
+				Source:        metadatav1.SnapshotterSourceMap,
+				MapName:       "socks",
+				KeyStructName: "sock_key",
+			},
+			expectedErrString: "map \"socks\" value is \"sock_stats\", expected struct \"sock_key\"",
+		},
+		"missing_key_struct": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName: "sock_stats",
+				Source:     metadatav1.SnapshotterSourceMap,
+				MapName:    "socks",
+			},
+			expectedErrString: "map \"socks\" key struct is \"sock_key\", got keyStructName \"\"",
+		},
+		"map_without_source": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName: "sock_stats",
+				MapName:    "socks",
+			},
+			expectedErrString: "mapName and keyStructName require source \"map\"",
+		},
+		"invalid_source": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName: "sock_stats",
+				Source:     "table",
+			},
+			expectedErrString: "invalid source \"table\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mapType := test.mapType
+			if mapType == ebpf.UnspecifiedMap {
+				mapType = ebpf.Hash
+			}
+
+			m := &metadatav1.GadgetMetadata{}
+			require.NoError(t, Populate(m, mapSnapshotterSpec(t, ebpf.Hash)))
+			m.Snapshotters["socks"] = test.snapshotter
+
+			err := Validate(m, mapSnapshotterSpec(t, mapType))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateSnapshotterMode(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		snapshotter       metadatav1.Snapshotter
+		keyField          bool
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"run_once": {
+			snapshotter: metadatav1.Snapshotter{StructName: "proc"},
+		},
+		"replace": {
+			snapshotter: metadatav1.Snapshotter{StructName: "proc", Interval: "5s", Mode: metadatav1.SnapshotterModeReplace},
+		},
+		"diff": {
+			snapshotter: metadatav1.Snapshotter{StructName: "proc", Interval: "5s", Mode: metadatav1.SnapshotterModeDiff},
+			keyField:    true,
+		},
+		"diff_map_key_struct": {
+			snapshotter: metadatav1.Snapshotter{
+				StructName:    "proc",
+				Source:        metadatav1.SnapshotterSourceMap,
+				MapName:       "procs",
+				KeyStructName: "proc_key",
+				Mode:          metadatav1.SnapshotterModeDiff,
+			},
+		},
+		"diff_without_keys": {
+			snapshotter:       metadatav1.Snapshotter{StructName: "proc", Mode: metadatav1.SnapshotterModeDiff},
+			expectedErrString: "mode \"diff\" requires key fields",
+		},
+		"invalid_mode": {
+			snapshotter:       metadatav1.Snapshotter{StructName: "proc", Mode: "append"},
+			expectedErrString: "invalid mode \"append\"",
+		},
+		"invalid_interval": {
+			snapshotter:       metadatav1.Snapshotter{StructName: "proc", Interval: "5"},
+			expectedErrString: "invalid interval \"5\"",
+		},
+		"negative_interval": {
+			snapshotter:       metadatav1.Snapshotter{StructName: "proc", Interval: "-1s"},
+			expectedErrString: "invalid interval \"-1s\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"proc": {Fields: []metadatav1.Field{
+						{Name: "pid", Attributes: metadatav1.FieldAttributes{Key: test.keyField}},
+						{Name: "comm"},
+					}},
+				},
+			}
+
+			err := validateSnapshotterMode(m, test.snapshotter)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateKeyFields(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{
+		Snapshotters: map[string]metadatav1.Snapshotter{
+			"procs": {StructName: "proc"},
+		},
+		Structs: map[string]metadatav1.Struct{
+			"proc": {Fields: []metadatav1.Field{
+				{Name: "pid", Attributes: metadatav1.FieldAttributes{Key: true}},
+				{Name: "comm"},
+			}},
+		},
+	}
+	require.NoError(t, validateKeyFields(m))
+
+	m.Structs["proc"] = metadatav1.Struct{Fields: []metadatav1.Field{
+		{Name: "pid"},
+		{Name: "kind", Source: `pid == 1 ? "init" : "other"`, Attributes: metadatav1.FieldAttributes{Key: true}},
+	}}
+	require.ErrorContains(t, validateKeyFields(m), "field \"kind\" of struct \"proc\": computed fields can't be keys")
+
+	m.Structs["event"] = metadatav1.Struct{Fields: []metadatav1.Field{
+		{Name: "pid", Attributes: metadatav1.FieldAttributes{Key: true}},
+	}}
+	require.ErrorContains(t, validateKeyFields(m), "field \"pid\" of struct \"event\": key is only supported by the fields of snapshotters and of tracers with aggregation")
+
+	// Tracers with aggregation group their events by key fields
+	m.Structs["proc"] = metadatav1.Struct{Fields: []metadatav1.Field{{Name: "pid"}}}
+	m.Tracers = map[string]metadatav1.Tracer{
+		"events": {StructName: "event", Aggregation: &metadatav1.Aggregation{Window: "1s", CountField: "count"}},
+	}
+	require.NoError(t, validateKeyFields(m))
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+var (
+	// https://prometheus.io/docs/concepts/data_model/#metric-names-and-labels
+	// Metric names are keys of the metadata file, which are read case
+	// insensitive: upper case letters aren't allowed.
+	metricNameRegex  = regexp.MustCompile(`^[a-z_:][a-z0-9_:]*$`)
+	metricLabelRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// maxMetricMapEntries is the number of entries of the map of a map-derived
+// metric above which a warning about the number of label sets is printed
+const maxMetricMapEntries = 10000
+
+// metricLabelName returns the name of the label the field is exported as:
+// nested fields, like "k8s.podName", are joined with underscores
+func metricLabelName(field string) string {
+	return strings.ReplaceAll(field, ".", "_")
+}
+
+func validateMetrics(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Metrics) {
+		if err := validateMetric(m, idx, name, m.Metrics[name]); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating metric %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+func validateMetric(m *metadatav1.GadgetMetadata, idx *btfIndex, name string, metric metadatav1.Metric) (result error) {
+	if !metricNameRegex.MatchString(name) {
+		result = multierror.Append(result, fmt.Errorf("invalid metric name %q: it must match %s", name, metricNameRegex))
+	}
+
+	switch metric.Type {
+	case metadatav1.MetricTypeCounter, metadatav1.MetricTypeGauge:
+	default:
+		result = multierror.Append(result, fmt.Errorf("invalid type %q, expected %q or %q",
+			metric.Type, metadatav1.MetricTypeCounter, metadatav1.MetricTypeGauge))
+	}
+
+	for _, label := range metric.Labels {
+		labelName := metricLabelName(label)
+		if !metricLabelRegex.MatchString(labelName) || strings.HasPrefix(labelName, "__") {
+			result = multierror.Append(result, fmt.Errorf("invalid label %q: %q must match %s and can't start with \"__\"",
+				label, labelName, metricLabelRegex))
+		}
+	}
+
+	switch {
+	case metric.MapName != "" && metric.DataSource != "":
+		return multierror.Append(result, errors.New("mapName and dataSource are mutually exclusive"))
+	case metric.MapName != "":
+		if err := validateMapMetric(idx.spec, name, metric); err != nil {
+			result = multierror.Append(result, err)
+		}
+	case metric.DataSource != "":
+		if err := validateEventMetric(m, idx, name, metric); err != nil {
+			result = multierror.Append(result, err)
+		}
+	default:
+		result = multierror.Append(result, errors.New("either mapName or dataSource is required"))
+	}
+	return
+}
+
+// validateMapMetric checks a metric read from a map: the labels have to be
+// fields of its key struct and the value an integer
+func validateMapMetric(spec *ebpf.CollectionSpec, name string, metric metadatav1.Metric) (result error) {
+	if len(metric.Selector) > 0 {
+		result = multierror.Append(result, errors.New("selector can only be used by metrics with a dataSource"))
+	}
+
+	metricMap, ok := spec.Maps[metric.MapName]
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("map %q not found in eBPF object", metric.MapName))
+	}
+	switch metricMap.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	default:
+		return multierror.Append(result, fmt.Errorf("map %q has a wrong type, expected: hash or lru hash (optionally per-CPU), got: %s",
+			metricMap.Name, metricMap.Type))
+	}
+	if metricMap.MaxEntries > maxMetricMapEntries {
+		log.Warnf("Metric %q: map %q can hold up to %d entries, each of them is a different set of labels",
+			name, metricMap.Name, metricMap.MaxEntries)
+	}
+
+	keyStruct, ok := btf.UnderlyingType(metricMap.Key).(*btf.Struct)
+	if !ok {
+		result = multierror.Append(result, fmt.Errorf("map %q key must be a struct whose fields are the labels", metricMap.Name))
+	} else {
+		for _, label := range metric.Labels {
+			member, ok := findMember(keyStruct.Members, label)
+			if !ok {
+				result = multierror.Append(result, fmt.Errorf("label %q not found in struct %q", label, keyStruct.Name))
+				continue
+			}
+			warnMetricLabelCardinality(name, label, member)
+		}
+	}
+
+	switch value := btf.UnderlyingType(metricMap.Value).(type) {
+	case *btf.Struct:
+		if metric.Field == "" {
+			return multierror.Append(result, fmt.Errorf("field is required, map %q value is struct %q", metricMap.Name, value.Name))
+		}
+		member, ok := findMember(value.Members, metric.Field)
+		if !ok {
+			return multierror.Append(result, fmt.Errorf("field %q not found in struct %q", metric.Field, value.Name))
+		}
+		if !isInteger(member) {
+			result = multierror.Append(result, fmt.Errorf("field %q must be an integer field", metric.Field))
+		}
+	case *btf.Int:
+		if metric.Field != "" {
+			result = multierror.Append(result, fmt.Errorf("field %q can't be used, map %q value is an integer", metric.Field, metricMap.Name))
+		}
+	default:
+		result = multierror.Append(result, fmt.Errorf("map %q value must be an integer or a struct", metricMap.Name))
+	}
+	return
+}
+
+// validateEventMetric checks a metric computed from the events of a data
+// source. Labels and selectors aren't checked, as they can use fields added
+// by operators, like the name of the pod.
+func validateEventMetric(m *metadatav1.GadgetMetadata, idx *btfIndex, name string, metric metadatav1.Metric) (result error) {
+	var structName string
+	if t, ok := m.Tracers[metric.DataSource]; ok {
+		structName = t.StructName
+	} else if t, ok := m.Toppers[metric.DataSource]; ok {
+		structName = t.StructName
+	} else if s, ok := m.Snapshotters[metric.DataSource]; ok {
+		structName = s.StructName
+	} else {
+		return fmt.Errorf("dataSource %q not found, expected a tracer, topper or snapshotter", metric.DataSource)
+	}
+
+	for _, rule := range metric.Selector {
+		if !strings.ContainsAny(rule, "=!~<>") {
+			result = multierror.Append(result, fmt.Errorf("invalid selector %q, expected a filter rule like \"comm==curl\"", rule))
+		}
+	}
+
+	if metric.Field == "" {
+		if metric.Type == metadatav1.MetricTypeGauge {
+			result = multierror.Append(result, errors.New("field is required by gauges"))
+		}
+		return
+	}
+
+	btfStruct, err := idx.structByName(structName)
+	if err != nil {
+		return multierror.Append(result, fmt.Errorf("looking for struct %q in eBPF object: %w", structName, err))
+	}
+	member, ok := findMember(btfStruct.Members, metric.Field)
+	if !ok {
+		return multierror.Append(result, fmt.Errorf("field %q not found in struct %q", metric.Field, structName))
+	}
+	if !isInteger(member) {
+		result = multierror.Append(result, fmt.Errorf("field %q must be an integer field", metric.Field))
+	}
+
+	for _, label := range metric.Labels {
+		if member, ok := findMember(btfStruct.Members, label); ok {
+			warnMetricLabelCardinality(name, label, member)
+		}
+	}
+	return
+}
+
+// warnMetricLabelCardinality warns about labels likely to take too many
+// values, as each of them is a different time series
+func warnMetricLabelCardinality(name, label string, member btf.Member) {
+	lower := strings.ToLower(label[strings.LastIndex(label, ".")+1:])
+	switch {
+	case lower == "pid" || lower == "tid" || lower == "ppid" || strings.Contains(lower, "timestamp"):
+	default:
+		t, ok := btfhelpers.GetUnderlyingType(member.Type).(*btf.Int)
+		if !ok || t.Size < 8 {
+			return
+		}
+	}
+	log.Warnf("Metric %q: label %q may take too many values, each of them is a different time series", name, label)
+}
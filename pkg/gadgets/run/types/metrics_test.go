@@ -0,0 +1,223 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func metricsSpec(t *testing.T, mapType ebpf.MapType) *ebpf.CollectionSpec {
+	t.Helper()
+
+	u64 := &btf.Int{Name: "__u64", Size: 8}
+	key := &btf.Struct{Name: "io_key", Size: 8, Members: []btf.Member{
+		{Name: "dev", Type: u32Type},
+		{Name: "op", Type: u32Type, Offset: 32},
+	}}
+	value := &btf.Struct{Name: "io_stats", Size: 16, Members: []btf.Member{
+		{Name: "ops", Type: u64},
+		{Name: "comm", Type: &btf.Array{Index: u32Type, Type: &btf.Int{Name: "char", Size: 1}, Nelems: 8}, Offset: 64},
+	}}
+	event := &btf.Struct{Name: "event", Size: 16, Members: []btf.Member{
+		{Name: "pid", Type: u32Type},
+		{Name: "bytes", Type: u64, Offset: 64},
+	}}
+
+	spec := newTestBTF(t, key, value, event)
+
+	var loadedKey, loadedValue *btf.Struct
+	require.NoError(t, spec.TypeByName("io_key", &loadedKey))
+	require.NoError(t, spec.TypeByName("io_stats", &loadedValue))
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"io": {
+				Name:       "io",
+				Type:       mapType,
+				KeySize:    loadedKey.Size,
+				ValueSize:  loadedValue.Size,
+				MaxEntries: 1024,
+				Key:        loadedKey,
+				Value:      loadedValue,
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestValidateMetrics(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name              string
+		metric            metadatav1.Metric
+		mapType           ebpf.MapType
+		expectedErrString string
+	}
+
+	mapCounter := metadatav1.Metric{
+		Type:    metadatav1.MetricTypeCounter,
+		MapName: "io",
+		Field:   "ops",
+		Labels:  []string{"dev", "op"},
+	}
+
+	tests := map[string]testCase{
+		"map_counter": {
+			name:   "io_ops_total",
+			metric: mapCounter,
+		},
+		"event_counter": {
+			name: "exec_bytes_total",
+			metric: metadatav1.Metric{
+				Type:       metadatav1.MetricTypeCounter,
+				DataSource: "exec",
+				Field:      "bytes",
+				Labels:     []string{"k8s.namespace", "k8s.podName"},
+				Selector:   []string{"comm==curl"},
+			},
+		},
+		"invalid_name": {
+			name:              "io-ops",
+			metric:            mapCounter,
+			expectedErrString: "invalid metric name \"io-ops\"",
+		},
+		"upper_case_name": {
+			name:              "IO_ops_total",
+			metric:            mapCounter,
+			expectedErrString: "invalid metric name \"IO_ops_total\"",
+		},
+		"invalid_label": {
+			name: "io_ops_total",
+			metric: metadatav1.Metric{
+				Type:    metadatav1.MetricTypeCounter,
+				MapName: "io",
+				Field:   "ops",
+				Labels:  []string{"__dev"},
+			},
+			expectedErrString: "invalid label \"__dev\"",
+		},
+		"invalid_type": {
+			name: "io_ops",
+			metric: metadatav1.Metric{
+				Type:    "histogram",
+				MapName: "io",
+				Field:   "ops",
+			},
+			expectedErrString: "invalid type \"histogram\"",
+		},
+		"map_and_datasource": {
+			name: "io_ops_total",
+			metric: metadatav1.Metric{
+				Type:       metadatav1.MetricTypeCounter,
+				MapName:    "io",
+				DataSource: "exec",
+			},
+			expectedErrString: "mapName and dataSource are mutually exclusive",
+		},
+		"wrong_map_type": {
+			name:              "io_ops_total",
+			metric:            mapCounter,
+			mapType:           ebpf.Array,
+			expectedErrString: "map \"io\" has a wrong type",
+		},
+		"unknown_label": {
+			name: "io_ops_total",
+			metric: metadatav1.Metric{
+				Type:    metadatav1.MetricTypeCounter,
+				MapName: "io",
+				Field:   "ops",
+				Labels:  []string{"disk"},
+			},
+			expectedErrString: "label \"disk\" not found in struct \"io_key\"",
+		},
+		"non_integer_field": {
+			name: "io_ops_total",
+			metric: metadatav1.Metric{
+				Type:    metadatav1.MetricTypeCounter,
+				MapName: "io",
+				Field:   "comm",
+			},
+			expectedErrString: "field \"comm\" must be an integer field",
+		},
+		"selector_on_map": {
+			name: "io_ops_total",
+			metric: metadatav1.Metric{
+				Type:     metadatav1.MetricTypeCounter,
+				MapName:  "io",
+				Field:    "ops",
+				Selector: []string{"dev==1"},
+			},
+			expectedErrString: "selector can only be used by metrics with a dataSource",
+		},
+		"unknown_datasource": {
+			name: "exec_total",
+			metric: metadatav1.Metric{
+				Type:       metadatav1.MetricTypeCounter,
+				DataSource: "open",
+			},
+			expectedErrString: "dataSource \"open\" not found",
+		},
+		"gauge_without_field": {
+			name: "exec_bytes",
+			metric: metadatav1.Metric{
+				Type:       metadatav1.MetricTypeGauge,
+				DataSource: "exec",
+			},
+			expectedErrString: "field is required by gauges",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mapType := test.mapType
+			if mapType == ebpf.UnspecifiedMap {
+				mapType = ebpf.Hash
+			}
+
+			m := &metadatav1.GadgetMetadata{
+				Tracers: map[string]metadatav1.Tracer{
+					"exec": {MapName: "events", StructName: "event"},
+				},
+				Metrics: map[string]metadatav1.Metric{
+					test.name: test.metric,
+				},
+			}
+
+			err := validateMetrics(m, newBTFIndex(metricsSpec(t, mapType)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestMetricLabelName(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "dev", metricLabelName("dev"))
+	require.Equal(t, "k8s_podName", metricLabelName("k8s.podName"))
+}
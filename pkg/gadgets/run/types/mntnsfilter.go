@@ -0,0 +1,88 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// mntnsIDSize is the size of the keys of the mount namespace filter map,
+// gadget_mntns_id
+const mntnsIDSize = 8
+
+// validateMntNsFilter checks the map filtering events by container: the one
+// declared in the metadata or, if none is, the conventional one if the gadget
+// defines it. A wrong map would make the gadget fail to load or, worse, not
+// filter anything.
+func validateMntNsFilter(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	name := gadgets.MntNsFilterMapName
+	if m.MntNsFilter != nil {
+		if m.MntNsFilter.MapName == "" {
+			return errors.New("mntnsFilter: mapName is required")
+		}
+		name = m.MntNsFilter.MapName
+	} else if _, ok := idx.spec.Maps[name]; !ok {
+		return nil
+	}
+
+	if err := validateMntNsFilterMap(idx.spec, name); err != nil {
+		return fmt.Errorf("mntnsFilter: %w", err)
+	}
+	if len(idx.vars[gadgets.FilterByMntNsName]) == 0 {
+		return fmt.Errorf("mntnsFilter: constant %q enabling the filter not found in eBPF object, see include/gadget/mntns_filter.h",
+			gadgets.FilterByMntNsName)
+	}
+	return nil
+}
+
+func validateMntNsFilterMap(spec *ebpf.CollectionSpec, name string) (result error) {
+	filterMap, ok := spec.Maps[name]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", name)
+	}
+
+	if filterMap.Type != ebpf.Hash {
+		result = multierror.Append(result, fmt.Errorf("map %q has type %s, expected %s", name, filterMap.Type, ebpf.Hash))
+	}
+	if filterMap.KeySize != mntnsIDSize {
+		result = multierror.Append(result, fmt.Errorf("map %q has keys of %d bytes, expected %d (gadget_mntns_id)",
+			name, filterMap.KeySize, mntnsIDSize))
+	}
+	if filterMap.MaxEntries == 0 {
+		result = multierror.Append(result, fmt.Errorf("map %q has no max entries", name))
+	}
+
+	return
+}
+
+// populateMntNsFilter records the conventional mount namespace filter map
+func populateMntNsFilter(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) {
+	if m.MntNsFilter != nil {
+		return
+	}
+	if _, ok := spec.Maps[gadgets.MntNsFilterMapName]; !ok {
+		return
+	}
+	log.Debugf("Adding mount namespace filter map %q", gadgets.MntNsFilterMapName)
+	m.MntNsFilter = &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName}
+}
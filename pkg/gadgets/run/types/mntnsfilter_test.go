@@ -0,0 +1,148 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// mntnsFilterSpec returns an object with the given maps, defining the
+// constant enabling the filter if withConst is set
+func mntnsFilterSpec(t *testing.T, maps map[string]*ebpf.MapSpec, withConst bool) *ebpf.CollectionSpec {
+	t.Helper()
+
+	var types []btf.Type
+	if withConst {
+		types = append(types, &btf.Var{
+			Name:    gadgets.FilterByMntNsName,
+			Type:    &btf.Const{Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}},
+			Linkage: btf.GlobalVar,
+		})
+	}
+	spec := newTestBTF(t, types...)
+
+	return &ebpf.CollectionSpec{Maps: maps, Types: spec}
+}
+
+func filterMapSpec(typ ebpf.MapType, keySize, maxEntries uint32) *ebpf.MapSpec {
+	return &ebpf.MapSpec{Type: typ, KeySize: keySize, ValueSize: 4, MaxEntries: maxEntries}
+}
+
+func TestValidateMntNsFilter(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mntnsFilter       *metadatav1.MntNsFilter
+		maps              map[string]*ebpf.MapSpec
+		noConst           bool
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"correct_map": {
+			mntnsFilter: &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName},
+			maps:        map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 1024)},
+		},
+		"custom_name": {
+			mntnsFilter: &metadatav1.MntNsFilter{MapName: "my_filter"},
+			maps:        map[string]*ebpf.MapSpec{"my_filter": filterMapSpec(ebpf.Hash, 8, 1024)},
+		},
+		"auto_detected": {
+			maps: map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 1024)},
+		},
+		"no_filter": {
+			noConst: true,
+		},
+		"wrong_key_size": {
+			mntnsFilter:       &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName},
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 4, 1024)},
+			expectedErrString: "map \"gadget_mntns_filter_map\" has keys of 4 bytes, expected 8",
+		},
+		"auto_detected_wrong_key_size": {
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 4, 1024)},
+			expectedErrString: "map \"gadget_mntns_filter_map\" has keys of 4 bytes, expected 8",
+		},
+		"wrong_type": {
+			mntnsFilter:       &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName},
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Array, 8, 1024)},
+			expectedErrString: "map \"gadget_mntns_filter_map\" has type Array, expected Hash",
+		},
+		"no_max_entries": {
+			mntnsFilter:       &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName},
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 0)},
+			expectedErrString: "map \"gadget_mntns_filter_map\" has no max entries",
+		},
+		"absent_map": {
+			mntnsFilter:       &metadatav1.MntNsFilter{MapName: "gadget_mntns_filtre_map"},
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 1024)},
+			expectedErrString: "mntnsFilter: map \"gadget_mntns_filtre_map\" not found in eBPF object",
+		},
+		"missing_map_name": {
+			mntnsFilter:       &metadatav1.MntNsFilter{},
+			expectedErrString: "mntnsFilter: mapName is required",
+		},
+		"missing_const": {
+			mntnsFilter:       &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName},
+			maps:              map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 1024)},
+			noConst:           true,
+			expectedErrString: "constant \"gadget_filter_by_mntns\" enabling the filter not found",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{MntNsFilter: test.mntnsFilter}
+			idx := newBTFIndex(mntnsFilterSpec(t, test.maps, !test.noConst))
+			err := validateMntNsFilter(m, idx)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateMntNsFilter(t *testing.T) {
+	t.Parallel()
+
+	spec := &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{gadgets.MntNsFilterMapName: filterMapSpec(ebpf.Hash, 8, 1024)},
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	populateMntNsFilter(m, spec)
+	require.Equal(t, &metadatav1.MntNsFilter{MapName: gadgets.MntNsFilterMapName}, m.MntNsFilter)
+
+	// A declared map is kept
+	m = &metadatav1.GadgetMetadata{MntNsFilter: &metadatav1.MntNsFilter{MapName: "my_filter"}}
+	populateMntNsFilter(m, spec)
+	require.Equal(t, "my_filter", m.MntNsFilter.MapName)
+
+	// Nothing is recorded for gadgets without the map
+	m = &metadatav1.GadgetMetadata{}
+	populateMntNsFilter(m, &ebpf.CollectionSpec{})
+	require.Nil(t, m.MntNsFilter)
+}
@@ -0,0 +1,274 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// PopulateFromSpecs populates the metadata from the eBPF objects of each
+// architecture, indexed by the architecture name. The metadata populated from
+// each of them must be the same: the structs must have the same members with
+// the same types, only their sizes (e.g. long on 32 and 64 bits) and hence the
+// width of the columns can differ. Those differences are logged and the
+// largest width is used. Likewise, the type hints of the params backed by
+// such types can differ, the narrowest one is used. The report is the one of the first architecture in
+// alphabetical order.
+func PopulateFromSpecs(m *metadatav1.GadgetMetadata, specs map[string]*ebpf.CollectionSpec, opts ...PopulateOption) (*PopulateReport, error) {
+	archs := sortedKeys(specs)
+	if len(archs) == 0 {
+		return nil, errors.New("no eBPF object provided")
+	}
+
+	report := newPopulateReport()
+	populated := make(map[string]*metadatav1.GadgetMetadata, len(archs))
+	for i, arch := range archs {
+		cpy, err := copyMetadata(m)
+		if err != nil {
+			return nil, err
+		}
+		var archReport *PopulateReport
+		if i == 0 {
+			archReport = report
+		}
+		if err := populate(cpy, specs[arch], archReport, opts...); err != nil {
+			return nil, fmt.Errorf("populating metadata for %s: %w", arch, err)
+		}
+		populated[arch] = cpy
+	}
+
+	ref := archs[0]
+	merged := populated[ref]
+
+	var result error
+	for _, arch := range archs[1:] {
+		if err := compareArchs(merged, ref, specs[ref], populated[arch], arch, specs[arch]); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	if result != nil {
+		return nil, fmt.Errorf("metadata differs across architectures: %w", result)
+	}
+
+	*m = *merged
+	return report, nil
+}
+
+// compareArchs checks that the metadata populated for two architectures is
+// the same. The width of the fields of a is increased to fit the ones of b.
+func compareArchs(a *metadatav1.GadgetMetadata, archA string, specA *ebpf.CollectionSpec,
+	b *metadatav1.GadgetMetadata, archB string, specB *ebpf.CollectionSpec,
+) error {
+	var result error
+	differs := func(format string, args ...any) {
+		result = multierror.Append(result, fmt.Errorf(format, args...))
+	}
+
+	compareMaps(differs, archA, archB, "tracers", a.Tracers, b.Tracers)
+	compareMaps(differs, archA, archB, "toppers", a.Toppers, b.Toppers)
+	compareMaps(differs, archA, archB, "profilers", a.Profilers, b.Profilers)
+	compareMaps(differs, archA, archB, "snapshotters", a.Snapshotters, b.Snapshotters)
+	compareMaps(differs, archA, archB, "ebpfParams", a.EBPFParams, compareParamWidths(archA, archB, a.EBPFParams, b.EBPFParams))
+	compareMaps(differs, archA, archB, "gadgetParams", a.GadgetParams, b.GadgetParams)
+	compareMaps(differs, archA, archB, "metrics", a.Metrics, b.Metrics)
+	compareMaps(differs, archA, archB, "alerts", a.Alerts, b.Alerts)
+	compareMaps(differs, archA, archB, "programs", a.Programs, b.Programs)
+	compareMaps(differs, archA, archB, "attach", a.Attach, b.Attach)
+
+	if !reflect.DeepEqual(a.MntNsFilter, b.MntNsFilter) {
+		differs("mntnsFilter: %+v on %s and %+v on %s", a.MntNsFilter, archA, b.MntNsFilter, archB)
+	}
+
+	for _, name := range sortedKeys(b.Structs) {
+		if _, ok := a.Structs[name]; !ok {
+			differs("structs.%s: only found on %s", name, archB)
+		}
+	}
+
+	for _, name := range sortedKeys(a.Structs) {
+		structB, ok := b.Structs[name]
+		if !ok {
+			differs("structs.%s: only found on %s", name, archA)
+			continue
+		}
+
+		membersA, err := structMembers(specA, name)
+		if err != nil {
+			differs("structs.%s: %s: %w", name, archA, err)
+			continue
+		}
+		membersB, err := structMembers(specB, name)
+		if err != nil {
+			differs("structs.%s: %s: %w", name, archB, err)
+			continue
+		}
+
+		fieldsA := a.Structs[name].Fields
+		fieldsB := structB.Fields
+		if len(fieldsA) != len(fieldsB) {
+			differs("structs.%s: has %d fields on %s and %d on %s", name, len(fieldsA), archA, len(fieldsB), archB)
+			continue
+		}
+
+		for i := range fieldsA {
+			fieldA, fieldB := &fieldsA[i], fieldsB[i]
+			path := fmt.Sprintf("structs.%s.fields.%s", name, fieldA.Name)
+
+			if fieldA.Name != fieldB.Name {
+				differs("structs.%s: field %d is %q on %s and %q on %s", name, i, fieldA.Name, archA, fieldB.Name, archB)
+				continue
+			}
+
+			memberA, okA := findMember(membersA, fieldA.Name)
+			memberB, okB := findMember(membersB, fieldB.Name)
+			if okA && okB {
+				typeA, typeB := typeDescription(memberA.Type), typeDescription(memberB.Type)
+				if typeA != typeB {
+					differs("%s: type is %s on %s and %s on %s", path, typeA, archA, typeB, archB)
+					continue
+				}
+
+				sizeA, _ := btf.Sizeof(memberA.Type)
+				sizeB, _ := btf.Sizeof(memberB.Type)
+				if sizeA != sizeB {
+					log.Warnf("%s: size of %s is %d bytes on %s and %d bytes on %s",
+						path, typeA, sizeA, archA, sizeB, archB)
+				}
+			}
+
+			// The width depends on the size of the member, compare the rest
+			fieldB.Attributes.Width = fieldA.Attributes.Width
+			if !reflect.DeepEqual(*fieldA, fieldB) {
+				differs("%s: attributes differ between %s and %s", path, archA, archB)
+				continue
+			}
+			fieldA.Attributes.Width = max(fieldA.Attributes.Width, structB.Fields[i].Attributes.Width)
+		}
+	}
+
+	return result
+}
+
+func compareMaps[T any](differs func(string, ...any), archA, archB, section string, a, b map[string]T) {
+	for _, name := range sortedKeys(b) {
+		if _, ok := a[name]; !ok {
+			differs("%s.%s: only found on %s", section, name, archB)
+		}
+	}
+	for _, name := range sortedKeys(a) {
+		valueB, ok := b[name]
+		if !ok {
+			differs("%s.%s: only found on %s", section, name, archA)
+		} else if !reflect.DeepEqual(a[name], valueB) {
+			differs("%s.%s: differs between %s and %s", section, name, archA, archB)
+		}
+	}
+}
+
+// widthTypeHints are the type hints derived from the size of the variable
+// backing a param, which can differ across architectures (e.g. long or size_t)
+var widthTypeHints = map[params.TypeHint]struct {
+	kind string
+	size int
+}{
+	params.TypeInt8:    {"int", 1},
+	params.TypeInt16:   {"int", 2},
+	params.TypeInt32:   {"int", 4},
+	params.TypeInt64:   {"int", 8},
+	params.TypeUint8:   {"uint", 1},
+	params.TypeUint16:  {"uint", 2},
+	params.TypeUint32:  {"uint", 4},
+	params.TypeUint64:  {"uint", 8},
+	params.TypeFloat32: {"float", 4},
+	params.TypeFloat64: {"float", 8},
+}
+
+// compareParamWidths logs the params of a and b whose type hints only differ
+// by their width, like the sizes of struct members. The narrowest type hint is
+// kept in a, so the values accepted fit the variables of all architectures.
+// It returns a copy of b with those type hints set to the ones of a, to
+// compare the rest.
+func compareParamWidths(archA, archB string, a, b map[string]metadatav1.EBPFParam) map[string]metadatav1.EBPFParam {
+	ret := make(map[string]metadatav1.EBPFParam, len(b))
+	for name, paramB := range b {
+		ret[name] = paramB
+	}
+
+	for _, name := range sortedKeys(a) {
+		paramA := a[name]
+		paramB, ok := b[name]
+		if !ok || paramA.TypeHint == paramB.TypeHint {
+			continue
+		}
+		hintA, okA := widthTypeHints[paramA.TypeHint]
+		hintB, okB := widthTypeHints[paramB.TypeHint]
+		if !okA || !okB || hintA.kind != hintB.kind {
+			continue
+		}
+
+		log.Warnf("ebpfParams.%s: type is %s on %s and %s on %s",
+			name, paramA.TypeHint, archA, paramB.TypeHint, archB)
+
+		if hintB.size < hintA.size {
+			paramA.TypeHint = paramB.TypeHint
+			a[name] = paramA
+		}
+		paramB.TypeHint = paramA.TypeHint
+		ret[name] = paramB
+	}
+
+	return ret
+}
+
+// structMembers returns the flattened members of a struct, like the fields
+// populated for it
+func structMembers(spec *ebpf.CollectionSpec, name string) ([]btf.Member, error) {
+	var btfStruct *btf.Struct
+	if err := spec.Types.TypeByName(name, &btfStruct); err != nil {
+		return nil, fmt.Errorf("looking for struct %q: %w", name, err)
+	}
+	return flattenMembers(btfStruct.Members, "", 0)
+}
+
+// typeDescription describes typ by its name, ignoring its size
+func typeDescription(typ btf.Type) string {
+	switch t := typ.(type) {
+	case *btf.Const:
+		return typeDescription(t.Type)
+	case *btf.Volatile:
+		return typeDescription(t.Type)
+	case *btf.Restrict:
+		return typeDescription(t.Type)
+	case *btf.Array:
+		return fmt.Sprintf("%s[%d]", typeDescription(t.Type), t.Nelems)
+	case *btf.Pointer:
+		return typeDescription(t.Target) + "*"
+	}
+
+	if name := typ.TypeName(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("anonymous %T", typ)
+}
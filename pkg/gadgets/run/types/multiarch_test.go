@@ -0,0 +1,170 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestPopulateFromSpecs(t *testing.T) {
+	t.Parallel()
+
+	const objectPath = "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o"
+
+	// loadSpec loads the eBPF object and changes the "pid" member of its
+	// event struct
+	loadSpec := func(t *testing.T, modify func(pid *btf.Member)) *ebpf.CollectionSpec {
+		spec, err := ebpf.LoadCollectionSpec(objectPath)
+		require.NoError(t, err)
+		if modify != nil {
+			var event *btf.Struct
+			require.NoError(t, spec.Types.TypeByName("event", &event))
+			modify(&event.Members[0])
+		}
+		return spec
+	}
+
+	withType := func(typ btf.Type) func(pid *btf.Member) {
+		return func(pid *btf.Member) {
+			pid.Type = typ
+		}
+	}
+
+	type testCase struct {
+		amd64, arm func(pid *btf.Member)
+		// expectedPidWidth is checked if no error is expected
+		expectedPidWidth  uint
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"matching": {
+			expectedPidWidth: columns.MaxCharsUint32,
+		},
+		"different_sizes": {
+			amd64:            withType(&btf.Int{Name: "long", Size: 8, Encoding: btf.Signed}),
+			arm:              withType(&btf.Int{Name: "long", Size: 4, Encoding: btf.Signed}),
+			expectedPidWidth: columns.MaxCharsInt64,
+		},
+		"different_types": {
+			arm:               withType(&btf.Int{Name: "__u64", Size: 8}),
+			expectedErrString: "structs.event.fields.pid: type is __u32 on amd64 and __u64 on arm",
+		},
+		"different_members": {
+			arm: func(pid *btf.Member) {
+				pid.Name = "tgid"
+			},
+			expectedErrString: "structs.event: field 0 is \"pid\" on amd64 and \"tgid\" on arm",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{}
+			report, err := PopulateFromSpecs(m, map[string]*ebpf.CollectionSpec{
+				"amd64": loadSpec(t, test.amd64),
+				"arm":   loadSpec(t, test.arm),
+			})
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, []string{"test"}, report.Tracers)
+
+			require.Contains(t, m.Tracers, "test")
+			fields := m.Structs["event"].Fields
+			require.Len(t, fields, 3)
+			require.Equal(t, test.expectedPidWidth, fields[0].Attributes.Width)
+		})
+	}
+}
+
+func TestCompareArchsParamWidths(t *testing.T) {
+	t.Parallel()
+
+	longParam := func(size uint32) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{ParamDesc: paramDescFromVar(&btf.Var{
+			Name: "threshold",
+			Type: &btf.Int{Name: "long", Size: size, Encoding: btf.Signed},
+		})}
+	}
+
+	type testCase struct {
+		amd64, arm        metadatav1.EBPFParam
+		expectedTypeHint  params.TypeHint
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"long": {
+			amd64:            longParam(8),
+			arm:              longParam(4),
+			expectedTypeHint: params.TypeInt32,
+		},
+		"different_signedness": {
+			amd64: longParam(8),
+			arm: metadatav1.EBPFParam{ParamDesc: paramDescFromVar(&btf.Var{
+				Name: "threshold",
+				Type: &btf.Int{Name: "unsigned long", Size: 4},
+			})},
+			expectedErrString: "ebpfParams.threshold: differs between amd64 and arm",
+		},
+		"different_description": {
+			amd64: longParam(8),
+			arm: func() metadatav1.EBPFParam {
+				p := longParam(4)
+				p.Description = "Threshold"
+				return p
+			}(),
+			expectedErrString: "ebpfParams.threshold: differs between amd64 and arm",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			a := &metadatav1.GadgetMetadata{EBPFParams: map[string]metadatav1.EBPFParam{"threshold": test.amd64}}
+			b := &metadatav1.GadgetMetadata{EBPFParams: map[string]metadatav1.EBPFParam{"threshold": test.arm}}
+			err := compareArchs(a, "amd64", nil, b, "arm", nil)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedTypeHint, a.EBPFParams["threshold"].TypeHint)
+		})
+	}
+}
+
+func TestPopulateFromSpecsNoSpecs(t *testing.T) {
+	t.Parallel()
+
+	_, err := PopulateFromSpecs(&metadatav1.GadgetMetadata{}, nil)
+	require.Error(t, err)
+}
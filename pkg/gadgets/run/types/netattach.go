@@ -0,0 +1,98 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/hashicorp/go-multierror"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+const (
+	socketSection     = "socket"
+	classifierSection = "classifier"
+)
+
+// tcSectionDirection returns the direction set by the section name of a TC
+// program, like "classifier/ingress/drop". It's empty for bare sections.
+func tcSectionDirection(sectionName string) metadatav1.AttachDirection {
+	parts := strings.Split(sectionName, "/")
+	if len(parts) < 2 {
+		return ""
+	}
+	return metadatav1.AttachDirection(parts[1])
+}
+
+func validateAttach(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Attach) {
+		if err := validateNetworkAttach(spec, name, m.Attach[name]); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating attach of program %q: %w", name, err))
+		}
+	}
+
+	return result
+}
+
+func validateNetworkAttach(spec *ebpf.CollectionSpec, name string, attach metadatav1.NetworkAttach) (result error) {
+	p, ok := spec.Programs[name]
+	if !ok {
+		return fmt.Errorf("program %q not found in eBPF object", name)
+	}
+
+	switch attach.Interfaces {
+	case "", metadatav1.AttachInterfacesPod, metadatav1.AttachInterfacesAll, metadatav1.AttachInterfacesParam:
+	default:
+		result = multierror.Append(result, fmt.Errorf("invalid interfaces %q: expected %q, %q or %q", attach.Interfaces,
+			metadatav1.AttachInterfacesPod, metadatav1.AttachInterfacesAll, metadatav1.AttachInterfacesParam))
+	}
+
+	switch {
+	case p.Type == ebpf.SocketFilter && strings.HasPrefix(p.SectionName, socketSection):
+		if attach.Direction != "" {
+			result = multierror.Append(result, errors.New("socket filters see both directions, direction can't be set"))
+		}
+		if attach.Interfaces == metadatav1.AttachInterfacesParam {
+			result = multierror.Append(result, fmt.Errorf("socket filters don't support interfaces %q", attach.Interfaces))
+		}
+	case p.Type == ebpf.SchedCLS && strings.HasPrefix(p.SectionName, classifierSection):
+		switch attach.Direction {
+		case "", metadatav1.AttachDirectionIngress, metadatav1.AttachDirectionEgress:
+		default:
+			result = multierror.Append(result, fmt.Errorf("invalid direction %q: expected %q or %q", attach.Direction,
+				metadatav1.AttachDirectionIngress, metadatav1.AttachDirectionEgress))
+		}
+
+		sectionDirection := tcSectionDirection(p.SectionName)
+		switch {
+		case attach.Direction == "" && sectionDirection == "":
+			result = multierror.Append(result, fmt.Errorf("missing direction: section %q doesn't set it", p.SectionName))
+		case attach.Direction != "" && sectionDirection != "" && attach.Direction != sectionDirection:
+			result = multierror.Append(result, fmt.Errorf("direction is %s but section %q is %s",
+				attach.Direction, p.SectionName, sectionDirection))
+		}
+	default:
+		return fmt.Errorf("program %q is %s with section %q, expected a socket filter or a TC program",
+			name, p.Type, p.SectionName)
+	}
+
+	return
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func attachSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ig_trace_dns": {
+				Name:        "ig_trace_dns",
+				Type:        ebpf.SocketFilter,
+				SectionName: "socket1",
+			},
+			"drop_ingress": {
+				Name:        "drop_ingress",
+				Type:        ebpf.SchedCLS,
+				SectionName: "classifier/ingress/drop",
+			},
+			"drop": {
+				Name:        "drop",
+				Type:        ebpf.SchedCLS,
+				SectionName: "classifier",
+			},
+			"do_unlinkat": {
+				Name:        "do_unlinkat",
+				Type:        ebpf.Kprobe,
+				SectionName: "kprobe/do_unlinkat",
+				AttachTo:    "do_unlinkat",
+			},
+		},
+	}
+}
+
+func TestValidateAttach(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name              string
+		attach            metadatav1.NetworkAttach
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"socket_filter": {
+			name:   "ig_trace_dns",
+			attach: metadatav1.NetworkAttach{Interfaces: metadatav1.AttachInterfacesAll},
+		},
+		"socket_filter_default": {
+			name: "ig_trace_dns",
+		},
+		"tc_section_direction": {
+			name:   "drop_ingress",
+			attach: metadatav1.NetworkAttach{Interfaces: metadatav1.AttachInterfacesParam},
+		},
+		"tc_same_direction": {
+			name:   "drop_ingress",
+			attach: metadatav1.NetworkAttach{Direction: metadatav1.AttachDirectionIngress},
+		},
+		"tc_bare_section": {
+			name:   "drop",
+			attach: metadatav1.NetworkAttach{Direction: metadatav1.AttachDirectionEgress, Interfaces: metadatav1.AttachInterfacesPod},
+		},
+		"missing_program": {
+			name:              "drop_egress",
+			expectedErrString: "program \"drop_egress\" not found in eBPF object",
+		},
+		"kprobe": {
+			name:              "do_unlinkat",
+			expectedErrString: "expected a socket filter or a TC program",
+		},
+		"invalid_interfaces": {
+			name:              "drop_ingress",
+			attach:            metadatav1.NetworkAttach{Interfaces: "node"},
+			expectedErrString: "invalid interfaces \"node\"",
+		},
+		"socket_filter_direction": {
+			name:              "ig_trace_dns",
+			attach:            metadatav1.NetworkAttach{Direction: metadatav1.AttachDirectionIngress},
+			expectedErrString: "socket filters see both directions",
+		},
+		"socket_filter_param": {
+			name:              "ig_trace_dns",
+			attach:            metadatav1.NetworkAttach{Interfaces: metadatav1.AttachInterfacesParam},
+			expectedErrString: "socket filters don't support interfaces \"param\"",
+		},
+		"tc_invalid_direction": {
+			name:              "drop",
+			attach:            metadatav1.NetworkAttach{Direction: "both"},
+			expectedErrString: "invalid direction \"both\"",
+		},
+		"tc_missing_direction": {
+			name:              "drop",
+			expectedErrString: "missing direction: section \"classifier\" doesn't set it",
+		},
+		"tc_wrong_direction": {
+			name:              "drop_ingress",
+			attach:            metadatav1.NetworkAttach{Direction: metadatav1.AttachDirectionEgress},
+			expectedErrString: "direction is egress but section \"classifier/ingress/drop\" is ingress",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Attach: map[string]metadatav1.NetworkAttach{
+					test.name: test.attach,
+				},
+			}
+
+			err := validateAttach(m, attachSpec())
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/hashicorp/go-multierror"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// validateParamAliases checks the other spellings of the params: an alias is
+// a single character, used as a short flag, and neither an alias nor an
+// alternative key can be used by two params. Aliases can't be one of the
+// reserved shorthands either. Registering the flags would fail otherwise.
+func validateParamAliases(m *metadatav1.GadgetMetadata, reservedShorthands []string) error {
+	var result error
+
+	varNames := sortedKeys(m.EBPFParams)
+
+	// key or alternative key -> variable of the param using it
+	keys := make(map[string]string)
+	for _, varName := range varNames {
+		keys[m.EBPFParams[varName].Key] = varName
+	}
+	// alias -> variable of the param using it
+	aliases := make(map[string]string)
+
+	for _, varName := range varNames {
+		p := m.EBPFParams[varName]
+
+		if p.Alias != "" {
+			if utf8.RuneCountInString(p.Alias) != 1 {
+				result = multierror.Append(result, fmt.Errorf("param %q: alias %q must be a single character", varName, p.Alias))
+			} else if slices.Contains(reservedShorthands, p.Alias) {
+				result = multierror.Append(result, fmt.Errorf("param %q: alias %q is reserved", varName, p.Alias))
+			} else if owner, ok := aliases[p.Alias]; ok {
+				result = multierror.Append(result, fmt.Errorf("param %q: alias %q is already used by param %q", varName, p.Alias, owner))
+			} else {
+				aliases[p.Alias] = varName
+			}
+		}
+
+		for _, altKey := range p.AltKeys {
+			owner, ok := keys[altKey]
+			switch {
+			case altKey == "":
+				result = multierror.Append(result, fmt.Errorf("param %q has an empty alternative key", varName))
+			case altKey == p.Key:
+				result = multierror.Append(result, fmt.Errorf("param %q: alternative key %q is its key", varName, altKey))
+			case ok && owner == varName:
+				result = multierror.Append(result, fmt.Errorf("param %q: alternative key %q is listed twice", varName, altKey))
+			case ok:
+				result = multierror.Append(result, fmt.Errorf("param %q: alternative key %q is already used by param %q", varName, altKey, owner))
+			default:
+				keys[altKey] = varName
+			}
+		}
+	}
+
+	return result
+}
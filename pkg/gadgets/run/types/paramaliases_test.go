@@ -0,0 +1,119 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestValidateParamAliases(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		params            map[string]metadatav1.EBPFParam
+		reserved          []string
+		expectedErrString string
+	}
+
+	param := func(key, alias string, altKeys ...string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{
+			ParamDesc: params.ParamDesc{Key: key, Alias: alias},
+			AltKeys:   altKeys,
+		}
+	}
+
+	tests := map[string]testCase{
+		"aliases": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file":  param("file", "f", "filename"),
+				"targ_limit": param("limit", "l"),
+				"targ_quiet": param("quiet", ""),
+			},
+			reserved: []string{"o", "v"},
+		},
+		"alias_collision": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file":   param("file", "f"),
+				"targ_filter": param("filter", "f"),
+			},
+			expectedErrString: "param \"targ_filter\": alias \"f\" is already used by param \"targ_file\"",
+		},
+		"reserved_alias": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_output": param("output", "o"),
+			},
+			reserved:          []string{"o", "v"},
+			expectedErrString: "param \"targ_output\": alias \"o\" is reserved",
+		},
+		"long_alias": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "fi"),
+			},
+			expectedErrString: "param \"targ_file\": alias \"fi\" must be a single character",
+		},
+		"alt_key_collides_with_key": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "", "path"),
+				"targ_path": param("path", ""),
+			},
+			expectedErrString: "param \"targ_file\": alternative key \"path\" is already used by param \"targ_path\"",
+		},
+		"alt_key_collision": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "", "name"),
+				"targ_comm": param("comm", "", "name"),
+			},
+			expectedErrString: "param \"targ_file\": alternative key \"name\" is already used by param \"targ_comm\"",
+		},
+		"alt_key_is_key": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "", "file"),
+			},
+			expectedErrString: "param \"targ_file\": alternative key \"file\" is its key",
+		},
+		"alt_key_twice": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "", "filename", "filename"),
+			},
+			expectedErrString: "param \"targ_file\": alternative key \"filename\" is listed twice",
+		},
+		"empty_alt_key": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_file": param("file", "", ""),
+			},
+			expectedErrString: "param \"targ_file\" has an empty alternative key",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{EBPFParams: test.params}
+			err := validateParamAliases(m, test.reserved)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
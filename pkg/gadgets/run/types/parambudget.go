@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// DefaultMaxParamSize is the size, in bytes, a variable backing a param can't
+// exceed unless WithMaxParamSize says otherwise
+const DefaultMaxParamSize = 4096
+
+// validateParamBudget checks the variables backing the params fit in the
+// .rodata sections holding them, with room for their default values, and
+// that none of them is larger than maxParamSize bytes, 0 meaning no limit.
+// Huge char arrays make loading the gadget fail or slow down for no reason.
+func validateParamBudget(m *metadatav1.GadgetMetadata, idx *btfIndex, maxParamSize uint32) error {
+	var result error
+
+	var tooLarge []string
+	for _, name := range sortedKeys(idx.spec.Maps) {
+		if !strings.HasPrefix(name, ".rodata") {
+			continue
+		}
+		contents, ds, err := btfhelpers.DataSection(idx.spec.Maps[name])
+		if errors.Is(err, btfhelpers.ErrMapNoBTFValue) {
+			continue
+		}
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("map %s: %w", name, err))
+			continue
+		}
+
+		var total uint32
+		var sizes, outOfBounds []string
+		for _, v := range ds.Vars {
+			varName := v.Type.TypeName()
+			p, ok := m.EBPFParams[varName]
+			if !ok {
+				continue
+			}
+
+			btfVar, ok := v.Type.(*btf.Var)
+			if !ok {
+				continue
+			}
+			size := v.Size
+			if _, ok := btfhelpers.CharArrayLen(btfVar.Type); ok && !p.IsAddress() && p.DefaultValue != "" {
+				// The default value and its terminating NUL are written into
+				// the array. Defaults too long for it are reported by
+				// validateParamConstraints, count what they'd need.
+				if needed := uint32(len(p.DefaultValue)) + 1; needed > size {
+					size = needed
+				}
+			}
+			total += size
+			sizes = append(sizes, fmt.Sprintf("%s (%d bytes)", varName, size))
+
+			if int(v.Offset+v.Size) > len(contents) {
+				outOfBounds = append(outOfBounds, fmt.Sprintf("%s (%d bytes at offset %d)", varName, v.Size, v.Offset))
+			}
+			if maxParamSize > 0 && size > maxParamSize {
+				tooLarge = append(tooLarge, fmt.Sprintf("%s (%d bytes)", varName, size))
+			}
+		}
+
+		if len(outOfBounds) > 0 {
+			result = multierror.Append(result, fmt.Errorf("params out of the %d bytes of %s: %s",
+				len(contents), name, strings.Join(outOfBounds, ", ")))
+		}
+		if int(total) > len(contents) {
+			result = multierror.Append(result, fmt.Errorf("params take %d bytes but %s holds %d: %s",
+				total, name, len(contents), strings.Join(sizes, ", ")))
+		}
+	}
+
+	if len(tooLarge) > 0 {
+		sort.Strings(tooLarge)
+		result = multierror.Append(result, fmt.Errorf("params larger than the %d bytes allowed: %s",
+			maxParamSize, strings.Join(tooLarge, ", ")))
+	}
+
+	return result
+}
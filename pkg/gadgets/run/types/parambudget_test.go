@@ -0,0 +1,115 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// budgetSpec returns an object whose .rodata section holds a char array of
+// commLen bytes and a __u32, and is sectionSize bytes long
+func budgetSpec(commLen, sectionSize uint32) *ebpf.CollectionSpec {
+	comm := constVar("comm", &btf.Array{Type: charType, Index: u32Type, Nelems: commLen})
+	pid := constVar("pid", u32Type)
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			".rodata": {
+				Name:       ".rodata",
+				Type:       ebpf.Array,
+				KeySize:    4,
+				ValueSize:  sectionSize,
+				MaxEntries: 1,
+				Value: &btf.Datasec{Name: ".rodata", Size: sectionSize, Vars: []btf.VarSecinfo{
+					{Type: comm, Offset: 0, Size: commLen},
+					{Type: pid, Offset: commLen, Size: 4},
+				}},
+				Contents: []ebpf.MapKV{{Key: uint32(0), Value: make([]byte, sectionSize)}},
+			},
+		},
+	}
+}
+
+func TestValidateParamBudget(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		spec              *ebpf.CollectionSpec
+		commDefault       string
+		maxParamSize      uint32
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"fits": {
+			spec:         budgetSpec(16, 20),
+			commDefault:  "bash",
+			maxParamSize: DefaultMaxParamSize,
+		},
+		"no_limit": {
+			spec: budgetSpec(8192, 8196),
+		},
+		"oversized_param": {
+			spec:              budgetSpec(8192, 8196),
+			maxParamSize:      DefaultMaxParamSize,
+			expectedErrString: "params larger than the 4096 bytes allowed: comm (8192 bytes)",
+		},
+		"lower_limit": {
+			spec:              budgetSpec(16, 20),
+			maxParamSize:      8,
+			expectedErrString: "params larger than the 8 bytes allowed: comm (16 bytes)",
+		},
+		"section_too_small": {
+			spec:              budgetSpec(8192, 4096),
+			expectedErrString: "params take 8196 bytes but .rodata holds 4096: comm (8192 bytes), pid (4 bytes)",
+		},
+		"out_of_section": {
+			spec:              budgetSpec(8192, 4096),
+			expectedErrString: "params out of the 4096 bytes of .rodata: comm (8192 bytes at offset 0), pid (4 bytes at offset 8192)",
+		},
+		"default_too_long": {
+			spec:              budgetSpec(4, 8),
+			commDefault:       "systemd",
+			expectedErrString: "params take 12 bytes but .rodata holds 8: comm (8 bytes), pid (4 bytes)",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"comm": {ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: test.commDefault}},
+					"pid":  {ParamDesc: params.ParamDesc{Key: "pid"}},
+				},
+			}
+			err := validateParamBudget(m, newBTFIndex(test.spec), test.maxParamSize)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// validateParamConstraints checks that the min, max, max length, pattern and
+// possible values of eBPF params apply to the type of their variables and that
+// the default values and examples satisfy them. The runtime rejects the values
+// breaking them.
+func validateParamConstraints(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		btfVar, err := idx.varByName(varName)
+		if err != nil {
+			// Already reported by checkParamVar
+			continue
+		}
+		p := m.EBPFParams[varName]
+		if err := validateParamConstraint(varName, &p, btfVar.Type); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func validateParamConstraint(varName string, p *metadatav1.EBPFParam, typ btf.Type) error {
+	if _, ok := btfhelpers.AsEnum(typ); ok {
+		// Values are names or numbers of enumerators, checked by validateEnumParams
+		return nil
+	}
+	if _, _, ok := btfhelpers.IntArray(typ); ok {
+		// Values are lists of integers, checked by validateArrayParams
+		return nil
+	}
+
+	typeHint := btfhelpers.GetTypeHint(typ)
+	if p.IsAddress() || p.HasUnit() {
+		typeHint = p.TypeHint
+	} else if p.HasTargetMap() {
+		// Values are lists of map keys, checked by validateTargetMapParams
+		typeHint = params.TypeString
+	} else if n, ok := btfhelpers.CharArrayLen(typ); ok && n > 0 {
+		if p.MaxLength != 0 && p.MaxLength != n-1 {
+			return fmt.Errorf("param %q: maxLength is %d but the char array holds %d bytes besides the terminating NUL",
+				varName, p.MaxLength, n-1)
+		}
+		p.MaxLength = n - 1
+	}
+
+	validator, err := ParamValidator(p, typeHint)
+	if err != nil {
+		return fmt.Errorf("param %q: %w", varName, err)
+	}
+
+	desc := p.ParamDesc
+	desc.TypeHint = typeHint
+	desc.Validator = validator
+
+	var result error
+
+	// Validate accepts any of the possible values as is
+	possibleValue := desc
+	possibleValue.PossibleValues = nil
+	for _, value := range p.PossibleValues {
+		if err := possibleValue.Validate(value); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: possible value: %w", varName, p.MaskError(err, value)))
+		}
+	}
+
+	for _, v := range checkedValues(p) {
+		if err := desc.Validate(v.value); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %s: %w", varName, v.what, p.MaskError(err, v.value)))
+		}
+	}
+
+	return result
+}
+
+// checkedValue is a value given in the metadata a param must accept
+type checkedValue struct {
+	// what refers to the value in errors
+	what  string
+	value string
+}
+
+// checkedValues returns the default value of the param, if any, and its
+// examples
+func checkedValues(p *metadatav1.EBPFParam) []checkedValue {
+	var values []checkedValue
+	if p.DefaultValue != "" {
+		values = append(values, checkedValue{"default value", p.DefaultValue})
+	}
+	for _, example := range p.Examples {
+		values = append(values, checkedValue{"example", example})
+	}
+	return values
+}
+
+// ParamValidator returns a function checking a value against the min, max, max length and pattern of
+// the param, given the type of its eBPF variable, or nil if it has none of them. It fails if they
+// don't apply to that type.
+func ParamValidator(p *metadatav1.EBPFParam, typeHint params.TypeHint) (params.ParamValidator, error) {
+	var validators []params.ParamValidator
+
+	if p.Min != "" || p.Max != "" {
+		validator, err := rangeValidator(p, typeHint)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, validator)
+	}
+
+	if p.MaxLength > 0 {
+		if typeHint != params.TypeString {
+			return nil, fmt.Errorf("maxLength is only supported by string params, the variable is %s", typeHint)
+		}
+		validators = append(validators, func(value string) error {
+			return lengthValidator(p, value)
+		})
+	}
+
+	if p.Pattern != "" {
+		if typeHint != params.TypeString {
+			return nil, fmt.Errorf("pattern is only supported by string params, the variable is %s", typeHint)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p.Pattern, err)
+		}
+		validators = append(validators, func(value string) error {
+			if !re.MatchString(value) {
+				return fmt.Errorf("must match %q", p.Pattern)
+			}
+			return nil
+		})
+	}
+
+	if len(validators) == 0 {
+		return nil, nil
+	}
+	return func(value string) error {
+		for _, validator := range validators {
+			if err := validator(value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// lengthValidator rejects the values not fitting in the char array instead of
+// truncating them, which could split a multi-byte character
+func lengthValidator(p *metadatav1.EBPFParam, value string) error {
+	if len(value) <= int(p.MaxLength) {
+		return nil
+	}
+	if utf8.RuneCountInString(value) <= int(p.MaxLength) {
+		return fmt.Errorf("must be at most %d bytes long, got %d: multi-byte characters take several bytes",
+			p.MaxLength, len(value))
+	}
+	return fmt.Errorf("must be at most %d bytes long, got %d", p.MaxLength, len(value))
+}
+
+func rangeValidator(p *metadatav1.EBPFParam, typeHint params.TypeHint) (params.ParamValidator, error) {
+	switch typeHint {
+	case params.TypeInt, params.TypeInt64:
+		return boundsValidator(p.Min, p.Max, typeHint, parseInt(64))
+	case params.TypeInt8:
+		return boundsValidator(p.Min, p.Max, typeHint, parseInt(8))
+	case params.TypeInt16:
+		return boundsValidator(p.Min, p.Max, typeHint, parseInt(16))
+	case params.TypeInt32:
+		return boundsValidator(p.Min, p.Max, typeHint, parseInt(32))
+	case params.TypeUint, params.TypeUint64:
+		return boundsValidator(p.Min, p.Max, typeHint, parseUint(64))
+	case params.TypeUint8:
+		return boundsValidator(p.Min, p.Max, typeHint, parseUint(8))
+	case params.TypeUint16:
+		return boundsValidator(p.Min, p.Max, typeHint, parseUint(16))
+	case params.TypeUint32:
+		return boundsValidator(p.Min, p.Max, typeHint, parseUint(32))
+	case params.TypeFloat32:
+		return boundsValidator(p.Min, p.Max, typeHint, parseFloat(32))
+	case params.TypeFloat64:
+		return boundsValidator(p.Min, p.Max, typeHint, parseFloat(64))
+	}
+	return nil, errors.New("min and max are only supported by numeric params")
+}
+
+func parseInt(bitSize int) func(string) (int64, error) {
+	return func(s string) (int64, error) { return strconv.ParseInt(s, 10, bitSize) }
+}
+
+func parseUint(bitSize int) func(string) (uint64, error) {
+	return func(s string) (uint64, error) { return strconv.ParseUint(s, 10, bitSize) }
+}
+
+func parseFloat(bitSize int) func(string) (float64, error) {
+	return func(s string) (float64, error) { return strconv.ParseFloat(s, bitSize) }
+}
+
+func boundsValidator[T int64 | uint64 | float64](min, max string, typeHint params.TypeHint,
+	parse func(string) (T, error),
+) (params.ParamValidator, error) {
+	var lower, upper *T
+	if min != "" {
+		v, err := parse(min)
+		if err != nil {
+			return nil, fmt.Errorf("min %q isn't a valid %s", min, typeHint)
+		}
+		lower = &v
+	}
+	if max != "" {
+		v, err := parse(max)
+		if err != nil {
+			return nil, fmt.Errorf("max %q isn't a valid %s", max, typeHint)
+		}
+		upper = &v
+	}
+	if lower != nil && upper != nil && *lower > *upper {
+		return nil, fmt.Errorf("min %s is greater than max %s", min, max)
+	}
+
+	return func(value string) error {
+		v, err := parse(value)
+		if err != nil {
+			return fmt.Errorf("expected a %s", typeHint)
+		}
+		if (lower == nil || v >= *lower) && (upper == nil || v <= *upper) {
+			return nil
+		}
+		switch {
+		case lower != nil && upper != nil:
+			return fmt.Errorf("must be between %s and %s", min, max)
+		case lower != nil:
+			return fmt.Errorf("must be at least %s", min)
+		default:
+			return fmt.Errorf("must be at most %s", max)
+		}
+	}, nil
+}
@@ -0,0 +1,323 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func paramConstraintsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	return newTestSpec(t,
+		constVar("interval", u32Type),
+		constVar("offset", s32Type),
+		constVar("level", u8Type),
+		constVar("ratio", &btf.Float{Name: "double", Size: 8}),
+		constVar("enabled", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("comm", &btf.Array{Type: charType, Index: u32Type, Nelems: 16}),
+	)
+}
+
+func TestValidateParamConstraints(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		varName           string
+		param             metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_constraints": {
+			varName: "interval",
+			param:   metadatav1.EBPFParam{ParamDesc: params.ParamDesc{DefaultValue: "0"}},
+		},
+		"range": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "10"},
+				Min:       "1",
+				Max:       "3600",
+			},
+		},
+		"negative_range": {
+			varName: "offset",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "-5"},
+				Min:       "-10",
+				Max:       "10",
+			},
+		},
+		"float_range": {
+			varName: "ratio",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "0.5"},
+				Min:       "0",
+				Max:       "1",
+			},
+		},
+		"default_below_min": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "interval", DefaultValue: "0"},
+				Min:       "1",
+				Max:       "3600",
+			},
+			expectedErrString: "param \"interval\": default value: invalid value \"0\" as \"interval\": must be between 1 and 3600",
+		},
+		"default_above_max": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "interval", DefaultValue: "4000"},
+				Max:       "3600",
+			},
+			expectedErrString: "must be at most 3600",
+		},
+		"min_greater_than_max": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				Min: "10",
+				Max: "1",
+			},
+			expectedErrString: "param \"interval\": min 10 is greater than max 1",
+		},
+		"negative_min_for_unsigned": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				Min: "-1",
+			},
+			expectedErrString: "min \"-1\" isn't a valid uint32",
+		},
+		"max_overflowing_type": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				Max: "300",
+			},
+			expectedErrString: "max \"300\" isn't a valid uint8",
+		},
+		"range_on_bool": {
+			varName: "enabled",
+			param: metadatav1.EBPFParam{
+				Max: "1",
+			},
+			expectedErrString: "min and max are only supported by numeric params",
+		},
+		"pattern": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "nginx"},
+				Pattern:   "^[a-z]+$",
+			},
+		},
+		"default_not_matching_pattern": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: "NGINX"},
+				Pattern:   "^[a-z]+$",
+			},
+			expectedErrString: "default value: invalid value \"NGINX\" as \"comm\": must match \"^[a-z]+$\"",
+		},
+		"sensitive_default_not_matching_pattern": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: "NGINX"},
+				Pattern:   "^[a-z]+$",
+				Sensitive: true,
+			},
+			expectedErrString: "param \"comm\": default value: invalid value \"***\" as \"comm\": must match \"^[a-z]+$\"",
+		},
+		"sensitive_possible_value_out_of_range": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "level", PossibleValues: []string{"1", "5"}},
+				Max:       "3",
+				Sensitive: true,
+			},
+			expectedErrString: "possible value: invalid value \"***\" as \"level\": must be at most 3",
+		},
+		"examples": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "interval", DefaultValue: "10"},
+				Min:       "1",
+				Max:       "3600",
+				Examples:  []string{"1", "60"},
+			},
+		},
+		"example_out_of_range": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "interval", DefaultValue: "10"},
+				Min:       "1",
+				Max:       "3600",
+				Examples:  []string{"60", "7200"},
+			},
+			expectedErrString: "param \"interval\": example: invalid value \"7200\" as \"interval\": must be between 1 and 3600",
+		},
+		"example_of_wrong_type": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "interval"},
+				Examples:  []string{"1m"},
+			},
+			expectedErrString: "param \"interval\": example: invalid value \"1m\" as \"interval\"",
+		},
+		"example_not_matching_pattern": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm"},
+				Pattern:   "^[a-z]+$",
+				Examples:  []string{"nginx", "NGINX"},
+			},
+			expectedErrString: "param \"comm\": example: invalid value \"NGINX\" as \"comm\": must match",
+		},
+		"invalid_pattern": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				Pattern: "[a-z",
+			},
+			expectedErrString: "invalid pattern \"[a-z\"",
+		},
+		"pattern_on_integer": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				Pattern: "^[0-9]+$",
+			},
+			expectedErrString: "pattern is only supported by string params, the variable is uint32",
+		},
+		"string_at_limit": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: "abcdefghijklmno"},
+			},
+		},
+		"string_over_limit": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: "abcdefghijklmnop"},
+			},
+			expectedErrString: "default value: invalid value \"abcdefghijklmnop\" as \"comm\": must be at most 15 bytes long, got 16",
+		},
+		"multi_byte_string_over_limit": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "comm", DefaultValue: "ééééééééé"},
+			},
+			expectedErrString: "must be at most 15 bytes long, got 18: multi-byte characters take several bytes",
+		},
+		"wrong_max_length": {
+			varName: "comm",
+			param: metadatav1.EBPFParam{
+				MaxLength: 31,
+			},
+			expectedErrString: "param \"comm\": maxLength is 31 but the char array holds 15 bytes besides the terminating NUL",
+		},
+		"max_length_on_integer": {
+			varName: "interval",
+			param: metadatav1.EBPFParam{
+				MaxLength: 15,
+			},
+			expectedErrString: "maxLength is only supported by string params, the variable is uint32",
+		},
+		"possible_values": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{DefaultValue: "2", PossibleValues: []string{"1", "2", "3"}},
+			},
+		},
+		"default_not_possible": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "level", DefaultValue: "4", PossibleValues: []string{"1", "2", "3"}},
+			},
+			expectedErrString: "default value: invalid value \"4\" as \"level\": valid values are: 1, 2, 3",
+		},
+		"possible_value_of_wrong_type": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "level", PossibleValues: []string{"1", "high"}},
+			},
+			expectedErrString: "possible value: invalid value \"high\" as \"level\"",
+		},
+		"possible_value_out_of_range": {
+			varName: "level",
+			param: metadatav1.EBPFParam{
+				ParamDesc: params.ParamDesc{Key: "level", PossibleValues: []string{"1", "5"}},
+				Max:       "3",
+			},
+			expectedErrString: "possible value: invalid value \"5\" as \"level\": must be at most 3",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{test.varName: test.param},
+			}
+			err := validateParamConstraints(m, newBTFIndex(paramConstraintsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestPopulateStringParam(t *testing.T) {
+	t.Parallel()
+
+	constVoidPtr := &btf.Pointer{Target: &btf.Const{Type: &btf.Void{}}}
+	spec := newTestSpec(t,
+		&btf.Var{
+			Name:    "comm",
+			Type:    &btf.Array{Type: &btf.Const{Type: &btf.Volatile{Type: charType}}, Index: u32Type, Nelems: 16},
+			Linkage: btf.GlobalVar,
+		},
+		&btf.Var{Name: "gadget_param_comm", Type: constVoidPtr, Linkage: btf.GlobalVar},
+	)
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, populateEbpfParams(m, newBTFIndex(spec), &PopulateReport{}))
+	require.Equal(t, metadatav1.EBPFParam{
+		ParamDesc: params.ParamDesc{
+			Key:         "comm",
+			Description: paramDescTODO,
+			TypeHint:    params.TypeString,
+		},
+		MaxLength: 15,
+	}, m.EBPFParams["comm"])
+
+	// The max length is recorded for params already in the metadata too
+	m = &metadatav1.GadgetMetadata{
+		EBPFParams: map[string]metadatav1.EBPFParam{
+			"comm": {ParamDesc: params.ParamDesc{Key: "comm", Description: "Command to trace"}},
+		},
+	}
+	require.NoError(t, populateEbpfParams(m, newBTFIndex(spec), &PopulateReport{}))
+	require.Equal(t, uint32(15), m.EBPFParams["comm"].MaxLength)
+	require.Equal(t, "Command to trace", m.EBPFParams["comm"].Description)
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// validateParamDependencies checks the params listed by requires and
+// conflictsWith: they must be keys of other params, a param can't both require
+// and conflict with the same one, and requirements can't form a cycle, as
+// none of the params of the cycle could be set first.
+func validateParamDependencies(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	varNames := sortedKeys(m.EBPFParams)
+
+	// key -> params it requires
+	requires := make(map[string][]string)
+	for _, varName := range varNames {
+		requires[m.EBPFParams[varName].Key] = nil
+	}
+
+	checkKeys := func(varName, field string, keys []string) {
+		p := m.EBPFParams[varName]
+		for _, key := range keys {
+			if _, ok := requires[key]; !ok {
+				result = multierror.Append(result, fmt.Errorf("param %q: %s: no param has key %q", varName, field, key))
+			} else if key == p.Key {
+				result = multierror.Append(result, fmt.Errorf("param %q: %s: param can't refer to itself", varName, field))
+			}
+		}
+	}
+
+	for _, varName := range varNames {
+		p := m.EBPFParams[varName]
+
+		checkKeys(varName, "requires", p.Requires)
+		checkKeys(varName, "conflictsWith", p.ConflictsWith)
+
+		for _, key := range p.Requires {
+			if slices.Contains(p.ConflictsWith, key) {
+				result = multierror.Append(result, fmt.Errorf("param %q both requires and conflicts with %q", varName, key))
+			}
+		}
+
+		if _, ok := requires[p.Key]; ok {
+			requires[p.Key] = append(requires[p.Key], p.Requires...)
+		}
+	}
+
+	if cycle := findRequiresCycle(requires); cycle != nil {
+		result = multierror.Append(result, fmt.Errorf("params require each other in a cycle: %s", strings.Join(cycle, " -> ")))
+	}
+
+	return result
+}
+
+// findRequiresCycle returns the keys of a cycle of requirements, starting and
+// ending with the same key, or nil if there is none
+func findRequiresCycle(requires map[string][]string) []string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var path []string
+
+	var visit func(key string) []string
+	visit = func(key string) []string {
+		switch state[key] {
+		case visiting:
+			start := slices.Index(path, key)
+			return append(slices.Clone(path[start:]), key)
+		case visited:
+			return nil
+		}
+		state[key] = visiting
+		path = append(path, key)
+		for _, required := range requires[key] {
+			if _, ok := requires[required]; !ok || required == key {
+				// Reported by validateParamDependencies
+				continue
+			}
+			if cycle := visit(required); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[key] = visited
+		return nil
+	}
+
+	for _, key := range sortedKeys(requires) {
+		if cycle := visit(key); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestValidateParamDependencies(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		params            map[string]metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	param := func(key string, requires, conflictsWith []string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{
+			ParamDesc:     params.ParamDesc{Key: key},
+			Requires:      requires,
+			ConflictsWith: conflictsWith,
+		}
+	}
+
+	tests := map[string]testCase{
+		"dependencies": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_latency":   param("latency", nil, nil),
+				"targ_threshold": param("threshold", []string{"latency"}, nil),
+				"targ_min":       param("min", []string{"threshold"}, nil),
+				"targ_pid":       param("pid", nil, []string{"netns"}),
+				"targ_netns":     param("netns", nil, []string{"pid"}),
+			},
+		},
+		"unknown_key": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_threshold": param("threshold", []string{"latncy"}, nil),
+			},
+			expectedErrString: "param \"targ_threshold\": requires: no param has key \"latncy\"",
+		},
+		"variable_name": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_pid":   param("pid", nil, []string{"targ_netns"}),
+				"targ_netns": param("netns", nil, nil),
+			},
+			expectedErrString: "param \"targ_pid\": conflictsWith: no param has key \"targ_netns\"",
+		},
+		"self_reference": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_pid": param("pid", nil, []string{"pid"}),
+			},
+			expectedErrString: "param \"targ_pid\": conflictsWith: param can't refer to itself",
+		},
+		"requires_and_conflicts": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_pid":   param("pid", []string{"netns"}, []string{"netns"}),
+				"targ_netns": param("netns", nil, nil),
+			},
+			expectedErrString: "param \"targ_pid\" both requires and conflicts with \"netns\"",
+		},
+		"cycle": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_a": param("a", []string{"b"}, nil),
+				"targ_b": param("b", []string{"c"}, nil),
+				"targ_c": param("c", []string{"a"}, nil),
+			},
+			expectedErrString: "params require each other in a cycle: a -> b -> c -> a",
+		},
+		"cycle_after_chain": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_a": param("a", []string{"b"}, nil),
+				"targ_b": param("b", []string{"c"}, nil),
+				"targ_c": param("c", []string{"b"}, nil),
+			},
+			expectedErrString: "params require each other in a cycle: b -> c -> b",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{EBPFParams: test.params}
+			err := validateParamDependencies(m)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	uprobeSection    = "uprobe"
+	uretprobeSection = "uretprobe"
+)
+
+// uprobeSectionKind returns whether the section name is the one of a uprobe
+// and if it's a uretprobe. Sections can set the target, like
+// "uprobe/libc:malloc", or leave it to the metadata, like "uprobe".
+func uprobeSectionKind(sectionName string) (uprobe bool, retprobe bool) {
+	kind, _, _ := strings.Cut(sectionName, "/")
+	switch kind {
+	case uprobeSection:
+		return true, false
+	case uretprobeSection:
+		return true, true
+	}
+	return false, false
+}
+
+func validatePrograms(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Programs) {
+		if err := validateProgram(m, spec, name, m.Programs[name]); err != nil {
+			result = multierror.Append(result, fmt.Errorf("validating program %q: %w", name, err))
+		}
+	}
+
+	for _, structName := range sortedKeys(m.Structs) {
+		for _, field := range m.Structs[structName].Fields {
+			if field.FilledBy == "" {
+				continue
+			}
+			if !m.Programs[field.FilledBy].Optional && attachingParam(m, field.FilledBy) == "" {
+				result = multierror.Append(result, fmt.Errorf("field %q of struct %q: filledBy %q isn't an optional program or one attached by a param",
+					field.Name, structName, field.FilledBy))
+			}
+		}
+	}
+
+	return result
+}
+
+func validateProgram(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, name string, program metadatav1.Program) (result error) {
+	p, ok := spec.Programs[name]
+	if !ok {
+		return fmt.Errorf("program %q not found in eBPF object", name)
+	}
+
+	if err := validateProgramGates(m, program); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	uprobe, retprobe := uprobeSectionKind(p.SectionName)
+	// Programs only made optional don't need to be uprobes, but uprobes
+	// whose section doesn't set where they attach to need the metadata to
+	attachSet := program.Target != "" || program.Symbol != "" || program.Retprobe
+	if !attachSet && (p.Type != ebpf.Kprobe || !uprobe || p.AttachTo != "") {
+		return
+	}
+	if p.Type != ebpf.Kprobe || !uprobe {
+		return fmt.Errorf("program %q is %s with section %q, expected a uprobe or uretprobe",
+			name, p.Type, p.SectionName)
+	}
+	// Bare sections don't tell whether they are retprobes
+	if p.AttachTo != "" && program.Retprobe != retprobe {
+		result = multierror.Append(result, fmt.Errorf("retprobe is %t but section %q is a %s",
+			program.Retprobe, p.SectionName, strings.Split(p.SectionName, "/")[0]))
+	}
+
+	if program.Symbol == "" {
+		result = multierror.Append(result, errors.New("missing symbol"))
+	}
+
+	switch {
+	case program.Target == "":
+		result = multierror.Append(result, errors.New("missing target"))
+	case containertemplate.IsTemplate(program.Target):
+		if err := containertemplate.Validate(program.Target); err != nil {
+			result = multierror.Append(result, fmt.Errorf("target: %w", err))
+		}
+	case !filepath.IsAbs(program.Target) && strings.Contains(program.Target, "/"):
+		result = multierror.Append(result, fmt.Errorf("target %q must be either an absolute path or a library name", program.Target))
+	}
+
+	return
+}
+
+// validateProgramGates checks the gates of optional programs: the kernel
+// version and the boolean param enabling them
+func validateProgramGates(m *metadatav1.GadgetMetadata, program metadatav1.Program) (result error) {
+	if !program.Optional && (program.MinKernel != "" || program.EnabledByParam != "") {
+		result = multierror.Append(result, errors.New("minKernel and enabledByParam require optional to be set"))
+	}
+
+	if program.MinKernel != "" {
+		if _, err := gadgets.ParseKernelVersion(program.MinKernel); err != nil {
+			result = multierror.Append(result, fmt.Errorf("minKernel: %w", err))
+		}
+	}
+
+	if program.EnabledByParam != "" {
+		param, ok := findParam(m, program.EnabledByParam)
+		switch {
+		case !ok:
+			result = multierror.Append(result, fmt.Errorf("enabledByParam: param %q not found", program.EnabledByParam))
+		case param.TypeHint != "" && param.TypeHint != params.TypeBool:
+			result = multierror.Append(result, fmt.Errorf("enabledByParam: param %q is %s, expected %s",
+				program.EnabledByParam, param.TypeHint, params.TypeBool))
+		}
+	}
+
+	return
+}
+
+// validateParamAttaches checks the programs attached by params: they must
+// exist, be attached by a single param, and the params must be bools, as the
+// programs are skipped when they are false
+func validateParamAttaches(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+
+	// program -> variable of the param attaching it
+	attachedBy := make(map[string]string)
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		p := m.EBPFParams[varName]
+		if len(p.Attaches) == 0 {
+			continue
+		}
+
+		isBool := p.TypeHint == params.TypeBool
+		if btfVar, err := idx.varByName(varName); err == nil && p.TypeHint == params.TypeUnknown {
+			isBool = btfhelpers.IsBool(btfVar.Type)
+		}
+		if !isBool {
+			result = multierror.Append(result, fmt.Errorf("param %q: attaches requires a %s param", varName, params.TypeBool))
+		}
+
+		for _, program := range p.Attaches {
+			if _, ok := idx.spec.Programs[program]; !ok {
+				result = multierror.Append(result, fmt.Errorf("param %q: attaches: program %q not found in eBPF object", varName, program))
+			} else if owner, ok := attachedBy[program]; ok {
+				result = multierror.Append(result, fmt.Errorf("param %q: attaches: program %q is already attached by param %q",
+					varName, program, owner))
+			} else {
+				attachedBy[program] = varName
+			}
+		}
+	}
+
+	return result
+}
+
+// attachingParam returns the variable of the param attaching the program, if
+// any
+func attachingParam(m *metadatav1.GadgetMetadata, program string) string {
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		if slices.Contains(m.EBPFParams[varName].Attaches, program) {
+			return varName
+		}
+	}
+	return ""
+}
+
+// findParam returns the eBPF or gadget param with the given key
+func findParam(m *metadatav1.GadgetMetadata, key string) (params.ParamDesc, bool) {
+	for _, name := range sortedKeys(m.EBPFParams) {
+		if p := m.EBPFParams[name].ParamDesc; p.Key == key {
+			return p, true
+		}
+	}
+	for _, name := range sortedKeys(m.GadgetParams) {
+		if p := m.GadgetParams[name]; p.Key == key || (p.Key == "" && name == key) {
+			return p, true
+		}
+	}
+	return params.ParamDesc{}, false
+}
+
+// populatePrograms adds the uprobes whose section name sets where they attach
+// to, like "uprobe/libssl.so:SSL_read"
+func populatePrograms(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec) {
+	for _, name := range sortedKeys(spec.Programs) {
+		p := spec.Programs[name]
+		uprobe, retprobe := uprobeSectionKind(p.SectionName)
+		if p.Type != ebpf.Kprobe || !uprobe || p.AttachTo == "" {
+			continue
+		}
+
+		target, symbol, ok := strings.Cut(p.AttachTo, ":")
+		if !ok {
+			log.Debugf("Program %q: section %q doesn't set a symbol, skipping", name, p.SectionName)
+			continue
+		}
+
+		if m.Programs == nil {
+			m.Programs = make(map[string]metadatav1.Program)
+		}
+		if _, ok := m.Programs[name]; ok {
+			log.Debugf("Program %q already defined, skipping", name)
+			continue
+		}
+		log.Debugf("Adding program %q", name)
+		m.Programs[name] = metadatav1.Program{
+			Target:   target,
+			Symbol:   symbol,
+			Retprobe: retprobe,
+		}
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func programsSpec() *ebpf.CollectionSpec {
+	return &ebpf.CollectionSpec{
+		Programs: map[string]*ebpf.ProgramSpec{
+			"ssl_read": {
+				Name:        "ssl_read",
+				Type:        ebpf.Kprobe,
+				SectionName: "uprobe/libssl.so:SSL_read",
+				AttachTo:    "libssl.so:SSL_read",
+			},
+			"ssl_read_ret": {
+				Name:        "ssl_read_ret",
+				Type:        ebpf.Kprobe,
+				SectionName: "uretprobe/libssl.so:SSL_read",
+				AttachTo:    "libssl.so:SSL_read",
+			},
+			"entrypoint": {
+				Name:        "entrypoint",
+				Type:        ebpf.Kprobe,
+				SectionName: "uprobe",
+			},
+			"do_unlinkat": {
+				Name:        "do_unlinkat",
+				Type:        ebpf.Kprobe,
+				SectionName: "kprobe/do_unlinkat",
+				AttachTo:    "do_unlinkat",
+			},
+			"usdt_probe": {
+				Name:        "usdt_probe",
+				Type:        ebpf.Kprobe,
+				SectionName: "usdt/libc:libc:setjmp",
+				AttachTo:    "libc:libc:setjmp",
+			},
+		},
+	}
+}
+
+func TestPopulatePrograms(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{
+		Programs: map[string]metadatav1.Program{
+			// Already defined programs aren't overwritten
+			"ssl_read_ret": {Target: "/usr/lib/libssl.so.3", Symbol: "SSL_read", Retprobe: true},
+		},
+	}
+	populatePrograms(m, programsSpec())
+	require.Equal(t, map[string]metadatav1.Program{
+		"ssl_read":     {Target: "libssl.so", Symbol: "SSL_read"},
+		"ssl_read_ret": {Target: "/usr/lib/libssl.so.3", Symbol: "SSL_read", Retprobe: true},
+	}, m.Programs)
+	require.NoError(t, validatePrograms(m, programsSpec()))
+}
+
+func TestValidatePrograms(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		name              string
+		program           metadatav1.Program
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"good": {
+			name:    "ssl_read",
+			program: metadatav1.Program{Target: "libssl.so", Symbol: "SSL_read"},
+		},
+		"bare_section": {
+			name:    "entrypoint",
+			program: metadatav1.Program{Target: "/bin/bash", Symbol: "readline", Retprobe: true},
+		},
+		"template": {
+			name:    "entrypoint",
+			program: metadatav1.Program{Target: "{{ .Container.Image.Entrypoint }}", Symbol: "main"},
+		},
+		"missing_program": {
+			name:              "ssl_write",
+			program:           metadatav1.Program{Target: "libssl.so", Symbol: "SSL_write"},
+			expectedErrString: "program \"ssl_write\" not found in eBPF object",
+		},
+		"kprobe": {
+			name:              "do_unlinkat",
+			program:           metadatav1.Program{Target: "libc", Symbol: "unlink"},
+			expectedErrString: "expected a uprobe or uretprobe",
+		},
+		"usdt": {
+			name:              "usdt_probe",
+			program:           metadatav1.Program{Target: "libc", Symbol: "setjmp"},
+			expectedErrString: "expected a uprobe or uretprobe",
+		},
+		"wrong_retprobe": {
+			name:              "ssl_read",
+			program:           metadatav1.Program{Target: "libssl.so", Symbol: "SSL_read", Retprobe: true},
+			expectedErrString: "retprobe is true but section \"uprobe/libssl.so:SSL_read\" is a uprobe",
+		},
+		"missing_symbol": {
+			name:              "entrypoint",
+			program:           metadatav1.Program{Target: "libssl.so"},
+			expectedErrString: "missing symbol",
+		},
+		"missing_target": {
+			name:              "entrypoint",
+			program:           metadatav1.Program{Symbol: "SSL_read"},
+			expectedErrString: "missing target",
+		},
+		"relative_target": {
+			name:              "entrypoint",
+			program:           metadatav1.Program{Target: "lib/libssl.so", Symbol: "SSL_read"},
+			expectedErrString: "target \"lib/libssl.so\" must be either an absolute path or a library name",
+		},
+		"invalid_template": {
+			name:              "entrypoint",
+			program:           metadatav1.Program{Target: "{{ .Container.Foo }}", Symbol: "main"},
+			expectedErrString: "target:",
+		},
+		"optional_kprobe": {
+			name:    "do_unlinkat",
+			program: metadatav1.Program{Optional: true, MinKernel: "5.15", EnabledByParam: "verbose"},
+		},
+		"optional_uprobe_section": {
+			name:    "ssl_read",
+			program: metadatav1.Program{Optional: true},
+		},
+		"optional_gadget_param": {
+			name:    "do_unlinkat",
+			program: metadatav1.Program{Optional: true, EnabledByParam: "details"},
+		},
+		"gate_without_optional": {
+			name:              "do_unlinkat",
+			program:           metadatav1.Program{MinKernel: "5.15"},
+			expectedErrString: "minKernel and enabledByParam require optional to be set",
+		},
+		"invalid_min_kernel": {
+			name:              "do_unlinkat",
+			program:           metadatav1.Program{Optional: true, MinKernel: "5.x"},
+			expectedErrString: "minKernel: invalid kernel version \"5.x\"",
+		},
+		"unknown_param": {
+			name:              "do_unlinkat",
+			program:           metadatav1.Program{Optional: true, EnabledByParam: "foo"},
+			expectedErrString: "enabledByParam: param \"foo\" not found",
+		},
+		"non_bool_param": {
+			name:              "do_unlinkat",
+			program:           metadatav1.Program{Optional: true, EnabledByParam: "count"},
+			expectedErrString: "enabledByParam: param \"count\" is uint32, expected bool",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				Programs: map[string]metadatav1.Program{
+					test.name: test.program,
+				},
+				EBPFParams: map[string]metadatav1.EBPFParam{
+					"gadget_verbose": {ParamDesc: params.ParamDesc{Key: "verbose", TypeHint: params.TypeBool}},
+					"gadget_count":   {ParamDesc: params.ParamDesc{Key: "count", TypeHint: params.TypeUint32}},
+				},
+				GadgetParams: map[string]params.ParamDesc{
+					"details": {Key: "details"},
+				},
+			}
+
+			err := validatePrograms(m, programsSpec())
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestValidateFilledBy(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{
+		Programs: map[string]metadatav1.Program{
+			"do_unlinkat": {Optional: true},
+			"ssl_read":    {Target: "libssl.so", Symbol: "SSL_read"},
+		},
+		Structs: map[string]metadatav1.Struct{
+			"event": {
+				Fields: []metadatav1.Field{
+					{Name: "flags", FilledBy: "do_unlinkat"},
+					{Name: "len", FilledBy: "ssl_read"},
+					{Name: "pid"},
+				},
+			},
+		},
+	}
+
+	err := validatePrograms(m, programsSpec())
+	require.ErrorContains(t, err, "field \"len\" of struct \"event\": filledBy \"ssl_read\" isn't an optional program")
+	require.NotContains(t, err.Error(), "flags")
+
+	// Programs attached by params can fill fields too
+	m.EBPFParams = map[string]metadatav1.EBPFParam{
+		"trace_ssl": {ParamDesc: params.ParamDesc{Key: "trace-ssl", TypeHint: params.TypeBool}, Attaches: []string{"ssl_read"}},
+	}
+	require.NoError(t, validatePrograms(m, programsSpec()))
+}
+
+func TestValidateParamAttaches(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		params            map[string]metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	param := func(key string, typeHint params.TypeHint, attaches ...string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{
+			ParamDesc: params.ParamDesc{Key: key, TypeHint: typeHint},
+			Attaches:  attaches,
+		}
+	}
+
+	tests := map[string]testCase{
+		"attaches": {
+			params: map[string]metadatav1.EBPFParam{
+				"trace_ssl":     param("trace-ssl", params.TypeBool, "ssl_read", "ssl_read_ret"),
+				"trace_unlinks": param("trace-unlinks", params.TypeBool, "do_unlinkat"),
+			},
+		},
+		"unknown_program": {
+			params: map[string]metadatav1.EBPFParam{
+				"trace_ssl": param("trace-ssl", params.TypeBool, "ssl_write"),
+			},
+			expectedErrString: "param \"trace_ssl\": attaches: program \"ssl_write\" not found in eBPF object",
+		},
+		"not_bool": {
+			params: map[string]metadatav1.EBPFParam{
+				"targ_pid": param("pid", params.TypeUint32, "do_unlinkat"),
+			},
+			expectedErrString: "param \"targ_pid\": attaches requires a bool param",
+		},
+		"attached_twice": {
+			params: map[string]metadatav1.EBPFParam{
+				"trace_ssl":  param("trace-ssl", params.TypeBool, "ssl_read"),
+				"trace_read": param("trace-read", params.TypeBool, "ssl_read"),
+			},
+			expectedErrString: "param \"trace_ssl\": attaches: program \"ssl_read\" is already attached by param \"trace_read\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{EBPFParams: test.params}
+			err := validateParamAttaches(m, newBTFIndex(programsSpec()))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cilium/ebpf"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// SkipReason is the reason why Populate didn't add a field for a member
+type SkipReason string
+
+const (
+	// SkipReasonExists is used for members with a field in the metadata
+	// already
+	SkipReasonExists SkipReason = "already exists"
+	// SkipReasonPadding is used for members only aligning the next one, see
+	// WithAllMembers
+	SkipReasonPadding SkipReason = "padding"
+)
+
+// SkippedMember is a member of a struct Populate didn't add a field for
+type SkippedMember struct {
+	Member string
+	Reason SkipReason
+}
+
+// PopulateReport describes what Populate found in the eBPF object and what it
+// added to the metadata
+type PopulateReport struct {
+	// Tracers, toppers, profilers and snapshotters found in the eBPF object,
+	// including the ones already in the metadata
+	Tracers      []string
+	Toppers      []string
+	Profilers    []string
+	Snapshotters []string
+
+	// FieldsAdded holds the fields added to each struct
+	FieldsAdded map[string][]string
+	// Skipped holds the members of each struct without a new field
+	Skipped map[string][]SkippedMember
+
+	// ParamsAdded holds the eBPF params added
+	ParamsAdded []string
+
+	// Warnings holds guesses the author should confirm, like members
+	// recognized as IP addresses
+	Warnings []string
+}
+
+// PopulateWithReport is like Populate but also returns a report of what it did
+func PopulateWithReport(m *metadatav1.GadgetMetadata, spec *ebpf.CollectionSpec, opts ...PopulateOption) (*PopulateReport, error) {
+	report := newPopulateReport()
+	if err := populate(m, spec, report, opts...); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func newPopulateReport() *PopulateReport {
+	return &PopulateReport{
+		FieldsAdded: make(map[string][]string),
+		Skipped:     make(map[string][]SkippedMember),
+	}
+}
+
+// The methods recording entries are no-ops on a nil report, so the populate
+// functions can be called without one
+
+func (r *PopulateReport) addTracer(name string) {
+	if r != nil {
+		r.Tracers = append(r.Tracers, name)
+	}
+}
+
+func (r *PopulateReport) addTopper(name string) {
+	if r != nil {
+		r.Toppers = append(r.Toppers, name)
+	}
+}
+
+func (r *PopulateReport) addProfiler(name string) {
+	if r != nil {
+		r.Profilers = append(r.Profilers, name)
+	}
+}
+
+func (r *PopulateReport) addSnapshotter(name string) {
+	if r != nil {
+		r.Snapshotters = append(r.Snapshotters, name)
+	}
+}
+
+func (r *PopulateReport) addField(structName, field string) {
+	if r != nil {
+		r.FieldsAdded[structName] = append(r.FieldsAdded[structName], field)
+	}
+}
+
+func (r *PopulateReport) skipMember(structName, member string, reason SkipReason) {
+	if r != nil {
+		r.Skipped[structName] = append(r.Skipped[structName], SkippedMember{Member: member, Reason: reason})
+	}
+}
+
+func (r *PopulateReport) addParam(name string) {
+	if r != nil {
+		r.ParamsAdded = append(r.ParamsAdded, name)
+	}
+}
+
+func (r *PopulateReport) addWarning(warning string) {
+	if r != nil {
+		r.Warnings = append(r.Warnings, warning)
+	}
+}
+
+// String returns a summary of the report, one line per section
+func (r *PopulateReport) String() string {
+	var sb strings.Builder
+
+	writeList := func(section string, names []string) {
+		if len(names) > 0 {
+			fmt.Fprintf(&sb, "%s: %s\n", section, strings.Join(names, ", "))
+		}
+	}
+
+	writeList("tracers", r.Tracers)
+	writeList("toppers", r.Toppers)
+	writeList("profilers", r.Profilers)
+	writeList("snapshotters", r.Snapshotters)
+
+	structNames := make([]string, 0, len(r.FieldsAdded)+len(r.Skipped))
+	for name := range r.FieldsAdded {
+		structNames = append(structNames, name)
+	}
+	for name := range r.Skipped {
+		if _, ok := r.FieldsAdded[name]; !ok {
+			structNames = append(structNames, name)
+		}
+	}
+	sort.Strings(structNames)
+
+	for _, name := range structNames {
+		writeList(fmt.Sprintf("structs.%s: added fields", name), r.FieldsAdded[name])
+
+		skipped := make([]string, 0, len(r.Skipped[name]))
+		for _, s := range r.Skipped[name] {
+			skipped = append(skipped, fmt.Sprintf("%s (%s)", s.Member, s.Reason))
+		}
+		writeList(fmt.Sprintf("structs.%s: skipped members", name), skipped)
+	}
+
+	writeList("ebpfParams: added", r.ParamsAdded)
+
+	for _, w := range r.Warnings {
+		fmt.Fprintf(&sb, "warning: %s\n", w)
+	}
+
+	return sb.String()
+}
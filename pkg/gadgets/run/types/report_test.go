@@ -0,0 +1,149 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestPopulateWithReport(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		objectPath      string
+		initialMetadata *metadatav1.GadgetMetadata
+		expected        *PopulateReport
+		expectedString  string
+	}
+
+	tests := map[string]testCase{
+		"tracer_from_scratch": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+			expected: &PopulateReport{
+				Tracers:     []string{"test"},
+				FieldsAdded: map[string][]string{"event": {"pid", "comm", "filename"}},
+				Skipped:     map[string][]SkippedMember{},
+			},
+			expectedString: "tracers: test\n" +
+				"structs.event: added fields: pid, comm, filename\n",
+		},
+		"tracer_existing_field": {
+			objectPath: "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o",
+			initialMetadata: &metadatav1.GadgetMetadata{
+				Structs: map[string]metadatav1.Struct{
+					"event": {Fields: []metadatav1.Field{{Name: "comm"}}},
+				},
+			},
+			expected: &PopulateReport{
+				Tracers:     []string{"test"},
+				FieldsAdded: map[string][]string{"event": {"pid", "filename"}},
+				Skipped: map[string][]SkippedMember{
+					"event": {{Member: "comm", Reason: SkipReasonExists}},
+				},
+			},
+			expectedString: "tracers: test\n" +
+				"structs.event: added fields: pid, filename\n" +
+				"structs.event: skipped members: comm (already exists)\n",
+		},
+		"topper": {
+			objectPath: "../../../../testdata/populate_metadata_1_topper_1_struct_from_scratch.o",
+			expected: &PopulateReport{
+				Toppers:     []string{"my_topper"},
+				FieldsAdded: map[string][]string{"event": {"pid", "comm", "filename"}},
+				Skipped:     map[string][]SkippedMember{},
+			},
+		},
+		"snapshotter": {
+			objectPath: "../../../../testdata/populate_metadata_snapshotter_struct.o",
+			expected: &PopulateReport{
+				Snapshotters: []string{"events"},
+				FieldsAdded:  map[string][]string{"event": {"pid", "comm", "filename"}},
+				Skipped:      map[string][]SkippedMember{},
+			},
+		},
+		"param": {
+			objectPath: "../../../../testdata/populate_metadata_1_param_from_scratch.o",
+			expected: &PopulateReport{
+				FieldsAdded: map[string][]string{},
+				Skipped:     map[string][]SkippedMember{},
+				ParamsAdded: []string{"param"},
+			},
+			expectedString: "ebpfParams: added: param\n",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			spec, err := ebpf.LoadCollectionSpec(test.objectPath)
+			require.NoError(t, err)
+
+			m := test.initialMetadata
+			if m == nil {
+				m = &metadatav1.GadgetMetadata{}
+			}
+
+			report, err := PopulateWithReport(m, spec)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, report)
+			if test.expectedString != "" {
+				require.Equal(t, test.expectedString, report.String())
+			}
+		})
+	}
+}
+
+func TestPopulateStructReportPadding(t *testing.T) {
+	t.Parallel()
+
+	u8 := &btf.Int{Name: "__u8", Size: 1}
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	event := &btf.Struct{
+		Name: "event",
+		Size: 8,
+		Members: []btf.Member{
+			{Name: "flag", Type: u8},
+			{Name: "__pad", Type: &btf.Array{Type: u8, Index: u32, Nelems: 3}, Offset: 8},
+			{Name: "pid", Type: u32, Offset: 32},
+		},
+	}
+
+	m := &metadatav1.GadgetMetadata{
+		Structs: map[string]metadatav1.Struct{
+			"event": {Fields: []metadatav1.Field{{Name: "pid"}}},
+		},
+	}
+	report := &PopulateReport{
+		FieldsAdded: make(map[string][]string),
+		Skipped:     make(map[string][]SkippedMember),
+	}
+	require.NoError(t, populateStruct(m, event, nil, populateOptions{}, report))
+
+	require.Equal(t, map[string][]string{"event": {"flag"}}, report.FieldsAdded)
+	require.Equal(t, map[string][]SkippedMember{
+		"event": {
+			{Member: "__pad", Reason: SkipReasonPadding},
+			{Member: "pid", Reason: SkipReasonExists},
+		},
+	}, report.Skipped)
+}
@@ -0,0 +1,430 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"gopkg.in/yaml.v3"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// schemaDraft is the version of JSON Schema the schema of the metadata follows
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// integerKeyPattern is the one of the keys of maps indexed by integers, like
+// the values of a field
+const integerKeyPattern = "^-?[0-9]+$"
+
+// jsonSchema is the subset of JSON Schema used to describe the metadata
+type jsonSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Ref         string                 `json:"$ref,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Enum        []string               `json:"enum,omitempty"`
+	Properties  map[string]*jsonSchema `json:"properties,omitempty"`
+	Required    []string               `json:"required,omitempty"`
+	Items       *jsonSchema            `json:"items,omitempty"`
+	Definitions map[string]*jsonSchema `json:"definitions,omitempty"`
+	// PropertyNames constrains the keys of maps
+	PropertyNames *jsonSchema `json:"propertyNames,omitempty"`
+	// Pattern is only used in PropertyNames
+	Pattern string `json:"pattern,omitempty"`
+	// AdditionalProperties is false for structs, rejecting unknown keys, and
+	// the schema of the values for maps
+	AdditionalProperties any `json:"additionalProperties,omitempty"`
+}
+
+// schemaEnums are the values of the string types of the metadata having a
+// fixed set of them, as reflection can't list the constants of a type
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(metadatav1.AlignmenNone): {
+		string(metadatav1.AlignmenNone), string(metadatav1.AlignmentLeft), string(metadatav1.AlignmentRight),
+	},
+	reflect.TypeOf(metadatav1.EllipsisNone): {
+		string(metadatav1.EllipsisNone), string(metadatav1.EllipsisStart),
+		string(metadatav1.EllipsisMiddle), string(metadatav1.EllipsisEnd),
+	},
+	reflect.TypeOf(metadatav1.BaseDecimal): {
+		string(metadatav1.BaseDecimal), string(metadatav1.BaseHex),
+	},
+	reflect.TypeOf(metadatav1.UnitNone): {
+		string(metadatav1.UnitNone), string(metadatav1.UnitBytes), string(metadatav1.UnitNanoseconds),
+		string(metadatav1.UnitMicroseconds), string(metadatav1.UnitMilliseconds), string(metadatav1.UnitCount),
+	},
+	reflect.TypeOf(metadatav1.EncodingUTF8): {
+		string(metadatav1.EncodingUTF8), string(metadatav1.EncodingHex), string(metadatav1.EncodingBase64),
+	},
+	reflect.TypeOf(metadatav1.TrailerTypeBytes): {
+		string(metadatav1.TrailerTypeBytes), string(metadatav1.TrailerTypeString),
+	},
+	reflect.TypeOf(metadatav1.AggregateSum): {
+		string(metadatav1.AggregateSum), string(metadatav1.AggregateMax), string(metadatav1.AggregateMin),
+	},
+	reflect.TypeOf(metadatav1.SnapshotterModeReplace): {
+		string(metadatav1.SnapshotterModeReplace), string(metadatav1.SnapshotterModeDiff),
+	},
+	reflect.TypeOf(metadatav1.SnapshotterSourceIterator): {
+		string(metadatav1.SnapshotterSourceIterator), string(metadatav1.SnapshotterSourceMap),
+	},
+	reflect.TypeOf(metadatav1.AttachDirectionIngress): {
+		string(metadatav1.AttachDirectionIngress), string(metadatav1.AttachDirectionEgress),
+	},
+	reflect.TypeOf(metadatav1.AttachInterfacesPod): {
+		string(metadatav1.AttachInterfacesPod), string(metadatav1.AttachInterfacesAll),
+		string(metadatav1.AttachInterfacesParam),
+	},
+	reflect.TypeOf(metadatav1.MetricTypeCounter): {
+		string(metadatav1.MetricTypeCounter), string(metadatav1.MetricTypeGauge),
+	},
+	reflect.TypeOf(metadatav1.AlertSeverityInfo): {
+		string(metadatav1.AlertSeverityInfo), string(metadatav1.AlertSeverityWarning),
+		string(metadatav1.AlertSeverityCritical),
+	},
+}
+
+// yaml11Bools are the strings decoded as booleans by gopkg.in/yaml.v2, used to
+// decode metadata files, but as strings by gopkg.in/yaml.v3
+var yaml11Bools = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|on|On|ON|off|Off|OFF)$`)
+
+// schemaGenerator builds the schemas of types, collecting the ones of the
+// structs they use as definitions
+type schemaGenerator struct {
+	definitions map[string]*jsonSchema
+	types       map[string]reflect.Type
+}
+
+// MetadataSchema returns the JSON Schema, draft-07, of metadata files. It's
+// generated from the metadatav1 types to always match them: the keys are the
+// ones of their yaml tags, structs reject unknown keys and keys without
+// omitempty are required.
+func MetadataSchema() ([]byte, error) {
+	s, err := metadataSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshalling schema: %w", err)
+	}
+	return append(out, '\n'), nil
+}
+
+func metadataSchema() (*jsonSchema, error) {
+	g := &schemaGenerator{
+		definitions: make(map[string]*jsonSchema),
+		types:       make(map[string]reflect.Type),
+	}
+
+	s, err := g.structSchema(reflect.TypeOf(metadatav1.GadgetMetadata{}))
+	if err != nil {
+		return nil, err
+	}
+	s.Schema = schemaDraft
+	s.Title = "Gadget metadata"
+	s.Definitions = g.definitions
+	return s, nil
+}
+
+// schemaOf returns the schema of the values of type t
+func (g *schemaGenerator) schemaOf(t reflect.Type) (*jsonSchema, error) {
+	if values, ok := schemaEnums[t]; ok {
+		return &jsonSchema{Type: "string", Enum: values}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaOf(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}, nil
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}, nil
+	case reflect.Interface:
+		// Anything goes, like the values of annotations
+		return &jsonSchema{}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return &jsonSchema{Type: "array", Items: items}, nil
+	case reflect.Map:
+		values, err := g.schemaOf(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := &jsonSchema{Type: "object", AdditionalProperties: values}
+		switch t.Key().Kind() {
+		case reflect.String:
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s.PropertyNames = &jsonSchema{Pattern: integerKeyPattern}
+		default:
+			return nil, fmt.Errorf("unsupported type of map keys %s", t.Key())
+		}
+		return s, nil
+	case reflect.Struct:
+		return g.structRef(t)
+	default:
+		return nil, fmt.Errorf("unsupported type %s", t)
+	}
+}
+
+// structRef returns a reference to the definition of struct t, adding it if
+// needed
+func (g *schemaGenerator) structRef(t reflect.Type) (*jsonSchema, error) {
+	name := t.Name()
+	ref := &jsonSchema{Ref: "#/definitions/" + name}
+
+	if other, ok := g.types[name]; ok {
+		if other != t {
+			return nil, fmt.Errorf("types %s and %s have the same name", other, t)
+		}
+		return ref, nil
+	}
+	// Registered before being built, for recursive types
+	g.types[name] = t
+
+	s, err := g.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	g.definitions[name] = s
+	return ref, nil
+}
+
+// structSchema returns the schema of struct t
+func (g *schemaGenerator) structSchema(t reflect.Type) (*jsonSchema, error) {
+	s := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+	if err := g.addFields(s, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", t.Name(), err)
+	}
+	return s, nil
+}
+
+// addFields adds the fields of struct t, and the ones of the structs it
+// inlines, to the properties of s
+func (g *schemaGenerator) addFields(s *jsonSchema, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, flags, _ := strings.Cut(tag, ",")
+		options := strings.Split(flags, ",")
+
+		if slices.Contains(options, "inline") {
+			if err := g.addFields(s, f.Type); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Default key of gopkg.in/yaml.v2
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		prop, err := g.schemaOf(f.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		s.Properties[name] = prop
+		if !slices.Contains(options, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return nil
+}
+
+// schemaValidator checks YAML documents against a schema
+type schemaValidator struct {
+	definitions map[string]*jsonSchema
+	// patterns are the compiled patterns of the keys of maps
+	patterns map[string]*regexp.Regexp
+}
+
+// ValidateYAMLAgainstSchema checks the metadata file in content against the
+// schema returned by MetadataSchema. Unlike decoding, it reports all the
+// unknown keys, missing required keys and values of the wrong type, each
+// with its line, making it suitable before decoding the file.
+func ValidateYAMLAgainstSchema(content []byte) error {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return fmt.Errorf("parsing metadata: %w", err)
+	}
+	// Empty document
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	s, err := metadataSchema()
+	if err != nil {
+		return fmt.Errorf("generating schema: %w", err)
+	}
+
+	v := &schemaValidator{
+		definitions: s.Definitions,
+		patterns:    make(map[string]*regexp.Regexp),
+	}
+	return v.validate(s, doc.Content[0], "")
+}
+
+// validate checks node against s. path locates node in the document, for
+// errors.
+func (v *schemaValidator) validate(s *jsonSchema, node *yaml.Node, path string) error {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if s.Ref != "" {
+		s = v.definitions[strings.TrimPrefix(s.Ref, "#/definitions/")]
+	}
+	// Empty values are decoded as the zero value
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return nil
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			return schemaError(node, path, "expected a mapping")
+		}
+		return v.validateMapping(s, node, path)
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			return schemaError(node, path, "expected a sequence")
+		}
+		var result error
+		for i, item := range node.Content {
+			if err := v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				result = multierror.Append(result, err)
+			}
+		}
+		return result
+	case "string":
+		// Any scalar is decoded as its text into strings
+		if node.Kind != yaml.ScalarNode {
+			return schemaError(node, path, "expected a string")
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, node.Value) {
+			return schemaError(node, path, fmt.Sprintf("invalid value %q, expected one of %s", node.Value, enumString(s.Enum)))
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			return schemaError(node, path, "expected an integer")
+		}
+	case "number":
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!int" && node.Tag != "!!float") {
+			return schemaError(node, path, "expected a number")
+		}
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || (node.Tag != "!!bool" && !yaml11Bools.MatchString(node.Value)) {
+			return schemaError(node, path, "expected a boolean")
+		}
+	}
+	return nil
+}
+
+// validateMapping checks the keys and values of mapping node against s
+func (v *schemaValidator) validateMapping(s *jsonSchema, node *yaml.Node, path string) error {
+	var result error
+
+	seen := make(map[string]struct{}, len(node.Content)/2)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		seen[key.Value] = struct{}{}
+		keyPath := key.Value
+		if path != "" {
+			keyPath = path + "." + key.Value
+		}
+
+		if s.PropertyNames != nil && !v.pattern(s.PropertyNames.Pattern).MatchString(key.Value) {
+			result = multierror.Append(result, schemaError(key, keyPath, "expected an integer key"))
+			continue
+		}
+
+		prop, ok := s.Properties[key.Value]
+		if !ok {
+			values, isSchema := s.AdditionalProperties.(*jsonSchema)
+			if !isSchema {
+				result = multierror.Append(result, schemaError(key, path, fmt.Sprintf("unknown key %q", key.Value)))
+				continue
+			}
+			prop = values
+		}
+		if err := v.validate(prop, value, keyPath); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	for _, name := range s.Required {
+		if _, ok := seen[name]; !ok {
+			result = multierror.Append(result, schemaError(node, path, fmt.Sprintf("missing required key %q", name)))
+		}
+	}
+
+	return result
+}
+
+func (v *schemaValidator) pattern(pattern string) *regexp.Regexp {
+	re, ok := v.patterns[pattern]
+	if !ok {
+		re = regexp.MustCompile(pattern)
+		v.patterns[pattern] = re
+	}
+	return re
+}
+
+func schemaError(node *yaml.Node, path, msg string) error {
+	if path == "" {
+		return fmt.Errorf("line %d: %s", node.Line, msg)
+	}
+	return fmt.Errorf("line %d: %s: %s", node.Line, path, msg)
+}
+
+// enumString returns the values of an enum for errors, showing the empty one
+// as ""
+func enumString(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("%q", value))
+	}
+	sort.Strings(quoted)
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,196 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataSchema(t *testing.T) {
+	t.Parallel()
+
+	// Update it with the output of MetadataSchema when the metadata types change
+	const goldenPath = "../../../../testdata/metadata_schema.json"
+
+	out, err := MetadataSchema()
+	require.NoError(t, err)
+
+	golden, err := os.ReadFile(goldenPath)
+	require.NoError(t, err)
+	require.Equal(t, string(golden), string(out), "schema out of date, update %s", goldenPath)
+}
+
+func TestValidateYAMLAgainstSchema(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		content            string
+		expectedErrStrings []string
+	}
+
+	tests := map[string]testCase{
+		"valid": {
+			content: `
+name: trace_open
+tracers:
+  open:
+    mapName: events
+    structName: event
+structs:
+  event:
+    fields:
+    - name: pid
+      attributes:
+        width: 10
+        alignment: right
+        ellipsis: end
+        hidden: yes
+    - name: type
+      values:
+        1: open
+        -1: unknown
+ebpfParams:
+  targ_uid:
+    key: uid
+    defaultValue: 0
+    description: Show only events of this user
+    examples: ["0", "1000"]
+annotations:
+`,
+		},
+		"empty": {},
+		"unknown_key": {
+			content: `
+name: trace_open
+tracer:
+  open:
+    mapName: events
+    structName: event
+`,
+			expectedErrStrings: []string{`line 3: unknown key "tracer"`},
+		},
+		"unknown_nested_key": {
+			content: `
+name: trace_open
+structs:
+  event:
+    fields:
+    - name: pid
+      attributes:
+        widht: 10
+`,
+			expectedErrStrings: []string{`line 8: structs.event.fields[0].attributes: unknown key "widht"`},
+		},
+		"invalid_enum_value": {
+			content: `
+name: trace_open
+structs:
+  event:
+    fields:
+    - name: pid
+      attributes:
+        alignment: center
+`,
+			expectedErrStrings: []string{
+				`line 8: structs.event.fields[0].attributes.alignment: invalid value "center", expected one of "", "left", "right"`,
+			},
+		},
+		"wrong_type": {
+			content: `
+name: trace_open
+structs:
+  event:
+    fields:
+    - name: pid
+      attributes:
+        width: wide
+        hidden: maybe
+`,
+			expectedErrStrings: []string{
+				"line 8: structs.event.fields[0].attributes.width: expected an integer",
+				"line 9: structs.event.fields[0].attributes.hidden: expected a boolean",
+			},
+		},
+		"missing_required_key": {
+			content: `
+name: trace_open
+tracers:
+  open:
+    mapName: events
+`,
+			expectedErrStrings: []string{`line 5: tracers.open: missing required key "structName"`},
+		},
+		"non_integer_key": {
+			content: `
+name: trace_open
+structs:
+  event:
+    fields:
+    - name: type
+      values:
+        open: 1
+`,
+			expectedErrStrings: []string{"line 8: structs.event.fields[0].values.open: expected an integer key"},
+		},
+		"mapping_instead_of_sequence": {
+			content: `
+name: trace_open
+structs:
+  event:
+    fields:
+      name: pid
+`,
+			expectedErrStrings: []string{"line 6: structs.event.fields: expected a sequence"},
+		},
+		"invalid_yaml": {
+			content:            "name: [trace_open",
+			expectedErrStrings: []string{"parsing metadata"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := ValidateYAMLAgainstSchema([]byte(test.content))
+			if len(test.expectedErrStrings) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			for _, expected := range test.expectedErrStrings {
+				require.ErrorContains(t, err, expected)
+			}
+		})
+	}
+}
+
+func TestValidateGadgetsAgainstSchema(t *testing.T) {
+	t.Parallel()
+
+	files, err := filepath.Glob("../../../../gadgets/*/gadget.yaml")
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		require.NoError(t, ValidateYAMLAgainstSchema(content), file)
+	}
+}
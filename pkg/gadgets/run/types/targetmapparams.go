@@ -0,0 +1,148 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// validateTargetMapParams checks the params whose values are inserted into a
+// hash map, like a list of ports to filter on: the map must have keys of the
+// declared key type, and the variable backing the param, set to the number of
+// values, must be an integer or a bool.
+func validateTargetMapParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		p := m.EBPFParams[varName]
+		if !p.HasTargetMap() {
+			if p.KeyType != "" || (p.List && !isArrayParam(idx, varName)) {
+				result = multierror.Append(result, fmt.Errorf("param %q: keyType and list are only supported by params with a targetMap, list also by params backed by an array", varName))
+			}
+			continue
+		}
+		if err := validateTargetMapParam(idx, varName, &p); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func validateTargetMapParam(idx *btfIndex, varName string, p *metadatav1.EBPFParam) error {
+	var result error
+
+	if btfVar, err := idx.varByName(varName); err == nil {
+		// A missing variable is already reported by checkParamVar
+		if _, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Int); !ok {
+			result = multierror.Append(result, fmt.Errorf("param %q: %q must be an integer or a bool, it's set to the number of values",
+				varName, varName))
+		}
+	}
+
+	keySize, err := TargetMapKeySize(p)
+	if err != nil {
+		result = multierror.Append(result, fmt.Errorf("param %q: %w", varName, err))
+		return result
+	}
+
+	if err := validateTargetMap(idx.spec, p.TargetMap, p.KeyType, keySize); err != nil {
+		result = multierror.Append(result, fmt.Errorf("param %q: %w", varName, err))
+	}
+
+	for _, v := range checkedValues(p) {
+		if _, err := TargetMapKeys(p, v.value); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %s: %w", varName, v.what, p.MaskError(err, v.value)))
+		}
+	}
+
+	return result
+}
+
+func validateTargetMap(spec *ebpf.CollectionSpec, name, keyType string, keySize int) error {
+	targetMap, ok := spec.Maps[name]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", name)
+	}
+	if targetMap.Type != ebpf.Hash && targetMap.Type != ebpf.LRUHash {
+		return fmt.Errorf("map %q has type %s, expected %s or %s", name, targetMap.Type, ebpf.Hash, ebpf.LRUHash)
+	}
+	if targetMap.KeySize != uint32(keySize) {
+		return fmt.Errorf("map %q has keys of %d bytes, expected %d (%s)", name, targetMap.KeySize, keySize, keyType)
+	}
+	if targetMap.Key == nil {
+		return nil
+	}
+	if _, ok := btfhelpers.GetUnderlyingType(targetMap.Key).(*btf.Int); !ok {
+		return fmt.Errorf("map %q has keys of type %s, expected an integer (%s)", name, targetMap.Key, keyType)
+	}
+	return nil
+}
+
+// targetMapKeySizes are the sizes of the keys of the target maps by key type
+var targetMapKeySizes = map[string]int{
+	string(params.TypeInt8):   1,
+	string(params.TypeInt16):  2,
+	string(params.TypeInt32):  4,
+	string(params.TypeInt64):  8,
+	string(params.TypeUint8):  1,
+	string(params.TypeUint16): 2,
+	string(params.TypeUint32): 4,
+	string(params.TypeUint64): 8,
+	metadatav1.KeyTypePort:    2,
+}
+
+// TargetMapKeySize returns the size of the keys of the target map, given by the key type
+func TargetMapKeySize(p *metadatav1.EBPFParam) (int, error) {
+	size, ok := targetMapKeySizes[p.KeyType]
+	if !ok {
+		return 0, fmt.Errorf("unknown key type %q, expected an integer type like uint32 or %s", p.KeyType, metadatav1.KeyTypePort)
+	}
+	return size, nil
+}
+
+// TargetMapKeys returns the keys to insert into the target map for value, encoded as the key type
+// requires. An empty value gives no key.
+func TargetMapKeys(p *metadatav1.EBPFParam, value string) ([][]byte, error) {
+	size, err := TargetMapKeySize(p)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	elems := []string{value}
+	if p.List {
+		elems = strings.Split(value, ",")
+	}
+
+	keys := make([][]byte, 0, len(elems))
+	for _, elem := range elems {
+		key := make([]byte, size)
+		if err := putInteger(key, strings.TrimSpace(elem), p.KeyType); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func targetMapParamsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	u16Type := &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}
+	spec := newTestBTF(t,
+		constVar("nr_ports", u32Type),
+		constVar("filter_pids", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("comm", &btf.Array{Type: charType, Index: u32Type, Nelems: 16}),
+		u16Type,
+	)
+
+	return &ebpf.CollectionSpec{
+		Maps: map[string]*ebpf.MapSpec{
+			"ports": {Name: "ports", Type: ebpf.Hash, KeySize: 2, ValueSize: 1, MaxEntries: 16, Key: u16Type},
+			"pids":  {Name: "pids", Type: ebpf.LRUHash, KeySize: 4, ValueSize: 1, MaxEntries: 16},
+			"array": {Name: "array", Type: ebpf.Array, KeySize: 4, ValueSize: 1, MaxEntries: 16},
+			"structs": {
+				Name: "structs", Type: ebpf.Hash, KeySize: 4, ValueSize: 1, MaxEntries: 16,
+				Key: &btf.Struct{Name: "key", Size: 4},
+			},
+		},
+		Types: spec,
+	}
+}
+
+func TestValidateTargetMapParams(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		varName           string
+		param             metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	targetMapParam := func(targetMap, keyType, defaultValue string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{
+			ParamDesc: params.ParamDesc{DefaultValue: defaultValue},
+			TargetMap: targetMap,
+			KeyType:   keyType,
+			List:      true,
+		}
+	}
+
+	tests := map[string]testCase{
+		"ports": {
+			varName: "nr_ports",
+			param:   targetMapParam("ports", metadatav1.KeyTypePort, "80,443"),
+		},
+		"pids_enable": {
+			varName: "filter_pids",
+			param:   targetMapParam("pids", string(params.TypeUint32), ""),
+		},
+		"unknown_key_type": {
+			varName:           "nr_ports",
+			param:             targetMapParam("ports", "short", ""),
+			expectedErrString: "param \"nr_ports\": unknown key type \"short\", expected an integer type like uint32 or port",
+		},
+		"key_size_mismatch": {
+			varName:           "nr_ports",
+			param:             targetMapParam("ports", string(params.TypeUint32), ""),
+			expectedErrString: "param \"nr_ports\": map \"ports\" has keys of 2 bytes, expected 4 (uint32)",
+		},
+		"struct_keys": {
+			varName:           "nr_ports",
+			param:             targetMapParam("structs", string(params.TypeUint32), ""),
+			expectedErrString: "param \"nr_ports\": map \"structs\" has keys of type Struct:\"key\", expected an integer (uint32)",
+		},
+		"not_a_hash": {
+			varName:           "nr_ports",
+			param:             targetMapParam("array", string(params.TypeUint32), ""),
+			expectedErrString: "param \"nr_ports\": map \"array\" has type Array, expected Hash or LRUHash",
+		},
+		"missing_map": {
+			varName:           "nr_ports",
+			param:             targetMapParam("nonexistent", metadatav1.KeyTypePort, ""),
+			expectedErrString: "param \"nr_ports\": map \"nonexistent\" not found in eBPF object",
+		},
+		"bad_default": {
+			varName:           "nr_ports",
+			param:             targetMapParam("ports", metadatav1.KeyTypePort, "80,http"),
+			expectedErrString: "param \"nr_ports\": default value: \"http\" isn't a valid port",
+		},
+		"not_an_integer": {
+			varName:           "comm",
+			param:             targetMapParam("ports", metadatav1.KeyTypePort, ""),
+			expectedErrString: "param \"comm\": \"comm\" must be an integer or a bool, it's set to the number of values",
+		},
+		"key_type_without_map": {
+			varName: "nr_ports",
+			param: metadatav1.EBPFParam{
+				KeyType: metadatav1.KeyTypePort,
+			},
+			expectedErrString: "param \"nr_ports\": keyType and list are only supported by params with a targetMap",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{test.varName: test.param},
+			}
+			err := validateTargetMapParams(m, newBTFIndex(targetMapParamsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestTargetMapKeys(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		keyType           string
+		list              bool
+		value             string
+		expectedKeys      [][]byte
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"ports": {
+			keyType:      metadatav1.KeyTypePort,
+			list:         true,
+			value:        "80,443",
+			expectedKeys: [][]byte{{0, 80}, {1, 187}},
+		},
+		"pids": {
+			keyType:      string(params.TypeUint32),
+			list:         true,
+			value:        "1,4242",
+			expectedKeys: [][]byte{binary.NativeEndian.AppendUint32(nil, 1), binary.NativeEndian.AppendUint32(nil, 4242)},
+		},
+		"signed": {
+			keyType:      string(params.TypeInt16),
+			value:        "-2",
+			expectedKeys: [][]byte{binary.NativeEndian.AppendUint16(nil, 0xfffe)},
+		},
+		"empty": {
+			keyType: string(params.TypeUint32),
+			list:    true,
+		},
+		"not_a_list": {
+			keyType:           string(params.TypeUint32),
+			value:             "1,2",
+			expectedErrString: "\"1,2\" isn't a valid uint32",
+		},
+		"overflow": {
+			keyType:           string(params.TypeUint8),
+			list:              true,
+			value:             "1,256",
+			expectedErrString: "\"256\" isn't a valid uint8",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := metadatav1.EBPFParam{TargetMap: "m", KeyType: test.keyType, List: test.list}
+			keys, err := TargetMapKeys(&p, test.value)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedKeys, keys)
+		})
+	}
+}
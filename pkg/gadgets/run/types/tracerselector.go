@@ -0,0 +1,101 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const tracerSelectorDescription = "Tracer to run, all of them by default"
+
+// populateTracerSelector generates the gadget param selecting the tracer to
+// run, its possible values being the names of the tracers. The description and
+// default value in the metadata file are kept.
+func populateTracerSelector(m *metadatav1.GadgetMetadata) {
+	if m.SelectorParam == "" {
+		return
+	}
+	if m.GadgetParams == nil {
+		m.GadgetParams = make(map[string]params.ParamDesc)
+	}
+
+	p := m.GadgetParams[m.SelectorParam]
+	p.Key = m.SelectorParam
+	if p.Description == "" {
+		p.Description = tracerSelectorDescription
+	}
+	if p.DefaultValue == "" {
+		p.DefaultValue = metadatav1.SelectAllTracers
+	}
+	p.PossibleValues = m.TracerSelectorValues()
+	m.GadgetParams[m.SelectorParam] = p
+}
+
+// validateTracerSelector checks the param selecting the tracer to run: its
+// possible values must be the names of the tracers, and the programs must be
+// linked to existing tracers
+func validateTracerSelector(m *metadatav1.GadgetMetadata) error {
+	var result error
+
+	for _, name := range sortedKeys(m.Programs) {
+		tracer := m.Programs[name].Tracer
+		if tracer == "" {
+			continue
+		}
+		if m.SelectorParam == "" {
+			result = multierror.Append(result, fmt.Errorf("program %q: tracer is set but the gadget has no selectorParam", name))
+		} else if _, ok := m.Tracers[tracer]; !ok {
+			result = multierror.Append(result, fmt.Errorf("program %q: tracer %q not found", name, tracer))
+		}
+	}
+
+	key := m.SelectorParam
+	if key == "" {
+		return result
+	}
+	if len(m.Tracers) == 0 {
+		result = multierror.Append(result, fmt.Errorf("selectorParam %q: the gadget has no tracers to select", key))
+		return result
+	}
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		if m.EBPFParams[varName].Key == key {
+			result = multierror.Append(result, fmt.Errorf("selectorParam %q: key already used by eBPF param %q", key, varName))
+		}
+	}
+
+	p, ok := m.GadgetParams[key]
+	if !ok {
+		result = multierror.Append(result, fmt.Errorf("selectorParam %q: gadget param not found, run ig image build to generate it", key))
+		return result
+	}
+	expected := m.TracerSelectorValues()
+	if !slices.Equal(p.PossibleValues, expected) {
+		result = multierror.Append(result, fmt.Errorf("selectorParam %q: possible values are %s but the tracers give %s, run ig image build to update them",
+			key, strings.Join(p.PossibleValues, ", "), strings.Join(expected, ", ")))
+	}
+	if p.DefaultValue != "" && !slices.Contains(expected, p.DefaultValue) {
+		result = multierror.Append(result, fmt.Errorf("selectorParam %q: default value %q is neither %q nor a tracer",
+			key, p.DefaultValue, metadatav1.SelectAllTracers))
+	}
+
+	return result
+}
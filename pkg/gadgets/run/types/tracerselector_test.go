@@ -0,0 +1,146 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func selectorMetadata() *metadatav1.GadgetMetadata {
+	return &metadatav1.GadgetMetadata{
+		SelectorParam: "tracer",
+		Tracers: map[string]metadatav1.Tracer{
+			"open": {MapName: "open_events", StructName: "open_event"},
+			"exec": {MapName: "exec_events", StructName: "exec_event"},
+		},
+		Programs: map[string]metadatav1.Program{
+			"trace_exec": {Tracer: "exec"},
+			"trace_open": {Tracer: "open"},
+		},
+	}
+}
+
+func TestPopulateTracerSelector(t *testing.T) {
+	t.Parallel()
+
+	m := selectorMetadata()
+	populateTracerSelector(m)
+	require.Equal(t, params.ParamDesc{
+		Key:            "tracer",
+		Description:    tracerSelectorDescription,
+		DefaultValue:   "all",
+		PossibleValues: []string{"all", "exec", "open"},
+	}, m.GadgetParams["tracer"])
+	require.NoError(t, validateTracerSelector(m))
+
+	// Possible values follow the tracers, the description and default value
+	// set by the author are kept
+	m.Tracers["connect"] = metadatav1.Tracer{}
+	p := m.GadgetParams["tracer"]
+	p.Description = "Kind of events to trace"
+	p.DefaultValue = "exec"
+	m.GadgetParams["tracer"] = p
+	populateTracerSelector(m)
+	require.Equal(t, []string{"all", "connect", "exec", "open"}, m.GadgetParams["tracer"].PossibleValues)
+	require.Equal(t, "Kind of events to trace", m.GadgetParams["tracer"].Description)
+	require.Equal(t, "exec", m.GadgetParams["tracer"].DefaultValue)
+
+	// Nothing is generated without selectorParam
+	m = &metadatav1.GadgetMetadata{Tracers: map[string]metadatav1.Tracer{"exec": {}}}
+	populateTracerSelector(m)
+	require.Empty(t, m.GadgetParams)
+}
+
+func TestValidateTracerSelector(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		modify            func(m *metadatav1.GadgetMetadata)
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"valid": {},
+		"out_of_sync": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.Tracers["connect"] = metadatav1.Tracer{}
+			},
+			expectedErrString: "selectorParam \"tracer\": possible values are all, exec, open but the tracers give all, connect, exec, open",
+		},
+		"missing_param": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				delete(m.GadgetParams, "tracer")
+			},
+			expectedErrString: "selectorParam \"tracer\": gadget param not found",
+		},
+		"unknown_default": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				p := m.GadgetParams["tracer"]
+				p.DefaultValue = "connect"
+				m.GadgetParams["tracer"] = p
+			},
+			expectedErrString: "selectorParam \"tracer\": default value \"connect\" is neither \"all\" nor a tracer",
+		},
+		"no_tracers": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.Tracers = nil
+				m.Programs = nil
+			},
+			expectedErrString: "selectorParam \"tracer\": the gadget has no tracers to select",
+		},
+		"key_used_by_ebpf_param": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.EBPFParams = map[string]metadatav1.EBPFParam{"targ_tracer": {ParamDesc: params.ParamDesc{Key: "tracer"}}}
+			},
+			expectedErrString: "selectorParam \"tracer\": key already used by eBPF param \"targ_tracer\"",
+		},
+		"unknown_program_tracer": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.Programs["trace_connect"] = metadatav1.Program{Tracer: "connect"}
+			},
+			expectedErrString: "program \"trace_connect\": tracer \"connect\" not found",
+		},
+		"program_tracer_without_selector": {
+			modify: func(m *metadatav1.GadgetMetadata) {
+				m.SelectorParam = ""
+			},
+			expectedErrString: "program \"trace_exec\": tracer is set but the gadget has no selectorParam",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := selectorMetadata()
+			populateTracerSelector(m)
+			if test.modify != nil {
+				test.modify(m)
+			}
+			err := validateTracerSelector(m)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
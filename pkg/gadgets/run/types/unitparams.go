@@ -0,0 +1,210 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// durationSuffixes are the suffixes of the names of the params populated as
+// durations, with their target units
+var durationSuffixes = map[string]string{
+	"_ns": "ns",
+	"_ms": "ms",
+}
+
+// validateUnitParams checks the duration and size params: the runtime converts
+// their values to the target unit and writes them into integer variables,
+// which their default values and examples must fit into
+func validateUnitParams(m *metadatav1.GadgetMetadata, idx *btfIndex) error {
+	var result error
+	for _, varName := range sortedKeys(m.EBPFParams) {
+		p := m.EBPFParams[varName]
+		if !p.HasUnit() {
+			if p.TargetUnit != "" {
+				result = multierror.Append(result, fmt.Errorf("param %q: targetUnit is only supported by duration and size params", varName))
+			}
+			continue
+		}
+		btfVar, err := idx.varByName(varName)
+		if err != nil {
+			// Already reported by checkParamVar
+			continue
+		}
+		if err := validateUnitParam(varName, &p, btfVar); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+	return result
+}
+
+func validateUnitParam(varName string, p *metadatav1.EBPFParam, btfVar *btf.Var) error {
+	if err := CheckTargetUnit(p); err != nil {
+		return fmt.Errorf("param %q: %w", varName, err)
+	}
+
+	intType, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Int)
+	if !ok || intType.Encoding == btf.Bool {
+		return fmt.Errorf("param %q: %s params need an integer variable", varName, p.TypeHint)
+	}
+
+	// Values that don't parse are reported by validateParamConstraints
+	desc := params.ParamDesc{TypeHint: p.TypeHint}
+	var result error
+	for _, v := range checkedValues(p) {
+		if desc.Validate(v.value) != nil {
+			continue
+		}
+		if _, err := ToTargetUnit(p, v.value, int(intType.Size), intType.Encoding == btf.Signed); err != nil {
+			result = multierror.Append(result, fmt.Errorf("param %q: %s: %w", varName, v.what, p.MaskError(err, v.value)))
+		}
+	}
+	return result
+}
+
+// applyDurationSuffix makes the integer params named like timeout_ms durations
+// in that unit, letting users pass values like 250ms
+func applyDurationSuffix(p *metadatav1.EBPFParam, btfVar *btf.Var) {
+	intType, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Int)
+	if !ok || intType.Encoding == btf.Bool {
+		return
+	}
+	for suffix, unit := range durationSuffixes {
+		if !strings.HasSuffix(btfVar.Name, suffix) {
+			continue
+		}
+		p.TypeHint = params.TypeDuration
+		p.TargetUnit = unit
+		if n, err := strconv.ParseInt(p.DefaultValue, 10, 64); err == nil {
+			p.DefaultValue = FromTargetUnit(p, n)
+		}
+		return
+	}
+}
+
+// durationUnits are the target units supported by duration params
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+}
+
+// CheckTargetUnit fails if the target unit isn't supported by the type of the param
+func CheckTargetUnit(p *metadatav1.EBPFParam) error {
+	switch {
+	case p.TargetUnit == "":
+		return nil
+	case p.TypeHint == params.TypeDuration:
+		if _, ok := durationUnits[p.TargetUnit]; !ok {
+			return fmt.Errorf("unknown target unit %q for a duration, expected ns, us, ms or s", p.TargetUnit)
+		}
+		return nil
+	case p.TypeHint == params.TypeSize:
+		if p.TargetUnit != "bytes" {
+			return fmt.Errorf("unknown target unit %q for a size, expected bytes", p.TargetUnit)
+		}
+		return nil
+	}
+	return errors.New("targetUnit is only supported by duration and size params")
+}
+
+// ToTargetUnit converts value, a duration like 250ms or a size like 4KiB, to the target unit of
+// the param. It fails if the result doesn't fit into an integer variable of the given size in
+// bytes and signedness. Negative values are returned in two's complement.
+func ToTargetUnit(p *metadatav1.EBPFParam, value string, size int, signed bool) (uint64, error) {
+	if err := CheckTargetUnit(p); err != nil {
+		return 0, err
+	}
+
+	bits := 8 * size
+	maxValue := uint64(math.MaxUint64) >> (64 - bits)
+	if signed {
+		maxValue >>= 1
+	}
+
+	switch p.TypeHint {
+	case params.TypeDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, err
+		}
+		unit := durationUnit(p)
+		if d%unit != 0 {
+			return 0, fmt.Errorf("%s isn't a whole number of %s", value, targetUnit(p))
+		}
+		n := int64(d / unit)
+		if n < 0 {
+			if !signed {
+				return 0, fmt.Errorf("%s is negative", value)
+			}
+			if uint64(-n) > maxValue+1 {
+				return 0, fmt.Errorf("%s is %d%s, beyond what a %d-bit variable holds", value, n, targetUnit(p), bits)
+			}
+			return uint64(n), nil
+		}
+		if uint64(n) > maxValue {
+			return 0, fmt.Errorf("%s is %d%s, beyond what a %d-bit variable holds", value, n, targetUnit(p), bits)
+		}
+		return uint64(n), nil
+	case params.TypeSize:
+		n, err := params.ParseSize(value)
+		if err != nil {
+			return 0, err
+		}
+		if n > maxValue {
+			return 0, fmt.Errorf("%s is %d bytes, beyond what a %d-bit variable holds", value, n, bits)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("%s params have no unit", p.TypeHint)
+}
+
+// FromTargetUnit formats n, an integer in the target unit of the param, as a duration or a size
+func FromTargetUnit(p *metadatav1.EBPFParam, n int64) string {
+	if p.TypeHint == params.TypeDuration {
+		return (time.Duration(n) * durationUnit(p)).String()
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+func targetUnit(p *metadatav1.EBPFParam) string {
+	if p.TargetUnit != "" {
+		return p.TargetUnit
+	}
+	if p.TypeHint == params.TypeDuration {
+		return "ns"
+	}
+	return "bytes"
+}
+
+func durationUnit(p *metadatav1.EBPFParam) time.Duration {
+	if unit, ok := durationUnits[targetUnit(p)]; ok {
+		return unit
+	}
+	return time.Nanosecond
+}
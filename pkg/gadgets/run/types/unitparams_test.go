@@ -0,0 +1,196 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func unitParamsSpec(t *testing.T) *ebpf.CollectionSpec {
+	t.Helper()
+
+	return newTestSpec(t,
+		constVar("timeout", u32Type),
+		constVar("threshold", &btf.Typedef{Name: "__u16", Type: &btf.Int{Name: "unsigned short", Size: 2}}),
+		constVar("verbose", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+	)
+}
+
+func TestValidateUnitParams(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		varName           string
+		param             metadatav1.EBPFParam
+		expectedErrString string
+	}
+
+	unitParam := func(typeHint params.TypeHint, targetUnit, defaultValue string) metadatav1.EBPFParam {
+		return metadatav1.EBPFParam{
+			ParamDesc:  params.ParamDesc{TypeHint: typeHint, DefaultValue: defaultValue},
+			TargetUnit: targetUnit,
+		}
+	}
+
+	tests := map[string]testCase{
+		"duration": {
+			varName: "timeout",
+			param:   unitParam(params.TypeDuration, "ms", "250ms"),
+		},
+		"duration_default_unit": {
+			varName: "timeout",
+			param:   unitParam(params.TypeDuration, "", "2s"),
+		},
+		"size": {
+			varName: "threshold",
+			param:   unitParam(params.TypeSize, "bytes", "4KiB"),
+		},
+		"unknown_duration_unit": {
+			varName:           "timeout",
+			param:             unitParam(params.TypeDuration, "min", ""),
+			expectedErrString: "param \"timeout\": unknown target unit \"min\" for a duration, expected ns, us, ms or s",
+		},
+		"unknown_size_unit": {
+			varName:           "threshold",
+			param:             unitParam(params.TypeSize, "KiB", ""),
+			expectedErrString: "unknown target unit \"KiB\" for a size, expected bytes",
+		},
+		"target_unit_on_integer": {
+			varName:           "timeout",
+			param:             unitParam(params.TypeUint32, "ms", ""),
+			expectedErrString: "param \"timeout\": targetUnit is only supported by duration and size params",
+		},
+		"bool_variable": {
+			varName:           "verbose",
+			param:             unitParam(params.TypeDuration, "", ""),
+			expectedErrString: "param \"verbose\": duration params need an integer variable",
+		},
+		"default_overflow": {
+			varName:           "threshold",
+			param:             unitParam(params.TypeSize, "", "1MiB"),
+			expectedErrString: "param \"threshold\": default value: 1MiB is 1048576 bytes, beyond what a 16-bit variable holds",
+		},
+		"default_not_whole": {
+			varName:           "timeout",
+			param:             unitParam(params.TypeDuration, "ms", "1500us"),
+			expectedErrString: "default value: 1500us isn't a whole number of ms",
+		},
+		"invalid_default": {
+			// Reported by validateParamConstraints
+			varName: "timeout",
+			param:   unitParam(params.TypeDuration, "ms", "soon"),
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := &metadatav1.GadgetMetadata{
+				EBPFParams: map[string]metadatav1.EBPFParam{test.varName: test.param},
+			}
+			err := validateUnitParams(m, newBTFIndex(unitParamsSpec(t)))
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestApplyDurationSuffix(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		varName          string
+		typ              btf.Type
+		defaultValue     string
+		expectedTypeHint params.TypeHint
+		expectedUnit     string
+		expectedDefault  string
+	}
+
+	boolType := &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}
+
+	tests := map[string]testCase{
+		"ms": {
+			varName:          "timeout_ms",
+			typ:              u32Type,
+			defaultValue:     "500",
+			expectedTypeHint: params.TypeDuration,
+			expectedUnit:     "ms",
+			expectedDefault:  "500ms",
+		},
+		"ns": {
+			varName:          "min_latency_ns",
+			typ:              u32Type,
+			defaultValue:     "0",
+			expectedTypeHint: params.TypeDuration,
+			expectedUnit:     "ns",
+			expectedDefault:  "0s",
+		},
+		"no_suffix": {
+			varName:          "timeout",
+			typ:              u32Type,
+			defaultValue:     "500",
+			expectedTypeHint: params.TypeUint32,
+			expectedDefault:  "500",
+		},
+		"bool": {
+			varName:          "verbose_ms",
+			typ:              boolType,
+			defaultValue:     "false",
+			expectedTypeHint: params.TypeBool,
+			expectedDefault:  "false",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			btfVar := &btf.Var{Name: test.varName, Type: &btf.Const{Type: &btf.Volatile{Type: test.typ}}}
+			p := &metadatav1.EBPFParam{ParamDesc: paramDescFromVar(btfVar)}
+			p.DefaultValue = test.defaultValue
+
+			applyDurationSuffix(p, btfVar)
+			require.Equal(t, test.expectedTypeHint, p.TypeHint)
+			require.Equal(t, test.expectedUnit, p.TargetUnit)
+			require.Equal(t, test.expectedDefault, p.DefaultValue)
+		})
+	}
+}
+
+func TestUnitParamRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	p := &metadatav1.EBPFParam{TargetUnit: "ms"}
+	p.TypeHint = params.TypeDuration
+	for _, value := range []string{"250ms", "2s", "1m30s", "0s"} {
+		n, err := ToTargetUnit(p, value, 4, false)
+		require.NoError(t, err)
+		require.Equal(t, value, FromTargetUnit(p, int64(n)))
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"gopkg.in/yaml.v3"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// addedComment marks the entries added to an existing metadata file
+const addedComment = "Added by ig image build"
+
+// UpdateMetadataFile populates the metadata file at path from the eBPF object,
+// see MarshalPreserving. The file is created if it doesn't exist.
+func UpdateMetadataFile(path string, spec *ebpf.CollectionSpec, opts ...PopulateOption) error {
+	original, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("reading metadata file: %w", err)
+	}
+
+	m := &metadatav1.GadgetMetadata{}
+	if err := yaml.Unmarshal(original, m); err != nil {
+		return fmt.Errorf("decoding metadata file: %w", err)
+	}
+
+	if err := Populate(m, spec, opts...); err != nil {
+		return fmt.Errorf("populating metadata: %w", err)
+	}
+
+	out, err := MarshalPreserving(original, m)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, out, 0o644); err != nil {
+		return fmt.Errorf("writing metadata file: %w", err)
+	}
+	return nil
+}
+
+// MarshalPreserving returns the YAML representation of m, keeping the comments,
+// the order of the keys and the quoting style of the original file. m must be
+// the metadata of the original file updated by Populate: new entries are
+// appended with a comment and changed values are replaced in place. Entries
+// that are only in the original file are kept. Sequences are indented by two
+// spaces. Without an original file, it's the same as Marshal.
+func MarshalPreserving(original []byte, m *metadatav1.GadgetMetadata) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(original, &doc); err != nil {
+		return nil, fmt.Errorf("parsing metadata file: %w", err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return Marshal(m)
+	}
+
+	var populated yaml.Node
+	if err := populated.Encode(m); err != nil {
+		return nil, fmt.Errorf("encoding metadata: %w", err)
+	}
+
+	mergeNodes(doc.Content[0], &populated)
+
+	out, err := encodeYAML(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding metadata file: %w", err)
+	}
+	return out, nil
+}
+
+// encodeYAML returns the YAML representation of v, with sequences indented by
+// two spaces. Both new and updated metadata files are written with it.
+func encodeYAML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeNodes updates dst with the content of src
+func mergeNodes(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		mergeMappings(dst, src)
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		mergeSequences(dst, src)
+	case dst.Kind == yaml.ScalarNode && src.Kind == yaml.ScalarNode:
+		if dst.Value != src.Value || dst.Tag != src.Tag {
+			dst.Value = src.Value
+			dst.Tag = src.Tag
+		}
+	}
+}
+
+func mergeMappings(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		if existing := mappingValue(dst, key.Value); existing != nil {
+			mergeNodes(existing, value)
+			continue
+		}
+		key.HeadComment = addedComment
+		dst.Content = append(dst.Content, key, value)
+	}
+}
+
+// mergeSequences appends the items of src missing in dst. Items that are
+// mappings, like fields, are matched by their "name" key and scalars, like
+// possible values or tags, by their value.
+func mergeSequences(dst, src *yaml.Node) {
+	for _, item := range src.Content {
+		found := false
+		switch item.Kind {
+		case yaml.ScalarNode:
+			for _, existing := range dst.Content {
+				if existing.Kind == yaml.ScalarNode && existing.Value == item.Value {
+					found = true
+					break
+				}
+			}
+		case yaml.MappingNode:
+			name := mappingValue(item, "name")
+			if name == nil {
+				continue
+			}
+			for _, existing := range dst.Content {
+				if n := mappingValue(existing, "name"); n != nil && n.Value == name.Value {
+					mergeNodes(existing, item)
+					found = true
+					break
+				}
+			}
+		default:
+			continue
+		}
+		if !found {
+			item.HeadComment = addedComment
+			dst.Content = append(dst.Content, item)
+		}
+	}
+}
+
+// mappingValue returns the value of key in node or nil if node isn't a mapping
+// or doesn't have it
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestUpdateMetadataFile(t *testing.T) {
+	t.Parallel()
+
+	spec, err := ebpf.LoadCollectionSpec("../../../../testdata/populate_metadata_tracer_add_missing_field.o")
+	require.NoError(t, err)
+
+	original, err := os.ReadFile("../../../../testdata/update_metadata_file.yaml")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../../../../testdata/update_metadata_file_expected.yaml")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "gadget.yaml")
+	require.NoError(t, os.WriteFile(path, original, 0o644))
+
+	// The comments survive and only the missing field is added
+	require.NoError(t, UpdateMetadataFile(path, spec))
+	updated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(updated))
+
+	// Nothing changes when the file is up to date
+	require.NoError(t, UpdateMetadataFile(path, spec))
+	updated, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(updated))
+}
+
+func TestUpdateMetadataFileFromScratch(t *testing.T) {
+	t.Parallel()
+
+	spec, err := ebpf.LoadCollectionSpec("../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.yaml")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "gadget.yaml")
+	require.NoError(t, UpdateMetadataFile(path, spec))
+	created, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(created))
+}
+
+func TestMarshalPreservingScalarSequences(t *testing.T) {
+	t.Parallel()
+
+	original, err := os.ReadFile("../../../../testdata/update_metadata_file_sequences.yaml")
+	require.NoError(t, err)
+	expected, err := os.ReadFile("../../../../testdata/update_metadata_file_sequences_expected.yaml")
+	require.NoError(t, err)
+
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal(original, m))
+
+	// New items are appended, existing ones are matched by value
+	tracer := m.Tracers["test"]
+	tracer.DefaultColumns = append(tracer.DefaultColumns, "filename")
+	m.Tracers["test"] = tracer
+	param := m.EBPFParams["mode"]
+	param.PossibleValues = []string{"slow", "fast", "auto"}
+	m.EBPFParams["mode"] = param
+
+	out, err := MarshalPreserving(original, m)
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(out))
+}
+
+func TestMarshalPreservingModifiedValue(t *testing.T) {
+	t.Parallel()
+
+	original := []byte(`name: 'TODO: Fill the gadget name' # set me
+description: bar
+`)
+	m := &metadatav1.GadgetMetadata{
+		Name:        "foo",
+		Description: "bar",
+	}
+
+	out, err := MarshalPreserving(original, m)
+	require.NoError(t, err)
+	require.Equal(t, "name: 'foo' # set me\ndescription: bar\n", string(out))
+}
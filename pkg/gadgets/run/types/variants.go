@@ -0,0 +1,288 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/hashicorp/go-multierror"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+const (
+	// VariantField is set by TracerDecoder to the name of the struct of each
+	// event, for tracers sending several kinds of events
+	VariantField = "variant"
+
+	// unknownVariantWarningInterval is the minimum time between two warnings
+	// about events with an unknown discriminator value
+	unknownVariantWarningInterval = 10 * time.Second
+)
+
+// sortedVariants returns the discriminator values of the variants of a
+// tracer, sorted
+func sortedVariants(structNames map[int64]string) []int64 {
+	values := make([]int64, 0, len(structNames))
+	for value := range structNames {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	return values
+}
+
+// discriminatorMember returns the member of the header telling the kind of
+// each event, which must be an integer or an enum
+func discriminatorMember(header *btf.Struct, name string) (btf.Member, error) {
+	for _, member := range header.Members {
+		if member.Name != name {
+			continue
+		}
+		if !isInteger(member) && (member.BitfieldSize > 0 || enumType(member.Type) == nil) {
+			return btf.Member{}, fmt.Errorf("discriminator %q of struct %q must be an integer or an enum", name, header.Name)
+		}
+		return member, nil
+	}
+	return btf.Member{}, fmt.Errorf("discriminator %q not found in struct %q", name, header.Name)
+}
+
+// validateVariantLayout checks that the variant starts with the members of
+// the header, at the same offsets and with the same sizes
+func validateVariantLayout(header, variant *btf.Struct) (result error) {
+	if variant.Size < header.Size {
+		return fmt.Errorf("struct %q has %d bytes, less than the %d of header %q", variant.Name, variant.Size, header.Size, header.Name)
+	}
+
+	for idx, headerMember := range header.Members {
+		if idx >= len(variant.Members) {
+			result = multierror.Append(result, fmt.Errorf("struct %q doesn't have member %q of header %q", variant.Name, headerMember.Name, header.Name))
+			continue
+		}
+		member := variant.Members[idx]
+		headerSize, _ := btf.Sizeof(headerMember.Type)
+		size, _ := btf.Sizeof(member.Type)
+		if member.Name != headerMember.Name || member.Offset != headerMember.Offset ||
+			member.BitfieldSize != headerMember.BitfieldSize || size != headerSize {
+			result = multierror.Append(result, fmt.Errorf("member %d of struct %q is %q at offset %d, expected %q at offset %d like in header %q",
+				idx, variant.Name, member.Name, member.Offset.Bytes(), headerMember.Name, headerMember.Offset.Bytes(), header.Name))
+		}
+	}
+
+	for _, member := range variant.Members {
+		if member.Name == VariantField {
+			result = multierror.Append(result, fmt.Errorf("member %q of struct %q collides with the field holding the variant", member.Name, variant.Name))
+		}
+	}
+
+	return
+}
+
+// validateTracerVariants checks the kinds of events of tracers sending
+// several of them: all of them start with the header holding the
+// discriminator
+func validateTracerVariants(t metadatav1.Tracer, idx *btfIndex) (result error) {
+	if t.Discriminator == "" && len(t.StructNames) == 0 {
+		return nil
+	}
+	if t.Discriminator == "" {
+		return errors.New("structNames requires a discriminator")
+	}
+	if len(t.StructNames) == 0 {
+		return errors.New("discriminator requires structNames")
+	}
+
+	header, err := idx.structByName(t.StructName)
+	if err != nil {
+		return fmt.Errorf("finding struct %q: %w", t.StructName, err)
+	}
+	if _, err := discriminatorMember(header, t.Discriminator); err != nil {
+		result = multierror.Append(result, err)
+	}
+
+	for _, value := range sortedVariants(t.StructNames) {
+		structName := t.StructNames[value]
+		variant, err := idx.structByName(structName)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("variant %d: finding struct %q: %w", value, structName, err))
+			continue
+		}
+		if err := validateVariantLayout(header, variant); err != nil {
+			result = multierror.Append(result, fmt.Errorf("variant %d: %w", value, err))
+		}
+	}
+
+	return
+}
+
+// populateTracerVariants adds the fields of the structs of the kinds of
+// events of the tracer
+func populateTracerVariants(m *metadatav1.GadgetMetadata, t metadatav1.Tracer, idx *btfIndex, opts populateOptions, report *PopulateReport) error {
+	for _, value := range sortedVariants(t.StructNames) {
+		variant, err := idx.structByName(t.StructNames[value])
+		if err != nil {
+			return fmt.Errorf("finding struct %q of variant %d: %w", t.StructNames[value], value, err)
+		}
+		if err := populateStruct(m, variant, idx.spec.Types, opts, report); err != nil {
+			return fmt.Errorf("populating struct %q: %w", variant.Name, err)
+		}
+	}
+	return nil
+}
+
+// TracerDecoder decodes the events of a tracer. The events of tracers sending
+// several kinds of events are decoded with the struct their discriminator maps
+// to, and VariantField is set to the name of that struct.
+type TracerDecoder struct {
+	name string
+
+	// single decodes the events of tracers sending one kind of events
+	single *Decoder
+
+	headerSize    uint32
+	discriminator func([]byte) any
+	variants      map[int64]tracerVariant
+
+	mu sync.Mutex
+	// unknown counts the events with an unknown discriminator value
+	unknown uint64
+	// unreported counts the ones since the last warning
+	unreported  uint64
+	lastWarning time.Time
+	now         func() time.Time
+}
+
+type tracerVariant struct {
+	name    string
+	decoder *Decoder
+}
+
+// NewTracerDecoder returns a decoder for the events of the tracer with the
+// given name, whose structs are looked up in spec
+func NewTracerDecoder(m *metadatav1.GadgetMetadata, spec *btf.Spec, name string, opts ...DecoderOption) (*TracerDecoder, error) {
+	t, ok := m.Tracers[name]
+	if !ok {
+		return nil, fmt.Errorf("tracer %q not found", name)
+	}
+
+	var header *btf.Struct
+	if err := spec.TypeByName(t.StructName, &header); err != nil {
+		return nil, fmt.Errorf("tracer %q: finding struct %q: %w", name, t.StructName, err)
+	}
+
+	d := &TracerDecoder{
+		name: name,
+		now:  time.Now,
+	}
+
+	if t.Discriminator == "" {
+		single, err := NewDecoder(m, header, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("tracer %q: %w", name, err)
+		}
+		d.single = single
+		return d, nil
+	}
+
+	member, err := discriminatorMember(header, t.Discriminator)
+	if err != nil {
+		return nil, fmt.Errorf("tracer %q: %w", name, err)
+	}
+	size, err := btf.Sizeof(member.Type)
+	if err != nil {
+		return nil, fmt.Errorf("tracer %q: getting size of discriminator: %w", name, err)
+	}
+	d.discriminator, err = intDecoder(member.Offset.Bytes(), uint32(size), btfhelpers.IsSigned(member.Type))
+	if err != nil {
+		return nil, fmt.Errorf("tracer %q: discriminator: %w", name, err)
+	}
+	d.headerSize = header.Size
+
+	d.variants = make(map[int64]tracerVariant, len(t.StructNames))
+	for _, value := range sortedVariants(t.StructNames) {
+		var variant *btf.Struct
+		if err := spec.TypeByName(t.StructNames[value], &variant); err != nil {
+			return nil, fmt.Errorf("tracer %q: variant %d: finding struct %q: %w", name, value, t.StructNames[value], err)
+		}
+		if err := validateVariantLayout(header, variant); err != nil {
+			return nil, fmt.Errorf("tracer %q: variant %d: %w", name, value, err)
+		}
+		decoder, err := NewDecoder(m, variant, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("tracer %q: variant %d: %w", name, value, err)
+		}
+		d.variants[value] = tracerVariant{name: variant.Name, decoder: decoder}
+	}
+
+	return d, nil
+}
+
+// Decode returns the value of each field of the event in b, by field name.
+// Events with an unknown discriminator value are dropped: Decode returns nil
+// without error, counts them and warns about them at most every 10 seconds.
+func (d *TracerDecoder) Decode(b []byte) (map[string]any, error) {
+	if d.single != nil {
+		return d.single.Decode(b)
+	}
+
+	if len(b) < int(d.headerSize) {
+		return nil, fmt.Errorf("tracer %q: buffer has %d bytes, expected at least %d", d.name, len(b), d.headerSize)
+	}
+
+	value := toInt64(d.discriminator(b))
+	variant, ok := d.variants[value]
+	if !ok {
+		d.dropUnknown(value)
+		return nil, nil
+	}
+
+	out, err := variant.decoder.Decode(b)
+	if err != nil {
+		return nil, fmt.Errorf("tracer %q: variant %q: %w", d.name, variant.name, err)
+	}
+	out[VariantField] = variant.name
+	return out, nil
+}
+
+// Unknown returns the number of events dropped because of an unknown
+// discriminator value
+func (d *TracerDecoder) Unknown() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.unknown
+}
+
+func (d *TracerDecoder) dropUnknown(value int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.unknown++
+	d.unreported++
+
+	now := d.now()
+	if !d.lastWarning.IsZero() && now.Sub(d.lastWarning) < unknownVariantWarningInterval {
+		return
+	}
+	log.Warnf("Tracer %q: dropped %d events with unknown discriminator values, like %d (%d in total)",
+		d.name, d.unreported, value, d.unknown)
+	d.lastWarning = now
+	d.unreported = 0
+}
@@ -0,0 +1,287 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package types
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// Fixtures of a tracer sending small "open" events and bigger "exec" ones,
+// both starting with the header holding the kind of the event
+type testVariantHeader struct {
+	Kind uint32
+	Pid  uint32
+}
+
+type testOpenEvent struct {
+	testVariantHeader
+	Flags uint32
+	Fname [8]byte
+}
+
+type testExecEvent struct {
+	testVariantHeader
+	Ppid uint32
+	Comm [16]byte
+	Uid  uint32
+}
+
+func variantsTypes() []btf.Type {
+	kind := &btf.Enum{
+		Name: "event_kind",
+		Size: 4,
+		Values: []btf.EnumValue{
+			{Name: "EVENT_OPEN", Value: 1},
+			{Name: "EVENT_EXEC", Value: 2},
+		},
+	}
+	headerMembers := func() []btf.Member {
+		return []btf.Member{
+			{Name: "kind", Type: kind, Offset: 0},
+			{Name: "pid", Type: u32Type, Offset: 4 * 8},
+		}
+	}
+	return []btf.Type{
+		&btf.Struct{Name: "event_header", Size: 8, Members: headerMembers()},
+		&btf.Struct{Name: "open_event", Size: 20, Members: append(headerMembers(),
+			btf.Member{Name: "flags", Type: u32Type, Offset: 8 * 8},
+			btf.Member{Name: "fname", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 8}, Offset: 12 * 8},
+		)},
+		&btf.Struct{Name: "exec_event", Size: 32, Members: append(headerMembers(),
+			btf.Member{Name: "ppid", Type: u32Type, Offset: 8 * 8},
+			btf.Member{Name: "comm", Type: &btf.Array{Index: u32Type, Type: charType, Nelems: 16}, Offset: 12 * 8},
+			btf.Member{Name: "uid", Type: u32Type, Offset: 28 * 8},
+		)},
+		// The header members are swapped
+		&btf.Struct{Name: "bad_event", Size: 12, Members: []btf.Member{
+			{Name: "pid", Type: u32Type, Offset: 0},
+			{Name: "kind", Type: kind, Offset: 4 * 8},
+			{Name: "extra", Type: u32Type, Offset: 8 * 8},
+		}},
+		&btf.Struct{Name: "short_event", Size: 4, Members: []btf.Member{
+			{Name: "kind", Type: kind, Offset: 0},
+		}},
+		&btf.Struct{Name: "colliding_event", Size: 12, Members: append(headerMembers(),
+			btf.Member{Name: "variant", Type: u32Type, Offset: 8 * 8},
+		)},
+	}
+}
+
+func variantsBTFSpec(t *testing.T) *btf.Spec {
+	t.Helper()
+
+	return newTestBTF(t, variantsTypes()...)
+}
+
+func variantsMetadata() *metadatav1.GadgetMetadata {
+	return &metadatav1.GadgetMetadata{
+		Tracers: map[string]metadatav1.Tracer{
+			"events": {
+				MapName:       "events",
+				StructName:    "event_header",
+				Discriminator: "kind",
+				StructNames: map[int64]string{
+					1: "open_event",
+					2: "exec_event",
+				},
+			},
+		},
+	}
+}
+
+func eventBytes(t *testing.T, ev any) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, binary.Write(&buf, byteOrder, ev))
+	return buf.Bytes()
+}
+
+func TestValidateTracerVariants(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		tracer            metadatav1.Tracer
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"good": {
+			tracer: variantsMetadata().Tracers["events"],
+		},
+		"single_struct": {
+			tracer: metadatav1.Tracer{StructName: "open_event"},
+		},
+		"missing_discriminator": {
+			tracer:            metadatav1.Tracer{StructName: "event_header", StructNames: map[int64]string{1: "open_event"}},
+			expectedErrString: "structNames requires a discriminator",
+		},
+		"missing_struct_names": {
+			tracer:            metadatav1.Tracer{StructName: "event_header", Discriminator: "kind"},
+			expectedErrString: "discriminator requires structNames",
+		},
+		"unknown_discriminator": {
+			tracer: metadatav1.Tracer{
+				StructName:    "event_header",
+				Discriminator: "type",
+				StructNames:   map[int64]string{1: "open_event"},
+			},
+			expectedErrString: "discriminator \"type\" not found in struct \"event_header\"",
+		},
+		"unknown_struct": {
+			tracer: metadatav1.Tracer{
+				StructName:    "event_header",
+				Discriminator: "kind",
+				StructNames:   map[int64]string{3: "exit_event"},
+			},
+			expectedErrString: "variant 3: finding struct \"exit_event\"",
+		},
+		"different_layout": {
+			tracer: metadatav1.Tracer{
+				StructName:    "event_header",
+				Discriminator: "kind",
+				StructNames:   map[int64]string{1: "bad_event"},
+			},
+			expectedErrString: "member 0 of struct \"bad_event\" is \"pid\" at offset 0, expected \"kind\" at offset 0",
+		},
+		"smaller_than_header": {
+			tracer: metadatav1.Tracer{
+				StructName:    "event_header",
+				Discriminator: "kind",
+				StructNames:   map[int64]string{1: "short_event"},
+			},
+			expectedErrString: "struct \"short_event\" has 4 bytes, less than the 8 of header \"event_header\"",
+		},
+		"colliding_member": {
+			tracer: metadatav1.Tracer{
+				StructName:    "event_header",
+				Discriminator: "kind",
+				StructNames:   map[int64]string{1: "colliding_event"},
+			},
+			expectedErrString: "member \"variant\" of struct \"colliding_event\" collides with the field holding the variant",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			idx := newBTFIndex(&ebpf.CollectionSpec{Types: variantsBTFSpec(t)})
+			err := validateTracerVariants(test.tracer, idx)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestTracerDecoderVariants(t *testing.T) {
+	t.Parallel()
+
+	d, err := NewTracerDecoder(variantsMetadata(), variantsBTFSpec(t), "events")
+	require.NoError(t, err)
+
+	open := testOpenEvent{testVariantHeader: testVariantHeader{Kind: 1, Pid: 42}, Flags: 0x241}
+	copy(open.Fname[:], "/etc/x")
+	out, err := d.Decode(eventBytes(t, open))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"kind":       "EVENT_OPEN",
+		"pid":        uint32(42),
+		"flags":      uint32(0x241),
+		"fname":      "/etc/x",
+		VariantField: "open_event",
+	}, out)
+
+	exec := testExecEvent{testVariantHeader: testVariantHeader{Kind: 2, Pid: 43}, Ppid: 1, Uid: 1000}
+	copy(exec.Comm[:], "bash")
+	out, err = d.Decode(eventBytes(t, exec))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{
+		"kind":       "EVENT_EXEC",
+		"pid":        uint32(43),
+		"ppid":       uint32(1),
+		"comm":       "bash",
+		"uid":        uint32(1000),
+		VariantField: "exec_event",
+	}, out)
+
+	// A corrupt record, shorter than the header, is an error
+	_, err = d.Decode(eventBytes(t, open)[:6])
+	require.ErrorContains(t, err, "tracer \"events\": buffer has 6 bytes, expected at least 8")
+
+	// So is an event shorter than its variant
+	_, err = d.Decode(eventBytes(t, exec)[:20])
+	require.ErrorContains(t, err, "tracer \"events\": variant \"exec_event\": buffer has 20 bytes, expected at least 32")
+
+	// Events with an unknown discriminator value are dropped and counted
+	now := time.Unix(1000, 0)
+	d.now = func() time.Time { return now }
+
+	unknown := open
+	unknown.Kind = 7
+	for i := 0; i < 3; i++ {
+		out, err = d.Decode(eventBytes(t, unknown))
+		require.NoError(t, err)
+		require.Nil(t, out)
+	}
+	require.Equal(t, uint64(3), d.Unknown())
+	// Only the first one was reported
+	require.Equal(t, uint64(2), d.unreported)
+	require.Equal(t, now, d.lastWarning)
+
+	now = now.Add(unknownVariantWarningInterval)
+	_, err = d.Decode(eventBytes(t, unknown))
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), d.Unknown())
+	require.Zero(t, d.unreported)
+	require.Equal(t, now, d.lastWarning)
+
+	// The stream goes on
+	out, err = d.Decode(eventBytes(t, open))
+	require.NoError(t, err)
+	require.Equal(t, "open_event", out[VariantField])
+}
+
+func TestTracerDecoderSingleStruct(t *testing.T) {
+	t.Parallel()
+
+	m := &metadatav1.GadgetMetadata{
+		Tracers: map[string]metadatav1.Tracer{
+			"events": {MapName: "events", StructName: "event_header"},
+		},
+	}
+	d, err := NewTracerDecoder(m, variantsBTFSpec(t), "events")
+	require.NoError(t, err)
+
+	out, err := d.Decode(eventBytes(t, testVariantHeader{Kind: 1, Pid: 42}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]any{"kind": "EVENT_OPEN", "pid": uint32(42)}, out)
+
+	_, err = NewTracerDecoder(m, variantsBTFSpec(t), "other")
+	require.ErrorContains(t, err, "tracer \"other\" not found")
+}
@@ -45,7 +45,7 @@ type Histogram struct {
 
 // NewIntervalsFromExp2Slots creates a new Interval array from an exp-2
 // histogram represented in slots.
-func NewIntervalsFromExp2Slots(slots []uint32) []Interval {
+func NewIntervalsFromExp2Slots[T uint32 | uint64](slots []T) []Interval {
 	if len(slots) == 0 {
 		return nil
 	}
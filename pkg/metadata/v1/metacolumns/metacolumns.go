@@ -0,0 +1,232 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metacolumns creates the column definitions of the structs described
+// by the metadata of a gadget, so that consumers showing their events as
+// columns don't each map the field attributes to the columns library.
+package metacolumns
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/ellipsis"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// defaultPrecision is the number of decimals shown for float fields without
+// precision, as in the columns library
+const defaultPrecision = 2
+
+// Event is an event decoded by EventColumns.Decode, holding the value of each
+// field by name
+type Event struct {
+	Values map[string]any
+}
+
+// EventColumns are the columns of a struct and the decoder filling the events
+// they extract values from
+type EventColumns struct {
+	*columns.Columns[Event]
+	decoder *types.Decoder
+}
+
+// Decode decodes the raw event in b, as read from the tracer map
+func (c *EventColumns) Decode(b []byte) (*Event, error) {
+	values, err := c.decoder.Decode(b)
+	if err != nil {
+		return nil, err
+	}
+	return &Event{Values: values}, nil
+}
+
+// Columns returns the columns of the fields of the struct named structName,
+// whose layout is looked up in spec. Columns are created in the order of the
+// fields and follow their width, alignment, ellipsis, visibility, template and
+// order attributes. Hidden fields get columns too, not shown by default. The
+// data following the struct, if any, gets the last column.
+func Columns(m *metadatav1.GadgetMetadata, spec *btf.Spec, structName string) (*EventColumns, error) {
+	s, ok := m.Structs[structName]
+	if !ok {
+		return nil, fmt.Errorf("struct %q not found in metadata", structName)
+	}
+
+	var btfStruct *btf.Struct
+	if err := spec.TypeByName(structName, &btfStruct); err != nil {
+		return nil, fmt.Errorf("finding struct %q: %w", structName, err)
+	}
+
+	decoder, err := types.NewDecoder(m, btfStruct, types.WithHiddenFields())
+	if err != nil {
+		return nil, err
+	}
+
+	// The values of a zeroed event give the type of each column
+	zero, err := decoder.Decode(make([]byte, btfStruct.Size))
+	if err != nil {
+		return nil, fmt.Errorf("struct %q: %w", structName, err)
+	}
+
+	cols, err := columns.NewColumns[Event]()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]metadatav1.Field, 0, len(s.Fields)+1)
+	for _, field := range s.Fields {
+		// Fields without value of their own, like void ones, aren't decoded
+		if _, ok := zero[field.Name]; ok {
+			fields = append(fields, field)
+		}
+	}
+	if s.Trailer != nil {
+		fields = append(fields, metadatav1.Field{Name: s.Trailer.Name})
+	}
+
+	orders := columnOrders(fields)
+	for i, field := range fields {
+		attributes, err := fieldAttributes(field, zero[field.Name])
+		if err != nil {
+			return nil, fmt.Errorf("struct %q: field %q: %w", structName, field.Name, err)
+		}
+		attributes.Order = orders[i]
+
+		name := field.Name
+		prototype := zero[name]
+		err = cols.AddColumn(attributes, func(e *Event) any {
+			if value, ok := e.Values[name]; ok {
+				return value
+			}
+			return prototype
+		})
+		if err != nil {
+			return nil, fmt.Errorf("struct %q: field %q: %w", structName, field.Name, err)
+		}
+	}
+
+	return &EventColumns{Columns: cols, decoder: decoder}, nil
+}
+
+// fieldAttributes returns the attributes of the column of field, whose values
+// are like prototype
+func fieldAttributes(field metadatav1.Field, prototype any) (columns.Attributes, error) {
+	attrs := field.Attributes
+
+	if err := validateWidths(attrs); err != nil {
+		return columns.Attributes{}, err
+	}
+	if attrs.Template != "" && !columns.HasTemplate(attrs.Template) {
+		return columns.Attributes{}, fmt.Errorf("unknown template %q", attrs.Template)
+	}
+
+	attributes := columns.Attributes{
+		Name:        field.Name,
+		Description: field.Description,
+		Width:       int(attrs.Width),
+		MinWidth:    int(attrs.MinWidth),
+		MaxWidth:    int(attrs.MaxWidth),
+		AutoWidth:   attrs.AutoWidth,
+		Visible:     !attrs.Hidden,
+		Precision:   defaultPrecision,
+		Template:    attrs.Template,
+		Group:       field.Group,
+	}
+	if attributes.Width == 0 {
+		attributes.Width = columns.GetWidthFromType(reflect.ValueOf(prototype).Kind())
+	}
+	if attributes.Width == 0 {
+		attributes.Width = metadatav1.DefaultColumnWidth
+	}
+	if attrs.Precision != nil {
+		attributes.Precision = int(*attrs.Precision)
+	}
+
+	switch attrs.Alignment {
+	case metadatav1.AlignmenNone, metadatav1.AlignmentLeft:
+		attributes.Alignment = columns.AlignLeft
+	case metadatav1.AlignmentRight:
+		attributes.Alignment = columns.AlignRight
+	default:
+		return columns.Attributes{}, fmt.Errorf("invalid alignment %q", attrs.Alignment)
+	}
+
+	switch attrs.Ellipsis {
+	case metadatav1.EllipsisNone:
+		attributes.EllipsisType = ellipsis.None
+	case metadatav1.EllipsisStart:
+		attributes.EllipsisType = ellipsis.Start
+	case metadatav1.EllipsisMiddle:
+		attributes.EllipsisType = ellipsis.Middle
+	case metadatav1.EllipsisEnd:
+		attributes.EllipsisType = ellipsis.End
+	default:
+		return columns.Attributes{}, fmt.Errorf("invalid ellipsis %q", attrs.Ellipsis)
+	}
+
+	return attributes, nil
+}
+
+// validateWidths checks that the widths of a column don't contradict each
+// other
+func validateWidths(attrs metadatav1.FieldAttributes) error {
+	if attrs.AutoWidth {
+		if attrs.MaxWidth == 0 {
+			return errors.New("autoWidth requires maxWidth")
+		}
+		if attrs.Width != 0 {
+			return fmt.Errorf("width must be 0 when autoWidth is set, got %d", attrs.Width)
+		}
+	}
+	if attrs.MaxWidth == 0 {
+		return nil
+	}
+	if attrs.MinWidth > attrs.MaxWidth {
+		return fmt.Errorf("minWidth %d is bigger than maxWidth %d", attrs.MinWidth, attrs.MaxWidth)
+	}
+	if attrs.Width > attrs.MaxWidth {
+		return fmt.Errorf("width %d is bigger than maxWidth %d", attrs.Width, attrs.MaxWidth)
+	}
+	return nil
+}
+
+// columnOrders returns the order of the column of each field: fields are
+// sorted by their order attribute, keeping their position for equal ones
+func columnOrders(fields []metadatav1.Field) []int {
+	order := func(field metadatav1.Field) int32 {
+		if field.Attributes.Order == nil {
+			return 0
+		}
+		return *field.Attributes.Order
+	}
+
+	positions := make([]int, len(fields))
+	for i := range positions {
+		positions[i] = i
+	}
+	sort.SliceStable(positions, func(i, j int) bool {
+		return order(fields[positions[i]]) < order(fields[positions[j]])
+	})
+
+	orders := make([]int, len(fields))
+	for pos, i := range positions {
+		orders[i] = pos * 100
+	}
+	return orders
+}
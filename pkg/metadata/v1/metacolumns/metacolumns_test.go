@@ -0,0 +1,202 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metacolumns
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/ellipsis"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+const (
+	metadataPath = "../../../../testdata/metacolumns_event.yaml"
+	objectPath   = "../../../../testdata/populate_metadata_1_tracer_1_struct_from_scratch.o"
+)
+
+func loadTestdata(t *testing.T) (*metadatav1.GadgetMetadata, *btf.Spec) {
+	t.Helper()
+
+	content, err := os.ReadFile(metadataPath)
+	require.NoError(t, err)
+	m := &metadatav1.GadgetMetadata{}
+	require.NoError(t, yaml.Unmarshal(content, m))
+
+	spec, err := ebpf.LoadCollectionSpec(objectPath)
+	require.NoError(t, err)
+
+	return m, spec.Types
+}
+
+func TestColumns(t *testing.T) {
+	t.Parallel()
+
+	m, spec := loadTestdata(t)
+
+	cols, err := Columns(m, spec, "event")
+	require.NoError(t, err)
+
+	expected := map[string]columns.Attributes{
+		"pid": {
+			Name:         "pid",
+			Description:  "Process ID",
+			Width:        10,
+			MinWidth:     7, // Set by the pid template
+			Alignment:    columns.AlignRight,
+			Visible:      true,
+			EllipsisType: ellipsis.End,
+			Precision:    2,
+			Order:        100,
+			Template:     "pid",
+		},
+		"comm": {
+			Name:         "comm",
+			Description:  "Process name",
+			Width:        metadatav1.DefaultColumnWidth,
+			MaxWidth:     16,
+			Alignment:    columns.AlignLeft,
+			Visible:      false,
+			EllipsisType: ellipsis.End,
+			Precision:    2,
+			Order:        200,
+		},
+		"filename": {
+			Name:         "filename",
+			Description:  "Opened file",
+			Width:        32,
+			MinWidth:     8,
+			MaxWidth:     255,
+			Alignment:    columns.AlignLeft,
+			Visible:      true,
+			EllipsisType: ellipsis.Middle,
+			Precision:    2,
+			Order:        0,
+		},
+	}
+
+	require.Len(t, cols.GetColumnMap(), len(expected))
+	for name, attributes := range expected {
+		column, ok := cols.GetColumn(name)
+		require.True(t, ok, "column %q not found", name)
+		require.Equal(t, attributes, column.Attributes, "column %q", name)
+	}
+
+	// filename comes first, having a lower order
+	require.Equal(t, []string{"filename", "pid", "comm"}, cols.GetColumnNames())
+}
+
+func TestColumnsValues(t *testing.T) {
+	t.Parallel()
+
+	m, spec := loadTestdata(t)
+
+	cols, err := Columns(m, spec, "event")
+	require.NoError(t, err)
+
+	// 1 byte of trailing padding
+	raw := make([]byte, 4+16+255+1)
+	raw[0] = 42
+	copy(raw[4:], "cat")
+	copy(raw[20:], "/etc/passwd")
+
+	event, err := cols.Decode(raw)
+	require.NoError(t, err)
+
+	values := make(map[string]any)
+	for name, column := range cols.GetColumnMap() {
+		values[name] = column.Extractor(event)
+	}
+	require.Equal(t, map[string]any{
+		"pid":      uint32(42),
+		"comm":     "cat",
+		"filename": "/etc/passwd",
+	}, values)
+
+	// Missing values are zero
+	require.Equal(t, uint32(0), cols.ColumnMap["pid"].Extractor(&Event{}))
+
+	_, err = cols.Decode(raw[:10])
+	require.ErrorContains(t, err, "buffer has 10 bytes, expected at least 276")
+}
+
+func TestColumnsErrors(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		structName        string
+		attributes        metadatav1.FieldAttributes
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"unknown_struct": {
+			structName:        "evnet",
+			expectedErrString: "struct \"evnet\" not found in metadata",
+		},
+		"unknown_template": {
+			attributes:        metadatav1.FieldAttributes{Template: "procname"},
+			expectedErrString: "struct \"event\": field \"filename\": unknown template \"procname\"",
+		},
+		"invalid_alignment": {
+			attributes:        metadatav1.FieldAttributes{Alignment: "center"},
+			expectedErrString: "struct \"event\": field \"filename\": invalid alignment \"center\"",
+		},
+		"invalid_ellipsis": {
+			attributes:        metadatav1.FieldAttributes{Ellipsis: "both"},
+			expectedErrString: "struct \"event\": field \"filename\": invalid ellipsis \"both\"",
+		},
+		"auto_width_without_max_width": {
+			attributes:        metadatav1.FieldAttributes{AutoWidth: true},
+			expectedErrString: "struct \"event\": field \"filename\": autoWidth requires maxWidth",
+		},
+		"auto_width_with_width": {
+			attributes:        metadatav1.FieldAttributes{AutoWidth: true, Width: 32, MaxWidth: 64},
+			expectedErrString: "struct \"event\": field \"filename\": width must be 0 when autoWidth is set, got 32",
+		},
+		"min_width_above_max_width": {
+			attributes:        metadatav1.FieldAttributes{MinWidth: 64, MaxWidth: 32},
+			expectedErrString: "struct \"event\": field \"filename\": minWidth 64 is bigger than maxWidth 32",
+		},
+		"width_above_max_width": {
+			attributes:        metadatav1.FieldAttributes{Width: 64, MaxWidth: 32},
+			expectedErrString: "struct \"event\": field \"filename\": width 64 is bigger than maxWidth 32",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m, spec := loadTestdata(t)
+			fields := m.Structs["event"].Fields
+			fields[2].Attributes = test.attributes
+
+			structName := test.structName
+			if structName == "" {
+				structName = "event"
+			}
+			_, err := Columns(m, spec, structName)
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -14,17 +14,54 @@
 
 package metadatav1
 
-import "github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+import (
+	"slices"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
 
 // Tracer describes the behavior of a gadget that collects and sends events to user space
 // TODO: We need to rename this concept not to collide with the opentelemetry concept
 type Tracer struct {
 	// Name of the perf event array or ring buffer that the gadget uses to send events
 	MapName string `yaml:"mapName"`
-	// Name of the structure generated by this tracer
+	// Name of the structure generated by this tracer. For tracers sending several kinds of
+	// events, it's the header all of them start with, holding the discriminator.
 	StructName string `yaml:"structName"`
+	// Fields shown by default in columns output. All non-hidden fields are
+	// shown if empty.
+	DefaultColumns []string `yaml:"defaultColumns,omitempty"`
+	// Discriminator is the integer or enum member of StructName telling the kind of each event,
+	// for tracers sending several kinds of events on the same map
+	Discriminator string `yaml:"discriminator,omitempty"`
+	// StructNames maps the values of Discriminator to the struct of the events of that kind. All
+	// of them start with the members of StructName, laid out the same way.
+	StructNames map[int64]string `yaml:"structNames,omitempty"`
+	// Aggregation collapses the events with the same key fields received within a window into
+	// one, to keep high-volume tracers readable
+	Aggregation *Aggregation `yaml:"aggregation,omitempty"`
+}
+
+// Aggregation describes how the events of a tracer are collapsed. The events received within a
+// window are grouped by the values of the fields with the key attribute and one event is emitted
+// per group, the first one received, with the number of events of the group. The other integer
+// fields can set the aggregate attribute to combine the values of the group instead.
+type Aggregation struct {
+	// Window events are collected during before being emitted, like "1s"
+	Window string `yaml:"window"`
+	// CountField is the name of the field added with the number of events of each group
+	CountField string `yaml:"countField"`
 }
 
+// AggregateFunc combines the values of an integer field of the events collapsed into one
+type AggregateFunc string
+
+const (
+	AggregateSum AggregateFunc = "sum"
+	AggregateMax AggregateFunc = "max"
+	AggregateMin AggregateFunc = "min"
+)
+
 // Topper describes the behavior of a gadget that shows the current activity
 // sorted by the highest to the lowest in the resource being observed.
 type Topper struct {
@@ -32,11 +69,74 @@ type Topper struct {
 	MapName string `yaml:"mapName"`
 	// Name of the structure generated by this topper
 	StructName string `yaml:"structName"`
+	// Name of the structure used as key of the map
+	KeyStructName string `yaml:"keyStructName,omitempty"`
+	// SortField is the integer field of the struct used to sort the entries, from the highest
+	// to the lowest value. The first integer field is used if it's not set.
+	SortField string `yaml:"sortField,omitempty"`
+	// Interval is the default interval to read the map at, like "1s"
+	Interval string `yaml:"interval,omitempty"`
+}
+
+// Profiler describes the behavior of a gadget that aggregates a value, like a latency, in log2
+// histograms and reports them periodically.
+type Profiler struct {
+	// Name of the array or hash map holding the histograms. Its values are an array of __u64
+	// slots, or a struct with such an array as its only member. Slot i counts the values between
+	// 2^i and 2^(i+1)-1.
+	MapName string `yaml:"mapName"`
+	// Unit of the value counted in the slots, like ns or bytes
+	Unit Unit `yaml:"unit,omitempty"`
+	// Name of the structure used as key of the map, for histograms per key, like per disk. Its
+	// fields are shown next to each histogram.
+	KeyStructName string `yaml:"keyStructName,omitempty"`
+	// Interval is the default interval to report the histograms at, like "1s"
+	Interval string `yaml:"interval,omitempty"`
 }
 
+// SnapshotterMode is how a snapshotter taking snapshots periodically reports them
+type SnapshotterMode string
+
+const (
+	// SnapshotterModeReplace snapshotters report all the entries of each snapshot, columns
+	// output redraws the table
+	SnapshotterModeReplace SnapshotterMode = "replace"
+	// SnapshotterModeDiff snapshotters only report the entries added, removed or changed since
+	// the previous snapshot, matched by their key fields. Each entry has an op field telling
+	// which of them it is.
+	SnapshotterModeDiff SnapshotterMode = "diff"
+)
+
+// SnapshotterSource is where a snapshotter gets its entries from
+type SnapshotterSource string
+
+const (
+	// SnapshotterSourceIterator snapshotters run BPF iterator programs
+	SnapshotterSourceIterator SnapshotterSource = "iterator"
+	// SnapshotterSourceMap snapshotters dump the entries of a map
+	SnapshotterSourceMap SnapshotterSource = "map"
+)
+
 // Snapshotter describes the behavior of a gadget that collects the state of a subsystem
 type Snapshotter struct {
+	// StructName is the struct generated by the iterators, or the value of the map of
+	// snapshotters dumping a map
 	StructName string `yaml:"structName"`
+	// Fields shown by default in columns output. All non-hidden fields are
+	// shown if empty.
+	DefaultColumns []string `yaml:"defaultColumns,omitempty"`
+	// Source of the entries, iterator if empty
+	Source SnapshotterSource `yaml:"source,omitempty"`
+	// MapName is the map dumped by snapshotters whose source is map
+	MapName string `yaml:"mapName,omitempty"`
+	// KeyStructName is the key of the map dumped by the snapshotter. Its fields come first in each
+	// entry, followed by the ones of the value. Empty for arrays.
+	KeyStructName string `yaml:"keyStructName,omitempty"`
+	// Interval is the default interval to take the snapshot again at, like "5s". The snapshot is
+	// taken only once if it's not set.
+	Interval string `yaml:"interval,omitempty"`
+	// Mode is how the snapshots taken periodically are reported, replace if empty
+	Mode SnapshotterMode `yaml:"mode,omitempty"`
 }
 
 const (
@@ -51,6 +151,13 @@ const (
 	AlignmentRight Alignment = "right"
 )
 
+type Base string
+
+const (
+	BaseDecimal Base = ""
+	BaseHex     Base = "hex"
+)
+
 type EllipsisType string
 
 const (
@@ -60,6 +167,28 @@ const (
 	EllipsisEnd    EllipsisType = "end"
 )
 
+// Unit is the unit of the value of a numeric field
+type Unit string
+
+const (
+	UnitNone         Unit = ""
+	UnitBytes        Unit = "bytes"
+	UnitNanoseconds  Unit = "ns"
+	UnitMicroseconds Unit = "us"
+	UnitMilliseconds Unit = "ms"
+	UnitCount        Unit = "count"
+)
+
+// Encoding is how the bytes of an array field are shown
+type Encoding string
+
+const (
+	// EncodingUTF8 shows the bytes as text, up to the first NUL byte
+	EncodingUTF8   Encoding = "utf8"
+	EncodingHex    Encoding = "hex"
+	EncodingBase64 Encoding = "base64"
+)
+
 // FieldAttributes describes how to format a field. It's almost 1:1 mapping with columns.Attributes,
 // however we are keeping this separated because we don't want to create a strong coupling with the
 // columns library now. Later on we can consider merging both of them.
@@ -70,6 +199,10 @@ type FieldAttributes struct {
 	MinWidth uint `yaml:"minWidth,omitempty"`
 	// MaxWidth is the maximum width for this field
 	MaxWidth uint `yaml:"maxWidth,omitempty"`
+	// AutoWidth sizes the column from the values it shows, between MinWidth and MaxWidth. Width
+	// must be 0 and MaxWidth is required. See textcolumns.Options.AutoWidthSamples for how the
+	// width is measured.
+	AutoWidth bool `yaml:"autoWidth,omitempty"`
 	// Alignment of this column (left or right)
 	Alignment Alignment `yaml:"alignment,omitempty"`
 	// Hidden defines whether a column is to be hid by default
@@ -80,6 +213,60 @@ type FieldAttributes struct {
 	// Template defines the template that will be used.
 	// TODO: add a link to existing templates
 	Template string `yaml:"template,omitempty"`
+	// Precision defines how many decimals are shown for float fields
+	Precision *uint `yaml:"precision,omitempty"`
+	// Order sets the position of the column relative to the other ones: columns are sorted by
+	// order, columns with the same order keep the order of the fields. Fields without order use
+	// 0. The container enrichment columns use orders from -30 to -20, so fields with an order
+	// below -30 are shown before them.
+	Order *int32 `yaml:"order,omitempty"`
+	// Base in which numbers are shown. Pointers are always shown in hex.
+	Base Base `yaml:"base,omitempty"`
+	// FlagsMaxWidth caps the width of the column showing the flags set in a bitmask field. A
+	// default cap is used if it's not set: all the flags are rarely set at once.
+	FlagsMaxWidth uint `yaml:"flagsMaxWidth,omitempty"`
+	// Unit of a numeric field (bytes, ns, us, ms or count)
+	Unit Unit `yaml:"unit,omitempty"`
+	// Humanize shows numeric fields with a unit in a human-readable form, like
+	// 1.4 MiB or 2.3 ms, instead of the raw value
+	Humanize bool `yaml:"humanize,omitempty"`
+	// Filterable defines whether the field can be used in filter expressions. Fields are
+	// filterable if it's not set.
+	Filterable *bool `yaml:"filterable,omitempty"`
+	// Sortable defines whether the field can be used to sort entries. Fields are sortable
+	// if it's not set.
+	Sortable *bool `yaml:"sortable,omitempty"`
+	// IPVersion marks the field as an IP address of the given version (4 or 6), stored in
+	// network byte order. It's shown using the "ipaddr" template.
+	IPVersion uint `yaml:"ipversion,omitempty"`
+	// StackMap is the name of the stack trace map this field holds ids of. The field is
+	// shown as the symbolized stack instead of the raw id.
+	StackMap string `yaml:"stackMap,omitempty"`
+	// BoolLabels are shown in columns instead of the value of boolean fields, like yes and no.
+	// JSON keeps the raw value.
+	BoolLabels *BoolLabels `yaml:"boolLabels,omitempty"`
+	// ZeroAs is shown in columns instead of the zero value of scalar fields, like "-" for
+	// optional values. JSON keeps the raw value.
+	ZeroAs string `yaml:"zeroAs,omitempty"`
+	// Encoding of byte array fields: utf8 (default), hex or base64. Use hex or base64 for arrays
+	// holding binary data instead of text.
+	Encoding Encoding `yaml:"encoding,omitempty"`
+	// Redact hides the value of sensitive string fields in all outputs: "full" replaces it,
+	// "keepPrefix:<n>" keeps its first n characters and "hash" replaces it with a short hash.
+	Redact string `yaml:"redact,omitempty"`
+	// Key marks the fields identifying the entries of a snapshotter, used to match them between
+	// snapshots in diff mode. The fields of the key struct of snapshotters dumping a map are used
+	// if none is set. For tracers with aggregation, they are the fields events are grouped by.
+	Key bool `yaml:"key,omitempty"`
+	// Aggregate combines the values of an integer field of the events collapsed by the
+	// aggregation of a tracer: sum, max or min. The value of the first event is kept if empty.
+	Aggregate AggregateFunc `yaml:"aggregate,omitempty"`
+}
+
+// BoolLabels are the labels of the values of a boolean field
+type BoolLabels struct {
+	True  string `yaml:"true"`
+	False string `yaml:"false"`
 }
 
 type Field struct {
@@ -87,27 +274,239 @@ type Field struct {
 	Name string `yaml:"name"`
 	// Field description
 	Description string `yaml:"description,omitempty"`
+	// Examples of values of the field, as shown to users. They are included in the generated
+	// documentation.
+	Examples []string `yaml:"examples,omitempty"`
 	// Attributes defines how the field should be formatted
 	Attributes FieldAttributes `yaml:"attributes,omitempty"`
 	// Annotations represents extra information that is not relevant to Inspektor Gadget, but
 	// for other applications, like color font for instance.
 	Annotations map[string]interface{} `yaml:"annotations,omitempty"`
+	// Values maps the numeric values of an enum or integer field to the labels to be shown
+	// instead. Values of unsigned 64-bit fields above math.MaxInt64 are stored as their two's
+	// complement. For enums, they take precedence over the names of the enumerators.
+	Values map[int64]string `yaml:"values,omitempty"`
+	// DefaultLabel is shown for values not found in Values
+	DefaultLabel string `yaml:"defaultLabel,omitempty"`
+	// Flags names the bits of a bitmask field. The names of the flags set are shown joined by
+	// "|", followed by the unknown bits in hex.
+	Flags []Flag `yaml:"flags,omitempty"`
+	// Group is the name of the group of related fields the field belongs to. It must be defined in the
+	// groups section of the metadata.
+	Group string `yaml:"group,omitempty"`
+	// OutputName is the key of the field in JSON and YAML output, if it has to differ from Name.
+	// Columns and filters keep using Name.
+	OutputName string `yaml:"outputName,omitempty"`
+	// Aliases are former names of the field, kept to avoid breaking consumers after a rename
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Deprecated marks a field that will be removed. Selecting, filtering or sorting by it prints
+	// a warning.
+	Deprecated bool `yaml:"deprecated,omitempty"`
+	// Source is an expression computing the value of the field from its sibling fields, like
+	// "bytes_out / duration" or `dport == 53 ? "dns" : "other"`. Fields with a source don't exist
+	// in the eBPF struct: their value is computed for each event and shown as a string.
+	Source string `yaml:"source,omitempty"`
+	// SourcePlaceholder is shown instead of the value of a field with a source when it can't be
+	// computed, like on divisions by zero. It defaults to "-".
+	SourcePlaceholder string `yaml:"sourcePlaceholder,omitempty"`
+	// VariantOf is the name of the sibling field that selects the active member of a union field
+	VariantOf string `yaml:"variantOf,omitempty"`
+	// Variants maps the values of the VariantOf field to the name of the active union member
+	Variants map[int64]string `yaml:"variants,omitempty"`
+	// FilledBy is the optional program that is the only one filling the field. The field is shown
+	// as "-" in columns output when the program is skipped, unless it sets zeroAs.
+	FilledBy string `yaml:"filledBy,omitempty"`
+	// ExternalMap is the external map the value of the field is read from, like gadget_sockets for
+	// the process owning a socket. The field is hidden when the provider of the map isn't available.
+	ExternalMap string `yaml:"externalMap,omitempty"`
+}
+
+// Flag is the name of a bit, or of a group of bits, of a bitmask field
+type Flag struct {
+	// Name of the flag
+	Name string `yaml:"name"`
+	// Value of the flag: a power of two unless Mask is set. The flag with value 0, if any, is
+	// shown when no bit is set.
+	Value uint64 `yaml:"value"`
+	// Mask allows Value to have several bits set, like O_ACCMODE. The flag is shown when all of
+	// them are set.
+	Mask bool `yaml:"mask,omitempty"`
+}
+
+// TrailerType is the type of the variable-length data following a struct
+type TrailerType string
+
+const (
+	TrailerTypeBytes  TrailerType = "bytes"
+	TrailerTypeString TrailerType = "string"
+)
+
+// Trailer describes variable-length data written by the gadget right after
+// the struct, like the arguments of a process or the payload of a packet
+type Trailer struct {
+	// Name of the field exposing the data
+	Name string `yaml:"name"`
+	// Type of the data: bytes or string
+	Type TrailerType `yaml:"type"`
+	// LengthField is the unsigned integer member of the struct holding the
+	// number of bytes of the data
+	LengthField string `yaml:"lengthField"`
 }
 
 // Struct describes a type generated by the gadget
 type Struct struct {
 	Fields []Field `yaml:"fields"`
+	// Trailer is the variable-length data following the struct, if any
+	Trailer *Trailer `yaml:"trailer,omitempty"`
+}
+
+// ExternalMap describes a map the gadget doesn't own but shares with other
+// gadgets, like the one provided by the socket enricher
+type ExternalMap struct {
+	// Version of the layout of the map the gadget was built against
+	LayoutVersion int `yaml:"layoutVersion"`
+	// Provider of the map, like socket-enricher. The gadget fails to run if it isn't available,
+	// instead of reading an empty map, unless the map is optional.
+	Provider string `yaml:"provider,omitempty"`
+	// Optional lets the gadget run without the provider of the map. The fields read from the map
+	// are hidden then.
+	Optional bool `yaml:"optional,omitempty"`
 }
 
 type EBPFParam struct {
 	params.ParamDesc `yaml:",inline"`
+	// Min and Max are the inclusive bounds of the values of a numeric param
+	Min string `yaml:"min,omitempty"`
+	Max string `yaml:"max,omitempty"`
+	// Pattern is a regular expression the values of a string param must match
+	Pattern string `yaml:"pattern,omitempty"`
+	// MaxLength is the maximum length in bytes of the values of a string param, backed by a char
+	// array. It's one less than the size of the array, keeping room for the terminating NUL.
+	MaxLength uint32 `yaml:"maxLength,omitempty"`
+	// LPMMap is the LPM trie map the network of a cidr param is inserted into, besides being
+	// written into the variables backing the param
+	LPMMap string `yaml:"lpmMap,omitempty"`
+	// TargetUnit is the unit of the integer variable backing a duration or size param, like ms.
+	// Values like 250ms or 4KiB are converted to it. It defaults to ns for durations and bytes
+	// for sizes.
+	TargetUnit string `yaml:"targetUnit,omitempty"`
+	// AltKeys are other names the param can be set with, like the names it had before being
+	// renamed. The alias, a single character, is set in ParamDesc.
+	AltKeys []string `yaml:"altKeys,omitempty"`
+	// TargetMap is the hash map the values of the param are inserted into, as keys with zeroed
+	// values. The variable backing the param is set to the number of values or, if it's a bool,
+	// to whether there are any: an empty value means no filtering.
+	TargetMap string `yaml:"targetMap,omitempty"`
+	// KeyType is the type of the keys of the target map: an integer type like uint32, written in
+	// host byte order, or port for 16-bit ports in network byte order
+	KeyType string `yaml:"keyType,omitempty"`
+	// List makes the param take a comma-separated list of values instead of a single one: the keys
+	// of its target map or the elements of the array backing it
+	List bool `yaml:"list,omitempty"`
+	// Category groups the param with related ones in front-ends, like filtering, performance or
+	// output
+	Category string `yaml:"category,omitempty"`
+	// Advanced params, like tuning knobs, can be collapsed by front-ends
+	Advanced bool `yaml:"advanced,omitempty"`
+	// Hidden params, for internal or debug use, aren't shown by front-ends. They need a default
+	// value, as they couldn't be set otherwise in non-interactive contexts.
+	Hidden bool `yaml:"hidden,omitempty"`
+	// Requires lists the keys of the params that must be set, and true for bools, for this one
+	// to be set
+	Requires []string `yaml:"requires,omitempty"`
+	// ConflictsWith lists the keys of the params that can't be set together with this one
+	ConflictsWith []string `yaml:"conflictsWith,omitempty"`
+	// Attaches lists the programs only loaded and attached when the param, a bool, is true,
+	// like an extra kprobe capturing stacks
+	Attaches []string `yaml:"attaches,omitempty"`
+	// Examples are values shown in the help of the param, like "8080" or "10.0.0.0/8". They must
+	// satisfy its constraints.
+	Examples []string `yaml:"examples,omitempty"`
+	// Sensitive params carry private data, like hostnames or usernames to match: their values are
+	// masked in logs, errors and generated docs, and front-ends should mask them when echoing
+	Sensitive bool `yaml:"sensitive,omitempty"`
+}
+
+// DisplayTags returns the tags telling front-ends how to show the param: its category, whether
+// it's advanced, hidden or sensitive and the params it requires or conflicts with
+func (p *EBPFParam) DisplayTags() []string {
+	var tags []string
+	if p.Category != "" {
+		tags = append(tags, params.TagCategoryPrefix+p.Category)
+	}
+	if p.Advanced {
+		tags = append(tags, params.TagAdvanced)
+	}
+	if p.Hidden {
+		tags = append(tags, params.TagHidden)
+	}
+	if p.Sensitive {
+		tags = append(tags, params.TagSensitive)
+	}
+	for _, key := range p.Requires {
+		tags = append(tags, params.TagRequiresPrefix+key)
+	}
+	for _, key := range p.ConflictsWith {
+		tags = append(tags, params.TagConflictsWithPrefix+key)
+	}
+	return tags
+}
+
+// ToParamDesc returns the description of the param, with the tags telling front-ends how to show it
+func (p *EBPFParam) ToParamDesc() params.ParamDesc {
+	desc := p.ParamDesc
+	if tags := p.DisplayTags(); len(tags) > 0 {
+		desc.Tags = append(slices.Clone(p.Tags), tags...)
+	}
+	return desc
+}
+
+// MaskValue returns value, or params.MaskedValue if the param is sensitive
+func (p *EBPFParam) MaskValue(value string) string {
+	return params.MaskValue(value, p.Sensitive)
+}
+
+// MaskError returns err with value masked in its message if the param is sensitive
+func (p *EBPFParam) MaskError(err error, value string) error {
+	return params.MaskError(err, value, p.Sensitive)
+}
+
+// IsAddress returns whether the param is an IPv4 or IPv6 address or network, written in network
+// byte order into its variable
+func (p *EBPFParam) IsAddress() bool {
+	switch p.TypeHint {
+	case params.TypeIPv4, params.TypeIPv6, params.TypeCIDR:
+		return true
+	}
+	return false
 }
 
+// HasUnit returns whether the param is a duration or a size, converted to the target unit of its
+// integer variable
+func (p *EBPFParam) HasUnit() bool {
+	return p.TypeHint == params.TypeDuration || p.TypeHint == params.TypeSize
+}
+
+// KeyTypePort is the key type of target maps holding ports in network byte order
+const KeyTypePort = "port"
+
+// HasTargetMap returns whether the values of the param are inserted into a map
+func (p *EBPFParam) HasTargetMap() bool {
+	return p.TargetMap != ""
+}
+
+// ArrayLenSuffix ends the name of the variable set to the number of values of a param backed by an
+// array, like ports_len for ports
+const ArrayLenSuffix = "_len"
+
 type GadgetMetadata struct {
 	// Gadget name
 	Name string `yaml:"name"`
 	// Gadget description
 	Description string `yaml:"description,omitempty"`
+	// LongDescription explains in detail what the gadget does and how to use it. It's written in
+	// markdown and included in the generated documentation.
+	LongDescription string `yaml:"longDescription,omitempty"`
 	// HomepageURL is the URL to the gadget's homepage
 	HomepageURL string `yaml:"homepageURL,omitempty"`
 	// DocumentationURL is the URL to the gadget's documentation
@@ -116,12 +515,23 @@ type GadgetMetadata struct {
 	SourceURL string `yaml:"sourceURL,omitempty"`
 	// Annotations is a map of key-value pairs that provide additional information about the gadget
 	Annotations map[string]string `yaml:"annotations,omitempty"`
+	// Includes are metadata fragments shared between gadgets, like the fields of common structs,
+	// as files or "oci://<image>" references. They are merged in order and the document itself
+	// last, later ones overriding earlier ones: struct fields are overridden by name and the
+	// entries of the other sections by key.
+	Includes []string `yaml:"includes,omitempty"`
 
 	// Tracers implemented by the gadget
 	// TODO: Rename this field to something that doesn't collide with the opentelemetry concept
 	Tracers map[string]Tracer `yaml:"tracers,omitempty"`
+	// SelectorParam is the key of the gadget param selecting the only tracer to run, among the
+	// names of the tracers or SelectAllTracers, the default. The programs linked to the other
+	// tracers aren't loaded and their maps aren't read from.
+	SelectorParam string `yaml:"selectorParam,omitempty"`
 	// Toppers implemented by the gadget
 	Toppers map[string]Topper `yaml:"toppers,omitempty"`
+	// Profilers implemented by the gadget
+	Profilers map[string]Profiler `yaml:"profilers,omitempty"`
 	// Snapshotters implemented by the gadget
 	Snapshotters map[string]Snapshotter `yaml:"snapshotters,omitempty"`
 	// Types generated by the gadget
@@ -130,4 +540,247 @@ type GadgetMetadata struct {
 	EBPFParams map[string]EBPFParam `yaml:"ebpfParams,omitempty"`
 	// Other params exposed by the gadget
 	GadgetParams map[string]params.ParamDesc `yaml:"gadgetParams,omitempty"`
+	// Maps shared with other gadgets
+	ExternalMaps map[string]ExternalMap `yaml:"externalMaps,omitempty"`
+	// Groups of related fields, like the source and destination of a connection
+	Groups map[string]Group `yaml:"groups,omitempty"`
+	// Programs describes where uprobes attach to and which programs are optional, by program name
+	Programs map[string]Program `yaml:"programs,omitempty"`
+	// Attach describes how socket filter and TC programs attach to the network, by program name
+	Attach map[string]NetworkAttach `yaml:"attach,omitempty"`
+	// Metrics exported by the gadget, by name. Their names follow the Prometheus naming rules in
+	// lower case, like "dropped_packets_total".
+	Metrics map[string]Metric `yaml:"metrics,omitempty"`
+	// MntNsFilter declares the map the gadget filters events by container with. Populate sets it
+	// when the gadget defines the conventional gadget_mntns_filter_map.
+	MntNsFilter *MntNsFilter `yaml:"mntnsFilter,omitempty"`
+	// Alerts tag the events matching a condition as notable, by name
+	Alerts map[string]Alert `yaml:"alerts,omitempty"`
+}
+
+// MntNsFilter describes the map holding the mount namespace ids of the containers selected by the
+// user, see include/gadget/mntns_filter.h
+type MntNsFilter struct {
+	// MapName is the name of the hash map, keyed by the 8-byte mount namespace id
+	MapName string `yaml:"mapName"`
+}
+
+// Program describes where a uprobe program attaches to, instead of setting it in its section name,
+// and whether a program can be skipped
+type Program struct {
+	// Target is the binary or library a uprobe attaches to: an absolute path, a library name looked
+	// up in the ld cache of each container, or a container template like
+	// "{{ .Container.Image.Entrypoint }}"
+	Target string `yaml:"target,omitempty"`
+	// Symbol is the function a uprobe attaches to
+	Symbol string `yaml:"symbol,omitempty"`
+	// Retprobe attaches the uprobe to the return of the function
+	Retprobe bool `yaml:"retprobe,omitempty"`
+	// Optional programs are skipped, instead of failing to run the gadget, when they can't be
+	// loaded or attached or when one of their gates is closed
+	Optional bool `yaml:"optional,omitempty"`
+	// MinKernel gates an optional program on the version of the running kernel, like "5.15"
+	MinKernel string `yaml:"minKernel,omitempty"`
+	// EnabledByParam gates an optional program on the boolean param with this key
+	EnabledByParam string `yaml:"enabledByParam,omitempty"`
+	// Enforcing LSM programs can deny the operations they hook into. The gadget only runs in
+	// enforce mode if the user explicitly allows it.
+	Enforcing bool `yaml:"enforcing,omitempty"`
+	// EnforceParam is the key of the boolean eBPF param switching an enforcing program between
+	// audit mode, only reporting the operations it would deny, and enforce mode, denying them
+	EnforceParam string `yaml:"enforceParam,omitempty"`
+	// Tracer links the program to a tracer: when the selector param of the gadget selects another
+	// tracer, the program isn't loaded
+	Tracer string `yaml:"tracer,omitempty"`
+}
+
+// SelectAllTracers is the value of the selector param running all the tracers
+const SelectAllTracers = "all"
+
+// TracerSelectorValues returns the possible values of the selector param of the gadget:
+// SelectAllTracers and the names of its tracers, sorted
+func (m *GadgetMetadata) TracerSelectorValues() []string {
+	values := []string{SelectAllTracers}
+	for name := range m.Tracers {
+		values = append(values, name)
+	}
+	slices.Sort(values[1:])
+	return values
+}
+
+// AttachDirection is the direction of the traffic seen by a TC program
+type AttachDirection string
+
+const (
+	AttachDirectionIngress AttachDirection = "ingress"
+	AttachDirectionEgress  AttachDirection = "egress"
+)
+
+// AttachInterfaces selects the interfaces a network program attaches to
+type AttachInterfaces string
+
+const (
+	// AttachInterfacesPod attaches to the network namespace of each selected container, including
+	// the ones created while the gadget runs. Socket filters get a socket in it, TC programs
+	// attach to the host side of its veth interfaces.
+	AttachInterfacesPod AttachInterfaces = "pod"
+	// AttachInterfacesAll attaches to all the interfaces of the host network namespace
+	AttachInterfacesAll AttachInterfaces = "all"
+	// AttachInterfacesParam attaches to the host interface named by the iface param. Only TC
+	// programs support it.
+	AttachInterfacesParam AttachInterfaces = "param"
+)
+
+// NetworkAttach describes how a socket filter or TC program attaches to the network
+type NetworkAttach struct {
+	// Direction of the traffic seen by a TC program, ingress or egress. It's required when the
+	// section name doesn't set it, like "classifier", and must match it otherwise. Socket filters
+	// see both directions.
+	Direction AttachDirection `yaml:"direction,omitempty"`
+	// Interfaces the program attaches to by default: pod, all or param. pod if empty. Setting the
+	// iface param attaches TC programs to that interface instead.
+	Interfaces AttachInterfaces `yaml:"interfaces,omitempty"`
+}
+
+// MetricType is the kind of a metric
+type MetricType string
+
+const (
+	MetricTypeCounter MetricType = "counter"
+	MetricTypeGauge   MetricType = "gauge"
+)
+
+// Annotations of the data sources emitting map-derived metrics, read by the otel-metrics operator
+const (
+	// AnnotationMetricsType tells the role of a field in a metric: MetricTypeKey for labels, or
+	// the type of the metric for its value
+	AnnotationMetricsType = "metrics.type"
+	// MetricTypeKey marks the fields used as labels
+	MetricTypeKey = "key"
+	// MapMetricValueField is the field holding the value of map-derived metrics whose map value
+	// is an integer
+	MapMetricValueField = "value"
+)
+
+// Metric describes a metric exported from the data of the gadget. Metrics are either derived
+// from a map, read periodically, or from the events of a data source.
+type Metric struct {
+	// Help describes the metric
+	Help string `yaml:"help,omitempty"`
+	// Type of the metric, counter or gauge
+	Type MetricType `yaml:"type"`
+	// MapName is the hash map a map-derived metric is read from: the fields of its key struct
+	// are the labels and a field of its value is the value of the metric. Counters expect the
+	// map to hold running totals.
+	MapName string `yaml:"mapName,omitempty"`
+	// DataSource is the tracer, topper or snapshotter whose events an event-derived metric is
+	// computed from
+	DataSource string `yaml:"dataSource,omitempty"`
+	// Field is the integer field holding the value of the metric. Map-derived metrics don't need
+	// it if the value of the map is an integer. Event-derived counters without it count the
+	// events.
+	Field string `yaml:"field,omitempty"`
+	// Labels are the fields whose values become labels of the metric. Map-derived metrics use
+	// all the fields of the key struct if it's empty.
+	Labels []string `yaml:"labels,omitempty"`
+	// Selector are the filter rules events must match to be taken into account by event-derived
+	// metrics, like "comm==curl". They use the syntax of the filter operator.
+	Selector []string `yaml:"selector,omitempty"`
+}
+
+// AlertSeverity is how notable the events matching an alert are
+type AlertSeverity string
+
+const (
+	AlertSeverityInfo     AlertSeverity = "info"
+	AlertSeverityWarning  AlertSeverity = "warning"
+	AlertSeverityCritical AlertSeverity = "critical"
+)
+
+// Alert tags the events of a data source matching a condition. Matching events get the
+// alert.name, alert.severity and alert.message fields, which can be used like any other field
+// in the output, filters and exporters. When several alerts match, alert.name lists all of them
+// and alert.severity is the highest one.
+type Alert struct {
+	// DataSource is the tracer, topper or snapshotter whose events are checked
+	DataSource string `yaml:"dataSource"`
+	// Condition is the expression events must match, with the syntax of computed fields, like
+	// "uid == 0 && ret < 0"
+	Condition string `yaml:"condition"`
+	// Severity of the alert: info, warning or critical
+	Severity AlertSeverity `yaml:"severity"`
+	// Message describes the alert. Fields of the event are referenced as {field}, like
+	// "{comm} failed with {ret}".
+	Message string `yaml:"message,omitempty"`
+}
+
+// Level returns the rank of the severity, higher for more notable alerts, or 0 if it's invalid
+func (s AlertSeverity) Level() int {
+	switch s {
+	case AlertSeverityInfo:
+		return 1
+	case AlertSeverityWarning:
+		return 2
+	case AlertSeverityCritical:
+		return 3
+	}
+	return 0
+}
+
+// Group describes a set of related fields. Their columns share the name of the group as header
+// prefix and can be selected or hidden together.
+type Group struct {
+	// Description of the group
+	Description string `yaml:"description,omitempty"`
+	// Hidden hides the fields of the group by default
+	Hidden bool `yaml:"hidden,omitempty"`
+}
+
+// DocURL returns the URL of the documentation of the gadget, falling back to
+// its homepage
+func (m *GadgetMetadata) DocURL() string {
+	if m.DocumentationURL != "" {
+		return m.DocumentationURL
+	}
+	return m.HomepageURL
+}
+
+// Field returns the field with the given name of the struct with the given
+// name
+func (m *GadgetMetadata) Field(structName, fieldName string) (Field, bool) {
+	for _, f := range m.Structs[structName].Fields {
+		if f.Name == fieldName {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// FilterableFields returns the names of the fields of the struct with the
+// given name that can be used in filter expressions
+func (m *GadgetMetadata) FilterableFields(structName string) []string {
+	return m.fieldsWith(structName, func(attrs FieldAttributes) *bool { return attrs.Filterable })
+}
+
+// SortableFields returns the names of the fields of the struct with the given
+// name that can be used to sort entries
+func (m *GadgetMetadata) SortableFields(structName string) []string {
+	return m.fieldsWith(structName, func(attrs FieldAttributes) *bool { return attrs.Sortable })
+}
+
+// fieldsWith returns the names of the fields of a struct not having the
+// attribute returned by attr explicitly set to false
+func (m *GadgetMetadata) fieldsWith(structName string, attr func(FieldAttributes) *bool) []string {
+	s, ok := m.Structs[structName]
+	if !ok {
+		return nil
+	}
+	var res []string
+	for _, f := range s.Fields {
+		if v := attr(f.Attributes); v != nil && !*v {
+			continue
+		}
+		res = append(res, f.Name)
+	}
+	return res
 }
@@ -32,6 +32,7 @@ import (
 	"oras.land/oras-go/v2/content"
 	"oras.land/oras-go/v2/errdef"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
 	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 )
 
@@ -69,6 +70,9 @@ type BuildGadgetImageOpts struct {
 	UpdateMetadata bool
 	// If true, the metadata is validated before creating the image.
 	ValidateMetadata bool
+	// If true, the validation also fails if the metadata still has
+	// placeholders, like "TODO: Fill the gadget name".
+	StrictMetadata bool
 	// Date and time on which the image is built (date-time string as defined by RFC 3339).
 	CreatedDate string
 }
@@ -172,11 +176,33 @@ func annotationsFromMetadata(metadataBytes []byte) (map[string]string, error) {
 	return annotations, nil
 }
 
+// resolveMetadataIncludes returns the metadata with the fragments it includes
+// merged in, so the image doesn't depend on them. Metadata without includes is
+// returned as is.
+func resolveMetadataIncludes(ctx context.Context, metadataFilePath string, metadataBytes []byte) ([]byte, error) {
+	metadata := &metadatav1.GadgetMetadata{}
+	if err := yaml.Unmarshal(metadataBytes, metadata); err != nil {
+		return nil, fmt.Errorf("decoding metadata file: %w", err)
+	}
+	if len(metadata.Includes) == 0 {
+		return metadataBytes, nil
+	}
+
+	if err := types.ResolveIncludes(metadata, includeLoader(ctx, metadataFilePath)); err != nil {
+		return nil, err
+	}
+	return types.Marshal(metadata)
+}
+
 func createMetadataDesc(ctx context.Context, target oras.Target, metadataFilePath string) (ocispec.Descriptor, error) {
 	metadataBytes, err := os.ReadFile(metadataFilePath)
 	if err != nil {
 		return ocispec.Descriptor{}, fmt.Errorf("reading metadata file: %w", err)
 	}
+	metadataBytes, err = resolveMetadataIncludes(ctx, metadataFilePath, metadataBytes)
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("resolving includes of metadata file: %w", err)
+	}
 	defDesc := content.NewDescriptorFromBytes(metadataMediaType, metadataBytes)
 	defDesc.Annotations, err = annotationsFromMetadata(metadataBytes)
 	if err != nil {
@@ -19,6 +19,8 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/cilium/ebpf"
 	log "github.com/sirupsen/logrus"
@@ -42,9 +44,8 @@ func loadSpec(progContent []byte) (*ebpf.CollectionSpec, error) {
 func getAnySpec(opts *BuildGadgetImageOpts) (*ebpf.CollectionSpec, error) {
 	var progPath string
 
-	// TODO: we could perform a sanity check to be sure different architectures generate the
-	// same metadata, but that's too much for now.
-	// We're validating the metadata at runtime, so a possible error will be caught there.
+	// The metadata is checked to be the same for all architectures when it's
+	// created or updated, see getSpecs.
 	for _, paths := range opts.ObjectPaths {
 		progPath = paths.EBPF
 		break
@@ -62,24 +63,110 @@ func getAnySpec(opts *BuildGadgetImageOpts) (*ebpf.CollectionSpec, error) {
 	return loadSpec(progContent)
 }
 
+// getSpecs returns the spec of the eBPF object of each architecture
+func getSpecs(opts *BuildGadgetImageOpts) (map[string]*ebpf.CollectionSpec, error) {
+	specs := make(map[string]*ebpf.CollectionSpec, len(opts.ObjectPaths))
+
+	for arch, paths := range opts.ObjectPaths {
+		if paths.EBPF == "" {
+			continue
+		}
+
+		progContent, err := os.ReadFile(paths.EBPF)
+		if err != nil {
+			return nil, fmt.Errorf("reading eBPF object file for %s: %w", arch, err)
+		}
+
+		spec, err := loadSpec(progContent)
+		if err != nil {
+			return nil, fmt.Errorf("loading eBPF object file for %s: %w", arch, err)
+		}
+		specs[arch] = spec
+	}
+
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no eBPF object file found")
+	}
+
+	return specs, nil
+}
+
+// ociIncludePrefix marks the includes of a metadata file referencing the
+// metadata of a gadget image instead of a file
+const ociIncludePrefix = "oci://"
+
+// includeLoader loads the fragments included by the metadata file at
+// metadataPath: files, relative to its directory, and the metadata of gadget
+// images found in the local store
+func includeLoader(ctx context.Context, metadataPath string) types.IncludeLoader {
+	return func(ref string) ([]byte, error) {
+		image, ok := strings.CutPrefix(ref, ociIncludePrefix)
+		if !ok {
+			if !filepath.IsAbs(ref) {
+				ref = filepath.Join(filepath.Dir(metadataPath), ref)
+			}
+			return os.ReadFile(ref)
+		}
+
+		store, err := GetLocalOciStore()
+		if err != nil {
+			return nil, fmt.Errorf("getting oci store: %w", err)
+		}
+		manifest, err := getManifestForHost(ctx, store, image)
+		if err != nil {
+			return nil, fmt.Errorf("getting manifest of %q: %w", image, err)
+		}
+		if manifest.Config.MediaType != metadataMediaType {
+			return nil, fmt.Errorf("image %q has no metadata", image)
+		}
+		return getContentBytesFromDescriptor(ctx, store, manifest.Config)
+	}
+}
+
 func validateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error {
-	metadataFile, err := os.Open(opts.MetadataPath)
+	content, err := os.ReadFile(opts.MetadataPath)
 	if err != nil {
-		return fmt.Errorf("opening metadata file: %w", err)
+		return fmt.Errorf("reading metadata file: %w", err)
+	}
+
+	// Point at the lines of unknown keys or values of the wrong type, instead
+	// of failing at the first one while decoding, or ignoring unknown keys
+	if err := types.ValidateYAMLAgainstSchema(content); err != nil {
+		return fmt.Errorf("metadata file %q: %w", opts.MetadataPath, err)
 	}
-	defer metadataFile.Close()
 
 	metadata := &metadatav1.GadgetMetadata{}
-	if err := yaml.NewDecoder(metadataFile).Decode(metadata); err != nil {
+	if err := yaml.Unmarshal(content, metadata); err != nil {
 		return fmt.Errorf("decoding metadata file: %w", err)
 	}
 
+	if err := types.ResolveIncludes(metadata, includeLoader(ctx, opts.MetadataPath)); err != nil {
+		return fmt.Errorf("resolving includes: %w", err)
+	}
+
 	spec, err := getAnySpec(opts)
 	if err != nil {
 		return fmt.Errorf("loading spec: %w", err)
 	}
 
-	return types.Validate(metadata, spec)
+	var validateOpts []types.ValidateOption
+	if opts.StrictMetadata {
+		validateOpts = append(validateOpts, types.WithStrict())
+	}
+
+	if err := types.Validate(metadata, spec, validateOpts...); err != nil {
+		return err
+	}
+
+	changes, err := types.PopulateDiff(metadata, spec)
+	if err != nil {
+		return fmt.Errorf("checking metadata file is up to date: %w", err)
+	}
+	if !changes.Empty() {
+		log.Warnf("Metadata file is out of date, use --update-metadata to update it:\n%s", changes)
+	}
+
+	return nil
 }
 
 func createOrUpdateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts) error {
@@ -92,19 +179,25 @@ func createOrUpdateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts)
 	update := statErr == nil
 
 	metadata := &metadatav1.GadgetMetadata{}
+	var original []byte
 
 	if update {
 		// load metadata file
-		metadataFile, err := os.Open(opts.MetadataPath)
+		original, err = os.ReadFile(opts.MetadataPath)
 		if err != nil {
-			return fmt.Errorf("opening metadata file: %w", err)
+			return fmt.Errorf("reading metadata file: %w", err)
 		}
-		defer metadataFile.Close()
 
-		if err := yaml.NewDecoder(metadataFile).Decode(metadata); err != nil {
+		if err := yaml.Unmarshal(original, metadata); err != nil {
 			return fmt.Errorf("decoding metadata file: %w", err)
 		}
 
+		// Populating the resolved metadata would copy the fragments into the file
+		if len(metadata.Includes) > 0 {
+			log.Warnf("Metadata file %q has includes, not updating it: update the included fragments instead", opts.MetadataPath)
+			return nil
+		}
+
 		log.Debugf("Metadata file found, updating it")
 
 		// TODO: this validation could be softer, just printing warnings
@@ -115,11 +208,19 @@ func createOrUpdateMetadataFile(ctx context.Context, opts *BuildGadgetImageOpts)
 		log.Debug("Metadata file not found, generating it")
 	}
 
-	if err := types.Populate(metadata, spec); err != nil {
+	specs, err := getSpecs(opts)
+	if err != nil {
+		return fmt.Errorf("loading specs: %w", err)
+	}
+
+	report, err := types.PopulateFromSpecs(metadata, specs)
+	if err != nil {
 		return fmt.Errorf("populating metadata: %w", err)
 	}
+	log.Infof("Populated metadata file %q:\n%s", opts.MetadataPath, report)
 
-	marshalled, err := yaml.Marshal(metadata)
+	// Keep the comments and the order of the existing file
+	marshalled, err := types.MarshalPreserving(original, metadata)
 	if err != nil {
 		return err
 	}
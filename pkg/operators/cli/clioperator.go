@@ -16,11 +16,14 @@ package clioperator
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
+	"golang.org/x/term"
 	"sigs.k8s.io/yaml"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns/formatter/textcolumns"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/formatters/json"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
@@ -34,8 +37,9 @@ const (
 	// to have happened before the operator becomes active
 	Priority = 10000
 
-	ParamFields = "fields"
-	ParamMode   = "output"
+	ParamFields       = "fields"
+	ParamMode         = "output"
+	ParamPauseHistory = "pause-history"
 
 	ModeJSON       = "json"
 	ModeJSONPretty = "jsonpretty"
@@ -43,6 +47,9 @@ const (
 	ModeYAML       = "yaml"
 )
 
+// clearScreen moves the cursor to the top of the terminal and clears it
+const clearScreen = "\033[H\033[2J"
+
 type cliOperator struct{}
 
 func (o *cliOperator) Name() string {
@@ -77,6 +84,10 @@ func (o *cliOperator) Priority() int {
 type cliOperatorInstance struct {
 	mode        string
 	paramValues api.ParamValues
+
+	pauser       *outputPauser
+	pauseControl *pauseControl
+	stopSignals  func()
 }
 
 func (o *cliOperatorInstance) Name() string {
@@ -87,6 +98,91 @@ func (o *cliOperatorInstance) InstanceParams() params.ParamDescs {
 	return nil
 }
 
+// resolveFieldNames replaces the output names of fields and the aliases of
+// renamed fields, if aliases are enabled for the data source, by their current
+// names. Names of groups are replaced by the fields in them.
+func resolveFieldNames(ds datasource.DataSource, names []string) []string {
+	aliases := datasource.AliasesEnabled(ds)
+
+	res := make([]string, 0, len(names))
+	for _, name := range names {
+		if aliases {
+			if f := ds.GetField(name); f != nil {
+				name = f.FullName()
+			}
+		}
+		if ds.GetField(name) == nil {
+			if f := datasource.FieldByOutputName(ds, name); f != nil {
+				name = f.FullName()
+			} else if group := datasource.FieldsInGroup(ds, name); len(group) > 0 {
+				for _, f := range group {
+					res = append(res, f.FullName())
+				}
+				continue
+			}
+		}
+		res = append(res, name)
+	}
+	return res
+}
+
+// deprecatedFields returns the deprecated fields among the ones selected by
+// the user. Aliases are reported by the fieldaliases operator.
+func deprecatedFields(ds datasource.DataSource, names []string) []string {
+	requested := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		requested[strings.TrimLeft(name, "+-")] = struct{}{}
+	}
+
+	var res []string
+	for _, f := range ds.Accessors(false) {
+		if _, ok := requested[f.FullName()]; ok && datasource.IsDeprecated(f) {
+			res = append(res, f.FullName())
+		}
+	}
+	return res
+}
+
+// selectFields returns the fields to show given the names requested by the
+// user. If all of them are prefixed with "+" or "-", they are added to or
+// removed from defaults instead.
+func selectFields(ds datasource.DataSource, defaults []string, names []string) []string {
+	var add, remove []string
+	for _, name := range names {
+		switch {
+		case strings.HasPrefix(name, "+"):
+			add = append(add, strings.TrimPrefix(name, "+"))
+		case strings.HasPrefix(name, "-"):
+			remove = append(remove, strings.TrimPrefix(name, "-"))
+		default:
+			return resolveFieldNames(ds, names)
+		}
+	}
+
+	removed := make(map[string]struct{})
+	for _, name := range resolveFieldNames(ds, remove) {
+		removed[name] = struct{}{}
+	}
+
+	candidates := make([]string, 0, len(defaults)+len(add))
+	candidates = append(candidates, defaults...)
+	candidates = append(candidates, resolveFieldNames(ds, add)...)
+
+	res := make([]string, 0, len(candidates))
+	present := make(map[string]struct{})
+	for _, name := range candidates {
+		if _, ok := removed[name]; ok {
+			continue
+		}
+		if _, ok := present[name]; ok {
+			continue
+		}
+		present[name] = struct{}{}
+		res = append(res, name)
+	}
+	return res
+}
+
 func getNamesFromFields(fields []*api.Field) []string {
 	res := make([]string, 0, len(fields))
 	for _, f := range fields {
@@ -112,6 +208,7 @@ func (o *cliOperatorInstance) ExtraParams(gadgetCtx operators.GadgetContext) api
 		fields := ds.Fields()
 		availableFields := make([]*api.Field, 0, len(fields))
 		defaultFields := make([]*api.Field, 0)
+		defaultColumns := datasource.DefaultColumns(ds)
 		for _, f := range fields {
 			if datasource.FieldFlagUnreferenced.In(f.Flags) ||
 				datasource.FieldFlagContainer.In(f.Flags) ||
@@ -119,7 +216,11 @@ func (o *cliOperatorInstance) ExtraParams(gadgetCtx operators.GadgetContext) api
 				continue
 			}
 			availableFields = append(availableFields, f)
-			if datasource.FieldFlagHidden.In(f.Flags) {
+			if defaultColumns != nil {
+				if !datasource.IsDefaultColumn(defaultColumns, f.FullName) {
+					continue
+				}
+			} else if datasource.FieldFlagHidden.In(f.Flags) {
 				continue
 			}
 			defaultFields = append(defaultFields, f)
@@ -168,7 +269,14 @@ func (o *cliOperatorInstance) ExtraParams(gadgetCtx operators.GadgetContext) api
 		PossibleValues: []string{ModeJSON, ModeJSONPretty, ModeColumns, ModeYAML},
 	}
 
-	return api.Params{fields, mode}
+	pauseHistory := &api.Param{
+		Key:          ParamPauseHistory,
+		DefaultValue: fmt.Sprintf("%d", DefaultPauseHistorySize),
+		Description:  "maximum number of events kept while the output is paused",
+		TypeHint:     api.TypeUint,
+	}
+
+	return api.Params{fields, mode, pauseHistory}
 }
 
 func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
@@ -190,6 +298,12 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 
 	o.mode = params.Get(ParamMode).AsString()
 
+	pauser, err := newOutputPauser(os.Stdout, os.Stderr, params.Get(ParamPauseHistory).AsInt())
+	if err != nil {
+		return err
+	}
+	o.pauser = pauser
+
 	for _, ds := range gadgetCtx.GetDataSources() {
 		gadgetCtx.Logger().Debugf("subscribing to %s", ds.Name())
 
@@ -208,20 +322,61 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 
 			defCols := p.GetDefaultColumns()
 			gadgetCtx.Logger().Debugf("default fields: %s", defCols)
-			formatter := p.GetTextColumnsFormatter()
+			// Styles are only applied in terminals, escape sequences would
+			// end up in files and pipes otherwise
+			formatter := p.GetTextColumnsFormatter(textcolumns.WithColors(term.IsTerminal(int(os.Stdout.Fd()))))
 
 			if hasFields {
-				err := formatter.SetShowColumns(strings.Split(fields, ","))
+				names := strings.Split(fields, ",")
+				for _, name := range deprecatedFields(ds, names) {
+					gadgetCtx.Logger().Warnf("%s: field %q is deprecated and will be removed", ds.Name(), name)
+				}
+				err := formatter.SetShowColumns(selectFields(ds, defCols, names))
 				if err != nil {
 					return fmt.Errorf("setting fields: %w", err)
 				}
 			}
 
 			formatter.SetEventCallback(func(s string) {
-				fmt.Print(s)
-				fmt.Print("\n")
+				o.pauser.Print(s + "\n")
 			})
 
+			// Fields like rendered histograms are printed below their row
+			formatter.SetEnableExtraLines(len(datasource.ExtraLinesFields(ds)) > 0)
+
+			if ds.Type() == datasource.TypeArray {
+				// Snapshots are handled as a whole, so columns sized from
+				// their content use all of its entries
+				p.SetEventCallback(formatter.EventHandlerFuncArray())
+				handler, ok := p.EventHandlerFuncArray().(func(data []*datasource.DataTuple))
+				if !ok {
+					gadgetCtx.Logger().Warnf("invalid data format: expected func(data []*datasource.DataTuple), got %T",
+						p.EventHandlerFuncArray())
+					continue
+				}
+
+				// Snapshots replacing the previous one redraw the table
+				// from the top of the terminal
+				refresh := ds.Annotations()[datasource.RefreshAnnotation] == "true" &&
+					term.IsTerminal(int(os.Stdout.Fd()))
+				if !refresh {
+					o.pauser.Print(formatter.FormatHeader() + "\n")
+				}
+
+				ds.SubscribeArray(func(ds datasource.DataSource, dataArray datasource.DataArray) error {
+					if refresh {
+						o.pauser.Print(clearScreen + formatter.FormatHeader() + "\n")
+					}
+					tuples := make([]*datasource.DataTuple, 0, dataArray.Len())
+					for i := 0; i < dataArray.Len(); i++ {
+						tuples = append(tuples, datasource.NewDataTuple(ds, dataArray.Get(i)))
+					}
+					handler(tuples)
+					return nil
+				}, Priority)
+				continue
+			}
+
 			p.SetEventCallback(formatter.EventHandlerFunc())
 			handler, ok := p.EventHandlerFunc().(func(data *datasource.DataTuple))
 			if !ok {
@@ -230,7 +385,7 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 				continue
 			}
 
-			fmt.Println(formatter.FormatHeader())
+			o.pauser.Print(formatter.FormatHeader() + "\n")
 
 			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
 				handler(datasource.NewDataTuple(ds, data))
@@ -260,8 +415,7 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 					if err != nil {
 						return fmt.Errorf("serializing yaml: %w", err)
 					}
-					fmt.Println("---")
-					fmt.Print(string(yml))
+					o.pauser.Print("---\n" + string(yml))
 					return nil
 				}, Priority)
 				return nil
@@ -270,12 +424,12 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 			switch ds.Type() {
 			case datasource.TypeSingle:
 				ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
-					fmt.Println(string(jsonFormatter.Marshal(data)))
+					o.pauser.Print(string(jsonFormatter.Marshal(data)) + "\n")
 					return nil
 				}, Priority)
 			case datasource.TypeArray:
 				ds.SubscribeArray(func(ds datasource.DataSource, dataArray datasource.DataArray) error {
-					fmt.Println(string(jsonFormatter.MarshalArray(dataArray)))
+					o.pauser.Print(string(jsonFormatter.MarshalArray(dataArray)) + "\n")
 					return nil
 				}, Priority)
 			}
@@ -285,10 +439,32 @@ func (o *cliOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error
 }
 
 func (o *cliOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	// Pausing is only meaningful when a user is watching the output; don't
+	// take over the terminal or job control when it's redirected or piped
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		o.pauseControl = newPauseControl(o.pauser, os.Stderr, os.Stdin)
+		o.stopSignals = o.pauseControl.handleSignals()
+	}
 	return nil
 }
 
 func (o *cliOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	if o.stopSignals != nil {
+		o.stopSignals()
+	}
+	if o.pauseControl != nil {
+		o.pauseControl.stop()
+	}
+
+	// Don't lose what was buffered if the gadget stops while paused
+	o.pauser.Resume(true)
+
+	stats := o.pauser.Stats()
+	if stats.PauseBuffered > 0 || stats.SlowWrites > 0 {
+		fmt.Fprintf(os.Stderr, "--- output stats: written %d, buffered while paused %d, dropped while paused %d, "+
+			"skipped on resume %d, slow writes %d ---\n",
+			stats.Written, stats.PauseBuffered, stats.PauseDropped, stats.PauseSkipped, stats.SlowWrites)
+	}
 	return nil
 }
 
@@ -0,0 +1,98 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+func TestResolveFieldNames(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("saddr_v4", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+		datasource.OutputNameAnnotation: "srcAddr",
+	}))
+	require.NoError(t, err)
+	_, err = ds.AddField("pid", api.Kind_Uint32)
+	require.NoError(t, err)
+
+	// Columns can be selected by name or output name
+	require.Equal(t, []string{"saddr_v4", "pid", "saddr_v4", "unknown"},
+		resolveFieldNames(ds, []string{"srcAddr", "pid", "saddr_v4", "unknown"}))
+}
+
+func TestDeprecatedFields(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	_, err = ds.AddField("fname", api.Kind_String, datasource.WithAnnotations(map[string]string{
+		datasource.DeprecatedAnnotation: "true",
+	}))
+	require.NoError(t, err)
+	_, err = ds.AddField("filename", api.Kind_String)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"fname"}, deprecatedFields(ds, []string{"filename", "fname"}))
+	require.Equal(t, []string{"fname"}, deprecatedFields(ds, []string{"+fname"}))
+	require.Empty(t, deprecatedFields(ds, []string{"filename", "unknown"}))
+}
+
+func TestSelectFieldsGroups(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	fields := []struct {
+		name  string
+		group string
+	}{
+		{name: "pid"},
+		{name: "saddr", group: "src"},
+		{name: "sport", group: "src"},
+		{name: "daddr", group: "dst"},
+		{name: "dport", group: "dst"},
+	}
+	for _, f := range fields {
+		var opts []datasource.FieldOption
+		if f.group != "" {
+			opts = append(opts, datasource.WithAnnotations(map[string]string{
+				datasource.GroupAnnotation: f.group,
+			}))
+		}
+		_, err = ds.AddField(f.name, api.Kind_Uint32, opts...)
+		require.NoError(t, err)
+	}
+
+	// dst is hidden by default
+	defaults := []string{"pid", "saddr", "sport"}
+
+	require.Equal(t, []string{"pid", "saddr", "sport", "daddr", "dport"},
+		selectFields(ds, defaults, []string{"+dst"}))
+	require.Equal(t, []string{"pid"},
+		selectFields(ds, defaults, []string{"-src"}))
+	require.Equal(t, []string{"daddr", "dport", "pid"},
+		selectFields(ds, defaults, []string{"dst", "pid"}))
+}
@@ -0,0 +1,185 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPauseHistorySize is the maximum number of events kept while the
+	// output is paused
+	DefaultPauseHistorySize = 1000
+
+	// slowWriteThreshold is the time after which writing an event is
+	// considered slow
+	slowWriteThreshold = 100 * time.Millisecond
+)
+
+// OutputStats holds counters about the events handled by an outputPauser. They
+// allow to tell events held back on purpose (pause) apart from a slow
+// consumer of the output.
+type OutputStats struct {
+	// Written is the number of events written to the output
+	Written uint64
+	// PauseBuffered is the number of events stored in the history because the
+	// output was paused
+	PauseBuffered uint64
+	// PauseDropped is the number of events evicted from the history because it
+	// was full while the output was paused
+	PauseDropped uint64
+	// PauseSkipped is the number of buffered events discarded on resume when
+	// jumping to live output
+	PauseSkipped uint64
+	// SlowWrites is the number of events that took longer than
+	// slowWriteThreshold to be written while the output was not paused
+	SlowWrites uint64
+}
+
+// outputPauser sits between the data sources and the output. While paused,
+// events keep being received and formatted, so nothing blocks the readers,
+// but they're stored in a bounded history instead of being written. On
+// resume, they're either replayed or skipped.
+type outputPauser struct {
+	mu sync.Mutex
+
+	out    io.Writer
+	notice io.Writer
+
+	paused  bool
+	history []string
+	size    int
+
+	// events received during the current pause, including the evicted ones
+	pausedEvents uint64
+	pausedAt     time.Time
+
+	stats OutputStats
+}
+
+func newOutputPauser(out, notice io.Writer, size int) (*outputPauser, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid pause history size %d: must be greater than 0", size)
+	}
+
+	return &outputPauser{
+		out:    out,
+		notice: notice,
+		size:   size,
+	}, nil
+}
+
+// Print writes s to the output or stores it in the history if the output is
+// paused.
+func (p *outputPauser) Print(s string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.pausedEvents++
+		p.stats.PauseBuffered++
+		if len(p.history) == p.size {
+			p.history = p.history[1:]
+			p.stats.PauseDropped++
+		}
+		p.history = append(p.history, s)
+		return
+	}
+
+	start := time.Now()
+	p.write(s)
+	if time.Since(start) > slowWriteThreshold {
+		p.stats.SlowWrites++
+	}
+}
+
+func (p *outputPauser) write(s string) {
+	io.WriteString(p.out, s)
+	p.stats.Written++
+}
+
+// Pause stops writing events to the output until Resume is called.
+func (p *outputPauser) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		return
+	}
+
+	p.paused = true
+	p.pausedEvents = 0
+	p.pausedAt = time.Now()
+
+	fmt.Fprintf(p.notice, "--- output paused, events received meanwhile are buffered ---\n")
+}
+
+// Resume goes back to writing events as they arrive. The events buffered while
+// paused are written first if replay is set, or skipped otherwise.
+func (p *outputPauser) Resume(replay bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		return
+	}
+
+	p.paused = false
+	dropped := p.pausedEvents - uint64(len(p.history))
+	pausedFor := time.Since(p.pausedAt).Round(time.Millisecond)
+
+	if replay {
+		fmt.Fprintf(p.notice, "--- output resumed after %s: replaying %d buffered events (%d dropped) ---\n",
+			pausedFor, len(p.history), dropped)
+		for _, s := range p.history {
+			p.write(s)
+		}
+		fmt.Fprintf(p.notice, "--- end of buffered events, now live ---\n")
+	} else {
+		p.stats.PauseSkipped += uint64(len(p.history))
+		fmt.Fprintf(p.notice, "--- output resumed after %s: skipped %d events ---\n",
+			pausedFor, p.pausedEvents)
+	}
+
+	p.history = nil
+}
+
+// Pending returns the number of events buffered during the current pause and
+// the number of them evicted because the history was full.
+func (p *outputPauser) Pending() (buffered, dropped uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return uint64(len(p.history)), p.pausedEvents - uint64(len(p.history))
+}
+
+// Paused returns whether the output is currently paused.
+func (p *outputPauser) Paused() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.paused
+}
+
+// Stats returns a copy of the counters.
+func (p *outputPauser) Stats() OutputStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.stats
+}
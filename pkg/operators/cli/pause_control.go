@@ -0,0 +1,119 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+const (
+	// pauseKey pauses the output
+	pauseKey = 'p'
+	// replayKey resumes the output, writing the buffered events first
+	replayKey = 'r'
+	// liveKey resumes the output, skipping the buffered events
+	liveKey = 'l'
+)
+
+// pauseControl lets the user pause the output, either by pressing pauseKey or
+// by stopping the process (Ctrl-Z), and choose on resume whether to replay the
+// events buffered meanwhile or to jump to live output.
+type pauseControl struct {
+	pauser *outputPauser
+	notice io.Writer
+
+	// keys is nil when keys can't be read from the terminal. The buffered
+	// events are then always replayed.
+	keys    *keyReader
+	stopped atomic.Bool
+}
+
+// newPauseControl reads keys from in if it's a terminal
+func newPauseControl(pauser *outputPauser, notice io.Writer, in *os.File) *pauseControl {
+	c := &pauseControl{
+		pauser: pauser,
+		notice: notice,
+	}
+	if keys, err := newKeyReader(int(in.Fd())); err == nil {
+		c.keys = keys
+		go c.readKeys(in)
+		fmt.Fprintf(notice, "--- press %c to pause the output ---\n", pauseKey)
+	}
+	return c
+}
+
+func (c *pauseControl) readKeys(in io.Reader) {
+	buf := make([]byte, 1)
+	for {
+		if _, err := in.Read(buf); err != nil {
+			return
+		}
+		if c.stopped.Load() {
+			return
+		}
+		c.onKey(buf[0])
+	}
+}
+
+func (c *pauseControl) onKey(key byte) {
+	if !c.pauser.Paused() {
+		if key == pauseKey {
+			c.pauser.Pause()
+			c.askResume()
+		}
+		return
+	}
+
+	switch key {
+	case replayKey:
+		c.pauser.Resume(true)
+	case liveKey:
+		c.pauser.Resume(false)
+	}
+}
+
+// askResume asks the user what to do with the buffered events
+func (c *pauseControl) askResume() {
+	if c.keys == nil {
+		c.pauser.Resume(true)
+		return
+	}
+	buffered, dropped := c.pauser.Pending()
+	fmt.Fprintf(c.notice, "--- %d events buffered so far (%d dropped): press %c to resume replaying them or %c to resume with live output ---\n",
+		buffered, dropped, replayKey, liveKey)
+}
+
+// suspend gives the terminal back before the process is stopped
+func (c *pauseControl) suspend() {
+	if c.keys != nil {
+		c.keys.restore()
+	}
+}
+
+// cont takes the terminal again once the process is continued
+func (c *pauseControl) cont() {
+	if c.keys != nil {
+		c.keys.enable()
+	}
+}
+
+// stop restores the terminal. Keys read afterwards are ignored.
+func (c *pauseControl) stop() {
+	c.stopped.Store(true)
+	c.suspend()
+}
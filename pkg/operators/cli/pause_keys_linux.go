@@ -0,0 +1,66 @@
+//go:build linux
+
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// keyReader puts a terminal in non-canonical mode without echo, so single key
+// presses can be read. Unlike raw mode, signal keys like Ctrl-C and Ctrl-Z keep
+// working.
+type keyReader struct {
+	fd    int
+	state *unix.Termios
+}
+
+func newKeyReader(fd int) (*keyReader, error) {
+	state, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, err
+	}
+	k := &keyReader{fd: fd, state: state}
+	if err := k.enable(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// enable sets the terminal up for reading keys. It fails if the process runs
+// in the background, where reading from the terminal would stop it.
+func (k *keyReader) enable() error {
+	pgrp, err := unix.IoctlGetInt(k.fd, unix.TIOCGPGRP)
+	if err != nil {
+		return err
+	}
+	if pgrp != unix.Getpgrp() {
+		return errors.New("not in the foreground")
+	}
+
+	t := *k.state
+	t.Lflag &^= unix.ICANON | unix.ECHO
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(k.fd, unix.TCSETS, &t)
+}
+
+// restore puts the terminal back in the state it was found in
+func (k *keyReader) restore() error {
+	return unix.IoctlSetTermios(k.fd, unix.TCSETS, k.state)
+}
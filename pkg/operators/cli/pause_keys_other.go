@@ -0,0 +1,37 @@
+//go:build !linux
+
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"errors"
+)
+
+// keyReader isn't supported outside Linux: the output can still be paused by
+// stopping the process where job control signals exist.
+type keyReader struct{}
+
+func newKeyReader(fd int) (*keyReader, error) {
+	return nil, errors.New("reading keys is not supported on this platform")
+}
+
+func (k *keyReader) enable() error {
+	return nil
+}
+
+func (k *keyReader) restore() error {
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals pauses the output on SIGTSTP (Ctrl-Z) and stops the process
+// with SIGSTOP, so job control works as usual: the Go runtime keeps ignoring
+// SIGTSTP once it has been caught, even after signal.Reset, so it can't just be
+// raised again. Once the process is continued, the user is asked what to do
+// with the events buffered meanwhile.
+func (c *pauseControl) handleSignals() (stop func()) {
+	tstp := make(chan os.Signal, 1)
+	cont := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(tstp, syscall.SIGTSTP)
+	signal.Notify(cont, syscall.SIGCONT)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-tstp:
+				c.pauser.Pause()
+				c.suspend()
+				syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+			case <-cont:
+				c.cont()
+				if c.pauser.Paused() {
+					c.askResume()
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(tstp)
+		signal.Stop(cont)
+		close(done)
+	}
+}
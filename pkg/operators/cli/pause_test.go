@@ -0,0 +1,124 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clioperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutputPauser(t *testing.T) {
+	type testCase struct {
+		replay         bool
+		expectedOut    string
+		expectedNotice string
+		expectedStats  OutputStats
+	}
+
+	tests := map[string]testCase{
+		"replay": {
+			replay:         true,
+			expectedOut:    "1\n3\n4\n5\n",
+			expectedNotice: "replaying 2 buffered events (1 dropped)",
+			expectedStats: OutputStats{
+				Written:       4,
+				PauseBuffered: 3,
+				PauseDropped:  1,
+			},
+		},
+		"live": {
+			expectedOut:    "1\n5\n",
+			expectedNotice: "skipped 3 events",
+			expectedStats: OutputStats{
+				Written:       2,
+				PauseBuffered: 3,
+				PauseDropped:  1,
+				PauseSkipped:  2,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var out, notice bytes.Buffer
+			p, err := newOutputPauser(&out, &notice, 2)
+			require.NoError(t, err)
+
+			p.Print("1\n")
+			p.Pause()
+			require.True(t, p.Paused())
+			p.Print("2\n")
+			p.Print("3\n")
+			p.Print("4\n")
+			require.Equal(t, "1\n", out.String())
+			buffered, dropped := p.Pending()
+			require.Equal(t, uint64(2), buffered)
+			require.Equal(t, uint64(1), dropped)
+			p.Resume(test.replay)
+			require.False(t, p.Paused())
+			p.Print("5\n")
+
+			require.Equal(t, test.expectedOut, out.String())
+			require.Contains(t, notice.String(), test.expectedNotice)
+			require.Equal(t, test.expectedStats, p.Stats())
+		})
+	}
+}
+
+func TestOutputPauserInvalid(t *testing.T) {
+	_, err := newOutputPauser(nil, nil, 0)
+	require.ErrorContains(t, err, "invalid pause history size")
+}
+
+func TestPauseControlKeys(t *testing.T) {
+	var out, notice bytes.Buffer
+	p, err := newOutputPauser(&out, &notice, 10)
+	require.NoError(t, err)
+	c := &pauseControl{pauser: p, notice: &notice, keys: &keyReader{}}
+
+	// Resume keys do nothing while not paused
+	c.onKey(liveKey)
+	require.False(t, p.Paused())
+
+	c.onKey(pauseKey)
+	require.True(t, p.Paused())
+	require.Contains(t, notice.String(), "press r to resume replaying them or l to resume with live output")
+
+	p.Print("1\n")
+	c.onKey('x')
+	require.True(t, p.Paused())
+	c.onKey(liveKey)
+	require.False(t, p.Paused())
+	require.Empty(t, out.String())
+
+	c.onKey(pauseKey)
+	p.Print("2\n")
+	c.onKey(replayKey)
+	require.False(t, p.Paused())
+	require.Equal(t, "2\n", out.String())
+
+	// Without keys, the buffered events are replayed right away
+	c.keys = nil
+	p.Pause()
+	p.Print("3\n")
+	c.askResume()
+	require.False(t, p.Paused())
+	require.Equal(t, "2\n3\n", out.String())
+}
@@ -0,0 +1,165 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// addressParam holds what's needed to write the value of an ipv4, ipv6 or cidr
+// param into the variables backing it and, for cidr params, into an LPM trie
+// map
+type addressParam struct {
+	varName  string
+	typeHint params.TypeHint
+	// size of the address variable, 4 bytes for IPv4 and 16 for IPv6
+	size int
+	// size of the <varName>_prefixlen variable of cidr params
+	prefixLenSize int
+	lpmMap        string
+}
+
+// lpmMapUpdater is the subset of *ebpf.Map used to insert networks into LPM
+// trie maps
+type lpmMapUpdater interface {
+	ValueSize() uint32
+	Update(key, value any, flags ebpf.MapUpdateFlags) error
+}
+
+func (i *ebpfInstance) newAddressParam(btfVar *btf.Var, typeHint params.TypeHint, lpmMap string) (*addressParam, error) {
+	size, err := btf.Sizeof(btfVar.Type)
+	if err != nil {
+		return nil, fmt.Errorf("getting size of %q: %w", btfVar.Name, err)
+	}
+	if size != net.IPv4len && size != net.IPv6len {
+		return nil, fmt.Errorf("%s params need a variable of %d or %d bytes, %q has %d",
+			typeHint, net.IPv4len, net.IPv6len, btfVar.Name, size)
+	}
+
+	a := &addressParam{
+		varName:  btfVar.Name,
+		typeHint: typeHint,
+		size:     size,
+	}
+	if typeHint != params.TypeCIDR {
+		return a, nil
+	}
+
+	var prefixLenVar *btf.Var
+	prefixLenName := btfVar.Name + gadgets.PrefixLenSuffix
+	if err := i.collectionSpec.Types.TypeByName(prefixLenName, &prefixLenVar); err != nil {
+		return nil, fmt.Errorf("looking up prefix length variable %q: %w", prefixLenName, err)
+	}
+	a.prefixLenSize, err = btf.Sizeof(prefixLenVar.Type)
+	if err != nil {
+		return nil, fmt.Errorf("getting size of %q: %w", prefixLenName, err)
+	}
+	a.lpmMap = lpmMap
+	return a, nil
+}
+
+// encode returns the address given by value in network byte order, sized for
+// the variable backing the param, and its prefix length
+func (a *addressParam) encode(value string) ([]byte, int, error) {
+	var ip net.IP
+	if a.typeHint == params.TypeCIDR {
+		_, ipNet, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%q is not a valid CIDR", value)
+		}
+		ip = ipNet.IP
+		ones, _ := ipNet.Mask.Size()
+		if ip.To4() != nil && ones > 8*net.IPv4len {
+			// IPv4-mapped IPv6 network
+			ones -= 8 * (net.IPv6len - net.IPv4len)
+		}
+		return a.sized(value, ip, ones)
+	}
+
+	ip = net.ParseIP(value)
+	if ip == nil {
+		return nil, 0, fmt.Errorf("%q is not a valid IP address", value)
+	}
+	return a.sized(value, ip, 8*a.size)
+}
+
+func (a *addressParam) sized(value string, ip net.IP, prefixLen int) ([]byte, int, error) {
+	if a.size == net.IPv4len {
+		ip4 := ip.To4()
+		if ip4 == nil {
+			return nil, 0, fmt.Errorf("%q is an IPv6 address but %q holds an IPv4 one", value, a.varName)
+		}
+		return ip4, prefixLen, nil
+	}
+	if ip.To4() != nil {
+		return nil, 0, fmt.Errorf("%q is an IPv4 address but %q holds an IPv6 one", value, a.varName)
+	}
+	return ip.To16(), prefixLen, nil
+}
+
+// rewrite sets the variables backing the param to value. It returns the key
+// to insert into the LPM trie map of the param, if any.
+func (a *addressParam) rewrite(value string, consts map[string]any) ([]byte, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	addr, prefixLen, err := a.encode(value)
+	if err != nil {
+		return nil, err
+	}
+	consts[a.varName] = addr
+	if a.typeHint != params.TypeCIDR {
+		return nil, nil
+	}
+
+	prefixLenBytes, err := nativeUint(uint64(prefixLen), a.prefixLenSize)
+	if err != nil {
+		return nil, fmt.Errorf("prefix length: %w", err)
+	}
+	consts[a.varName+gadgets.PrefixLenSuffix] = prefixLenBytes
+
+	if a.lpmMap == "" {
+		return nil, nil
+	}
+	return lpmKey(addr, prefixLen), nil
+}
+
+// lpmKey returns the key of an LPM trie map for the given network, laid out as
+// struct bpf_lpm_trie_key: the prefix length in host byte order followed by the
+// address in network byte order
+func lpmKey(addr []byte, prefixLen int) []byte {
+	key := binary.NativeEndian.AppendUint32(nil, uint32(prefixLen))
+	return append(key, addr...)
+}
+
+// insertLPMKeys inserts the networks given by keys into m, with zeroed values
+func insertLPMKeys(m lpmMapUpdater, keys [][]byte) error {
+	value := make([]byte, m.ValueSize())
+	for _, key := range keys {
+		if err := m.Update(key, value, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
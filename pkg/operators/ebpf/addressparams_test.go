@@ -0,0 +1,235 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestAddressParamRewrite(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		address           *addressParam
+		value             string
+		expectedConsts    map[string]any
+		expectedKey       []byte
+		expectedErrString string
+	}
+
+	prefixLen := func(n uint32) []byte {
+		return binary.NativeEndian.AppendUint32(nil, n)
+	}
+
+	tests := map[string]testCase{
+		"ipv4": {
+			address:        &addressParam{varName: "saddr", typeHint: params.TypeIPv4, size: net.IPv4len},
+			value:          "10.0.0.1",
+			expectedConsts: map[string]any{"saddr": []byte{10, 0, 0, 1}},
+		},
+		"ipv6": {
+			address: &addressParam{varName: "saddr6", typeHint: params.TypeIPv6, size: net.IPv6len},
+			value:   "2001:db8::1",
+			expectedConsts: map[string]any{
+				"saddr6": []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			},
+		},
+		"unset": {
+			address:        &addressParam{varName: "saddr", typeHint: params.TypeIPv4, size: net.IPv4len},
+			expectedConsts: map[string]any{},
+		},
+		"cidr": {
+			address: &addressParam{varName: "net", typeHint: params.TypeCIDR, size: net.IPv4len, prefixLenSize: 1},
+			value:   "10.0.0.0/8",
+			expectedConsts: map[string]any{
+				"net":           []byte{10, 0, 0, 0},
+				"net_prefixlen": []byte{8},
+			},
+		},
+		"cidr_lpm_map": {
+			address: &addressParam{
+				varName:       "net",
+				typeHint:      params.TypeCIDR,
+				size:          net.IPv4len,
+				prefixLenSize: 4,
+				lpmMap:        "nets",
+			},
+			value: "192.168.1.0/24",
+			expectedConsts: map[string]any{
+				"net":           []byte{192, 168, 1, 0},
+				"net_prefixlen": prefixLen(24),
+			},
+			expectedKey: append(prefixLen(24), 192, 168, 1, 0),
+		},
+		"cidr_host_bits_cleared": {
+			address: &addressParam{varName: "net", typeHint: params.TypeCIDR, size: net.IPv4len, prefixLenSize: 1},
+			value:   "192.168.1.42/24",
+			expectedConsts: map[string]any{
+				"net":           []byte{192, 168, 1, 0},
+				"net_prefixlen": []byte{24},
+			},
+		},
+		"cidr_v6": {
+			address: &addressParam{varName: "net6", typeHint: params.TypeCIDR, size: net.IPv6len, prefixLenSize: 4},
+			value:   "2001:db8::/32",
+			expectedConsts: map[string]any{
+				"net6":           []byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0},
+				"net6_prefixlen": prefixLen(32),
+			},
+		},
+		"invalid_address": {
+			address:           &addressParam{varName: "saddr", typeHint: params.TypeIPv4, size: net.IPv4len},
+			value:             "10.0.0.256",
+			expectedErrString: "\"10.0.0.256\" is not a valid IP address",
+		},
+		"ipv6_into_ipv4": {
+			address:           &addressParam{varName: "saddr", typeHint: params.TypeIPv4, size: net.IPv4len},
+			value:             "::1",
+			expectedErrString: "\"::1\" is an IPv6 address but \"saddr\" holds an IPv4 one",
+		},
+		"ipv4_cidr_into_ipv6": {
+			address:           &addressParam{varName: "net6", typeHint: params.TypeCIDR, size: net.IPv6len, prefixLenSize: 4},
+			value:             "10.0.0.0/8",
+			expectedErrString: "\"10.0.0.0/8\" is an IPv4 address but \"net6\" holds an IPv6 one",
+		},
+		"invalid_cidr": {
+			address:           &addressParam{varName: "net", typeHint: params.TypeCIDR, size: net.IPv4len, prefixLenSize: 1},
+			value:             "10.0.0.0/33",
+			expectedErrString: "\"10.0.0.0/33\" is not a valid CIDR",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			consts := make(map[string]any)
+			key, err := test.address.rewrite(test.value, consts)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedConsts, consts)
+			require.Equal(t, test.expectedKey, key)
+		})
+	}
+}
+
+// fakeLPMMap records the entries inserted into an LPM trie map
+type fakeLPMMap struct {
+	valueSize uint32
+	entries   map[string][]byte
+}
+
+func (m *fakeLPMMap) ValueSize() uint32 {
+	return m.valueSize
+}
+
+func (m *fakeLPMMap) Update(key, value any, flags ebpf.MapUpdateFlags) error {
+	m.entries[string(key.([]byte))] = value.([]byte)
+	return nil
+}
+
+func TestInsertLPMKeys(t *testing.T) {
+	t.Parallel()
+
+	a := &addressParam{varName: "net", typeHint: params.TypeCIDR, size: net.IPv4len, prefixLenSize: 4, lpmMap: "nets"}
+	key, err := a.rewrite("192.168.1.0/24", make(map[string]any))
+	require.NoError(t, err)
+
+	lpmMap := &fakeLPMMap{valueSize: 4, entries: make(map[string][]byte)}
+	require.NoError(t, insertLPMKeys(lpmMap, [][]byte{key}))
+
+	expectedKey := append(binary.NativeEndian.AppendUint32(nil, 24), 192, 168, 1, 0)
+	require.Equal(t, map[string][]byte{string(expectedKey): make([]byte, 4)}, lpmMap.entries)
+}
+
+func TestPopulateAddressParams(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "unsigned int", Size: 4}
+	u8 := &btf.Int{Name: "unsigned char", Size: 1}
+	spec := newTestSpec(t,
+		constVar("saddr", u32),
+		constVar("saddr6", &btf.Array{Type: u8, Index: u32, Nelems: 16}),
+		constVar("net", u32),
+		constVar("net_prefixlen", u8),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  saddr:
+    key: saddr
+    typeHint: ipv4
+  saddr6:
+    key: saddr6
+    typeHint: ipv6
+  net:
+    key: net
+    typeHint: cidr
+    lpmMap: nets
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	for _, varName := range []string{"saddr", "saddr6", "net"} {
+		require.NoError(t, i.populateParam(nil, varName))
+	}
+
+	require.Equal(t, &addressParam{varName: "saddr", typeHint: params.TypeIPv4, size: 4}, i.params["saddr"].address)
+	require.Equal(t, string(params.TypeIPv4), i.params["saddr"].TypeHint)
+	require.Equal(t, &addressParam{varName: "saddr6", typeHint: params.TypeIPv6, size: 16}, i.params["saddr6"].address)
+	require.Zero(t, i.params["saddr6"].arrayLen)
+	require.Equal(t, &addressParam{
+		varName:       "net",
+		typeHint:      params.TypeCIDR,
+		size:          4,
+		prefixLenSize: 1,
+		lpmMap:        "nets",
+	}, i.params["net"].address)
+
+	// Invalid addresses are rejected when parsing the values, before loading
+	parameters := params.Params{}
+	for _, p := range i.params {
+		desc := apihelpers.ParamToParamDesc(p.Param)
+		desc.Validator = p.validator
+		parameters = append(parameters, desc.ToParam())
+	}
+	require.NoError(t, parameters.CopyFromMap(map[string]string{"saddr": "10.0.0.1", "net": "10.0.0.0/8"}, ""))
+	err := parameters.CopyFromMap(map[string]string{"saddr6": "10.0.0.1"}, "")
+	require.ErrorContains(t, err, "\"10.0.0.1\" is not a valid IPv6 address")
+	err = parameters.CopyFromMap(map[string]string{"net": "10.0.0.0"}, "")
+	require.ErrorContains(t, err, "\"10.0.0.0\" is not a valid CIDR")
+}
@@ -0,0 +1,200 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// aggregator collapses the events of a tracer with the same key fields
+// received within a window, see metadatav1.Aggregation
+type aggregator struct {
+	ds         datasource.DataSource
+	accessor   datasource.FieldAccessor
+	countField datasource.FieldAccessor
+
+	keyFields       []*Field
+	aggregateFields []*Field
+	window          time.Duration
+
+	mu sync.Mutex
+	// groups holds the groups of the current window by key, order the
+	// same groups in the order their first event was received
+	groups map[string]*aggregateGroup
+	order  []*aggregateGroup
+}
+
+type aggregateGroup struct {
+	// event is the first event of the group, with the aggregated fields
+	// combined with the ones of the next events
+	event []byte
+	count uint64
+}
+
+func newAggregator(ds datasource.DataSource, accessor datasource.FieldAccessor, aggregation metadatav1.Aggregation, fields []*Field) (*aggregator, error) {
+	window, err := time.ParseDuration(aggregation.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("invalid window %q", aggregation.Window)
+	}
+
+	a := &aggregator{
+		ds:       ds,
+		accessor: accessor,
+		window:   window,
+		groups:   make(map[string]*aggregateGroup),
+	}
+	for _, f := range fields {
+		if f.Attributes.Key {
+			a.keyFields = append(a.keyFields, f)
+		}
+		if f.Attributes.Aggregate != "" {
+			if integer, _ := isIntegerKind(f.kind); !integer {
+				return nil, fmt.Errorf("field %q: only integer fields can be aggregated", f.Name)
+			}
+			a.aggregateFields = append(a.aggregateFields, f)
+		}
+	}
+	if len(a.keyFields) == 0 {
+		return nil, fmt.Errorf("aggregation requires key fields")
+	}
+
+	a.countField, err = ds.AddField(aggregation.CountField, api.Kind_Uint64,
+		datasource.WithAnnotations(map[string]string{
+			"description": "Number of events collapsed into this one",
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("adding field %q: %w", aggregation.CountField, err)
+	}
+	return a, nil
+}
+
+// key returns the key of the group of an event: the values of its key
+// fields. Strings end at their first NUL, the bytes after it don't matter.
+func (a *aggregator) key(event []byte) string {
+	var key []byte
+	for _, f := range a.keyFields {
+		value := event[f.Offset : f.Offset+f.Size]
+		if f.kind == api.Kind_CString {
+			if end := bytes.IndexByte(value, 0); end >= 0 {
+				value = value[:end]
+			}
+			key = append(key, value...)
+			key = append(key, 0)
+			continue
+		}
+		key = append(key, value...)
+	}
+	return string(key)
+}
+
+// add adds an event to its group, creating it if it's the first event of
+// the group in the current window
+func (a *aggregator) add(event []byte) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := a.key(event)
+	group, ok := a.groups[key]
+	if !ok {
+		group = &aggregateGroup{event: bytes.Clone(event)}
+		a.groups[key] = group
+		a.order = append(a.order, group)
+	} else {
+		a.combine(group.event, event)
+	}
+	group.count++
+}
+
+// combine aggregates the fields of event into the ones of the event of its
+// group
+func (a *aggregator) combine(dst, event []byte) {
+	for _, f := range a.aggregateFields {
+		current := fieldValue(a.ds, f, dst)
+		value := fieldValue(a.ds, f, event)
+		_, signed := isIntegerKind(f.kind)
+
+		switch f.Attributes.Aggregate {
+		case metadatav1.AggregateSum:
+			current += value
+		case metadatav1.AggregateMax:
+			if (signed && int64(value) > int64(current)) || (!signed && value > current) {
+				current = value
+			}
+		case metadatav1.AggregateMin:
+			if (signed && int64(value) < int64(current)) || (!signed && value < current) {
+				current = value
+			}
+		}
+		setFieldValue(a.ds, f, dst, current)
+	}
+}
+
+// flush emits one event per group of the current window, in the order their
+// first event was received, and starts a new window
+func (a *aggregator) flush() error {
+	a.mu.Lock()
+	order := a.order
+	a.groups = make(map[string]*aggregateGroup)
+	a.order = nil
+	a.mu.Unlock()
+
+	for _, group := range order {
+		data, err := a.ds.NewPacketSingle()
+		if err != nil {
+			return fmt.Errorf("creating new packet: %w", err)
+		}
+		if err := a.accessor.Set(data, group.event); err != nil {
+			a.ds.Release(data)
+			return fmt.Errorf("setting buffer: %w", err)
+		}
+		if err := a.countField.PutUint64(data, group.count); err != nil {
+			a.ds.Release(data)
+			return fmt.Errorf("setting count: %w", err)
+		}
+		if err := a.ds.EmitAndRelease(data); err != nil {
+			return fmt.Errorf("emitting data: %w", err)
+		}
+	}
+	return nil
+}
+
+// run flushes the groups at the end of each window until ctx is done. The
+// partial window left is flushed by the caller, once no more events can be
+// added.
+func (a *aggregator) run(ctx context.Context, logger logger.Logger) {
+	ticker := time.NewTicker(a.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := a.flush(); err != nil {
+				logger.Warnf("flushing aggregated events: %v", err)
+			}
+		}
+	}
+}
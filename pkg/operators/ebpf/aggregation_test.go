@@ -0,0 +1,194 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// openEvent is laid out as: pid (u32), ret (s32), fname (char[8]),
+// latency (u32), bytes (u32)
+const openEventSize = 24
+
+func openEvent(pid uint32, ret int32, fname string, latency, bytes uint32) []byte {
+	event := make([]byte, openEventSize)
+	binary.NativeEndian.PutUint32(event[0:], pid)
+	binary.NativeEndian.PutUint32(event[4:], uint32(ret))
+	copy(event[8:16], fname)
+	binary.NativeEndian.PutUint32(event[16:], latency)
+	binary.NativeEndian.PutUint32(event[20:], bytes)
+	return event
+}
+
+type aggregatedEvent struct {
+	pid     uint32
+	ret     int32
+	fname   string
+	latency uint32
+	bytes   uint32
+	count   uint64
+}
+
+func newOpenAggregator(t *testing.T, window string) (*aggregator, *[]aggregatedEvent, *sync.Mutex) {
+	t.Helper()
+
+	fields := []*Field{
+		{Field: metadatav1.Field{Name: "pid", Attributes: metadatav1.FieldAttributes{Key: true}}, Offset: 0, Size: 4, parent: -1, kind: api.Kind_Uint32, name: "pid"},
+		{Field: metadatav1.Field{Name: "ret", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateMin}}, Offset: 4, Size: 4, parent: -1, kind: api.Kind_Int32, name: "ret"},
+		{Field: metadatav1.Field{Name: "fname", Attributes: metadatav1.FieldAttributes{Key: true}}, Offset: 8, Size: 8, parent: -1, kind: api.Kind_CString, name: "fname"},
+		{Field: metadatav1.Field{Name: "latency", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateMax}}, Offset: 16, Size: 4, parent: -1, kind: api.Kind_Uint32, name: "latency"},
+		{Field: metadatav1.Field{Name: "bytes", Attributes: metadatav1.FieldAttributes{Aggregate: metadatav1.AggregateSum}}, Offset: 20, Size: 4, parent: -1, kind: api.Kind_Uint32, name: "bytes"},
+	}
+
+	ds, err := datasource.New(datasource.TypeSingle, "open")
+	require.NoError(t, err)
+	staticFields := make([]datasource.StaticField, 0, len(fields))
+	for _, f := range fields {
+		staticFields = append(staticFields, f)
+	}
+	accessor, err := ds.AddStaticFields(openEventSize, staticFields)
+	require.NoError(t, err)
+
+	a, err := newAggregator(ds, accessor, metadatav1.Aggregation{Window: window, CountField: "count"}, fields)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var events []aggregatedEvent
+	ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+		event := accessor.Get(data)
+		count, err := a.countField.Uint64(data)
+		require.NoError(t, err)
+		fname := event[8:16]
+		for i, c := range fname {
+			if c == 0 {
+				fname = fname[:i]
+				break
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, aggregatedEvent{
+			pid:     binary.NativeEndian.Uint32(event[0:]),
+			ret:     int32(binary.NativeEndian.Uint32(event[4:])),
+			fname:   string(fname),
+			latency: binary.NativeEndian.Uint32(event[16:]),
+			bytes:   binary.NativeEndian.Uint32(event[20:]),
+			count:   count,
+		})
+		return nil
+	}, 0)
+
+	return a, &events, &mu
+}
+
+func TestAggregator(t *testing.T) {
+	t.Parallel()
+
+	a, events, _ := newOpenAggregator(t, "1s")
+
+	a.add(openEvent(10, 0, "/etc/a", 5, 100))
+	a.add(openEvent(20, 0, "/etc/a", 1, 10))
+	// Same key as the first event, the bytes after the NUL don't matter
+	garbage := openEvent(10, -2, "/etc/a", 9, 50)
+	garbage[15] = 'x'
+	a.add(garbage)
+	a.add(openEvent(10, 0, "/etc/b", 3, 1))
+	a.add(openEvent(20, -13, "/etc/a", 2, 20))
+	a.add(openEvent(10, 0, "/etc/a", 7, 25))
+
+	require.NoError(t, a.flush())
+	require.Equal(t, []aggregatedEvent{
+		// Groups are emitted in the order their first event was received
+		{pid: 10, ret: -2, fname: "/etc/a", latency: 9, bytes: 175, count: 3},
+		{pid: 20, ret: -13, fname: "/etc/a", latency: 2, bytes: 30, count: 2},
+		{pid: 10, ret: 0, fname: "/etc/b", latency: 3, bytes: 1, count: 1},
+	}, *events)
+
+	// Each window starts from scratch
+	*events = nil
+	require.NoError(t, a.flush())
+	require.Empty(t, *events)
+
+	a.add(openEvent(10, 0, "/etc/a", 1, 1))
+	require.NoError(t, a.flush())
+	require.Equal(t, []aggregatedEvent{
+		{pid: 10, fname: "/etc/a", latency: 1, bytes: 1, count: 1},
+	}, *events)
+}
+
+func TestAggregatorWindow(t *testing.T) {
+	t.Parallel()
+
+	a, events, mu := newOpenAggregator(t, "10ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.run(ctx, logger.DefaultLogger())
+		close(done)
+	}()
+
+	a.add(openEvent(10, 0, "/etc/a", 1, 1))
+	a.add(openEvent(10, 0, "/etc/a", 1, 1))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(*events) == 1
+	}, time.Second, time.Millisecond)
+
+	mu.Lock()
+	require.Equal(t, uint64(2), (*events)[0].count)
+	mu.Unlock()
+
+	// Stopping doesn't flush: the tracer does it once no more events can be
+	// added, so the partial window isn't lost
+	cancel()
+	<-done
+	a.add(openEvent(20, 0, "/etc/b", 1, 1))
+	require.NoError(t, a.flush())
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, *events, 2)
+	require.Equal(t, aggregatedEvent{pid: 20, fname: "/etc/b", latency: 1, bytes: 1, count: 1}, (*events)[1])
+}
+
+func TestNewAggregator(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "open")
+	require.NoError(t, err)
+
+	fields := []*Field{
+		{Field: metadatav1.Field{Name: "pid"}, Offset: 0, Size: 4, parent: -1, kind: api.Kind_Uint32, name: "pid"},
+	}
+	_, err = newAggregator(ds, nil, metadatav1.Aggregation{Window: "1s", CountField: "count"}, fields)
+	require.ErrorContains(t, err, "aggregation requires key fields")
+
+	_, err = newAggregator(ds, nil, metadatav1.Aggregation{Window: "0s", CountField: "count"}, fields)
+	require.ErrorContains(t, err, "invalid window \"0s\"")
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/expr"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const alertFieldName = "alert"
+
+// alertRule is an alert declared in the metadata, ready to be evaluated on the
+// events of its data source
+type alertRule struct {
+	metadatav1.Alert
+	name      string
+	condition *expr.Expr
+}
+
+// initAlertsFormatter tags the events matching the alerts declared in the
+// metadata with the alert.name, alert.severity and alert.message fields. It
+// runs after the computed fields are added.
+func (i *ebpfInstance) initAlertsFormatter(gadgetCtx operators.GadgetContext) error {
+	var alerts map[string]metadatav1.Alert
+	if err := i.config.UnmarshalKey("alerts", &alerts); err != nil {
+		return fmt.Errorf("reading alerts: %w", err)
+	}
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	dataSources := make(map[string]datasource.DataSource)
+	for ds := range i.dataSourceStructs() {
+		dataSources[ds.Name()] = ds
+	}
+
+	rules := make(map[datasource.DataSource][]*alertRule)
+	for _, name := range sortedKeys(alerts) {
+		alert := alerts[name]
+		ds, ok := dataSources[alert.DataSource]
+		if !ok {
+			return fmt.Errorf("alert %q: data source %q not found", name, alert.DataSource)
+		}
+		condition, err := expr.Parse(alert.Condition)
+		if err != nil {
+			return fmt.Errorf("alert %q: %w", name, err)
+		}
+		rules[ds] = append(rules[ds], &alertRule{Alert: alert, name: name, condition: condition})
+	}
+
+	for ds, dsRules := range rules {
+		if err := i.addAlerts(ds, dsRules); err != nil {
+			return fmt.Errorf("adding alerts to %q: %w", ds.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (i *ebpfInstance) addAlerts(ds datasource.DataSource, rules []*alertRule) error {
+	accessors := make(map[string]datasource.FieldAccessor)
+	for _, rule := range rules {
+		for _, name := range append(rule.condition.Fields(), types.AlertMessageFields(rule.Alert)...) {
+			acc := ds.GetField(name)
+			if acc == nil {
+				return fmt.Errorf("alert %q: field %q not found", rule.name, name)
+			}
+			accessors[name] = acc
+		}
+	}
+
+	alert, err := ds.AddField(alertFieldName, api.Kind_Invalid, datasource.WithFlags(datasource.FieldFlagEmpty))
+	if err != nil {
+		return err
+	}
+	nameField, err := alert.AddSubField("name", api.Kind_String)
+	if err != nil {
+		return err
+	}
+	severityField, err := alert.AddSubField("severity", api.Kind_String)
+	if err != nil {
+		return err
+	}
+	messageField, err := alert.AddSubField("message", api.Kind_String)
+	if err != nil {
+		return err
+	}
+
+	i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+		lookup := func(name string) (any, error) {
+			return exprValue(accessors[name], data)
+		}
+
+		var names, messages []string
+		var severity metadatav1.AlertSeverity
+		for _, rule := range rules {
+			matched, err := rule.condition.Eval(lookup)
+			if err != nil || matched != true {
+				continue
+			}
+			names = append(names, rule.name)
+			if rule.Severity.Level() > severity.Level() {
+				severity = rule.Severity
+			}
+			if rule.Message != "" {
+				messages = append(messages, types.FormatAlertMessage(rule.Alert, func(field string) string {
+					v, err := lookup(field)
+					if err != nil {
+						return ""
+					}
+					return expr.Format(v)
+				}))
+			}
+		}
+
+		if err := nameField.PutString(data, strings.Join(names, ",")); err != nil {
+			return err
+		}
+		if err := severityField.PutString(data, string(severity)); err != nil {
+			return err
+		}
+		return messageField.PutString(data, strings.Join(messages, "; "))
+	})
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/expr"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func alertRules(t *testing.T, alerts map[string]metadatav1.Alert) []*alertRule {
+	t.Helper()
+
+	var rules []*alertRule
+	for _, name := range sortedKeys(alerts) {
+		condition, err := expr.Parse(alerts[name].Condition)
+		require.NoError(t, err)
+		rules = append(rules, &alertRule{Alert: alerts[name], name: name, condition: condition})
+	}
+	return rules
+}
+
+func TestAlerts(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		uid              uint32
+		ret              int32
+		comm             string
+		expectedName     string
+		expectedSeverity string
+		expectedMessage  string
+	}
+
+	tests := map[string]testCase{
+		"no_match": {
+			uid:  1000,
+			comm: "cat",
+		},
+		"one_match": {
+			uid:              0,
+			comm:             "cat",
+			expectedName:     "root",
+			expectedSeverity: "warning",
+		},
+		"highest_severity": {
+			uid:              0,
+			ret:              -2,
+			comm:             "cat",
+			expectedName:     "failed,root",
+			expectedSeverity: "critical",
+			expectedMessage:  "cat failed with -2",
+		},
+		"several_messages": {
+			uid:              1000,
+			ret:              -13,
+			comm:             "sh",
+			expectedName:     "failed,shell",
+			expectedSeverity: "critical",
+			expectedMessage:  "sh failed with -13; shell started",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := datasource.New(datasource.TypeSingle, "exec")
+			require.NoError(t, err)
+			uid, err := ds.AddField("uid", api.Kind_Uint32)
+			require.NoError(t, err)
+			ret, err := ds.AddField("ret", api.Kind_Int32)
+			require.NoError(t, err)
+			comm, err := ds.AddField("comm", api.Kind_String)
+			require.NoError(t, err)
+
+			i := &ebpfInstance{
+				formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+			}
+			require.NoError(t, i.addAlerts(ds, alertRules(t, map[string]metadatav1.Alert{
+				"root": {
+					Condition: "uid == 0",
+					Severity:  metadatav1.AlertSeverityWarning,
+				},
+				"failed": {
+					Condition: "ret < 0",
+					Severity:  metadatav1.AlertSeverityCritical,
+					Message:   "{comm} failed with {ret}",
+				},
+				"shell": {
+					Condition: `comm == "sh"`,
+					Severity:  metadatav1.AlertSeverityInfo,
+					Message:   "shell started",
+				},
+			})))
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, uid.PutUint32(data, test.uid))
+			require.NoError(t, ret.PutInt32(data, test.ret))
+			require.NoError(t, comm.PutString(data, test.comm))
+
+			for _, formatter := range i.formatters[ds] {
+				require.NoError(t, formatter(ds, data))
+			}
+
+			for field, expected := range map[string]string{
+				"alert.name":     test.expectedName,
+				"alert.severity": test.expectedSeverity,
+				"alert.message":  test.expectedMessage,
+			} {
+				out, err := ds.GetField(field).String(data)
+				require.NoError(t, err)
+				require.Equal(t, expected, out, field)
+			}
+		})
+	}
+}
+
+func TestAlertsUnknownField(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "exec")
+	require.NoError(t, err)
+
+	i := &ebpfInstance{
+		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+	}
+	err = i.addAlerts(ds, alertRules(t, map[string]metadatav1.Alert{
+		"root": {Condition: "uid == 0", Severity: metadatav1.AlertSeverityWarning},
+	}))
+	require.ErrorContains(t, err, `alert "root": field "uid" not found`)
+}
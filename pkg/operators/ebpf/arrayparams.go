@@ -0,0 +1,102 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// arrayParam holds what's needed to write the values of a list param into
+// the array backing it, like up to 4 ports to watch, and their number into
+// the companion variable with the ArrayLenSuffix suffix
+type arrayParam struct {
+	elemType params.TypeHint
+	nelems   int
+	lenVar   string
+	lenSize  int
+	// elemValidator checks each value against the constraints of the param
+	elemValidator params.ParamValidator
+}
+
+func (i *ebpfInstance) newArrayParam(btfVar *btf.Var, constraints *metadatav1.EBPFParam) (*arrayParam, error) {
+	elemType, nelems, ok := btfhelpers.IntArray(btfVar.Type)
+	if !ok {
+		return nil, fmt.Errorf("%q isn't an array of integers", btfVar.Name)
+	}
+
+	lenVarName := btfVar.Name + metadatav1.ArrayLenSuffix
+	var lenVar *btf.Var
+	if err := i.collectionSpec.Types.TypeByName(lenVarName, &lenVar); err != nil {
+		return nil, fmt.Errorf("variable %q holding the number of values not found: %w", lenVarName, err)
+	}
+	lenType, ok := btfhelpers.GetUnderlyingType(lenVar.Type).(*btf.Int)
+	if !ok || lenType.Encoding == btf.Bool {
+		return nil, fmt.Errorf("%q must be an integer, it's set to the number of values", lenVarName)
+	}
+
+	elemValidator, err := types.ParamValidator(constraints, elemType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &arrayParam{
+		elemType:      elemType,
+		nelems:        int(nelems),
+		lenVar:        lenVarName,
+		lenSize:       int(lenType.Size),
+		elemValidator: elemValidator,
+	}, nil
+}
+
+// validate checks the values fit in the array and satisfy the constraints
+// of the param
+func (a *arrayParam) validate(value string) error {
+	if _, _, err := types.EncodeArray(value, a.elemType, a.nelems); err != nil {
+		return err
+	}
+	if a.elemValidator == nil || strings.TrimSpace(value) == "" {
+		return nil
+	}
+	for _, elem := range strings.Split(value, ",") {
+		if err := a.elemValidator(strings.TrimSpace(elem)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite sets the array backing the param to the values given by value,
+// zero-filled after them, and its companion variable to their number
+func (a *arrayParam) rewrite(varName, value string, consts map[string]any) error {
+	array, n, err := types.EncodeArray(value, a.elemType, a.nelems)
+	if err != nil {
+		return err
+	}
+	count, err := nativeUint(uint64(n), a.lenSize)
+	if err != nil {
+		return err
+	}
+	consts[varName] = array
+	consts[a.lenVar] = count
+	return nil
+}
@@ -0,0 +1,152 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// arrayParamInstance returns an instance whose object declares
+// const volatile __u16 ports[4], and const volatile __u32 ports_len if withLen
+// is set, with the given metadata
+func arrayParamInstance(t *testing.T, metadata string, withLen bool) *ebpfInstance {
+	t.Helper()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	types := []btf.Type{
+		&btf.Var{
+			Name: "ports",
+			Type: &btf.Array{
+				Type:   &btf.Const{Type: &btf.Volatile{Type: &btf.Int{Name: "__u16", Size: 2}}},
+				Index:  u32,
+				Nelems: 4,
+			},
+			Linkage: btf.GlobalVar,
+		},
+	}
+	if withLen {
+		types = append(types, constVar("ports_len", u32))
+	}
+	spec := newTestSpec(t, types...)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(metadata)))
+
+	return &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+}
+
+func TestArrayParams(t *testing.T) {
+	t.Parallel()
+
+	i := arrayParamInstance(t, `
+params:
+  ports:
+    key: ports
+    list: true
+    min: 1
+`, true)
+	require.NoError(t, i.populateParam(nil, "ports"))
+	p := i.params["ports"]
+	require.NotNil(t, p.array)
+	require.Equal(t, "ports_len", p.array.lenVar)
+
+	desc := apihelpers.ParamToParamDesc(p.Param)
+	desc.Validator = p.validator
+
+	type testCase struct {
+		value             string
+		expectedPorts     []uint16
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"full": {
+			value:         "22,80,443,8080",
+			expectedPorts: []uint16{22, 80, 443, 8080},
+		},
+		"partially_full": {
+			value:         "80, 443",
+			expectedPorts: []uint16{80, 443},
+		},
+		"empty": {
+			value: "",
+		},
+		"over_capacity": {
+			value:             "22,80,443,8080,8443",
+			expectedErrString: "at most 4 values can be given, got 5",
+		},
+		"out_of_range": {
+			value:             "80,65536",
+			expectedErrString: "65536",
+		},
+		"below_min": {
+			value:             "0,80",
+			expectedErrString: "0",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			param := desc.ToParam()
+			err := param.Set(test.value)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+
+			consts := make(map[string]any)
+			require.NoError(t, p.array.rewrite("ports", param.String(), consts))
+
+			// Values are followed by zeros
+			expectedArray := make([]byte, 4*2)
+			for i, port := range test.expectedPorts {
+				binary.NativeEndian.PutUint16(expectedArray[i*2:], port)
+			}
+			require.Equal(t, expectedArray, consts["ports"])
+			require.Equal(t, binary.NativeEndian.AppendUint32(nil, uint32(len(test.expectedPorts))), consts["ports_len"])
+		})
+	}
+}
+
+func TestArrayParamsMissingLen(t *testing.T) {
+	t.Parallel()
+
+	i := arrayParamInstance(t, `
+params:
+  ports:
+    key: ports
+    list: true
+`, false)
+	require.ErrorContains(t, i.populateParam(nil, "ports"), "variable \"ports_len\" holding the number of values not found")
+}
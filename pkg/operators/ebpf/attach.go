@@ -16,14 +16,13 @@ package ebpfoperator
 
 import (
 	"fmt"
-	"net"
 	"strings"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/link"
 
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
-	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
 )
 
 const (
@@ -32,9 +31,6 @@ const (
 	iterPrefix      = "iter/"
 	fentryPrefix    = "fentry/"
 	fexitPrefix     = "fexit/"
-	uprobePrefix    = "uprobe/"
-	uretprobePrefix = "uretprobe/"
-	usdtPrefix      = "usdt/"
 )
 
 func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.ProgramSpec, prog *ebpf.Program) (link.Link, error) {
@@ -43,32 +39,31 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 		switch {
 		case strings.HasPrefix(p.SectionName, kprobePrefix):
 			i.logger.Debugf("Attaching kprobe %q to %q", p.Name, p.AttachTo)
-			return link.Kprobe(p.AttachTo, prog, nil)
+			return link.Kprobe(p.AttachTo, prog, &link.KprobeOptions{Cookie: i.programCookie(p.Name)})
 		case strings.HasPrefix(p.SectionName, kretprobePrefix):
 			i.logger.Debugf("Attaching kretprobe %q to %q", p.Name, p.AttachTo)
-			return link.Kretprobe(p.AttachTo, prog, nil)
-		case strings.HasPrefix(p.SectionName, uprobePrefix) ||
-			strings.HasPrefix(p.SectionName, uretprobePrefix) ||
-			strings.HasPrefix(p.SectionName, usdtPrefix):
-			uprobeTracer := i.uprobeTracers[p.Name]
-			switch strings.Split(p.SectionName, "/")[0] {
-			case "uprobe":
-				return nil, uprobeTracer.AttachProg(p.Name, uprobetracer.ProgUprobe, p.AttachTo, prog)
-			case "uretprobe":
-				return nil, uprobeTracer.AttachProg(p.Name, uprobetracer.ProgUretprobe, p.AttachTo, prog)
-			case "usdt":
-				return nil, uprobeTracer.AttachProg(p.Name, uprobetracer.ProgUSDT, p.AttachTo, prog)
-			}
+			return link.Kretprobe(p.AttachTo, prog, &link.KprobeOptions{Cookie: i.programCookie(p.Name)})
+		case i.uprobes[p.Name] != nil:
+			u := i.uprobes[p.Name]
+			i.logger.Debugf("Attaching uprobe %q to %q", p.Name, u.attachTo)
+			return nil, i.uprobeTracers[p.Name].AttachProg(p.Name, u.progType, u.attachTo, prog)
 		}
 		return nil, fmt.Errorf("unsupported section name %q for program %q", p.SectionName, p.Name)
 	case ebpf.TracePoint:
 		i.logger.Debugf("Attaching tracepoint %q to %q", p.Name, p.AttachTo)
 		parts := strings.Split(p.AttachTo, "/")
-		return link.Tracepoint(parts[0], parts[1], prog, nil)
+		return link.Tracepoint(parts[0], parts[1], prog, &link.TracepointOptions{Cookie: i.programCookie(p.Name)})
 	case ebpf.SocketFilter:
 		i.logger.Debugf("Attaching socket filter %q to %q", p.Name, p.AttachTo)
 		networkTracer := i.networkTracers[p.Name]
-		return nil, networkTracer.AttachProg(prog)
+		if err := networkTracer.AttachProg(prog); err != nil {
+			return nil, err
+		}
+		if i.netPrograms[p.Name].interfaces == metadatav1.AttachInterfacesAll {
+			i.logger.Debugf("Attaching socket filter %q to the host network namespace", p.Name)
+			return nil, networkTracer.Attach(1)
+		}
+		return nil, nil
 	case ebpf.Tracing:
 		switch {
 		case strings.HasPrefix(p.SectionName, iterPrefix):
@@ -85,12 +80,14 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 			return link.AttachTracing(link.TracingOptions{
 				Program:    prog,
 				AttachType: ebpf.AttachTraceFEntry,
+				Cookie:     i.programCookie(p.Name),
 			})
 		case strings.HasPrefix(p.SectionName, fexitPrefix):
 			i.logger.Debugf("Attaching fexit %q to %q", p.Name, p.AttachTo)
 			return link.AttachTracing(link.TracingOptions{
 				Program:    prog,
 				AttachType: ebpf.AttachTraceFExit,
+				Cookie:     i.programCookie(p.Name),
 			})
 		}
 		return nil, fmt.Errorf("unsupported section name %q for program %q as type ebpf.Tracing", p.SectionName, p.Name)
@@ -102,17 +99,8 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 		})
 	case ebpf.SchedCLS:
 		handler := i.tcHandlers[p.Name]
-
-		ifaceName := i.paramValues[ParamIface]
-		if ifaceName != "" {
-			iface, err := net.InterfaceByName(ifaceName)
-			if err != nil {
-				return nil, fmt.Errorf("getting interface %q: %w", ifaceName, err)
-			}
-
-			if err := handler.AttachIface(iface); err != nil {
-				return nil, fmt.Errorf("attaching iface %q: %w", ifaceName, err)
-			}
+		if err := i.attachHostIfaces(p.Name, i.netPrograms[p.Name], handler); err != nil {
+			return nil, err
 		}
 
 		i.logger.Debugf("Attaching sched_cls %q", p.Name)
@@ -121,6 +109,7 @@ func (i *ebpfInstance) attachProgram(gadgetCtx operators.GadgetContext, p *ebpf.
 		i.logger.Debugf("Attaching LSM %q to %q", p.Name, p.AttachTo)
 		return link.AttachLSM(link.LSMOptions{
 			Program: prog,
+			Cookie:  i.programCookie(p.Name),
 		})
 	default:
 		return nil, fmt.Errorf("unsupported program %q of type %q", p.Name, p.Type)
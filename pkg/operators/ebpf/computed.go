@@ -0,0 +1,134 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/expr"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+// defaultSourcePlaceholder is shown when the value of a computed field can't
+// be computed and the metadata doesn't set a placeholder
+const defaultSourcePlaceholder = "-"
+
+// initComputedFormatter adds the fields whose value is computed from an
+// expression over their sibling fields, see metadatav1.Field.Source. It runs
+// after the other formatters, so the raw values are already in place.
+func (i *ebpfInstance) initComputedFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Computed {
+			if err := i.addComputedField(ds, field); err != nil {
+				return fmt.Errorf("adding computed field %q to %q: %w", field.Name, ds.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (i *ebpfInstance) addComputedField(ds datasource.DataSource, field metadatav1.Field) error {
+	e, err := expr.Parse(field.Source)
+	if err != nil {
+		return err
+	}
+
+	accessors := make(map[string]datasource.FieldAccessor)
+	for _, name := range e.Fields() {
+		acc := ds.GetField(name)
+		if acc == nil {
+			return fmt.Errorf("field %q not found", name)
+		}
+		accessors[name] = acc
+	}
+
+	f := &Field{Field: field}
+	opts := []datasource.FieldOption{
+		datasource.WithAnnotations(f.FieldAnnotations()),
+		datasource.WithOrder(f.FieldOrder()),
+	}
+	if f.FieldHidden() {
+		opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+	}
+	out, err := ds.AddField(field.Name, api.Kind_String, opts...)
+	if err != nil {
+		return err
+	}
+
+	placeholder := field.SourcePlaceholder
+	if placeholder == "" {
+		placeholder = defaultSourcePlaceholder
+	}
+
+	i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+		v, err := e.Eval(func(name string) (any, error) {
+			return exprValue(accessors[name], data)
+		})
+		if err != nil {
+			// Divisions by zero, mostly
+			return out.PutString(data, placeholder)
+		}
+		return out.PutString(data, expr.Format(v))
+	})
+	return nil
+}
+
+// exprValue returns the value of the field accessed by acc as used in
+// expressions: numbers are float64
+func exprValue(acc datasource.FieldAccessor, data datasource.Data) (any, error) {
+	switch acc.Type() {
+	case api.Kind_Int8:
+		v, err := acc.Int8(data)
+		return float64(v), err
+	case api.Kind_Int16:
+		v, err := acc.Int16(data)
+		return float64(v), err
+	case api.Kind_Int32:
+		v, err := acc.Int32(data)
+		return float64(v), err
+	case api.Kind_Int64:
+		v, err := acc.Int64(data)
+		return float64(v), err
+	case api.Kind_Uint8:
+		v, err := acc.Uint8(data)
+		return float64(v), err
+	case api.Kind_Uint16:
+		v, err := acc.Uint16(data)
+		return float64(v), err
+	case api.Kind_Uint32:
+		v, err := acc.Uint32(data)
+		return float64(v), err
+	case api.Kind_Uint64:
+		v, err := acc.Uint64(data)
+		return float64(v), err
+	case api.Kind_Float32:
+		v, err := acc.Float32(data)
+		return float64(v), err
+	case api.Kind_Float64:
+		return acc.Float64(data)
+	case api.Kind_Bool:
+		return acc.Bool(data)
+	case api.Kind_String, api.Kind_CString:
+		return acc.String(data)
+	}
+	return nil, fmt.Errorf("field %q of kind %s can't be used in expressions", acc.Name(), acc.Type())
+}
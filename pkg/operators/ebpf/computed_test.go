@@ -0,0 +1,106 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestComputedFields(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		bytesOut uint64
+		duration uint32
+		dport    uint16
+		expected map[string]string
+	}
+
+	tests := map[string]testCase{
+		"values": {
+			bytesOut: 3000,
+			duration: 4,
+			dport:    53,
+			expected: map[string]string{"rate": "750", "proto": "dns", "rate_or_zero": "750"},
+		},
+		"division_by_zero": {
+			bytesOut: 3000,
+			dport:    80,
+			expected: map[string]string{"rate": "-", "proto": "other", "rate_or_zero": "n/a"},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := datasource.New(datasource.TypeSingle, "event")
+			require.NoError(t, err)
+			bytesOut, err := ds.AddField("bytes_out", api.Kind_Uint64)
+			require.NoError(t, err)
+			duration, err := ds.AddField("duration", api.Kind_Uint32)
+			require.NoError(t, err)
+			dport, err := ds.AddField("dport", api.Kind_Uint16)
+			require.NoError(t, err)
+
+			i := &ebpfInstance{
+				formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+			}
+			for _, field := range []metadatav1.Field{
+				{Name: "rate", Source: "bytes_out / duration"},
+				{Name: "proto", Source: `dport == 53 ? "dns" : "other"`},
+				{Name: "rate_or_zero", Source: "bytes_out / duration", SourcePlaceholder: "n/a"},
+			} {
+				require.NoError(t, i.addComputedField(ds, field))
+			}
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, bytesOut.PutUint64(data, test.bytesOut))
+			require.NoError(t, duration.PutUint32(data, test.duration))
+			require.NoError(t, dport.PutUint16(data, test.dport))
+
+			for _, formatter := range i.formatters[ds] {
+				require.NoError(t, formatter(ds, data))
+			}
+
+			for name, expected := range test.expected {
+				out, err := ds.GetField(name).String(data)
+				require.NoError(t, err)
+				require.Equal(t, expected, out, name)
+			}
+		})
+	}
+}
+
+func TestComputedFieldUnknownField(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	i := &ebpfInstance{
+		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+	}
+	err = i.addComputedField(ds, metadatav1.Field{Name: "rate", Source: "bytes_out / duration"})
+	require.ErrorContains(t, err, `field "bytes_out" not found`)
+}
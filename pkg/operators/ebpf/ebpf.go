@@ -19,12 +19,16 @@ package ebpfoperator
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
@@ -34,16 +38,18 @@ import (
 	"oras.land/oras-go/v2"
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/networktracer"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/oci"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
-	"github.com/inspektor-gadget/inspektor-gadget/pkg/socketenricher"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/tchandler"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
 )
@@ -55,6 +61,11 @@ const (
 
 	ParamIface       = "iface"
 	ParamTraceKernel = "trace-pipe"
+	ParamNoRedact    = "no-redact"
+
+	// ParamAllowEnforce must be set to run gadgets with enforcing programs in
+	// enforce mode
+	ParamAllowEnforce = "allow-enforce"
 
 	// Keep in sync with `include/gadget/kernel_stack_map.h`
 	KernelStackMapName       = "ig_kstack"
@@ -67,6 +78,27 @@ const (
 type param struct {
 	*api.Param
 	fromEbpf bool
+	// validator enforces the constraints of the param that api.Param can't carry
+	validator params.ParamValidator
+	// arrayLen is the size of the char array backing a string param
+	arrayLen uint32
+	// address is set for ipv4, ipv6 and cidr params
+	address *addressParam
+	// unit is set for duration and size params
+	unit *unitParam
+	// altKeys are other keys the param can be set with
+	altKeys []string
+	// targetMap is set for params whose values are inserted into a map
+	targetMap *targetMapParam
+	// enum is set for params backed by an enum, taking the names or numbers
+	// of its enumerators
+	enum *btf.Enum
+	// attaches are the programs only loaded when the param is true
+	attaches []string
+	// array is set for list params backed by an array of integers
+	array *arrayParam
+	// sensitive params have their values masked in logs and errors
+	sensitive bool
 }
 
 // ebpfOperator reads ebpf programs from OCI images and runs them
@@ -111,11 +143,16 @@ func (o *ebpfOperator) InstantiateImageOperator(
 		tracers:      make(map[string]*Tracer),
 		structs:      make(map[string]*Struct),
 		snapshotters: make(map[string]*Snapshotter),
+		toppers:      make(map[string]*Topper),
+		profilers:    make(map[string]*Profiler),
+		metrics:      make(map[string]*MapMetric),
 		params:       make(map[string]*param),
 
 		containers: make(map[string]*containercollection.Container),
 
-		enums:      make(map[string]*btf.Enum),
+		enums:      make(map[enumField]*btf.Enum),
+		bitfields:  make(map[string]bitfield),
+		pointers:   make(map[string]struct{}),
 		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
 
 		vars: make(map[string]*ebpfVar),
@@ -123,6 +160,14 @@ func (o *ebpfOperator) InstantiateImageOperator(
 		networkTracers: make(map[string]*networktracer.Tracer[api.GadgetData]),
 		tcHandlers:     make(map[string]*tchandler.Handler),
 		uprobeTracers:  make(map[string]*uprobetracer.Tracer[api.GadgetData]),
+		uprobes:        make(map[string]*uprobe),
+		netPrograms:    make(map[string]*netProgram),
+		netAttachments: newNetAttachments(gadgetCtx.Logger()),
+
+		optionalPrograms: make(map[string]struct{}),
+		skippedPrograms:  make(map[string]string),
+
+		enforcingPrograms: make(map[string]string),
 
 		paramValues: paramValues,
 	}
@@ -158,12 +203,18 @@ type ebpfInstance struct {
 	tracers      map[string]*Tracer
 	structs      map[string]*Struct
 	snapshotters map[string]*Snapshotter
+	toppers      map[string]*Topper
+	profilers    map[string]*Profiler
+	metrics      map[string]*MapMetric
 	params       map[string]*param
 	paramValues  map[string]string
 
 	networkTracers map[string]*networktracer.Tracer[api.GadgetData]
 	tcHandlers     map[string]*tchandler.Handler
 	uprobeTracers  map[string]*uprobetracer.Tracer[api.GadgetData]
+	uprobes        map[string]*uprobe
+	netPrograms    map[string]*netProgram
+	netAttachments *netAttachments
 
 	// map from ebpf variable name to ebpfVar struct
 	vars map[string]*ebpfVar
@@ -172,11 +223,38 @@ type ebpfInstance struct {
 
 	containers map[string]*containercollection.Container
 
-	enums      map[string]*btf.Enum
+	enums      map[enumField]*btf.Enum
+	bitfields  map[string]bitfield
+	pointers   map[string]struct{}
 	formatters map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error
 
 	stackIdMap *ebpf.Map
 
+	// mntnsFilterMap is the map filtering events by mount namespace, see
+	// initMntNsFilter
+	mntnsFilterMap string
+
+	// provenance information of the programs, by program name
+	programs map[string]gadgets.ProgramInfo
+	// programCookies is set when events need the program id passed as BPF cookie
+	programCookies bool
+
+	// optionalPrograms are the programs skipped instead of failing to run
+	// the gadget, see gatePrograms
+	optionalPrograms map[string]struct{}
+	// skippedPrograms holds why each skipped optional program was skipped
+	skippedPrograms map[string]string
+
+	// enforcingPrograms holds the key of the param switching each enforcing
+	// program to enforce mode, see initEnforcing
+	enforcingPrograms map[string]string
+
+	// hasRedactedFields is set when fields have the redact attribute, see
+	// initRedactFormatter
+	hasRedactedFields bool
+	// redactionDisabled shows redacted fields as captured, for debugging
+	redactionDisabled atomic.Bool
+
 	gadgetCtx operators.GadgetContext
 }
 
@@ -202,6 +280,21 @@ func (i *ebpfInstance) analyze() error {
 			validator:    i.validateGlobalConstVoidPtrVar,
 			populateFunc: i.populateSnapshotter,
 		},
+		{
+			prefixFunc:   hasPrefix(mapSnapshotterPrefix),
+			validator:    i.validateGlobalConstVoidPtrVar,
+			populateFunc: i.populateMapSnapshotter,
+		},
+		{
+			prefixFunc:   hasPrefix(topperInfoPrefix),
+			validator:    i.validateGlobalConstVoidPtrVar,
+			populateFunc: i.populateTopper,
+		},
+		{
+			prefixFunc:   hasPrefix(profilerInfoPrefix),
+			validator:    i.validateGlobalConstVoidPtrVar,
+			populateFunc: i.populateProfiler,
+		},
 		{
 			prefixFunc:   hasPrefix(paramPrefix),
 			validator:    i.validateGlobalConstVoidPtrVar,
@@ -215,16 +308,22 @@ func (i *ebpfInstance) analyze() error {
 		{
 			prefixFunc: func(s string) (string, bool) {
 				// Exceptions for backwards-compatibility
-				if s == gadgets.MntNsFilterMapName {
+				if s == i.mntnsFilterMap {
 					return gadgets.MntNsFilterMapName, true
 				}
-				if s == socketenricher.SocketsMapName {
-					return socketenricher.SocketsMapName, true
-				}
 				return "", false
 			},
 			populateFunc: i.populateMap,
 		},
+		{
+			prefixFunc: func(s string) (string, bool) {
+				if sharedmaps.Get(s) != nil {
+					return s, true
+				}
+				return "", false
+			},
+			populateFunc: i.populateExternalMap,
+		},
 		{
 			prefixFunc: func(s string) (string, bool) {
 				// Exceptions for backwards-compatibility
@@ -301,10 +400,34 @@ func (i *ebpfInstance) init(gadgetCtx operators.GadgetContext) error {
 	if err != nil {
 		return fmt.Errorf("initializing: %w", err)
 	}
+	err = i.initMntNsFilter()
+	if err != nil {
+		return fmt.Errorf("initializing mount namespace filter: %w", err)
+	}
 	err = i.analyze()
 	if err != nil {
 		return fmt.Errorf("analyzing: %w", err)
 	}
+	err = i.checkExternalMaps()
+	if err != nil {
+		return fmt.Errorf("checking external maps: %w", err)
+	}
+	err = i.populateMetrics()
+	if err != nil {
+		return fmt.Errorf("populating metrics: %w", err)
+	}
+	err = i.selectTracer()
+	if err != nil {
+		return fmt.Errorf("selecting tracer: %w", err)
+	}
+	err = i.gatePrograms()
+	if err != nil {
+		return fmt.Errorf("gating optional programs: %w", err)
+	}
+	err = i.initEnforcing()
+	if err != nil {
+		return fmt.Errorf("initializing enforcing programs: %w", err)
+	}
 
 	err = i.register(gadgetCtx)
 	if err != nil {
@@ -350,18 +473,104 @@ func (i *ebpfInstance) register(gadgetCtx operators.GadgetContext) error {
 		if err != nil {
 			return fmt.Errorf("adding datasource: %w", err)
 		}
+		if len(m.DefaultColumns) > 0 {
+			ds.AddAnnotation(datasource.DefaultColumnsAnnotation, strings.Join(m.DefaultColumns, ","))
+		}
 		m.accessor = accessor
 		m.ds = ds
+		if m.Aggregation != nil {
+			m.aggregator, err = newAggregator(ds, accessor, *m.Aggregation, i.structs[m.StructName].Fields)
+			if err != nil {
+				return fmt.Errorf("tracer %q: aggregation: %w", name, err)
+			}
+		}
 	}
 	for name, m := range i.snapshotters {
+		size := i.structs[m.StructName].Size
+		fields := i.structs[m.StructName].Fields
+		if m.Source == metadatav1.SnapshotterSourceMap {
+			var keyFields []*Field
+			if m.KeyStructName != "" {
+				keyFields = i.structs[m.KeyStructName].Fields
+			}
+			var err error
+			fields, err = m.entryFields(keyFields, fields)
+			if err != nil {
+				return fmt.Errorf("snapshotter %q: %w", name, err)
+			}
+			size += m.keySize
+			m.counters = counterFields(i.structs[m.StructName].Fields)
+		}
+		ds, accessor, err := i.addDataSource(gadgetCtx, datasource.TypeArray, name, size, fields)
+		if err != nil {
+			return fmt.Errorf("adding datasource: %w", err)
+		}
+		if len(m.DefaultColumns) > 0 {
+			ds.AddAnnotation(datasource.DefaultColumnsAnnotation, strings.Join(m.DefaultColumns, ","))
+		}
+
+		m.accessor = accessor
+		m.ds = ds
+
+		mode := m.Mode
+		if paramMode := i.paramValues[ParamSnapshotMode]; paramMode != "" {
+			mode = metadatav1.SnapshotterMode(paramMode)
+		}
+		if err := m.initMode(mode, fields); err != nil {
+			return fmt.Errorf("snapshotter %q: %w", name, err)
+		}
+		// Columns output redraws the snapshots taken periodically
+		interval := m.Interval
+		if paramInterval := i.paramValues[ParamInterval]; paramInterval != "" {
+			interval = paramInterval
+		}
+		if d, err := time.ParseDuration(interval); err == nil && d > 0 && mode != metadatav1.SnapshotterModeDiff {
+			ds.AddAnnotation(datasource.RefreshAnnotation, "true")
+		}
+	}
+	for name, m := range i.toppers {
 		ds, accessor, err := i.addDataSource(gadgetCtx, datasource.TypeArray, name, i.structs[m.StructName].Size, i.structs[m.StructName].Fields)
 		if err != nil {
 			return fmt.Errorf("adding datasource: %w", err)
 		}
+		if err := m.initFields(i.structs[m.StructName].Fields); err != nil {
+			return fmt.Errorf("topper %q: %w", name, err)
+		}
 
 		m.accessor = accessor
 		m.ds = ds
 	}
+	for name, m := range i.profilers {
+		if m.KeyStructName != "" {
+			ds, accessor, err := i.addDataSource(gadgetCtx, datasource.TypeArray, name, i.structs[m.KeyStructName].Size, i.structs[m.KeyStructName].Fields)
+			if err != nil {
+				return fmt.Errorf("adding datasource: %w", err)
+			}
+			m.accessor = accessor
+			m.ds = ds
+		} else {
+			ds, err := gadgetCtx.RegisterDataSource(datasource.TypeArray, name)
+			if err != nil {
+				return fmt.Errorf("adding profiler datasource: %w", err)
+			}
+			m.ds = ds
+		}
+		if err := m.addHistogramFields(); err != nil {
+			return fmt.Errorf("profiler %q: %w", name, err)
+		}
+	}
+	for name, m := range i.metrics {
+		keyFields := i.structs[m.keyStructName].Fields
+		ds, accessor, err := i.addDataSource(gadgetCtx, datasource.TypeArray, name, i.structs[m.keyStructName].Size, keyFields)
+		if err != nil {
+			return fmt.Errorf("adding datasource: %w", err)
+		}
+		m.accessor = accessor
+		m.ds = ds
+		if err := m.addFields(keyFields); err != nil {
+			return fmt.Errorf("metric %q: %w", name, err)
+		}
+	}
 	return nil
 }
 
@@ -373,6 +582,7 @@ func (i *ebpfInstance) ExtraParams(gadgetCtx operators.GadgetContext) api.Params
 	res := make(api.Params, 0, len(i.params))
 	for _, p := range i.params {
 		res = append(res, p.Param)
+		res = append(res, p.altKeyParams()...)
 	}
 	return res
 }
@@ -389,12 +599,27 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 
 	// Create network tracers, one for each socket filter program
 	// The same applies to uprobe / uretprobe as well.
+	uprobeTargets, defaultUprobeTarget, err := parseUprobeTargets(i.paramValues[ParamUprobeTarget], i.collectionSpec.Programs)
+	if err != nil {
+		return err
+	}
+
 	for _, p := range i.collectionSpec.Programs {
 		switch p.Type {
 		case ebpf.Kprobe:
-			if strings.HasPrefix(p.SectionName, "uprobe/") ||
-				strings.HasPrefix(p.SectionName, "uretprobe/") ||
-				strings.HasPrefix(p.SectionName, "usdt/") {
+			if progType, ok := uprobeProgType(p); ok {
+				u, err := i.resolveUprobe(p, progType, uprobeTargets, defaultUprobeTarget)
+				if err != nil {
+					i.Close()
+					return err
+				}
+				// Templates are resolved when attaching to each container,
+				// check them before anything starts
+				if err := containertemplate.Validate(u.attachTo); err != nil {
+					i.Close()
+					return fmt.Errorf("program %q: %w", p.Name, err)
+				}
+				i.uprobes[p.Name] = u
 				uprobeTracer, err := uprobetracer.NewTracer[api.GadgetData](gadgetCtx.Logger())
 				if err != nil {
 					i.Close()
@@ -404,40 +629,44 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 			}
 		case ebpf.SocketFilter:
 			if strings.HasPrefix(p.SectionName, "socket") {
+				np, err := i.resolveNetProgram(p)
+				if err != nil {
+					i.Close()
+					return err
+				}
+				if np.interfaces == metadatav1.AttachInterfacesParam {
+					i.Close()
+					return fmt.Errorf("program %q: socket filters don't support interfaces %q", p.Name, np.interfaces)
+				}
+				i.netPrograms[p.Name] = np
 				networkTracer, err := networktracer.NewTracer[api.GadgetData]()
 				if err != nil {
 					i.Close()
 					return fmt.Errorf("creating network tracer: %w", err)
 				}
 				i.networkTracers[p.Name] = networkTracer
+				if np.interfaces == metadatav1.AttachInterfacesPod {
+					i.netAttachments.add(p.Name, networkTracer)
+				}
 			}
 		case ebpf.SchedCLS:
-			parts := strings.Split(p.SectionName, "/")
-			if len(parts) != 3 {
-				return fmt.Errorf("invalid section name %q", p.SectionName)
-			}
-			if parts[0] != "classifier" {
-				return fmt.Errorf("invalid section name %q", p.SectionName)
-			}
-
-			var direction tchandler.AttachmentDirection
-
-			switch parts[1] {
-			case "ingress":
-				direction = tchandler.AttachmentDirectionIngress
-			case "egress":
-				direction = tchandler.AttachmentDirectionEgress
-			default:
-				return fmt.Errorf("unsupported hook type %q", parts[1])
+			np, err := i.resolveNetProgram(p)
+			if err != nil {
+				i.Close()
+				return err
 			}
+			i.netPrograms[p.Name] = np
 
-			handler, err := tchandler.NewHandler(direction)
+			handler, err := tchandler.NewHandler(np.direction)
 			if err != nil {
 				i.Close()
 				return fmt.Errorf("creating tc network tracer: %w", err)
 			}
 
 			i.tcHandlers[p.Name] = handler
+			if np.interfaces == metadatav1.AttachInterfacesPod {
+				i.netAttachments.add(p.Name, handler)
+			}
 		}
 	}
 
@@ -452,6 +681,10 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 		}
 	}
 
+	if len(i.uprobes) > 0 {
+		i.addUprobeTargetParam()
+	}
+
 	i.params[ParamTraceKernel] = &param{
 		Param: &api.Param{
 			Key:          ParamTraceKernel,
@@ -459,9 +692,118 @@ func (i *ebpfInstance) Prepare(gadgetCtx operators.GadgetContext) error {
 			TypeHint:     api.TypeBool,
 		},
 	}
+
+	if len(i.enforcingPrograms) > 0 {
+		i.params[ParamAllowEnforce] = &param{
+			Param: &api.Param{
+				Key:          ParamAllowEnforce,
+				Description:  "Allow the gadget to deny operations when it runs in enforce mode",
+				DefaultValue: "false",
+				TypeHint:     api.TypeBool,
+			},
+		}
+	}
+
+	if i.hasRedactedFields {
+		i.params[ParamNoRedact] = &param{
+			Param: &api.Param{
+				Key:          ParamNoRedact,
+				Description:  "Show the values of sensitive fields instead of redacting them. Only use it for debugging",
+				DefaultValue: "false",
+				TypeHint:     api.TypeBool,
+			},
+		}
+	}
+
+	for _, topper := range i.toppers {
+		interval := topper.Interval
+		if interval == "" {
+			interval = defaultTopperInterval
+		}
+		i.addIntervalParams(interval, "Interval to read and report the top entries at",
+			"Maximum number of entries to report on each interval, 0 to report all of them")
+	}
+
+	for _, profiler := range i.profilers {
+		interval := profiler.Interval
+		if interval == "" {
+			interval = defaultProfilerInterval
+		}
+		i.addIntervalParams(interval, "Interval to report the histograms at",
+			"Maximum number of histograms to report on each interval, the ones with the most values first. 0 to report all of them")
+	}
+
+	for _, snapshotter := range i.snapshotters {
+		i.addSnapshotterParams(snapshotter)
+	}
+
+	if _, ok := i.params[ParamInterval]; !ok && len(i.metrics) > 0 {
+		i.params[ParamInterval] = &param{
+			Param: &api.Param{
+				Key:          ParamInterval,
+				Description:  "Interval to read the maps of the metrics at",
+				DefaultValue: defaultMetricsInterval,
+				TypeHint:     api.TypeDuration,
+			},
+		}
+	}
 	return nil
 }
 
+// addSnapshotterParams adds the params of snapshotters to take the snapshots
+// periodically. They are only taken once by default.
+func (i *ebpfInstance) addSnapshotterParams(snapshotter *Snapshotter) {
+	if _, ok := i.params[ParamInterval]; !ok {
+		interval := snapshotter.Interval
+		if interval == "" {
+			interval = "0s"
+		}
+		i.params[ParamInterval] = &param{
+			Param: &api.Param{
+				Key:          ParamInterval,
+				Description:  "Interval to take the snapshot again at, 0 to take it only once",
+				DefaultValue: interval,
+				TypeHint:     api.TypeDuration,
+			},
+		}
+	}
+
+	mode := snapshotter.Mode
+	if mode == "" {
+		mode = metadatav1.SnapshotterModeReplace
+	}
+	i.params[ParamSnapshotMode] = &param{
+		Param: &api.Param{
+			Key: ParamSnapshotMode,
+			Description: "How snapshots taken periodically are reported: replace reports all the entries, " +
+				"diff only the ones added, removed or changed since the previous snapshot",
+			DefaultValue:   string(mode),
+			PossibleValues: []string{string(metadatav1.SnapshotterModeReplace), string(metadatav1.SnapshotterModeDiff)},
+		},
+	}
+}
+
+// addIntervalParams adds the params of the gadgets reading their maps
+// periodically: toppers and profilers
+func (i *ebpfInstance) addIntervalParams(defaultInterval, intervalDesc, maxRowsDesc string) {
+	i.params[ParamInterval] = &param{
+		Param: &api.Param{
+			Key:          ParamInterval,
+			Description:  intervalDesc,
+			DefaultValue: defaultInterval,
+			TypeHint:     api.TypeDuration,
+		},
+	}
+	i.params[ParamMaxRows] = &param{
+		Param: &api.Param{
+			Key:          ParamMaxRows,
+			Description:  maxRowsDesc,
+			DefaultValue: strconv.Itoa(defaultMaxRows),
+			TypeHint:     api.TypeInt,
+		},
+	}
+}
+
 func (i *ebpfInstance) tracePipe(gadgetCtx operators.GadgetContext) error {
 	tracePipe, err := os.Open("/sys/kernel/debug/tracing/trace_pipe")
 	if err != nil {
@@ -483,6 +825,62 @@ func (i *ebpfInstance) tracePipe(gadgetCtx operators.GadgetContext) error {
 	return nil
 }
 
+// rewriteParam sets the constants, or the keys of the LPM and target maps,
+// backing the param p to its value. Values of sensitive params are masked in
+// logs and errors.
+func (i *ebpfInstance) rewriteParam(name string, p *param, value *params.Param, consts map[string]any,
+	lpmKeys, targetMapKeys map[string][][]byte,
+) error {
+	var err error
+	switch {
+	case p.address != nil:
+		var key []byte
+		key, err = p.address.rewrite(value.String(), consts)
+		if key != nil {
+			lpmKeys[p.address.lpmMap] = append(lpmKeys[p.address.lpmMap], key)
+		}
+	case p.targetMap != nil:
+		var keys [][]byte
+		keys, err = p.targetMap.rewrite(value.String(), consts)
+		if err == nil {
+			// Filled even without keys, to clear it
+			targetMapKeys[p.targetMap.TargetMap] = append(targetMapKeys[p.targetMap.TargetMap], keys...)
+		}
+	case p.unit != nil:
+		if v := value.String(); v != "" {
+			var b []byte
+			b, err = p.unit.encode(v)
+			if err == nil {
+				consts[name] = b
+			}
+		}
+	case p.array != nil:
+		err = p.array.rewrite(name, value.String(), consts)
+	case p.enum != nil:
+		var b []byte
+		b, err = encodeEnum(p.enum, value.String())
+		if err == nil {
+			consts[name] = b
+		}
+	case p.arrayLen > 0:
+		consts[name] = charArray(value.AsString(), p.arrayLen)
+	case p.TypeHint == api.TypeBool:
+		consts[name] = boolByte(value.AsBool())
+	default:
+		consts[name] = value.AsAny()
+	}
+	if err != nil {
+		return fmt.Errorf("param %q: %w", p.Key, params.MaskError(err, value.String(), p.sensitive))
+	}
+
+	if p.sensitive {
+		i.logger.Debugf("setting param value %q = %s", name, params.MaskedValue)
+	} else {
+		i.logger.Debugf("setting param value %q = %v", name, value.AsAny())
+	}
+	return nil
+}
+
 func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 	i.logger.Debugf("starting ebpfInstance")
 
@@ -491,14 +889,32 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 	parameters := params.Params{}              // used to CopyFromMap
 	paramMap := make(map[string]*params.Param) // used for second iteration
 	for name, p := range i.params {
-		param := apihelpers.ParamToParamDesc(p.Param).ToParam()
+		desc := apihelpers.ParamToParamDesc(p.Param)
+		desc.Validator = p.validator
+		param := desc.ToParam()
 		paramMap[name] = param
 		parameters = append(parameters, param)
 	}
-	err := parameters.CopyFromMap(i.paramValues, "")
+	paramValues, err := resolveAltKeys(i.params, i.paramValues)
+	if err != nil {
+		return fmt.Errorf("parsing parameter values: %w", err)
+	}
+	if err := checkParamTemplates(i.params, paramValues); err != nil {
+		return err
+	}
+	if err := parseBoolParams(i.params, paramValues); err != nil {
+		return fmt.Errorf("parsing parameter values: %w", err)
+	}
+	err = parameters.CopyFromMap(paramValues, "")
 	if err != nil {
 		return fmt.Errorf("parsing parameter values: %w", err)
 	}
+	if err := checkMandatoryParams(i.params, paramMap); err != nil {
+		return err
+	}
+	if err := parameters.CheckDependencies(); err != nil {
+		return err
+	}
 
 	if paramMap[ParamTraceKernel].AsBool() {
 		err := i.tracePipe(gadgetCtx)
@@ -507,6 +923,17 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 		}
 	}
 
+	if p, ok := paramMap[ParamNoRedact]; ok && p.AsBool() {
+		gadgetCtx.Logger().Warnf("Redaction is disabled: sensitive fields are shown as captured")
+		i.redactionDisabled.Store(true)
+	}
+
+	if len(i.enforcingPrograms) > 0 {
+		if err := i.checkEnforcing(paramMap[ParamAllowEnforce].AsBool()); err != nil {
+			return err
+		}
+	}
+
 	mapReplacements := make(map[string]*ebpf.Map)
 	constReplacements := make(map[string]any)
 
@@ -516,12 +943,15 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 	}
 
 	// Set gadget params
+	lpmKeys := make(map[string][][]byte)
+	targetMapKeys := make(map[string][][]byte)
 	for name, p := range i.params {
 		if !p.fromEbpf {
 			continue
 		}
-		constReplacements[name] = paramMap[name].AsAny()
-		i.logger.Debugf("setting param value %q = %v", name, paramMap[name].AsAny())
+		if err := i.rewriteParam(name, p, paramMap[name], constReplacements, lpmKeys, targetMapKeys); err != nil {
+			return err
+		}
 	}
 
 	for _, v := range i.vars {
@@ -546,7 +976,11 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 			constReplacements[v.name] = res
 		}
 	}
+	i.renameMntNsFilterMap(mapReplacements)
 
+	if err := i.checkConstants(constReplacements); err != nil {
+		return err
+	}
 	if err := i.collectionSpec.RewriteConstants(constReplacements); err != nil {
 		return fmt.Errorf("rewriting constants: %w", err)
 	}
@@ -566,11 +1000,41 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 		opts.Programs.KernelTypes = btfSpec
 	}
 	collection, err := ebpf.NewCollectionWithOptions(i.collectionSpec, opts)
-	if err != nil {
-		return fmt.Errorf("creating eBPF collection: %w", err)
+	for err != nil {
+		name, ok := i.failedOptionalProgram(err)
+		if !ok {
+			return fmt.Errorf("creating eBPF collection: %w", err)
+		}
+		i.logger.Debugf("Skipping optional program %q: %v", name, err)
+		i.skipProgram(name, fmt.Sprintf("loading: %v", err))
+		collection, err = ebpf.NewCollectionWithOptions(i.collectionSpec, opts)
 	}
 	i.collection = collection
 
+	for mapName, keys := range lpmKeys {
+		lpmMap, ok := i.collection.Maps[mapName]
+		if !ok {
+			i.Close()
+			return fmt.Errorf("LPM trie map %q not found", mapName)
+		}
+		if err := insertLPMKeys(lpmMap, keys); err != nil {
+			i.Close()
+			return fmt.Errorf("inserting networks into map %q: %w", mapName, err)
+		}
+	}
+
+	for mapName, keys := range targetMapKeys {
+		targetMap, ok := i.collection.Maps[mapName]
+		if !ok {
+			i.Close()
+			return fmt.Errorf("target map %q not found", mapName)
+		}
+		if err := fillTargetMap(targetMap, keys); err != nil {
+			i.Close()
+			return fmt.Errorf("filling map %q: %w", mapName, err)
+		}
+	}
+
 	for _, tracer := range i.tracers {
 		i.logger.Debugf("starting tracer %q", tracer.MapName)
 		go func(tracer *Tracer) {
@@ -584,6 +1048,16 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 	// Attach programs
 	for progName, p := range i.collectionSpec.Programs {
 		l, err := i.attachProgram(gadgetCtx, p, i.collection.Programs[progName])
+		if err != nil && errors.Is(err, link.ErrNotSupported) && i.programCookie(progName) != 0 {
+			i.logger.Warnf("BPF cookies not supported, program provenance won't be available: %v", err)
+			i.programCookies = false
+			l, err = i.attachProgram(gadgetCtx, p, i.collection.Programs[progName])
+		}
+		if _, ok := i.optionalPrograms[progName]; ok && err != nil {
+			i.logger.Debugf("Skipping optional program %q: %v", progName, err)
+			i.skippedPrograms[progName] = fmt.Sprintf("attaching: %v", err)
+			continue
+		}
 		if err != nil {
 			i.Close()
 			return fmt.Errorf("attaching eBPF program %q: %w", progName, err)
@@ -618,12 +1092,51 @@ func (i *ebpfInstance) Start(gadgetCtx operators.GadgetContext) error {
 		}
 	}
 
+	i.reportSkippedPrograms()
+
+	for name, topper := range i.toppers {
+		interval := paramMap[ParamInterval].AsDuration()
+		if interval <= 0 {
+			i.Close()
+			return fmt.Errorf("invalid interval %s for topper %q", interval, name)
+		}
+		i.logger.Debugf("starting topper %q", name)
+		go i.runTopper(gadgetCtx, name, topper, interval, paramMap[ParamMaxRows].AsInt())
+	}
+
+	for name, profiler := range i.profilers {
+		interval := paramMap[ParamInterval].AsDuration()
+		if interval <= 0 {
+			i.Close()
+			return fmt.Errorf("invalid interval %s for profiler %q", interval, name)
+		}
+		i.logger.Debugf("starting profiler %q", name)
+		go i.runProfiler(gadgetCtx, name, profiler, interval, paramMap[ParamMaxRows].AsInt())
+	}
+
+	for name, metric := range i.metrics {
+		interval := paramMap[ParamInterval].AsDuration()
+		if interval <= 0 {
+			i.Close()
+			return fmt.Errorf("invalid interval %s for metric %q", interval, name)
+		}
+		i.logger.Debugf("starting metric %q", name)
+		go i.runMapMetric(gadgetCtx, name, metric, interval)
+	}
+
 	err = i.runSnapshotters()
 	if err != nil {
 		i.Close()
 		return fmt.Errorf("running snapshotters: %w", err)
 	}
 
+	if len(i.snapshotters) > 0 {
+		if interval := paramMap[ParamInterval].AsDuration(); interval > 0 {
+			i.logger.Debugf("taking snapshots every %s", interval)
+			go i.runSnapshottersPeriodically(gadgetCtx, interval)
+		}
+	}
+
 	return nil
 }
 
@@ -642,6 +1155,7 @@ func (i *ebpfInstance) Close() {
 	}
 	i.links = nil
 
+	i.netAttachments.detachAll()
 	for _, networkTracer := range i.networkTracers {
 		networkTracer.Close()
 	}
@@ -660,19 +1174,7 @@ func (i *ebpfInstance) AttachContainer(container *containercollection.Container)
 	i.containers[container.Runtime.ContainerID] = container
 	i.mu.Unlock()
 
-	for _, networkTracer := range i.networkTracers {
-		if err := networkTracer.Attach(container.Pid); err != nil {
-			return err
-		}
-	}
-
-	if ifaceName := i.paramValues[ParamIface]; ifaceName == "" {
-		for _, handler := range i.tcHandlers {
-			if err := handler.AttachContainer(container); err != nil {
-				return err
-			}
-		}
-	}
+	i.netAttachments.attach(container)
 
 	for _, handler := range i.uprobeTracers {
 		if err := handler.AttachContainer(container); err != nil {
@@ -688,19 +1190,7 @@ func (i *ebpfInstance) DetachContainer(container *containercollection.Container)
 	delete(i.containers, container.Runtime.ContainerID)
 	i.mu.Unlock()
 
-	for _, networkTracer := range i.networkTracers {
-		if err := networkTracer.Detach(container.Pid); err != nil {
-			return err
-		}
-	}
-
-	if ifaceName := i.paramValues[ParamIface]; ifaceName == "" {
-		for _, handler := range i.tcHandlers {
-			if err := handler.DetachContainer(container); err != nil {
-				return err
-			}
-		}
-	}
+	i.netAttachments.detach(container)
 
 	for _, uTracer := range i.uprobeTracers {
 		if err := uTracer.DetachContainer(container); err != nil {
@@ -0,0 +1,76 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// initEnforcing looks up the LSM programs that can deny operations, along
+// with the param switching them to enforce mode
+func (i *ebpfInstance) initEnforcing() error {
+	for _, name := range sortedKeys(i.collectionSpec.Programs) {
+		programConfig := i.config.Sub("programs." + name)
+		if programConfig == nil {
+			continue
+		}
+		var program metadatav1.Program
+		if err := programConfig.Unmarshal(&program); err != nil {
+			return fmt.Errorf("program %q: unmarshalling metadata: %w", name, err)
+		}
+		if !program.Enforcing {
+			continue
+		}
+		if program.EnforceParam == "" {
+			return fmt.Errorf("program %q: enforcing programs require enforceParam", name)
+		}
+		i.enforcingPrograms[name] = program.EnforceParam
+	}
+	return nil
+}
+
+// checkEnforcing refuses to run the gadget in enforce mode, where it denies
+// operations, unless the user explicitly allowed it
+func (i *ebpfInstance) checkEnforcing(allowed bool) error {
+	var enforcing []string
+	for _, name := range sortedKeys(i.enforcingPrograms) {
+		key := i.enforcingPrograms[name]
+		value, ok := i.paramValue(key)
+		if !ok {
+			return fmt.Errorf("program %q: enforce param %q not found", name, key)
+		}
+		enforce, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("program %q: enforce param %q: %w", name, key, err)
+		}
+		if enforce {
+			enforcing = append(enforcing, fmt.Sprintf("%s (--%s)", name, key))
+		}
+	}
+	if len(enforcing) == 0 {
+		return nil
+	}
+
+	if !allowed {
+		return fmt.Errorf("programs %s would deny operations on the node: set --%s to confirm or run them in audit mode",
+			strings.Join(enforcing, ", "), ParamAllowEnforce)
+	}
+	i.logger.Warnf("Enforce mode enabled: programs %s deny operations on the node", strings.Join(enforcing, ", "))
+	return nil
+}
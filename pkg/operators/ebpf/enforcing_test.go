@@ -0,0 +1,109 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+func TestEnforcing(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		enforce           string
+		allowed           bool
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"audit_mode": {},
+		"audit_mode_allowed": {
+			enforce: "false",
+			allowed: true,
+		},
+		"enforce_mode_not_allowed": {
+			enforce:           "true",
+			expectedErrString: "programs check_exec (--enforce) would deny operations on the node: set --allow-enforce to confirm",
+		},
+		"enforce_mode_allowed": {
+			enforce: "true",
+			allowed: true,
+		},
+		"enforce_mode_with_capitalized_true": {
+			enforce:           "TRUE",
+			expectedErrString: "programs check_exec (--enforce) would deny operations on the node",
+		},
+		"invalid_value": {
+			enforce:           "maybe",
+			allowed:           true,
+			expectedErrString: "program \"check_exec\": enforce param \"enforce\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+programs:
+  check_exec:
+    enforcing: true
+    enforceParam: enforce
+  other: {}
+`)))
+
+			paramValues := map[string]string{}
+			if test.enforce != "" {
+				paramValues["enforce"] = test.enforce
+			}
+			i := &ebpfInstance{
+				config: config,
+				logger: logger.DefaultLogger(),
+				collectionSpec: &ebpf.CollectionSpec{
+					Programs: map[string]*ebpf.ProgramSpec{
+						"check_exec": {Name: "check_exec", Type: ebpf.LSM},
+						"other":      {Name: "other", Type: ebpf.LSM},
+					},
+				},
+				params: map[string]*param{
+					"gadget_enforce": {Param: &api.Param{Key: "enforce", DefaultValue: "false"}},
+				},
+				paramValues:       paramValues,
+				enforcingPrograms: make(map[string]string),
+			}
+
+			require.NoError(t, i.initEnforcing())
+			require.Equal(t, map[string]string{"check_exec": "enforce"}, i.enforcingPrograms)
+
+			err := i.checkEnforcing(test.allowed)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+)
+
+// encodeEnum returns the number of the enumerator of enum named value or whose
+// number is value, sized for the variable
+func encodeEnum(enum *btf.Enum, value string) ([]byte, error) {
+	n, ok := btfhelpers.EnumValue(enum, value)
+	if !ok {
+		return nil, fmt.Errorf("%q isn't a value of enum %s", value, enum.Name)
+	}
+	return nativeUint(n, int(enum.Size))
+}
+
+// decodeEnum returns the name of the enumerator whose number is in b, or the
+// number if there is none
+func decodeEnum(enum *btf.Enum, b []byte) string {
+	var n uint64
+	switch len(b) {
+	case 1:
+		n = uint64(b[0])
+		if enum.Signed {
+			n = uint64(int8(b[0]))
+		}
+	case 2:
+		v := binary.NativeEndian.Uint16(b)
+		n = uint64(v)
+		if enum.Signed {
+			n = uint64(int16(v))
+		}
+	case 4:
+		v := binary.NativeEndian.Uint32(b)
+		n = uint64(v)
+		if enum.Signed {
+			n = uint64(int32(v))
+		}
+	case 8:
+		n = binary.NativeEndian.Uint64(b)
+	default:
+		return ""
+	}
+
+	for _, v := range enum.Values {
+		if v.Value == n {
+			return v.Name
+		}
+	}
+	return btfhelpers.FormatEnumValue(enum, n)
+}
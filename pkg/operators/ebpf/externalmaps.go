@@ -0,0 +1,71 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
+)
+
+// checkExternalMaps makes sure the providers of the maps declared in
+// externalMaps are available. Without them, the gadget would read its own
+// empty copy of the map and silently emit empty data. The fields read from
+// optional maps whose provider is missing are hidden instead.
+func (i *ebpfInstance) checkExternalMaps() error {
+	externalMaps := i.config.GetStringMap("externalMaps")
+	for _, name := range sortedKeys(externalMaps) {
+		var externalMap metadatav1.ExternalMap
+		if err := i.config.Sub("externalMaps." + name).Unmarshal(&externalMap); err != nil {
+			return fmt.Errorf("external map %q: unmarshalling metadata: %w", name, err)
+		}
+
+		provider := sharedmaps.Get(name)
+		if provider == nil {
+			if !externalMap.Optional {
+				return fmt.Errorf("gadget needs %s, which isn't available: enable it or mark the map as optional",
+					describeExternalMap(name, externalMap))
+			}
+			i.logger.Warnf("%s isn't available, hiding the fields read from it", describeExternalMap(name, externalMap))
+			i.hideExternalMapFields(name)
+			continue
+		}
+
+		if externalMap.Provider != "" && externalMap.Provider != provider.ProviderName() {
+			return fmt.Errorf("gadget expects map %q to be provided by %q, but it's provided by %q",
+				name, externalMap.Provider, provider.ProviderName())
+		}
+	}
+	return nil
+}
+
+func describeExternalMap(name string, externalMap metadatav1.ExternalMap) string {
+	if externalMap.Provider == "" {
+		return fmt.Sprintf("map %q", name)
+	}
+	return fmt.Sprintf("map %q provided by %q", name, externalMap.Provider)
+}
+
+// hideExternalMapFields hides the fields read from the given map
+func (i *ebpfInstance) hideExternalMapFields(mapName string) {
+	for _, s := range i.structs {
+		for _, field := range s.Fields {
+			if field.ExternalMap == mapName {
+				field.Attributes.Hidden = true
+			}
+		}
+	}
+}
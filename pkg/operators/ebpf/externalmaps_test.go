@@ -0,0 +1,134 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
+)
+
+const testExternalMapName = "ebpfoperator_test_sockets"
+
+type testProvider struct{}
+
+func (p *testProvider) ProviderName() string            { return "test-enricher" }
+func (p *testProvider) MapName() string                 { return testExternalMapName }
+func (p *testProvider) LayoutVersion() int              { return 1 }
+func (p *testProvider) MapSpec() (*ebpf.MapSpec, error) { return &ebpf.MapSpec{}, nil }
+func (p *testProvider) Acquire() (*ebpf.Map, error)     { return nil, nil }
+func (p *testProvider) Release()                        {}
+
+func init() {
+	sharedmaps.Register(&testProvider{})
+}
+
+func TestCheckExternalMaps(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		config            string
+		expectedHidden    bool
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"provider_available": {
+			config: `
+externalMaps:
+  ebpfoperator_test_sockets:
+    layoutVersion: 1
+    provider: test-enricher
+`,
+		},
+		"provider_not_declared": {
+			config: `
+externalMaps:
+  ebpfoperator_test_sockets:
+    layoutVersion: 1
+`,
+		},
+		"other_provider": {
+			config: `
+externalMaps:
+  ebpfoperator_test_sockets:
+    layoutVersion: 1
+    provider: socket-enricher
+`,
+			expectedErrString: "gadget expects map \"ebpfoperator_test_sockets\" to be provided by \"socket-enricher\", but it's provided by \"test-enricher\"",
+		},
+		"provider_missing": {
+			config: `
+externalMaps:
+  gadget_sockets_missing:
+    layoutVersion: 1
+    provider: socket-enricher
+`,
+			expectedErrString: "gadget needs map \"gadget_sockets_missing\" provided by \"socket-enricher\", which isn't available",
+		},
+		"optional_provider_missing": {
+			config: `
+externalMaps:
+  gadget_sockets_missing:
+    layoutVersion: 1
+    provider: socket-enricher
+    optional: true
+`,
+			expectedHidden: true,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(test.config)))
+
+			i := &ebpfInstance{
+				config: config,
+				logger: logger.DefaultLogger(),
+				structs: map[string]*Struct{
+					"event": {
+						Fields: []*Field{
+							{Field: metadatav1.Field{Name: "comm", ExternalMap: "gadget_sockets_missing"}},
+							{Field: metadatav1.Field{Name: "pid"}},
+						},
+					},
+				},
+			}
+
+			err := i.checkExternalMaps()
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+
+			fields := i.structs["event"].Fields
+			require.Equal(t, test.expectedHidden, fields[0].Attributes.Hidden)
+			require.False(t, fields[1].Attributes.Hidden)
+		})
+	}
+}
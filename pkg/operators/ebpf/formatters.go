@@ -15,29 +15,49 @@
 package ebpfoperator
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/cilium/ebpf/btf"
 
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/annotations"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/capabilities"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/errno"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/redact"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/signals"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/syscalls"
 )
 
 const (
 	kernelStackTargetNameAnnotation = "ebpf.formatter.kstack"
 	enumTargetNameAnnotation        = "ebpf.formatter.enum"
+	valuesTargetNameAnnotation      = "ebpf.formatter.values"
 	enumBitfieldSeparatorAnnotation = "ebpf.formatter.bitfield.separator"
+	unionTargetNameAnnotation       = "ebpf.formatter.union"
 )
 
 const (
 	StackIdKernelType = "type:gadget_kernel_stack"
 )
 
+// defaultFlagsMaxWidth caps the width of the columns showing the names of the
+// flags set in a bitmask field, see metadatav1.FieldAttributes.FlagsMaxWidth
+const defaultFlagsMaxWidth = 32
+
 func byteSliceAsUint64(in []byte, signed bool, ds datasource.DataSource) uint64 {
 	if signed {
 		switch len(in) {
@@ -66,17 +86,28 @@ func byteSliceAsUint64(in []byte, signed bool, ds datasource.DataSource) uint64
 	return 0
 }
 
+// enumField identifies an enum field by the struct it belongs to and its full
+// name, as structs can have fields with the same name but different enums
+type enumField struct {
+	structName string
+	name       string
+}
+
 func (i *ebpfInstance) initEnumFormatter(gadgetCtx operators.GadgetContext) error {
 	btfSpec, err := btf.LoadKernelSpec()
 	if err != nil {
 		i.logger.Warnf("Kernel BTF information not available. Enums won't be resolved to strings")
 	}
 
+	emittedStructs := i.dataSourceEmittedStructs()
 	for _, ds := range gadgetCtx.GetDataSources() {
 		var formatters []func(ds datasource.DataSource, data datasource.Data) error
 
-		for name, enum := range i.enums {
-			in := ds.GetField(name)
+		for key, enum := range i.enums {
+			if !slices.Contains(emittedStructs[ds], key.structName) {
+				continue
+			}
+			in := ds.GetField(key.name)
 			if in == nil {
 				continue
 			}
@@ -124,17 +155,27 @@ func (i *ebpfInstance) initEnumFormatter(gadgetCtx operators.GadgetContext) erro
 					return nil
 				}
 			} else {
+				// Labels from the metadata take precedence over the enumerator names
+				values, defaultLabel := i.fieldValues(key.structName, key.name)
+				if defaultLabel == "" {
+					defaultLabel = "UNKNOWN"
+				}
+
 				formatter = func(ds datasource.DataSource, data datasource.Data) error {
 					// TODO: lookup table?
 					inBytes := in.Get(data)
 					val := byteSliceAsUint64(inBytes, enum.Signed, ds)
+					if label, ok := values[int64(val)]; ok {
+						out.Set(data, []byte(label))
+						return nil
+					}
 					for _, v := range enum.Values {
 						if val == v.Value {
 							out.Set(data, []byte(v.Name))
 							return nil
 						}
 					}
-					out.Set(data, []byte("UNKNOWN"))
+					out.Set(data, []byte(defaultLabel))
 					return nil
 				}
 			}
@@ -211,7 +252,718 @@ func (i *ebpfInstance) initStackConverter(gadgetCtx operators.GadgetContext) err
 	return nil
 }
 
+// bitfieldRawSuffix is appended to the name of the fields holding the bytes
+// of bitfields
+const bitfieldRawSuffix = "_raw"
+
+type bitfield struct {
+	// offset in bits from the start of the field holding the bitfield
+	offset btf.Bits
+	size   btf.Bits
+	signed bool
+	kind   api.Kind
+}
+
+func (i *ebpfInstance) initBitfieldFormatter(gadgetCtx operators.GadgetContext) error {
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for name, bf := range i.bitfields {
+			in := ds.GetField(name)
+			if in == nil {
+				continue
+			}
+			// The decoded field gets the original name and the metadata of
+			// the member
+			outName := strings.TrimSuffix(in.Name(), bitfieldRawSuffix)
+			opts := []datasource.FieldOption{datasource.WithAnnotations(maps.Clone(in.Annotations()))}
+			if in.Annotations()["hidden"] == "true" {
+				opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+			}
+			in.SetHidden(true, false)
+
+			var out datasource.FieldAccessor
+			var err error
+			if parent := in.Parent(); parent != nil {
+				out, err = parent.AddSubField(outName, bf.kind, opts...)
+			} else {
+				out, err = ds.AddField(outName, bf.kind, opts...)
+			}
+			if err != nil {
+				return fmt.Errorf("adding field for bitfield %q: %w", name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				val := btfhelpers.ReadBitfield(in.Get(data), bf.offset, bf.size, ds.ByteOrder(), bf.signed)
+				switch bf.kind {
+				case api.Kind_Bool:
+					return out.PutBool(data, val != 0)
+				case api.Kind_Int8:
+					return out.PutInt8(data, int8(val))
+				case api.Kind_Int16:
+					return out.PutInt16(data, int16(val))
+				case api.Kind_Int32:
+					return out.PutInt32(data, int32(val))
+				case api.Kind_Int64:
+					return out.PutInt64(data, int64(val))
+				case api.Kind_Uint8:
+					return out.PutUint8(data, uint8(val))
+				case api.Kind_Uint16:
+					return out.PutUint16(data, uint16(val))
+				case api.Kind_Uint32:
+					return out.PutUint32(data, uint32(val))
+				default:
+					return out.PutUint64(data, val)
+				}
+			})
+		}
+	}
+	return nil
+}
+
+// pointerRawSuffix is appended to the name of the fields holding the address
+// stored in pointers
+const pointerRawSuffix = "_raw"
+
+// initPointerFormatter renders the addresses stored in pointer members as
+// zero-padded hex strings, in a field with the original name of the member
+func (i *ebpfInstance) initPointerFormatter(gadgetCtx operators.GadgetContext) error {
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for name := range i.pointers {
+			in := ds.GetField(name)
+			if in == nil {
+				continue
+			}
+			outName := strings.TrimSuffix(in.Name(), pointerRawSuffix)
+			opts := []datasource.FieldOption{datasource.WithAnnotations(maps.Clone(in.Annotations()))}
+			if in.Annotations()["hidden"] == "true" {
+				opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+			}
+			in.SetHidden(true, false)
+
+			var out datasource.FieldAccessor
+			var err error
+			if parent := in.Parent(); parent != nil {
+				out, err = parent.AddSubField(outName, api.Kind_String, opts...)
+			} else {
+				out, err = ds.AddField(outName, api.Kind_String, opts...)
+			}
+			if err != nil {
+				return fmt.Errorf("adding field for pointer %q: %w", name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				addr, _ := in.Uint64(data)
+				return out.PutString(data, formatPointer(addr))
+			})
+		}
+	}
+	return nil
+}
+
+func formatPointer(addr uint64) string {
+	return fmt.Sprintf("0x%016x", addr)
+}
+
+// dataSourceStructs returns the name of the struct emitted by each data source
+// of the tracers and snapshotters
+func (i *ebpfInstance) dataSourceStructs() map[datasource.DataSource]string {
+	structNames := make(map[datasource.DataSource]string)
+	for _, tracer := range i.tracers {
+		if tracer.ds != nil {
+			structNames[tracer.ds] = tracer.StructName
+		}
+	}
+	for _, snapshotter := range i.snapshotters {
+		if snapshotter.ds != nil {
+			structNames[snapshotter.ds] = snapshotter.StructName
+		}
+	}
+	return structNames
+}
+
+// dataSourceEmittedStructs returns the names of all the structs whose fields
+// are emitted by each data source, like the key and value structs of the
+// snapshotters of maps
+func (i *ebpfInstance) dataSourceEmittedStructs() map[datasource.DataSource][]string {
+	structNames := make(map[datasource.DataSource][]string)
+	add := func(ds datasource.DataSource, names ...string) {
+		if ds == nil {
+			return
+		}
+		for _, name := range names {
+			if name != "" {
+				structNames[ds] = append(structNames[ds], name)
+			}
+		}
+	}
+	for _, tracer := range i.tracers {
+		add(tracer.ds, tracer.StructName)
+	}
+	for _, snapshotter := range i.snapshotters {
+		add(snapshotter.ds, snapshotter.KeyStructName, snapshotter.StructName)
+	}
+	for _, topper := range i.toppers {
+		add(topper.ds, topper.StructName)
+	}
+	for _, profiler := range i.profilers {
+		add(profiler.ds, profiler.KeyStructName)
+	}
+	for _, metric := range i.metrics {
+		add(metric.ds, metric.keyStructName)
+	}
+	return structNames
+}
+
+// replaceWithString hides in and frees its name for a string field with the
+// same annotations, holding the rendering of its value. The new field is
+// returned.
+func replaceWithString(ds datasource.DataSource, in datasource.FieldAccessor) (datasource.FieldAccessor, error) {
+	opts := []datasource.FieldOption{datasource.WithAnnotations(maps.Clone(in.Annotations()))}
+	if in.Annotations()["hidden"] == "true" {
+		opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+	}
+
+	in.SetHidden(true, false)
+	in.RemoveReference(false)
+
+	if parent := in.Parent(); parent != nil {
+		return parent.AddSubField(in.Name(), api.Kind_String, opts...)
+	}
+	return ds.AddField(in.Name(), api.Kind_String, opts...)
+}
+
+// integerTemplates renders the value of integer fields using these templates
+var integerTemplates = map[string]func(v int64) string{
+	// The table of the architecture set in the syscalls package is used
+	"syscall": func(v int64) string {
+		return syscalls.SyscallName(int(v))
+	},
+	"signal": signals.Name,
+	"capabilities": func(v int64) string {
+		return capabilities.Format(uint64(v))
+	},
+}
+
+// initIntegerTemplateFormatter renders the integers using one of
+// integerTemplates as a string, like the name of a syscall or a signal
+func (i *ebpfInstance) initIntegerTemplateFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			render, ok := integerTemplates[field.Attributes.Template]
+			if !ok {
+				continue
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+			var signed bool
+			switch in.Type() {
+			case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+				signed = true
+			case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+			default:
+				i.logger.Debugf("skipping %s field %q of type %s", field.Attributes.Template, field.Name, in.Type())
+				continue
+			}
+
+			out, err := replaceWithString(ds, in)
+			if err != nil {
+				return fmt.Errorf("adding field for %s %q: %w", field.Attributes.Template, field.Name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				v := int64(byteSliceAsUint64(in.Get(data), signed, ds))
+				return out.PutString(data, render(v))
+			})
+		}
+	}
+	return nil
+}
+
+// macAddrTemplate is the template of fields showing a MAC address
+const macAddrTemplate = "macaddr"
+
+// initMacAddrFormatter renders the 6-byte arrays using the "macaddr" template
+// as colon-separated hex strings. The string field takes the name of the
+// member, so JSON output also carries the formatted address.
+func (i *ebpfInstance) initMacAddrFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			if field.Attributes.Template != macAddrTemplate || field.Size != 6 {
+				continue
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+			out, err := replaceWithString(ds, in)
+			if err != nil {
+				return fmt.Errorf("adding field for MAC address %q: %w", field.Name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				return out.PutString(data, net.HardwareAddr(in.Get(data)).String())
+			})
+		}
+	}
+	return nil
+}
+
+// initRedactFormatter replaces the values of fields with the redact attribute
+// by their redacted form. It runs before the other formatters, so computed
+// fields only see the redacted values, and the captured bytes are cleared.
+func (i *ebpfInstance) initRedactFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			if field.Attributes.Redact == "" {
+				continue
+			}
+			redactor, err := redact.Parse(field.Attributes.Redact)
+			if err != nil {
+				return fmt.Errorf("field %q: %w", field.Name, err)
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+			out, err := replaceWithString(ds, in)
+			if err != nil {
+				return fmt.Errorf("adding field for redacted %q: %w", field.Name, err)
+			}
+			i.hasRedactedFields = true
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				b := in.Get(data)
+				value := string(b)
+				if n := bytes.IndexByte(b, 0); n >= 0 {
+					value = string(b[:n])
+				}
+				if i.redactionDisabled.Load() {
+					return out.PutString(data, value)
+				}
+				clear(b)
+				return out.PutString(data, redactor(value))
+			})
+		}
+	}
+	return nil
+}
+
+// initEncodingFormatter shows byte arrays using the encoding set in their
+// attributes. Arrays holding binary data are shown in hex or base64, so JSON
+// carries the exact bytes.
+func (i *ebpfInstance) initEncodingFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			var encode func([]byte) string
+			switch field.Attributes.Encoding {
+			case metadatav1.EncodingHex:
+				encode = hex.EncodeToString
+			case metadatav1.EncodingBase64:
+				encode = base64.StdEncoding.EncodeToString
+			case metadatav1.EncodingUTF8:
+				encode = func(b []byte) string {
+					if n := bytes.IndexByte(b, 0); n >= 0 {
+						b = b[:n]
+					}
+					return string(b)
+				}
+			default:
+				continue
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+			// Char arrays are already shown as text
+			if in.Type() == api.Kind_CString && field.Attributes.Encoding == metadatav1.EncodingUTF8 {
+				continue
+			}
+			out, err := replaceWithString(ds, in)
+			if err != nil {
+				return fmt.Errorf("adding field for %s encoding of %q: %w", field.Attributes.Encoding, field.Name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				return out.PutString(data, encode(in.Get(data)))
+			})
+		}
+	}
+	return nil
+}
+
+const (
+	// errnoTemplate is the template of fields holding an error number
+	errnoTemplate = "errno"
+	// errnoRawSuffix is appended to the name of the field keeping the
+	// numeric value of errno fields
+	errnoRawSuffix = "_raw"
+)
+
+// initErrnoFormatter renders negative values of signed integers using the
+// "errno" template as the name of the error, like EACCES. The number is kept
+// in a hidden sibling field, so JSON output carries both.
+func (i *ebpfInstance) initErrnoFormatter(gadgetCtx operators.GadgetContext) error {
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			if field.Attributes.Template != errnoTemplate {
+				continue
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+			switch in.Type() {
+			case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+			default:
+				i.logger.Debugf("skipping errno field %q of type %s", field.Name, in.Type())
+				continue
+			}
+
+			out, err := replaceWithString(ds, in)
+			if err != nil {
+				return fmt.Errorf("adding field for errno %q: %w", field.Name, err)
+			}
+			add := ds.AddField
+			if parent := in.Parent(); parent != nil {
+				add = parent.AddSubField
+			}
+			raw, err := add(in.Name()+errnoRawSuffix, api.Kind_Int64, datasource.WithFlags(datasource.FieldFlagHidden))
+			if err != nil {
+				return fmt.Errorf("adding raw field for errno %q: %w", field.Name, err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				ret := signedValue(in, data)
+				if err := raw.PutInt64(data, ret); err != nil {
+					return err
+				}
+				return out.PutString(data, errno.Format(ret))
+			})
+		}
+	}
+	return nil
+}
+
+// signedValue returns the value of a signed integer field
+func signedValue(f datasource.FieldAccessor, data datasource.Data) int64 {
+	switch f.Type() {
+	case api.Kind_Int8:
+		v, _ := f.Int8(data)
+		return int64(v)
+	case api.Kind_Int16:
+		v, _ := f.Int16(data)
+		return int64(v)
+	case api.Kind_Int32:
+		v, _ := f.Int32(data)
+		return int64(v)
+	}
+	v, _ := f.Int64(data)
+	return v
+}
+
+// initBitmaskFormatter adds a string field with the names of the flags set in
+// integer fields having flags in the metadata or a bitmask annotation. Flags
+// from the metadata take precedence.
+func (i *ebpfInstance) initBitmaskFormatter(gadgetCtx operators.GadgetContext) error {
+	structNames := i.dataSourceStructs()
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, in := range ds.Accessors(false) {
+			if _, ok := i.bitfields[in.FullName()]; ok {
+				// Handled by the field of the decoded bitfield
+				continue
+			}
+
+			zero := in.Annotations()[annotations.BitmaskZeroAnnotation]
+			metadataFlags, maxWidth := i.fieldFlags(structNames[ds], in.FullName())
+			var flags []annotations.Flag
+			if len(metadataFlags) > 0 {
+				for _, flag := range metadataFlags {
+					if flag.Value == 0 {
+						zero = flag.Name
+						continue
+					}
+					flags = append(flags, annotations.Flag{Value: flag.Value, Name: flag.Name})
+				}
+			} else {
+				bitsAnnotation, ok := in.Annotations()[annotations.BitmaskBitsAnnotation]
+				if !ok {
+					continue
+				}
+				bits, err := annotations.ParseBitmask(bitsAnnotation)
+				if err != nil {
+					return fmt.Errorf("parsing bitmask of field %q: %w", in.Name(), err)
+				}
+				flags = annotations.BitmaskFlags(bits)
+			}
+
+			var signed bool
+			switch in.Type() {
+			case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+				signed = true
+			case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+			default:
+				i.logger.Warnf("Bitmask is only supported for integers, field %q is %s", in.Name(), in.Type())
+				continue
+			}
+
+			separator := in.Annotations()[enumBitfieldSeparatorAnnotation]
+			if separator == "" {
+				separator = "|"
+			}
+
+			targetName, err := annotations.GetTargetNameFromAnnotation(i.logger, "bitmask", in, annotations.BitmaskAnnotation)
+			if err != nil {
+				if len(metadataFlags) == 0 {
+					i.logger.Warnf("Failed to get target name for bitmask field %q: %v", in.Name(), err)
+					continue
+				}
+				// Same default as the one used when populating the metadata
+				targetName = in.Name() + "_str"
+			}
+			in.SetHidden(true, false)
+
+			// Showing all the flags at once is rare, don't reserve room for it
+			if maxWidth == 0 {
+				maxWidth = defaultFlagsMaxWidth
+			}
+			width := min(annotations.FlagsWidth(flags, zero, separator, int(in.Size())), int(maxWidth))
+			opts := []datasource.FieldOption{
+				datasource.WithAnnotations(map[string]string{
+					"columns.width": strconv.Itoa(width),
+				}),
+			}
+
+			var out datasource.FieldAccessor
+			if parent := in.Parent(); parent != nil {
+				out, err = parent.AddSubField(targetName, api.Kind_String, opts...)
+			} else {
+				out, err = ds.AddField(targetName, api.Kind_String, opts...)
+			}
+			if err != nil {
+				return fmt.Errorf("adding field for bitmask %q: %w", in.Name(), err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				val := byteSliceAsUint64(in.Get(data), signed, ds)
+				if size := len(in.Get(data)); size < 8 {
+					// Don't show the sign extension as unknown flags
+					val &= 1<<(8*size) - 1
+				}
+				return out.PutString(data, annotations.RenderFlags(val, flags, zero, separator))
+			})
+		}
+	}
+	return nil
+}
+
+// isEnumField returns whether the field with the given full name of any of the
+// given structs is an enum
+func (i *ebpfInstance) isEnumField(structNames []string, name string) bool {
+	for _, structName := range structNames {
+		if _, ok := i.enums[enumField{structName: structName, name: name}]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// initValuesFormatter adds a field with the label of the value of integer
+// fields having a value-to-label mapping in the metadata. Values without label
+// are shown as numbers unless a default label is set. The integer field is
+// kept, hidden, for filtering and JSON.
+func (i *ebpfInstance) initValuesFormatter(gadgetCtx operators.GadgetContext) error {
+	structNames := i.dataSourceStructs()
+	emittedStructs := i.dataSourceEmittedStructs()
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, in := range ds.Accessors(false) {
+			if i.isEnumField(emittedStructs[ds], in.FullName()) {
+				// Handled by the enum formatter
+				continue
+			}
+			if _, ok := i.bitfields[in.FullName()]; ok {
+				// Handled by the field of the decoded bitfield
+				continue
+			}
+			values, defaultLabel := i.fieldValues(structNames[ds], in.FullName())
+			if len(values) == 0 {
+				continue
+			}
+
+			var signed bool
+			switch in.Type() {
+			case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+				signed = true
+			case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+			default:
+				i.logger.Warnf("Values are only supported for integers, field %q is %s", in.Name(), in.Type())
+				continue
+			}
+
+			targetName, err := annotations.GetTargetNameFromAnnotation(i.logger, "values", in, valuesTargetNameAnnotation)
+			if err != nil {
+				i.logger.Warnf("Failed to get target name for field %q with values: %v", in.Name(), err)
+				continue
+			}
+			in.SetHidden(true, false)
+
+			var out datasource.FieldAccessor
+			if parent := in.Parent(); parent != nil {
+				out, err = parent.AddSubField(targetName, api.Kind_String)
+			} else {
+				out, err = ds.AddField(targetName, api.Kind_String)
+			}
+			if err != nil {
+				return fmt.Errorf("adding field for values of %q: %w", in.Name(), err)
+			}
+
+			i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+				val := int64(byteSliceAsUint64(in.Get(data), signed, ds))
+				return out.PutString(data, valueLabel(val, signed, values, defaultLabel))
+			})
+		}
+	}
+	return nil
+}
+
+// valueLabel returns the label of val, the default label or val as a number
+func valueLabel(val int64, signed bool, values map[int64]string, defaultLabel string) string {
+	if label, ok := values[val]; ok {
+		return label
+	}
+	if defaultLabel != "" {
+		return defaultLabel
+	}
+	if signed {
+		return strconv.FormatInt(val, 10)
+	}
+	return strconv.FormatUint(uint64(val), 10)
+}
+
+// variantString returns the value of a union variant as a string
+func variantString(f datasource.FieldAccessor, ds datasource.DataSource, data datasource.Data) string {
+	switch f.Type() {
+	case api.Kind_String, api.Kind_CString:
+		s, _ := f.String(data)
+		return s
+	case api.Kind_Bool:
+		b, _ := f.Bool(data)
+		return strconv.FormatBool(b)
+	case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+		return strconv.FormatInt(int64(byteSliceAsUint64(f.Get(data), true, ds)), 10)
+	case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+		return strconv.FormatUint(byteSliceAsUint64(f.Get(data), false, ds), 10)
+	case api.Kind_Float32:
+		v, _ := f.Float32(data)
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case api.Kind_Float64:
+		v, _ := f.Float64(data)
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return hex.EncodeToString(f.Get(data))
+}
+
+// initUnionFormatter renders the active variant of unions with a variant
+// selector (see metadatav1.Field.VariantOf) to a string field, hiding the
+// union and all of its variants.
+func (i *ebpfInstance) initUnionFormatter(gadgetCtx operators.GadgetContext) error {
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, s := range i.structs {
+			for _, field := range s.Fields {
+				if field.VariantOf == "" {
+					continue
+				}
+
+				in := ds.GetField(field.Name)
+				if in == nil {
+					continue
+				}
+
+				selectorName := field.VariantOf
+				if idx := strings.LastIndex(field.Name, "."); idx >= 0 {
+					selectorName = field.Name[:idx+1] + field.VariantOf
+				}
+				selector := ds.GetField(selectorName)
+				if selector == nil {
+					return fmt.Errorf("variant selector %q of union %q not found", selectorName, field.Name)
+				}
+				signed := false
+				switch selector.Type() {
+				case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+					signed = true
+				}
+
+				subFields := make(map[string]datasource.FieldAccessor)
+				for _, sub := range in.SubFields() {
+					subFields[sub.Name()] = sub
+				}
+				variants := make(map[int64]datasource.FieldAccessor, len(field.Variants))
+				for v, name := range field.Variants {
+					sub, ok := subFields[name]
+					if !ok {
+						return fmt.Errorf("variant %q of union %q not found", name, field.Name)
+					}
+					variants[v] = sub
+				}
+
+				targetName, err := annotations.GetTargetNameFromAnnotation(i.logger, "union", in, unionTargetNameAnnotation)
+				if err != nil {
+					i.logger.Warnf("Failed to get target name for union field %q: %v", in.Name(), err)
+					continue
+				}
+				in.SetHidden(true, true)
+
+				var out datasource.FieldAccessor
+				if parent := in.Parent(); parent != nil {
+					out, err = parent.AddSubField(targetName, api.Kind_String)
+				} else {
+					out, err = ds.AddField(targetName, api.Kind_String)
+				}
+				if err != nil {
+					return fmt.Errorf("adding field for union %q: %w", field.Name, err)
+				}
+
+				i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+					val := int64(byteSliceAsUint64(selector.Get(data), signed, ds))
+					variant, ok := variants[val]
+					if !ok {
+						// Unknown variant, don't show any
+						return out.PutString(data, "")
+					}
+					return out.PutString(data, variantString(variant, ds, data))
+				})
+			}
+		}
+	}
+	return nil
+}
+
 func (i *ebpfInstance) initFormatters(gadgetCtx operators.GadgetContext) error {
+	// First, so no other formatter sees the values of sensitive fields
+	if err := i.initRedactFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing redact formatter: %w", err)
+	}
+
 	if err := i.initEnumFormatter(gadgetCtx); err != nil {
 		return fmt.Errorf("initializing enum formatter: %w", err)
 	}
@@ -220,5 +972,60 @@ func (i *ebpfInstance) initFormatters(gadgetCtx operators.GadgetContext) error {
 		return fmt.Errorf("initializing stack converters: %w", err)
 	}
 
+	if err := i.initBitfieldFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing bitfield formatter: %w", err)
+	}
+
+	// After the bitfields, so decoded bitfields can have labels too
+	if err := i.initValuesFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing values formatter: %w", err)
+	}
+
+	if err := i.initPointerFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing pointer formatter: %w", err)
+	}
+
+	// After the bitfields, so decoded bitfields can be flags too
+	if err := i.initBitmaskFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing bitmask formatter: %w", err)
+	}
+
+	// After the bitfields, so decoded bitfields can be used as selectors
+	if err := i.initUnionFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing union formatter: %w", err)
+	}
+
+	if err := i.initIntegerTemplateFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing integer template formatter: %w", err)
+	}
+
+	if err := i.initStackMapFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing stack map formatter: %w", err)
+	}
+
+	if err := i.initErrnoFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing errno formatter: %w", err)
+	}
+
+	if err := i.initEncodingFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing encoding formatter: %w", err)
+	}
+
+	if err := i.initMacAddrFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing MAC address formatter: %w", err)
+	}
+
+	if err := i.initProvenanceFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing provenance formatter: %w", err)
+	}
+
+	if err := i.initComputedFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing computed fields: %w", err)
+	}
+
+	if err := i.initAlertsFormatter(gadgetCtx); err != nil {
+		return fmt.Errorf("initializing alerts: %w", err)
+	}
+
 	return nil
 }
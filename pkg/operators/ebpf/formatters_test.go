@@ -0,0 +1,342 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestValueLabel(t *testing.T) {
+	t.Parallel()
+
+	protocols := map[int64]string{6: "TCP", 17: "UDP"}
+	errnos := map[int64]string{-1: "EPERM", -2: "ENOENT"}
+
+	type testCase struct {
+		val          int64
+		signed       bool
+		values       map[int64]string
+		defaultLabel string
+		expected     string
+	}
+
+	tests := map[string]testCase{
+		"tcp":            {val: 6, values: protocols, defaultLabel: "OTHER", expected: "TCP"},
+		"udp":            {val: 17, values: protocols, defaultLabel: "OTHER", expected: "UDP"},
+		"default_label":  {val: 1, values: protocols, defaultLabel: "OTHER", expected: "OTHER"},
+		"no_default":     {val: 1, values: protocols, expected: "1"},
+		"signed":         {val: -2, signed: true, values: errnos, expected: "ENOENT"},
+		"signed_unknown": {val: -5, signed: true, values: errnos, expected: "-5"},
+		// Unsigned 64-bit values above math.MaxInt64 are stored as negative ones
+		"u64_max": {val: -1, values: map[int64]string{-1: "ALL"}, expected: "ALL"},
+		"u64_big": {val: -2, values: map[int64]string{-1: "ALL"}, expected: "18446744073709551614"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, valueLabel(test.val, test.signed, test.values, test.defaultLabel))
+		})
+	}
+}
+
+func TestFieldValuesByStruct(t *testing.T) {
+	t.Parallel()
+
+	i := &ebpfInstance{
+		structs: map[string]*Struct{
+			"event": {Fields: []*Field{
+				{Field: metadatav1.Field{Name: "type", Values: map[int64]string{1: "EXEC"}, DefaultLabel: "OTHER"}},
+				{Field: metadatav1.Field{Name: "mode", Flags: []metadatav1.Flag{{Name: "READ", Value: 1}}}},
+			}},
+			"other_event": {Fields: []*Field{
+				{Field: metadatav1.Field{Name: "type", Values: map[int64]string{1: "OPEN"}}},
+				{Field: metadatav1.Field{Name: "mode", Flags: []metadatav1.Flag{{Name: "WRITE", Value: 1}}}},
+			}},
+		},
+	}
+
+	values, defaultLabel := i.fieldValues("event", "type")
+	require.Equal(t, map[int64]string{1: "EXEC"}, values)
+	require.Equal(t, "OTHER", defaultLabel)
+
+	values, defaultLabel = i.fieldValues("other_event", "type")
+	require.Equal(t, map[int64]string{1: "OPEN"}, values)
+	require.Empty(t, defaultLabel)
+
+	values, _ = i.fieldValues("unknown", "type")
+	require.Nil(t, values)
+
+	flags, _ := i.fieldFlags("other_event", "mode")
+	require.Equal(t, []metadatav1.Flag{{Name: "WRITE", Value: 1}}, flags)
+}
+
+func TestEnumFormatterByStruct(t *testing.T) {
+	t.Parallel()
+
+	// Both structs have a state_raw field, backed by different enums
+	enumStruct := func(name string, enum *btf.Enum) *btf.Struct {
+		return &btf.Struct{Name: name, Size: 4, Members: []btf.Member{{Name: "state_raw", Type: enum}}}
+	}
+	execState := &btf.Enum{Name: "test_exec_state", Size: 4, Values: []btf.EnumValue{
+		{Name: "RUNNING", Value: 0}, {Name: "EXITED", Value: 1},
+	}}
+	sockState := &btf.Enum{Name: "test_sock_state", Size: 4, Values: []btf.EnumValue{
+		{Name: "LISTEN", Value: 0}, {Name: "CLOSED", Value: 1},
+	}}
+
+	i := &ebpfInstance{
+		config:     viper.New(),
+		logger:     logger.DefaultLogger(),
+		structs:    make(map[string]*Struct),
+		enums:      make(map[enumField]*btf.Enum),
+		bitfields:  make(map[string]bitfield),
+		pointers:   make(map[string]struct{}),
+		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+	}
+	require.NoError(t, i.populateStructDirect(enumStruct("exec_event", execState)))
+	require.NoError(t, i.populateStructDirect(enumStruct("sock_event", sockState)))
+	require.Equal(t, map[enumField]*btf.Enum{
+		{structName: "exec_event", name: "state_raw"}: execState,
+		{structName: "sock_event", name: "state_raw"}: sockState,
+	}, i.enums)
+
+	gadgetCtx := gadgetcontext.New(context.Background(), "")
+	i.tracers = make(map[string]*Tracer)
+	for name, structName := range map[string]string{"exec": "exec_event", "sock": "sock_event"} {
+		ds, err := gadgetCtx.RegisterDataSource(datasource.TypeSingle, name)
+		require.NoError(t, err)
+		_, err = ds.AddField("state_raw", api.Kind_Uint32)
+		require.NoError(t, err)
+		i.tracers[name] = &Tracer{Tracer: metadatav1.Tracer{StructName: structName}, ds: ds}
+	}
+	require.NoError(t, i.initEnumFormatter(gadgetCtx))
+
+	for name, expected := range map[string]string{"exec": "EXITED", "sock": "CLOSED"} {
+		ds := i.tracers[name].ds
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, ds.GetField("state_raw").PutUint32(data, 1))
+
+		require.Len(t, i.formatters[ds], 1)
+		require.NoError(t, i.formatters[ds][0](ds, data))
+		state, err := ds.GetField("state").String(data)
+		require.NoError(t, err)
+		require.Equal(t, expected, state, name)
+	}
+}
+
+func TestErrnoFormatter(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	ret, err := ds.AddField("ret", api.Kind_Int32)
+	require.NoError(t, err)
+
+	i := &ebpfInstance{
+		tracers: map[string]*Tracer{
+			"events": {Tracer: metadatav1.Tracer{StructName: "event"}, ds: ds},
+		},
+		structs: map[string]*Struct{
+			"event": {Fields: []*Field{
+				{Field: metadatav1.Field{Name: "ret", Attributes: metadatav1.FieldAttributes{Template: "errno"}}},
+			}},
+		},
+		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+	}
+	require.NoError(t, i.initErrnoFormatter(nil))
+
+	out := ds.GetField("ret")
+	require.NotNil(t, out)
+	require.Equal(t, api.Kind_String, out.Type())
+	raw := ds.GetField("ret_raw")
+	require.NotNil(t, raw)
+
+	for value, expected := range map[int32]string{-13: "EACCES", 0: "0", 3: "3"} {
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, ret.PutInt32(data, value))
+
+		for _, formatter := range i.formatters[ds] {
+			require.NoError(t, formatter(ds, data))
+		}
+
+		str, err := out.String(data)
+		require.NoError(t, err)
+		require.Equal(t, expected, str)
+		n, err := raw.Int64(data)
+		require.NoError(t, err)
+		require.Equal(t, int64(value), n)
+	}
+}
+
+func TestEncodingFormatter(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte{'G', 'E', 'T', 0x00, 0xff, 0xfe, 0x80, 0x01}
+
+	type testCase struct {
+		encoding metadatav1.Encoding
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"utf8":   {encoding: metadatav1.EncodingUTF8, expected: "GET"},
+		"hex":    {encoding: metadatav1.EncodingHex, expected: "47455400fffe8001"},
+		"base64": {encoding: metadatav1.EncodingBase64, expected: "R0VUAP/+gAE="},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := datasource.New(datasource.TypeSingle, "event")
+			require.NoError(t, err)
+			payload, err := ds.AddField("payload", api.Kind_Bytes)
+			require.NoError(t, err)
+
+			i := &ebpfInstance{
+				tracers: map[string]*Tracer{
+					"events": {Tracer: metadatav1.Tracer{StructName: "event"}, ds: ds},
+				},
+				structs: map[string]*Struct{
+					"event": {Fields: []*Field{
+						{Field: metadatav1.Field{Name: "payload", Attributes: metadatav1.FieldAttributes{Encoding: test.encoding}}},
+					}},
+				},
+				formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+			}
+			require.NoError(t, i.initEncodingFormatter(nil))
+
+			out := ds.GetField("payload")
+			require.NotNil(t, out)
+			require.Equal(t, api.Kind_String, out.Type())
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, payload.Set(data, raw))
+			for _, formatter := range i.formatters[ds] {
+				require.NoError(t, formatter(ds, data))
+			}
+
+			str, err := out.String(data)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, str)
+		})
+	}
+}
+
+func TestRedactFormatter(t *testing.T) {
+	t.Parallel()
+
+	raw := []byte{'h', 'u', 'n', 't', 'e', 'r', '2', 0x00, 'x'}
+
+	type testCase struct {
+		mode     string
+		disabled bool
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"full":       {mode: "full", expected: "***"},
+		"keepPrefix": {mode: "keepPrefix:2", expected: "hu***"},
+		"hash":       {mode: "hash", expected: "sha256:f52fbd32b2b3b86f"},
+		"disabled":   {mode: "full", disabled: true, expected: "hunter2"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, err := datasource.New(datasource.TypeSingle, "event")
+			require.NoError(t, err)
+			password, err := ds.AddField("password", api.Kind_Bytes)
+			require.NoError(t, err)
+
+			i := &ebpfInstance{
+				tracers: map[string]*Tracer{
+					"events": {Tracer: metadatav1.Tracer{StructName: "event"}, ds: ds},
+				},
+				structs: map[string]*Struct{
+					"event": {Fields: []*Field{
+						{Field: metadatav1.Field{Name: "password", Attributes: metadatav1.FieldAttributes{Redact: test.mode}}},
+					}},
+				},
+				formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+			}
+			require.NoError(t, i.initRedactFormatter(nil))
+			require.True(t, i.hasRedactedFields)
+			i.redactionDisabled.Store(test.disabled)
+
+			out := ds.GetField("password")
+			require.NotNil(t, out)
+			require.Equal(t, api.Kind_String, out.Type())
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, password.Set(data, bytes.Clone(raw)))
+			for _, formatter := range i.formatters[ds] {
+				require.NoError(t, formatter(ds, data))
+			}
+
+			str, err := out.String(data)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, str)
+
+			if !test.disabled {
+				require.NotContains(t, string(password.Get(data)), "hunter2")
+			}
+		})
+	}
+}
+
+func TestRedactFormatterInvalidMode(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	_, err = ds.AddField("password", api.Kind_Bytes)
+	require.NoError(t, err)
+
+	i := &ebpfInstance{
+		tracers: map[string]*Tracer{
+			"events": {Tracer: metadatav1.Tracer{StructName: "event"}, ds: ds},
+		},
+		structs: map[string]*Struct{
+			"event": {Fields: []*Field{
+				{Field: metadatav1.Field{Name: "password", Attributes: metadatav1.FieldAttributes{Redact: "mask"}}},
+			}},
+		},
+		formatters: make(map[datasource.DataSource][]func(ds datasource.DataSource, data datasource.Data) error),
+	}
+	require.Error(t, i.initRedactFormatter(nil))
+}
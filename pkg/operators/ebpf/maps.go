@@ -15,10 +15,13 @@
 package ebpfoperator
 
 import (
+	"fmt"
 	"reflect"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
 )
 
 func (i *ebpfInstance) populateMap(t btf.Type, varName string) error {
@@ -37,3 +40,34 @@ func (i *ebpfInstance) populateMap(t btf.Type, varName string) error {
 	i.gadgetCtx.SetVar(varName, nilVal)
 	return nil
 }
+
+// populateExternalMap handles a map shared with other gadgets. Its layout has
+// to match the one of the provider, otherwise the gadget would read garbage.
+func (i *ebpfInstance) populateExternalMap(t btf.Type, varName string) error {
+	provider := sharedmaps.Get(varName)
+
+	mapSpec, ok := i.collectionSpec.Maps[varName]
+	if !ok {
+		return fmt.Errorf("shared map %q not found in eBPF object", varName)
+	}
+
+	key := "externalMaps." + varName + ".layoutVersion"
+	if i.config.IsSet(key) {
+		if version := i.config.GetInt(key); version != provider.LayoutVersion() {
+			return fmt.Errorf("gadget was built against layout version %d of shared map %q but version %d is provided, rebuild the gadget",
+				version, varName, provider.LayoutVersion())
+		}
+	} else {
+		i.logger.Debugf("shared map %q not declared in externalMaps, only checking its layout", varName)
+	}
+
+	providerSpec, err := provider.MapSpec()
+	if err != nil {
+		return fmt.Errorf("getting spec of shared map %q: %w", varName, err)
+	}
+	if err := sharedmaps.CheckLayout(mapSpec, providerSpec); err != nil {
+		return fmt.Errorf("layout of shared map %q doesn't match the provided one, rebuild the gadget: %w", varName, err)
+	}
+
+	return i.populateMap(t, varName)
+}
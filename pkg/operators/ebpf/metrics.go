@@ -0,0 +1,329 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const defaultMetricsInterval = "10s"
+
+// MapMetric is a metric declared in the metrics section of the metadata that
+// is read from a map. Each entry of the map is emitted as an element of a
+// data source named after the metric: the fields of the key struct followed
+// by the value.
+type MapMetric struct {
+	metadatav1.Metric
+
+	ds datasource.DataSource
+	// accessor holds the fields of the key struct
+	accessor   datasource.FieldAccessor
+	valueField datasource.FieldAccessor
+
+	keyStructName string
+	keySize       uint32
+	valueSize     uint32
+
+	// fieldOffset, fieldSize and signed locate the integer within the value
+	// of the map
+	fieldOffset uint32
+	fieldSize   uint32
+	signed      bool
+
+	// totals are the running totals of counters read on the previous
+	// interval, by key
+	totals map[string]uint64
+}
+
+// metricEntry is the value of a metric for a key of its map
+type metricEntry struct {
+	key   []byte
+	value uint64
+}
+
+// populateMetrics looks up the maps of the map-derived metrics declared in the
+// metadata. Event-derived metrics are computed by the otel-metrics operator
+// from the data sources of the gadget.
+func (i *ebpfInstance) populateMetrics() error {
+	var metrics map[string]metadatav1.Metric
+	if err := i.config.UnmarshalKey("metrics", &metrics); err != nil {
+		return fmt.Errorf("reading metrics: %w", err)
+	}
+
+	for name, metric := range metrics {
+		if metric.MapName == "" {
+			continue
+		}
+
+		i.logger.Debugf("populating metric %q", name)
+
+		metricMap, ok := i.collectionSpec.Maps[metric.MapName]
+		if !ok {
+			return fmt.Errorf("metric %q: map %q not found in eBPF object", name, metric.MapName)
+		}
+		switch metricMap.Type {
+		case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+		default:
+			return fmt.Errorf("metric %q: map %q has a wrong type, expected: hash or lru hash (optionally per-CPU), got: %s",
+				name, metric.MapName, metricMap.Type.String())
+		}
+		if metricMap.Key == nil || metricMap.Value == nil {
+			return fmt.Errorf("metric %q: map %q does not have BTF information", name, metric.MapName)
+		}
+
+		keyStruct, ok := btf.UnderlyingType(metricMap.Key).(*btf.Struct)
+		if !ok {
+			return fmt.Errorf("metric %q: map %q key is %q, expected a struct", name, metric.MapName, metricMap.Key.TypeName())
+		}
+
+		mapMetric := &MapMetric{
+			Metric:        metric,
+			keyStructName: keyStruct.Name,
+			keySize:       metricMap.KeySize,
+			valueSize:     metricMap.ValueSize,
+			totals:        make(map[string]uint64),
+		}
+		if err := mapMetric.locateValue(metricMap.Value); err != nil {
+			return fmt.Errorf("metric %q: %w", name, err)
+		}
+		i.metrics[name] = mapMetric
+
+		if err := i.populateStructDirect(keyStruct); err != nil {
+			return fmt.Errorf("populating struct %q for metric %q: %w", keyStruct.Name, name, err)
+		}
+	}
+	return nil
+}
+
+// locateValue finds the integer holding the value of the metric within the
+// value of its map: the value itself or its member named by Field
+func (m *MapMetric) locateValue(value btf.Type) error {
+	var typ btf.Type
+	switch t := btf.UnderlyingType(value).(type) {
+	case *btf.Int:
+		typ = t
+	case *btf.Struct:
+		if m.Field == "" {
+			return fmt.Errorf("field is required, map %q value is struct %q", m.MapName, t.Name)
+		}
+		for _, member := range t.Members {
+			if member.Name == m.Field {
+				typ = member.Type
+				m.fieldOffset = member.Offset.Bytes()
+				break
+			}
+		}
+		if typ == nil {
+			return fmt.Errorf("field %q not found in struct %q", m.Field, t.Name)
+		}
+	default:
+		return fmt.Errorf("map %q value is %q, expected an integer or a struct", m.MapName, value.TypeName())
+	}
+
+	intType, ok := btf.UnderlyingType(typ).(*btf.Int)
+	if !ok {
+		return fmt.Errorf("value of map %q must be an integer", m.MapName)
+	}
+	m.fieldSize = intType.Size
+	m.signed = intType.Encoding == btf.Signed
+	return nil
+}
+
+// addFields adds the value field to the data source and annotates the fields
+// so the otel-metrics operator exports the top level fields of the key as
+// labels.
+func (m *MapMetric) addFields(keyFields []*Field) error {
+	valueName := m.Field
+	if valueName == "" {
+		valueName = metadatav1.MapMetricValueField
+	}
+	kind := api.Kind_Uint64
+	if m.signed {
+		kind = api.Kind_Int64
+	}
+
+	var err error
+	m.valueField, err = m.ds.AddField(valueName, kind,
+		datasource.WithAnnotations(map[string]string{
+			metadatav1.AnnotationMetricsType: string(m.Type),
+			"description":                    m.Help,
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("adding field %q: %w", valueName, err)
+	}
+
+	for _, f := range keyFields {
+		if f.parent != -1 {
+			continue
+		}
+		if integer, _ := isIntegerKind(f.kind); !integer && f.kind != api.Kind_CString && f.kind != api.Kind_String {
+			continue
+		}
+		if acc := m.ds.GetField(f.Name); acc != nil {
+			acc.AddAnnotation(metadatav1.AnnotationMetricsType, metadatav1.MetricTypeKey)
+		}
+	}
+	return nil
+}
+
+func (m *MapMetric) fieldValue(value []byte) uint64 {
+	if len(value) < int(m.fieldOffset+m.fieldSize) {
+		return 0
+	}
+	return byteSliceAsUint64(value[m.fieldOffset:m.fieldOffset+m.fieldSize], m.signed, m.ds)
+}
+
+// collect reads the value of the metric for every key of the map. Entries
+// are kept in the map, as it holds running totals: counters report how much
+// they increased since the previous call, gauges their current value.
+func (m *MapMetric) collect(sm statsMap, possibleCPUs int) ([]metricEntry, error) {
+	perCPU := sm.Type() == ebpf.PerCPUHash || sm.Type() == ebpf.LRUCPUHash
+
+	var keys [][]byte
+	// An untyped nil is needed to get the first key
+	var key any
+	for {
+		var nextKey []byte
+		err := sm.NextKey(key, &nextKey)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iterating map %q: %w", m.MapName, err)
+		}
+		keys = append(keys, nextKey)
+		key = nextKey
+	}
+
+	entries := make([]metricEntry, 0, len(keys))
+	totals := make(map[string]uint64, len(keys))
+	for _, key := range keys {
+		var value uint64
+		if perCPU {
+			values := make([][]byte, possibleCPUs)
+			if err := sm.Lookup(key, values); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", m.MapName, err)
+			}
+			for _, v := range values {
+				value += m.fieldValue(v)
+			}
+		} else {
+			var v []byte
+			if err := sm.Lookup(key, &v); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", m.MapName, err)
+			}
+			value = m.fieldValue(v)
+		}
+
+		if m.Type == metadatav1.MetricTypeGauge {
+			entries = append(entries, metricEntry{key: key, value: value})
+			continue
+		}
+
+		totals[string(key)] = value
+		delta := value
+		// A total lower than the previous one means the entry was removed
+		// and added again in the meantime
+		if last, ok := m.totals[string(key)]; ok && value >= last {
+			delta = value - last
+		}
+		if delta == 0 {
+			continue
+		}
+		entries = append(entries, metricEntry{key: key, value: delta})
+	}
+	if m.Type != metadatav1.MetricTypeGauge {
+		m.totals = totals
+	}
+	return entries, nil
+}
+
+func (m *MapMetric) emit(entries []metricEntry) error {
+	pArray, err := m.ds.NewPacketArray()
+	if err != nil {
+		return fmt.Errorf("creating new packet: %w", err)
+	}
+	for idx, entry := range entries {
+		data := pArray.New()
+		if err := m.setEntry(data, entry); err != nil {
+			pArray.Release(data)
+			m.ds.Release(pArray)
+			return fmt.Errorf("setting data element %d: %w", idx, err)
+		}
+		pArray.Append(data)
+	}
+	return m.ds.EmitAndRelease(pArray)
+}
+
+func (m *MapMetric) setEntry(data datasource.Data, entry metricEntry) error {
+	if err := m.accessor.Set(data, entry.key[:m.keySize]); err != nil {
+		return err
+	}
+	if m.signed {
+		return m.valueField.PutInt64(data, int64(entry.value))
+	}
+	return m.valueField.PutUint64(data, entry.value)
+}
+
+func (i *ebpfInstance) runMapMetric(gadgetCtx operators.GadgetContext, name string, metric *MapMetric, interval time.Duration) {
+	m, ok := i.collection.Maps[metric.MapName]
+	if !ok {
+		i.logger.Errorf("metric %q: map %q not found", name, metric.MapName)
+		return
+	}
+
+	possibleCPUs, err := ebpf.PossibleCPU()
+	if err != nil {
+		i.logger.Errorf("metric %q: getting number of possible CPUs: %v", name, err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gadgetCtx.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		entries, err := metric.collect(m, possibleCPUs)
+		if err != nil {
+			i.logger.Errorf("metric %q: %v", name, err)
+			continue
+		}
+		if err := metric.emit(entries); err != nil {
+			i.logger.Errorf("metric %q: emitting data: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func ioKey(dev, op uint32) []byte {
+	b := make([]byte, 8)
+	binary.NativeEndian.PutUint32(b[0:], dev)
+	binary.NativeEndian.PutUint32(b[4:], op)
+	return b
+}
+
+func ioStats(ops uint64) []byte {
+	b := make([]byte, 16)
+	binary.NativeEndian.PutUint64(b[8:], ops)
+	return b
+}
+
+func newIOMetric(t *testing.T, metricType metadatav1.MetricType) *MapMetric {
+	ds, err := datasource.New(datasource.TypeArray, "io_ops_total")
+	require.NoError(t, err)
+
+	keyFields := []*Field{
+		{Field: metadatav1.Field{Name: "dev"}, Offset: 0, Size: 4, parent: -1, name: "dev", kind: api.Kind_Uint32},
+		{Field: metadatav1.Field{Name: "op"}, Offset: 4, Size: 4, parent: -1, name: "op", kind: api.Kind_Uint32},
+	}
+	staticFields := make([]datasource.StaticField, 0, len(keyFields))
+	for _, f := range keyFields {
+		staticFields = append(staticFields, f)
+	}
+	accessor, err := ds.AddStaticFields(8, staticFields)
+	require.NoError(t, err)
+
+	m := &MapMetric{
+		Metric: metadatav1.Metric{
+			Help:    "I/O operations",
+			Type:    metricType,
+			MapName: "io",
+			Field:   "ops",
+		},
+		ds:          ds,
+		accessor:    accessor,
+		keySize:     8,
+		valueSize:   16,
+		fieldOffset: 8,
+		fieldSize:   8,
+		totals:      make(map[string]uint64),
+	}
+	require.NoError(t, m.addFields(keyFields))
+	return m
+}
+
+func TestMapMetricCollect(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		metricType metadatav1.MetricType
+		mapType    ebpf.MapType
+		first      map[string][][]byte
+		second     map[string][][]byte
+		expected   []metricEntry
+	}
+
+	tests := map[string]testCase{
+		"counter": {
+			metricType: metadatav1.MetricTypeCounter,
+			mapType:    ebpf.Hash,
+			first: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(10)},
+				string(ioKey(1, 1)): {ioStats(5)},
+			},
+			second: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(12)},
+				string(ioKey(1, 1)): {ioStats(5)},
+				string(ioKey(2, 0)): {ioStats(3)},
+			},
+			expected: []metricEntry{
+				{key: ioKey(1, 0), value: 2},
+				{key: ioKey(2, 0), value: 3},
+			},
+		},
+		"counter_reset": {
+			metricType: metadatav1.MetricTypeCounter,
+			mapType:    ebpf.LRUHash,
+			first: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(10)},
+			},
+			second: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(4)},
+			},
+			expected: []metricEntry{
+				{key: ioKey(1, 0), value: 4},
+			},
+		},
+		"gauge": {
+			metricType: metadatav1.MetricTypeGauge,
+			mapType:    ebpf.Hash,
+			first: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(10)},
+			},
+			second: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(7)},
+				string(ioKey(1, 1)): {ioStats(0)},
+			},
+			expected: []metricEntry{
+				{key: ioKey(1, 0), value: 7},
+				{key: ioKey(1, 1), value: 0},
+			},
+		},
+		"per_cpu": {
+			metricType: metadatav1.MetricTypeCounter,
+			mapType:    ebpf.PerCPUHash,
+			first: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(1), nil, ioStats(2)},
+			},
+			second: map[string][][]byte{
+				string(ioKey(1, 0)): {ioStats(4), ioStats(1), ioStats(2)},
+			},
+			expected: []metricEntry{
+				{key: ioKey(1, 0), value: 4},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			metric := newIOMetric(t, test.metricType)
+
+			m := newFakeStatsMap(test.mapType)
+			for _, key := range sortedTestKeys(test.first) {
+				m.put(key, test.first[key]...)
+			}
+			_, err := metric.collect(m, 4)
+			require.NoError(t, err)
+
+			// Entries are kept in the map between intervals
+			require.Len(t, m.keys, len(test.first))
+
+			for _, key := range sortedTestKeys(test.second) {
+				m.put(key, test.second[key]...)
+			}
+			entries, err := metric.collect(m, 4)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, entries)
+		})
+	}
+}
+
+func TestMapMetricLabels(t *testing.T) {
+	t.Parallel()
+
+	metric := newIOMetric(t, metadatav1.MetricTypeCounter)
+
+	pArray, err := metric.ds.NewPacketArray()
+	require.NoError(t, err)
+	data := pArray.New()
+	require.NoError(t, metric.setEntry(data, metricEntry{key: ioKey(259, 1), value: 42}))
+
+	// The fields of the key struct are the labels of the metric
+	for name, expected := range map[string]uint32{"dev": 259, "op": 1} {
+		f := metric.ds.GetField(name)
+		require.NotNil(t, f)
+		require.Equal(t, metadatav1.MetricTypeKey, f.Annotations()[metadatav1.AnnotationMetricsType])
+		v, err := f.Uint32(data)
+		require.NoError(t, err)
+		require.Equal(t, expected, v)
+	}
+
+	value := metric.ds.GetField("ops")
+	require.NotNil(t, value)
+	require.Equal(t, string(metadatav1.MetricTypeCounter), value.Annotations()[metadatav1.AnnotationMetricsType])
+	require.Equal(t, "I/O operations", value.Annotations()["description"])
+	v, err := value.Uint64(data)
+	require.NoError(t, err)
+	require.Equal(t, uint64(42), v)
+}
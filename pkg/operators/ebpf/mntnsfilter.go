@@ -0,0 +1,52 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+)
+
+// initMntNsFilter sets the map filtering events by mount namespace: the one
+// declared in the metadata or, if none is, the conventional one. The managers
+// provide it under the conventional name, see renameMntNsFilterMap.
+func (i *ebpfInstance) initMntNsFilter() error {
+	i.mntnsFilterMap = gadgets.MntNsFilterMapName
+
+	name := i.config.GetString("mntnsFilter.mapName")
+	if name == "" {
+		return nil
+	}
+	if _, ok := i.collectionSpec.Maps[name]; !ok {
+		return fmt.Errorf("mount namespace filter map %q not found in eBPF object", name)
+	}
+	i.mntnsFilterMap = name
+	return nil
+}
+
+// renameMntNsFilterMap moves the map provided under the conventional name to
+// the one declared in the metadata
+func (i *ebpfInstance) renameMntNsFilterMap(mapReplacements map[string]*ebpf.Map) {
+	if i.mntnsFilterMap == gadgets.MntNsFilterMapName {
+		return
+	}
+	if m, ok := mapReplacements[gadgets.MntNsFilterMapName]; ok {
+		delete(mapReplacements, gadgets.MntNsFilterMapName)
+		mapReplacements[i.mntnsFilterMap] = m
+	}
+}
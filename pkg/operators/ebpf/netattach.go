@@ -0,0 +1,246 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cilium/ebpf"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/tchandler"
+)
+
+// containerAttacher attaches a network program to the network namespace of
+// containers. It's implemented by the network tracers and the TC handlers.
+type containerAttacher interface {
+	AttachContainer(container *containercollection.Container) error
+	DetachContainer(container *containercollection.Container) error
+}
+
+// netProgram is how a socket filter or TC program attaches to the network
+type netProgram struct {
+	interfaces metadatav1.AttachInterfaces
+	// direction of the traffic seen by TC programs
+	direction tchandler.AttachmentDirection
+	// iface is the host interface TC programs attach to when interfaces is
+	// param
+	iface string
+}
+
+// resolveNetProgram returns how the program attaches to the network: the
+// section name is completed by the attach section of the metadata, and the
+// iface param attaches TC programs to that interface
+func (i *ebpfInstance) resolveNetProgram(p *ebpf.ProgramSpec) (*netProgram, error) {
+	var attach metadatav1.NetworkAttach
+	if attachConfig := i.config.Sub("attach." + p.Name); attachConfig != nil {
+		if err := attachConfig.Unmarshal(&attach); err != nil {
+			return nil, fmt.Errorf("program %q: unmarshalling metadata: %w", p.Name, err)
+		}
+	}
+
+	np := &netProgram{
+		interfaces: attach.Interfaces,
+	}
+	if np.interfaces == "" {
+		np.interfaces = metadatav1.AttachInterfacesPod
+	}
+
+	if p.Type != ebpf.SchedCLS {
+		return np, nil
+	}
+
+	parts := strings.Split(p.SectionName, "/")
+	if parts[0] != "classifier" || (len(parts) != 1 && len(parts) != 3) {
+		return nil, fmt.Errorf("invalid section name %q", p.SectionName)
+	}
+	direction := attach.Direction
+	if len(parts) == 3 {
+		direction = metadatav1.AttachDirection(parts[1])
+	}
+	switch direction {
+	case metadatav1.AttachDirectionIngress:
+		np.direction = tchandler.AttachmentDirectionIngress
+	case metadatav1.AttachDirectionEgress:
+		np.direction = tchandler.AttachmentDirectionEgress
+	case "":
+		return nil, fmt.Errorf("program %q doesn't set its direction: use a section like %q or the attach section of the metadata",
+			p.Name, "classifier/ingress/"+p.Name)
+	default:
+		return nil, fmt.Errorf("unsupported hook type %q", direction)
+	}
+
+	if iface := i.paramValues[ParamIface]; iface != "" {
+		np.interfaces = metadatav1.AttachInterfacesParam
+		np.iface = iface
+	} else if np.interfaces == metadatav1.AttachInterfacesParam {
+		return nil, fmt.Errorf("program %q attaches to the interface set by the %s param, which is empty", p.Name, ParamIface)
+	}
+
+	return np, nil
+}
+
+// attachHostIfaces attaches a TC program to the interfaces of the host
+// selected by np. Interfaces going away while listing them are skipped.
+func (i *ebpfInstance) attachHostIfaces(name string, np *netProgram, handler *tchandler.Handler) error {
+	switch np.interfaces {
+	case metadatav1.AttachInterfacesParam:
+		iface, err := net.InterfaceByName(np.iface)
+		if err != nil {
+			return fmt.Errorf("getting interface %q: %w", np.iface, err)
+		}
+		if err := handler.AttachIface(iface); err != nil {
+			return fmt.Errorf("attaching iface %q: %w", np.iface, err)
+		}
+	case metadatav1.AttachInterfacesAll:
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return fmt.Errorf("listing interfaces: %w", err)
+		}
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			if err := handler.AttachIface(&iface); err != nil {
+				i.logger.Warnf("attaching program %q to interface %q: %v", name, iface.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// netAttachment is a container whose network namespace programs are attached
+// to
+type netAttachment struct {
+	container *containercollection.Container
+	// programs attached successfully, the only ones to detach
+	programs []string
+}
+
+// netAttachments keeps track of the network namespaces of the containers
+// the programs are attached to, to detach them when the containers go away
+// or the gadget stops. Failing to attach or detach a program, like when an
+// interface disappears, is logged without stopping the gadget.
+type netAttachments struct {
+	mu     sync.Mutex
+	logger logger.Logger
+
+	// attachers of the programs attaching to each container, by program name
+	attachers map[string]containerAttacher
+	// containers attached, by container ID
+	containers map[string]*netAttachment
+	// ids of the containers attached in each network namespace
+	netns map[uint64]map[string]struct{}
+}
+
+func newNetAttachments(logger logger.Logger) *netAttachments {
+	return &netAttachments{
+		logger:     logger,
+		attachers:  make(map[string]containerAttacher),
+		containers: make(map[string]*netAttachment),
+		netns:      make(map[uint64]map[string]struct{}),
+	}
+}
+
+func (n *netAttachments) add(name string, attacher containerAttacher) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.attachers[name] = attacher
+}
+
+func (n *netAttachments) attach(container *containercollection.Container) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if len(n.attachers) == 0 {
+		return
+	}
+	id := container.Runtime.ContainerID
+	if _, ok := n.containers[id]; ok {
+		return
+	}
+
+	a := &netAttachment{container: container}
+	for _, name := range sortedKeys(n.attachers) {
+		if err := n.attachers[name].AttachContainer(container); err != nil {
+			n.logger.Warnf("attaching program %q to network namespace %d of container %q: %v",
+				name, container.Netns, container.Runtime.ContainerName, err)
+			continue
+		}
+		a.programs = append(a.programs, name)
+	}
+	n.containers[id] = a
+
+	users, ok := n.netns[container.Netns]
+	if !ok {
+		n.logger.Debugf("attaching programs to network namespace %d", container.Netns)
+		users = make(map[string]struct{})
+		n.netns[container.Netns] = users
+	}
+	users[id] = struct{}{}
+}
+
+func (n *netAttachments) detach(container *containercollection.Container) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.detachLocked(container.Runtime.ContainerID)
+}
+
+func (n *netAttachments) detachLocked(id string) {
+	a, ok := n.containers[id]
+	if !ok {
+		return
+	}
+	delete(n.containers, id)
+
+	for _, name := range a.programs {
+		if err := n.attachers[name].DetachContainer(a.container); err != nil {
+			n.logger.Warnf("detaching program %q from network namespace %d of container %q: %v",
+				name, a.container.Netns, a.container.Runtime.ContainerName, err)
+		}
+	}
+
+	users := n.netns[a.container.Netns]
+	delete(users, id)
+	if len(users) == 0 {
+		n.logger.Debugf("detached programs from network namespace %d", a.container.Netns)
+		delete(n.netns, a.container.Netns)
+	}
+}
+
+// detachAll detaches the programs from all the containers, when the gadget
+// stops
+func (n *netAttachments) detachAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, id := range sortedKeys(n.containers) {
+		n.detachLocked(id)
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
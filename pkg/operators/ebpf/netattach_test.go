@@ -0,0 +1,230 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/tchandler"
+)
+
+// fakeAttacher records the containers attached, failing for the ones in
+// failAttach and failDetach
+type fakeAttacher struct {
+	attached   map[string]struct{}
+	failAttach map[string]struct{}
+	failDetach map[string]struct{}
+	detaches   int
+}
+
+func newFakeAttacher() *fakeAttacher {
+	return &fakeAttacher{
+		attached:   make(map[string]struct{}),
+		failAttach: make(map[string]struct{}),
+		failDetach: make(map[string]struct{}),
+	}
+}
+
+func (f *fakeAttacher) AttachContainer(container *containercollection.Container) error {
+	if _, ok := f.failAttach[container.Runtime.ContainerID]; ok {
+		return errors.New("interface not found")
+	}
+	f.attached[container.Runtime.ContainerID] = struct{}{}
+	return nil
+}
+
+func (f *fakeAttacher) DetachContainer(container *containercollection.Container) error {
+	f.detaches++
+	if _, ok := f.attached[container.Runtime.ContainerID]; !ok {
+		return errors.New("container is not attached")
+	}
+	delete(f.attached, container.Runtime.ContainerID)
+	if _, ok := f.failDetach[container.Runtime.ContainerID]; ok {
+		return errors.New("interface not found")
+	}
+	return nil
+}
+
+func newNetContainer(id string, netns uint64) *containercollection.Container {
+	c := &containercollection.Container{Netns: netns}
+	c.Runtime.ContainerID = id
+	c.Runtime.ContainerName = id
+	return c
+}
+
+func TestNetAttachments(t *testing.T) {
+	t.Parallel()
+
+	socketFilter := newFakeAttacher()
+	tc := newFakeAttacher()
+
+	n := newNetAttachments(logger.DefaultLogger())
+	n.add("socket_filter", socketFilter)
+	n.add("tc", tc)
+
+	// Two containers of a pod share the network namespace
+	c1 := newNetContainer("c1", 1000)
+	c2 := newNetContainer("c2", 1000)
+	c3 := newNetContainer("c3", 2000)
+
+	// The interface of c3 disappears before tc attaches to it
+	tc.failAttach["c3"] = struct{}{}
+
+	n.attach(c1)
+	n.attach(c2)
+	n.attach(c3)
+	// Attaching twice is a no-op
+	n.attach(c1)
+
+	require.Equal(t, map[string]struct{}{"c1": {}, "c2": {}, "c3": {}}, socketFilter.attached)
+	require.Equal(t, map[string]struct{}{"c1": {}, "c2": {}}, tc.attached)
+	require.Len(t, n.netns, 2)
+	require.Len(t, n.netns[1000], 2)
+	require.Equal(t, []string{"socket_filter"}, n.containers["c3"].programs)
+
+	// tc isn't detached from c3, it didn't attach to it
+	n.detach(c3)
+	require.Equal(t, 0, tc.detaches)
+	require.NotContains(t, n.netns, uint64(2000))
+
+	// Failing to detach doesn't prevent the cleanup
+	socketFilter.failDetach["c1"] = struct{}{}
+	n.detach(c1)
+	require.Equal(t, map[string]struct{}{"c2": {}}, socketFilter.attached)
+	require.Equal(t, map[string]struct{}{"c2": {}}, tc.attached)
+	require.Len(t, n.netns[1000], 1)
+
+	// Unknown containers are ignored
+	n.detach(newNetContainer("c4", 3000))
+
+	// A network namespace appearing mid-run
+	c5 := newNetContainer("c5", 3000)
+	n.attach(c5)
+	require.Contains(t, tc.attached, "c5")
+
+	n.detachAll()
+	require.Empty(t, socketFilter.attached)
+	require.Empty(t, tc.attached)
+	require.Empty(t, n.containers)
+	require.Empty(t, n.netns)
+}
+
+func TestResolveNetProgram(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		program           *ebpf.ProgramSpec
+		iface             string
+		expected          *netProgram
+		expectedErrString string
+	}
+
+	socketFilter := &ebpf.ProgramSpec{
+		Name:        "ig_trace_dns",
+		Type:        ebpf.SocketFilter,
+		SectionName: "socket1",
+	}
+	dropIngress := &ebpf.ProgramSpec{
+		Name:        "drop_ingress",
+		Type:        ebpf.SchedCLS,
+		SectionName: "classifier/ingress/drop",
+	}
+	drop := &ebpf.ProgramSpec{
+		Name:        "drop",
+		Type:        ebpf.SchedCLS,
+		SectionName: "classifier",
+	}
+	unset := &ebpf.ProgramSpec{
+		Name:        "unset",
+		Type:        ebpf.SchedCLS,
+		SectionName: "classifier",
+	}
+
+	tests := map[string]testCase{
+		"socket_filter": {
+			program:  socketFilter,
+			expected: &netProgram{interfaces: metadatav1.AttachInterfacesAll},
+		},
+		"section": {
+			program:  dropIngress,
+			expected: &netProgram{interfaces: metadatav1.AttachInterfacesPod, direction: tchandler.AttachmentDirectionIngress},
+		},
+		"section_iface": {
+			program: dropIngress,
+			iface:   "eth0",
+			expected: &netProgram{
+				interfaces: metadatav1.AttachInterfacesParam,
+				direction:  tchandler.AttachmentDirectionIngress,
+				iface:      "eth0",
+			},
+		},
+		"metadata": {
+			program: drop,
+			iface:   "eth0",
+			expected: &netProgram{
+				interfaces: metadatav1.AttachInterfacesParam,
+				direction:  tchandler.AttachmentDirectionEgress,
+				iface:      "eth0",
+			},
+		},
+		"metadata_missing_iface": {
+			program:           drop,
+			expectedErrString: "program \"drop\" attaches to the interface set by the iface param, which is empty",
+		},
+		"missing_direction": {
+			program:           unset,
+			expectedErrString: "program \"unset\" doesn't set its direction",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+attach:
+  ig_trace_dns:
+    interfaces: all
+  drop:
+    direction: egress
+    interfaces: param
+`)))
+			i := &ebpfInstance{
+				config:      config,
+				paramValues: map[string]string{ParamIface: test.iface},
+			}
+
+			np, err := i.resolveNetProgram(test.program)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				require.Equal(t, test.expected, np)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// skippedFieldPlaceholder is shown instead of the fields only filled by
+// skipped programs
+const skippedFieldPlaceholder = "-"
+
+// programGate returns why an optional program has to be skipped, or an empty
+// string if all its gates are open
+func programGate(program metadatav1.Program, kernel func() (gadgets.KernelVersion, error), paramValue func(string) (string, bool)) (string, error) {
+	if program.MinKernel != "" {
+		minKernel, err := gadgets.ParseKernelVersion(program.MinKernel)
+		if err != nil {
+			return "", fmt.Errorf("minKernel: %w", err)
+		}
+		current, err := kernel()
+		if err != nil {
+			return "", fmt.Errorf("getting kernel version: %w", err)
+		}
+		if current.Less(minKernel) {
+			return fmt.Sprintf("kernel %s is older than %s", current, minKernel), nil
+		}
+	}
+
+	if program.EnabledByParam != "" {
+		value, ok := paramValue(program.EnabledByParam)
+		if !ok {
+			return "", fmt.Errorf("enabledByParam: param %q not found", program.EnabledByParam)
+		}
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("enabledByParam: param %q: %w", program.EnabledByParam, err)
+		}
+		if !enabled {
+			return fmt.Sprintf("param %q is false", program.EnabledByParam), nil
+		}
+	}
+
+	return "", nil
+}
+
+// toggledPrograms returns the programs to skip, with the reason, because the
+// bool param attaching them is false or unset
+func toggledPrograms(ps map[string]*param, paramValue func(string) (string, bool)) (map[string]string, error) {
+	skipped := make(map[string]string)
+	for _, name := range sortedKeys(ps) {
+		p := ps[name]
+		if len(p.attaches) == 0 {
+			continue
+		}
+		value, _ := paramValue(p.Key)
+		enabled := false
+		if value != "" {
+			var err error
+			enabled, err = strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("param %q: %w", p.Key, err)
+			}
+		}
+		if enabled {
+			continue
+		}
+		for _, program := range p.attaches {
+			skipped[program] = fmt.Sprintf("param %q is false", p.Key)
+		}
+	}
+	return skipped, nil
+}
+
+// paramValue returns the value of the param with the given key, its default
+// value if it wasn't set
+func (i *ebpfInstance) paramValue(key string) (string, bool) {
+	if value, ok := i.paramValues[key]; ok {
+		return value, true
+	}
+	for _, p := range i.params {
+		if p.Key == key {
+			return p.DefaultValue, true
+		}
+	}
+	return "", false
+}
+
+// gatePrograms skips the optional programs whose gates are closed and the
+// programs attached by params that are false. It removes them from the
+// collection spec, so they are neither loaded nor attached.
+func (i *ebpfInstance) gatePrograms() error {
+	var kernel *gadgets.KernelVersion
+	currentKernel := func() (gadgets.KernelVersion, error) {
+		if kernel == nil {
+			v, err := gadgets.CurrentKernelVersion()
+			if err != nil {
+				return v, err
+			}
+			kernel = &v
+		}
+		return *kernel, nil
+	}
+
+	toggled, err := toggledPrograms(i.params, i.paramValue)
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(toggled) {
+		if _, ok := i.skippedPrograms[name]; ok {
+			// Linked to a tracer that isn't selected
+			continue
+		}
+		if _, ok := i.collectionSpec.Programs[name]; !ok {
+			return fmt.Errorf("program %q attached by a param not found", name)
+		}
+		i.logger.Debugf("Skipping program %q: %s", name, toggled[name])
+		i.skipProgram(name, toggled[name])
+	}
+
+	for _, name := range sortedKeys(i.collectionSpec.Programs) {
+		programConfig := i.config.Sub("programs." + name)
+		if programConfig == nil {
+			continue
+		}
+		var program metadatav1.Program
+		if err := programConfig.Unmarshal(&program); err != nil {
+			return fmt.Errorf("program %q: unmarshalling metadata: %w", name, err)
+		}
+		if !program.Optional {
+			continue
+		}
+		i.optionalPrograms[name] = struct{}{}
+
+		reason, err := programGate(program, currentKernel, i.paramValue)
+		if err != nil {
+			return fmt.Errorf("program %q: %w", name, err)
+		}
+		if reason != "" {
+			i.logger.Debugf("Skipping optional program %q: %s", name, reason)
+			i.skipProgram(name, reason)
+		}
+	}
+
+	i.markSkippedFields()
+	return nil
+}
+
+// skipProgram records an optional program as skipped and removes it from
+// the collection spec
+func (i *ebpfInstance) skipProgram(name, reason string) {
+	i.skippedPrograms[name] = reason
+	delete(i.collectionSpec.Programs, name)
+}
+
+// markSkippedFields shows the fields only filled by skipped programs as "-"
+// in columns output, instead of their zero value
+func (i *ebpfInstance) markSkippedFields() {
+	for _, s := range i.structs {
+		for _, field := range s.Fields {
+			if _, ok := i.skippedPrograms[field.FilledBy]; !ok || field.Attributes.ZeroAs != "" {
+				continue
+			}
+			field.Attributes.ZeroAs = skippedFieldPlaceholder
+		}
+	}
+}
+
+// failedOptionalProgram returns the optional program that made loading the
+// collection fail, if any
+func (i *ebpfInstance) failedOptionalProgram(err error) (string, bool) {
+	for _, name := range sortedKeys(i.optionalPrograms) {
+		if _, ok := i.collectionSpec.Programs[name]; !ok {
+			continue
+		}
+		if strings.Contains(err.Error(), "program "+name+":") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// reportSkippedPrograms tells the user which programs were skipped
+// and why
+func (i *ebpfInstance) reportSkippedPrograms() {
+	if len(i.skippedPrograms) == 0 {
+		return
+	}
+	skipped := make([]string, 0, len(i.skippedPrograms))
+	for _, name := range sortedKeys(i.skippedPrograms) {
+		skipped = append(skipped, fmt.Sprintf("%s (%s)", name, i.skippedPrograms[name]))
+	}
+	i.logger.Infof("Programs skipped: %s", strings.Join(skipped, ", "))
+}
@@ -0,0 +1,296 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestProgramGate(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		program           metadatav1.Program
+		kernel            string
+		params            map[string]string
+		expectedReason    string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"no_gates": {
+			program: metadatav1.Program{Optional: true},
+		},
+		"newer_kernel": {
+			program: metadatav1.Program{Optional: true, MinKernel: "5.15"},
+			kernel:  "6.1.0-18-amd64",
+		},
+		"same_kernel": {
+			program: metadatav1.Program{Optional: true, MinKernel: "5.15"},
+			kernel:  "5.15.0-91-generic",
+		},
+		// 5.4 is older than 5.15, even if "5.4" > "5.15" as strings
+		"older_kernel": {
+			program:        metadatav1.Program{Optional: true, MinKernel: "5.15"},
+			kernel:         "5.4.0-150-generic",
+			expectedReason: "kernel 5.4.0 is older than 5.15.0",
+		},
+		"older_patch": {
+			program:        metadatav1.Program{Optional: true, MinKernel: "5.10.2"},
+			kernel:         "5.10.1",
+			expectedReason: "kernel 5.10.1 is older than 5.10.2",
+		},
+		"invalid_min_kernel": {
+			program:           metadatav1.Program{Optional: true, MinKernel: "latest"},
+			kernel:            "6.1.0",
+			expectedErrString: "minKernel: invalid kernel version",
+		},
+		"param_enabled": {
+			program: metadatav1.Program{Optional: true, EnabledByParam: "details"},
+			params:  map[string]string{"details": "true"},
+		},
+		"param_disabled": {
+			program:        metadatav1.Program{Optional: true, EnabledByParam: "details"},
+			params:         map[string]string{"details": "false"},
+			expectedReason: "param \"details\" is false",
+		},
+		"param_disabled_with_f": {
+			program:        metadatav1.Program{Optional: true, EnabledByParam: "details"},
+			params:         map[string]string{"details": "F"},
+			expectedReason: "param \"details\" is false",
+		},
+		"param_invalid": {
+			program:           metadatav1.Program{Optional: true, EnabledByParam: "details"},
+			params:            map[string]string{"details": "maybe"},
+			expectedErrString: "enabledByParam: param \"details\"",
+		},
+		"param_not_found": {
+			program:           metadatav1.Program{Optional: true, EnabledByParam: "details"},
+			expectedErrString: "enabledByParam: param \"details\" not found",
+		},
+		"both_gates": {
+			program:        metadatav1.Program{Optional: true, MinKernel: "5.15", EnabledByParam: "details"},
+			kernel:         "6.1.0",
+			params:         map[string]string{"details": "false"},
+			expectedReason: "param \"details\" is false",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			kernel := func() (gadgets.KernelVersion, error) {
+				if test.kernel == "" {
+					return gadgets.KernelVersion{}, errors.New("unexpected kernel version lookup")
+				}
+				return gadgets.ParseKernelVersion(test.kernel)
+			}
+			paramValue := func(key string) (string, bool) {
+				value, ok := test.params[key]
+				return value, ok
+			}
+
+			reason, err := programGate(test.program, kernel, paramValue)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedReason, reason)
+		})
+	}
+}
+
+func TestGatePrograms(t *testing.T) {
+	t.Parallel()
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+programs:
+  details:
+    optional: true
+    enabledByParam: details
+  fexit_variant:
+    optional: true
+    minKernel: "1.0"
+  required: {}
+`)))
+
+	i := &ebpfInstance{
+		config: config,
+		logger: logger.DefaultLogger(),
+		collectionSpec: &ebpf.CollectionSpec{
+			Programs: map[string]*ebpf.ProgramSpec{
+				"details":       {Name: "details"},
+				"fexit_variant": {Name: "fexit_variant"},
+				"required":      {Name: "required"},
+			},
+		},
+		params: map[string]*param{
+			"gadget_details": {Param: &api.Param{Key: "details", DefaultValue: "false"}},
+		},
+		paramValues: map[string]string{},
+		structs: map[string]*Struct{
+			"event": {
+				Fields: []*Field{
+					{Field: metadatav1.Field{Name: "detail", FilledBy: "details"}},
+					{Field: metadatav1.Field{Name: "other", FilledBy: "details", Attributes: metadatav1.FieldAttributes{ZeroAs: "n/a"}}},
+					{Field: metadatav1.Field{Name: "pid"}},
+				},
+			},
+		},
+		optionalPrograms: make(map[string]struct{}),
+		skippedPrograms:  make(map[string]string),
+	}
+
+	require.NoError(t, i.gatePrograms())
+
+	require.Equal(t, map[string]struct{}{"details": {}, "fexit_variant": {}}, i.optionalPrograms)
+	require.Equal(t, map[string]string{"details": "param \"details\" is false"}, i.skippedPrograms)
+	require.NotContains(t, i.collectionSpec.Programs, "details")
+	require.Contains(t, i.collectionSpec.Programs, "fexit_variant")
+	require.Contains(t, i.collectionSpec.Programs, "required")
+
+	fields := i.structs["event"].Fields
+	require.Equal(t, skippedFieldPlaceholder, fields[0].Attributes.ZeroAs)
+	require.Equal(t, "n/a", fields[1].Attributes.ZeroAs)
+	require.Empty(t, fields[2].Attributes.ZeroAs)
+
+	// Only optional programs still in the spec make loading fail
+	name, ok := i.failedOptionalProgram(errors.New("program fexit_variant: load program: invalid argument"))
+	require.True(t, ok)
+	require.Equal(t, "fexit_variant", name)
+	_, ok = i.failedOptionalProgram(errors.New("program details: load program: invalid argument"))
+	require.False(t, ok)
+	_, ok = i.failedOptionalProgram(errors.New("program required: load program: invalid argument"))
+	require.False(t, ok)
+}
+
+func TestToggledPrograms(t *testing.T) {
+	t.Parallel()
+
+	ps := map[string]*param{
+		"capture_stacks": {
+			Param:    &api.Param{Key: "capture-stacks", DefaultValue: "false"},
+			attaches: []string{"stack_kprobe", "stack_kretprobe"},
+		},
+		"trace_exits": {
+			Param:    &api.Param{Key: "trace-exits"},
+			attaches: []string{"exit_tracepoint"},
+		},
+		"verbose": {
+			Param: &api.Param{Key: "verbose", DefaultValue: "false"},
+		},
+	}
+
+	type testCase struct {
+		values            map[string]string
+		expectedSkipped   map[string]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"defaults": {
+			values: map[string]string{},
+			expectedSkipped: map[string]string{
+				"stack_kprobe":    "param \"capture-stacks\" is false",
+				"stack_kretprobe": "param \"capture-stacks\" is false",
+				"exit_tracepoint": "param \"trace-exits\" is false",
+			},
+		},
+		"enabled": {
+			values:          map[string]string{"capture-stacks": "T", "trace-exits": "true"},
+			expectedSkipped: map[string]string{},
+		},
+		"one_enabled": {
+			values: map[string]string{"capture-stacks": "1", "trace-exits": "false"},
+			expectedSkipped: map[string]string{
+				"exit_tracepoint": "param \"trace-exits\" is false",
+			},
+		},
+		"not_a_bool": {
+			values:            map[string]string{"capture-stacks": "maybe"},
+			expectedErrString: "param \"capture-stacks\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			i := &ebpfInstance{params: ps, paramValues: test.values}
+			skipped, err := toggledPrograms(ps, i.paramValue)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSkipped, skipped)
+		})
+	}
+}
+
+func TestGateToggledPrograms(t *testing.T) {
+	t.Parallel()
+
+	i := &ebpfInstance{
+		config: viper.New(),
+		logger: logger.DefaultLogger(),
+		collectionSpec: &ebpf.CollectionSpec{
+			Programs: map[string]*ebpf.ProgramSpec{
+				"stack_kprobe": {Name: "stack_kprobe"},
+				"main":         {Name: "main"},
+			},
+		},
+		params: map[string]*param{
+			"capture_stacks": {
+				Param:    &api.Param{Key: "capture-stacks", DefaultValue: "false"},
+				attaches: []string{"stack_kprobe"},
+			},
+		},
+		paramValues: map[string]string{},
+		structs: map[string]*Struct{
+			"event": {
+				Fields: []*Field{
+					{Field: metadatav1.Field{Name: "kstack", FilledBy: "stack_kprobe"}},
+				},
+			},
+		},
+		optionalPrograms: make(map[string]struct{}),
+		skippedPrograms:  make(map[string]string),
+	}
+
+	require.NoError(t, i.gatePrograms())
+	require.Equal(t, map[string]string{"stack_kprobe": "param \"capture-stacks\" is false"}, i.skippedPrograms)
+	require.NotContains(t, i.collectionSpec.Programs, "stack_kprobe")
+	require.Contains(t, i.collectionSpec.Programs, "main")
+	require.Equal(t, skippedFieldPlaceholder, i.structs["event"].Fields[0].Attributes.ZeroAs)
+	require.Empty(t, i.optionalPrograms)
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"sort"
+
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// altKeyParams returns the params registered for the alternative keys of p,
+// letting users set it with any of them
+func (p *param) altKeyParams() api.Params {
+	res := make(api.Params, 0, len(p.altKeys))
+	for _, altKey := range p.altKeys {
+		res = append(res, &api.Param{
+			Key:            altKey,
+			Description:    fmt.Sprintf("Same as --%s", p.Key),
+			DefaultValue:   p.DefaultValue,
+			TypeHint:       p.TypeHint,
+			PossibleValues: p.PossibleValues,
+		})
+	}
+	return res
+}
+
+// resolveAltKeys returns values with the values given with the alternative
+// keys of the params moved to their key, so they set the same variable. It
+// fails if several spellings of a param were given different values.
+func resolveAltKeys(ps map[string]*param, values map[string]string) (map[string]string, error) {
+	res := make(map[string]string, len(values))
+	for k, v := range values {
+		res[k] = v
+	}
+
+	for _, p := range ps {
+		setWith := ""
+		if value, ok := values[p.Key]; ok && value != p.DefaultValue {
+			setWith = p.Key
+		}
+		for _, altKey := range p.altKeys {
+			value, ok := values[altKey]
+			if !ok || value == p.DefaultValue {
+				continue
+			}
+			if setWith != "" && res[p.Key] != value {
+				return nil, fmt.Errorf("param %q set to %q with %q and to %q with %q",
+					p.Key, params.MaskValue(res[p.Key], p.sensitive), setWith, params.MaskValue(value, p.sensitive), altKey)
+			}
+			setWith = altKey
+			res[p.Key] = value
+		}
+	}
+
+	return res, nil
+}
+
+// checkParamTemplates returns an error if a param backed by an eBPF variable
+// was given a container template. Its value is shared by all containers, so
+// it can't be resolved for each of them. values are the ones returned by
+// resolveAltKeys, by param key.
+func checkParamTemplates(ps map[string]*param, values map[string]string) error {
+	keys := make([]string, 0, len(ps))
+	for _, p := range ps {
+		if p.fromEbpf && containertemplate.IsTemplate(values[p.Key]) {
+			keys = append(keys, p.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	return fmt.Errorf("param %q: templates aren't supported, its value is shared by all containers", keys[0])
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestParamAliases(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("targ_limit", &btf.Int{Name: "unsigned int", Size: 4}),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  targ_limit:
+    key: limit
+    alias: l
+    altKeys: [max]
+    defaultValue: "10"
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "targ_limit"))
+
+	extra := i.ExtraParams(nil)
+	require.Len(t, extra, 2)
+	keys := map[string]string{}
+	for _, p := range extra {
+		keys[p.Key] = p.Alias
+	}
+	require.Equal(t, map[string]string{"limit": "l", "max": ""}, keys)
+
+	decode := func(values map[string]string) (any, error) {
+		values, err := resolveAltKeys(i.params, values)
+		if err != nil {
+			return nil, err
+		}
+		desc := apihelpers.ParamToParamDesc(i.params["targ_limit"].Param)
+		p := desc.ToParam()
+		parameters := params.Params{p}
+		if err := parameters.CopyFromMap(values, ""); err != nil {
+			return nil, err
+		}
+		return p.AsAny(), nil
+	}
+
+	byKey, err := decode(map[string]string{"limit": "42", "max": "10"})
+	require.NoError(t, err)
+	byAltKey, err := decode(map[string]string{"limit": "10", "max": "42"})
+	require.NoError(t, err)
+	require.Equal(t, uint32(42), byKey)
+	require.Equal(t, byKey, byAltKey)
+
+	// Both spellings can be given the same value
+	both, err := decode(map[string]string{"limit": "42", "max": "42"})
+	require.NoError(t, err)
+	require.Equal(t, byKey, both)
+
+	_, err = decode(map[string]string{"limit": "42", "max": "43"})
+	require.ErrorContains(t, err, "param \"limit\" set to \"42\" with \"limit\" and to \"43\" with \"max\"")
+}
+
+func TestCheckParamTemplates(t *testing.T) {
+	t.Parallel()
+
+	ps := map[string]*param{
+		// Renamed with key, values are given with it
+		"targ_pid": {Param: &api.Param{Key: "pid"}, fromEbpf: true, altKeys: []string{"process"}},
+		"operator": {Param: &api.Param{Key: "filter"}},
+	}
+	template := "{{ .Container.Pid }}"
+
+	require.NoError(t, checkParamTemplates(ps, map[string]string{"pid": "42", "filter": template}))
+
+	err := checkParamTemplates(ps, map[string]string{"pid": template})
+	require.EqualError(t, err, "param \"pid\": templates aren't supported, its value is shared by all containers")
+
+	// Values given with an alternative key are checked too
+	values, err := resolveAltKeys(ps, map[string]string{"process": template})
+	require.NoError(t, err)
+	require.ErrorContains(t, checkParamTemplates(ps, values), "param \"pid\"")
+}
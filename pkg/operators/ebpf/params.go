@@ -15,66 +15,21 @@
 package ebpfoperator
 
 import (
+	"encoding/binary"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/cilium/ebpf/btf"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
-func getTypeHint(typ btf.Type) params.TypeHint {
-	switch typedMember := typ.(type) {
-	case *btf.Int:
-		switch typedMember.Encoding {
-		case btf.Signed:
-			switch typedMember.Size {
-			case 1:
-				return params.TypeInt8
-			case 2:
-				return params.TypeInt16
-			case 4:
-				return params.TypeInt32
-			case 8:
-				return params.TypeInt64
-			}
-		case btf.Unsigned:
-			switch typedMember.Size {
-			case 1:
-				return params.TypeUint8
-			case 2:
-				return params.TypeUint16
-			case 4:
-				return params.TypeUint32
-			case 8:
-				return params.TypeUint64
-			}
-		case btf.Bool:
-			return params.TypeBool
-		case btf.Char:
-			return params.TypeUint8
-		}
-	case *btf.Float:
-		switch typedMember.Size {
-		case 4:
-			return params.TypeFloat32
-		case 8:
-			return params.TypeFloat64
-		}
-	case *btf.Typedef:
-		typ := btfhelpers.GetUnderlyingType(typedMember)
-		if typ == nil {
-			return params.TypeUnknown
-		}
-		return getTypeHint(typ)
-	case *btf.Volatile:
-		return getTypeHint(typedMember.Type)
-	}
-
-	return params.TypeUnknown
-}
-
 func (i *ebpfInstance) populateParam(t btf.Type, varName string) error {
 	if _, found := i.params[varName]; found {
 		i.logger.Debugf("param %q already defined, skipping", varName)
@@ -87,12 +42,11 @@ func (i *ebpfInstance) populateParam(t btf.Type, varName string) error {
 		return fmt.Errorf("no BTF type found for: %s: %w", varName, err)
 	}
 
-	btfConst, ok := btfVar.Type.(*btf.Const)
-	if !ok {
+	if isConst, _ := btfhelpers.Qualifiers(btfVar.Type); !isConst {
 		return fmt.Errorf("type for %s is not a constant, got %s", varName, btfVar.Type)
 	}
 
-	th := getTypeHint(btfConst.Type)
+	th := btfhelpers.GetTypeHint(btfVar.Type)
 
 	i.logger.Debugf("adding param %q (%v)", btfVar.Name, th)
 
@@ -101,6 +55,8 @@ func (i *ebpfInstance) populateParam(t btf.Type, varName string) error {
 		TypeHint: string(th),
 	}
 
+	var constraints metadatav1.EBPFParam
+
 	// Fill additional information from metadata
 	paramInfo := i.config.Sub("params." + varName)
 	if paramInfo == nil {
@@ -118,11 +74,198 @@ func (i *ebpfInstance) populateParam(t btf.Type, varName string) error {
 		if s := paramInfo.GetString("description"); s != "" {
 			newParam.Description = s
 		}
+		newParam.Alias = paramInfo.GetString("alias")
+		newParam.IsMandatory = paramInfo.GetBool("isMandatory")
+		newParam.PossibleValues = paramInfo.GetStringSlice("possibleValues")
+		constraints.Min = paramInfo.GetString("min")
+		constraints.Max = paramInfo.GetString("max")
+		constraints.Pattern = paramInfo.GetString("pattern")
+		constraints.TypeHint = params.TypeHint(paramInfo.GetString("typeHint"))
+		constraints.LPMMap = paramInfo.GetString("lpmMap")
+		constraints.TargetUnit = paramInfo.GetString("targetUnit")
+		constraints.TargetMap = paramInfo.GetString("targetMap")
+		constraints.KeyType = paramInfo.GetString("keyType")
+		constraints.List = paramInfo.GetBool("list")
+		constraints.Category = paramInfo.GetString("category")
+		constraints.Advanced = paramInfo.GetBool("advanced")
+		constraints.Hidden = paramInfo.GetBool("hidden")
+		constraints.Requires = paramInfo.GetStringSlice("requires")
+		constraints.ConflictsWith = paramInfo.GetStringSlice("conflictsWith")
+		constraints.Sensitive = paramInfo.GetBool("sensitive")
+		newParam.Tags = append(paramInfo.GetStringSlice("tags"), constraints.DisplayTags()...)
+	}
+
+	p := &param{
+		Param:     newParam,
+		fromEbpf:  true,
+		sensitive: constraints.Sensitive,
+	}
+	if paramInfo != nil {
+		p.altKeys = paramInfo.GetStringSlice("altKeys")
+		p.attaches = paramInfo.GetStringSlice("attaches")
+	}
+
+	enum, _ := btfhelpers.AsEnum(btfVar.Type)
+
+	switch {
+	case constraints.IsAddress():
+		// Addresses are written in network byte order, their variables
+		// only give their size
+		th = constraints.TypeHint
+		newParam.TypeHint = string(th)
+		p.address, err = i.newAddressParam(btfVar, th, constraints.LPMMap)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", varName, err)
+		}
+	case constraints.HasTargetMap():
+		// Values are inserted into a map, the variable gets their number
+		th = params.TypeString
+		newParam.TypeHint = string(th)
+		p.targetMap, err = newTargetMapParam(btfVar, &constraints)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", varName, err)
+		}
+	case constraints.HasUnit():
+		// Durations and sizes are converted to integers in the target unit
+		th = constraints.TypeHint
+		newParam.TypeHint = string(th)
+		p.unit, err = newUnitParam(btfVar, &constraints)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", varName, err)
+		}
+	case constraints.TypeHint == params.TypeBool || btfhelpers.IsBool(btfVar.Type):
+		// Bools take a single byte, whatever the integer type of their
+		// variable
+		if !btfhelpers.IsByte(btfVar.Type) {
+			size, _ := btf.Sizeof(btfVar.Type)
+			return fmt.Errorf("param %q: bool params need a 1-byte variable, %q is %d bytes wide", varName, varName, size)
+		}
+		th = params.TypeBool
+		newParam.TypeHint = string(th)
+	case constraints.List:
+		// Lists are written into an array, their number into a companion
+		// variable
+		th = params.TypeString
+		newParam.TypeHint = string(th)
+		p.array, err = i.newArrayParam(btfVar, &constraints)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", varName, err)
+		}
+	case enum != nil:
+		// Enums take the names of their enumerators, or their numbers
+		p.enum = enum
+		if len(newParam.PossibleValues) == 0 {
+			newParam.PossibleValues = btfhelpers.EnumNames(enum)
+		}
+		newParam.Tags = append(newParam.Tags, btfhelpers.EnumParamTags(enum)...)
+	default:
+		if n, ok := btfhelpers.CharArrayLen(btfVar.Type); ok && n > 0 {
+			// String params are backed by char arrays, holding the terminating NUL
+			constraints.MaxLength = n - 1
+			p.arrayLen = n
+		}
 	}
 
-	i.params[varName] = &param{
-		Param:    newParam,
-		fromEbpf: true,
+	var validator params.ParamValidator
+	if p.array != nil {
+		// Constraints apply to each value
+		validator = p.array.validate
+	} else {
+		validator, err = types.ParamValidator(&constraints, th)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", varName, err)
+		}
 	}
+	p.validator = validator
+	if p.unit != nil || p.targetMap != nil {
+		// Reject the values overflowing the variable or the map keys when
+		// parsing them
+		p.validator = func(value string) error {
+			if validator != nil {
+				if err := validator(value); err != nil {
+					return err
+				}
+			}
+			if p.unit != nil {
+				_, err := p.unit.encode(value)
+				return err
+			}
+			_, err := types.TargetMapKeys(&p.targetMap.EBPFParam, value)
+			return err
+		}
+	}
+
+	i.params[varName] = p
 	return nil
 }
+
+// checkMandatoryParams fails if mandatory params weren't given a value,
+// listing all of them so users can fix them at once
+func checkMandatoryParams(ps map[string]*param, paramMap map[string]*params.Param) error {
+	var missing []string
+	for name, p := range ps {
+		if p.IsMandatory && paramMap[name].String() == "" {
+			missing = append(missing, p.Key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("missing values for mandatory params: %s", strings.Join(missing, ", "))
+}
+
+// charArray returns value padded with NULs to fill the char array of n
+// elements backing a string param. The value was already checked to fit.
+func charArray(value string, n uint32) []byte {
+	b := make([]byte, n)
+	copy(b, value)
+	return b
+}
+
+// parseBoolParams rewrites the values of bool params given with any of the
+// spellings accepted by strconv.ParseBool, like 1 or T, as true or false
+func parseBoolParams(ps map[string]*param, values map[string]string) error {
+	for _, p := range ps {
+		if p.TypeHint != api.TypeBool {
+			continue
+		}
+		value, ok := values[p.Key]
+		if !ok || value == "" {
+			continue
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("param %q: expected a bool, got %q", p.Key, value)
+		}
+		values[p.Key] = strconv.FormatBool(b)
+	}
+	return nil
+}
+
+// boolByte returns the single byte written for a bool param
+func boolByte(b bool) []byte {
+	if b {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// nativeUint returns v in host byte order, truncated to an integer variable of
+// size bytes
+func nativeUint(v uint64, size int) ([]byte, error) {
+	b := make([]byte, size)
+	switch size {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		binary.NativeEndian.PutUint16(b, uint16(v))
+	case 4:
+		binary.NativeEndian.PutUint32(b, uint32(v))
+	case 8:
+		binary.NativeEndian.PutUint64(b, v)
+	default:
+		return nil, fmt.Errorf("integers of %d bytes not supported", size)
+	}
+	return b, nil
+}
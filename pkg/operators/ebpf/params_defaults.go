@@ -17,26 +17,29 @@ package ebpfoperator
 import (
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"unsafe"
 
-	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
 )
 
-var errMapNoBTFValue = errors.New("map spec does not contain a BTF Value")
-
-// fillParamDefaults will fill out i.Params' default values
+// fillParamDefaults will fill out i.Params' default values from the initial
+// values of their variables. Default values set in the metadata, like the ones
+// of GADGET_PARAM_DEFAULT, are kept: the initial value is only the default of
+// params without one.
 func (i *ebpfInstance) fillParamDefaults() error {
 	spec := i.collectionSpec
 	for name, spec := range spec.Maps {
 		if !strings.HasPrefix(name, ".rodata") {
 			continue
 		}
-		b, ds, err := dataSection(spec)
-		if errors.Is(err, errMapNoBTFValue) {
+		b, ds, err := btfhelpers.DataSection(spec)
+		if errors.Is(err, btfhelpers.ErrMapNoBTFValue) {
 			continue
 		}
 		if err != nil {
@@ -51,31 +54,32 @@ func (i *ebpfInstance) fillParamDefaults() error {
 				continue
 			}
 
-			if int(v.Offset+v.Size) > len(b) {
+			// Address variables hold addresses in binary, the variables of
+			// target map params the number of keys and arrays their number in
+			// another variable, keep the default value of the metadata.
+			// Mandatory params have none.
+			if param.address != nil || param.targetMap != nil || param.array != nil || param.IsMandatory {
 				continue
 			}
-
-			btfVar, ok := v.Type.(*btf.Var)
-			if !ok {
+			if param.DefaultValue != "" {
 				continue
 			}
 
-			btfConst, ok := btfVar.Type.(*btf.Const)
-			if !ok {
+			if int(v.Offset+v.Size) > len(b) {
 				continue
 			}
 
-			btfVolatile, ok := btfConst.Type.(*btf.Volatile)
+			btfVar, ok := v.Type.(*btf.Var)
 			if !ok {
 				continue
 			}
 
-			vtype := btfVolatile.Type
-
-			if typedef, ok := vtype.(*btf.Typedef); ok {
-				vtype = btfhelpers.GetUnderlyingType(typedef)
+			if isConst, isVolatile := btfhelpers.Qualifiers(btfVar.Type); !isConst || !isVolatile {
+				continue
 			}
 
+			vtype := btfhelpers.GetUnderlyingType(btfVar.Type)
+
 			bytes := b[v.Offset : v.Offset+v.Size]
 
 			var defaultValue string
@@ -105,44 +109,31 @@ func (i *ebpfInstance) fillParamDefaults() error {
 						defaultValue = fmt.Sprintf("%d", *(*uint64)(unsafe.Pointer(&bytes[0])))
 					}
 				}
-				if t.Encoding&btf.Bool != 0 {
+				if t.Encoding&btf.Bool != 0 || param.TypeHint == api.TypeBool {
 					if defaultValue == "0" {
 						defaultValue = "false"
 					} else {
 						defaultValue = "true"
 					}
 				}
+			case *btf.Enum:
+				defaultValue = decodeEnum(t, bytes)
+			case *btf.Array:
+				if _, ok := btfhelpers.CharArrayLen(t); ok {
+					defaultValue, _, _ = strings.Cut(string(bytes), "\x00")
+				}
 			}
 
-			i.gadgetCtx.Logger().Debugf("default value for param %q set to %q (%.2X), type was %T", vname, defaultValue, bytes, vtype)
+			if param.unit != nil {
+				if n, err := strconv.ParseInt(defaultValue, 10, 64); err == nil {
+					defaultValue = types.FromTargetUnit(&param.unit.EBPFParam, n)
+				}
+			}
+
+			i.logger.Debugf("default value for param %q set to %q (%.2X), type was %T", vname, defaultValue, bytes, vtype)
 
 			param.DefaultValue = defaultValue
 		}
 	}
 	return nil
 }
-
-// dataSection returns the contents and BTF Datasec descriptor of the spec.
-// borrowed from cilium/ebpf
-func dataSection(ms *ebpf.MapSpec) ([]byte, *btf.Datasec, error) {
-	if ms.Value == nil {
-		return nil, nil, errMapNoBTFValue
-	}
-
-	ds, ok := ms.Value.(*btf.Datasec)
-	if !ok {
-		return nil, nil, fmt.Errorf("map value BTF is a %T, not a *btf.Datasec", ms.Value)
-	}
-
-	if n := len(ms.Contents); n != 1 {
-		return nil, nil, fmt.Errorf("expected one key, found %d", n)
-	}
-
-	kv := ms.Contents[0]
-	value, ok := kv.Value.([]byte)
-	if !ok {
-		return nil, nil, fmt.Errorf("value at first map key is %T, not []byte", kv.Value)
-	}
-
-	return value, ds, nil
-}
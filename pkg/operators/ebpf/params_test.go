@@ -0,0 +1,503 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestParamConstraints(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		key               string
+		value             string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"in_range": {
+			key:   "interval",
+			value: "60",
+		},
+		"below_min": {
+			key:               "interval",
+			value:             "0",
+			expectedErrString: "invalid value \"0\" as \"interval\": must be between 1 and 3600",
+		},
+		"above_max": {
+			key:               "interval",
+			value:             "3601",
+			expectedErrString: "must be between 1 and 3600",
+		},
+		"matching_pattern": {
+			key:   "comm",
+			value: "nginx",
+		},
+		"not_matching_pattern": {
+			key:               "comm",
+			value:             "NGINX",
+			expectedErrString: "invalid value \"NGINX\" as \"comm\": must match \"^[a-z]+$\"",
+		},
+		"string_at_limit": {
+			key:   "comm",
+			value: "abcdefghijklmno",
+		},
+		"string_over_limit": {
+			key:               "comm",
+			value:             "abcdefghijklmnop",
+			expectedErrString: "invalid value \"abcdefghijklmnop\" as \"comm\": must be at most 15 bytes long, got 16",
+		},
+		"multi_byte_string_over_limit": {
+			key:               "comm",
+			value:             "ééééééééé",
+			expectedErrString: "multi-byte characters take several bytes",
+		},
+		"possible_value": {
+			key:   "level",
+			value: "2",
+		},
+		"impossible_value": {
+			key:               "level",
+			value:             "4",
+			expectedErrString: "invalid value \"4\" as \"level\": valid values are: 1, 2, 3",
+		},
+	}
+
+	u32 := &btf.Int{Name: "unsigned int", Size: 4}
+	spec := newTestSpec(t,
+		constVar("gadget_interval", u32),
+		constVar("gadget_level", &btf.Int{Name: "unsigned char", Size: 1}),
+		constVar("gadget_comm", &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Index: u32, Nelems: 16}),
+	)
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  gadget_interval:
+    key: interval
+    defaultValue: "10"
+    min: 1
+    max: 3600
+  gadget_level:
+    key: level
+    defaultValue: "1"
+    possibleValues: ["1", "2", "3"]
+  gadget_comm:
+    key: comm
+    pattern: ^[a-z]+$
+`)))
+
+			i := &ebpfInstance{
+				config:         config,
+				logger:         logger.DefaultLogger(),
+				collectionSpec: spec,
+				params:         make(map[string]*param),
+			}
+			for _, varName := range []string{"gadget_interval", "gadget_level", "gadget_comm"} {
+				require.NoError(t, i.populateParam(nil, varName))
+			}
+
+			parameters := params.Params{}
+			for _, p := range i.params {
+				desc := apihelpers.ParamToParamDesc(p.Param)
+				desc.Validator = p.validator
+				parameters = append(parameters, desc.ToParam())
+			}
+
+			err := parameters.CopyFromMap(map[string]string{test.key: test.value}, "")
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestParamConstraintsMismatch(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		&btf.Var{Name: "gadget_enabled", Type: &btf.Const{Type: &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}}},
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  gadget_enabled:
+    key: enabled
+    max: 1
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	err := i.populateParam(nil, "gadget_enabled")
+	require.ErrorContains(t, err, "param \"gadget_enabled\": min and max are only supported by numeric params")
+}
+
+func TestCharArray(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []byte("nginx\x00\x00\x00"), charArray("nginx", 8))
+	require.Equal(t, []byte("abcdefg\x00"), charArray("abcdefg", 8))
+	require.Equal(t, make([]byte, 8), charArray("", 8))
+}
+
+func TestBoolParams(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("enable_bool", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("enable_char", &btf.Int{Name: "char", Size: 1, Encoding: btf.Char}),
+		constVar("enable_u8", &btf.Int{Name: "unsigned char", Size: 1}),
+		constVar("enable_u32", &btf.Int{Name: "unsigned int", Size: 4}),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  enable_u8:
+    key: enable_u8
+    typeHint: bool
+  enable_u32:
+    key: enable_u32
+    typeHint: bool
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	for _, name := range []string{"enable_bool", "enable_char", "enable_u8"} {
+		require.NoError(t, i.populateParam(nil, name))
+		require.Equal(t, string(params.TypeBool), i.params[name].TypeHint, name)
+	}
+	err := i.populateParam(nil, "enable_u32")
+	require.ErrorContains(t, err, "param \"enable_u32\": bool params need a 1-byte variable, \"enable_u32\" is 4 bytes wide")
+
+	desc := apihelpers.ParamToParamDesc(i.params["enable_bool"].Param)
+	desc.Validator = i.params["enable_bool"].validator
+
+	for value, expected := range map[string]bool{
+		"true": true, "True": true, "T": true, "1": true,
+		"false": false, "FALSE": false, "f": false, "0": false,
+	} {
+		values := map[string]string{"enable_bool": value}
+		require.NoError(t, parseBoolParams(i.params, values), value)
+		p := desc.ToParam()
+		require.NoError(t, p.Set(values["enable_bool"]), value)
+		require.Equal(t, expected, p.AsBool(), value)
+		require.Len(t, boolByte(p.AsBool()), 1)
+	}
+	for _, value := range []string{"yes", "no", "2"} {
+		require.Error(t, parseBoolParams(i.params, map[string]string{"enable_bool": value}), value)
+	}
+
+	require.Equal(t, []byte{1}, boolByte(true))
+	require.Equal(t, []byte{0}, boolByte(false))
+}
+
+func TestEnumParams(t *testing.T) {
+	t.Parallel()
+
+	// enum capture_mode { CAPTURE_NONE, CAPTURE_HEADERS, CAPTURE_FULL = 4 };
+	captureMode := &btf.Enum{
+		Name: "capture_mode",
+		Size: 4,
+		Values: []btf.EnumValue{
+			{Name: "CAPTURE_NONE", Value: 0},
+			{Name: "CAPTURE_HEADERS", Value: 1},
+			{Name: "CAPTURE_FULL", Value: 4},
+		},
+	}
+	spec := newTestSpec(t,
+		constVar("mode", captureMode),
+	)
+
+	i := &ebpfInstance{
+		config:         viper.New(),
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "mode"))
+	p := i.params["mode"]
+	require.Equal(t, []string{"CAPTURE_NONE", "CAPTURE_HEADERS", "CAPTURE_FULL"}, p.PossibleValues)
+	require.NotNil(t, p.enum)
+
+	desc := apihelpers.ParamToParamDesc(p.Param)
+	desc.Validator = p.validator
+
+	for value, expected := range map[string]uint32{
+		"CAPTURE_NONE": 0, "CAPTURE_HEADERS": 1, "CAPTURE_FULL": 4,
+		"0": 0, "1": 1, "4": 4, "0x4": 4,
+	} {
+		param := desc.ToParam()
+		require.NoError(t, param.Set(value), value)
+		b, err := encodeEnum(p.enum, param.String())
+		require.NoError(t, err, value)
+		require.Equal(t, expected, binary.NativeEndian.Uint32(b), value)
+	}
+
+	for _, value := range []string{"CAPTURE_ALL", "capture_full", "2", "-1", ""} {
+		require.Error(t, desc.ToParam().Set(value), value)
+		_, err := encodeEnum(p.enum, value)
+		require.ErrorContains(t, err, "isn't a value of enum capture_mode", value)
+	}
+
+	require.Equal(t, "CAPTURE_FULL", decodeEnum(p.enum, binary.NativeEndian.AppendUint32(nil, 4)))
+	require.Equal(t, "3", decodeEnum(p.enum, binary.NativeEndian.AppendUint32(nil, 3)))
+}
+
+func TestMandatoryParams(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "unsigned int", Size: 4}
+	spec := newTestSpec(t, constVar("targ_pid", u32), constVar("targ_uid", u32), constVar("targ_limit", u32))
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  targ_pid:
+    key: pid
+    isMandatory: true
+  targ_uid:
+    key: uid
+    isMandatory: true
+  targ_limit:
+    key: limit
+    defaultValue: "10"
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	for _, name := range []string{"targ_pid", "targ_uid", "targ_limit"} {
+		require.NoError(t, i.populateParam(nil, name))
+	}
+	require.True(t, i.params["targ_pid"].IsMandatory)
+	require.False(t, i.params["targ_limit"].IsMandatory)
+
+	parse := func(values map[string]string) error {
+		parameters := params.Params{}
+		paramMap := make(map[string]*params.Param)
+		for name, p := range i.params {
+			param := apihelpers.ParamToParamDesc(p.Param).ToParam()
+			paramMap[name] = param
+			parameters = append(parameters, param)
+		}
+		if err := parameters.CopyFromMap(values, ""); err != nil {
+			return err
+		}
+		return checkMandatoryParams(i.params, paramMap)
+	}
+
+	// All the missing params are reported at once
+	err := parse(map[string]string{"limit": "20"})
+	require.EqualError(t, err, "missing values for mandatory params: pid, uid")
+
+	err = parse(map[string]string{"pid": "42"})
+	require.EqualError(t, err, "missing values for mandatory params: uid")
+
+	require.NoError(t, parse(map[string]string{"pid": "42", "uid": "1000"}))
+}
+
+func TestParamDisplayTags(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("targ_debug", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  targ_debug:
+    key: debug
+    category: output
+    advanced: true
+    hidden: true
+    requires: [verbose]
+    conflictsWith: [quiet]
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "targ_debug"))
+
+	desc := apihelpers.ParamToParamDesc(i.params["targ_debug"].Param)
+	require.Equal(t, "output", desc.Category())
+	require.True(t, desc.IsAdvanced())
+	require.True(t, desc.IsHidden())
+	require.Equal(t, []string{"verbose"}, desc.Requires())
+	require.Equal(t, []string{"quiet"}, desc.ConflictsWith())
+}
+
+func TestSensitiveParams(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "__u32", Size: 4}
+	spec := newTestSpec(t,
+		&btf.Var{
+			Name: "targ_user",
+			Type: &btf.Const{Type: &btf.Volatile{Type: &btf.Array{
+				Type:   &btf.Int{Name: "char", Size: 1, Encoding: btf.Char},
+				Index:  u32,
+				Nelems: 16,
+			}}},
+			Linkage: btf.GlobalVar,
+		},
+		&btf.Var{
+			Name: "targ_mode",
+			Type: &btf.Const{Type: &btf.Volatile{Type: &btf.Enum{
+				Name:   "capture_mode",
+				Size:   4,
+				Values: []btf.EnumValue{{Name: "CAPTURE_NONE", Value: 0}, {Name: "CAPTURE_FULL", Value: 1}},
+			}}},
+			Linkage: btf.GlobalVar,
+		},
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  targ_user:
+    key: user
+    pattern: ^[a-z]+$
+    sensitive: true
+  targ_mode:
+    key: mode
+    sensitive: true
+`)))
+
+	var logs bytes.Buffer
+	l := log.New()
+	l.SetOutput(&logs)
+	l.SetLevel(log.DebugLevel)
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.NewFromGenericLogger(l),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "targ_user"))
+	require.NoError(t, i.populateParam(nil, "targ_mode"))
+	p := i.params["targ_user"]
+	require.Contains(t, p.Tags, params.TagSensitive)
+
+	desc := apihelpers.ParamToParamDesc(p.Param)
+	desc.Validator = p.validator
+
+	// Validation errors tell which param failed, not its value
+	err := desc.ToParam().Set("Alice")
+	require.ErrorContains(t, err, "invalid value \"***\" as \"user\"")
+	require.NotContains(t, err.Error(), "Alice")
+
+	// Values are masked when applied
+	value := desc.ToParam()
+	require.NoError(t, value.Set("alice"))
+	consts := make(map[string]any)
+	require.NoError(t, i.rewriteParam("targ_user", p, value, consts, nil, nil))
+	require.Equal(t, charArray("alice", 16), consts["targ_user"])
+	require.Contains(t, logs.String(), "setting param value \\\"targ_user\\\" = ***")
+	require.NotContains(t, logs.String(), "alice")
+
+	mode := (&params.ParamDesc{Key: "mode", DefaultValue: "CAPTURE_ALL"}).ToParam()
+	err = i.rewriteParam("targ_mode", i.params["targ_mode"], mode, consts, nil, nil)
+	require.EqualError(t, err, "param \"mode\": \"***\" isn't a value of enum capture_mode")
+
+	// So are conflicting values given with alternative keys
+	p.altKeys = []string{"username"}
+	_, err = resolveAltKeys(map[string]*param{"targ_user": p}, map[string]string{"user": "alice", "username": "bob"})
+	require.EqualError(t, err, "param \"user\" set to \"***\" with \"user\" and to \"***\" with \"username\"")
+}
+
+func TestFillParamDefaults(t *testing.T) {
+	t.Parallel()
+
+	u16 := &btf.Const{Type: &btf.Volatile{Type: &btf.Int{Name: "unsigned short", Size: 2}}}
+	port := &btf.Var{Name: "targ_port", Type: u16, Linkage: btf.GlobalVar}
+	limit := &btf.Var{Name: "targ_limit", Type: u16, Linkage: btf.GlobalVar}
+
+	contents := binary.NativeEndian.AppendUint16(nil, 0)
+	contents = binary.NativeEndian.AppendUint16(contents, 10)
+
+	i := &ebpfInstance{
+		logger: logger.DefaultLogger(),
+		collectionSpec: &ebpf.CollectionSpec{
+			Maps: map[string]*ebpf.MapSpec{
+				".rodata": {
+					Name: ".rodata",
+					Value: &btf.Datasec{
+						Name: ".rodata",
+						Vars: []btf.VarSecinfo{
+							{Type: port, Offset: 0, Size: 2},
+							{Type: limit, Offset: 2, Size: 2},
+						},
+					},
+					Contents: []ebpf.MapKV{{Key: uint32(0), Value: contents}},
+				},
+			},
+		},
+		params: map[string]*param{
+			// Set by GADGET_PARAM_DEFAULT, different from the initial value
+			"targ_port":  {Param: &api.Param{Key: "port", DefaultValue: "443"}},
+			"targ_limit": {Param: &api.Param{Key: "limit"}},
+		},
+	}
+
+	require.NoError(t, i.fillParamDefaults())
+	require.Equal(t, "443", i.params["targ_port"].DefaultValue)
+	require.Equal(t, "10", i.params["targ_limit"].DefaultValue)
+}
@@ -0,0 +1,383 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	jsonformatter "github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/formatters/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/histogram"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const (
+	// maxProfilerSlots is the number of slots needed to bucket any __u64 value
+	maxProfilerSlots = 64
+
+	histogramFieldName = "histogram"
+	bucketsFieldName   = "buckets"
+
+	defaultProfilerInterval = "1s"
+)
+
+type Profiler struct {
+	metadatav1.Profiler
+
+	ds datasource.DataSource
+	// accessor holds the fields of the key struct, nil without it
+	accessor       datasource.FieldAccessor
+	histogramField datasource.FieldAccessor
+	bucketsField   datasource.FieldAccessor
+
+	keySize   uint32
+	valueSize uint32
+	slots     int
+}
+
+// profilerEntry is the histogram of a key of the map
+type profilerEntry struct {
+	key   []byte
+	slots []uint64
+	total uint64
+}
+
+func validateProfilerMap(profilerMap *ebpf.MapSpec) (int, error) {
+	switch profilerMap.Type {
+	case ebpf.Array, ebpf.PerCPUArray, ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	default:
+		return 0, fmt.Errorf("map %q has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: %s",
+			profilerMap.Name, profilerMap.Type.String())
+	}
+	if profilerMap.Value == nil {
+		return 0, fmt.Errorf("map %q does not have BTF information for its values", profilerMap.Name)
+	}
+
+	// The value is an array of __u64 slots, or a struct with such an array as
+	// its only member
+	typ := btf.UnderlyingType(profilerMap.Value)
+	if s, ok := typ.(*btf.Struct); ok && len(s.Members) == 1 {
+		typ = btf.UnderlyingType(s.Members[0].Type)
+	}
+	array, ok := typ.(*btf.Array)
+	if !ok {
+		return 0, fmt.Errorf("map %q value is %q, expected an array of __u64 slots", profilerMap.Name, profilerMap.Value.TypeName())
+	}
+	if slot, ok := btf.UnderlyingType(array.Type).(*btf.Int); !ok || slot.Size != 8 || slot.Encoding == btf.Signed {
+		return 0, fmt.Errorf("map %q slots are %q, expected __u64", profilerMap.Name, array.Type.TypeName())
+	}
+	if array.Nelems == 0 || array.Nelems > maxProfilerSlots {
+		return 0, fmt.Errorf("map %q value has %d slots, expected between 1 and %d", profilerMap.Name, array.Nelems, maxProfilerSlots)
+	}
+	return int(array.Nelems), nil
+}
+
+func (i *ebpfInstance) populateProfiler(t btf.Type, varName string) error {
+	i.logger.Debugf("populating profiler %q", varName)
+
+	parts := strings.Split(varName, typeSplitter)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid profiler info: %q", varName)
+	}
+
+	name := parts[0]
+	mapName := parts[1]
+
+	i.logger.Debugf("> name       : %q", name)
+	i.logger.Debugf("> map name   : %q", mapName)
+
+	profilerConfig := i.config.Sub("profilers." + name)
+	if profilerConfig != nil {
+		if configMapName := profilerConfig.GetString("mapName"); configMapName != "" && configMapName != mapName {
+			return fmt.Errorf("validating profiler %q: mapName %q in eBPF program does not match %q from metadata file",
+				name, configMapName, mapName)
+		}
+		i.logger.Debugf("> successfully validated with metadata")
+	}
+
+	if _, ok := i.profilers[name]; ok {
+		i.logger.Debugf("profiler %q already defined, skipping", name)
+		return nil
+	}
+
+	profilerMap, ok := i.collectionSpec.Maps[mapName]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", mapName)
+	}
+
+	slots, err := validateProfilerMap(profilerMap)
+	if err != nil {
+		return fmt.Errorf("profiler map is invalid: %w", err)
+	}
+
+	i.logger.Debugf("adding profiler %q", name)
+	profiler := &Profiler{
+		Profiler: metadatav1.Profiler{
+			MapName: mapName,
+		},
+		keySize:   profilerMap.KeySize,
+		valueSize: profilerMap.ValueSize,
+		slots:     slots,
+	}
+	if profilerConfig != nil {
+		profiler.Unit = metadatav1.Unit(profilerConfig.GetString("unit"))
+		profiler.Interval = profilerConfig.GetString("interval")
+	}
+
+	keyStruct, ok := btf.UnderlyingType(profilerMap.Key).(*btf.Struct)
+	if ok {
+		if profilerConfig != nil {
+			if configKeyStructName := profilerConfig.GetString("keyStructName"); configKeyStructName != "" && configKeyStructName != keyStruct.Name {
+				return fmt.Errorf("validating profiler %q: keyStructName %q in eBPF program does not match %q from metadata file",
+					name, configKeyStructName, keyStruct.Name)
+			}
+		}
+		profiler.KeyStructName = keyStruct.Name
+	}
+	i.profilers[name] = profiler
+
+	if keyStruct == nil {
+		return nil
+	}
+	err = i.populateStructDirect(keyStruct)
+	if err != nil {
+		return fmt.Errorf("populating struct %q for profiler %q: %w", keyStruct.Name, name, err)
+	}
+
+	return nil
+}
+
+// addHistogramFields adds the fields the histograms are rendered to: the
+// histogram field is printed below the keys in columns output and the buckets
+// field is the array of buckets for JSON output.
+func (p *Profiler) addHistogramFields() error {
+	var err error
+	p.histogramField, err = p.ds.AddField(histogramFieldName, api.Kind_String,
+		datasource.WithAnnotations(map[string]string{
+			datasource.ExtraLinesAnnotation:   "true",
+			jsonformatter.SkipFieldAnnotation: "true",
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("adding field %q: %w", histogramFieldName, err)
+	}
+	p.bucketsField, err = p.ds.AddField(bucketsFieldName, api.Kind_String,
+		datasource.WithFlags(datasource.FieldFlagHidden),
+		datasource.WithAnnotations(map[string]string{
+			jsonformatter.RawAnnotation: "true",
+			"description":               "Buckets of the histogram",
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("adding field %q: %w", bucketsFieldName, err)
+	}
+	return nil
+}
+
+// sumSlots adds the slots of a value of the map to sums
+func (p *Profiler) sumSlots(sums []uint64, value []byte) {
+	if len(value) < p.slots*8 {
+		return
+	}
+	for idx := range sums {
+		sums[idx] += p.ds.ByteOrder().Uint64(value[idx*8:])
+	}
+}
+
+// collect reads the histograms of the map and resets them, so each interval
+// only reports the values seen since the last one. It returns the maxRows
+// histograms with the most values, or all of them if maxRows is 0.
+func (p *Profiler) collect(m statsMap, possibleCPUs int, maxRows int) ([]profilerEntry, error) {
+	perCPU := false
+	isArray := false
+	switch m.Type() {
+	case ebpf.PerCPUArray:
+		perCPU, isArray = true, true
+	case ebpf.Array:
+		isArray = true
+	case ebpf.PerCPUHash, ebpf.LRUCPUHash:
+		perCPU = true
+	}
+
+	var keys [][]byte
+	// An untyped nil is needed to get the first key
+	var key any
+	for {
+		var nextKey []byte
+		err := m.NextKey(key, &nextKey)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iterating map %q: %w", p.MapName, err)
+		}
+		keys = append(keys, nextKey)
+		key = nextKey
+	}
+
+	var zero any
+	if isArray {
+		// Entries of arrays can't be deleted, they are zeroed instead
+		if perCPU {
+			zeroes := make([][]byte, possibleCPUs)
+			for idx := range zeroes {
+				zeroes[idx] = make([]byte, p.valueSize)
+			}
+			zero = zeroes
+		} else {
+			zero = make([]byte, p.valueSize)
+		}
+	}
+
+	entries := make([]profilerEntry, 0, len(keys))
+	for _, key := range keys {
+		entry := profilerEntry{key: key, slots: make([]uint64, p.slots)}
+		if perCPU {
+			values := make([][]byte, possibleCPUs)
+			if err := m.Lookup(key, values); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", p.MapName, err)
+			}
+			for _, value := range values {
+				p.sumSlots(entry.slots, value)
+			}
+		} else {
+			var value []byte
+			if err := m.Lookup(key, &value); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", p.MapName, err)
+			}
+			p.sumSlots(entry.slots, value)
+		}
+
+		if isArray {
+			if err := m.Update(key, zero, ebpf.UpdateExist); err != nil {
+				return nil, fmt.Errorf("resetting key in map %q: %w", p.MapName, err)
+			}
+		} else if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, fmt.Errorf("deleting key from map %q: %w", p.MapName, err)
+		}
+
+		for _, count := range entry.slots {
+			entry.total += count
+		}
+		if entry.total == 0 {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].total > entries[j].total
+	})
+	if maxRows > 0 && len(entries) > maxRows {
+		entries = entries[:maxRows]
+	}
+	return entries, nil
+}
+
+// newHistogram returns the histogram of the slots of an entry
+func (p *Profiler) newHistogram(slots []uint64) *histogram.Histogram {
+	unit := histogram.Unit(p.Unit)
+	if unit == "" {
+		unit = "value"
+	}
+	return &histogram.Histogram{
+		Unit:      unit,
+		Intervals: histogram.NewIntervalsFromExp2Slots(slots),
+	}
+}
+
+func (p *Profiler) emit(entries []profilerEntry) error {
+	pArray, err := p.ds.NewPacketArray()
+	if err != nil {
+		return fmt.Errorf("creating new packet: %w", err)
+	}
+	for idx, entry := range entries {
+		data := pArray.New()
+		if err := p.setEntry(data, entry); err != nil {
+			pArray.Release(data)
+			p.ds.Release(pArray)
+			return fmt.Errorf("setting data element %d: %w", idx, err)
+		}
+		pArray.Append(data)
+	}
+	return p.ds.EmitAndRelease(pArray)
+}
+
+func (p *Profiler) setEntry(data datasource.Data, entry profilerEntry) error {
+	if p.accessor != nil {
+		if err := p.accessor.Set(data, entry.key); err != nil {
+			return err
+		}
+	}
+	h := p.newHistogram(entry.slots)
+	if err := p.histogramField.PutString(data, h.String()); err != nil {
+		return err
+	}
+	buckets, err := json.Marshal(h.Intervals)
+	if err != nil {
+		return fmt.Errorf("marshaling buckets: %w", err)
+	}
+	return p.bucketsField.PutString(data, string(buckets))
+}
+
+func (i *ebpfInstance) runProfiler(gadgetCtx operators.GadgetContext, name string, profiler *Profiler, interval time.Duration, maxRows int) {
+	m, ok := i.collection.Maps[profiler.MapName]
+	if !ok {
+		i.logger.Errorf("profiler %q: map %q not found", name, profiler.MapName)
+		return
+	}
+
+	possibleCPUs, err := ebpf.PossibleCPU()
+	if err != nil {
+		i.logger.Errorf("profiler %q: getting number of possible CPUs: %v", name, err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gadgetCtx.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		entries, err := profiler.collect(m, possibleCPUs, maxRows)
+		if err != nil {
+			i.logger.Errorf("profiler %q: %v", name, err)
+			continue
+		}
+		if err := profiler.emit(entries); err != nil {
+			i.logger.Errorf("profiler %q: emitting data: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func histSlots(slots ...uint64) []byte {
+	b := make([]byte, 8*len(slots))
+	for idx, slot := range slots {
+		binary.NativeEndian.PutUint64(b[idx*8:], slot)
+	}
+	return b
+}
+
+func newLatencyProfiler(t *testing.T, unit metadatav1.Unit) *Profiler {
+	ds, err := datasource.New(datasource.TypeArray, "latency")
+	require.NoError(t, err)
+
+	p := &Profiler{
+		Profiler:  metadatav1.Profiler{MapName: "hists", Unit: unit},
+		ds:        ds,
+		keySize:   4,
+		valueSize: 32,
+		slots:     4,
+	}
+	require.NoError(t, p.addHistogramFields())
+	return p
+}
+
+func TestProfilerCollect(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mapType  ebpf.MapType
+		maxRows  int
+		entries  map[string][][]byte
+		expected []profilerEntry
+	}
+
+	tests := map[string]testCase{
+		"hash": {
+			mapType: ebpf.Hash,
+			entries: map[string][][]byte{
+				"sda": {histSlots(0, 1, 2, 0)},
+				"sdb": {histSlots(5, 0, 0, 1)},
+				"sdc": {histSlots(0, 0, 0, 0)},
+			},
+			expected: []profilerEntry{
+				{key: []byte("sdb"), slots: []uint64{5, 0, 0, 1}, total: 6},
+				{key: []byte("sda"), slots: []uint64{0, 1, 2, 0}, total: 3},
+			},
+		},
+		"max_rows": {
+			mapType: ebpf.LRUHash,
+			maxRows: 1,
+			entries: map[string][][]byte{
+				"sda": {histSlots(0, 1, 2, 0)},
+				"sdb": {histSlots(5, 0, 0, 1)},
+			},
+			expected: []profilerEntry{
+				{key: []byte("sdb"), slots: []uint64{5, 0, 0, 1}, total: 6},
+			},
+		},
+		"per_cpu_array": {
+			mapType: ebpf.PerCPUArray,
+			entries: map[string][][]byte{
+				"\x00\x00\x00\x00": {histSlots(1, 2, 0, 0), nil, histSlots(0, 3, 1, 0)},
+			},
+			expected: []profilerEntry{
+				{key: []byte("\x00\x00\x00\x00"), slots: []uint64{1, 5, 1, 0}, total: 7},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := newLatencyProfiler(t, metadatav1.UnitNanoseconds)
+			m := newFakeStatsMap(test.mapType)
+			for _, key := range sortedTestKeys(test.entries) {
+				m.put(key, test.entries[key]...)
+			}
+
+			entries, err := p.collect(m, 4, test.maxRows)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, entries)
+
+			// Histograms are reset, so the next interval starts from scratch
+			entries, err = p.collect(m, 4, test.maxRows)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func TestProfilerRender(t *testing.T) {
+	t.Parallel()
+
+	p := newLatencyProfiler(t, metadatav1.UnitNanoseconds)
+	pArray, err := p.ds.NewPacketArray()
+	require.NoError(t, err)
+	data := pArray.New()
+
+	require.NoError(t, p.setEntry(data, profilerEntry{slots: []uint64{0, 10, 40, 20}, total: 70}))
+
+	histogram, err := p.histogramField.String(data)
+	require.NoError(t, err)
+	require.Equal(t, ""+
+		"        ns               : count    distribution\n"+
+		"         0 -> 1          : 0        |                                        |\n"+
+		"         2 -> 3          : 10       |**********                              |\n"+
+		"         4 -> 7          : 40       |****************************************|\n"+
+		"         8 -> 15         : 20       |********************                    |\n",
+		histogram)
+
+	buckets, err := p.bucketsField.String(data)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"count": 0, "start": 0, "end": 1},
+		{"count": 10, "start": 2, "end": 3},
+		{"count": 40, "start": 4, "end": 7},
+		{"count": 20, "start": 8, "end": 15}
+	]`, buckets)
+
+	// The histogram is printed below the keys in columns output
+	require.Equal(t, []string{
+		"        ns               : count    distribution",
+		"         0 -> 1          : 0        |                                        |",
+		"         2 -> 3          : 10       |**********                              |",
+		"         4 -> 7          : 40       |****************************************|",
+		"         8 -> 15         : 20       |********************                    |",
+	}, datasource.NewDataTuple(p.ds, data).ExtraLines())
+}
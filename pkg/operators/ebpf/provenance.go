@@ -0,0 +1,161 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const (
+	ProgramIdType = "type:" + gadgets.ProgramIdTypeName
+
+	provenanceProgramField     = "program"
+	provenanceAttachPointField = "attach_point"
+	provenanceDirectionField   = "direction"
+)
+
+type provenanceFields struct {
+	program     datasource.FieldAccessor
+	attachPoint datasource.FieldAccessor
+	direction   datasource.FieldAccessor
+}
+
+// addProvenanceFields adds the provenance fields to ds. Fields whose name is
+// already used by the gadget are skipped, leaving the gadget's ones untouched.
+func (i *ebpfInstance) addProvenanceFields(ds datasource.DataSource, opts ...datasource.FieldOption) (*provenanceFields, error) {
+	addField := func(name string) (datasource.FieldAccessor, error) {
+		if ds.GetField(name) != nil {
+			i.logger.Debugf("%q already has a %q field, skipping provenance field", ds.Name(), name)
+			return nil, nil
+		}
+		acc, err := ds.AddField(name, api.Kind_String, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("adding field %q: %w", name, err)
+		}
+		return acc, nil
+	}
+
+	var err error
+	f := &provenanceFields{}
+
+	if f.program, err = addField(provenanceProgramField); err != nil {
+		return nil, err
+	}
+	if f.attachPoint, err = addField(provenanceAttachPointField); err != nil {
+		return nil, err
+	}
+	if f.direction, err = addField(provenanceDirectionField); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (f *provenanceFields) set(data datasource.Data, info gadgets.ProgramInfo) {
+	if f.program != nil {
+		f.program.PutString(data, info.Name)
+	}
+	if f.attachPoint != nil {
+		f.attachPoint.PutString(data, info.AttachPoint)
+	}
+	if f.direction != nil {
+		f.direction.PutString(data, info.Direction)
+	}
+}
+
+// programCookie returns the BPF cookie to use when attaching the program with
+// the given name, or 0 if no event needs it.
+func (i *ebpfInstance) programCookie(name string) uint64 {
+	if !i.programCookies {
+		return 0
+	}
+	return i.programs[name].ID
+}
+
+// initProvenanceFormatter adds the fields describing the program that produced
+// each event. Events with a gadget_program_id field get them from the BPF
+// cookie; tracers whose map is written by a single program get them from that
+// program directly.
+func (i *ebpfInstance) initProvenanceFormatter(gadgetCtx operators.GadgetContext) error {
+	i.programs = gadgets.GetProgramsInfo(i.collectionSpec)
+
+	byID := make(map[uint64]gadgets.ProgramInfo, len(i.programs))
+	for _, info := range i.programs {
+		byID[info.ID] = info
+	}
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		ins := ds.GetFieldsWithTag(ProgramIdType)
+		if len(ins) == 0 {
+			continue
+		}
+		if len(ins) > 1 {
+			i.logger.Warnf("multiple %q fields found in %q, using %q", gadgets.ProgramIdTypeName, ds.Name(), ins[0].Name())
+		}
+		in := ins[0]
+		in.SetHidden(true, false)
+
+		fields, err := i.addProvenanceFields(ds)
+		if err != nil {
+			return err
+		}
+
+		i.programCookies = true
+		i.formatters[ds] = append(i.formatters[ds], func(ds datasource.DataSource, data datasource.Data) error {
+			id, err := in.Uint64(data)
+			if err != nil {
+				return err
+			}
+			// Unknown ids (e.g. 0 when cookies aren't supported) leave the fields empty
+			fields.set(data, byID[id])
+			return nil
+		})
+	}
+
+	for name, tracer := range i.tracers {
+		if tracer.ds == nil || len(tracer.ds.GetFieldsWithTag(ProgramIdType)) > 0 {
+			continue
+		}
+
+		producers := gadgets.GetMapProducers(i.collectionSpec, tracer.MapName)
+		if len(producers) != 1 {
+			if len(producers) > 1 {
+				i.logger.Debugf("tracer %q has %d producers but no %q field, skipping provenance",
+					name, len(producers), gadgets.ProgramIdTypeName)
+			}
+			continue
+		}
+
+		info := i.programs[producers[0]]
+
+		// All events share the same values: keep them out of the way by default
+		fields, err := i.addProvenanceFields(tracer.ds, datasource.WithFlags(datasource.FieldFlagHidden))
+		if err != nil {
+			return err
+		}
+
+		i.formatters[tracer.ds] = append(i.formatters[tracer.ds], func(ds datasource.DataSource, data datasource.Data) error {
+			fields.set(data, info)
+			return nil
+		})
+	}
+
+	return nil
+}
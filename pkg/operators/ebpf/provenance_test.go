@@ -0,0 +1,57 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+func TestAddProvenanceFieldsExisting(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	direction, err := ds.AddField("direction", api.Kind_Uint8)
+	require.NoError(t, err)
+
+	i := &ebpfInstance{logger: logger.DefaultLogger()}
+	fields, err := i.addProvenanceFields(ds)
+	require.NoError(t, err)
+	require.NotNil(t, fields.program)
+	require.NotNil(t, fields.attachPoint)
+	require.Nil(t, fields.direction)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, direction.PutUint8(data, 1))
+
+	fields.set(data, gadgets.ProgramInfo{Name: "ig_tcp_in", AttachPoint: "tc", Direction: "ingress"})
+
+	program, err := fields.program.String(data)
+	require.NoError(t, err)
+	require.Equal(t, "ig_tcp_in", program)
+
+	// The gadget's own field is left untouched
+	val, err := direction.Uint8(data)
+	require.NoError(t, err)
+	require.Equal(t, uint8(1), val)
+}
@@ -0,0 +1,159 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// checkConstants makes sure the values about to be written into .rodata fit
+// their variables, as described by BTF. Type hints can drift from the eBPF
+// code when metadata is stale: a value of the wrong size would overwrite the
+// constants next to it and one out of range would be truncated.
+func (i *ebpfInstance) checkConstants(consts map[string]any) error {
+	names := make([]string, 0, len(consts))
+	for name := range consts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		var btfVar *btf.Var
+		if err := i.collectionSpec.Types.TypeByName(name, &btfVar); err != nil {
+			// Reported when rewriting the constants
+			continue
+		}
+		if err := checkConstant(btfVar, consts[name]); err != nil {
+			if p, ok := i.params[name]; ok {
+				return fmt.Errorf("param %q: %w", p.Key, params.MaskError(err, fmt.Sprint(consts[name]), p.sensitive))
+			}
+			return fmt.Errorf("variable %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// checkConstant fails if value can't be written into btfVar: its size must be
+// the one of the variable and integers must be in the range of its type
+func checkConstant(btfVar *btf.Var, value any) error {
+	size, err := btf.Sizeof(btfVar.Type)
+	if err != nil {
+		return fmt.Errorf("getting size of %q: %w", btfVar.Name, err)
+	}
+	typ := btfhelpers.GetUnderlyingType(btfVar.Type)
+	expected := describeVarType(typ, size)
+
+	valueSize := binary.Size(value)
+	switch v := value.(type) {
+	case []byte:
+		valueSize = len(v)
+	case string:
+		valueSize = len(v)
+	case int, uint:
+		valueSize = strconv.IntSize / 8
+	}
+	if valueSize >= 0 && valueSize != size {
+		return fmt.Errorf("value %v is %d bytes wide but the variable is %s", value, valueSize, expected)
+	}
+
+	// Integers are checked by value, whatever their Go type
+	var n int64
+	var u uint64
+	isSigned := true
+	switch v := value.(type) {
+	case int:
+		n = int64(v)
+	case int8:
+		n = int64(v)
+	case int16:
+		n = int64(v)
+	case int32:
+		n = int64(v)
+	case int64:
+		n = v
+	case uint:
+		u, isSigned = uint64(v), false
+	case uint8:
+		u, isSigned = uint64(v), false
+	case uint16:
+		u, isSigned = uint64(v), false
+	case uint32:
+		u, isSigned = uint64(v), false
+	case uint64:
+		u, isSigned = v, false
+	case float32, float64:
+		if _, ok := typ.(*btf.Float); !ok {
+			return fmt.Errorf("value %v is a float but the variable is %s", value, expected)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	var signed bool
+	switch t := typ.(type) {
+	case *btf.Int:
+		signed = t.Encoding == btf.Signed
+	case *btf.Enum:
+		signed = t.Signed
+	default:
+		return fmt.Errorf("value %v is an integer but the variable is %s", value, expected)
+	}
+
+	bits := uint(size * 8)
+	inRange := true
+	switch {
+	case signed && isSigned:
+		inRange = n >= math.MinInt64>>(64-bits) && n <= math.MaxInt64>>(64-bits)
+	case signed:
+		inRange = u <= math.MaxInt64>>(64-bits)
+	case isSigned:
+		inRange = n >= 0 && uint64(n) <= math.MaxUint64>>(64-bits)
+	default:
+		inRange = u <= math.MaxUint64>>(64-bits)
+	}
+	if !inRange {
+		return fmt.Errorf("value %v is out of the range of %s", value, expected)
+	}
+	return nil
+}
+
+// describeVarType returns the type of a variable as shown in errors
+func describeVarType(typ btf.Type, size int) string {
+	switch t := typ.(type) {
+	case *btf.Int:
+		switch {
+		case t.Encoding == btf.Bool:
+			return "a bool"
+		case t.Encoding == btf.Signed:
+			return fmt.Sprintf("a signed %d-bit integer", size*8)
+		}
+		return fmt.Sprintf("an unsigned %d-bit integer", size*8)
+	case *btf.Enum:
+		return fmt.Sprintf("enum %s (%d bytes)", t.Name, size)
+	case *btf.Float:
+		return fmt.Sprintf("a %d-bit float", size*8)
+	}
+	return fmt.Sprintf("%d bytes wide", size)
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestCheckConstant(t *testing.T) {
+	t.Parallel()
+
+	u32Type := &btf.Int{Name: "unsigned int", Size: 4}
+	s16Type := &btf.Int{Name: "short", Size: 2, Encoding: btf.Signed}
+	u8Type := &btf.Int{Name: "unsigned char", Size: 1}
+	enumType := &btf.Enum{Name: "mode", Size: 4, Values: []btf.EnumValue{{Name: "MODE_NONE"}}}
+
+	type testCase struct {
+		typ               btf.Type
+		value             any
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"u32": {
+			typ:   u32Type,
+			value: uint32(42),
+		},
+		"u32_as_bytes": {
+			typ:   u32Type,
+			value: []byte{1, 2, 3, 4},
+		},
+		"s16_negative": {
+			typ:   s16Type,
+			value: int16(-42),
+		},
+		"bool": {
+			typ:   &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool},
+			value: true,
+		},
+		"enum": {
+			typ:   enumType,
+			value: uint32(1),
+		},
+		"wider_value": {
+			typ:               u32Type,
+			value:             uint64(42),
+			expectedErrString: "value 42 is 8 bytes wide but the variable is an unsigned 32-bit integer",
+		},
+		"narrower_value": {
+			typ:               &btf.Int{Name: "long", Size: 8, Encoding: btf.Signed},
+			value:             int32(42),
+			expectedErrString: "value 42 is 4 bytes wide but the variable is a signed 64-bit integer",
+		},
+		"wrong_bytes": {
+			typ:               u32Type,
+			value:             []byte{1, 2},
+			expectedErrString: "is 2 bytes wide but the variable is an unsigned 32-bit integer",
+		},
+		"negative_into_unsigned": {
+			typ:               u32Type,
+			value:             int32(-1),
+			expectedErrString: "value -1 is out of the range of an unsigned 32-bit integer",
+		},
+		"too_big_for_signed": {
+			typ:               s16Type,
+			value:             uint16(40000),
+			expectedErrString: "value 40000 is out of the range of a signed 16-bit integer",
+		},
+		"signed_byte_into_unsigned": {
+			typ:               u8Type,
+			value:             int8(-128),
+			expectedErrString: "value -128 is out of the range of an unsigned 8-bit integer",
+		},
+		"float_into_integer": {
+			typ:               u32Type,
+			value:             float32(1.5),
+			expectedErrString: "value 1.5 is a float but the variable is an unsigned 32-bit integer",
+		},
+		"integer_into_array": {
+			typ:               &btf.Array{Type: u8Type, Index: u32Type, Nelems: 4},
+			value:             uint32(42),
+			expectedErrString: "value 42 is an integer but the variable is 4 bytes wide",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			btfVar := &btf.Var{Name: "targ_value", Type: &btf.Const{Type: &btf.Volatile{Type: test.typ}}}
+			err := checkConstant(btfVar, test.value)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestCheckConstantsStaleTypeHint(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("targ_pid", &btf.Int{Name: "unsigned int", Size: 4}),
+	)
+
+	// The variable was changed to a __u32 but the type hint still says it's
+	// a __u64
+	p := &param{
+		Param:    &api.Param{Key: "pid", TypeHint: string(params.TypeUint64)},
+		fromEbpf: true,
+	}
+	i := &ebpfInstance{
+		collectionSpec: spec,
+		params:         map[string]*param{"targ_pid": p},
+	}
+
+	value := (&params.ParamDesc{Key: "pid", TypeHint: params.TypeHint(p.TypeHint)}).ToParam()
+	require.NoError(t, value.Set("42"))
+
+	err := i.checkConstants(map[string]any{"targ_pid": value.AsAny()})
+	require.EqualError(t, err, "param \"pid\": value 42 is 8 bytes wide but the variable is an unsigned 32-bit integer")
+
+	require.NoError(t, i.checkConstants(map[string]any{"targ_pid": uint32(42)}))
+}
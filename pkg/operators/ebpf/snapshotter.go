@@ -15,17 +15,22 @@
 package ebpfoperator
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/cilium/ebpf"
 	"github.com/cilium/ebpf/btf"
 	"github.com/cilium/ebpf/link"
+	"github.com/spf13/viper"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
 	bpfiterns "github.com/inspektor-gadget/inspektor-gadget/pkg/utils/bpf-iter-ns"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/nsenter"
 )
@@ -49,6 +54,51 @@ type Snapshotter struct {
 	// links is a map of iterators to their links. Links are created when the
 	// iterator is attached to the kernel.
 	links map[string]*linkSnapshotter
+
+	// keySize and valueSize are the sizes of the key and value of the map of
+	// snapshotters dumping a map. Each entry is the key followed by the value,
+	// without the key for arrays.
+	keySize   uint32
+	valueSize uint32
+	// maxEntries of the map, bounding the iteration of the dump
+	maxEntries uint32
+	// counters are the fields of the value summed up for per-CPU maps
+	counters []*Field
+
+	// opField is set in diff mode, telling whether each entry was added,
+	// removed or changed since the previous snapshot
+	opField datasource.FieldAccessor
+	// keyFields identify the entries in diff mode. The key of the map is used
+	// for snapshotters dumping a map without them.
+	keyFields []*Field
+	// previous are the entries of the previous snapshot in diff mode
+	previous [][]byte
+}
+
+const (
+	ParamSnapshotMode = "snapshot-mode"
+
+	snapshotOpFieldName = "op"
+
+	snapshotOpAdded   = "added"
+	snapshotOpRemoved = "removed"
+	snapshotOpChanged = "changed"
+)
+
+// snapshotChange is an entry reported by a snapshotter in diff mode
+type snapshotChange struct {
+	op    string
+	entry []byte
+}
+
+// applyConfig sets the settings of the snapshotter from the metadata
+func (s *Snapshotter) applyConfig(snapConfig *viper.Viper) {
+	if snapConfig == nil {
+		return
+	}
+	s.DefaultColumns = snapConfig.GetStringSlice("defaultColumns")
+	s.Interval = snapConfig.GetString("interval")
+	s.Mode = metadatav1.SnapshotterMode(snapConfig.GetString("mode"))
 }
 
 func (i *ebpfInstance) parseSnapshotterPrograms(programs []string) (map[string]struct{}, error) {
@@ -119,13 +169,15 @@ func (i *ebpfInstance) populateSnapshotter(t btf.Type, varName string) error {
 	}
 
 	i.logger.Debugf("adding snapshotter %q", name)
-	i.snapshotters[name] = &Snapshotter{
+	snapshotter := &Snapshotter{
 		Snapshotter: metadatav1.Snapshotter{
 			StructName: btfStruct.Name,
 		},
 		iterators: iterators,
 		links:     make(map[string]*linkSnapshotter),
 	}
+	snapshotter.applyConfig(snapConfig)
+	i.snapshotters[name] = snapshotter
 
 	err = i.populateStructDirect(btfStruct)
 	if err != nil {
@@ -135,94 +187,460 @@ func (i *ebpfInstance) populateSnapshotter(t btf.Type, varName string) error {
 	return nil
 }
 
-func (i *ebpfInstance) runSnapshotters() error {
-	for sName, snapshotter := range i.snapshotters {
-		i.logger.Debugf("Running snapshotter %q", sName)
+// validateSnapshotterMap checks the map dumped by a snapshotter and returns its
+// key and value structs. Arrays don't have a key struct, their index isn't
+// part of the entries.
+func validateSnapshotterMap(snapshotterMap *ebpf.MapSpec) (*btf.Struct, *btf.Struct, error) {
+	isArray := false
+	switch snapshotterMap.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	case ebpf.Array, ebpf.PerCPUArray:
+		isArray = true
+	default:
+		return nil, nil, fmt.Errorf("map %q has a wrong type, expected: array, hash or lru hash (optionally per-CPU), got: %s",
+			snapshotterMap.Name, snapshotterMap.Type.String())
+	}
+	if snapshotterMap.Key == nil || snapshotterMap.Value == nil {
+		return nil, nil, fmt.Errorf("map %q does not have BTF information for its keys and values", snapshotterMap.Name)
+	}
+
+	valueStruct, ok := btf.UnderlyingType(snapshotterMap.Value).(*btf.Struct)
+	if !ok {
+		return nil, nil, fmt.Errorf("map %q value is %q, expected a struct", snapshotterMap.Name, snapshotterMap.Value.TypeName())
+	}
+	if isArray {
+		return nil, valueStruct, nil
+	}
+	keyStruct, ok := btf.UnderlyingType(snapshotterMap.Key).(*btf.Struct)
+	if !ok {
+		return nil, nil, fmt.Errorf("map %q key is %q, expected a struct", snapshotterMap.Name, snapshotterMap.Key.TypeName())
+	}
+	return keyStruct, valueStruct, nil
+}
+
+func (i *ebpfInstance) populateMapSnapshotter(t btf.Type, varName string) error {
+	i.logger.Debugf("populating map snapshotter %q", varName)
 
-		pArray, err := snapshotter.ds.NewPacketArray()
+	parts := strings.Split(varName, typeSplitter)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid map snapshotter info: %q", varName)
+	}
+
+	name := parts[0]
+	mapName := parts[1]
+
+	i.logger.Debugf("> name       : %q", name)
+	i.logger.Debugf("> map name   : %q", mapName)
+
+	snapConfig := i.config.Sub("snapshotters." + name)
+	if snapConfig != nil {
+		if configMapName := snapConfig.GetString("mapName"); configMapName != "" && configMapName != mapName {
+			return fmt.Errorf("validating snapshotter %q: mapName %q in eBPF program does not match %q from metadata file",
+				name, configMapName, mapName)
+		}
+		i.logger.Debugf("> successfully validated with metadata")
+	}
+
+	if _, ok := i.snapshotters[name]; ok {
+		i.logger.Debugf("snapshotter %q already defined, skipping", name)
+		return nil
+	}
+
+	snapshotterMap, ok := i.collectionSpec.Maps[mapName]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", mapName)
+	}
+	keyStruct, valueStruct, err := validateSnapshotterMap(snapshotterMap)
+	if err != nil {
+		return fmt.Errorf("snapshotter map is invalid: %w", err)
+	}
+
+	if snapConfig != nil {
+		if configStructName := snapConfig.GetString("structName"); configStructName != "" && configStructName != valueStruct.Name {
+			return fmt.Errorf("validating snapshotter %q: structName %q in eBPF program does not match %q from metadata file",
+				name, configStructName, valueStruct.Name)
+		}
+	}
+
+	i.logger.Debugf("adding snapshotter %q", name)
+	snapshotter := &Snapshotter{
+		Snapshotter: metadatav1.Snapshotter{
+			StructName: valueStruct.Name,
+			Source:     metadatav1.SnapshotterSourceMap,
+			MapName:    mapName,
+		},
+		valueSize:  valueStruct.Size,
+		maxEntries: snapshotterMap.MaxEntries,
+	}
+	if keyStruct != nil {
+		snapshotter.KeyStructName = keyStruct.Name
+		snapshotter.keySize = keyStruct.Size
+	}
+	snapshotter.applyConfig(snapConfig)
+	i.snapshotters[name] = snapshotter
+
+	if keyStruct != nil {
+		if err := i.populateStructDirect(keyStruct); err != nil {
+			return fmt.Errorf("populating struct %q for snapshotter %q: %w", keyStruct.Name, name, err)
+		}
+	}
+	if err := i.populateStructDirect(valueStruct); err != nil {
+		return fmt.Errorf("populating struct %q for snapshotter %q: %w", valueStruct.Name, name, err)
+	}
+
+	return nil
+}
+
+// entryFields joins the fields of the key and value structs of a snapshotter
+// dumping a map, the ones of the value following the key
+func (s *Snapshotter) entryFields(keyFields, valueFields []*Field) ([]*Field, error) {
+	fields := make([]*Field, 0, len(keyFields)+len(valueFields))
+	names := make(map[string]struct{}, len(keyFields))
+	for _, f := range keyFields {
+		fields = append(fields, f)
+		names[f.Name] = struct{}{}
+	}
+	for _, f := range valueFields {
+		if _, ok := names[f.Name]; ok {
+			return nil, fmt.Errorf("field %q is both in the key struct %q and the value struct %q",
+				f.Name, s.KeyStructName, s.StructName)
+		}
+		valueField := *f
+		valueField.Offset += s.keySize
+		if valueField.parent != -1 {
+			valueField.parent += len(keyFields)
+		}
+		fields = append(fields, &valueField)
+	}
+	return fields, nil
+}
+
+// dumpMap reads all the entries of the map of the snapshotter, each of them
+// being its key followed by its value. The map can be modified by eBPF
+// programs in the meantime: entries deleted during the dump are skipped, and
+// the iteration is bounded as it restarts from the first key when the
+// current one is deleted.
+func (s *Snapshotter) dumpMap(m statsMap, possibleCPUs int) ([][]byte, error) {
+	perCPU := false
+	isArray := false
+	switch m.Type() {
+	case ebpf.PerCPUArray:
+		perCPU, isArray = true, true
+	case ebpf.Array:
+		isArray = true
+	case ebpf.PerCPUHash, ebpf.LRUCPUHash:
+		perCPU = true
+	}
+
+	seen := make(map[string]struct{})
+	var entries [][]byte
+	// An untyped nil is needed to get the first key
+	var key any
+	for iterations := uint32(0); iterations <= 2*s.maxEntries; iterations++ {
+		var nextKey []byte
+		err := m.NextKey(key, &nextKey)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
 		if err != nil {
-			return fmt.Errorf("creating new packet: %w", err)
+			return nil, fmt.Errorf("iterating map %q: %w", s.MapName, err)
+		}
+		key = nextKey
+		if _, ok := seen[string(nextKey)]; ok {
+			continue
+		}
+		seen[string(nextKey)] = struct{}{}
+
+		var value []byte
+		if perCPU {
+			values := make([][]byte, possibleCPUs)
+			if err := m.Lookup(nextKey, values); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", s.MapName, err)
+			}
+			value = mergePerCPU(s.ds, s.counters, s.valueSize, values)
+		} else if err := m.Lookup(nextKey, &value); err != nil {
+			if errors.Is(err, ebpf.ErrKeyNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("looking up key in map %q: %w", s.MapName, err)
 		}
 
-		for pName, l := range snapshotter.links {
-			i.logger.Debugf("Running iterator %q", pName)
+		if len(value) < int(s.valueSize) {
+			continue
+		}
+		value = value[:s.valueSize]
+		// Unused entries of arrays are left out
+		if isArray && isZero(value) {
+			continue
+		}
 
-			if !isIteratorKindSupported(l.typ) {
-				return fmt.Errorf("iterator kind %q is not supported", l.typ)
+		entry := make([]byte, 0, s.keySize+s.valueSize)
+		if !isArray {
+			if len(nextKey) < int(s.keySize) {
+				continue
 			}
-			if !isIteratorKindPerNetNs(l.typ) {
-				buf, err := bpfiterns.Read(l.link)
+			entry = append(entry, nextKey[:s.keySize]...)
+		}
+		entries = append(entries, append(entry, value...))
+	}
+	return entries, nil
+}
+
+// initMode registers the op field and finds the key fields of snapshotters in
+// diff mode
+func (s *Snapshotter) initMode(mode metadatav1.SnapshotterMode, fields []*Field) error {
+	if mode != metadatav1.SnapshotterModeDiff {
+		return nil
+	}
+
+	for _, f := range fields {
+		if f.Attributes.Key {
+			s.keyFields = append(s.keyFields, f)
+		}
+	}
+	if len(s.keyFields) == 0 && s.keySize == 0 {
+		return fmt.Errorf("mode %q requires key fields", mode)
+	}
+
+	var err error
+	s.opField, err = s.ds.AddField(snapshotOpFieldName, api.Kind_String,
+		datasource.WithAnnotations(map[string]string{
+			"description": "Whether the entry was added, removed or changed since the previous snapshot",
+		}),
+		datasource.WithOrder(datasource.EnrichmentOrderFirst-10),
+	)
+	if err != nil {
+		return fmt.Errorf("adding field %q: %w", snapshotOpFieldName, err)
+	}
+	return nil
+}
+
+// entryKey returns the key identifying an entry: the bytes of its key fields,
+// or the key of the map for snapshotters dumping a map without them
+func (s *Snapshotter) entryKey(entry []byte) string {
+	if len(s.keyFields) == 0 {
+		return string(entry[:s.keySize])
+	}
+	var key []byte
+	for _, f := range s.keyFields {
+		key = append(key, entry[f.Offset:f.Offset+f.Size]...)
+	}
+	return string(key)
+}
+
+// diff compares a snapshot to the previous one. It returns the entries added
+// and changed, in the order of the snapshot, followed by the removed ones, and
+// keeps the snapshot to compare the next one to. Only the first entry of a key
+// is taken into account.
+func (s *Snapshotter) diff(entries [][]byte) []snapshotChange {
+	previous := make(map[string][]byte, len(s.previous))
+	for _, entry := range s.previous {
+		previous[s.entryKey(entry)] = entry
+	}
+
+	var changes []snapshotChange
+	current := make(map[string]struct{}, len(entries))
+	snapshot := make([][]byte, 0, len(entries))
+	for _, entry := range entries {
+		key := s.entryKey(entry)
+		if _, ok := current[key]; ok {
+			continue
+		}
+		current[key] = struct{}{}
+		snapshot = append(snapshot, entry)
+
+		prev, ok := previous[key]
+		switch {
+		case !ok:
+			changes = append(changes, snapshotChange{op: snapshotOpAdded, entry: entry})
+		case !bytes.Equal(prev, entry):
+			changes = append(changes, snapshotChange{op: snapshotOpChanged, entry: entry})
+		}
+	}
+	for _, entry := range s.previous {
+		if _, ok := current[s.entryKey(entry)]; !ok {
+			changes = append(changes, snapshotChange{op: snapshotOpRemoved, entry: entry})
+		}
+	}
+
+	s.previous = snapshot
+	return changes
+}
+
+// emit reports a snapshot: all of its entries, or only the changes since the
+// previous one in diff mode
+func (s *Snapshotter) emit(entries [][]byte) error {
+	changes := make([]snapshotChange, 0, len(entries))
+	if s.opField != nil {
+		changes = s.diff(entries)
+		if len(changes) == 0 {
+			return nil
+		}
+	} else {
+		for _, entry := range entries {
+			changes = append(changes, snapshotChange{entry: entry})
+		}
+	}
+
+	pArray, err := s.ds.NewPacketArray()
+	if err != nil {
+		return fmt.Errorf("creating new packet: %w", err)
+	}
+	for idx, change := range changes {
+		data := pArray.New()
+		if err := s.accessor.Set(data, change.entry); err != nil {
+			pArray.Release(data)
+			s.ds.Release(pArray)
+			return fmt.Errorf("setting data element %d: %w", idx, err)
+		}
+		if s.opField != nil {
+			if err := s.opField.PutString(data, change.op); err != nil {
+				pArray.Release(data)
+				s.ds.Release(pArray)
+				return fmt.Errorf("setting op of data element %d: %w", idx, err)
+			}
+		}
+		pArray.Append(data)
+	}
+	return s.ds.EmitAndRelease(pArray)
+}
+
+func (i *ebpfInstance) dumpSnapshotterMap(snapshotter *Snapshotter) ([][]byte, error) {
+	m, ok := i.collection.Maps[snapshotter.MapName]
+	if !ok {
+		return nil, fmt.Errorf("map %q not found", snapshotter.MapName)
+	}
+
+	possibleCPUs, err := ebpf.PossibleCPU()
+	if err != nil {
+		return nil, fmt.Errorf("getting number of possible CPUs: %w", err)
+	}
+
+	return snapshotter.dumpMap(m, possibleCPUs)
+}
+
+// splitEntries splits the buffer returned by an iterator in entries
+func (s *Snapshotter) splitEntries(pName string, buf []byte) ([][]byte, error) {
+	size := s.accessor.Size()
+	if uint32(len(buf))%size != 0 {
+		return nil, fmt.Errorf("iter %q returned an invalid buffer's size %d, expected multiple of %d",
+			pName, len(buf), size)
+	}
+
+	entries := make([][]byte, 0, uint32(len(buf))/size)
+	for i := uint32(0); i < uint32(len(buf)); i += size {
+		entries = append(entries, buf[i:i+size])
+	}
+	return entries, nil
+}
+
+// takeSnapshot returns the entries of a snapshotter, by dumping its map or by
+// running its iterators
+func (i *ebpfInstance) takeSnapshot(snapshotter *Snapshotter) ([][]byte, error) {
+	var entries [][]byte
+
+	if snapshotter.Source == metadatav1.SnapshotterSourceMap {
+		mapEntries, err := i.dumpSnapshotterMap(snapshotter)
+		if err != nil {
+			return nil, fmt.Errorf("dumping map: %w", err)
+		}
+		entries = append(entries, mapEntries...)
+	}
+
+	for pName, l := range snapshotter.links {
+		i.logger.Debugf("Running iterator %q", pName)
+
+		if !isIteratorKindSupported(l.typ) {
+			return nil, fmt.Errorf("iterator kind %q is not supported", l.typ)
+		}
+		if !isIteratorKindPerNetNs(l.typ) {
+			buf, err := bpfiterns.Read(l.link)
+			if err != nil {
+				return nil, fmt.Errorf("reading iterator %q: %w", pName, err)
+			}
+
+			iterEntries, err := snapshotter.splitEntries(pName, buf)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, iterEntries...)
+			continue
+		}
+
+		visitedNetNs := make(map[uint64]struct{})
+		for _, container := range i.containers {
+			_, visited := visitedNetNs[container.Netns]
+			if visited {
+				continue
+			}
+			visitedNetNs[container.Netns] = struct{}{}
+
+			err := nsenter.NetnsEnter(int(container.Pid), func() error {
+				reader, err := l.link.Open()
 				if err != nil {
-					return fmt.Errorf("reading iterator %q: %w", pName, err)
+					return err
 				}
+				defer reader.Close()
 
-				size := snapshotter.accessor.Size()
-				if uint32(len(buf))%size != 0 {
-					return fmt.Errorf("iter %q returned an invalid buffer's size %d, expected multiple of %d",
-						pName, len(buf), size)
+				buf, err := io.ReadAll(reader)
+				if err != nil {
+					return fmt.Errorf("reading iterator %q: %w", pName, err)
 				}
 
-				for i := uint32(0); i < uint32(len(buf)); i += size {
-					data := pArray.New()
-					if err := snapshotter.accessor.Set(data, buf[i:i+size]); err != nil {
-						pArray.Release(data)
-						return fmt.Errorf("setting data element %d: %w", i, err)
-					}
-					pArray.Append(data)
-				}
-			} else {
-				visitedNetNs := make(map[uint64]struct{})
-				for _, container := range i.containers {
-					_, visited := visitedNetNs[container.Netns]
-					if visited {
-						continue
-					}
-					visitedNetNs[container.Netns] = struct{}{}
-
-					err := nsenter.NetnsEnter(int(container.Pid), func() error {
-						reader, err := l.link.Open()
-						if err != nil {
-							return err
-						}
-						defer reader.Close()
-
-						buf, err := io.ReadAll(reader)
-						if err != nil {
-							return fmt.Errorf("reading iterator %q: %w", pName, err)
-						}
-
-						size := snapshotter.accessor.Size()
-						if uint32(len(buf))%size != 0 {
-							return fmt.Errorf("iter %q returned an invalid buffer's size %d, expected multiple of %d",
-								pName, len(buf), size)
-						}
-
-						for i := uint32(0); i < uint32(len(buf)); i += size {
-							data := pArray.New()
-							if err := snapshotter.accessor.Set(data, buf[i:i+size]); err != nil {
-								pArray.Release(data)
-								return fmt.Errorf("setting data element %d: %w", i, err)
-							}
-							pArray.Append(data)
-						}
-
-						return nil
-					})
-					if err != nil {
-						return fmt.Errorf("entering container %q's netns to run iterator %q: %w",
-							container.Runtime.RuntimeName, pName, err)
-					}
+				iterEntries, err := snapshotter.splitEntries(pName, buf)
+				if err != nil {
+					return err
 				}
+				entries = append(entries, iterEntries...)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("entering container %q's netns to run iterator %q: %w",
+					container.Runtime.RuntimeName, pName, err)
 			}
 		}
+	}
+
+	return entries, nil
+}
+
+func (i *ebpfInstance) runSnapshotters() error {
+	for sName, snapshotter := range i.snapshotters {
+		i.logger.Debugf("Running snapshotter %q", sName)
 
-		if err := snapshotter.ds.EmitAndRelease(pArray); err != nil {
+		entries, err := i.takeSnapshot(snapshotter)
+		if err != nil {
+			return fmt.Errorf("snapshotter %q: %w", sName, err)
+		}
+		if err := snapshotter.emit(entries); err != nil {
 			return fmt.Errorf("emitting snapshotter %q data: %w", sName, err)
 		}
 	}
 	return nil
 }
 
+// runSnapshottersPeriodically takes the snapshots again at each interval,
+// after the first one taken by Start
+func (i *ebpfInstance) runSnapshottersPeriodically(gadgetCtx operators.GadgetContext, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gadgetCtx.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := i.runSnapshotters(); err != nil {
+			i.logger.Errorf("running snapshotters: %v", err)
+		}
+	}
+}
+
 // isIteratorKindPerNetNs returns true if the iterator kind needs to be run per
 // network namespace.
 func isIteratorKindPerNetNs(kind string) bool {
@@ -0,0 +1,341 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// sockKey is laid out as struct sock_key { __u32 pid; __u32 fd; }
+func sockKey(pid, fd uint32) []byte {
+	b := make([]byte, 8)
+	binary.NativeEndian.PutUint32(b[0:], pid)
+	binary.NativeEndian.PutUint32(b[4:], fd)
+	return b
+}
+
+// sockStats is laid out as struct sock_stats { __u32 state; __u32 pad; __u64 bytes; }
+func sockStats(state uint32, bytes uint64) []byte {
+	b := make([]byte, 16)
+	binary.NativeEndian.PutUint32(b[0:], state)
+	binary.NativeEndian.PutUint64(b[8:], bytes)
+	return b
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+var (
+	sockKeyFields = []*Field{
+		{Field: metadatav1.Field{Name: "pid", Attributes: metadatav1.FieldAttributes{Template: "pid"}}, Offset: 0, Size: 4, parent: -1, kind: api.Kind_Uint32},
+		{Field: metadatav1.Field{Name: "fd"}, Offset: 4, Size: 4, parent: -1, kind: api.Kind_Uint32},
+	}
+	sockStatsFields = []*Field{
+		{Field: metadatav1.Field{Name: "state", Values: map[int64]string{1: "ESTABLISHED"}}, Offset: 0, Size: 4, parent: -1, kind: api.Kind_Uint32},
+		{Field: metadatav1.Field{Name: "bytes"}, Offset: 8, Size: 8, parent: -1, kind: api.Kind_Uint64},
+	}
+)
+
+func newSockSnapshotter(t *testing.T, isArray bool) *Snapshotter {
+	ds, err := datasource.New(datasource.TypeArray, "socks")
+	require.NoError(t, err)
+
+	s := &Snapshotter{
+		Snapshotter: metadatav1.Snapshotter{
+			StructName:    "sock_stats",
+			Source:        metadatav1.SnapshotterSourceMap,
+			MapName:       "socks",
+			KeyStructName: "sock_key",
+		},
+		ds:         ds,
+		keySize:    8,
+		valueSize:  16,
+		maxEntries: 16,
+		counters:   counterFields(sockStatsFields),
+	}
+	if isArray {
+		s.KeyStructName = ""
+		s.keySize = 0
+	}
+	return s
+}
+
+func TestSnapshotterEntryFields(t *testing.T) {
+	t.Parallel()
+
+	s := newSockSnapshotter(t, false)
+	fields, err := s.entryFields(sockKeyFields, sockStatsFields)
+	require.NoError(t, err)
+
+	offsets := make(map[string]uint32)
+	for _, f := range fields {
+		offsets[f.Name] = f.Offset
+	}
+	require.Equal(t, map[string]uint32{"pid": 0, "fd": 4, "state": 8, "bytes": 16}, offsets)
+
+	// The fields of the value struct are left untouched
+	require.Equal(t, uint32(8), sockStatsFields[1].Offset)
+
+	_, err = s.entryFields(sockKeyFields, sockKeyFields)
+	require.ErrorContains(t, err, "field \"pid\" is both in the key struct \"sock_key\" and the value struct \"sock_stats\"")
+}
+
+// deletingStatsMap deletes entries right before they are looked up, as eBPF
+// programs can do during a dump
+type deletingStatsMap struct {
+	*fakeStatsMap
+	deleted map[string]bool
+}
+
+func (m *deletingStatsMap) Lookup(key, valueOut any) error {
+	if m.deleted[string(key.([]byte))] {
+		m.Delete(key)
+	}
+	return m.fakeStatsMap.Lookup(key, valueOut)
+}
+
+func TestSnapshotterDumpMap(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mapType  ebpf.MapType
+		entries  map[string][][]byte
+		deleted  []string
+		expected [][]byte
+	}
+
+	tests := map[string]testCase{
+		"hash": {
+			mapType: ebpf.Hash,
+			entries: map[string][][]byte{
+				string(sockKey(10, 3)): {sockStats(1, 100)},
+				string(sockKey(11, 4)): {sockStats(2, 0)},
+			},
+			expected: [][]byte{
+				join(sockKey(10, 3), sockStats(1, 100)),
+				join(sockKey(11, 4), sockStats(2, 0)),
+			},
+		},
+		"per_cpu_hash": {
+			mapType: ebpf.PerCPUHash,
+			entries: map[string][][]byte{
+				string(sockKey(10, 3)): {sockStats(1, 100), nil, sockStats(1, 20)},
+			},
+			// Numeric fields are summed up, but not the ones with values
+			expected: [][]byte{
+				join(sockKey(10, 3), sockStats(1, 120)),
+			},
+		},
+		"array": {
+			mapType: ebpf.Array,
+			entries: map[string][][]byte{
+				"\x00\x00\x00\x00": {sockStats(1, 100)},
+				"\x01\x00\x00\x00": {sockStats(0, 0)},
+				"\x02\x00\x00\x00": {sockStats(2, 5)},
+			},
+			// The index isn't part of the entries and unused ones are skipped
+			expected: [][]byte{
+				sockStats(1, 100),
+				sockStats(2, 5),
+			},
+		},
+		"deleted_during_dump": {
+			mapType: ebpf.Hash,
+			entries: map[string][][]byte{
+				string(sockKey(10, 3)): {sockStats(1, 100)},
+				string(sockKey(11, 4)): {sockStats(1, 200)},
+				string(sockKey(12, 5)): {sockStats(1, 300)},
+			},
+			deleted: []string{string(sockKey(11, 4))},
+			expected: [][]byte{
+				join(sockKey(10, 3), sockStats(1, 100)),
+				join(sockKey(12, 5), sockStats(1, 300)),
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			isArray := test.mapType == ebpf.Array || test.mapType == ebpf.PerCPUArray
+			s := newSockSnapshotter(t, isArray)
+
+			fake := newFakeStatsMap(test.mapType)
+			for _, key := range sortedTestKeys(test.entries) {
+				fake.put(key, test.entries[key]...)
+			}
+			m := &deletingStatsMap{fakeStatsMap: fake, deleted: make(map[string]bool)}
+			for _, key := range test.deleted {
+				m.deleted[key] = true
+			}
+
+			entries, err := s.dumpMap(m, 4)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, entries)
+		})
+	}
+}
+
+func TestSnapshotterDiff(t *testing.T) {
+	t.Parallel()
+
+	type step struct {
+		snapshot [][]byte
+		expected []snapshotChange
+	}
+
+	type testCase struct {
+		keyFields []*Field
+		steps     []step
+	}
+
+	a := join(sockKey(1, 3), sockStats(1, 100))
+	aChanged := join(sockKey(1, 3), sockStats(1, 200))
+	b := join(sockKey(2, 4), sockStats(1, 50))
+	c := join(sockKey(3, 5), sockStats(1, 0))
+	// Same pid as a, on a different fd
+	aOtherFd := join(sockKey(1, 4), sockStats(1, 10))
+
+	tests := map[string]testCase{
+		"map_key": {
+			steps: []step{
+				{
+					snapshot: [][]byte{a, b},
+					expected: []snapshotChange{{op: snapshotOpAdded, entry: a}, {op: snapshotOpAdded, entry: b}},
+				},
+				{
+					snapshot: [][]byte{a, b},
+				},
+				{
+					snapshot: [][]byte{aChanged, c},
+					expected: []snapshotChange{
+						{op: snapshotOpChanged, entry: aChanged},
+						{op: snapshotOpAdded, entry: c},
+						{op: snapshotOpRemoved, entry: b},
+					},
+				},
+				{
+					snapshot: [][]byte{aChanged, c, aOtherFd},
+					expected: []snapshotChange{{op: snapshotOpAdded, entry: aOtherFd}},
+				},
+				{
+					expected: []snapshotChange{
+						{op: snapshotOpRemoved, entry: aChanged},
+						{op: snapshotOpRemoved, entry: c},
+						{op: snapshotOpRemoved, entry: aOtherFd},
+					},
+				},
+			},
+		},
+		"key_fields": {
+			keyFields: sockKeyFields[:1],
+			steps: []step{
+				{
+					// Only the first entry of a key is kept
+					snapshot: [][]byte{a, aOtherFd, b},
+					expected: []snapshotChange{{op: snapshotOpAdded, entry: a}, {op: snapshotOpAdded, entry: b}},
+				},
+				{
+					snapshot: [][]byte{aOtherFd, b},
+					expected: []snapshotChange{{op: snapshotOpChanged, entry: aOtherFd}},
+				},
+				{
+					snapshot: [][]byte{c},
+					expected: []snapshotChange{
+						{op: snapshotOpAdded, entry: c},
+						{op: snapshotOpRemoved, entry: aOtherFd},
+						{op: snapshotOpRemoved, entry: b},
+					},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := newSockSnapshotter(t, false)
+			s.keyFields = test.keyFields
+			for idx, step := range test.steps {
+				require.Equal(t, step.expected, s.diff(step.snapshot), "step %d", idx)
+			}
+		})
+	}
+}
+
+func TestSnapshotterEmitDiff(t *testing.T) {
+	t.Parallel()
+
+	s := newSockSnapshotter(t, false)
+	fields, err := s.entryFields(sockKeyFields, sockStatsFields)
+	require.NoError(t, err)
+	staticFields := make([]datasource.StaticField, 0, len(fields))
+	for _, f := range fields {
+		staticFields = append(staticFields, f)
+	}
+	s.accessor, err = s.ds.AddStaticFields(s.keySize+s.valueSize, staticFields)
+	require.NoError(t, err)
+
+	// Arrays need key fields
+	array := newSockSnapshotter(t, true)
+	require.ErrorContains(t, array.initMode(metadatav1.SnapshotterModeDiff, sockStatsFields), "requires key fields")
+
+	require.NoError(t, s.initMode(metadatav1.SnapshotterModeDiff, fields))
+	require.NotNil(t, s.opField)
+
+	type row struct {
+		op  string
+		pid uint32
+	}
+	var rows []row
+	s.ds.SubscribeArray(func(ds datasource.DataSource, dataArray datasource.DataArray) error {
+		for idx := 0; idx < dataArray.Len(); idx++ {
+			data := dataArray.Get(idx)
+			op, err := s.opField.String(data)
+			require.NoError(t, err)
+			rows = append(rows, row{op: op, pid: binary.NativeEndian.Uint32(s.accessor.Get(data))})
+		}
+		return nil
+	}, 0)
+
+	a := join(sockKey(1, 3), sockStats(1, 100))
+	b := join(sockKey(2, 4), sockStats(1, 50))
+	require.NoError(t, s.emit([][]byte{a, b}))
+	require.NoError(t, s.emit([][]byte{a, b}))
+	require.NoError(t, s.emit([][]byte{join(sockKey(1, 3), sockStats(1, 200))}))
+	require.Equal(t, []row{
+		{op: snapshotOpAdded, pid: 1},
+		{op: snapshotOpAdded, pid: 2},
+		{op: snapshotOpChanged, pid: 1},
+		{op: snapshotOpRemoved, pid: 2},
+	}, rows)
+}
@@ -0,0 +1,228 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/formatters/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const (
+	stackTargetNameAnnotation = "ebpf.formatter.stack"
+
+	// lostStack is shown when the stack can't be found in the stack map,
+	// because the id is invalid or the entry was overwritten
+	lostStack = "<lost>"
+
+	// stackOneLineSuffix is appended to the name of the field holding the
+	// one-line version of the stack, shown in columns
+	stackOneLineSuffix = "_oneline"
+
+	// kernelAddrStart is the lowest kernel address on 64-bit architectures,
+	// lower addresses belong to user space
+	kernelAddrStart = 1 << 63
+)
+
+// stackLookup gives access to the stacks stored in a stack trace map. It's
+// implemented by *ebpf.Map.
+type stackLookup interface {
+	LookupBytes(key interface{}) ([]byte, error)
+}
+
+// symbolResolver resolves kernel addresses to symbols. It's implemented by
+// *kallsyms.KAllSyms.
+type symbolResolver interface {
+	LookupByInstructionPointer(ip uint64) string
+}
+
+// stackTargetName returns the name of the field holding the symbolized stack
+// of the stack id field in
+func stackTargetName(in datasource.FieldAccessor) string {
+	if name := in.Annotations()[stackTargetNameAnnotation]; name != "" {
+		return name
+	}
+	if name, ok := strings.CutSuffix(in.Name(), "_id"); ok && name != "" {
+		return name
+	}
+	return in.Name() + "_stack"
+}
+
+// readStack returns the addresses of the stack with the given id, stopping at
+// the first empty frame
+func readStack(m stackLookup, id int64) ([]uint64, error) {
+	if m == nil {
+		return nil, errors.New("stack map not loaded")
+	}
+	if id < 0 || id > int64(^uint32(0)) {
+		return nil, fmt.Errorf("invalid stack id %d", id)
+	}
+	buf, err := m.LookupBytes(uint32(id))
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("stack id %d not found", id)
+	}
+	addrs := make([]uint64, 0, len(buf)/8)
+	for ; len(buf) >= 8; buf = buf[8:] {
+		addr := binary.NativeEndian.Uint64(buf)
+		if addr == 0 {
+			break
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// symbolizeStack resolves the kernel addresses of a stack. User addresses, and
+// kernel ones when no resolver is available, are shown in hex.
+func symbolizeStack(addrs []uint64, syms symbolResolver) []string {
+	frames := make([]string, 0, len(addrs))
+	for depth, addr := range addrs {
+		frame := formatPointer(addr)
+		if addr >= kernelAddrStart && syms != nil {
+			frame = syms.LookupByInstructionPointer(addr)
+		}
+		frames = append(frames, fmt.Sprintf("[%d]%s", depth, frame))
+	}
+	return frames
+}
+
+// addStackField adds the fields showing the stacks referenced by the stack id
+// field in: a multi-line one for JSON and a one-line one for columns. lookup
+// returns the stack map to use; it's called for each event as the map is only
+// available after the collection is loaded.
+func addStackField(
+	ds datasource.DataSource,
+	in datasource.FieldAccessor,
+	lookup func() stackLookup,
+	syms symbolResolver,
+) (func(ds datasource.DataSource, data datasource.Data) error, error) {
+	var signed bool
+	switch in.Type() {
+	case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+		signed = true
+	case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+	default:
+		return nil, fmt.Errorf("stack id field %q has type %s, expected an integer", in.Name(), in.Type())
+	}
+
+	add := ds.AddField
+	if parent := in.Parent(); parent != nil {
+		add = parent.AddSubField
+	}
+
+	targetName := stackTargetName(in)
+	oneLine, err := add(targetName+stackOneLineSuffix, api.Kind_String,
+		datasource.WithAnnotations(map[string]string{
+			json.SkipFieldAnnotation: "true",
+		}),
+		datasource.WithFlags(datasource.FieldFlagHidden),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("adding one-line stack field: %w", err)
+	}
+
+	outAnnotations := maps.Clone(in.Annotations())
+	delete(outAnnotations, stackTargetNameAnnotation)
+	if outAnnotations == nil {
+		outAnnotations = map[string]string{}
+	}
+	outAnnotations[datasource.ColumnsReplaceAnnotation] = oneLine.FullName()
+	if _, ok := outAnnotations["columns.width"]; !ok {
+		outAnnotations["columns.width"] = "40"
+	}
+	outAnnotations["columns.ellipsis"] = "end"
+	out, err := add(targetName, api.Kind_String, datasource.WithAnnotations(outAnnotations))
+	if err != nil {
+		return nil, fmt.Errorf("adding stack field: %w", err)
+	}
+
+	in.SetHidden(true, false)
+
+	return func(ds datasource.DataSource, data datasource.Data) error {
+		id := int64(byteSliceAsUint64(in.Get(data), signed, ds))
+
+		addrs, err := readStack(lookup(), id)
+		if err != nil {
+			if err := oneLine.PutString(data, lostStack); err != nil {
+				return err
+			}
+			return out.PutString(data, lostStack)
+		}
+
+		frames := symbolizeStack(addrs, syms)
+		if err := oneLine.PutString(data, strings.Join(frames, "; ")); err != nil {
+			return err
+		}
+		return out.PutString(data, strings.Join(frames, "\n"))
+	}, nil
+}
+
+// initStackMapFormatter symbolizes the stacks referenced by fields having the
+// stackMap attribute
+func (i *ebpfInstance) initStackMapFormatter(gadgetCtx operators.GadgetContext) error {
+	var syms symbolResolver
+	for ds, structName := range i.dataSourceStructs() {
+		s, ok := i.structs[structName]
+		if !ok {
+			continue
+		}
+		for _, field := range s.Fields {
+			mapName := field.Attributes.StackMap
+			if mapName == "" {
+				continue
+			}
+			in := ds.GetField(field.Name)
+			if in == nil {
+				continue
+			}
+
+			if syms == nil {
+				k, err := kallsyms.NewKAllSyms()
+				if err != nil {
+					i.logger.Warnf("Failed to load kernel symbols, showing addresses instead: %v", err)
+				} else {
+					syms = k
+				}
+			}
+
+			lookup := func() stackLookup {
+				if i.collection == nil {
+					return nil
+				}
+				if m, ok := i.collection.Maps[mapName]; ok {
+					return m
+				}
+				return nil
+			}
+			formatter, err := addStackField(ds, in, lookup, syms)
+			if err != nil {
+				return fmt.Errorf("adding stack for field %q: %w", field.Name, err)
+			}
+			i.formatters[ds] = append(i.formatters[ds], formatter)
+		}
+	}
+	return nil
+}
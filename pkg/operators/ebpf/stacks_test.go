@@ -0,0 +1,165 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/formatters/json"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/kallsyms"
+)
+
+// fakeStackMap holds stacks by id, like a stack trace map does
+type fakeStackMap map[uint32][]uint64
+
+func (m fakeStackMap) LookupBytes(key interface{}) ([]byte, error) {
+	addrs, ok := m[key.(uint32)]
+	if !ok {
+		return nil, nil
+	}
+	buf := make([]byte, 8*PerfMaxStackDepth)
+	for i, addr := range addrs {
+		binary.NativeEndian.PutUint64(buf[i*8:], addr)
+	}
+	return buf, nil
+}
+
+func TestStackTargetName(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	for name, expected := range map[string]string{
+		"kern_stack_id": "kern_stack",
+		"stack":         "stack_stack",
+		"_id":           "_id_stack",
+	} {
+		f, err := ds.AddField(name, api.Kind_Int32)
+		require.NoError(t, err)
+		require.Equal(t, expected, stackTargetName(f))
+	}
+
+	f, err := ds.AddField("ustack_id", api.Kind_Int32, datasource.WithAnnotations(map[string]string{
+		stackTargetNameAnnotation: "user_stack",
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "user_stack", stackTargetName(f))
+}
+
+func TestStackField(t *testing.T) {
+	t.Parallel()
+
+	syms, err := kallsyms.NewKAllSymsFromReader(strings.NewReader(strings.Join([]string{
+		"ffffffffb4231f40 T do_sys_openat2",
+		"ffffffffb43723e0 T __x64_sys_openat",
+	}, "\n")))
+	require.NoError(t, err)
+
+	stacks := fakeStackMap{
+		1: {0xffffffffb4231f48, 0xffffffffb43723e4},
+		2: {0xffffffffb4231f48, 0x7f0012345678},
+	}
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	in, err := ds.AddField("kern_stack_id", api.Kind_Int32)
+	require.NoError(t, err)
+
+	formatter, err := addStackField(ds, in, func() stackLookup { return stacks }, syms)
+	require.NoError(t, err)
+
+	out := ds.GetField("kern_stack")
+	require.NotNil(t, out)
+	oneLine := ds.GetField("kern_stack" + stackOneLineSuffix)
+	require.NotNil(t, oneLine)
+	require.Equal(t, oneLine.FullName(), out.Annotations()[datasource.ColumnsReplaceAnnotation])
+	require.Equal(t, "true", oneLine.Annotations()[json.SkipFieldAnnotation])
+	require.True(t, datasource.FieldFlagHidden.In(oneLine.Flags()))
+	require.True(t, datasource.FieldFlagHidden.In(in.Flags()))
+
+	type testCase struct {
+		id              int32
+		expected        string
+		expectedOneLine string
+	}
+
+	tests := map[string]testCase{
+		"kernel": {
+			id:              1,
+			expected:        "[0]do_sys_openat2\n[1]__x64_sys_openat",
+			expectedOneLine: "[0]do_sys_openat2; [1]__x64_sys_openat",
+		},
+		"user_frames_as_hex": {
+			id:              2,
+			expected:        "[0]do_sys_openat2\n[1]0x00007f0012345678",
+			expectedOneLine: "[0]do_sys_openat2; [1]0x00007f0012345678",
+		},
+		"expired": {
+			id:              3,
+			expected:        lostStack,
+			expectedOneLine: lostStack,
+		},
+		"error": {
+			id:              -14,
+			expected:        lostStack,
+			expectedOneLine: lostStack,
+		},
+	}
+
+	// Data is shared by the data source, so cases can't run in parallel
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, in.PutInt32(data, test.id))
+			require.NoError(t, formatter(ds, data))
+
+			str, err := out.String(data)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, str)
+			str, err = oneLine.String(data)
+			require.NoError(t, err)
+			require.Equal(t, test.expectedOneLine, str)
+		})
+	}
+}
+
+func TestStackFieldWithoutMap(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	in, err := ds.AddField("stack_id", api.Kind_Uint32)
+	require.NoError(t, err)
+
+	formatter, err := addStackField(ds, in, func() stackLookup { return nil }, nil)
+	require.NoError(t, err)
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, in.PutUint32(data, 1))
+	require.NoError(t, formatter(ds, data))
+
+	str, err := ds.GetField("stack").String(data)
+	require.NoError(t, err)
+	require.Equal(t, lostStack, str)
+}
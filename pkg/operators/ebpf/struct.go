@@ -18,12 +18,15 @@ import (
 	"fmt"
 	"reflect"
 	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/cilium/ebpf/btf"
 	"gopkg.in/yaml.v3"
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/columns"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 )
@@ -36,11 +39,16 @@ type Field struct {
 	parent int
 	name   string
 	kind   api.Kind
+	// enum is the type of enum fields, converted to strings
+	enum *btf.Enum
 }
 
 type Struct struct {
 	Fields []*Field `yaml:"fields"`
 	Size   uint32
+
+	// Computed fields, which aren't part of the eBPF struct
+	Computed []metadatav1.Field `yaml:"-"`
 }
 
 func (f *Field) FieldName() string {
@@ -63,6 +71,13 @@ func (f *Field) FieldType() api.Kind {
 	return f.kind
 }
 
+func (f *Field) FieldOrder() int32 {
+	if f.Attributes.Order == nil {
+		return 0
+	}
+	return *f.Attributes.Order
+}
+
 func (f *Field) FieldHidden() bool {
 	return f.Attributes.Hidden
 }
@@ -99,12 +114,49 @@ func (f *Field) FieldAnnotations() map[string]string {
 	if val := f.Attributes.MaxWidth; val != 0 {
 		out["columns.maxWidth"] = fmt.Sprintf("%d", val)
 	}
+	if f.Attributes.AutoWidth {
+		out["columns.autoWidth"] = "true"
+	}
 	if val := f.Attributes.Template; val != "" {
 		out["columns.template"] = val
 	}
+	if val := f.Attributes.Precision; val != nil {
+		out[datasource.PrecisionAnnotation] = fmt.Sprintf("%d", *val)
+	}
+	if val := f.Attributes.Unit; val != metadatav1.UnitNone {
+		out[datasource.UnitAnnotation] = string(val)
+	}
+	if val := f.Attributes.Humanize; val {
+		out[datasource.HumanizeAnnotation] = "true"
+	}
+	if val := f.Attributes.BoolLabels; val != nil {
+		out[datasource.BoolTrueLabelAnnotation] = val.True
+		out[datasource.BoolFalseLabelAnnotation] = val.False
+	}
+	if val := f.Attributes.ZeroAs; val != "" {
+		out[datasource.ZeroAsAnnotation] = val
+	}
+	if val := f.OutputName; val != "" {
+		out[datasource.OutputNameAnnotation] = val
+	}
+	if val := f.Group; val != "" {
+		out[datasource.GroupAnnotation] = val
+	}
+	if val := f.Attributes.Filterable; val != nil {
+		out[datasource.FilterableAnnotation] = strconv.FormatBool(*val)
+	}
+	if val := f.Attributes.Sortable; val != nil {
+		out[datasource.SortableAnnotation] = strconv.FormatBool(*val)
+	}
 	if val := f.Attributes.Hidden; val {
 		out["hidden"] = "true"
 	}
+	if len(f.Aliases) > 0 {
+		out[datasource.AliasesAnnotation] = strings.Join(f.Aliases, ",")
+	}
+	if f.Deprecated {
+		out[datasource.DeprecatedAnnotation] = "true"
+	}
 	return out
 }
 
@@ -128,6 +180,13 @@ func (i *ebpfInstance) populateStructDirect(btfStruct *btf.Struct) error {
 
 	i.getFieldsFromStruct(btfStruct, &gadgetStruct.Fields, "", 0, -1)
 
+	// Keep enums to convert them to strings
+	for _, field := range gadgetStruct.Fields {
+		if field.enum != nil {
+			i.enums[enumField{structName: btfStruct.Name, name: field.Name}] = field.enum
+		}
+	}
+
 	var configStruct *metadatav1.Struct
 	fields := i.config.Sub("structs." + btfStruct.Name)
 	if fields != nil {
@@ -140,13 +199,27 @@ func (i *ebpfInstance) populateStructDirect(btfStruct *btf.Struct) error {
 
 		// Build lookup
 		lookup := make(map[string]metadatav1.Field)
+		gadgetStruct.Computed = nil
 		for _, field := range configStruct.Fields {
+			if field.Source != "" {
+				gadgetStruct.Computed = append(gadgetStruct.Computed, field)
+				continue
+			}
 			lookup[field.Name] = field
 		}
 
 		// Only handling topmost layer for now // TODO
 		for _, field := range gadgetStruct.Fields {
-			cfgField, ok := lookup[field.Name]
+			name := field.Name
+			if _, ok := i.bitfields[name]; ok {
+				// The metadata refers to the decoded bitfield
+				name = strings.TrimSuffix(name, bitfieldRawSuffix)
+			}
+			if _, ok := i.pointers[name]; ok {
+				// The metadata refers to the hex rendering of the pointer
+				name = strings.TrimSuffix(name, pointerRawSuffix)
+			}
+			cfgField, ok := lookup[name]
 			if !ok {
 				continue
 			}
@@ -156,6 +229,19 @@ func (i *ebpfInstance) populateStructDirect(btfStruct *btf.Struct) error {
 			field.Description = cfgField.Description
 			field.Attributes = cfgField.Attributes
 			field.Annotations = cfgField.Annotations
+			field.Values = cfgField.Values
+			field.DefaultLabel = cfgField.DefaultLabel
+			field.Flags = cfgField.Flags
+			field.Aliases = cfgField.Aliases
+			field.Deprecated = cfgField.Deprecated
+			field.OutputName = cfgField.OutputName
+			field.Group = cfgField.Group
+			if field.Group != "" && i.config.GetBool("groups."+field.Group+".hidden") {
+				field.Attributes.Hidden = true
+			}
+			field.VariantOf = cfgField.VariantOf
+			field.Variants = cfgField.Variants
+			field.FilledBy = cfgField.FilledBy
 		}
 	}
 
@@ -165,6 +251,42 @@ func (i *ebpfInstance) populateStructDirect(btfStruct *btf.Struct) error {
 	return nil
 }
 
+// structField returns the field with the given full name of the struct with
+// the given name, if any
+func (i *ebpfInstance) structField(structName, name string) *Field {
+	s, ok := i.structs[structName]
+	if !ok {
+		return nil
+	}
+	for _, field := range s.Fields {
+		if field.Name == name {
+			return field
+		}
+	}
+	return nil
+}
+
+// fieldValues returns the value-to-label mapping set in the metadata for the
+// given field of the given struct, if any, along with the label of unknown
+// values.
+func (i *ebpfInstance) fieldValues(structName, name string) (map[int64]string, string) {
+	field := i.structField(structName, name)
+	if field == nil || len(field.Values) == 0 {
+		return nil, ""
+	}
+	return field.Values, field.DefaultLabel
+}
+
+// fieldFlags returns the flags set in the metadata for the given bitmask field
+// of the given struct, if any, along with the cap of the width of its column.
+func (i *ebpfInstance) fieldFlags(structName, name string) ([]metadatav1.Flag, uint) {
+	field := i.structField(structName, name)
+	if field == nil || len(field.Flags) == 0 {
+		return nil, 0
+	}
+	return field.Flags, field.Attributes.FlagsMaxWidth
+}
+
 func getFieldKind(typ reflect.Type, tags []string) api.Kind {
 	if typ == nil {
 		return api.Kind_Invalid
@@ -232,6 +354,18 @@ func (i *ebpfInstance) getFieldsFromMember(member btf.Member, fields *[]*Field,
 		}
 	}
 
+	// Promote members of anonymous structs and unions, as in C
+	if member.Name == "" {
+		switch t := member.Type.(type) {
+		case *btf.Struct:
+			i.getFieldsFromStruct(t, fields, prefix, offset+member.Offset.Bytes(), parent)
+			return
+		case *btf.Union:
+			i.getFieldsFromUnion(t, fields, prefix, offset+member.Offset.Bytes(), parent)
+			return
+		}
+	}
+
 	// Flatten embedded structs
 	if t, ok := member.Type.(*btf.Struct); ok {
 		// Add outer struct as well
@@ -255,6 +389,16 @@ func (i *ebpfInstance) getFieldsFromMember(member btf.Member, fields *[]*Field,
 		return
 	}
 
+	if member.BitfieldSize > 0 {
+		i.addBitfield(member, fields, prefix, offset, parent, tags)
+		return
+	}
+
+	if _, ok := btf.UnderlyingType(member.Type).(*btf.Pointer); ok {
+		i.addPointer(member, fields, prefix, offset, parent, tags)
+		return
+	}
+
 	if refType == nil {
 		i.logger.Debugf(" skipping field %q (%T)", prefix+member.Name, member.Type)
 		return
@@ -268,14 +412,12 @@ func (i *ebpfInstance) getFieldsFromMember(member btf.Member, fields *[]*Field,
 		return
 	}
 
-	// Keep enums to convert them to strings
-	if enum, ok := member.Type.(*btf.Enum); ok {
-		i.enums[member.Name] = enum
-	}
-
 	kind := getFieldKind(refType, tags)
 
 	field := newField(fsize, kind)
+	if enum, ok := member.Type.(*btf.Enum); ok {
+		field.enum = enum
+	}
 	field.Field.Attributes.Width = uint(columns.GetWidthFromType(refType.Kind()))
 
 	i.logger.Debugf(" adding field %q (%s) (kind: %s) at %d (parent %d) (%v)",
@@ -283,6 +425,71 @@ func (i *ebpfInstance) getFieldsFromMember(member btf.Member, fields *[]*Field,
 	*fields = append(*fields, field)
 }
 
+// addBitfield adds a hidden field with the bytes holding a bitfield member.
+// Bitfields don't start at byte boundaries, so their value is extracted by a
+// formatter, see initBitfieldFormatter.
+func (i *ebpfInstance) addBitfield(member btf.Member, fields *[]*Field, prefix string, offset uint32, parent int, tags []string) {
+	start, end := btfhelpers.BitfieldBytes(member.Offset, member.BitfieldSize)
+	name := member.Name + bitfieldRawSuffix
+
+	field := &Field{
+		Field: metadatav1.Field{
+			Name: prefix + name,
+			Attributes: metadatav1.FieldAttributes{
+				Alignment: metadatav1.AlignmentLeft,
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+		},
+		Size:   end - start,
+		Tags:   tags,
+		Offset: offset + start,
+		parent: parent,
+		name:   name,
+		kind:   api.Kind_Bytes,
+	}
+
+	signed := btfhelpers.IsSigned(member.Type)
+	i.bitfields[field.Name] = bitfield{
+		offset: member.Offset % 8,
+		size:   member.BitfieldSize,
+		signed: signed,
+		kind:   getFieldKind(btfhelpers.GetBitfieldType(member.BitfieldSize, signed), nil),
+	}
+
+	i.logger.Debugf(" adding bitfield %q (%d bits) at %d (parent %d) (%v)",
+		field.Name, member.BitfieldSize, field.Offset, parent, tags)
+	*fields = append(*fields, field)
+}
+
+// addPointer adds a hidden field with the address stored in a pointer member.
+// eBPF pointers are always 64 bits wide. The address is rendered as hex by a
+// formatter, see initPointerFormatter.
+func (i *ebpfInstance) addPointer(member btf.Member, fields *[]*Field, prefix string, offset uint32, parent int, tags []string) {
+	name := member.Name + pointerRawSuffix
+
+	field := &Field{
+		Field: metadatav1.Field{
+			Name: prefix + name,
+			Attributes: metadatav1.FieldAttributes{
+				Alignment: metadatav1.AlignmentLeft,
+				Ellipsis:  metadatav1.EllipsisEnd,
+			},
+		},
+		Size:   8,
+		Tags:   append(tags, "type:pointer"),
+		Offset: offset + member.Offset.Bytes(),
+		parent: parent,
+		name:   name,
+		kind:   api.Kind_Uint64,
+	}
+
+	i.pointers[field.Name] = struct{}{}
+
+	i.logger.Debugf(" adding pointer %q at %d (parent %d) (%v)",
+		field.Name, field.Offset, parent, tags)
+	*fields = append(*fields, field)
+}
+
 func (i *ebpfInstance) getFieldsFromStruct(btfStruct *btf.Struct, fields *[]*Field, prefix string, offset uint32, parent int) {
 	for _, member := range btfStruct.Members {
 		i.getFieldsFromMember(member, fields, prefix, offset, parent)
@@ -0,0 +1,114 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// targetMapParam holds what's needed to insert the values of a param into a
+// hash map, like the ports or PIDs to filter on, and to set the variable
+// backing it to their number
+type targetMapParam struct {
+	metadatav1.EBPFParam
+	varName string
+	// size of the variable, set to the number of values, or 0 if it's a bool
+	// only telling whether there are any
+	countSize int
+}
+
+// targetMapUpdater is the subset of *ebpf.Map used to fill target maps
+type targetMapUpdater interface {
+	lpmMapUpdater
+	KeySize() uint32
+	NextKey(key, nextKeyOut any) error
+	Delete(key any) error
+}
+
+func newTargetMapParam(btfVar *btf.Var, constraints *metadatav1.EBPFParam) (*targetMapParam, error) {
+	if _, err := types.TargetMapKeySize(constraints); err != nil {
+		return nil, err
+	}
+
+	intType, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Int)
+	if !ok {
+		return nil, fmt.Errorf("params with a target map need an integer or a bool variable, %q isn't one", btfVar.Name)
+	}
+
+	t := &targetMapParam{
+		EBPFParam: *constraints,
+		varName:   btfVar.Name,
+	}
+	if intType.Encoding != btf.Bool {
+		t.countSize = int(intType.Size)
+	}
+	return t, nil
+}
+
+// rewrite sets the variable backing the param to the number of values given
+// by value, or to whether there are any. It returns the keys to insert into
+// the target map.
+func (t *targetMapParam) rewrite(value string, consts map[string]any) ([][]byte, error) {
+	keys, err := types.TargetMapKeys(&t.EBPFParam, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.countSize == 0 {
+		consts[t.varName] = boolByte(len(keys) > 0)
+		return keys, nil
+	}
+	count, err := nativeUint(uint64(len(keys)), t.countSize)
+	if err != nil {
+		return nil, err
+	}
+	consts[t.varName] = count
+	return keys, nil
+}
+
+// fillTargetMap replaces the keys of m with the given ones, with zeroed
+// values. Removing the previous keys lets it be called again when the value
+// of the param changes.
+func fillTargetMap(m targetMapUpdater, keys [][]byte) error {
+	key := make([]byte, m.KeySize())
+	for {
+		err := m.NextKey(nil, key)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("iterating keys: %w", err)
+		}
+		if err := m.Delete(key); err != nil {
+			return fmt.Errorf("deleting key: %w", err)
+		}
+	}
+
+	value := make([]byte, m.ValueSize())
+	for _, key := range keys {
+		if err := m.Update(key, value, ebpf.UpdateAny); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+// fakeHashMap holds the entries of a hash map
+type fakeHashMap struct {
+	keySize   uint32
+	valueSize uint32
+	entries   map[string][]byte
+}
+
+func (m *fakeHashMap) KeySize() uint32 {
+	return m.keySize
+}
+
+func (m *fakeHashMap) ValueSize() uint32 {
+	return m.valueSize
+}
+
+func (m *fakeHashMap) Update(key, value any, flags ebpf.MapUpdateFlags) error {
+	m.entries[string(key.([]byte))] = value.([]byte)
+	return nil
+}
+
+func (m *fakeHashMap) NextKey(key, nextKeyOut any) error {
+	for k := range m.entries {
+		copy(nextKeyOut.([]byte), k)
+		return nil
+	}
+	return ebpf.ErrKeyNotExist
+}
+
+func (m *fakeHashMap) Delete(key any) error {
+	delete(m.entries, string(key.([]byte)))
+	return nil
+}
+
+func (m *fakeHashMap) keys() []string {
+	var keys []string
+	for k := range m.entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func port(n uint16) string {
+	return string(binary.BigEndian.AppendUint16(nil, n))
+}
+
+func TestFillTargetMap(t *testing.T) {
+	t.Parallel()
+
+	tp := &targetMapParam{
+		EBPFParam: metadatav1.EBPFParam{TargetMap: "ports", KeyType: metadatav1.KeyTypePort, List: true},
+		varName:   "nr_ports",
+		countSize: 4,
+	}
+	consts := make(map[string]any)
+	keys, err := tp.rewrite("80,443, 8080", consts)
+	require.NoError(t, err)
+	require.Equal(t, binary.NativeEndian.AppendUint32(nil, 3), consts["nr_ports"])
+
+	ports := &fakeHashMap{keySize: 2, valueSize: 1, entries: make(map[string][]byte)}
+	require.NoError(t, fillTargetMap(ports, keys))
+	require.Equal(t, []string{port(80), port(443), port(8080)}, ports.keys())
+	require.Equal(t, []byte{0}, ports.entries[port(80)])
+
+	// A new value replaces the keys
+	keys, err = tp.rewrite("22", consts)
+	require.NoError(t, err)
+	require.NoError(t, fillTargetMap(ports, keys))
+	require.Equal(t, []string{port(22)}, ports.keys())
+
+	// No value means no filtering
+	keys, err = tp.rewrite("", consts)
+	require.NoError(t, err)
+	require.Equal(t, binary.NativeEndian.AppendUint32(nil, 0), consts["nr_ports"])
+	require.NoError(t, fillTargetMap(ports, keys))
+	require.Empty(t, ports.entries)
+
+	_, err = tp.rewrite("80,65536", consts)
+	require.ErrorContains(t, err, "\"65536\" isn't a valid port")
+}
+
+func TestPopulateTargetMapParams(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("filter_ports", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+		constVar("comm", &btf.Array{Type: &btf.Int{Name: "char", Size: 1, Encoding: btf.Signed}, Index: &btf.Int{Name: "unsigned int", Size: 4}, Nelems: 16}),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  filter_ports:
+    key: ports
+    targetMap: ports
+    keyType: port
+    list: true
+  comm:
+    key: comm
+    targetMap: comms
+    keyType: uint32
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "filter_ports"))
+	err := i.populateParam(nil, "comm")
+	require.ErrorContains(t, err, "param \"comm\": params with a target map need an integer or a bool variable, \"comm\" isn't one")
+
+	p := i.params["filter_ports"]
+	require.Equal(t, string(params.TypeString), p.TypeHint)
+	require.NoError(t, p.validator("80,443,8080"))
+	require.ErrorContains(t, p.validator("80,https"), "\"https\" isn't a valid port")
+
+	consts := make(map[string]any)
+	keys, err := p.targetMap.rewrite("80,443,8080", consts)
+	require.NoError(t, err)
+	require.Equal(t, []byte{1}, consts["filter_ports"])
+	require.Equal(t, [][]byte{{0, 80}, {1, 187}, {0x1f, 0x90}}, keys)
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestBTF returns the BTF spec of the given types, marshaled and loaded
+// back like the one of an eBPF object
+func newTestBTF(tb testing.TB, types ...btf.Type) *btf.Spec {
+	tb.Helper()
+
+	b, err := btf.NewBuilder(types)
+	require.NoError(tb, err)
+	raw, err := b.Marshal(nil, nil)
+	require.NoError(tb, err)
+	spec, err := btf.LoadSpecFromReader(bytes.NewReader(raw))
+	require.NoError(tb, err)
+	return spec
+}
+
+// newTestSpec returns a collection spec with the BTF of the given types, to
+// add maps and programs to
+func newTestSpec(tb testing.TB, types ...btf.Type) *ebpf.CollectionSpec {
+	tb.Helper()
+
+	return &ebpf.CollectionSpec{Types: newTestBTF(tb, types...)}
+}
+
+// constVar returns a global const volatile variable of the given type, like
+// the ones backing params
+func constVar(name string, typ btf.Type) *btf.Var {
+	return &btf.Var{Name: name, Type: &btf.Const{Type: &btf.Volatile{Type: typ}}, Linkage: btf.GlobalVar}
+}
@@ -0,0 +1,378 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+)
+
+const (
+	ParamInterval = "interval"
+	ParamMaxRows  = "max-rows"
+
+	defaultTopperInterval = "1s"
+	defaultMaxRows        = 20
+)
+
+type Topper struct {
+	metadatav1.Topper
+
+	ds       datasource.DataSource
+	accessor datasource.FieldAccessor
+
+	valueSize uint32
+
+	// counters are the fields summed up when merging per-CPU values
+	counters []*Field
+	// sortField is the field the entries are sorted by, nil if the topper
+	// has no integer fields
+	sortField *Field
+}
+
+// statsMap is the subset of *ebpf.Map used to read the entries of toppers and
+// profilers
+type statsMap interface {
+	Type() ebpf.MapType
+	NextKey(key, nextKeyOut any) error
+	Lookup(key, valueOut any) error
+	Update(key, value any, flags ebpf.MapUpdateFlags) error
+	Delete(key any) error
+}
+
+func validateTopperMap(topperMap *ebpf.MapSpec) error {
+	switch topperMap.Type {
+	case ebpf.Hash, ebpf.LRUHash, ebpf.PerCPUHash, ebpf.LRUCPUHash:
+	default:
+		return fmt.Errorf("map %q has a wrong type, expected: hash or lru hash (optionally per-CPU), got: %s",
+			topperMap.Name, topperMap.Type.String())
+	}
+	if topperMap.Value == nil {
+		return fmt.Errorf("map %q does not have BTF information for its values", topperMap.Name)
+	}
+	return nil
+}
+
+func (i *ebpfInstance) populateTopper(t btf.Type, varName string) error {
+	i.logger.Debugf("populating topper %q", varName)
+
+	parts := strings.Split(varName, typeSplitter)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid topper info: %q", varName)
+	}
+
+	name := parts[0]
+	mapName := parts[1]
+
+	i.logger.Debugf("> name       : %q", name)
+	i.logger.Debugf("> map name   : %q", mapName)
+
+	topperConfig := i.config.Sub("toppers." + name)
+	if topperConfig != nil {
+		if configMapName := topperConfig.GetString("mapName"); configMapName != "" && configMapName != mapName {
+			return fmt.Errorf("validating topper %q: mapName %q in eBPF program does not match %q from metadata file",
+				name, configMapName, mapName)
+		}
+		i.logger.Debugf("> successfully validated with metadata")
+	}
+
+	if _, ok := i.toppers[name]; ok {
+		i.logger.Debugf("topper %q already defined, skipping", name)
+		return nil
+	}
+
+	topperMap, ok := i.collectionSpec.Maps[mapName]
+	if !ok {
+		return fmt.Errorf("map %q not found in eBPF object", mapName)
+	}
+
+	if err := validateTopperMap(topperMap); err != nil {
+		return fmt.Errorf("topper map is invalid: %w", err)
+	}
+
+	btfStruct, ok := btf.UnderlyingType(topperMap.Value).(*btf.Struct)
+	if !ok {
+		return fmt.Errorf("map %q value is %q, expected a struct", mapName, topperMap.Value.TypeName())
+	}
+
+	if topperConfig != nil {
+		if configStructName := topperConfig.GetString("structName"); configStructName != "" && configStructName != btfStruct.Name {
+			return fmt.Errorf("validating topper %q: structName %q in eBPF program does not match %q from metadata file",
+				name, configStructName, btfStruct.Name)
+		}
+	}
+
+	i.logger.Debugf("adding topper %q", name)
+	topper := &Topper{
+		Topper: metadatav1.Topper{
+			MapName:    mapName,
+			StructName: btfStruct.Name,
+		},
+		valueSize: btfStruct.Size,
+	}
+	if topperConfig != nil {
+		topper.SortField = topperConfig.GetString("sortField")
+		topper.Interval = topperConfig.GetString("interval")
+	}
+	i.toppers[name] = topper
+
+	err := i.populateStructDirect(btfStruct)
+	if err != nil {
+		return fmt.Errorf("populating struct %q for topper %q: %w", btfStruct.Name, name, err)
+	}
+
+	return nil
+}
+
+func isIntegerKind(kind api.Kind) (integer bool, signed bool) {
+	switch kind {
+	case api.Kind_Int8, api.Kind_Int16, api.Kind_Int32, api.Kind_Int64:
+		return true, true
+	case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+		return true, false
+	}
+	return false, false
+}
+
+// counterFields returns the fields summed up when merging per-CPU values:
+// top level integer fields without template, values or flags, as pids or enums
+// can't be summed up.
+func counterFields(fields []*Field) []*Field {
+	var counters []*Field
+	for _, f := range fields {
+		if f.parent != -1 {
+			continue
+		}
+		if integer, _ := isIntegerKind(f.kind); !integer {
+			continue
+		}
+		if f.Attributes.Template != "" || len(f.Values) > 0 || len(f.Flags) > 0 {
+			continue
+		}
+		counters = append(counters, f)
+	}
+	return counters
+}
+
+// initFields looks up the counters and the sort field of the topper within the
+// fields of its struct
+func (t *Topper) initFields(fields []*Field) error {
+	t.counters = counterFields(fields)
+
+	if t.SortField == "" {
+		if len(t.counters) > 0 {
+			t.sortField = t.counters[0]
+		}
+		return nil
+	}
+	for _, f := range fields {
+		if f.Name != t.SortField {
+			continue
+		}
+		if integer, _ := isIntegerKind(f.kind); !integer {
+			return fmt.Errorf("sortField %q must be an integer field", t.SortField)
+		}
+		t.sortField = f
+		return nil
+	}
+	return fmt.Errorf("sortField %q not found in struct %q", t.SortField, t.StructName)
+}
+
+func (t *Topper) fieldValue(f *Field, value []byte) uint64 {
+	return fieldValue(t.ds, f, value)
+}
+
+func fieldValue(ds datasource.DataSource, f *Field, value []byte) uint64 {
+	_, signed := isIntegerKind(f.kind)
+	return byteSliceAsUint64(value[f.Offset:f.Offset+f.Size], signed, ds)
+}
+
+func setFieldValue(ds datasource.DataSource, f *Field, value []byte, v uint64) {
+	b := value[f.Offset : f.Offset+f.Size]
+	switch f.Size {
+	case 1:
+		b[0] = uint8(v)
+	case 2:
+		ds.ByteOrder().PutUint16(b, uint16(v))
+	case 4:
+		ds.ByteOrder().PutUint32(b, uint32(v))
+	case 8:
+		ds.ByteOrder().PutUint64(b, v)
+	}
+}
+
+func (t *Topper) mergePerCPU(values [][]byte) []byte {
+	return mergePerCPU(t.ds, t.counters, t.valueSize, values)
+}
+
+// mergePerCPU merges the values of an entry from all CPUs: counters are
+// summed up and the other fields are taken from the first CPU that has seen
+// the entry.
+func mergePerCPU(ds datasource.DataSource, counters []*Field, valueSize uint32, values [][]byte) []byte {
+	var res []byte
+	for _, value := range values {
+		if len(value) < int(valueSize) || isZero(value[:valueSize]) {
+			continue
+		}
+		if res == nil {
+			res = make([]byte, valueSize)
+			copy(res, value)
+			continue
+		}
+		for _, f := range counters {
+			setFieldValue(ds, f, res, fieldValue(ds, f, res)+fieldValue(ds, f, value))
+		}
+	}
+	return res
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// collect reads and deletes all the entries of the map, so each interval only
+// reports what happened since the last one. It returns the maxRows entries
+// with the highest value of the sort field, or all of them if maxRows is 0.
+func (t *Topper) collect(m statsMap, possibleCPUs int, maxRows int) ([][]byte, error) {
+	perCPU := m.Type() == ebpf.PerCPUHash || m.Type() == ebpf.LRUCPUHash
+
+	var keys [][]byte
+	// An untyped nil is needed to get the first key
+	var key any
+	for {
+		var nextKey []byte
+		err := m.NextKey(key, &nextKey)
+		if errors.Is(err, ebpf.ErrKeyNotExist) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("iterating map %q: %w", t.MapName, err)
+		}
+		keys = append(keys, nextKey)
+		key = nextKey
+	}
+
+	entries := make([][]byte, 0, len(keys))
+	for _, key := range keys {
+		var value []byte
+		if perCPU {
+			values := make([][]byte, possibleCPUs)
+			if err := m.Lookup(key, values); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", t.MapName, err)
+			}
+			value = t.mergePerCPU(values)
+		} else {
+			if err := m.Lookup(key, &value); err != nil {
+				if errors.Is(err, ebpf.ErrKeyNotExist) {
+					continue
+				}
+				return nil, fmt.Errorf("looking up key in map %q: %w", t.MapName, err)
+			}
+		}
+		if err := m.Delete(key); err != nil && !errors.Is(err, ebpf.ErrKeyNotExist) {
+			return nil, fmt.Errorf("deleting key from map %q: %w", t.MapName, err)
+		}
+		if len(value) < int(t.valueSize) {
+			continue
+		}
+		entries = append(entries, value[:t.valueSize])
+	}
+
+	if t.sortField != nil {
+		_, signed := isIntegerKind(t.sortField.kind)
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := t.fieldValue(t.sortField, entries[i]), t.fieldValue(t.sortField, entries[j])
+			if signed {
+				return int64(a) > int64(b)
+			}
+			return a > b
+		})
+	}
+
+	if maxRows > 0 && len(entries) > maxRows {
+		entries = entries[:maxRows]
+	}
+	return entries, nil
+}
+
+func (t *Topper) emit(entries [][]byte) error {
+	pArray, err := t.ds.NewPacketArray()
+	if err != nil {
+		return fmt.Errorf("creating new packet: %w", err)
+	}
+	for idx, entry := range entries {
+		data := pArray.New()
+		if err := t.accessor.Set(data, entry); err != nil {
+			pArray.Release(data)
+			t.ds.Release(pArray)
+			return fmt.Errorf("setting data element %d: %w", idx, err)
+		}
+		pArray.Append(data)
+	}
+	return t.ds.EmitAndRelease(pArray)
+}
+
+func (i *ebpfInstance) runTopper(gadgetCtx operators.GadgetContext, name string, topper *Topper, interval time.Duration, maxRows int) {
+	m, ok := i.collection.Maps[topper.MapName]
+	if !ok {
+		i.logger.Errorf("topper %q: map %q not found", name, topper.MapName)
+		return
+	}
+
+	possibleCPUs, err := ebpf.PossibleCPU()
+	if err != nil {
+		i.logger.Errorf("topper %q: getting number of possible CPUs: %v", name, err)
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gadgetCtx.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		entries, err := topper.collect(m, possibleCPUs, maxRows)
+		if err != nil {
+			i.logger.Errorf("topper %q: %v", name, err)
+			continue
+		}
+		if err := topper.emit(entries); err != nil {
+			i.logger.Errorf("topper %q: emitting data: %v", name, err)
+		}
+	}
+}
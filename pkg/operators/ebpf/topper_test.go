@@ -0,0 +1,245 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// fakeStatsMap keeps the entries in insertion order, as a hash map would
+// return them in any order
+type fakeStatsMap struct {
+	typ    ebpf.MapType
+	keys   []string
+	values map[string][][]byte
+}
+
+func newFakeStatsMap(typ ebpf.MapType) *fakeStatsMap {
+	return &fakeStatsMap{typ: typ, values: make(map[string][][]byte)}
+}
+
+func (m *fakeStatsMap) put(key string, values ...[]byte) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = values
+}
+
+func (m *fakeStatsMap) Type() ebpf.MapType {
+	return m.typ
+}
+
+func (m *fakeStatsMap) NextKey(key, nextKeyOut any) error {
+	next := 0
+	if key != nil {
+		for i, k := range m.keys {
+			if k == string(key.([]byte)) {
+				next = i + 1
+			}
+		}
+	}
+	if next >= len(m.keys) {
+		return ebpf.ErrKeyNotExist
+	}
+	*nextKeyOut.(*[]byte) = []byte(m.keys[next])
+	return nil
+}
+
+func (m *fakeStatsMap) Lookup(key, valueOut any) error {
+	values, ok := m.values[string(key.([]byte))]
+	if !ok {
+		return ebpf.ErrKeyNotExist
+	}
+	switch out := valueOut.(type) {
+	case *[]byte:
+		*out = values[0]
+	case [][]byte:
+		copy(out, values)
+	default:
+		return fmt.Errorf("unexpected value type %T", valueOut)
+	}
+	return nil
+}
+
+func (m *fakeStatsMap) Update(key, value any, flags ebpf.MapUpdateFlags) error {
+	k := string(key.([]byte))
+	if _, ok := m.values[k]; !ok {
+		if flags == ebpf.UpdateExist {
+			return ebpf.ErrKeyNotExist
+		}
+		m.keys = append(m.keys, k)
+	}
+	switch v := value.(type) {
+	case []byte:
+		m.values[k] = [][]byte{v}
+	case [][]byte:
+		m.values[k] = v
+	default:
+		return fmt.Errorf("unexpected value type %T", value)
+	}
+	return nil
+}
+
+func (m *fakeStatsMap) Delete(key any) error {
+	for i, k := range m.keys {
+		if k == string(key.([]byte)) {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			delete(m.values, k)
+			return nil
+		}
+	}
+	return ebpf.ErrKeyNotExist
+}
+
+// fileStat is laid out as struct file_stat { __u32 pid; __u32 reads; __u64 rbytes; }
+func fileStat(pid, reads uint32, rbytes uint64) []byte {
+	b := make([]byte, 16)
+	binary.NativeEndian.PutUint32(b[0:], pid)
+	binary.NativeEndian.PutUint32(b[4:], reads)
+	binary.NativeEndian.PutUint64(b[8:], rbytes)
+	return b
+}
+
+func newFileStatTopper(t *testing.T, sortField string) *Topper {
+	ds, err := datasource.New(datasource.TypeArray, "file")
+	require.NoError(t, err)
+
+	topper := &Topper{
+		Topper: metadatav1.Topper{
+			MapName:    "stats",
+			StructName: "file_stat",
+			SortField:  sortField,
+		},
+		ds:        ds,
+		valueSize: 16,
+	}
+	fields := []*Field{
+		{Field: metadatav1.Field{Name: "pid", Attributes: metadatav1.FieldAttributes{Template: "pid"}}, Offset: 0, Size: 4, parent: -1, kind: api.Kind_Uint32},
+		{Field: metadatav1.Field{Name: "reads"}, Offset: 4, Size: 4, parent: -1, kind: api.Kind_Uint32},
+		{Field: metadatav1.Field{Name: "rbytes"}, Offset: 8, Size: 8, parent: -1, kind: api.Kind_Uint64},
+	}
+	require.NoError(t, topper.initFields(fields))
+	return topper
+}
+
+func TestTopperInitFields(t *testing.T) {
+	t.Parallel()
+
+	topper := newFileStatTopper(t, "")
+	require.Len(t, topper.counters, 2)
+	require.Equal(t, "reads", topper.sortField.Name)
+
+	topper = newFileStatTopper(t, "rbytes")
+	require.Equal(t, "rbytes", topper.sortField.Name)
+
+	topper.SortField = "wbytes"
+	require.ErrorContains(t, topper.initFields(nil), `sortField "wbytes" not found in struct "file_stat"`)
+}
+
+func TestTopperCollect(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mapType   ebpf.MapType
+		sortField string
+		maxRows   int
+		entries   map[string][][]byte
+		expected  [][]byte
+	}
+
+	tests := map[string]testCase{
+		"hash": {
+			mapType:   ebpf.Hash,
+			sortField: "rbytes",
+			entries: map[string][][]byte{
+				"a": {fileStat(1, 10, 100)},
+				"b": {fileStat(2, 5, 300)},
+				"c": {fileStat(3, 1, 200)},
+			},
+			expected: [][]byte{fileStat(2, 5, 300), fileStat(3, 1, 200), fileStat(1, 10, 100)},
+		},
+		"default_sort_field": {
+			mapType: ebpf.Hash,
+			entries: map[string][][]byte{
+				"a": {fileStat(1, 10, 100)},
+				"b": {fileStat(2, 5, 300)},
+			},
+			expected: [][]byte{fileStat(1, 10, 100), fileStat(2, 5, 300)},
+		},
+		"max_rows": {
+			mapType:   ebpf.LRUHash,
+			sortField: "rbytes",
+			maxRows:   1,
+			entries: map[string][][]byte{
+				"a": {fileStat(1, 10, 100)},
+				"b": {fileStat(2, 5, 300)},
+			},
+			expected: [][]byte{fileStat(2, 5, 300)},
+		},
+		"per_cpu": {
+			mapType:   ebpf.PerCPUHash,
+			sortField: "rbytes",
+			entries: map[string][][]byte{
+				// Counters are summed up, pid is taken from the first CPU
+				// with data
+				"a": {make([]byte, 16), fileStat(1, 10, 100), fileStat(1, 2, 250)},
+				"b": {fileStat(2, 5, 300)},
+			},
+			expected: [][]byte{fileStat(1, 12, 350), fileStat(2, 5, 300)},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			topper := newFileStatTopper(t, test.sortField)
+			m := newFakeStatsMap(test.mapType)
+			for _, key := range sortedTestKeys(test.entries) {
+				m.put(key, test.entries[key]...)
+			}
+
+			entries, err := topper.collect(m, 4, test.maxRows)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, entries)
+
+			// The map is emptied, so the next interval starts from scratch
+			require.Empty(t, m.keys)
+			entries, err = topper.collect(m, 4, test.maxRows)
+			require.NoError(t, err)
+			require.Empty(t, entries)
+		})
+	}
+}
+
+func sortedTestKeys(m map[string][][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
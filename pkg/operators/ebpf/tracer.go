@@ -40,6 +40,10 @@ type Tracer struct {
 	eventSize     uint32 // needed to trim trailing bytes when reading for perf event array
 	ringbufReader *ringbuf.Reader
 	perfReader    *perf.Reader
+
+	// aggregator collapses the events before they are emitted, for tracers
+	// with aggregation
+	aggregator *aggregator
 }
 
 func validateTracerMap(traceMap *ebpf.MapSpec) error {
@@ -99,13 +103,23 @@ func (i *ebpfInstance) populateTracer(t btf.Type, varName string) error {
 	}
 
 	i.logger.Debugf("adding tracer %q", name)
-	i.tracers[name] = &Tracer{
+	tracer := &Tracer{
 		Tracer: metadatav1.Tracer{
 			MapName:    mapName,
 			StructName: btfStruct.Name,
 		},
 		eventSize: btfStruct.Size,
 	}
+	if tracerConfig != nil {
+		tracer.DefaultColumns = tracerConfig.GetStringSlice("defaultColumns")
+		if aggregationConfig := tracerConfig.Sub("aggregation"); aggregationConfig != nil {
+			tracer.Aggregation = &metadatav1.Aggregation{}
+			if err := aggregationConfig.Unmarshal(tracer.Aggregation); err != nil {
+				return fmt.Errorf("tracer %q: unmarshalling aggregation: %w", name, err)
+			}
+		}
+	}
+	i.tracers[name] = tracer
 
 	err := i.populateStructDirect(btfStruct)
 	if err != nil {
@@ -134,11 +148,6 @@ func (t *Tracer) receiveEventsFromRingReader(gadgetCtx operators.GadgetContext)
 		if err != nil {
 			return err
 		}
-		pSingle, err := t.ds.NewPacketSingle()
-		if err != nil {
-			gadgetCtx.Logger().Warnf("error creating new packet: %v", err)
-			continue
-		}
 		sample := rec.RawSample
 		if uint32(len(rec.RawSample)) < t.eventSize {
 			// event is truncated; we need to copy
@@ -153,6 +162,15 @@ func (t *Tracer) receiveEventsFromRingReader(gadgetCtx operators.GadgetContext)
 			lastSlowLen = len(rec.RawSample)
 			sample = slowBuf
 		}
+		if t.aggregator != nil {
+			t.aggregator.add(sample)
+			continue
+		}
+		pSingle, err := t.ds.NewPacketSingle()
+		if err != nil {
+			gadgetCtx.Logger().Warnf("error creating new packet: %v", err)
+			continue
+		}
 		err = t.accessor.Set(pSingle, sample)
 		if err != nil {
 			gadgetCtx.Logger().Warnf("error setting buffer: %v", err)
@@ -174,10 +192,8 @@ func (t *Tracer) receiveEventsFromPerfReader(gadgetCtx operators.GadgetContext)
 		if err != nil {
 			return err
 		}
-		pSingle, err := t.ds.NewPacketSingle()
-		if err != nil {
-			gadgetCtx.Logger().Warnf("error creating new packet: %v", err)
-			continue
+		if rec.LostSamples > 0 {
+			t.ds.ReportLostData(rec.LostSamples)
 		}
 		sample := rec.RawSample
 		sampleLen := len(rec.RawSample)
@@ -197,6 +213,15 @@ func (t *Tracer) receiveEventsFromPerfReader(gadgetCtx operators.GadgetContext)
 			// event has trailing garbage, remove it
 			sample = sample[:t.eventSize]
 		}
+		if t.aggregator != nil {
+			t.aggregator.add(sample)
+			continue
+		}
+		pSingle, err := t.ds.NewPacketSingle()
+		if err != nil {
+			gadgetCtx.Logger().Warnf("error creating new packet: %v", err)
+			continue
+		}
 		err = t.accessor.Set(pSingle, sample)
 		if err != nil {
 			gadgetCtx.Logger().Warnf("error setting buffer: %v", err)
@@ -207,9 +232,6 @@ func (t *Tracer) receiveEventsFromPerfReader(gadgetCtx operators.GadgetContext)
 		if err != nil {
 			gadgetCtx.Logger().Warnf("error emitting data: %v", err)
 		}
-		if rec.LostSamples > 0 {
-			t.ds.ReportLostData(rec.LostSamples)
-		}
 	}
 }
 
@@ -240,7 +262,15 @@ func (i *ebpfInstance) runTracer(gadgetCtx operators.GadgetContext, tracer *Trac
 		return fmt.Errorf("creating BPF map reader: %w", err)
 	}
 
-	go tracer.receiveEvents(gadgetCtx)
+	received := make(chan struct{})
+	go func() {
+		tracer.receiveEvents(gadgetCtx)
+		close(received)
+	}()
+
+	if tracer.aggregator != nil {
+		go tracer.aggregator.run(gadgetCtx.Context(), gadgetCtx.Logger())
+	}
 
 	<-gadgetCtx.Context().Done()
 
@@ -250,5 +280,13 @@ func (i *ebpfInstance) runTracer(gadgetCtx operators.GadgetContext, tracer *Trac
 	if tracer.perfReader != nil {
 		tracer.perfReader.Close()
 	}
+
+	if tracer.aggregator != nil {
+		// Emit the events of the partial window once no more can be added
+		<-received
+		if err := tracer.aggregator.flush(); err != nil {
+			return fmt.Errorf("flushing aggregated events: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,77 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// selectTracer adds the param selecting the tracer to run, for gadgets
+// setting selectorParam. When a single tracer is selected, the other ones
+// are dropped, so their maps aren't read from and no data source is
+// registered for them, and the programs linked to them are skipped.
+func (i *ebpfInstance) selectTracer() error {
+	key := i.config.GetString("selectorParam")
+	if key == "" {
+		return nil
+	}
+
+	// Possible values are generated from the tracers found, keeping them in
+	// sync with the eBPF object
+	m := metadatav1.GadgetMetadata{Tracers: make(map[string]metadatav1.Tracer, len(i.tracers))}
+	for name := range i.tracers {
+		m.Tracers[name] = metadatav1.Tracer{}
+	}
+	description := i.config.GetString("gadgetParams." + key + ".description")
+	if description == "" {
+		description = "Tracer to run, all of them by default"
+	}
+	i.params[key] = &param{
+		Param: &api.Param{
+			Key:            key,
+			Description:    description,
+			DefaultValue:   metadatav1.SelectAllTracers,
+			PossibleValues: m.TracerSelectorValues(),
+		},
+	}
+
+	selected, _ := i.paramValue(key)
+	if selected == "" || selected == metadatav1.SelectAllTracers {
+		return nil
+	}
+	if _, ok := i.tracers[selected]; !ok {
+		return fmt.Errorf("param %q: tracer %q not found", key, selected)
+	}
+
+	for _, name := range sortedKeys(i.tracers) {
+		if name != selected {
+			i.logger.Debugf("Dropping tracer %q: %q is selected", name, selected)
+			delete(i.tracers, name)
+		}
+	}
+	for _, name := range sortedKeys(i.collectionSpec.Programs) {
+		tracer := i.config.GetString("programs." + name + ".tracer")
+		if tracer == "" || tracer == selected {
+			continue
+		}
+		reason := fmt.Sprintf("tracer %q isn't selected", tracer)
+		i.logger.Debugf("Skipping program %q: %s", name, reason)
+		i.skipProgram(name, reason)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+func TestSelectTracer(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		paramValues       map[string]string
+		expectedTracers   []string
+		expectedPrograms  []string
+		expectedSkipped   map[string]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"default": {
+			expectedTracers:  []string{"exec", "open"},
+			expectedPrograms: []string{"common", "trace_exec", "trace_open"},
+			expectedSkipped:  map[string]string{},
+		},
+		"all": {
+			paramValues:      map[string]string{"tracer": "all"},
+			expectedTracers:  []string{"exec", "open"},
+			expectedPrograms: []string{"common", "trace_exec", "trace_open"},
+			expectedSkipped:  map[string]string{},
+		},
+		"one_tracer": {
+			paramValues:      map[string]string{"tracer": "exec"},
+			expectedTracers:  []string{"exec"},
+			expectedPrograms: []string{"common", "trace_exec"},
+			expectedSkipped:  map[string]string{"trace_open": "tracer \"open\" isn't selected"},
+		},
+		"other_tracer": {
+			paramValues:      map[string]string{"tracer": "open"},
+			expectedTracers:  []string{"open"},
+			expectedPrograms: []string{"common", "trace_open"},
+			expectedSkipped:  map[string]string{"trace_exec": "tracer \"exec\" isn't selected"},
+		},
+		"unknown_tracer": {
+			paramValues:       map[string]string{"tracer": "connect"},
+			expectedErrString: "param \"tracer\": tracer \"connect\" not found",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+selectorParam: tracer
+programs:
+  trace_exec:
+    tracer: exec
+  trace_open:
+    tracer: open
+`)))
+
+			i := &ebpfInstance{
+				config: config,
+				logger: logger.DefaultLogger(),
+				collectionSpec: &ebpf.CollectionSpec{
+					Programs: map[string]*ebpf.ProgramSpec{
+						"trace_exec": {Name: "trace_exec"},
+						"trace_open": {Name: "trace_open"},
+						"common":     {Name: "common"},
+					},
+				},
+				tracers: map[string]*Tracer{
+					"exec": {Tracer: metadatav1.Tracer{MapName: "exec_events"}},
+					"open": {Tracer: metadatav1.Tracer{MapName: "open_events"}},
+				},
+				params:           make(map[string]*param),
+				paramValues:      test.paramValues,
+				optionalPrograms: make(map[string]struct{}),
+				skippedPrograms:  make(map[string]string),
+			}
+
+			err := i.selectTracer()
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, i.gatePrograms())
+
+			p := i.params["tracer"]
+			require.Equal(t, []string{"all", "exec", "open"}, p.PossibleValues)
+			require.Equal(t, "all", p.DefaultValue)
+
+			// Only the maps of the selected tracers are read from
+			require.Equal(t, test.expectedTracers, sortedKeys(i.tracers))
+			// and only their programs loaded and attached
+			require.Equal(t, test.expectedPrograms, sortedKeys(i.collectionSpec.Programs))
+			require.Equal(t, test.expectedSkipped, i.skippedPrograms)
+		})
+	}
+}
+
+func TestSelectTracerWithoutSelector(t *testing.T) {
+	t.Parallel()
+
+	i := &ebpfInstance{
+		config:  viper.New(),
+		tracers: map[string]*Tracer{"exec": {}, "open": {}},
+		params:  make(map[string]*param),
+	}
+	require.NoError(t, i.selectTracer())
+	require.Len(t, i.tracers, 2)
+	require.Empty(t, i.params)
+}
@@ -19,12 +19,21 @@ const (
 	// Prefix used to mark trace maps
 	tracerInfoPrefix = "gadget_tracer_"
 
+	// Prefix used to mark topper maps
+	topperInfoPrefix = "gadget_topper_"
+
+	// Prefix used to mark profiler maps
+	profilerInfoPrefix = "gadget_profiler_"
+
 	// Prefix used to mark eBPF params
 	paramPrefix = "gadget_param_"
 
 	// Prefix used to mark snapshotters structs
 	snapshottersPrefix = "gadget_snapshotter_"
 
+	// Prefix used to mark snapshotters dumping a map
+	mapSnapshotterPrefix = "gadget_map_snapshotter_"
+
 	// Prefix used to mark tracer map created with GADGET_TRACER_MAP() defined in
 	// include/gadget/buffer.h.
 	tracerMapPrefix = "gadget_map_tracer_"
@@ -0,0 +1,61 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf/btf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/btfhelpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets/run/types"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+)
+
+// unitParam holds what's needed to convert the value of a duration or size
+// param, like 250ms or 4KiB, to the integer written into its variable
+type unitParam struct {
+	metadatav1.EBPFParam
+	size   int
+	signed bool
+}
+
+func newUnitParam(btfVar *btf.Var, desc *metadatav1.EBPFParam) (*unitParam, error) {
+	if err := types.CheckTargetUnit(desc); err != nil {
+		return nil, err
+	}
+	intType, ok := btfhelpers.GetUnderlyingType(btfVar.Type).(*btf.Int)
+	if !ok || intType.Encoding == btf.Bool {
+		return nil, fmt.Errorf("%s params need an integer variable, %q isn't one", desc.TypeHint, btfVar.Name)
+	}
+
+	u := &unitParam{
+		size:   int(intType.Size),
+		signed: intType.Encoding == btf.Signed,
+	}
+	u.TypeHint = desc.TypeHint
+	u.TargetUnit = desc.TargetUnit
+	return u, nil
+}
+
+// encode returns value converted to the target unit of the param, sized for
+// its variable
+func (u *unitParam) encode(value string) ([]byte, error) {
+	n, err := types.ToTargetUnit(&u.EBPFParam, value, u.size, u.signed)
+	if err != nil {
+		return nil, err
+	}
+	return nativeUint(n, u.size)
+}
@@ -0,0 +1,194 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/cilium/ebpf/btf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	apihelpers "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api-helpers"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+func TestUnitParamEncode(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		typeHint          params.TypeHint
+		targetUnit        string
+		size              int
+		signed            bool
+		value             string
+		expected          uint64
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"ms_to_ns": {
+			typeHint: params.TypeDuration,
+			size:     8,
+			value:    "250ms",
+			expected: 250_000_000,
+		},
+		"s_to_ms": {
+			typeHint:   params.TypeDuration,
+			targetUnit: "ms",
+			size:       4,
+			value:      "2s",
+			expected:   2000,
+		},
+		"compound_to_us": {
+			typeHint:   params.TypeDuration,
+			targetUnit: "us",
+			size:       4,
+			value:      "1m30s",
+			expected:   90_000_000,
+		},
+		"negative_signed": {
+			typeHint:   params.TypeDuration,
+			targetUnit: "ms",
+			size:       4,
+			signed:     true,
+			value:      "-5ms",
+			expected:   uint64(0xfffffffb),
+		},
+		"kib": {
+			typeHint: params.TypeSize,
+			size:     4,
+			value:    "4KiB",
+			expected: 4096,
+		},
+		"mb": {
+			typeHint:   params.TypeSize,
+			targetUnit: "bytes",
+			size:       8,
+			value:      "1MB",
+			expected:   1_000_000,
+		},
+		"duration_overflow": {
+			typeHint:          params.TypeDuration,
+			size:              4,
+			value:             "5s",
+			expectedErrString: "5s is 5000000000ns, beyond what a 32-bit variable holds",
+		},
+		"signed_duration_overflow": {
+			typeHint:          params.TypeDuration,
+			targetUnit:        "ms",
+			size:              2,
+			signed:            true,
+			value:             "40s",
+			expectedErrString: "40s is 40000ms, beyond what a 16-bit variable holds",
+		},
+		"size_overflow": {
+			typeHint:          params.TypeSize,
+			size:              2,
+			value:             "64KiB",
+			expectedErrString: "64KiB is 65536 bytes, beyond what a 16-bit variable holds",
+		},
+		"negative_unsigned": {
+			typeHint:          params.TypeDuration,
+			size:              8,
+			value:             "-1s",
+			expectedErrString: "-1s is negative",
+		},
+		"not_whole": {
+			typeHint:          params.TypeDuration,
+			targetUnit:        "ms",
+			size:              4,
+			value:             "250us",
+			expectedErrString: "250us isn't a whole number of ms",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			u := &unitParam{size: test.size, signed: test.signed}
+			u.TypeHint = test.typeHint
+			u.TargetUnit = test.targetUnit
+
+			b, err := u.encode(test.value)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			expected, err := nativeUint(test.expected, test.size)
+			require.NoError(t, err)
+			require.Equal(t, expected, b)
+		})
+	}
+}
+
+func TestPopulateUnitParams(t *testing.T) {
+	t.Parallel()
+
+	spec := newTestSpec(t,
+		constVar("timeout_ms", &btf.Int{Name: "unsigned int", Size: 4}),
+		constVar("threshold", &btf.Int{Name: "unsigned short", Size: 2}),
+		constVar("verbose", &btf.Int{Name: "_Bool", Size: 1, Encoding: btf.Bool}),
+	)
+
+	config := viper.New()
+	config.SetConfigType("yaml")
+	require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+params:
+  timeout_ms:
+    key: timeout
+    typeHint: duration
+    targetUnit: ms
+  threshold:
+    key: threshold
+    typeHint: size
+  verbose:
+    key: verbose
+    typeHint: duration
+`)))
+
+	i := &ebpfInstance{
+		config:         config,
+		logger:         logger.DefaultLogger(),
+		collectionSpec: spec,
+		params:         make(map[string]*param),
+	}
+	require.NoError(t, i.populateParam(nil, "timeout_ms"))
+	require.NoError(t, i.populateParam(nil, "threshold"))
+	err := i.populateParam(nil, "verbose")
+	require.ErrorContains(t, err, "duration params need an integer variable, \"verbose\" isn't one")
+
+	require.Equal(t, string(params.TypeDuration), i.params["timeout_ms"].TypeHint)
+	timeout, err := i.params["timeout_ms"].unit.encode("1.5s")
+	require.NoError(t, err)
+	require.Equal(t, binary.NativeEndian.AppendUint32(nil, 1500), timeout)
+
+	// Overflows are rejected when parsing the values, before loading
+	parameters := params.Params{}
+	for _, p := range i.params {
+		desc := apihelpers.ParamToParamDesc(p.Param)
+		desc.Validator = p.validator
+		parameters = append(parameters, desc.ToParam())
+	}
+	require.NoError(t, parameters.CopyFromMap(map[string]string{"timeout": "250ms", "threshold": "32KiB"}, ""))
+	err = parameters.CopyFromMap(map[string]string{"threshold": "1MiB"}, "")
+	require.ErrorContains(t, err, "invalid value \"1MiB\" as \"threshold\": 1MiB is 1048576 bytes, beyond what a 16-bit variable holds")
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
+)
+
+const (
+	ParamUprobeTarget = "uprobe-target"
+)
+
+// uprobe is where a uprobe, uretprobe or USDT program attaches to
+type uprobe struct {
+	progType uprobetracer.ProgType
+	// attachTo is "target:symbol", the target being an absolute path, a
+	// library name or a container template resolved for each container
+	attachTo string
+}
+
+// uprobeProgType returns the kind of uprobe of a program by its section name,
+// which can be bare ("uprobe") when the metadata sets where it attaches to
+func uprobeProgType(p *ebpf.ProgramSpec) (uprobetracer.ProgType, bool) {
+	if p.Type != ebpf.Kprobe {
+		return 0, false
+	}
+	kind, _, _ := strings.Cut(p.SectionName, "/")
+	switch kind {
+	case "uprobe":
+		return uprobetracer.ProgUprobe, true
+	case "uretprobe":
+		return uprobetracer.ProgUretprobe, true
+	case "usdt":
+		return uprobetracer.ProgUSDT, true
+	}
+	return 0, false
+}
+
+// parseUprobeTargets parses the value of the uprobe-target param: either a
+// comma separated list of "program=target" or a single target used for all
+// programs
+func parseUprobeTargets(value string, programs map[string]*ebpf.ProgramSpec) (map[string]string, string, error) {
+	targets := make(map[string]string)
+	if value == "" {
+		return targets, "", nil
+	}
+	if !strings.Contains(value, "=") {
+		return targets, value, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		name, target, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || target == "" {
+			return nil, "", fmt.Errorf("invalid uprobe target %q, expected program=target", entry)
+		}
+		if _, ok := programs[name]; !ok {
+			return nil, "", fmt.Errorf("invalid uprobe target %q: program %q not found", entry, name)
+		}
+		targets[name] = target
+	}
+	return targets, "", nil
+}
+
+// resolveUprobe returns where the program attaches to: the section name is
+// overridden by the programs section of the metadata, and its target by the
+// uprobe-target param
+func (i *ebpfInstance) resolveUprobe(p *ebpf.ProgramSpec, progType uprobetracer.ProgType, targets map[string]string, defaultTarget string) (*uprobe, error) {
+	u := &uprobe{
+		progType: progType,
+		attachTo: p.AttachTo,
+	}
+
+	if programConfig := i.config.Sub("programs." + p.Name); programConfig != nil {
+		var program metadatav1.Program
+		if err := programConfig.Unmarshal(&program); err != nil {
+			return nil, fmt.Errorf("program %q: unmarshalling metadata: %w", p.Name, err)
+		}
+		// The entry can only make the program optional
+		if program.Target != "" || program.Symbol != "" || program.Retprobe {
+			if progType == uprobetracer.ProgUSDT {
+				return nil, fmt.Errorf("program %q: the programs section doesn't support USDT", p.Name)
+			}
+			u.attachTo = program.Target + ":" + program.Symbol
+			if program.Retprobe {
+				u.progType = uprobetracer.ProgUretprobe
+			}
+		}
+	}
+
+	if u.attachTo == "" {
+		return nil, fmt.Errorf("program %q doesn't set where to attach to: use a section like %q or the programs section of the metadata",
+			p.Name, p.SectionName+"/target:symbol")
+	}
+
+	target, ok := targets[p.Name]
+	if !ok {
+		target = defaultTarget
+	}
+	if target != "" {
+		_, symbol, _ := strings.Cut(u.attachTo, ":")
+		u.attachTo = target + ":" + symbol
+	}
+
+	return u, nil
+}
+
+func (i *ebpfInstance) addUprobeTargetParam() {
+	i.params[ParamUprobeTarget] = &param{
+		Param: &api.Param{
+			Key: ParamUprobeTarget,
+			Description: "Binary or library the uprobes attach to, instead of the one of the gadget. " +
+				"Either a single target for all of them or a comma separated list of program=target. " +
+				"Targets can be container templates like {{ .Container.Image.Entrypoint }}",
+		},
+	}
+}
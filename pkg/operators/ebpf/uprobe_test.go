@@ -0,0 +1,129 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ebpfoperator
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/uprobetracer"
+)
+
+func TestResolveUprobe(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		program           *ebpf.ProgramSpec
+		param             string
+		expected          *uprobe
+		expectedErrString string
+	}
+
+	sslRead := &ebpf.ProgramSpec{
+		Name:        "ssl_read",
+		Type:        ebpf.Kprobe,
+		SectionName: "uprobe/libssl.so:SSL_read",
+		AttachTo:    "libssl.so:SSL_read",
+	}
+	entrypoint := &ebpf.ProgramSpec{
+		Name:        "entrypoint",
+		Type:        ebpf.Kprobe,
+		SectionName: "uprobe",
+	}
+	unset := &ebpf.ProgramSpec{
+		Name:        "unset",
+		Type:        ebpf.Kprobe,
+		SectionName: "uprobe",
+	}
+
+	tests := map[string]testCase{
+		"section": {
+			program:  sslRead,
+			expected: &uprobe{progType: uprobetracer.ProgUprobe, attachTo: "libssl.so:SSL_read"},
+		},
+		"metadata": {
+			program:  entrypoint,
+			expected: &uprobe{progType: uprobetracer.ProgUretprobe, attachTo: "{{ .Container.Image.Entrypoint }}:main"},
+		},
+		"param_for_all": {
+			program:  sslRead,
+			param:    "/usr/lib/libssl.so.3",
+			expected: &uprobe{progType: uprobetracer.ProgUprobe, attachTo: "/usr/lib/libssl.so.3:SSL_read"},
+		},
+		"param_for_program": {
+			program:  entrypoint,
+			param:    "ssl_read=/usr/lib/libssl.so.3,entrypoint=/bin/bash",
+			expected: &uprobe{progType: uprobetracer.ProgUretprobe, attachTo: "/bin/bash:main"},
+		},
+		"param_for_other_program": {
+			program:  sslRead,
+			param:    "entrypoint=/bin/bash",
+			expected: &uprobe{progType: uprobetracer.ProgUprobe, attachTo: "libssl.so:SSL_read"},
+		},
+		"param_unknown_program": {
+			program:           sslRead,
+			param:             "ssl_write=/bin/bash",
+			expectedErrString: "program \"ssl_write\" not found",
+		},
+		"missing_target": {
+			program:           unset,
+			expectedErrString: "program \"unset\" doesn't set where to attach to",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := viper.New()
+			config.SetConfigType("yaml")
+			require.NoError(t, config.ReadConfig(bytes.NewBufferString(`
+programs:
+  entrypoint:
+    target: "{{ .Container.Image.Entrypoint }}"
+    symbol: main
+    retprobe: true
+`)))
+			i := &ebpfInstance{config: config}
+
+			programs := map[string]*ebpf.ProgramSpec{
+				sslRead.Name:    sslRead,
+				entrypoint.Name: entrypoint,
+				unset.Name:      unset,
+			}
+			targets, defaultTarget, err := parseUprobeTargets(test.param, programs)
+			if err == nil {
+				progType, ok := uprobeProgType(test.program)
+				require.True(t, ok)
+
+				var u *uprobe
+				u, err = i.resolveUprobe(test.program, progType, targets, defaultTarget)
+				if err == nil {
+					require.Equal(t, test.expected, u)
+				}
+			}
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -35,19 +35,18 @@ func (i *ebpfInstance) populateVar(t btf.Type, varName string) error {
 		return fmt.Errorf("%q not of type *btf.Var", varName)
 	}
 
-	btfConst, ok := btfVar.Type.(*btf.Const)
-	if !ok {
+	isConst, isVolatile := btfhelpers.Qualifiers(btfVar.Type)
+	if !isConst {
 		return fmt.Errorf("%q not a const", varName)
 	}
-
-	btfVolatile, ok := btfConst.Type.(*btf.Volatile)
-	if !ok {
+	if !isVolatile {
 		return fmt.Errorf("%q not volatile", varName)
 	}
 
-	refType, tags := btfhelpers.GetType(btfVolatile.Type)
+	refType, tags := btfhelpers.GetType(btfVar.Type)
 	if refType == nil {
-		i.logger.Warnf("unknown type for variable %q: %s (%T)", varName, btfVolatile.Type.TypeName(), btfVolatile.Type)
+		varType := btfhelpers.GetUnderlyingType(btfVar.Type)
+		i.logger.Warnf("unknown type for variable %q: %s (%T)", varName, varType.TypeName(), varType)
 		return nil
 	}
 
@@ -0,0 +1,290 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eventhash implements a data operator that adds a content hash to
+// each event, so that events captured more than once (e.g. by overlapping
+// instances) can be deduplicated downstream.
+//
+// The hash covers the fields annotated with "eventhash.include: true" or, if
+// no field of the data source has that annotation, all the fields coming from
+// eBPF except timestamps. Fields can be left out with
+// "eventhash.include: false". Enrichment fields aren't included by default.
+//
+// Canonical encoding, version 1:
+//
+//	version    uint8 (1)
+//	for each included field, sorted by full name:
+//	  name     uint16 length + bytes
+//	  kind     uint8 (api.Kind)
+//	  value    uint32 length + bytes
+//
+// Lengths are little endian. Integers and floats are encoded little endian
+// regardless of the byte order of the producer and C strings are cut at the
+// first NUL byte. The hash is the hex encoding of the first 16 bytes of the
+// SHA-256 of the encoding. Any change to the encoding must bump the version,
+// which is also available in the "eventhash.version" annotation of the field.
+package eventhash
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"sort"
+	"strconv"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	ParamEventHash = "event-hash"
+
+	// EncodingVersion is the version of the canonical encoding described in
+	// the package documentation
+	EncodingVersion = 1
+
+	// HashField is the name of the field added to the data sources
+	HashField = "event_hash"
+
+	// timestampTag is set on fields whose value changes between captures of
+	// the same event
+	timestampTag = "type:gadget_timestamp"
+
+	includeAnnotation = "eventhash.include"
+	versionAnnotation = "eventhash.version"
+
+	// hashLen is the number of bytes of the SHA-256 that are kept
+	hashLen = 16
+)
+
+type eventHashOperator struct{}
+
+func (o *eventHashOperator) Name() string {
+	return "eventhash"
+}
+
+func (o *eventHashOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *eventHashOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *eventHashOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamEventHash,
+			Title:        "Event Hash",
+			DefaultValue: "false",
+			TypeHint:     api.TypeBool,
+			Description:  "Add a " + HashField + " field with a hash of the event content, to deduplicate events captured more than once",
+		},
+	}
+}
+
+func (o *eventHashOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	// Keep the hot path untouched unless requested
+	if enabled, _ := strconv.ParseBool(instanceParamValues[ParamEventHash]); !enabled {
+		return nil, nil
+	}
+
+	inst := &eventHashOperatorInstance{
+		hashers: make(map[datasource.DataSource]*hasher),
+	}
+
+	for _, ds := range gadgetCtx.GetDataSources() {
+		fields := hashedFields(ds)
+		if len(fields) == 0 {
+			continue
+		}
+
+		h, err := newHasher(ds, fields)
+		if err != nil {
+			return nil, fmt.Errorf("data source %q: %w", ds.Name(), err)
+		}
+		inst.hashers[ds] = h
+	}
+
+	// Don't run, if we don't have anything to do
+	if len(inst.hashers) == 0 {
+		return nil, nil
+	}
+
+	return inst, nil
+}
+
+func (o *eventHashOperator) Priority() int {
+	return 0
+}
+
+// hashedFields returns the fields of ds to be hashed, sorted by full name.
+func hashedFields(ds datasource.DataSource) []datasource.FieldAccessor {
+	kernelFields := make(map[string]struct{})
+	for _, f := range ds.GetFieldsWithTag(api.TagSrcEbpf) {
+		kernelFields[f.FullName()] = struct{}{}
+	}
+	for _, f := range ds.GetFieldsWithTag(timestampTag) {
+		delete(kernelFields, f.FullName())
+	}
+
+	var explicit, defaults []datasource.FieldAccessor
+
+	for _, f := range ds.Accessors(false) {
+		// Only leaves carry data of their own
+		if len(f.SubFields()) > 0 {
+			continue
+		}
+
+		switch f.Annotations()[includeAnnotation] {
+		case "true":
+			explicit = append(explicit, f)
+		case "false":
+		default:
+			if _, ok := kernelFields[f.FullName()]; ok {
+				defaults = append(defaults, f)
+			}
+		}
+	}
+
+	fields := defaults
+	if len(explicit) > 0 {
+		fields = explicit
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].FullName() < fields[j].FullName()
+	})
+	return fields
+}
+
+type hasher struct {
+	fields []datasource.FieldAccessor
+	out    datasource.FieldAccessor
+
+	// swap is set when the byte order of the data source isn't the one of
+	// the canonical encoding
+	swap bool
+
+	// headers holds the encoded name and kind of each field, that don't
+	// change from event to event
+	headers [][]byte
+}
+
+func newHasher(ds datasource.DataSource, fields []datasource.FieldAccessor) (*hasher, error) {
+	h := &hasher{
+		fields:  fields,
+		swap:    ds.ByteOrder().Uint16([]byte{1, 0}) != 1,
+		headers: make([][]byte, 0, len(fields)),
+	}
+
+	for _, f := range fields {
+		name := f.FullName()
+		header := binary.LittleEndian.AppendUint16(nil, uint16(len(name)))
+		header = append(header, name...)
+		header = append(header, byte(f.Type()))
+		h.headers = append(h.headers, header)
+	}
+
+	out, err := ds.AddField(HashField, api.Kind_String, datasource.WithAnnotations(map[string]string{
+		versionAnnotation: strconv.Itoa(EncodingVersion),
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("adding field %q: %w", HashField, err)
+	}
+	h.out = out
+
+	return h, nil
+}
+
+// canonicalValue returns the value of f in its canonical encoding. It only
+// copies the value when its byte order needs to be swapped.
+func canonicalValue(f datasource.FieldAccessor, data datasource.Data, swap bool, scratch []byte) []byte {
+	value := f.Get(data)
+
+	switch f.Type() {
+	case api.Kind_CString:
+		if i := bytes.IndexByte(value, 0); i >= 0 {
+			value = value[:i]
+		}
+	case api.Kind_Int16, api.Kind_Uint16,
+		api.Kind_Int32, api.Kind_Uint32, api.Kind_Float32,
+		api.Kind_Int64, api.Kind_Uint64, api.Kind_Float64:
+		if !swap {
+			return value
+		}
+		scratch = append(scratch[:0], value...)
+		for i, j := 0, len(scratch)-1; i < j; i, j = i+1, j-1 {
+			scratch[i], scratch[j] = scratch[j], scratch[i]
+		}
+		return scratch
+	}
+
+	return value
+}
+
+func (h *hasher) write(w hash.Hash, data datasource.Data) {
+	var scratch [8]byte
+	var length [4]byte
+
+	w.Write([]byte{EncodingVersion})
+	for i, f := range h.fields {
+		value := canonicalValue(f, data, h.swap, scratch[:0])
+
+		w.Write(h.headers[i])
+		binary.LittleEndian.PutUint32(length[:], uint32(len(value)))
+		w.Write(length[:])
+		w.Write(value)
+	}
+}
+
+func (h *hasher) hash(data datasource.Data) string {
+	w := sha256.New()
+	h.write(w, data)
+	return hex.EncodeToString(w.Sum(nil)[:hashLen])
+}
+
+type eventHashOperatorInstance struct {
+	hashers map[datasource.DataSource]*hasher
+}
+
+func (o *eventHashOperatorInstance) Name() string {
+	return "eventhash"
+}
+
+func (o *eventHashOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for ds, h := range o.hashers {
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			return h.out.PutString(data, h.hash(data))
+		}, 0)
+	}
+	return nil
+}
+
+func (o *eventHashOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *eventHashOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func init() {
+	operators.RegisterDataOperator(&eventHashOperator{})
+}
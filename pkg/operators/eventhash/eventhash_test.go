@@ -0,0 +1,187 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eventhash
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+type staticField struct {
+	name        string
+	size        uint32
+	offset      uint32
+	kind        api.Kind
+	tags        []string
+	annotations map[string]string
+}
+
+func (f *staticField) FieldName() string                   { return f.name }
+func (f *staticField) FieldSize() uint32                   { return f.size }
+func (f *staticField) FieldOffset() uint32                 { return f.offset }
+func (f *staticField) FieldType() api.Kind                 { return f.kind }
+func (f *staticField) FieldTags() []string                 { return f.tags }
+func (f *staticField) FieldAnnotations() map[string]string { return f.annotations }
+
+type event struct {
+	pid       uint32
+	comm      string
+	timestamp uint64
+	// garbage is written after the NUL terminator of comm
+	garbage byte
+}
+
+// newEventSource returns a data source with the layout
+//
+//	struct event { __u32 pid; char comm[8]; gadget_timestamp timestamp; };
+//
+// and a function to build packets from events. fields are added in the
+// given order to check that it doesn't change the hash.
+func newEventSource(t *testing.T, reversed bool, annotations map[string]map[string]string) (datasource.DataSource, func(event) datasource.Data) {
+	t.Helper()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	fields := []datasource.StaticField{
+		&staticField{name: "pid", size: 4, offset: 0, kind: api.Kind_Uint32, tags: []string{api.TagSrcEbpf}},
+		&staticField{name: "comm", size: 8, offset: 4, kind: api.Kind_CString, tags: []string{api.TagSrcEbpf}},
+		&staticField{name: "timestamp", size: 8, offset: 12, kind: api.Kind_Uint64, tags: []string{api.TagSrcEbpf, timestampTag}},
+	}
+	if reversed {
+		fields[0], fields[2] = fields[2], fields[0]
+	}
+	for _, f := range fields {
+		sf := f.(*staticField)
+		sf.annotations = annotations[sf.name]
+	}
+
+	acc, err := ds.AddStaticFields(20, fields)
+	require.NoError(t, err)
+
+	return ds, func(e event) datasource.Data {
+		b := make([]byte, 20)
+		binary.NativeEndian.PutUint32(b[0:], e.pid)
+		copy(b[4:11], e.comm)
+		b[10] = e.garbage
+		binary.NativeEndian.PutUint64(b[12:], e.timestamp)
+
+		data, err := ds.NewPacketSingle()
+		require.NoError(t, err)
+		require.NoError(t, acc.Set(data, b))
+		return data
+	}
+}
+
+func hashOf(t *testing.T, ds datasource.DataSource, data datasource.Data) string {
+	t.Helper()
+
+	h, err := newHasher(ds, hashedFields(ds))
+	require.NoError(t, err)
+	return h.hash(data)
+}
+
+func TestHashedFields(t *testing.T) {
+	t.Parallel()
+
+	ds, _ := newEventSource(t, false, nil)
+	names := []string{}
+	for _, f := range hashedFields(ds) {
+		names = append(names, f.FullName())
+	}
+	require.Equal(t, []string{"comm", "pid"}, names)
+
+	ds, _ = newEventSource(t, false, map[string]map[string]string{
+		"pid": {includeAnnotation: "false"},
+	})
+	names = []string{}
+	for _, f := range hashedFields(ds) {
+		names = append(names, f.FullName())
+	}
+	require.Equal(t, []string{"comm"}, names)
+
+	ds, _ = newEventSource(t, false, map[string]map[string]string{
+		"timestamp": {includeAnnotation: "true"},
+	})
+	names = []string{}
+	for _, f := range hashedFields(ds) {
+		names = append(names, f.FullName())
+	}
+	require.Equal(t, []string{"timestamp"}, names)
+}
+
+func TestHash(t *testing.T) {
+	t.Parallel()
+
+	base := event{pid: 42, comm: "cat", timestamp: 1000}
+
+	ds, newData := newEventSource(t, false, nil)
+	expected := hashOf(t, ds, newData(base))
+
+	// Pin the encoding: if this changes, EncodingVersion must be bumped
+	require.Equal(t, "c499e6179cbcc879604aa48aec08ee89", expected)
+
+	type testCase struct {
+		reversed bool
+		event    event
+		equal    bool
+	}
+
+	tests := map[string]testCase{
+		"same": {
+			event: base,
+			equal: true,
+		},
+		"field_order": {
+			reversed: true,
+			event:    base,
+			equal:    true,
+		},
+		"timestamp": {
+			event: event{pid: 42, comm: "cat", timestamp: 2000},
+			equal: true,
+		},
+		"after_nul": {
+			event: event{pid: 42, comm: "cat", timestamp: 1000, garbage: 'x'},
+			equal: true,
+		},
+		"pid": {
+			event: event{pid: 43, comm: "cat", timestamp: 1000},
+		},
+		"comm": {
+			event: event{pid: 42, comm: "dog", timestamp: 1000},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ds, newData := newEventSource(t, test.reversed, nil)
+			hash := hashOf(t, ds, newData(test.event))
+			if test.equal {
+				require.Equal(t, expected, hash)
+			} else {
+				require.NotEqual(t, expected, hash)
+			}
+		})
+	}
+}
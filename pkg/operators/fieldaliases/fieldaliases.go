@@ -0,0 +1,147 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fieldaliases provides an operator that keeps former names of
+// renamed fields working: when enabled, aliases are accepted wherever fields
+// are looked up by name (filters, sorting, column selection) and emitted in
+// JSON output next to the canonical name.
+package fieldaliases
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	// Priority needs to be lower than the one of the operators looking fields
+	// up by name
+	Priority = -100
+
+	ParamFieldAliases = "field-aliases"
+)
+
+type fieldAliasesOperator struct{}
+
+func (o *fieldAliasesOperator) Name() string {
+	return "fieldaliases"
+}
+
+func (o *fieldAliasesOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *fieldAliasesOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *fieldAliasesOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamFieldAliases,
+			Title:        "Field Aliases",
+			DefaultValue: "false",
+			TypeHint:     api.TypeBool,
+			Description: "Accept the former names of renamed fields in filters, sorting and field selection, " +
+				"and include them in JSON output. Aliases are deprecated and will eventually be removed",
+		},
+	}
+}
+
+func (o *fieldAliasesOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	if enabled, _ := strconv.ParseBool(instanceParamValues[ParamFieldAliases]); !enabled {
+		return nil, nil
+	}
+
+	var dataSources []datasource.DataSource
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, f := range ds.Accessors(false) {
+			if len(datasource.FieldAliases(f)) > 0 {
+				ds.AddAnnotation(datasource.AliasesEnabledAnnotation, "true")
+				dataSources = append(dataSources, ds)
+				break
+			}
+		}
+	}
+
+	// Don't run, if we don't have anything to do
+	if len(dataSources) == 0 {
+		return nil, nil
+	}
+
+	return &fieldAliasesOperatorInstance{dataSources: dataSources}, nil
+}
+
+func (o *fieldAliasesOperator) Priority() int {
+	return Priority
+}
+
+type fieldAliasesOperatorInstance struct {
+	dataSources []datasource.DataSource
+}
+
+func (o *fieldAliasesOperatorInstance) Name() string {
+	return "fieldaliases"
+}
+
+func (o *fieldAliasesOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *fieldAliasesOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *fieldAliasesOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	// Other operators look fields up until they're started, so by now all
+	// usages have been counted
+	o.warnUsages(gadgetCtx)
+	return nil
+}
+
+func (o *fieldAliasesOperatorInstance) warnUsages(gadgetCtx operators.GadgetContext) {
+	for _, ds := range o.dataSources {
+		counter, ok := ds.(datasource.AliasUsageCounter)
+		if !ok {
+			continue
+		}
+
+		usages := counter.AliasUsages()
+		aliases := make([]string, 0, len(usages))
+		for alias := range usages {
+			aliases = append(aliases, alias)
+		}
+		sort.Strings(aliases)
+
+		canonical := make(map[string]string)
+		for _, f := range ds.Accessors(false) {
+			for _, alias := range datasource.FieldAliases(f) {
+				canonical[alias] = f.FullName()
+			}
+		}
+
+		for _, alias := range aliases {
+			gadgetCtx.Logger().Warnf("%s: field name %q is deprecated, use %q instead (used %d times)",
+				ds.Name(), alias, canonical[alias], usages[alias])
+		}
+	}
+}
+
+func init() {
+	operators.RegisterDataOperator(&fieldAliasesOperator{})
+}
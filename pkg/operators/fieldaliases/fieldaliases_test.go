@@ -0,0 +1,82 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fieldaliases
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+func TestFieldAliases(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		enabled bool
+	}
+
+	tests := map[string]testCase{
+		"enabled":  {enabled: true},
+		"disabled": {enabled: false},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var out bytes.Buffer
+			logger := log.New()
+			logger.SetOutput(&out)
+
+			gadgetCtx := gadgetcontext.New(context.Background(), "", gadgetcontext.WithLogger(logger))
+			ds, err := gadgetCtx.RegisterDataSource(datasource.TypeSingle, "open")
+			require.NoError(t, err)
+			_, err = ds.AddField("filename", api.Kind_String, datasource.WithAnnotations(map[string]string{
+				datasource.AliasesAnnotation: "fname",
+			}))
+			require.NoError(t, err)
+
+			op := &fieldAliasesOperator{}
+			instance, err := op.InstantiateDataOperator(gadgetCtx, api.ParamValues{
+				ParamFieldAliases: strconv.FormatBool(test.enabled),
+			})
+			require.NoError(t, err)
+
+			if !test.enabled {
+				require.Nil(t, instance)
+				require.Nil(t, ds.GetField("fname"))
+				return
+			}
+
+			// Selecting by alias returns the renamed field
+			f := ds.GetField("fname")
+			require.NotNil(t, f)
+			require.Equal(t, "filename", f.FullName())
+			require.NotNil(t, ds.GetField("fname"))
+
+			require.NoError(t, instance.Stop(gadgetCtx))
+			require.Contains(t, out.String(), `open: field name \"fname\" is deprecated, use \"filename\" instead (used 2 times)`)
+		})
+	}
+}
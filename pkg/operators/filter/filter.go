@@ -22,6 +22,7 @@ import (
 
 	"golang.org/x/exp/constraints"
 
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
@@ -73,6 +74,8 @@ func (f *filterOperator) InstanceParams() api.Params {
     columnName<value      - matches, if the content of columnName is less than the value
     columnName~value      - matches, if the content of columnName matches the regular expression 'value'
                  see [https://github.com/google/re2/wiki/Syntax] for more information on the syntax
+  value can be a template that is resolved for each container, e.g.
+    exe=={{ .Container.Image.Entrypoint }} or port=={{ .Pod.Ports[0] }}
         `,
 		Alias: "F",
 	}}
@@ -106,6 +109,9 @@ func (f *filterOperator) Priority() int {
 
 type filterOperatorInstance struct {
 	ffns map[datasource.DataSource][]func(datasource.DataSource, datasource.Data) bool
+
+	// templateFilters are resolved for each container
+	templateFilters []*templateFilter
 }
 
 func (f *filterOperatorInstance) Name() string {
@@ -113,6 +119,17 @@ func (f *filterOperatorInstance) Name() string {
 }
 
 func (f *filterOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if len(f.templateFilters) > 0 {
+		lookup, ok := gadgetCtx.GetVar(containertemplate.LookupVar)
+		if !ok {
+			return fmt.Errorf("filters using templates need container information, which isn't available")
+		}
+		for _, tf := range f.templateFilters {
+			tf.lookup = lookup.(containertemplate.LookupFunc)
+			tf.logger = gadgetCtx.Logger()
+		}
+	}
+
 	for ds, funcs := range f.ffns {
 		funcs := funcs
 		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
@@ -254,6 +271,22 @@ func (f *filterOperatorInstance) addFilter(gadgetCtx operators.GadgetContext, fi
 	if field == nil {
 		return fmt.Errorf("field %q not found", fieldName)
 	}
+	if !datasource.IsFilterable(field) {
+		return fmt.Errorf("field %q is not filterable", fieldName)
+	}
+	if datasource.IsDeprecated(field) {
+		gadgetCtx.Logger().Warnf("%s: field %q is deprecated and will be removed", filterds.Name(), fieldName)
+	}
+
+	if containertemplate.IsTemplate(value) {
+		tf, err := newTemplateFilter(filterds, field, op, negate, value)
+		if err != nil {
+			return fmt.Errorf("filter rule %q: %w", filter, err)
+		}
+		f.templateFilters = append(f.templateFilters, tf)
+		f.ffns[filterds] = append(f.ffns[filterds], tf.match)
+		return nil
+	}
 
 	ff, err := getFilterFunc(field, op, negate, value)
 	if err != nil {
@@ -264,6 +297,30 @@ func (f *filterOperatorInstance) addFilter(gadgetCtx operators.GadgetContext, fi
 	return nil
 }
 
+// NewSelector returns a function matching the data of ds against a rule
+// using the same syntax as the filter parameter, like "comm==curl". Other
+// operators use it to select the events they act on.
+func NewSelector(ds datasource.DataSource, rule string) (func(datasource.DataSource, datasource.Data) bool, error) {
+	dsName, fieldName, op, negate, value, err := extractFilter(rule)
+	if err != nil {
+		return nil, fmt.Errorf("extracting selector rule %q: %w", rule, err)
+	}
+	if dsName != "" && dsName != ds.Name() {
+		return nil, fmt.Errorf("selector rule %q: data source %q doesn't match %q", rule, dsName, ds.Name())
+	}
+
+	field := ds.GetField(fieldName)
+	if field == nil {
+		return nil, fmt.Errorf("selector rule %q: field %q not found", rule, fieldName)
+	}
+
+	ff, err := getFilterFunc(field, op, negate, value)
+	if err != nil {
+		return nil, fmt.Errorf("selector rule %q: %w", rule, err)
+	}
+	return ff, nil
+}
+
 func getFilterFunc(f datasource.FieldAccessor, op comparisonType, negate bool, stringVal string) (
 	func(datasource.DataSource, datasource.Data) bool, error,
 ) {
@@ -509,3 +509,95 @@ func Tester(
 
 	return gadgetCtx.Run(paramValues)
 }
+
+func TestFilterOutputName(t *testing.T) {
+	type testCase struct {
+		filterString string
+		match        bool
+		error        bool
+	}
+
+	// The output name only changes the key in JSON, filters use the name
+	tests := map[string]testCase{
+		"name_match":    {filterString: "saddr_v4==167772161", match: true},
+		"name_no_match": {filterString: "saddr_v4==1"},
+		"output_name":   {filterString: "srcAddr==167772161", error: true},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			var ds datasource.DataSource
+			var addrField datasource.FieldAccessor
+			rows := 0
+			err := Tester(
+				t,
+				&filterOperator{},
+				api.ParamValues{
+					"operator.filter.filter": test.filterString,
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					var err error
+					ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "filter")
+					require.NoError(t, err)
+					addrField, err = ds.AddField("saddr_v4", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+						datasource.OutputNameAnnotation: "srcAddr",
+					}))
+					require.NoError(t, err)
+					return nil
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					data, err := ds.NewPacketSingle()
+					require.NoError(t, err)
+					require.NoError(t, addrField.PutUint32(data, 0x0a000001))
+					require.NoError(t, ds.EmitAndRelease(data))
+					return nil
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					return ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+						rows++
+						return nil
+					}, Priority+1)
+				},
+			)
+			if test.error {
+				require.ErrorContains(t, err, `field "srcAddr" not found`)
+				return
+			}
+			require.NoError(t, err)
+			if test.match {
+				require.Equal(t, 1, rows)
+			} else {
+				require.Equal(t, 0, rows)
+			}
+		})
+	}
+}
+
+func TestFilterNotFilterable(t *testing.T) {
+	var ds datasource.DataSource
+	err := Tester(
+		t,
+		&filterOperator{},
+		api.ParamValues{
+			"operator.filter.filter": "src==10.0.0.1:80",
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "filter")
+			require.NoError(t, err)
+			_, err = ds.AddField("src", api.Kind_String, datasource.WithAnnotations(map[string]string{
+				datasource.FilterableAnnotation: "false",
+			}))
+			require.NoError(t, err)
+			return nil
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			return nil
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			return nil
+		},
+	)
+	require.ErrorContains(t, err, `field "src" is not filterable`)
+}
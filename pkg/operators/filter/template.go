@@ -0,0 +1,115 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"fmt"
+	"sync"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/compat"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+)
+
+// resolvedFilter is the filter function of a container; fn is nil if the
+// template couldn't be resolved for it
+type resolvedFilter struct {
+	container *containercollection.Container
+	fn        func(datasource.DataSource, datasource.Data) bool
+}
+
+// templateFilter is a filter whose value is a template resolved for the
+// container that generated each event. Events from containers the template
+// can't be resolved for are dropped.
+type templateFilter struct {
+	field      datasource.FieldAccessor
+	mntnsField datasource.FieldAccessor
+	op         comparisonType
+	negate     bool
+	tmpl       *containertemplate.Template
+
+	lookup containertemplate.LookupFunc
+	logger logger.Logger
+
+	mu       sync.Mutex
+	resolved map[uint64]*resolvedFilter
+}
+
+func newTemplateFilter(ds datasource.DataSource, field datasource.FieldAccessor, op comparisonType, negate bool, value string) (*templateFilter, error) {
+	tmpl, err := containertemplate.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	mntnsFields := ds.GetFieldsWithTag(compat.MntNsIdType)
+	if len(mntnsFields) == 0 {
+		return nil, fmt.Errorf("templates need a field with %q in data source %q", compat.MntNsIdType, ds.Name())
+	}
+
+	return &templateFilter{
+		field:      field,
+		mntnsField: mntnsFields[0],
+		op:         op,
+		negate:     negate,
+		tmpl:       tmpl,
+		resolved:   make(map[uint64]*resolvedFilter),
+	}, nil
+}
+
+// filterFor returns the filter function for the container with the given
+// mount namespace, resolving the template the first time it's seen
+func (t *templateFilter) filterFor(mntns uint64) func(datasource.DataSource, datasource.Data) bool {
+	container := t.lookup(mntns)
+	if container == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Check the container as well, the mount namespace id can be reused
+	if r, ok := t.resolved[mntns]; ok && r.container == container {
+		return r.fn
+	}
+
+	r := &resolvedFilter{container: container}
+	t.resolved[mntns] = r
+
+	value, err := t.tmpl.Resolve(container)
+	if err != nil {
+		t.logger.Warnf("skipping container %q: %s", container.K8s.ContainerName, err)
+		return nil
+	}
+
+	r.fn, err = getFilterFunc(t.field, t.op, t.negate, value)
+	if err != nil {
+		t.logger.Warnf("skipping container %q: template %q resolved to %q: %s",
+			container.K8s.ContainerName, t.tmpl, value, err)
+		return nil
+	}
+
+	return r.fn
+}
+
+func (t *templateFilter) match(ds datasource.DataSource, data datasource.Data) bool {
+	mntns, _ := t.mntnsField.Uint64(data)
+	fn := t.filterFor(mntns)
+	if fn == nil {
+		return false
+	}
+	return fn(ds, data)
+}
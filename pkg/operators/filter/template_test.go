@@ -0,0 +1,122 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package filter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource/compat"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/types"
+)
+
+func TestTemplateFilter(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+
+	mntnsField, err := ds.AddField("mntns_id", api.Kind_Uint64, datasource.WithTags(compat.MntNsIdType))
+	require.NoError(t, err)
+	portField, err := ds.AddField("port", api.Kind_Uint16)
+	require.NoError(t, err)
+
+	containers := map[uint64]*containercollection.Container{
+		1: {K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{ContainerName: "web"},
+			PodPorts:         []int32{80},
+		}},
+		2: {K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{ContainerName: "db"},
+			PodPorts:         []int32{5432},
+		}},
+		// Can't be resolved
+		3: {K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{ContainerName: "noports"},
+		}},
+		// Resolves to a value that isn't valid for the field
+		4: {K8s: containercollection.K8sMetadata{
+			BasicK8sMetadata: types.BasicK8sMetadata{ContainerName: "invalid"},
+			PodPorts:         []int32{100000},
+		}},
+	}
+
+	tf, err := newTemplateFilter(ds, portField, comparisonTypeMatch, false, "{{ .Pod.Ports[0] }}")
+	require.NoError(t, err)
+	tf.logger = logger.DefaultLogger()
+	tf.lookup = func(mntns uint64) *containercollection.Container {
+		return containers[mntns]
+	}
+
+	type testCase struct {
+		mntns    uint64
+		port     uint16
+		expected bool
+	}
+
+	tests := map[string]testCase{
+		"web_match":    {mntns: 1, port: 80, expected: true},
+		"web_no_match": {mntns: 1, port: 5432},
+		"db_match":     {mntns: 2, port: 5432, expected: true},
+		"db_no_match":  {mntns: 2, port: 80},
+		"unresolved":   {mntns: 3, port: 80},
+		"invalid":      {mntns: 4, port: 80},
+		"no_container": {mntns: 5, port: 80},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := ds.NewPacketSingle()
+			require.NoError(t, err)
+			require.NoError(t, mntnsField.PutUint64(data, test.mntns))
+			require.NoError(t, portField.PutUint16(data, test.port))
+
+			require.Equal(t, test.expected, tf.match(ds, data))
+		})
+	}
+}
+
+func TestTemplateFilterValidation(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	portField, err := ds.AddField("port", api.Kind_Uint16)
+	require.NoError(t, err)
+
+	// No mount namespace field
+	_, err = newTemplateFilter(ds, portField, comparisonTypeMatch, false, "{{ .Pod.Ports[0] }}")
+	require.Error(t, err)
+
+	_, err = ds.AddField("mntns_id", api.Kind_Uint64, datasource.WithTags(compat.MntNsIdType))
+	require.NoError(t, err)
+
+	_, err = newTemplateFilter(ds, portField, comparisonTypeMatch, false, "{{ .Pod.Ports[0] }}")
+	require.NoError(t, err)
+
+	_, err = newTemplateFilter(ds, portField, comparisonTypeMatch, false, "{{ .Pod.Port }}")
+	require.Error(t, err)
+
+	_, err = newTemplateFilter(ds, portField, comparisonTypeMatch, false, "{{ .Pod.Ports[0]")
+	require.Error(t, err)
+}
@@ -17,6 +17,7 @@ package formatters
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -58,10 +59,45 @@ const (
 
 const (
 	timestampTargetAnnotation = "formatters.timestamp.target"
+	timestampFormatAnnotation = "formatters.timestamp.format"
 	syscallTargetAnnotation   = "formatters.syscall.target"
 	signalTargetAnnotation    = "formatters.signal.target"
 )
 
+// Special values of the formatters.timestamp.format annotation, any other value
+// is used as layout for time.Format
+const (
+	// Nanoseconds since the epoch
+	timestampFormatRaw = "raw"
+	// RFC3339 with nanoseconds
+	timestampFormatRFC3339 = "rfc3339"
+	// Time elapsed since the gadget started
+	timestampFormatRelative = "relative"
+)
+
+// timestampFormatter returns a function rendering wall-clock times in the
+// given format. start is the reference for relative timestamps.
+func timestampFormatter(format string, start time.Time) func(time.Time) string {
+	switch format {
+	case timestampFormatRaw:
+		return func(t time.Time) string {
+			return strconv.FormatInt(t.UnixNano(), 10)
+		}
+	case timestampFormatRFC3339:
+		return func(t time.Time) string {
+			return t.Format(time.RFC3339Nano)
+		}
+	case timestampFormatRelative:
+		return func(t time.Time) string {
+			return t.Sub(start).String()
+		}
+	default:
+		return func(t time.Time) string {
+			return t.Format(format)
+		}
+	}
+}
+
 type formattersOperator struct{}
 
 func (f *formattersOperator) Name() string {
@@ -272,10 +308,11 @@ var replacers = []replacer{
 		selectors: []string{"type:" + TimestampTypeName},
 		replace: func(logger logger.Logger, ds datasource.DataSource, in datasource.FieldAccessor) (func(data datasource.Data) error, error) {
 			timestampFormat := "2006-01-02T15:04:05.000000000Z07:00"
-			if format := in.Annotations()["formatters.timestamp.format"]; format != "" {
+			if format := in.Annotations()[timestampFormatAnnotation]; format != "" {
 				logger.Debugf("formatter.timestamp: using custom timestamp format %q for field %q", format, in.Name())
 				timestampFormat = format
 			}
+			format := timestampFormatter(timestampFormat, time.Now())
 
 			outName, err := annotations.GetTargetNameFromAnnotation(logger, "formatters.timestamp", in, timestampTargetAnnotation)
 			if err != nil {
@@ -306,11 +343,11 @@ var replacers = []replacer{
 					correctedTime := gadgets.WallTimeFromBootTime(ds.ByteOrder().Uint64(inBytes))
 					ds.ByteOrder().PutUint64(inBytes, uint64(correctedTime))
 					t := time.Unix(0, int64(correctedTime))
-					if err := out.Set(data, []byte(t.Format(timestampFormat))); err != nil {
-						multierror.Append(result, err)
+					if err := out.Set(data, []byte(format(t))); err != nil {
+						result = multierror.Append(result, err)
 					}
 					if err := in.PutUint64(data, uint64(correctedTime)); err != nil {
-						multierror.Append(result, err)
+						result = multierror.Append(result, err)
 					}
 
 					return result
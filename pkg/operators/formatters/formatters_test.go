@@ -0,0 +1,62 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatters
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampFormatter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	ts := start.Add(1500 * time.Millisecond)
+
+	type testCase struct {
+		format   string
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"raw": {
+			format:   "raw",
+			expected: "1715342401500000000",
+		},
+		"rfc3339": {
+			format:   "rfc3339",
+			expected: "2024-05-10T12:00:01.5Z",
+		},
+		"relative": {
+			format:   "relative",
+			expected: "1.5s",
+		},
+		"layout": {
+			format:   "15:04:05.000",
+			expected: "12:00:01.500",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, timestampFormatter(test.format, start)(ts))
+		})
+	}
+}
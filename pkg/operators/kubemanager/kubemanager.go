@@ -379,12 +379,15 @@ func (k *KubeManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext,
 	}
 
 	activate := false
+	// canFilter is set when the gadget filters events by container
+	canFilter := false
 
 	// Check, whether the gadget requested a map from us
 	if t, ok := gadgetCtx.GetVar(gadgets.MntNsFilterMapName); ok {
 		if _, ok := t.(*ebpf.Map); ok {
 			gadgetCtx.Logger().Debugf("gadget requested map %s", gadgets.MntNsFilterMapName)
 			activate = true
+			canFilter = true
 		}
 	}
 
@@ -393,6 +396,7 @@ func (k *KubeManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext,
 	if val, ok := gadgetCtx.GetVar("NeedContainerEvents"); ok {
 		if b, ok := val.(bool); ok && b {
 			activate = true
+			canFilter = true
 		}
 	}
 
@@ -405,6 +409,15 @@ func (k *KubeManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext,
 		activate = true
 	}
 
+	filterParams := map[string]string{
+		ParamContainerName: params.Get(ParamContainerName).AsString(),
+		ParamPodName:       params.Get(ParamPodName).AsString(),
+		ParamSelector:      strings.Join(params.Get(ParamSelector).AsStringSlice(), ","),
+	}
+	if err := gadgets.CheckContainerFilter(canFilter, filterParams); err != nil {
+		return nil, err
+	}
+
 	if !activate {
 		return nil, nil
 	}
@@ -0,0 +1,314 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package l7parser implements a data operator that extracts L7 protocol
+// details (HTTP method/path/status, DNS query name/type and TLS SNI) from the
+// payload prefixes captured by gadgets.
+//
+// Fields are selected using annotations:
+//
+//	l7parser.protocol: auto|http|dns|tls (required)
+//	l7parser.lengthField: name of the field holding the number of valid bytes
+//	l7parser.maxBytes: maximum number of bytes to inspect
+//
+// The extracted data is stored in new fields named after the source field,
+// e.g. payload_http_method.
+package l7parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	ParamParsers = "l7-parsers"
+
+	protocolAnnotation    = "l7parser.protocol"
+	lengthFieldAnnotation = "l7parser.lengthField"
+	maxBytesAnnotation    = "l7parser.maxBytes"
+
+	// defaultMaxBytes bounds the work done for each event
+	defaultMaxBytes = 512
+)
+
+type l7ParserOperator struct{}
+
+func (o *l7ParserOperator) Name() string {
+	return "l7parser"
+}
+
+func (o *l7ParserOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *l7ParserOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *l7ParserOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamParsers,
+			Title:        "L7 Parsers",
+			DefaultValue: strings.Join([]string{ProtocolHTTP, ProtocolDNS, ProtocolTLS}, ","),
+			Description: "Comma-separated list of L7 parsers to run on captured payloads. Valid values: " +
+				strings.Join([]string{ProtocolHTTP, ProtocolDNS, ProtocolTLS}, ", ") + ". Leave empty to disable parsing",
+		},
+	}
+}
+
+func parseEnabled(value string) (map[string]bool, error) {
+	enabled := make(map[string]bool)
+	for _, p := range strings.Split(value, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if _, ok := parsers[p]; !ok {
+			return nil, fmt.Errorf("invalid L7 parser %q", p)
+		}
+		enabled[p] = true
+	}
+	return enabled, nil
+}
+
+func (o *l7ParserOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	enabled, err := parseEnabled(instanceParamValues[ParamParsers])
+	if err != nil {
+		return nil, err
+	}
+	if len(enabled) == 0 {
+		return nil, nil
+	}
+
+	inst := &l7ParserOperatorInstance{
+		extractors: make(map[datasource.DataSource][]*extractor),
+	}
+
+	logger := gadgetCtx.Logger()
+	for _, ds := range gadgetCtx.GetDataSources() {
+		for _, field := range ds.Accessors(false) {
+			protocol, ok := field.Annotations()[protocolAnnotation]
+			if !ok {
+				continue
+			}
+			ex, err := newExtractor(logger, ds, field, protocol, enabled)
+			if err != nil {
+				logger.Warnf("l7parser: skipping field %q: %v", field.FullName(), err)
+				continue
+			}
+			if ex == nil {
+				continue
+			}
+			inst.extractors[ds] = append(inst.extractors[ds], ex)
+		}
+	}
+
+	// Don't run, if we don't have anything to do
+	if len(inst.extractors) == 0 {
+		return nil, nil
+	}
+
+	return inst, nil
+}
+
+func (o *l7ParserOperator) Priority() int {
+	return 0
+}
+
+type extractor struct {
+	in       datasource.FieldAccessor
+	length   datasource.FieldAccessor
+	maxBytes int
+	protocol string
+	enabled  map[string]bool
+
+	proto      datasource.FieldAccessor
+	httpMethod datasource.FieldAccessor
+	httpPath   datasource.FieldAccessor
+	httpStatus datasource.FieldAccessor
+	dnsQName   datasource.FieldAccessor
+	dnsQType   datasource.FieldAccessor
+	tlsSNI     datasource.FieldAccessor
+}
+
+func newExtractor(
+	logger logger.Logger,
+	ds datasource.DataSource,
+	in datasource.FieldAccessor,
+	protocol string,
+	enabled map[string]bool,
+) (*extractor, error) {
+	if protocol != ProtocolAuto {
+		if _, ok := parsers[protocol]; !ok {
+			return nil, fmt.Errorf("invalid protocol %q", protocol)
+		}
+		if !enabled[protocol] {
+			logger.Debugf("l7parser: parser %q disabled, ignoring field %q", protocol, in.FullName())
+			return nil, nil
+		}
+	}
+
+	ex := &extractor{
+		in:       in,
+		maxBytes: defaultMaxBytes,
+		protocol: protocol,
+		enabled:  enabled,
+	}
+
+	annotations := in.Annotations()
+	if s := annotations[maxBytesAnnotation]; s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid %s %q", maxBytesAnnotation, s)
+		}
+		ex.maxBytes = n
+	}
+
+	if name := annotations[lengthFieldAnnotation]; name != "" {
+		ex.length = ds.GetField(name)
+		if ex.length == nil {
+			return nil, fmt.Errorf("length field %q not found", name)
+		}
+		switch ex.length.Type() {
+		case api.Kind_Uint8, api.Kind_Uint16, api.Kind_Uint32, api.Kind_Uint64:
+		default:
+			return nil, fmt.Errorf("length field %q must be an unsigned integer", name)
+		}
+	}
+
+	// Only add the fields the selected parsers can fill
+	has := func(p string) bool {
+		return enabled[p] && (protocol == ProtocolAuto || protocol == p)
+	}
+
+	var err error
+	addField := func(suffix string, kind api.Kind) datasource.FieldAccessor {
+		if err != nil {
+			return nil
+		}
+		var f datasource.FieldAccessor
+		f, err = ds.AddField(in.Name()+"_"+suffix, kind)
+		return f
+	}
+
+	if protocol == ProtocolAuto {
+		ex.proto = addField("proto", api.Kind_String)
+	}
+	if has(ProtocolHTTP) {
+		ex.httpMethod = addField("http_method", api.Kind_String)
+		ex.httpPath = addField("http_path", api.Kind_String)
+		ex.httpStatus = addField("http_status", api.Kind_Uint16)
+	}
+	if has(ProtocolDNS) {
+		ex.dnsQName = addField("dns_qname", api.Kind_String)
+		ex.dnsQType = addField("dns_qtype", api.Kind_String)
+	}
+	if has(ProtocolTLS) {
+		ex.tlsSNI = addField("tls_sni", api.Kind_String)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("adding field: %w", err)
+	}
+
+	return ex, nil
+}
+
+func (ex *extractor) payload(data datasource.Data) []byte {
+	b := ex.in.Get(data)
+
+	if ex.length != nil {
+		var n uint64
+		switch ex.length.Type() {
+		case api.Kind_Uint8:
+			v, _ := ex.length.Uint8(data)
+			n = uint64(v)
+		case api.Kind_Uint16:
+			v, _ := ex.length.Uint16(data)
+			n = uint64(v)
+		case api.Kind_Uint32:
+			v, _ := ex.length.Uint32(data)
+			n = uint64(v)
+		case api.Kind_Uint64:
+			n, _ = ex.length.Uint64(data)
+		}
+		if n < uint64(len(b)) {
+			b = b[:n]
+		}
+	}
+
+	if len(b) > ex.maxBytes {
+		b = b[:ex.maxBytes]
+	}
+	return b
+}
+
+func (ex *extractor) extract(data datasource.Data) error {
+	r := parse(ex.payload(data), ex.protocol, ex.enabled)
+
+	if ex.proto != nil {
+		ex.proto.PutString(data, r.protocol)
+	}
+	if ex.httpMethod != nil {
+		ex.httpMethod.PutString(data, r.httpMethod)
+		ex.httpPath.PutString(data, r.httpPath)
+		ex.httpStatus.PutUint16(data, r.httpStatus)
+	}
+	if ex.dnsQName != nil {
+		ex.dnsQName.PutString(data, r.dnsQName)
+		ex.dnsQType.PutString(data, r.dnsQType)
+	}
+	if ex.tlsSNI != nil {
+		ex.tlsSNI.PutString(data, r.tlsSNI)
+	}
+	return nil
+}
+
+type l7ParserOperatorInstance struct {
+	extractors map[datasource.DataSource][]*extractor
+}
+
+func (o *l7ParserOperatorInstance) Name() string {
+	return "l7parser"
+}
+
+func (o *l7ParserOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for ds, extractors := range o.extractors {
+		for _, ex := range extractors {
+			ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				return ex.extract(data)
+			}, 0)
+		}
+	}
+	return nil
+}
+
+func (o *l7ParserOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *l7ParserOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func init() {
+	operators.RegisterDataOperator(&l7ParserOperator{})
+}
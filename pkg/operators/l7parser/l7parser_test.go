@@ -0,0 +1,201 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package l7parser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	gadgetcontext "github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-context"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/filter"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/simple"
+)
+
+func Tester(
+	t *testing.T,
+	dataOperators []operators.DataOperator,
+	paramValues api.ParamValues,
+	prepare func(operators.GadgetContext) error,
+	produce func(operators.GadgetContext) error,
+	verify func(operators.GadgetContext) error,
+) error {
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	producer := simple.New("producer",
+		simple.WithPriority(-1),
+		simple.OnInit(prepare),
+		simple.OnStart(produce),
+	)
+
+	verifier := simple.New("verifier",
+		simple.WithPriority(filter.Priority+1),
+		simple.OnInit(func(gadgetCtx operators.GadgetContext) error {
+			defer cancel()
+			return verify(gadgetCtx)
+		}),
+	)
+
+	dataOperators = append(dataOperators, producer, verifier)
+	gadgetCtx := gadgetcontext.New(ctx, "",
+		gadgetcontext.WithDataOperators(dataOperators...),
+	)
+
+	return gadgetCtx.Run(paramValues)
+}
+
+func TestL7ParserFields(t *testing.T) {
+	type testCase struct {
+		parsers  string
+		protocol string
+		expected []string
+	}
+
+	tests := map[string]testCase{
+		"auto": {
+			parsers:  "http,dns,tls",
+			protocol: ProtocolAuto,
+			expected: []string{
+				"payload_proto",
+				"payload_http_method", "payload_http_path", "payload_http_status",
+				"payload_dns_qname", "payload_dns_qtype",
+				"payload_tls_sni",
+			},
+		},
+		"auto_subset": {
+			parsers:  "dns",
+			protocol: ProtocolAuto,
+			expected: []string{"payload_proto", "payload_dns_qname", "payload_dns_qtype"},
+		},
+		"http": {
+			parsers:  "http,dns,tls",
+			protocol: ProtocolHTTP,
+			expected: []string{"payload_http_method", "payload_http_path", "payload_http_status"},
+		},
+		"http_disabled": {
+			parsers:  "dns,tls",
+			protocol: ProtocolHTTP,
+		},
+		"invalid_protocol": {
+			parsers:  "http,dns,tls",
+			protocol: "ftp",
+		},
+		"no_parsers": {
+			parsers:  "",
+			protocol: ProtocolAuto,
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var ds datasource.DataSource
+			var fields []string
+			err := Tester(
+				t,
+				[]operators.DataOperator{&l7ParserOperator{}},
+				api.ParamValues{
+					"operator.l7parser." + ParamParsers: test.parsers,
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					var err error
+					ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "l7")
+					require.NoError(t, err)
+					_, err = ds.AddField("payload", api.Kind_Bytes, datasource.WithAnnotations(map[string]string{
+						protocolAnnotation: test.protocol,
+					}))
+					require.NoError(t, err)
+					return nil
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					return nil
+				},
+				func(gadgetCtx operators.GadgetContext) error {
+					for _, f := range ds.Accessors(false) {
+						if f.Name() != "payload" {
+							fields = append(fields, f.Name())
+						}
+					}
+					return nil
+				},
+			)
+			require.NoError(t, err)
+			require.ElementsMatch(t, test.expected, fields)
+		})
+	}
+}
+
+// The filter operator must see the fields the parser fills, so the parser has
+// to run before it
+func TestL7ParserBeforeFilter(t *testing.T) {
+	require.Less(t, (&l7ParserOperator{}).Priority(), filter.Priority)
+
+	payloads := [][]byte{
+		[]byte("GET /index.html HTTP/1.1\r\n\r\n"),
+		[]byte("POST /api HTTP/1.1\r\n\r\n"),
+		dnsQuery,
+	}
+
+	var ds datasource.DataSource
+	var payload datasource.FieldAccessor
+	var paths []string
+	err := Tester(
+		t,
+		[]operators.DataOperator{&l7ParserOperator{}, operators.GetDataOperators()["filter"]},
+		api.ParamValues{
+			"operator.l7parser." + ParamParsers:     "http,dns",
+			"operator.filter." + filter.ParamFilter: "payload_http_method==GET",
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			var err error
+			ds, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "l7")
+			require.NoError(t, err)
+			payload, err = ds.AddField("payload", api.Kind_Bytes, datasource.WithAnnotations(map[string]string{
+				protocolAnnotation: ProtocolAuto,
+			}))
+			require.NoError(t, err)
+			return nil
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			for _, p := range payloads {
+				data, err := ds.NewPacketSingle()
+				require.NoError(t, err)
+				require.NoError(t, payload.PutBytes(data, p))
+				require.NoError(t, ds.EmitAndRelease(data))
+			}
+			return nil
+		},
+		func(gadgetCtx operators.GadgetContext) error {
+			path := ds.GetField("payload_http_path")
+			require.NotNil(t, path)
+			return ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				s, err := path.String(data)
+				require.NoError(t, err)
+				paths = append(paths, s)
+				return nil
+			}, filter.Priority+1)
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"/index.html"}, paths)
+}
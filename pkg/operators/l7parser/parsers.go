@@ -0,0 +1,355 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package l7parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	ProtocolHTTP = "http"
+	ProtocolDNS  = "dns"
+	ProtocolTLS  = "tls"
+	ProtocolAuto = "auto"
+)
+
+const (
+	// Maximum number of compression pointers followed while reading a DNS name
+	dnsMaxPointers = 8
+	// Maximum length of a DNS name as defined in RFC 1035
+	dnsMaxNameLen = 255
+	// Maximum number of TLS extensions inspected while looking for the SNI
+	tlsMaxExtensions = 64
+)
+
+// result holds the fields extracted from a payload. Fields that weren't found
+// are left empty.
+type result struct {
+	protocol string
+
+	httpMethod string
+	httpPath   string
+	httpStatus uint16
+
+	dnsQName string
+	dnsQType string
+
+	tlsSNI string
+}
+
+var httpMethods = [][]byte{
+	[]byte("GET "),
+	[]byte("POST "),
+	[]byte("PUT "),
+	[]byte("DELETE "),
+	[]byte("HEAD "),
+	[]byte("OPTIONS "),
+	[]byte("PATCH "),
+	[]byte("CONNECT "),
+	[]byte("TRACE "),
+}
+
+// parseHTTP extracts the method and path of a request or the status of a
+// response from the first line of an HTTP/1.x payload. Only the first message
+// is inspected when several are pipelined. A truncated request line still
+// yields the method and the part of the path that was captured.
+func parseHTTP(b []byte, r *result) bool {
+	// Only the first line is interesting
+	if i := bytes.Index(b, []byte("\r\n")); i >= 0 {
+		b = b[:i]
+	}
+
+	if bytes.HasPrefix(b, []byte("HTTP/1.")) {
+		// HTTP/1.1 200 OK
+		parts := bytes.SplitN(b, []byte(" "), 3)
+		if len(parts) < 2 || len(parts[1]) != 3 {
+			return false
+		}
+		var status uint16
+		for _, c := range parts[1] {
+			if c < '0' || c > '9' {
+				return false
+			}
+			status = status*10 + uint16(c-'0')
+		}
+		r.protocol = ProtocolHTTP
+		r.httpStatus = status
+		return true
+	}
+
+	for _, m := range httpMethods {
+		if !bytes.HasPrefix(b, m) {
+			continue
+		}
+		rest := b[len(m):]
+		if i := bytes.IndexByte(rest, ' '); i >= 0 {
+			rest = rest[:i]
+		}
+		if !isPrintable(rest) {
+			return false
+		}
+		r.protocol = ProtocolHTTP
+		r.httpMethod = string(m[:len(m)-1])
+		r.httpPath = string(rest)
+		return true
+	}
+
+	return false
+}
+
+func isPrintable(b []byte) bool {
+	for _, c := range b {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+var dnsTypes = map[uint16]string{
+	1:   "A",
+	2:   "NS",
+	5:   "CNAME",
+	6:   "SOA",
+	12:  "PTR",
+	15:  "MX",
+	16:  "TXT",
+	28:  "AAAA",
+	33:  "SRV",
+	65:  "HTTPS",
+	255: "ANY",
+}
+
+// parseDNS extracts the name and type of the first question of a DNS message
+// (without the TCP length prefix). Compressed names are supported, as long as
+// pointers point backwards and they're within the captured bytes.
+func parseDNS(b []byte, r *result) bool {
+	if len(b) < 12 {
+		return false
+	}
+
+	flags := binary.BigEndian.Uint16(b[2:4])
+	// Only standard queries (opcode 0) are supported
+	if (flags>>11)&0xf != 0 {
+		return false
+	}
+	qdcount := binary.BigEndian.Uint16(b[4:6])
+	if qdcount == 0 || qdcount > 16 {
+		return false
+	}
+
+	name, next, ok := readDNSName(b, 12)
+	if !ok || name == "" {
+		return false
+	}
+
+	r.protocol = ProtocolDNS
+	r.dnsQName = name
+
+	// The type could be missing if the capture was truncated
+	if next+2 <= len(b) {
+		qtype := binary.BigEndian.Uint16(b[next : next+2])
+		if s, ok := dnsTypes[qtype]; ok {
+			r.dnsQType = s
+		} else {
+			r.dnsQType = fmt.Sprintf("TYPE%d", qtype)
+		}
+	}
+
+	return true
+}
+
+// readDNSName reads the name starting at off and returns it along with the
+// offset of the first byte after it.
+func readDNSName(b []byte, off int) (string, int, bool) {
+	var sb strings.Builder
+	next := -1
+	pointers := 0
+
+	for {
+		if off >= len(b) {
+			return "", 0, false
+		}
+		l := int(b[off])
+		switch {
+		case l == 0:
+			if next < 0 {
+				next = off + 1
+			}
+			return sb.String(), next, true
+		case l&0xc0 == 0xc0:
+			if off+1 >= len(b) {
+				return "", 0, false
+			}
+			ptr := int(binary.BigEndian.Uint16(b[off:off+2]) & 0x3fff)
+			// Only allow backward pointers to avoid loops
+			if ptr >= off || pointers >= dnsMaxPointers {
+				return "", 0, false
+			}
+			pointers++
+			if next < 0 {
+				next = off + 2
+			}
+			off = ptr
+		case l&0xc0 != 0:
+			// Reserved label types
+			return "", 0, false
+		default:
+			if off+1+l > len(b) {
+				return "", 0, false
+			}
+			label := b[off+1 : off+1+l]
+			if !isPrintable(label) {
+				return "", 0, false
+			}
+			if sb.Len() > 0 {
+				sb.WriteByte('.')
+			}
+			sb.Write(label)
+			if sb.Len() > dnsMaxNameLen {
+				return "", 0, false
+			}
+			off += 1 + l
+		}
+	}
+}
+
+// parseTLS extracts the server name indication from a TLS ClientHello. The
+// whole ClientHello up to the SNI extension needs to be captured; fragmented
+// or truncated messages simply yield no SNI.
+func parseTLS(b []byte, r *result) bool {
+	// Record header: type (1), version (2), length (2)
+	if len(b) < 5 || b[0] != 0x16 || b[1] != 0x03 {
+		return false
+	}
+	b = b[5:]
+
+	// Handshake header: type (1), length (3)
+	if len(b) < 4 || b[0] != 0x01 {
+		return false
+	}
+	r.protocol = ProtocolTLS
+	b = b[4:]
+
+	// Client version (2) and random (32)
+	if len(b) < 34 {
+		return true
+	}
+	b = b[34:]
+
+	// Session ID, cipher suites and compression methods
+	var ok bool
+	if b, ok = skipVector(b, 1); !ok {
+		return true
+	}
+	if b, ok = skipVector(b, 2); !ok {
+		return true
+	}
+	if b, ok = skipVector(b, 1); !ok {
+		return true
+	}
+
+	if len(b) < 2 {
+		return true
+	}
+	extLen := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if extLen < len(b) {
+		b = b[:extLen]
+	}
+
+	for i := 0; i < tlsMaxExtensions && len(b) >= 4; i++ {
+		typ := binary.BigEndian.Uint16(b)
+		l := int(binary.BigEndian.Uint16(b[2:]))
+		b = b[4:]
+		if l > len(b) {
+			return true
+		}
+		if typ == 0 {
+			r.tlsSNI = parseSNIExtension(b[:l])
+			return true
+		}
+		b = b[l:]
+	}
+
+	return true
+}
+
+func parseSNIExtension(b []byte) string {
+	// Server name list length (2), name type (1), name length (2)
+	if len(b) < 5 || b[2] != 0 {
+		return ""
+	}
+	l := int(binary.BigEndian.Uint16(b[3:]))
+	if 5+l > len(b) || !isPrintable(b[5:5+l]) {
+		return ""
+	}
+	return string(b[5 : 5+l])
+}
+
+// skipVector skips a TLS variable-length vector whose length is encoded in
+// lenSize bytes.
+func skipVector(b []byte, lenSize int) ([]byte, bool) {
+	if len(b) < lenSize {
+		return nil, false
+	}
+	var l int
+	for i := 0; i < lenSize; i++ {
+		l = l<<8 | int(b[i])
+	}
+	b = b[lenSize:]
+	if l > len(b) {
+		return nil, false
+	}
+	return b[l:], true
+}
+
+// parsers maps each protocol to its parser. Keep ProtocolAuto out of it.
+var parsers = map[string]func([]byte, *result) bool{
+	ProtocolHTTP: parseHTTP,
+	ProtocolDNS:  parseDNS,
+	ProtocolTLS:  parseTLS,
+}
+
+// autoDetectOrder is the order in which parsers are tried when the protocol is
+// unknown. Parsers with the most specific signatures go first.
+var autoDetectOrder = []string{ProtocolTLS, ProtocolHTTP, ProtocolDNS}
+
+// parse runs the parser for protocol on b, or tries all the enabled ones if
+// protocol is ProtocolAuto. Binary or unknown payloads return an empty result.
+func parse(b []byte, protocol string, enabled map[string]bool) result {
+	var r result
+
+	if protocol != ProtocolAuto {
+		if enabled[protocol] {
+			parsers[protocol](b, &r)
+		}
+		return r
+	}
+
+	for _, p := range autoDetectOrder {
+		if !enabled[p] {
+			continue
+		}
+		if parsers[p](b, &r) {
+			return r
+		}
+		r = result{}
+	}
+	return r
+}
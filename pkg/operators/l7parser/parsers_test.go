@@ -0,0 +1,211 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package l7parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var allParsers = map[string]bool{
+	ProtocolHTTP: true,
+	ProtocolDNS:  true,
+	ProtocolTLS:  true,
+}
+
+// dnsQuery for example.com, type AAAA
+var dnsQuery = []byte{
+	0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0,
+	0x00, 0x1c, 0x00, 0x01,
+}
+
+func clientHello(sni string) []byte {
+	ext := []byte{
+		0x00, 0x00, // extension type: server_name
+		0x00, byte(len(sni) + 5), // extension length
+		0x00, byte(len(sni) + 3), // server name list length
+		0x00,                 // name type: host_name
+		0x00, byte(len(sni)), // name length
+	}
+	ext = append(ext, sni...)
+
+	body := []byte{0x03, 0x03}                  // client version
+	body = append(body, make([]byte, 32)...)    // random
+	body = append(body, 0x00)                   // session id
+	body = append(body, 0x00, 0x02, 0x13, 0x01) // cipher suites
+	body = append(body, 0x01, 0x00)             // compression methods
+	// an unrelated extension first: supported_versions
+	exts := []byte{0x00, 0x2b, 0x00, 0x03, 0x02, 0x03, 0x04}
+	exts = append(exts, ext...)
+	body = append(body, byte(len(exts)>>8), byte(len(exts)))
+	body = append(body, exts...)
+
+	hs := []byte{0x01, 0x00, byte(len(body) >> 8), byte(len(body))}
+	hs = append(hs, body...)
+
+	rec := []byte{0x16, 0x03, 0x01, byte(len(hs) >> 8), byte(len(hs))}
+	return append(rec, hs...)
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	hello := clientHello("example.com")
+
+	type testCase struct {
+		payload  []byte
+		protocol string
+		enabled  map[string]bool
+		expected result
+	}
+
+	tests := map[string]testCase{
+		"http_request": {
+			payload:  []byte("GET /index.html HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolHTTP, httpMethod: "GET", httpPath: "/index.html"},
+		},
+		"http_request_truncated": {
+			payload:  []byte("POST /api/v1/na"),
+			protocol: ProtocolHTTP,
+			expected: result{protocol: ProtocolHTTP, httpMethod: "POST", httpPath: "/api/v1/na"},
+		},
+		"http_pipelined": {
+			payload:  []byte("GET /a HTTP/1.1\r\n\r\nGET /b HTTP/1.1\r\n\r\n"),
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolHTTP, httpMethod: "GET", httpPath: "/a"},
+		},
+		"http_response": {
+			payload:  []byte("HTTP/1.1 404 Not Found\r\n"),
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolHTTP, httpStatus: 404},
+		},
+		"http_invalid_status": {
+			payload:  []byte("HTTP/1.1 4x4 Nope\r\n"),
+			protocol: ProtocolHTTP,
+			expected: result{},
+		},
+		"dns_query": {
+			payload:  dnsQuery,
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolDNS, dnsQName: "example.com", dnsQType: "AAAA"},
+		},
+		"dns_unknown_type": {
+			payload:  append(append([]byte{}, dnsQuery[:25]...), 0x12, 0x34),
+			protocol: ProtocolDNS,
+			expected: result{protocol: ProtocolDNS, dnsQName: "example.com", dnsQType: "TYPE4660"},
+		},
+		"dns_truncated_type": {
+			payload:  dnsQuery[:25],
+			protocol: ProtocolDNS,
+			expected: result{protocol: ProtocolDNS, dnsQName: "example.com"},
+		},
+		"dns_truncated_name": {
+			payload:  dnsQuery[:18],
+			protocol: ProtocolDNS,
+			expected: result{},
+		},
+		"dns_pointer_loop": {
+			payload: []byte{
+				0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0xc0, 0x0c,
+			},
+			protocol: ProtocolDNS,
+			expected: result{},
+		},
+		"tls_client_hello": {
+			payload:  hello,
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolTLS, tlsSNI: "example.com"},
+		},
+		"tls_fragment": {
+			payload:  hello[:60],
+			protocol: ProtocolAuto,
+			expected: result{protocol: ProtocolTLS},
+		},
+		"tls_truncated_sni": {
+			payload:  hello[:len(hello)-3],
+			protocol: ProtocolTLS,
+			expected: result{protocol: ProtocolTLS},
+		},
+		"binary": {
+			payload:  []byte{0x00, 0xff, 0x13, 0x37},
+			protocol: ProtocolAuto,
+			expected: result{},
+		},
+		"empty": {
+			payload:  nil,
+			protocol: ProtocolAuto,
+			expected: result{},
+		},
+		"parser_disabled": {
+			payload:  []byte("GET / HTTP/1.1\r\n"),
+			protocol: ProtocolAuto,
+			enabled:  map[string]bool{ProtocolDNS: true},
+			expected: result{},
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			enabled := test.enabled
+			if enabled == nil {
+				enabled = allParsers
+			}
+			require.Equal(t, test.expected, parse(test.payload, test.protocol, enabled))
+		})
+	}
+}
+
+func TestReadDNSName(t *testing.T) {
+	t.Parallel()
+
+	// "www" followed by a pointer to "example.com" in the first question
+	msg := append(append([]byte{}, dnsQuery...), 3, 'w', 'w', 'w', 0xc0, 0x0c, 0x00, 0x01)
+	name, next, ok := readDNSName(msg, len(dnsQuery))
+	require.True(t, ok)
+	require.Equal(t, "www.example.com", name)
+	require.Equal(t, len(dnsQuery)+6, next)
+
+	// Pointer to itself
+	msg = append(append([]byte{}, dnsQuery[:12]...), 3, 'w', 'w', 'w', 0xc0, 0x0c)
+	_, _, ok = readDNSName(msg, 12)
+	require.False(t, ok)
+
+	// Forward pointer
+	msg = append(append([]byte{}, dnsQuery[:12]...), 0xc0, 0x10, 0x00, 0x00)
+	_, _, ok = readDNSName(msg, 12)
+	require.False(t, ok)
+}
+
+func TestParseEnabled(t *testing.T) {
+	t.Parallel()
+
+	enabled, err := parseEnabled("http, tls")
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{ProtocolHTTP: true, ProtocolTLS: true}, enabled)
+
+	enabled, err = parseEnabled("")
+	require.NoError(t, err)
+	require.Empty(t, enabled)
+
+	_, err = parseEnabled("http,ftp")
+	require.ErrorContains(t, err, "invalid L7 parser")
+}
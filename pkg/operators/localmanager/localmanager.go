@@ -28,6 +28,7 @@ import (
 
 	commonutils "github.com/inspektor-gadget/inspektor-gadget/cmd/common/utils"
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
 	containerutils "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils"
 	runtimeclient "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/runtime-client"
 	containerutilsTypes "github.com/inspektor-gadget/inspektor-gadget/pkg/container-utils/types"
@@ -486,12 +487,21 @@ func (l *LocalManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext
 	}
 
 	activate := false
+	// canFilter is set when the gadget filters events by container
+	canFilter := false
+
+	// Allow other operators to resolve container templates
+	if l.igManager != nil {
+		gadgetCtx.SetVar(containertemplate.LookupVar,
+			containertemplate.LookupFunc(l.igManager.ContainerCollection.LookupContainerByMntns))
+	}
 
 	// Check, whether the gadget requested a map from us
 	if t, ok := gadgetCtx.GetVar(gadgets.MntNsFilterMapName); ok {
 		if _, ok := t.(*ebpf.Map); ok {
 			gadgetCtx.Logger().Debugf("gadget requested map %s", gadgets.MntNsFilterMapName)
 			activate = true
+			canFilter = true
 		}
 	}
 
@@ -499,6 +509,7 @@ func (l *LocalManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext
 	if val, ok := gadgetCtx.GetVar("NeedContainerEvents"); ok {
 		if b, ok := val.(bool); ok && b {
 			activate = true
+			canFilter = true
 		}
 	}
 
@@ -511,6 +522,13 @@ func (l *LocalManager) InstantiateDataOperator(gadgetCtx operators.GadgetContext
 		activate = true
 	}
 
+	filterParams := map[string]string{
+		ContainerName: params.Get(ContainerName).AsString(),
+	}
+	if err := gadgets.CheckContainerFilter(canFilter, filterParams); err != nil {
+		return nil, err
+	}
+
 	if !activate {
 		return nil, nil
 	}
@@ -23,6 +23,7 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/metric"
@@ -31,7 +32,9 @@ import (
 
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	metadatav1 "github.com/inspektor-gadget/inspektor-gadget/pkg/metadata/v1"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/filter"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 )
 
@@ -43,16 +46,20 @@ const (
 	ParamOtelMetricsListenAddress = "otel-metrics-listen-address"
 	ParamOtelMetricsName          = "otel-metrics-name"
 
-	MetricTypeKey       = "key"
+	MetricTypeKey       = metadatav1.MetricTypeKey
 	MetricTypeCounter   = "counter"
 	MetricTypeGauge     = "gauge"
 	MetricTypeHistogram = "histogram"
 
 	AnnotationMetricsExport      = "metrics.export"
-	AnnotationMetricsType        = "metrics.type"
+	AnnotationMetricsType        = metadatav1.AnnotationMetricsType
 	AnnotationMetricsDescription = "metrics.description"
 	AnnotationMetricsUnit        = "metrics.unit"
 	AnnotationMetricsBoundaries  = "metrics.boundaries"
+
+	// MapMetricValueField is the field holding the value of map-derived
+	// metrics declared in the metadata whose map value is an integer
+	MapMetricValueField = metadatav1.MapMetricValueField
 )
 
 type otelMetricsOperator struct {
@@ -146,7 +153,7 @@ func (m *otelMetricsOperator) InstantiateDataOperator(gadgetCtx operators.Gadget
 
 	instance := &otelMetricsOperatorInstance{
 		op:           m,
-		collectors:   make(map[datasource.DataSource]*metricsCollector),
+		collectors:   make(map[datasource.DataSource][]*metricsCollector),
 		nameMappings: mappings,
 	}
 
@@ -163,7 +170,7 @@ func (m *otelMetricsOperator) Priority() int {
 
 type otelMetricsOperatorInstance struct {
 	op           *otelMetricsOperator
-	collectors   map[datasource.DataSource]*metricsCollector
+	collectors   map[datasource.DataSource][]*metricsCollector
 	nameMappings map[string]string
 }
 
@@ -175,6 +182,9 @@ type metricsCollector struct {
 	meter  metric.Meter
 	keys   []func(datasource.Data) attribute.KeyValue
 	values []func(context.Context, datasource.Data, attribute.Set)
+
+	// selectors are the rules data has to match to be collected
+	selectors []func(datasource.DataSource, datasource.Data) bool
 }
 
 func asInt64Func[T constraints.Integer](extract func(datasource.Data) (T, error)) func(datasource.Data) int64 {
@@ -227,8 +237,7 @@ func asFloat64(f datasource.FieldAccessor) func(datasource.Data) float64 {
 	}
 }
 
-func (mc *metricsCollector) addKeyFunc(f datasource.FieldAccessor) error {
-	name := f.Name()
+func (mc *metricsCollector) addKeyFunc(name string, f datasource.FieldAccessor) error {
 	switch f.Type() {
 	default:
 		return fmt.Errorf("unsupported field type for metrics collector: %s", f.Type())
@@ -412,6 +421,73 @@ func (mc *metricsCollector) addValHistFunc(f datasource.FieldAccessor) error {
 	}
 }
 
+// addMetadataValFunc adds the counter or gauge of a metric declared in the
+// metadata. Counters without a field count the data collected.
+func (mc *metricsCollector) addMetadataValFunc(name string, m metadatav1.Metric, f datasource.FieldAccessor) error {
+	var options []metric.InstrumentOption
+	if m.Help != "" {
+		options = append(options, metric.WithDescription(m.Help))
+	}
+
+	asIntFn := func(datasource.Data) int64 { return 1 }
+	if f != nil {
+		switch f.Type() {
+		default:
+			return fmt.Errorf("unsupported field type for metrics value %q: %s", f.Name(), f.Type())
+		case api.Kind_Uint8,
+			api.Kind_Uint16,
+			api.Kind_Uint32,
+			api.Kind_Uint64,
+			api.Kind_Int8,
+			api.Kind_Int16,
+			api.Kind_Int32,
+			api.Kind_Int64:
+			asIntFn = asInt64(f)
+		}
+	} else if m.Type != metadatav1.MetricTypeCounter {
+		return fmt.Errorf("field is required by %s %q", m.Type, name)
+	}
+
+	switch m.Type {
+	default:
+		return fmt.Errorf("unsupported metric type %q for %q", m.Type, name)
+	case metadatav1.MetricTypeCounter:
+		tOptions := make([]metric.Int64CounterOption, len(options))
+		for i, option := range options {
+			tOptions[i] = option
+		}
+		ctr, err := mc.meter.Int64Counter(name, tOptions...)
+		if err != nil {
+			return fmt.Errorf("adding metric %s for %q: %w", m.Type, name, err)
+		}
+		mc.values = append(mc.values, func(ctx context.Context, data datasource.Data, set attribute.Set) {
+			ctr.Add(ctx, asIntFn(data), metric.WithAttributeSet(set))
+		})
+	case metadatav1.MetricTypeGauge:
+		tOptions := make([]metric.Int64GaugeOption, len(options))
+		for i, option := range options {
+			tOptions[i] = option
+		}
+		ctr, err := mc.meter.Int64Gauge(name, tOptions...)
+		if err != nil {
+			return fmt.Errorf("adding metric %s for %q: %w", m.Type, name, err)
+		}
+		mc.values = append(mc.values, func(ctx context.Context, data datasource.Data, set attribute.Set) {
+			ctr.Record(ctx, asIntFn(data), metric.WithAttributeSet(set))
+		})
+	}
+	return nil
+}
+
+func (mc *metricsCollector) matches(ds datasource.DataSource, data datasource.Data) bool {
+	for _, selector := range mc.selectors {
+		if !selector(ds, data) {
+			return false
+		}
+	}
+	return true
+}
+
 func (mc *metricsCollector) Collect(ctx context.Context, data datasource.Data) {
 	kvs := make([]attribute.KeyValue, 0, len(mc.keys))
 	for _, kf := range mc.keys {
@@ -456,7 +532,7 @@ func (m *otelMetricsOperatorInstance) init(gadgetCtx operators.GadgetContext) er
 			default:
 				continue
 			case MetricTypeKey:
-				err := collector.addKeyFunc(f)
+				err := collector.addKeyFunc(f.Name(), f)
 				if err != nil {
 					return fmt.Errorf("adding key for %q: %w", fieldName, err)
 				}
@@ -478,19 +554,115 @@ func (m *otelMetricsOperatorInstance) init(gadgetCtx operators.GadgetContext) er
 		if !hasValueFields {
 			continue
 		}
-		m.collectors[ds] = collector
+		m.collectors[ds] = append(m.collectors[ds], collector)
+	}
+	return m.initMetadataMetrics(gadgetCtx)
+}
+
+// initMetadataMetrics adds the collectors of the metrics declared in the
+// metrics section of the metadata. Map-derived metrics are read from the data
+// source of the same name, which has the key of the map and its value.
+// Event-derived metrics are collected from the events of their data source.
+func (m *otelMetricsOperatorInstance) initMetadataMetrics(gadgetCtx operators.GadgetContext) error {
+	cfg, ok := gadgetCtx.GetVar("config")
+	if !ok {
+		return nil
+	}
+	v, ok := cfg.(*viper.Viper)
+	if !ok {
+		return nil
+	}
+	var metrics map[string]metadatav1.Metric
+	if err := v.UnmarshalKey("metrics", &metrics); err != nil {
+		return fmt.Errorf("reading metrics: %w", err)
+	}
+
+	dataSources := make(map[string]datasource.DataSource)
+	for _, ds := range gadgetCtx.GetDataSources() {
+		dataSources[ds.Name()] = ds
+	}
+
+	for metricName, metadataMetric := range metrics {
+		dsName := metadataMetric.DataSource
+		if dsName == "" {
+			dsName = metricName
+		}
+		ds, ok := dataSources[dsName]
+		if !ok {
+			gadgetCtx.Logger().Warnf("data source %q not found for metric %q, skipping export", dsName, metricName)
+			continue
+		}
+
+		mappedName, ok := m.nameMappings[dsName]
+		if !ok {
+			mappedName = m.nameMappings[""]
+		}
+		if mappedName == "" {
+			mappedName = dsName
+		}
+
+		collector := &metricsCollector{meter: m.op.meterProvider.Meter(mappedName)}
+
+		labels := metadataMetric.Labels
+		if len(labels) == 0 && metadataMetric.MapName != "" {
+			for _, f := range ds.Accessors(false) {
+				if f.Annotations()[AnnotationMetricsType] == MetricTypeKey {
+					labels = append(labels, f.FullName())
+				}
+			}
+		}
+		for _, label := range labels {
+			f := ds.GetField(label)
+			if f == nil {
+				return fmt.Errorf("metric %q: label field %q not found", metricName, label)
+			}
+			if err := collector.addKeyFunc(strings.ReplaceAll(label, ".", "_"), f); err != nil {
+				return fmt.Errorf("metric %q: adding label %q: %w", metricName, label, err)
+			}
+		}
+
+		fieldName := metadataMetric.Field
+		if fieldName == "" && metadataMetric.MapName != "" {
+			fieldName = MapMetricValueField
+		}
+		var valueField datasource.FieldAccessor
+		if fieldName != "" {
+			valueField = ds.GetField(fieldName)
+			if valueField == nil {
+				return fmt.Errorf("metric %q: field %q not found", metricName, fieldName)
+			}
+		}
+		if err := collector.addMetadataValFunc(metricName, metadataMetric, valueField); err != nil {
+			return fmt.Errorf("metric %q: %w", metricName, err)
+		}
+
+		for _, rule := range metadataMetric.Selector {
+			selector, err := filter.NewSelector(ds, rule)
+			if err != nil {
+				return fmt.Errorf("metric %q: %w", metricName, err)
+			}
+			collector.selectors = append(collector.selectors, selector)
+		}
+
+		gadgetCtx.Logger().Debugf("registered metric %q from data source %q", metricName, dsName)
+		m.collectors[ds] = append(m.collectors[ds], collector)
 	}
 	return nil
 }
 
 func (m *otelMetricsOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
-	for ds, collector := range m.collectors {
-		err := ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
-			collector.Collect(gadgetCtx.Context(), data)
-			return nil
-		}, Priority)
-		if err != nil {
-			return err
+	for ds, collectors := range m.collectors {
+		for _, collector := range collectors {
+			collector := collector
+			err := ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+				if collector.matches(ds, data) {
+					collector.Collect(gadgetCtx.Context(), data)
+				}
+				return nil
+			}, Priority)
+			if err != nil {
+				return err
+			}
 		}
 	}
 	return nil
@@ -505,3 +677,7 @@ func (m *otelMetricsOperatorInstance) Stop(gadgetCtx operators.GadgetContext) er
 }
 
 var Operator = &otelMetricsOperator{}
+
+func init() {
+	operators.RegisterDataOperator(Operator)
+}
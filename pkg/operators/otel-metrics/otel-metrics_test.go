@@ -16,9 +16,11 @@ package otelmetrics
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/sdk/metric/metricdata"
@@ -198,3 +200,128 @@ func TestMetricsHistogram(t *testing.T) {
 		assert.True(t, found)
 	}
 }
+
+func newMetadataConfig(t *testing.T, metadata string) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(metadata)))
+	return v
+}
+
+func TestMetricsFromMetadata(t *testing.T) {
+	o := &otelMetricsOperator{skipListen: true}
+	globalParams := apihelpers.ToParamDescs(o.GlobalParams()).ToParams()
+	globalParams.Set(ParamOtelMetricsEnabled, "true")
+	err := o.Init(globalParams)
+	require.NoError(t, err)
+
+	var events, io datasource.DataSource
+	var pid, bytes, dev, op, value datasource.FieldAccessor
+
+	ctx, cancel := context.WithTimeout(context.TODO(), time.Second)
+	defer cancel()
+
+	config := newMetadataConfig(t, `
+metrics:
+  exec_total:
+    help: Number of processes executed
+    type: counter
+    dataSource: exec
+    selector:
+    - pid>=100
+  exec_bytes_total:
+    type: counter
+    dataSource: exec
+    field: bytes
+  io_ops_total:
+    help: I/O operations by device
+    type: counter
+    mapName: io
+`)
+
+	prepare := func(gadgetCtx operators.GadgetContext) error {
+		gadgetCtx.SetVar("config", config)
+
+		var err error
+		events, err = gadgetCtx.RegisterDataSource(datasource.TypeSingle, "exec")
+		require.NoError(t, err)
+		pid, err = events.AddField("pid", api.Kind_Uint32)
+		require.NoError(t, err)
+		bytes, err = events.AddField("bytes", api.Kind_Uint64)
+		require.NoError(t, err)
+
+		// Map-derived metrics are emitted by the ebpf operator with the
+		// fields of the key annotated as keys
+		io, err = gadgetCtx.RegisterDataSource(datasource.TypeArray, "io_ops_total")
+		require.NoError(t, err)
+		keyAnnotations := datasource.WithAnnotations(map[string]string{AnnotationMetricsType: MetricTypeKey})
+		dev, err = io.AddField("dev", api.Kind_Uint32, keyAnnotations)
+		require.NoError(t, err)
+		op, err = io.AddField("op", api.Kind_Uint32, keyAnnotations)
+		require.NoError(t, err)
+		value, err = io.AddField(MapMetricValueField, api.Kind_Uint64)
+		require.NoError(t, err)
+		return nil
+	}
+	produce := func(operators.GadgetContext) error {
+		for i := range 4 {
+			data, err := events.NewPacketSingle()
+			require.NoError(t, err)
+			assert.NoError(t, pid.PutUint32(data, uint32(99+i)))
+			assert.NoError(t, bytes.PutUint64(data, 10))
+			assert.NoError(t, events.EmitAndRelease(data))
+		}
+
+		arr, err := io.NewPacketArray()
+		require.NoError(t, err)
+		for _, key := range [][2]uint32{{259, 0}, {259, 1}} {
+			data := arr.New()
+			assert.NoError(t, dev.PutUint32(data, key[0]))
+			assert.NoError(t, op.PutUint32(data, key[1]))
+			assert.NoError(t, value.PutUint64(data, uint64(5+key[1])))
+			arr.Append(data)
+		}
+		assert.NoError(t, io.EmitAndRelease(arr))
+		cancel()
+		return nil
+	}
+
+	producer := simple.New("producer",
+		simple.WithPriority(Priority-1),
+		simple.OnInit(prepare),
+		simple.OnStart(produce),
+	)
+
+	gadgetCtx := gadgetcontext.New(ctx, "", gadgetcontext.WithDataOperators(o, producer))
+	require.NoError(t, gadgetCtx.Run(api.ParamValues{}))
+
+	md := &metricdata.ResourceMetrics{}
+	require.NoError(t, o.exporter.Collect(context.Background(), md))
+
+	sums := make(map[string]metricdata.Sum[int64])
+	for _, sm := range md.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			data, ok := (m.Data).(metricdata.Sum[int64])
+			require.True(t, ok, "metric %q", m.Name)
+			sums[m.Name] = data
+		}
+	}
+	require.Len(t, sums, 3)
+
+	// Only events matching the selector are counted
+	require.Len(t, sums["exec_total"].DataPoints, 1)
+	require.Equal(t, int64(3), sums["exec_total"].DataPoints[0].Value)
+	require.Equal(t, int64(40), sums["exec_bytes_total"].DataPoints[0].Value)
+
+	// The fields of the key are the labels
+	ops := make(map[int64]int64)
+	for _, dp := range sums["io_ops_total"].DataPoints {
+		v, ok := dp.Attributes.Value("dev")
+		require.True(t, ok)
+		require.Equal(t, int64(259), v.AsInt64())
+		v, ok = dp.Attributes.Value("op")
+		require.True(t, ok)
+		ops[v.AsInt64()] = dp.Value
+	}
+	require.Equal(t, map[int64]int64{0: 5, 1: 6}, ops)
+}
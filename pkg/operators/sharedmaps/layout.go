@@ -0,0 +1,115 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharedmaps
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+)
+
+// CheckLayout returns an error if the spec of a map defined by a gadget
+// doesn't match the one of the provider of the shared map.
+func CheckLayout(gadget, provider *ebpf.MapSpec) error {
+	if gadget.Type != provider.Type {
+		return fmt.Errorf("map type is %s, expected %s", gadget.Type, provider.Type)
+	}
+	if gadget.KeySize != provider.KeySize {
+		return fmt.Errorf("key size is %d, expected %d", gadget.KeySize, provider.KeySize)
+	}
+	if gadget.ValueSize != provider.ValueSize {
+		return fmt.Errorf("value size is %d, expected %d", gadget.ValueSize, provider.ValueSize)
+	}
+
+	// Without BTF only the sizes can be checked
+	if gadget.Key != nil && provider.Key != nil {
+		if err := compareTypes("key", gadget.Key, provider.Key); err != nil {
+			return err
+		}
+	}
+	if gadget.Value != nil && provider.Value != nil {
+		if err := compareTypes("value", gadget.Value, provider.Value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compareTypes(path string, a, b btf.Type) error {
+	a = btf.UnderlyingType(a)
+	b = btf.UnderlyingType(b)
+
+	sizeA, errA := btf.Sizeof(a)
+	sizeB, errB := btf.Sizeof(b)
+	if errA == nil && errB == nil && sizeA != sizeB {
+		return fmt.Errorf("%s: size is %d, expected %d", path, sizeA, sizeB)
+	}
+
+	switch tb := b.(type) {
+	case *btf.Struct:
+		ta, ok := a.(*btf.Struct)
+		if !ok {
+			return fmt.Errorf("%s: type is %s, expected a struct", path, a)
+		}
+		return compareMembers(path, ta.Members, tb.Members)
+	case *btf.Union:
+		ta, ok := a.(*btf.Union)
+		if !ok {
+			return fmt.Errorf("%s: type is %s, expected a union", path, a)
+		}
+		return compareMembers(path, ta.Members, tb.Members)
+	case *btf.Array:
+		ta, ok := a.(*btf.Array)
+		if !ok {
+			return fmt.Errorf("%s: type is %s, expected an array", path, a)
+		}
+		if ta.Nelems != tb.Nelems {
+			return fmt.Errorf("%s: array has %d elements, expected %d", path, ta.Nelems, tb.Nelems)
+		}
+		return compareTypes(path+"[]", ta.Type, tb.Type)
+	case *btf.Int:
+		ta, ok := a.(*btf.Int)
+		if !ok {
+			return fmt.Errorf("%s: type is %s, expected an integer", path, a)
+		}
+		if (ta.Encoding == btf.Signed) != (tb.Encoding == btf.Signed) {
+			return fmt.Errorf("%s: signedness differs", path)
+		}
+	}
+
+	return nil
+}
+
+func compareMembers(path string, a, b []btf.Member) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("%s: has %d members, expected %d", path, len(a), len(b))
+	}
+	for i := range b {
+		ma, mb := a[i], b[i]
+		if ma.Name != mb.Name {
+			return fmt.Errorf("%s: member %d is %q, expected %q", path, i, ma.Name, mb.Name)
+		}
+		memberPath := path + "." + mb.Name
+		if ma.Offset != mb.Offset || ma.BitfieldSize != mb.BitfieldSize {
+			return fmt.Errorf("%s: offset or bitfield size differs", memberPath)
+		}
+		if err := compareTypes(memberPath, ma.Type, mb.Type); err != nil {
+			return err
+		}
+	}
+	return nil
+}
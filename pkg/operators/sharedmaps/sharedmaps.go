@@ -0,0 +1,249 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sharedmaps handles eBPF maps holding auxiliary kernel state (like the
+// process owning each socket) that is shared by all the gadgets running on a
+// node instead of each gadget keeping its own copy.
+//
+// A provider registers the map it exposes with Register. Gadgets use the map by
+// defining a map with the same name and declaring it in the "externalMaps"
+// section of their metadata, along with the layout version they were built
+// against and the provider they depend on. The provider is started by the
+// first gadget using its map and stopped after the last one is done.
+package sharedmaps
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cilium/ebpf"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	name     = "sharedmaps"
+	Priority = 10
+)
+
+// Provider provisions a map shared by all the gadgets of a node
+type Provider interface {
+	// ProviderName identifies the provider in the metadata of the gadgets,
+	// like "socket-enricher"
+	ProviderName() string
+
+	// MapName is the name gadgets use for the map
+	MapName() string
+
+	// LayoutVersion must be increased on every change of the layout of the
+	// map, so gadgets built against an older layout are rejected
+	LayoutVersion() int
+
+	// MapSpec describes the layout of the map
+	MapSpec() (*ebpf.MapSpec, error)
+
+	// Acquire starts the provider if it isn't running yet and returns the
+	// map. Each call must be paired with a call to Release.
+	Acquire() (*ebpf.Map, error)
+
+	// Release stops the provider after its last user is done
+	Release()
+}
+
+var (
+	providersMu sync.Mutex
+	providers   = map[string]Provider{}
+)
+
+// Register makes a provider available to gadgets
+func Register(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if _, ok := providers[p.MapName()]; ok {
+		panic(fmt.Errorf("shared map %q already registered", p.MapName()))
+	}
+	providers[p.MapName()] = p
+}
+
+// Get returns the provider of the map with the given name or nil
+func Get(mapName string) Provider {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	return providers[mapName]
+}
+
+// RefCounted implements Acquire and Release for providers: start is called
+// by the first user and stop after the last one is done.
+type RefCounted struct {
+	start func() (*ebpf.Map, error)
+	stop  func()
+
+	mu       sync.Mutex
+	refCount int
+	m        *ebpf.Map
+}
+
+// NewRefCounted returns a RefCounted calling start to create the map when it
+// gets its first user and stop to tear it down after the last one leaves.
+func NewRefCounted(start func() (*ebpf.Map, error), stop func()) *RefCounted {
+	return &RefCounted{
+		start: start,
+		stop:  stop,
+	}
+}
+
+// Acquire returns the map, calling start if there are no other users. It's
+// safe to call concurrently, and each successful call must be paired with a
+// call to Release.
+func (r *RefCounted) Acquire() (*ebpf.Map, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refCount == 0 {
+		m, err := r.start()
+		if err != nil {
+			return nil, err
+		}
+		r.m = m
+	}
+
+	r.refCount++
+	return r.m, nil
+}
+
+// Release drops a reference taken by Acquire and calls stop when it was the
+// last one. Extra calls are ignored.
+func (r *RefCounted) Release() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refCount == 0 {
+		return
+	}
+
+	r.refCount--
+	if r.refCount == 0 {
+		r.stop()
+		r.m = nil
+	}
+}
+
+// Close stops the provider regardless of its users
+func (r *RefCounted) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.refCount > 0 {
+		r.stop()
+		r.m = nil
+		r.refCount = 0
+	}
+}
+
+type sharedMapsOperator struct{}
+
+func (o *sharedMapsOperator) Name() string {
+	return name
+}
+
+func (o *sharedMapsOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *sharedMapsOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *sharedMapsOperator) InstanceParams() api.Params {
+	return nil
+}
+
+func (o *sharedMapsOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	providersMu.Lock()
+	names := make([]string, 0, len(providers))
+	for mapName := range providers {
+		names = append(names, mapName)
+	}
+	providersMu.Unlock()
+	sort.Strings(names)
+
+	inst := &sharedMapsOperatorInstance{}
+
+	// The ebpf operator sets a variable for each map it expects from others
+	for _, mapName := range names {
+		if _, ok := gadgetCtx.GetVar(mapName); !ok {
+			continue
+		}
+		inst.providers = append(inst.providers, Get(mapName))
+	}
+
+	if len(inst.providers) == 0 {
+		return nil, nil
+	}
+
+	return inst, nil
+}
+
+func (o *sharedMapsOperator) Priority() int {
+	return Priority
+}
+
+type sharedMapsOperatorInstance struct {
+	providers []Provider
+	acquired  []Provider
+}
+
+func (i *sharedMapsOperatorInstance) Name() string {
+	return name
+}
+
+func (i *sharedMapsOperatorInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	for _, p := range i.providers {
+		m, err := p.Acquire()
+		if err != nil {
+			i.release()
+			return fmt.Errorf("starting provider of shared map %q: %w", p.MapName(), err)
+		}
+		i.acquired = append(i.acquired, p)
+
+		gadgetCtx.Logger().Debugf("setting shared map %q", p.MapName())
+		gadgetCtx.SetVar(p.MapName(), m)
+	}
+	return nil
+}
+
+func (i *sharedMapsOperatorInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (i *sharedMapsOperatorInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	i.release()
+	return nil
+}
+
+func (i *sharedMapsOperatorInstance) release() {
+	for _, p := range i.acquired {
+		p.Release()
+	}
+	i.acquired = nil
+}
+
+func init() {
+	operators.RegisterDataOperator(&sharedMapsOperator{})
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sharedmaps
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/btf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefCounted(t *testing.T) {
+	t.Parallel()
+
+	starts, stops := 0, 0
+	m := &ebpf.Map{}
+	r := NewRefCounted(func() (*ebpf.Map, error) {
+		starts++
+		return m, nil
+	}, func() {
+		stops++
+	})
+
+	got, err := r.Acquire()
+	require.NoError(t, err)
+	require.Same(t, m, got)
+
+	got, err = r.Acquire()
+	require.NoError(t, err)
+	require.Same(t, m, got)
+	require.Equal(t, 1, starts)
+
+	r.Release()
+	require.Equal(t, 0, stops)
+	r.Release()
+	require.Equal(t, 1, stops)
+
+	// Extra releases are ignored
+	r.Release()
+	require.Equal(t, 1, stops)
+
+	// Started again by the next user
+	_, err = r.Acquire()
+	require.NoError(t, err)
+	require.Equal(t, 2, starts)
+
+	r.Close()
+	require.Equal(t, 2, stops)
+	r.Close()
+	require.Equal(t, 2, stops)
+}
+
+func TestRefCountedStartError(t *testing.T) {
+	t.Parallel()
+
+	stops := 0
+	r := NewRefCounted(func() (*ebpf.Map, error) {
+		return nil, errors.New("failed")
+	}, func() {
+		stops++
+	})
+
+	_, err := r.Acquire()
+	require.Error(t, err)
+
+	r.Release()
+	require.Equal(t, 0, stops)
+}
+
+func TestCheckLayout(t *testing.T) {
+	t.Parallel()
+
+	u32 := &btf.Int{Name: "u32", Size: 4}
+	s32 := &btf.Int{Name: "s32", Size: 4, Encoding: btf.Signed}
+	u64 := &btf.Int{Name: "u64", Size: 8}
+
+	key := func(second btf.Type, name string) *btf.Struct {
+		return &btf.Struct{
+			Name: "key",
+			Size: 8,
+			Members: []btf.Member{
+				{Name: "a", Type: u32},
+				{Name: name, Type: second, Offset: 32},
+			},
+		}
+	}
+	value := func(nelems uint32) *btf.Array {
+		return &btf.Array{Type: u32, Nelems: nelems}
+	}
+
+	provider := &ebpf.MapSpec{
+		Type:      ebpf.Hash,
+		KeySize:   8,
+		ValueSize: 16,
+		Key:       key(u32, "b"),
+		Value:     value(4),
+	}
+
+	type testCase struct {
+		spec              *ebpf.MapSpec
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"same": {
+			spec: &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16, Key: key(u32, "b"), Value: value(4)},
+		},
+		"typedef": {
+			spec: &ebpf.MapSpec{
+				Type:      ebpf.Hash,
+				KeySize:   8,
+				ValueSize: 16,
+				Key:       &btf.Typedef{Name: "key_t", Type: key(u32, "b")},
+				Value:     value(4),
+			},
+		},
+		"no_btf": {
+			spec: &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16},
+		},
+		"wrong_type": {
+			spec:              &ebpf.MapSpec{Type: ebpf.LRUHash, KeySize: 8, ValueSize: 16},
+			expectedErrString: "map type is LRUHash, expected Hash",
+		},
+		"wrong_key_size": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 4, ValueSize: 16},
+			expectedErrString: "key size is 4, expected 8",
+		},
+		"wrong_value_size": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 8},
+			expectedErrString: "value size is 8, expected 16",
+		},
+		"renamed_member": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16, Key: key(u32, "c"), Value: value(4)},
+			expectedErrString: "key: member 1 is \"c\", expected \"b\"",
+		},
+		"signedness": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16, Key: key(s32, "b"), Value: value(4)},
+			expectedErrString: "key.b: signedness differs",
+		},
+		"member_size": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16, Key: key(u64, "b"), Value: value(4)},
+			expectedErrString: "key.b: size is 8, expected 4",
+		},
+		"array_elements": {
+			spec:              &ebpf.MapSpec{Type: ebpf.Hash, KeySize: 8, ValueSize: 16, Key: key(u32, "b"), Value: value(2)},
+			expectedErrString: "value: size is 8, expected 16",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := CheckLayout(test.spec, provider)
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
@@ -20,30 +20,71 @@ package socketenricher
 
 import (
 	"fmt"
-	"sync"
 
 	"github.com/cilium/ebpf"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadgets"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators/sharedmaps"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
 	tracer "github.com/inspektor-gadget/inspektor-gadget/pkg/socketenricher"
 )
 
 const (
 	OperatorName = "SocketEnricher"
+
+	// ProviderName identifies the socket enricher in the externalMaps section
+	// of the metadata of the gadgets using its map
+	ProviderName = "socket-enricher"
 )
 
 type SocketEnricherInterface interface {
 	SetSocketEnricherMap(*ebpf.Map)
 }
 
+// SocketEnricher provides the sockets map to the legacy gadgets and, through
+// the sharedmaps operator, to the image-based ones
 type SocketEnricher struct {
-	mu             sync.Mutex
+	*sharedmaps.RefCounted
+
 	socketEnricher *tracer.SocketEnricher
-	refCount       int
+}
+
+func newSocketEnricher() *SocketEnricher {
+	s := &SocketEnricher{}
+	s.RefCounted = sharedmaps.NewRefCounted(s.start, s.stop)
+	return s
+}
+
+func (s *SocketEnricher) start() (*ebpf.Map, error) {
+	t, err := tracer.NewSocketEnricher()
+	if err != nil {
+		return nil, err
+	}
+	s.socketEnricher = t
+	return t.SocketsMap(), nil
+}
+
+func (s *SocketEnricher) stop() {
+	s.socketEnricher.Close()
+	s.socketEnricher = nil
+}
+
+func (s *SocketEnricher) ProviderName() string {
+	return ProviderName
+}
+
+func (s *SocketEnricher) MapName() string {
+	return tracer.SocketsMapName
+}
+
+func (s *SocketEnricher) LayoutVersion() int {
+	return tracer.SocketsMapLayoutVersion
+}
+
+func (s *SocketEnricher) MapSpec() (*ebpf.MapSpec, error) {
+	return tracer.SocketsMapSpec()
 }
 
 func (s *SocketEnricher) Name() string {
@@ -87,13 +128,7 @@ func (s *SocketEnricher) Init(params *params.Params) error {
 }
 
 func (s *SocketEnricher) Close() error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	if s.socketEnricher != nil {
-		s.socketEnricher.Close()
-		s.socketEnricher = nil
-	}
+	s.RefCounted.Close()
 	return nil
 }
 
@@ -121,34 +156,18 @@ func (i *SocketEnricherInstance) PreGadgetRun() error {
 		return fmt.Errorf("gadget doesn't implement socket enricher interface")
 	}
 
-	i.manager.mu.Lock()
-	defer i.manager.mu.Unlock()
-
-	if i.manager.refCount == 0 {
-		t, err := tracer.NewSocketEnricher()
-		if err != nil {
-			return err
-		}
-		i.manager.socketEnricher = t
+	m, err := i.manager.Acquire()
+	if err != nil {
+		return err
 	}
 
-	i.manager.refCount++
-
-	setter.SetSocketEnricherMap(i.manager.socketEnricher.SocketsMap())
+	setter.SetSocketEnricherMap(m)
 
 	return nil
 }
 
 func (i *SocketEnricherInstance) PostGadgetRun() error {
-	i.manager.mu.Lock()
-	defer i.manager.mu.Unlock()
-
-	i.manager.refCount--
-	if i.manager.refCount == 0 {
-		i.manager.socketEnricher.Close()
-		i.manager.socketEnricher = nil
-	}
-
+	i.manager.Release()
 	return nil
 }
 
@@ -156,50 +175,8 @@ func (i *SocketEnricherInstance) EnrichEvent(ev any) error {
 	return nil
 }
 
-func (s *SocketEnricher) GlobalParams() api.Params {
-	return nil
-}
-
-func (s *SocketEnricher) InstanceParams() api.Params {
-	return nil
-}
-
-func (s *SocketEnricher) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
-	if _, ok := gadgetCtx.GetVar(tracer.SocketsMapName); !ok {
-		return nil, nil
-	}
-
-	instance := &SocketEnricherInstance{
-		gadgetCtx: gadgetCtx,
-		manager:   s,
-	}
-	instance.gadgetInstance = instance
-	return instance, nil
-}
-
-func (s *SocketEnricher) Priority() int {
-	return 10
-}
-
-func (i *SocketEnricherInstance) PreStart(gadgetCtx operators.GadgetContext) error {
-	return i.PreGadgetRun()
-}
-
-func (i *SocketEnricherInstance) Start(gadgetCtx operators.GadgetContext) error {
-	return nil
-}
-
-func (i *SocketEnricherInstance) Stop(gadgetCtx operators.GadgetContext) error {
-	return i.PostGadgetRun()
-}
-
-func (i *SocketEnricherInstance) SetSocketEnricherMap(m *ebpf.Map) {
-	i.gadgetCtx.Logger().Debugf("setting sockets map")
-	i.gadgetCtx.SetVar(tracer.SocketsMapName, m)
-}
-
 func init() {
-	op := &SocketEnricher{}
+	op := newSocketEnricher()
 	operators.Register(op)
-	operators.RegisterDataOperator(op)
+	sharedmaps.Register(op)
 }
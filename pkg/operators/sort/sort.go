@@ -223,6 +223,12 @@ func (s *sortOperatorInstance) init(gadgetCtx operators.GadgetContext) error {
 			if field == nil {
 				return fmt.Errorf("field %s not found", fieldName)
 			}
+			if !datasource.IsSortable(field) {
+				return fmt.Errorf("field %s is not sortable", fieldName)
+			}
+			if datasource.IsDeprecated(field) {
+				gadgetCtx.Logger().Warnf("%s: field %q is deprecated and will be removed", ds.Name(), fieldName)
+			}
 
 			cmp := getCompareFunc(field, negate)
 			if cmp == nil {
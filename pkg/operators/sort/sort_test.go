@@ -227,3 +227,54 @@ func TestMultiple(t *testing.T) {
 		"string,number",
 	)
 }
+
+func TestSortableFields(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		sortBy            string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"u64": {
+			sortBy: "bytes",
+		},
+		"endpoint": {
+			sortBy:            "src",
+			expectedErrString: "field src is not sortable",
+		},
+		"endpoint_descending": {
+			sortBy:            "-src",
+			expectedErrString: "field src is not sortable",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			gadgetCtx := gadgetcontext.New(context.Background(), "")
+			ds, err := gadgetCtx.RegisterDataSource(datasource.TypeArray, "foo")
+			require.NoError(t, err)
+
+			_, err = ds.AddField("bytes", api.Kind_Uint64, datasource.WithAnnotations(map[string]string{
+				datasource.SortableAnnotation: "true",
+			}))
+			require.NoError(t, err)
+			_, err = ds.AddField("src", api.Kind_String, datasource.WithAnnotations(map[string]string{
+				datasource.SortableAnnotation: "false",
+			}))
+			require.NoError(t, err)
+
+			s := &sortOperatorInstance{sortBy: test.sortBy}
+			err = s.init(gadgetCtx)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
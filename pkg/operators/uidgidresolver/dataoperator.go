@@ -0,0 +1,221 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uidgidresolver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/operators"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/params"
+)
+
+const (
+	// ParamResolveUidGid enables the resolution of the fields using the
+	// "uid" and "gid" templates. It can be disabled for performance.
+	ParamResolveUidGid = "resolve-uid-gid"
+
+	uidTemplate = "uid"
+	gidTemplate = "gid"
+
+	templateAnnotation = "columns.template"
+)
+
+// uidGidDataOperator adds a companion field with the user or group name to
+// the fields of image-based gadgets using the "uid" and "gid" templates. The
+// names are read from /etc/passwd and /etc/group of the host, not of the
+// container that generated the event.
+type uidGidDataOperator struct{}
+
+func (o *uidGidDataOperator) Name() string {
+	return "uidgidresolver"
+}
+
+func (o *uidGidDataOperator) Init(params *params.Params) error {
+	return nil
+}
+
+func (o *uidGidDataOperator) GlobalParams() api.Params {
+	return nil
+}
+
+func (o *uidGidDataOperator) InstanceParams() api.Params {
+	return api.Params{
+		{
+			Key:          ParamResolveUidGid,
+			Title:        "Resolve UID and GID",
+			DefaultValue: "true",
+			TypeHint:     api.TypeBool,
+			Description:  "Add the user and group names of the uid and gid fields, read from the host",
+		},
+	}
+}
+
+func (o *uidGidDataOperator) InstantiateDataOperator(gadgetCtx operators.GadgetContext, instanceParamValues api.ParamValues) (operators.DataOperatorInstance, error) {
+	// Keep the hot path untouched if disabled. It's enabled if unset.
+	if value := instanceParamValues[ParamResolveUidGid]; value != "" {
+		if enabled, _ := strconv.ParseBool(value); !enabled {
+			return nil, nil
+		}
+	}
+
+	inst, err := newUidGidInstance(gadgetCtx.GetDataSources(), GetUserGroupCache())
+	if err != nil {
+		return nil, err
+	}
+
+	// Don't run, if we don't have anything to do
+	if len(inst.resolvers) == 0 {
+		return nil, nil
+	}
+
+	return inst, nil
+}
+
+func (o *uidGidDataOperator) Priority() int {
+	return 0
+}
+
+// resolver fills the companion field of an id field
+type resolver struct {
+	in     datasource.FieldAccessor
+	out    datasource.FieldAccessor
+	lookup func(uint32) string
+}
+
+type uidGidInstance struct {
+	cache     UserGroupCache
+	resolvers map[datasource.DataSource][]resolver
+}
+
+func newUidGidInstance(dataSources map[string]datasource.DataSource, cache UserGroupCache) (*uidGidInstance, error) {
+	inst := &uidGidInstance{
+		cache:     cache,
+		resolvers: make(map[datasource.DataSource][]resolver),
+	}
+
+	for _, ds := range dataSources {
+		for _, in := range ds.Accessors(false) {
+			var lookup func(uint32) string
+			switch in.Annotations()[templateAnnotation] {
+			case uidTemplate:
+				lookup = cache.GetUsername
+			case gidTemplate:
+				lookup = cache.GetGroupname
+			default:
+				continue
+			}
+			if in.Type() != api.Kind_Uint32 {
+				continue
+			}
+
+			out, err := addCompanionField(ds, in)
+			if err != nil {
+				return nil, fmt.Errorf("data source %q: %w", ds.Name(), err)
+			}
+			inst.resolvers[ds] = append(inst.resolvers[ds], resolver{in: in, out: out, lookup: lookup})
+		}
+	}
+
+	return inst, nil
+}
+
+// companionName returns the name of the field with the name of the user or
+// group of an id field: uid becomes user and gid group, keeping any prefix
+// like in owner_uid
+func companionName(name, template string) string {
+	switch template {
+	case uidTemplate:
+		return strings.TrimSuffix(name, "uid") + "user"
+	default:
+		return strings.TrimSuffix(name, "gid") + "group"
+	}
+}
+
+func addCompanionField(ds datasource.DataSource, in datasource.FieldAccessor) (datasource.FieldAccessor, error) {
+	name := companionName(in.Name(), in.Annotations()[templateAnnotation])
+	opts := []datasource.FieldOption{
+		datasource.WithAnnotations(map[string]string{
+			"description":   fmt.Sprintf("Name resolved from %s on the host", in.Name()),
+			"columns.width": "16",
+		}),
+	}
+	if datasource.FieldFlagHidden.In(in.Flags()) {
+		opts = append(opts, datasource.WithFlags(datasource.FieldFlagHidden))
+	}
+
+	var out datasource.FieldAccessor
+	var err error
+	if parent := in.Parent(); parent != nil {
+		out, err = parent.AddSubField(name, api.Kind_String, opts...)
+	} else {
+		out, err = ds.AddField(name, api.Kind_String, opts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("adding field %q: %w", name, err)
+	}
+	return out, nil
+}
+
+// resolve returns the name of id or, if it's unknown, the id itself
+func (r *resolver) resolve(id uint32) string {
+	if name := r.lookup(id); name != "" {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (o *uidGidInstance) Name() string {
+	return "uidgidresolver"
+}
+
+func (o *uidGidInstance) PreStart(gadgetCtx operators.GadgetContext) error {
+	if err := o.cache.Start(); err != nil {
+		return err
+	}
+
+	for ds, resolvers := range o.resolvers {
+		resolvers := resolvers
+		ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+			for _, r := range resolvers {
+				id, err := r.in.Uint32(data)
+				if err != nil {
+					return err
+				}
+				if err := r.out.PutString(data, r.resolve(id)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}, 0)
+	}
+	return nil
+}
+
+func (o *uidGidInstance) Start(gadgetCtx operators.GadgetContext) error {
+	return nil
+}
+
+func (o *uidGidInstance) Stop(gadgetCtx operators.GadgetContext) error {
+	o.cache.Stop()
+	return nil
+}
+
+func init() {
+	operators.RegisterDataOperator(&uidGidDataOperator{})
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uidgidresolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/cachedmap"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/datasource"
+	"github.com/inspektor-gadget/inspektor-gadget/pkg/gadget-service/api"
+)
+
+// fakeCache is a UserGroupCache with fixed entries that counts lookups
+type fakeCache struct {
+	users   map[uint32]string
+	groups  map[uint32]string
+	lookups int
+}
+
+func (c *fakeCache) Start() error { return nil }
+func (c *fakeCache) Stop()        {}
+
+func (c *fakeCache) GetUsername(uid uint32) string {
+	c.lookups++
+	return c.users[uid]
+}
+
+func (c *fakeCache) GetGroupname(gid uint32) string {
+	c.lookups++
+	return c.groups[gid]
+}
+
+func TestUserGroupCacheEntries(t *testing.T) {
+	t.Parallel()
+
+	passwd := filepath.Join(t.TempDir(), "passwd")
+	require.NoError(t, os.WriteFile(passwd, []byte(
+		"# comment\nroot:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/sh\n"), 0o644))
+
+	f, err := os.Open(passwd)
+	require.NoError(t, err)
+	defer f.Close()
+
+	users := cachedmap.NewCachedMap[uint32, string](time.Minute)
+	defer users.Close()
+	updateEntries(f, users)
+
+	cache := &userGroupCache{userCache: users}
+	r := resolver{lookup: cache.GetUsername}
+
+	// Every lookup is served from the cache, the file is only read once
+	require.Equal(t, "root", r.resolve(0))
+	require.Equal(t, "alice", r.resolve(1000))
+	require.Equal(t, "alice", r.resolve(1000))
+
+	// Unknown ids fall back to the numeric value
+	require.Equal(t, "4242", r.resolve(4242))
+}
+
+func TestUidGidCompanionFields(t *testing.T) {
+	t.Parallel()
+
+	ds, err := datasource.New(datasource.TypeSingle, "event")
+	require.NoError(t, err)
+	uid, err := ds.AddField("uid", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+		"columns.template": "uid",
+	}))
+	require.NoError(t, err)
+	gid, err := ds.AddField("owner_gid", api.Kind_Uint32, datasource.WithAnnotations(map[string]string{
+		"columns.template": "gid",
+	}))
+	require.NoError(t, err)
+	// Not a uid: no companion field
+	_, err = ds.AddField("pid", api.Kind_Uint32)
+	require.NoError(t, err)
+
+	cache := &fakeCache{
+		users:  map[uint32]string{0: "root"},
+		groups: map[uint32]string{},
+	}
+	inst, err := newUidGidInstance(map[string]datasource.DataSource{"event": ds}, cache)
+	require.NoError(t, err)
+	require.Len(t, inst.resolvers[ds], 2)
+	require.NoError(t, inst.PreStart(nil))
+
+	user := ds.GetField("user")
+	require.NotNil(t, user)
+	group := ds.GetField("owner_group")
+	require.NotNil(t, group)
+
+	// The companion fields are shown next to the ids, which are kept
+	require.False(t, datasource.FieldFlagHidden.In(user.Flags()))
+	require.False(t, datasource.FieldFlagHidden.In(uid.Flags()))
+
+	var userName, groupName string
+	require.NoError(t, ds.Subscribe(func(ds datasource.DataSource, data datasource.Data) error {
+		userName, _ = user.String(data)
+		groupName, _ = group.String(data)
+		return nil
+	}, 1))
+
+	data, err := ds.NewPacketSingle()
+	require.NoError(t, err)
+	require.NoError(t, uid.PutUint32(data, 0))
+	require.NoError(t, gid.PutUint32(data, 1234))
+	require.NoError(t, ds.EmitAndRelease(data))
+
+	require.Equal(t, "root", userName)
+	// Unknown ids fall back to the numeric value
+	require.Equal(t, "1234", groupName)
+	require.Equal(t, 2, cache.lookups)
+}
+
+func TestUidGidDisabled(t *testing.T) {
+	t.Parallel()
+
+	o := &uidGidDataOperator{}
+	for _, value := range []string{"false", "False", "FALSE", "0"} {
+		inst, err := o.InstantiateDataOperator(nil, api.ParamValues{ParamResolveUidGid: value})
+		require.NoError(t, err, value)
+		require.Nil(t, inst, value)
+	}
+}
@@ -27,6 +27,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -91,6 +92,28 @@ type ParamDesc struct {
 	PossibleValues []string `json:"possibleValues" yaml:"possibleValues,omitempty"`
 }
 
+// Tags telling front-ends how to show a param
+const (
+	// TagAdvanced marks params front-ends can collapse, like tuning knobs
+	TagAdvanced = "advanced"
+	// TagHidden marks internal or debug params front-ends don't show
+	TagHidden = "hidden"
+	// TagSensitive marks params whose values are private, like usernames to match. Their values
+	// are replaced by MaskedValue in logs and errors, and front-ends should mask them when echoing.
+	TagSensitive = "sensitive"
+	// TagCategoryPrefix starts the tag giving the category of a param, like category:filtering
+	TagCategoryPrefix = "category:"
+	// TagRequiresPrefix starts the tags giving the keys of the params that must be set for the
+	// param to be set, like requires:measure-latency
+	TagRequiresPrefix = "requires:"
+	// TagConflictsWithPrefix starts the tags giving the keys of the params that can't be set
+	// together with the param, like conflictsWith:netns
+	TagConflictsWithPrefix = "conflictsWith:"
+	// TagEnumPrefix starts the tags giving the numbers of the possible values of a param backed
+	// by an enum, like enum:full=2. The param accepts them as well as the names.
+	TagEnumPrefix = "enum:"
+)
+
 // Param holds a ParamDesc but can additionally store a value
 type Param struct {
 	*ParamDesc
@@ -121,21 +144,25 @@ func (p *ParamDesc) Validate(value string) error {
 	}
 
 	if len(p.PossibleValues) > 0 {
+		if name, ok := p.enumName(value); ok {
+			value = name
+		}
 		for _, v := range p.PossibleValues {
 			if v == value {
 				return nil
 			}
 		}
-		return fmt.Errorf("invalid value %q as %q: valid values are: %s", value, p.Key, strings.Join(p.PossibleValues, ", "))
+		return MaskError(fmt.Errorf("invalid value %q as %q: valid values are: %s", value, p.Key, strings.Join(p.PossibleValues, ", ")),
+			value, p.IsSensitive())
 	}
 	if typeValidator, ok := typeHintValidators[p.TypeHint]; ok {
 		if err := typeValidator(value); err != nil {
-			return fmt.Errorf("invalid value %q as %q: %w", value, p.Key, err)
+			return MaskError(fmt.Errorf("invalid value %q as %q: %w", value, p.Key, err), value, p.IsSensitive())
 		}
 	}
 	if p.Validator != nil {
 		if err := p.Validator(value); err != nil {
-			return fmt.Errorf("invalid value %q as %q: %w", value, p.Key, err)
+			return MaskError(fmt.Errorf("invalid value %q as %q: %w", value, p.Key, err), value, p.IsSensitive())
 		}
 	}
 
@@ -155,6 +182,113 @@ func (p *ParamDesc) IsBoolFlag() bool {
 	return p.TypeHint == TypeBool
 }
 
+// Category returns the category of the param, set with a TagCategoryPrefix tag, or an empty string
+func (p *ParamDesc) Category() string {
+	if categories := p.tagValues(TagCategoryPrefix); len(categories) > 0 {
+		return categories[0]
+	}
+	return ""
+}
+
+// Requires returns the keys of the params that must be set for this one to be set, given by
+// TagRequiresPrefix tags
+func (p *ParamDesc) Requires() []string {
+	return p.tagValues(TagRequiresPrefix)
+}
+
+// ConflictsWith returns the keys of the params that can't be set together with this one, given
+// by TagConflictsWithPrefix tags
+func (p *ParamDesc) ConflictsWith() []string {
+	return p.tagValues(TagConflictsWithPrefix)
+}
+
+// enumName returns the name of the possible value whose number, given by a TagEnumPrefix tag, is
+// value
+func (p *ParamDesc) enumName(value string) (string, bool) {
+	number := canonicalNumber(value)
+	if number == "" {
+		return "", false
+	}
+	for _, enumerator := range p.tagValues(TagEnumPrefix) {
+		name, n, ok := strings.Cut(enumerator, "=")
+		if ok && canonicalNumber(n) == number {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// canonicalNumber returns value, an integer in any base, in base 10, or an empty string if it
+// isn't an integer
+func canonicalNumber(value string) string {
+	if n, err := strconv.ParseInt(value, 0, 64); err == nil {
+		return strconv.FormatInt(n, 10)
+	}
+	if n, err := strconv.ParseUint(value, 0, 64); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+	return ""
+}
+
+func (p *ParamDesc) tagValues(prefix string) []string {
+	var values []string
+	for _, tag := range p.Tags {
+		if value, ok := strings.CutPrefix(tag, prefix); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// IsAdvanced returns whether front-ends can collapse the param
+func (p *ParamDesc) IsAdvanced() bool {
+	return slices.Contains(p.Tags, TagAdvanced)
+}
+
+// IsHidden returns whether front-ends shouldn't show the param
+func (p *ParamDesc) IsHidden() bool {
+	return slices.Contains(p.Tags, TagHidden)
+}
+
+// IsSensitive returns whether the values of the param must be masked
+func (p *ParamDesc) IsSensitive() bool {
+	return slices.Contains(p.Tags, TagSensitive)
+}
+
+// MaskedValue replaces the values of sensitive params in logs and errors
+const MaskedValue = "***"
+
+// MaskValue returns value, or MaskedValue if sensitive is set
+func MaskValue(value string, sensitive bool) string {
+	if sensitive {
+		return MaskedValue
+	}
+	return value
+}
+
+// maskedError hides a value in the message of the error it wraps
+type maskedError struct {
+	err   error
+	value string
+}
+
+func (e *maskedError) Error() string {
+	return strings.ReplaceAll(e.err.Error(), e.value, MaskedValue)
+}
+
+func (e *maskedError) Unwrap() error {
+	return e.err
+}
+
+// MaskError returns err with value replaced by MaskedValue in its message if sensitive is set.
+// Errors can still be compared with errors.Is and errors.As.
+func MaskError(err error, value string, sensitive bool) error {
+	if err == nil || !sensitive || value == "" {
+		return err
+	}
+	return &maskedError{err: err, value: value}
+}
+
 func (p ParamDescs) ToParams() *Params {
 	params := make(Params, 0, len(p))
 	for _, param := range p {
@@ -277,6 +411,46 @@ func b64DecodeAndDecompress(s string) ([]byte, error) {
 	return bytes, nil
 }
 
+// CheckDependencies fails if a param was set but a param it requires isn't, or if params
+// conflicting with each other were both set. All the problems are reported at once.
+func (p *Params) CheckDependencies() error {
+	var problems []string
+	for _, param := range *p {
+		if !param.isGiven() {
+			continue
+		}
+		for _, key := range param.Requires() {
+			if required := p.Get(key); required == nil || !required.isEnabled() {
+				problems = append(problems, fmt.Sprintf("%q requires %q to be set", param.Key, key))
+			}
+		}
+		for _, key := range param.ConflictsWith() {
+			// Report each conflict once, conflicts being declared on one or both sides
+			if conflicting := p.Get(key); conflicting != nil && conflicting.isGiven() &&
+				(param.Key < key || !slices.Contains(conflicting.ConflictsWith(), param.Key)) {
+				problems = append(problems, fmt.Sprintf("%q conflicts with %q", param.Key, key))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid combination of params: %s", strings.Join(problems, ", "))
+}
+
+// isGiven returns whether the param was given a value other than its default
+func (p *Param) isGiven() bool {
+	return !p.IsDefault() && p.isEnabled()
+}
+
+// isEnabled returns whether the param has a value, which must be true for bools
+func (p *Param) isEnabled() bool {
+	if p.TypeHint == TypeBool {
+		return p.AsBool()
+	}
+	return p.value != ""
+}
+
 func (p *Params) CopyToMap(target map[string]string, prefix string) {
 	for _, param := range *p {
 		if param.TypeHint == TypeBytes {
@@ -398,8 +572,12 @@ func (p *Param) AsAny() any {
 		return p.AsFloat64()
 	case TypeDuration:
 		return p.AsDuration()
-	case TypeIP:
+	case TypeIP, TypeIPv4, TypeIPv6:
 		return p.AsIP()
+	case TypeCIDR:
+		return p.AsCIDR()
+	case TypeSize:
+		return p.AsSize()
 	default:
 		return p.value
 	}
@@ -529,3 +707,13 @@ func (p *Param) AsDuration() time.Duration {
 func (p *Param) AsIP() net.IP {
 	return net.ParseIP(p.value)
 }
+
+func (p *Param) AsSize() uint64 {
+	n, _ := ParseSize(p.value)
+	return n
+}
+
+func (p *Param) AsCIDR() *net.IPNet {
+	_, ipNet, _ := net.ParseCIDR(p.value)
+	return ipNet
+}
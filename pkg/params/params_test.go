@@ -16,6 +16,7 @@ package params
 
 import (
 	"errors"
+	"fmt"
 	"net"
 	"testing"
 	"time"
@@ -483,3 +484,98 @@ func TestIsDefault(t *testing.T) {
 	p.Set("bar")
 	require.False(t, p.IsDefault())
 }
+
+func TestCheckDependencies(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		values            map[string]string
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"nothing_set": {
+			values: map[string]string{},
+		},
+		"requirement_met": {
+			values: map[string]string{"latency": "true", "threshold": "10ms", "unit": "us"},
+		},
+		"requirement_unmet": {
+			values:            map[string]string{"threshold": "10ms"},
+			expectedErrString: "invalid combination of params: \"threshold\" requires \"latency\" to be set",
+		},
+		"requirement_disabled": {
+			values:            map[string]string{"latency": "false", "threshold": "10ms"},
+			expectedErrString: "\"threshold\" requires \"latency\" to be set",
+		},
+		"requirement_with_default": {
+			values: map[string]string{"latency": "true", "threshold": "10ms"},
+		},
+		"conflict": {
+			values:            map[string]string{"pid": "42", "netns": "4026531840"},
+			expectedErrString: "invalid combination of params: \"netns\" conflicts with \"pid\"",
+		},
+		"several_problems": {
+			values:            map[string]string{"pid": "42", "netns": "4026531840", "threshold": "10ms", "latency": "false"},
+			expectedErrString: "\"threshold\" requires \"latency\" to be set, \"netns\" conflicts with \"pid\"",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ps := Params{
+				(&ParamDesc{Key: "latency", TypeHint: TypeBool, DefaultValue: "false"}).ToParam(),
+				(&ParamDesc{Key: "threshold", Tags: []string{TagRequiresPrefix + "latency", TagRequiresPrefix + "unit"}}).ToParam(),
+				(&ParamDesc{Key: "unit", DefaultValue: "ns"}).ToParam(),
+				(&ParamDesc{Key: "pid", Tags: []string{TagConflictsWithPrefix + "netns"}}).ToParam(),
+				(&ParamDesc{Key: "netns", Tags: []string{TagConflictsWithPrefix + "pid"}}).ToParam(),
+			}
+			require.NoError(t, ps.CopyFromMap(test.values, ""))
+
+			err := ps.CheckDependencies()
+			if test.expectedErrString == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, test.expectedErrString)
+		})
+	}
+}
+
+func TestSensitiveParam(t *testing.T) {
+	t.Parallel()
+
+	errValidator := errors.New("doesn't match")
+	p := (&ParamDesc{
+		Key:      "user",
+		Tags:     []string{TagSensitive},
+		TypeHint: TypeString,
+		Validator: func(value string) error {
+			return fmt.Errorf("%q %w", value, errValidator)
+		},
+	}).ToParam()
+	require.True(t, p.IsSensitive())
+
+	err := p.Set("alice")
+	require.EqualError(t, err, "invalid value \"***\" as \"user\": \"***\" doesn't match")
+	require.ErrorIs(t, err, errValidator)
+
+	p = (&ParamDesc{
+		Key:            "user",
+		Tags:           []string{TagSensitive},
+		PossibleValues: []string{"root"},
+	}).ToParam()
+	require.EqualError(t, p.Set("alice"), "invalid value \"***\" as \"user\": valid values are: root")
+
+	// Values of other params are shown
+	p = (&ParamDesc{Key: "user", PossibleValues: []string{"root"}}).ToParam()
+	require.False(t, p.IsSensitive())
+	require.EqualError(t, p.Set("alice"), "invalid value \"alice\" as \"user\": valid values are: root")
+
+	require.Equal(t, MaskedValue, MaskValue("alice", true))
+	require.Equal(t, "alice", MaskValue("alice", false))
+	require.Nil(t, MaskError(nil, "alice", true))
+}
@@ -539,3 +539,131 @@ func TestValidateIP(t *testing.T) {
 		ValidateIP,
 	)
 }
+
+func TestValidateIPv4(t *testing.T) {
+	testValidate(t,
+		[]validateTest{
+			{
+				name:          "IPv4_no_error",
+				value:         "10.0.0.1",
+				expectedError: false,
+			},
+			{
+				name:          "empty_no_error",
+				value:         "",
+				expectedError: false,
+			},
+			{
+				name:          "IPv6",
+				value:         "::1",
+				expectedError: true,
+			},
+			{
+				name:          "bad_input",
+				value:         "10.0.0.256",
+				expectedError: true,
+			},
+		},
+		ValidateIPv4,
+	)
+}
+
+func TestValidateIPv6(t *testing.T) {
+	testValidate(t,
+		[]validateTest{
+			{
+				name:          "IPv6_no_error",
+				value:         "2001:db8::1",
+				expectedError: false,
+			},
+			{
+				name:          "empty_no_error",
+				value:         "",
+				expectedError: false,
+			},
+			{
+				name:          "IPv4",
+				value:         "10.0.0.1",
+				expectedError: true,
+			},
+			{
+				name:          "bad_input",
+				value:         "2001:db8:::1",
+				expectedError: true,
+			},
+		},
+		ValidateIPv6,
+	)
+}
+
+func TestValidateCIDR(t *testing.T) {
+	testValidate(t,
+		[]validateTest{
+			{
+				name:          "IPv4_no_error",
+				value:         "10.0.0.0/8",
+				expectedError: false,
+			},
+			{
+				name:          "IPv6_no_error",
+				value:         "2001:db8::/32",
+				expectedError: false,
+			},
+			{
+				name:          "empty_no_error",
+				value:         "",
+				expectedError: false,
+			},
+			{
+				name:          "no_prefix_length",
+				value:         "10.0.0.0",
+				expectedError: true,
+			},
+			{
+				name:          "prefix_too_long",
+				value:         "10.0.0.0/33",
+				expectedError: true,
+			},
+		},
+		ValidateCIDR,
+	)
+}
+
+func TestParseSize(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		value             string
+		expectedSize      uint64
+		expectedErrString string
+	}
+
+	tests := map[string]testCase{
+		"bytes":       {value: "4096", expectedSize: 4096},
+		"bytes_unit":  {value: "512B", expectedSize: 512},
+		"kib":         {value: "4KiB", expectedSize: 4096},
+		"mb":          {value: "1MB", expectedSize: 1000 * 1000},
+		"gib":         {value: "2GiB", expectedSize: 2 << 30},
+		"space":       {value: "16 MiB", expectedSize: 16 << 20},
+		"empty":       {value: "", expectedErrString: "\"\" is not a valid size"},
+		"unknown":     {value: "4Ki", expectedErrString: "\"4Ki\" has an unknown unit \"Ki\""},
+		"fractional":  {value: "1.5MB", expectedErrString: "\"1.5MB\" is not a valid size"},
+		"negative":    {value: "-1KiB", expectedErrString: "\"-1KiB\" is not a valid size"},
+		"overflowing": {value: "20000000TiB", expectedErrString: "\"20000000TiB\" is too large"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			size, err := ParseSize(test.value)
+			if test.expectedErrString != "" {
+				require.ErrorContains(t, err, test.expectedErrString)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expectedSize, size)
+		})
+	}
+}
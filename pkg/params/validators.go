@@ -16,6 +16,7 @@ package params
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"strconv"
 	"strings"
@@ -43,6 +44,10 @@ const (
 	TypeFloat64  TypeHint = "float64"
 	TypeDuration TypeHint = "duration"
 	TypeIP       TypeHint = "ip"
+	TypeIPv4     TypeHint = "ipv4"
+	TypeIPv6     TypeHint = "ipv6"
+	TypeCIDR     TypeHint = "cidr"
+	TypeSize     TypeHint = "size"
 )
 
 var typeHintValidators = map[TypeHint]ParamValidator{
@@ -61,6 +66,10 @@ var typeHintValidators = map[TypeHint]ParamValidator{
 	TypeFloat64:  ValidateFloat(64),
 	TypeDuration: ValidateDuration,
 	TypeIP:       ValidateIP,
+	TypeIPv4:     ValidateIPv4,
+	TypeIPv6:     ValidateIPv6,
+	TypeCIDR:     ValidateCIDR,
+	TypeSize:     ValidateSize,
 }
 
 type ValueHint string
@@ -151,9 +160,83 @@ func ValidateDuration(value string) error {
 	return err
 }
 
+// ValidateSize checks that value is a size in bytes, optionally followed by a
+// unit like KiB or MB
+func ValidateSize(value string) error {
+	_, err := ParseSize(value)
+	return err
+}
+
 func ValidateIP(value string) error {
 	if value != "" && net.ParseIP(value) == nil {
 		return fmt.Errorf("%q is not a valid IP address", value)
 	}
 	return nil
 }
+
+func ValidateIPv4(value string) error {
+	if value == "" {
+		return nil
+	}
+	if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+		return fmt.Errorf("%q is not a valid IPv4 address", value)
+	}
+	return nil
+}
+
+func ValidateIPv6(value string) error {
+	if value == "" {
+		return nil
+	}
+	if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+		return fmt.Errorf("%q is not a valid IPv6 address", value)
+	}
+	return nil
+}
+
+// ValidateCIDR checks that value is an IPv4 or IPv6 network in CIDR notation,
+// like 10.0.0.0/8
+func ValidateCIDR(value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, _, err := net.ParseCIDR(value); err != nil {
+		return fmt.Errorf("%q is not a valid CIDR", value)
+	}
+	return nil
+}
+
+// sizeUnits are the multipliers of the units of sizes, both decimal and binary
+var sizeUnits = map[string]uint64{
+	"":    1,
+	"B":   1,
+	"kB":  1000,
+	"KB":  1000,
+	"MB":  1000 * 1000,
+	"GB":  1000 * 1000 * 1000,
+	"TB":  1000 * 1000 * 1000 * 1000,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"TiB": 1 << 40,
+}
+
+// ParseSize parses a size in bytes, optionally followed by a unit like KiB or
+// MB: "4096", "4KiB" and "1MB" are valid sizes.
+func ParseSize(value string) (uint64, error) {
+	digits := strings.TrimRight(value, "BKMGTiBk")
+	unit := value[len(digits):]
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("%q has an unknown unit %q, expected B, kB, MB, GB, TB, KiB, MiB, GiB or TiB", value, unit)
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(digits), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid size", value)
+	}
+	if n > math.MaxUint64/multiplier {
+		return 0, fmt.Errorf("%q is too large", value)
+	}
+	return n * multiplier, nil
+}
@@ -83,6 +83,11 @@ func (oh *outputHelper[T]) EventHandlerFunc() any {
 			}
 		}
 
+		// Columns sized from their content are measured on the first
+		// entries; the header is written again if they grew meanwhile
+		if oh.TextColumnsFormatter.MeasureStreamEntry(ev) {
+			oh.eventCallback(oh.TextColumnsFormatter.FormatHeader())
+		}
 		oh.forwardEvent(ev)
 	}
 }
@@ -92,6 +97,12 @@ func (oh *outputHelper[T]) EventHandlerFuncArray(headerFuncs ...func()) any {
 		panic("set event callback before getting the EventHandlerFunc from TextColumnsFormatter")
 	}
 	return func(events []*T) {
+		// Columns sized from their content are measured on the whole array;
+		// without headerFuncs, the header was already written and is written
+		// again if they grew
+		if oh.TextColumnsFormatter.MeasureEntries(events) && len(headerFuncs) == 0 {
+			oh.eventCallback(oh.TextColumnsFormatter.FormatHeader())
+		}
 		for _, hf := range headerFuncs {
 			hf()
 		}
@@ -35,6 +35,10 @@ import (
 
 const (
 	SocketsMapName = "gadget_sockets"
+
+	// SocketsMapLayoutVersion must be increased on every change of the key
+	// or value of the sockets map, see include/gadget/sockets-map.h
+	SocketsMapLayoutVersion = 1
 )
 
 // SocketEnricher creates a map exposing processes owning each socket.
@@ -55,6 +59,19 @@ func (se *SocketEnricher) SocketsMap() *ebpf.Map {
 	return se.objs.GadgetSockets
 }
 
+// SocketsMapSpec returns the spec of the sockets map
+func SocketsMapSpec() (*ebpf.MapSpec, error) {
+	spec, err := loadSocketenricher()
+	if err != nil {
+		return nil, fmt.Errorf("loading socket enricher asset: %w", err)
+	}
+	mapSpec, ok := spec.Maps[SocketsMapName]
+	if !ok {
+		return nil, fmt.Errorf("map %q not found in socket enricher asset", SocketsMapName)
+	}
+	return mapSpec, nil
+}
+
 func NewSocketEnricher() (*SocketEnricher, error) {
 	se := &SocketEnricher{}
 
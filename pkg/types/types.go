@@ -49,7 +49,20 @@ func init() {
 	columns.MustRegisterTemplate("ipport", "minWidth:type")
 	// Assume type width for ipport is 5 characters long. Delimiter is 1. Add that to ipaddr template
 	columns.MustRegisterTemplate("ipaddrport", "minWidth:22,width:40,maxWidth:52")
+	// Same as ipaddrport, for L4 endpoints of eBPF gadgets
+	columns.MustRegisterTemplate("l4endpoint", "minWidth:22,width:40,maxWidth:52")
 	columns.MustRegisterTemplate("ipversion", "width:2,fixed")
+	// For MAC addresses: XX:XX:XX:XX:XX:XX = 17
+	columns.MustRegisterTemplate("macaddr", "width:17,fixed")
+	// For errors shown by name: ENOTRECOVERABLE = 15
+	columns.MustRegisterTemplate("errno", "width:15")
+	// For signals: the longest is SIGRTMIN+32 = 11
+	columns.MustRegisterTemplate("signal", "width:11,maxWidth:11")
+	// For capability sets: show the first ones, the full set can be very long
+	columns.MustRegisterTemplate("capabilities", "width:32,ellipsis:end")
+	// For durations like 999.99ms; the width depends on the precision and is
+	// set by the datasource package
+	columns.MustRegisterTemplate("duration", "align:right")
 
 	// For system calls as the longest is sched_rr_get_interval_time64 with 28
 	// characters:
@@ -16,7 +16,7 @@
 // to containers. It has two running modes: `pending` mode and `running` mode.
 //
 // Before `AttachProg` is called, uprobetracer runs in `pending` mode, only
-// maintaining the container PIDs ready to attach to.
+// maintaining the containers ready to attach to.
 //
 // When `AttachProg` is called, uprobetracer enters the `running` mode and
 // attaches to all pending containers. After that, it will never get back to
@@ -28,6 +28,10 @@
 // Uprobetracer doesn't maintain ebpf.collection or perf-ring buffer by itself,
 // those are hold by the parent tracer.
 //
+// The file path in the section name can be a template, like
+// `uprobe/{{ .Container.Image.Entrypoint }}:main`, that is resolved for each
+// container. Containers the template can't be resolved for are skipped.
+//
 // All interfaces should hold locks, while inner functions do not.
 package uprobetracer
 
@@ -44,6 +48,7 @@ import (
 	"github.com/cilium/ebpf/link"
 
 	containercollection "github.com/inspektor-gadget/inspektor-gadget/pkg/container-collection"
+	containertemplate "github.com/inspektor-gadget/inspektor-gadget/pkg/container-template"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/kfilefields"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/logger"
 	"github.com/inspektor-gadget/inspektor-gadget/pkg/utils/host"
@@ -77,8 +82,10 @@ type Tracer[Event any] struct {
 	progName       string
 	progType       ProgType
 	attachFilePath string
-	attachSymbol   string
-	prog           *ebpf.Program
+	// attachFileTemplate is set if attachFilePath is a template
+	attachFileTemplate *containertemplate.Template
+	attachSymbol       string
+	prog               *ebpf.Program
 
 	// keeps the inodes for each attached container
 	// when users write library names in ebpf section names, it's possible to
@@ -100,8 +107,8 @@ type Tracer[Event any] struct {
 	// To deduplicate, we need to identify the underlying inode hidden by overlayFS,
 	// and use it as a unique identifier. For each realInodePtr, we only attach to it once.
 	inodeRefCount map[uint64]*inodeKeeper
-	// used as a set, keeps PIDs of the pending containers
-	pendingContainerPids map[uint32]bool
+	// keeps the pending containers by PID
+	pendingContainers map[uint32]*containercollection.Container
 
 	logger logger.Logger
 
@@ -111,11 +118,11 @@ type Tracer[Event any] struct {
 
 func NewTracer[Event any](logger logger.Logger) (*Tracer[Event], error) {
 	t := &Tracer[Event]{
-		containerPid2Inodes:  make(map[uint32][]uint64),
-		inodeRefCount:        make(map[uint64]*inodeKeeper),
-		pendingContainerPids: make(map[uint32]bool),
-		logger:               logger,
-		closed:               false,
+		containerPid2Inodes: make(map[uint32][]uint64),
+		inodeRefCount:       make(map[uint64]*inodeKeeper),
+		pendingContainers:   make(map[uint32]*containercollection.Container),
+		logger:              logger,
+		closed:              false,
 	}
 	return t, nil
 }
@@ -137,8 +144,15 @@ func (t *Tracer[Event]) AttachProg(progName string, progType ProgType, attachTo
 	if len(parts) < 2 {
 		return fmt.Errorf("invalid section name %q", attachTo)
 	}
-	if !filepath.IsAbs(parts[0]) && strings.Contains(parts[0], "/") {
-		return fmt.Errorf("section name must be either an absolute path or a library name: %q", parts[0])
+	var attachFileTemplate *containertemplate.Template
+	if containertemplate.IsTemplate(parts[0]) {
+		tmpl, err := containertemplate.Parse(parts[0])
+		if err != nil {
+			return fmt.Errorf("invalid section name %q: %w", attachTo, err)
+		}
+		attachFileTemplate = tmpl
+	} else if err := validateAttachFilePath(parts[0]); err != nil {
+		return err
 	}
 	if progType == ProgUSDT && len(strings.Split(parts[1], ":")) != 2 {
 		return fmt.Errorf("invalid USDT section name: %q", attachTo)
@@ -154,20 +168,43 @@ func (t *Tracer[Event]) AttachProg(progName string, progType ProgType, attachTo
 	t.progName = progName
 	t.progType = progType
 	t.attachFilePath = parts[0]
+	t.attachFileTemplate = attachFileTemplate
 	t.attachSymbol = parts[1]
 	t.prog = prog
 
 	// attach to pending containers, then release the pending list
-	for pid := range t.pendingContainerPids {
-		t.attach(pid)
+	for _, container := range t.pendingContainers {
+		t.attach(container)
 	}
-	t.pendingContainerPids = nil
+	t.pendingContainers = nil
 
 	return nil
 }
 
-func (t *Tracer[Event]) searchForLibrary(containerPid uint32) ([]string, error) {
-	filePath := t.attachFilePath
+func validateAttachFilePath(filePath string) error {
+	if !filepath.IsAbs(filePath) && strings.Contains(filePath, "/") {
+		return fmt.Errorf("section name must be either an absolute path or a library name: %q", filePath)
+	}
+	return nil
+}
+
+// attachFilePathFor returns the file path to attach to in the given container
+func (t *Tracer[Event]) attachFilePathFor(container *containercollection.Container) (string, error) {
+	if t.attachFileTemplate == nil {
+		return t.attachFilePath, nil
+	}
+
+	filePath, err := t.attachFileTemplate.Resolve(container)
+	if err != nil {
+		return "", err
+	}
+	if err := validateAttachFilePath(filePath); err != nil {
+		return "", fmt.Errorf("template %q: %w", t.attachFilePath, err)
+	}
+	return filePath, nil
+}
+
+func (t *Tracer[Event]) searchForLibrary(containerPid uint32, filePath string) ([]string, error) {
 	if filepath.IsAbs(filePath) {
 		return []string{filePath}, nil
 	}
@@ -208,9 +245,21 @@ func (t *Tracer[Event]) attachUprobe(file *os.File) (link.Link, error) {
 }
 
 // try attaching to a container, will update `containerPid2Inodes`
-func (t *Tracer[Event]) attach(containerPid uint32) {
+func (t *Tracer[Event]) attach(container *containercollection.Container) {
+	containerPid := container.Pid
+
 	var attachedRealInodes []uint64
-	unsecuredAttachFilePaths, err := t.searchForLibrary(containerPid)
+	defer func() {
+		t.containerPid2Inodes[containerPid] = attachedRealInodes
+	}()
+
+	attachFilePath, err := t.attachFilePathFor(container)
+	if err != nil {
+		t.logger.Warnf("skipping container %q for uprobe %q: %s", container.K8s.ContainerName, t.progName, err)
+		return
+	}
+
+	unsecuredAttachFilePaths, err := t.searchForLibrary(containerPid, attachFilePath)
 	if err != nil {
 		t.logger.Debugf("attaching to container %d: %s", containerPid, err.Error())
 	}
@@ -252,8 +301,6 @@ func (t *Tracer[Event]) attach(containerPid uint32) {
 			file.Close()
 		}
 	}
-
-	t.containerPid2Inodes[containerPid] = attachedRealInodes
 }
 
 // AttachContainer will attach now if the prog is ready, otherwise it will add container into the pending list
@@ -266,17 +313,17 @@ func (t *Tracer[Event]) AttachContainer(container *containercollection.Container
 	}
 
 	if t.prog == nil {
-		_, exist := t.pendingContainerPids[container.Pid]
+		_, exist := t.pendingContainers[container.Pid]
 		if exist {
 			return fmt.Errorf("container PID already exists: %d", container.Pid)
 		}
-		t.pendingContainerPids[container.Pid] = true
+		t.pendingContainers[container.Pid] = container
 	} else {
 		_, exist := t.containerPid2Inodes[container.Pid]
 		if exist {
 			return fmt.Errorf("container PID already exists: %d", container.Pid)
 		}
-		t.attach(container.Pid)
+		t.attach(container)
 	}
 	return nil
 }
@@ -291,11 +338,11 @@ func (t *Tracer[Event]) DetachContainer(container *containercollection.Container
 
 	if t.prog == nil {
 		// remove from pending list
-		_, exist := t.pendingContainerPids[container.Pid]
+		_, exist := t.pendingContainers[container.Pid]
 		if !exist {
 			return errors.New("container has not been attached")
 		}
-		delete(t.pendingContainerPids, container.Pid)
+		delete(t.pendingContainers, container.Pid)
 	} else {
 		// detach from container if attached
 		attachedRealInodes, exist := t.containerPid2Inodes[container.Pid]
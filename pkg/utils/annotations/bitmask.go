@@ -0,0 +1,136 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// BitmaskAnnotation sets the name of the field the names of the flags
+	// set in an integer field are written to
+	BitmaskAnnotation = "ebpf.formatter.bitmask"
+
+	// BitmaskBitsAnnotation maps bit positions to names, like
+	// "0:O_WRONLY,6:O_CREAT"
+	BitmaskBitsAnnotation = "ebpf.formatter.bitmask.bits"
+
+	// BitmaskZeroAnnotation is the name shown when no bit is set
+	BitmaskZeroAnnotation = "ebpf.formatter.bitmask.zero"
+)
+
+// ParseBitmask parses the value of BitmaskBitsAnnotation
+func ParseBitmask(s string) (map[uint]string, error) {
+	bits := make(map[uint]string)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		bitStr, name, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid bitmask entry %q, expected bit:name", entry)
+		}
+		bit, err := strconv.ParseUint(bitStr, 10, 8)
+		if err != nil || bit >= 64 {
+			return nil, fmt.Errorf("invalid bit %q in bitmask entry %q", bitStr, entry)
+		}
+		if _, ok := bits[uint(bit)]; ok {
+			return nil, fmt.Errorf("bit %d defined more than once in bitmask", bit)
+		}
+		bits[uint(bit)] = name
+	}
+	return bits, nil
+}
+
+// FormatBitmask returns the value of BitmaskBitsAnnotation for bits
+func FormatBitmask(bits map[uint]string) string {
+	entries := make([]string, 0, len(bits))
+	for _, bit := range sortedBits(bits) {
+		entries = append(entries, fmt.Sprintf("%d:%s", bit, bits[bit]))
+	}
+	return strings.Join(entries, ",")
+}
+
+// RenderBitmask returns the names of the bits set in val joined by separator.
+// Bits without a name are shown as a single hexadecimal value at the end.
+func RenderBitmask(val uint64, bits map[uint]string, zero, separator string) string {
+	return RenderFlags(val, BitmaskFlags(bits), zero, separator)
+}
+
+// Flag is the name of a bit, or of a group of bits, of a bitmask
+type Flag struct {
+	Value uint64
+	Name  string
+}
+
+// BitmaskFlags returns the flags of the bits returned by ParseBitmask
+func BitmaskFlags(bits map[uint]string) []Flag {
+	flags := make([]Flag, 0, len(bits))
+	for _, bit := range sortedBits(bits) {
+		flags = append(flags, Flag{Value: 1 << bit, Name: bits[bit]})
+	}
+	return flags
+}
+
+// RenderFlags returns the names of the flags whose bits are all set in val,
+// in the order of flags, joined by separator. Bits not covered by any flag
+// are shown as a single hexadecimal value at the end.
+func RenderFlags(val uint64, flags []Flag, zero, separator string) string {
+	if val == 0 {
+		if zero != "" {
+			return zero
+		}
+		return "0"
+	}
+
+	var names []string
+	remaining := val
+	for _, flag := range flags {
+		if flag.Value != 0 && val&flag.Value == flag.Value {
+			names = append(names, flag.Name)
+			remaining &^= flag.Value
+		}
+	}
+	if remaining != 0 {
+		names = append(names, fmt.Sprintf("0x%x", remaining))
+	}
+	return strings.Join(names, separator)
+}
+
+// FlagsWidth returns the width needed to show all the flags of a bitmask of
+// size bytes set at once, along with unknown bits
+func FlagsWidth(flags []Flag, zero, separator string, size int) int {
+	width := 0
+	for _, flag := range flags {
+		if flag.Value != 0 {
+			width += len(flag.Name) + len(separator)
+		}
+	}
+	width += len("0x") + 2*size
+	return max(width, len(zero))
+}
+
+func sortedBits(bits map[uint]string) []uint {
+	sorted := make([]uint, 0, len(bits))
+	for bit := range bits {
+		sorted = append(sorted, bit)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
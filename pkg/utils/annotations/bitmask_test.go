@@ -0,0 +1,112 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package annotations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBitmask(t *testing.T) {
+	t.Parallel()
+
+	bits, err := ParseBitmask("0:O_WRONLY, 6:O_CREAT,19:O_CLOEXEC")
+	require.NoError(t, err)
+	require.Equal(t, map[uint]string{0: "O_WRONLY", 6: "O_CREAT", 19: "O_CLOEXEC"}, bits)
+	require.Equal(t, "0:O_WRONLY,6:O_CREAT,19:O_CLOEXEC", FormatBitmask(bits))
+
+	for _, invalid := range []string{"O_WRONLY", "0:", "x:A", "64:A", "1:A,1:B"} {
+		_, err := ParseBitmask(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestRenderBitmask(t *testing.T) {
+	t.Parallel()
+
+	bits := map[uint]string{0: "O_WRONLY", 6: "O_CREAT", 19: "O_CLOEXEC"}
+
+	type testCase struct {
+		val      uint64
+		zero     string
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"zero":         {val: 0, zero: "O_RDONLY", expected: "O_RDONLY"},
+		"zero_no_name": {val: 0, expected: "0"},
+		"single":       {val: 0o100, expected: "O_CREAT"},
+		"multiple":     {val: 0o2000101, expected: "O_WRONLY|O_CREAT|O_CLOEXEC"},
+		"unknown_bits": {val: 0o100 | 0x300, expected: "O_CREAT|0x300"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, RenderBitmask(test.val, bits, test.zero, "|"))
+		})
+	}
+}
+
+func TestRenderFlags(t *testing.T) {
+	t.Parallel()
+
+	flags := []Flag{
+		{Value: 0o1, Name: "O_WRONLY"},
+		{Value: 0o2, Name: "O_RDWR"},
+		{Value: 0o100, Name: "O_CREAT"},
+		{Value: 0o1000, Name: "O_TRUNC"},
+		{Value: 0o2000000, Name: "O_CLOEXEC"},
+		// Multi-bit mask
+		{Value: 0o300, Name: "O_CREAT_EXCL"},
+	}
+
+	type testCase struct {
+		val      uint64
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"zero":         {val: 0, expected: "O_RDONLY"},
+		"single":       {val: 0o1, expected: "O_WRONLY"},
+		"multiple":     {val: 0o2001102, expected: "O_RDWR|O_CREAT|O_TRUNC|O_CLOEXEC"},
+		"mask":         {val: 0o301, expected: "O_WRONLY|O_CREAT|O_CREAT_EXCL"},
+		"partial_mask": {val: 0o200, expected: "0x80"},
+		"unknown_bit":  {val: 0o1101 | 0x8000000, expected: "O_WRONLY|O_CREAT|O_TRUNC|0x8000000"},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, RenderFlags(test.val, flags, "O_RDONLY", "|"))
+		})
+	}
+}
+
+func TestFlagsWidth(t *testing.T) {
+	t.Parallel()
+
+	flags := []Flag{{Value: 1, Name: "A"}, {Value: 2, Name: "BB"}}
+
+	// "A|BB|0xffffffff"
+	require.Equal(t, 15, FlagsWidth(flags, "", "|", 4))
+	require.Equal(t, len(RenderFlags(0xffffffff, flags, "", "|")), FlagsWidth(flags, "", "|", 4))
+	require.Equal(t, 20, FlagsWidth(flags, "NOTHING_AT_ALL_SET_X", "|", 1))
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package capabilities renders Linux capability sets, as stored by the kernel
+// in kernel_cap_t, as the names of the capabilities they hold.
+package capabilities
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/gocapability/capability"
+)
+
+// Separator is placed between the names of the capabilities of a set
+const Separator = "|"
+
+// Name returns the name of capability c, like CAP_NET_ADMIN for 12, or
+// CAP_<c> if it's unknown
+func Name(c uint) string {
+	if name := capability.Cap(c).String(); name != "unknown" {
+		return "CAP_" + strings.ToUpper(name)
+	}
+	return "CAP_" + strconv.FormatUint(uint64(c), 10)
+}
+
+// Format returns the names of the capabilities in the set mask, by bit
+// position, separated by Separator. Unknown bits are kept, see Name. It
+// returns "" for an empty set.
+func Format(mask uint64) string {
+	var names []string
+	for c := uint(0); c < 64; c++ {
+		if mask&(1<<c) != 0 {
+			names = append(names, Name(c))
+		}
+	}
+	return strings.Join(names, Separator)
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package capabilities
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mask     uint64
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"empty": {
+			mask:     0,
+			expected: "",
+		},
+		"single": {
+			mask:     1 << 12,
+			expected: "CAP_NET_ADMIN",
+		},
+		"several": {
+			mask:     1<<12 | 1<<19,
+			expected: "CAP_NET_ADMIN|CAP_SYS_PTRACE",
+		},
+		"unknown_bit": {
+			mask:     1<<0 | 1<<63,
+			expected: "CAP_CHOWN|CAP_63",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, Format(test.mask))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package errno
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		ret      int64
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"eacces": {
+			ret:      -13,
+			expected: "EACCES",
+		},
+		"zero": {
+			ret:      0,
+			expected: "0",
+		},
+		"fd": {
+			ret:      3,
+			expected: "3",
+		},
+		"unknown": {
+			ret:      -4095,
+			expected: "-4095",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, Format(test.ret))
+		})
+	}
+}
+
+func TestMaxNameLen(t *testing.T) {
+	t.Parallel()
+
+	longest := 0
+	for _, name := range names {
+		longest = max(longest, len(name))
+	}
+	require.Equal(t, MaxNameLen, longest)
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact hides sensitive values, like command line arguments that
+// may contain secrets, before they're shown or exported.
+package redact
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ModeFull replaces the whole value
+	ModeFull = "full"
+	// ModeKeepPrefix keeps the first n characters of the value, it's used as
+	// "keepPrefix:<n>"
+	ModeKeepPrefix = "keepPrefix"
+	// ModeHash replaces the value by a short hash of it, so equal values can
+	// still be correlated
+	ModeHash = "hash"
+
+	// Mask is shown instead of the redacted part of values
+	Mask = "***"
+
+	// hashPrefix is prepended to hashed values
+	hashPrefix = "sha256:"
+	// hashLen is the number of bytes of the hash that are kept
+	hashLen = 8
+)
+
+// Redactor returns the redacted form of a value. Empty values are kept as
+// they are, they don't disclose anything.
+type Redactor func(string) string
+
+// Parse returns the redactor for the given mode: "full", "keepPrefix:<n>" or
+// "hash"
+func Parse(mode string) (Redactor, error) {
+	name, arg, hasArg := strings.Cut(mode, ":")
+	switch name {
+	case ModeFull, ModeHash:
+		if hasArg {
+			return nil, fmt.Errorf("redaction mode %q doesn't take an argument", name)
+		}
+	case ModeKeepPrefix:
+		if !hasArg {
+			return nil, fmt.Errorf("redaction mode %q needs the number of characters to keep, like %s:4", name, name)
+		}
+	default:
+		return nil, fmt.Errorf("invalid redaction mode %q, expected %q, \"%s:<n>\" or %q", mode, ModeFull, ModeKeepPrefix, ModeHash)
+	}
+
+	switch name {
+	case ModeFull:
+		return full, nil
+	case ModeHash:
+		return hash, nil
+	}
+
+	n, err := strconv.ParseUint(arg, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of characters to keep %q: %w", arg, err)
+	}
+	return keepPrefix(int(n)), nil
+}
+
+func full(s string) string {
+	if s == "" {
+		return ""
+	}
+	return Mask
+}
+
+func hash(s string) string {
+	if s == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hashPrefix + hex.EncodeToString(sum[:hashLen])
+}
+
+func keepPrefix(n int) Redactor {
+	return func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= n {
+			return s
+		}
+		return string(runes[:n]) + Mask
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		mode     string
+		value    string
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"full": {
+			mode:     "full",
+			value:    "curl -u admin:hunter2",
+			expected: "***",
+		},
+		"full_empty": {
+			mode:     "full",
+			value:    "",
+			expected: "",
+		},
+		"keep_prefix": {
+			mode:     "keepPrefix:4",
+			value:    "curl -u admin:hunter2",
+			expected: "curl***",
+		},
+		"keep_prefix_short_value": {
+			mode:     "keepPrefix:4",
+			value:    "ls",
+			expected: "ls",
+		},
+		"keep_prefix_multibyte": {
+			mode:     "keepPrefix:2",
+			value:    "héllo",
+			expected: "hé***",
+		},
+		"keep_prefix_zero": {
+			mode:     "keepPrefix:0",
+			value:    "secret",
+			expected: "***",
+		},
+		"hash": {
+			mode:     "hash",
+			value:    "hunter2",
+			expected: "sha256:f52fbd32b2b3b86f",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			redact, err := Parse(test.mode)
+			require.NoError(t, err)
+			require.Equal(t, test.expected, redact(test.value))
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"unknown":        "invalid redaction mode \"unknown\"",
+		"mask":           "invalid redaction mode \"mask\"",
+		"keepPrefix":     "needs the number of characters to keep",
+		"keepPrefix:-1":  "invalid number of characters to keep \"-1\"",
+		"keepPrefix:abc": "invalid number of characters to keep \"abc\"",
+		"full:1":         "redaction mode \"full\" doesn't take an argument",
+		"hash:sha1":      "redaction mode \"hash\" doesn't take an argument",
+		"":               "invalid redaction mode \"\"",
+	}
+
+	for mode, expectedErrString := range tests {
+		mode, expectedErrString := mode, expectedErrString
+		t.Run(mode, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := Parse(mode)
+			require.ErrorContains(t, err, expectedErrString)
+		})
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package signals maps Linux signal numbers to their names. Like for errors,
+// the numbers are the ones of the kernel, whatever the platform of the client.
+package signals
+
+import "strconv"
+
+const (
+	// RTMin is the first realtime signal, as seen by the kernel. The C
+	// library may reserve some of them.
+	RTMin = 32
+	// RTMax is the last realtime signal
+	RTMax = 64
+
+	// MaxNameLen is the length of the longest name, SIGRTMIN+32
+	MaxNameLen = 11
+)
+
+// names holds the standard signals of include/uapi/asm-generic/signal.h, used
+// by x86 and arm64
+var names = [RTMin]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	10: "SIGUSR1",
+	11: "SIGSEGV",
+	12: "SIGUSR2",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+	16: "SIGSTKFLT",
+	17: "SIGCHLD",
+	18: "SIGCONT",
+	19: "SIGSTOP",
+	20: "SIGTSTP",
+	21: "SIGTTIN",
+	22: "SIGTTOU",
+	23: "SIGURG",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+	26: "SIGVTALRM",
+	27: "SIGPROF",
+	28: "SIGWINCH",
+	29: "SIGIO",
+	30: "SIGPWR",
+	31: "SIGSYS",
+}
+
+// Name returns the name of signal n, like SIGKILL for 9. Realtime signals are
+// named from SIGRTMIN, like SIGRTMIN+2 for 34. Numbers that aren't signals
+// are returned as numbers.
+func Name(n int64) string {
+	switch {
+	case n > 0 && n < RTMin:
+		return names[n]
+	case n == RTMin:
+		return "SIGRTMIN"
+	case n > RTMin && n <= RTMax:
+		return "SIGRTMIN+" + strconv.FormatInt(n-RTMin, 10)
+	}
+	return strconv.FormatInt(n, 10)
+}
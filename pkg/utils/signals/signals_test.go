@@ -0,0 +1,72 @@
+// Copyright 2024 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signals
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestName(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		n        int64
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"sigkill": {
+			n:        9,
+			expected: "SIGKILL",
+		},
+		"sigterm": {
+			n:        15,
+			expected: "SIGTERM",
+		},
+		"rtmin": {
+			n:        32,
+			expected: "SIGRTMIN",
+		},
+		"realtime": {
+			n:        34,
+			expected: "SIGRTMIN+2",
+		},
+		"rtmax": {
+			n:        64,
+			expected: "SIGRTMIN+32",
+		},
+		"zero": {
+			n:        0,
+			expected: "0",
+		},
+		"out_of_range": {
+			n:        65,
+			expected: "65",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := Name(test.n)
+			require.Equal(t, test.expected, got)
+			require.LessOrEqual(t, len(got), MaxNameLen)
+		})
+	}
+}
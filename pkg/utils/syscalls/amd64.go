@@ -15,7 +15,7 @@
 package syscalls
 
 // This is updated to kernel 6.6-rc2
-var syscallsNameToNumber = map[string]int{
+var amd64NameToNumber = map[string]int{
 	"_sysctl":                 156,
 	"accept":                  43,
 	"accept4":                 288,
@@ -376,7 +376,7 @@ var syscallsNameToNumber = map[string]int{
 	"writev":                  20,
 }
 
-var syscallsNumberToName = map[int]string{
+var amd64NumberToName = map[int]string{
 	156: "_sysctl",
 	43:  "accept",
 	288: "accept4",
@@ -15,7 +15,7 @@
 package syscalls
 
 // This is updated to kernel 6.6-rc2
-var syscallsNameToNumber = map[string]int{
+var arm64NameToNumber = map[string]int{
 	"accept":                  202,
 	"accept4":                 242,
 	"acct":                    89,
@@ -325,7 +325,7 @@ var syscallsNameToNumber = map[string]int{
 	"writev":                  66,
 }
 
-var syscallsNumberToName = map[int]string{
+var arm64NumberToName = map[int]string{
 	202: "accept",
 	242: "accept4",
 	89:  "acct",
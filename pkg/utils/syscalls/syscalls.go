@@ -12,16 +12,97 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
+// Package syscalls maps syscall numbers to names. The numbers differ between
+// architectures: the tables of all the supported architectures are compiled
+// in and the one of the host is used, unless overridden with SetArch to
+// inspect data captured elsewhere.
 package syscalls
 
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strconv"
+	"sync/atomic"
+)
+
+type table struct {
+	nameToNumber map[string]int
+	numberToName map[int]string
+}
+
+// tables holds the syscall table of each architecture, by GOARCH name
+var tables = map[string]table{
+	"amd64": {nameToNumber: amd64NameToNumber, numberToName: amd64NumberToName},
+	"arm64": {nameToNumber: arm64NameToNumber, numberToName: arm64NumberToName},
+}
+
+// arch is the architecture whose table is used, see SetArch
+var arch atomic.Value
+
+func init() {
+	arch.Store(runtime.GOARCH)
+}
+
+// Arches returns the architectures with a syscall table, sorted
+func Arches() []string {
+	arches := make([]string, 0, len(tables))
+	for a := range tables {
+		arches = append(arches, a)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// Arch returns the architecture whose table is used
+func Arch() string {
+	return arch.Load().(string)
+}
+
+// SetArch sets the architecture whose table is used, by GOARCH name. It
+// defaults to the one of the host.
+func SetArch(a string) error {
+	if _, ok := tables[a]; !ok {
+		return fmt.Errorf("no syscall table for architecture %q, supported: %v", a, Arches())
+	}
+	arch.Store(a)
+	return nil
+}
+
 func GetSyscallNumberByName(name string) (int, bool) {
-	number, ok := syscallsNameToNumber[name]
+	return GetSyscallNumberByNameForArch(Arch(), name)
+}
+
+func GetSyscallNameByNumber(number int) (string, bool) {
+	return GetSyscallNameByNumberForArch(Arch(), number)
+}
+
+// GetSyscallNumberByNameForArch is like GetSyscallNumberByName for the given
+// architecture
+func GetSyscallNumberByNameForArch(a string, name string) (int, bool) {
+	number, ok := tables[a].nameToNumber[name]
 
 	return number, ok
 }
 
-func GetSyscallNameByNumber(number int) (string, bool) {
-	name, ok := syscallsNumberToName[number]
+// GetSyscallNameByNumberForArch is like GetSyscallNameByNumber for the given
+// architecture
+func GetSyscallNameByNumberForArch(a string, number int) (string, bool) {
+	name, ok := tables[a].numberToName[number]
 
 	return name, ok
 }
+
+// SyscallName returns the name of the syscall with the given number, or
+// syscall_<number> if it's unknown
+func SyscallName(number int) string {
+	return SyscallNameForArch(Arch(), number)
+}
+
+// SyscallNameForArch is like SyscallName for the given architecture
+func SyscallNameForArch(a string, number int) string {
+	if name, ok := GetSyscallNameByNumberForArch(a, number); ok {
+		return name
+	}
+	return "syscall_" + strconv.Itoa(number)
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 The Inspektor Gadget authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syscalls
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyscallNameForArch(t *testing.T) {
+	t.Parallel()
+
+	type testCase struct {
+		arch     string
+		number   int
+		expected string
+	}
+
+	tests := map[string]testCase{
+		"amd64": {
+			arch:     "amd64",
+			number:   257,
+			expected: "openat",
+		},
+		"arm64": {
+			arch:     "arm64",
+			number:   56,
+			expected: "openat",
+		},
+		"unknown_number": {
+			arch:     "amd64",
+			number:   9999,
+			expected: "syscall_9999",
+		},
+		"unknown_arch": {
+			arch:     "mips",
+			number:   257,
+			expected: "syscall_257",
+		},
+	}
+
+	for name, test := range tests {
+		test := test
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, test.expected, SyscallNameForArch(test.arch, test.number))
+		})
+	}
+}
+
+func TestNumberByNameForArch(t *testing.T) {
+	t.Parallel()
+
+	number, ok := GetSyscallNumberByNameForArch("amd64", "openat")
+	require.True(t, ok)
+	require.Equal(t, 257, number)
+
+	number, ok = GetSyscallNumberByNameForArch("arm64", "openat")
+	require.True(t, ok)
+	require.Equal(t, 56, number)
+
+	_, ok = GetSyscallNumberByNameForArch("arm64", "open")
+	require.False(t, ok)
+}
+
+// Not parallel: it changes the architecture used by the package
+func TestSetArch(t *testing.T) {
+	defer arch.Store(Arch())
+
+	require.ErrorContains(t, SetArch("mips"), `no syscall table for architecture "mips"`)
+
+	require.NoError(t, SetArch("arm64"))
+	require.Equal(t, "arm64", Arch())
+	require.Equal(t, "openat", SyscallName(56))
+
+	require.NoError(t, SetArch("amd64"))
+	require.Equal(t, "openat", SyscallName(257))
+}